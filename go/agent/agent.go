@@ -16,7 +16,13 @@
 
 package agent
 
-import "github.com/outbrain/orchestrator/go/inst"
+import (
+	"regexp"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/inst"
+)
 
 // LogicalVolume describes an LVM volume
 type LogicalVolume struct {
@@ -50,21 +56,82 @@ type Agent struct {
 	LogicalVolumes          []LogicalVolume
 	MountPoint              Mount
 	MySQLRunning            bool
+	MySQLStatusValid        bool // the agent responded to the MySQL status inquiry, hence MySQLRunning is known
 	MySQLDiskUsage          int64
 	MySQLPort               int64
 	MySQLDatadirDiskFree    int64
+	MySQLDatadirDiskTotal   int64
 	MySQLErrorLogTail       []string
 }
 
+// DatadirDiskUsedPercent returns how full the MySQL datadir's file system is: space in use (by any file) out of
+// the file system's total space. Returns 0 when the agent did not report the file system's total space.
+func (this *Agent) DatadirDiskUsedPercent() float64 {
+	if this.MySQLDatadirDiskTotal <= 0 {
+		return 0
+	}
+	usedSpace := this.MySQLDatadirDiskTotal - this.MySQLDatadirDiskFree
+	if usedSpace < 0 {
+		usedSpace = 0
+	}
+	return 100 * float64(usedSpace) / float64(this.MySQLDatadirDiskTotal)
+}
+
+// ErrorLogCrashMarker returns the most recent line in the MySQL error log tail which matches any of
+// AgentErrorLogCrashPatterns, or an empty string if there is none
+func (this *Agent) ErrorLogCrashMarker() string {
+	crashPatterns := []*regexp.Regexp{}
+	for _, pattern := range config.Config.AgentErrorLogCrashPatterns {
+		crashPattern, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Errorf("Invalid AgentErrorLogCrashPatterns entry %s: %+v", pattern, err)
+			continue
+		}
+		crashPatterns = append(crashPatterns, crashPattern)
+	}
+	for i := len(this.MySQLErrorLogTail) - 1; i >= 0; i-- {
+		for _, crashPattern := range crashPatterns {
+			if crashPattern.MatchString(this.MySQLErrorLogTail[i]) {
+				return this.MySQLErrorLogTail[i]
+			}
+		}
+	}
+	return ""
+}
+
+// SeedMethod is the means by which data is copied from source host to target host
+type SeedMethod string
+
+const (
+	SeedMethodLVM        SeedMethod = "lvm"
+	SeedMethodXtrabackup            = "xtrabackup"
+	SeedMethodMysqldump             = "mysqldump"
+	SeedMethodMydumper              = "mydumper"
+)
+
+// IsPhysical tells whether the method copies data files (requiring MySQL to be down on target host),
+// as opposed to a logical copy (requiring MySQL to be up on target host)
+func (this SeedMethod) IsPhysical() bool {
+	return this == SeedMethodLVM || this == SeedMethodXtrabackup
+}
+
 // SeedOperation makes for the high level data & state of a seed operation
 type SeedOperation struct {
 	SeedId         int64
 	TargetHostname string
 	SourceHostname string
+	SeedMethod     SeedMethod
 	StartTimestamp string
 	EndTimestamp   string
 	IsComplete     bool
 	IsSuccessful   bool
+	BytesCopied    int64
+	TotalBytes     int64
+}
+
+// ProgressPercent returns the copied portion of the seed, 0-100
+func (this *SeedOperation) ProgressPercent() int64 {
+	return progressPercent(this.BytesCopied, this.TotalBytes)
 }
 
 // SeedOperationState represents a single state (step) in a seed operation
@@ -74,6 +141,33 @@ type SeedOperationState struct {
 	StateTimestamp string
 	Action         string
 	ErrorMessage   string
+	Step           string
+}
+
+// SeedProgress is an agent's report on the data transfer of a streaming seed
+type SeedProgress struct {
+	BytesCopied  int64
+	TotalBytes   int64
+	IsComplete   bool
+	IsSuccessful bool
+	Error        string
+}
+
+// SeedReplicationCoordinates are the replication positions captured along with seed data
+type SeedReplicationCoordinates struct {
+	BinlogCoordinates       inst.BinlogCoordinates // Seed source's own binary log position
+	MasterBinlogCoordinates inst.BinlogCoordinates // Seed source's position in its master's binary logs, if source is a slave
+	ExecutedGtidSet         string                 // Seed source's executed GTID set, if using Oracle GTID
+}
+
+func progressPercent(bytesCopied int64, totalBytes int64) int64 {
+	if totalBytes <= 0 {
+		return 0
+	}
+	if bytesCopied >= totalBytes {
+		return 100
+	}
+	return 100 * bytesCopied / totalBytes
 }
 
 // Build an instance key for a given agent
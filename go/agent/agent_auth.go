@@ -0,0 +1,180 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package agent
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/pmylund/go-cache"
+)
+
+// AgentRequest is the identity presented by an agent when registering or rotating its token
+type AgentRequest struct {
+	Hostname          string
+	RequestPath       string
+	RemoteAddr        string
+	Timestamp         string
+	Nonce             string
+	Signature         string
+	ClientCertificate *x509.Certificate // leaf of a verified client certificate chain (mutual TLS), if any
+}
+
+// lookupHost resolves hostnames; tests override it
+var lookupHost = net.LookupHost
+
+// seenAgentRequestNonces holds nonces of accepted signed requests, for as long as these requests are not expired
+var seenAgentRequestNonces = cache.New(time.Hour, time.Minute)
+
+// SignAgentRequest returns the signature expected on a request to given path at given timestamp (unix seconds)
+// with given nonce: the hex encoded HMAC-SHA256 of "<path>:<timestamp>:<nonce>" using given secret
+func SignAgentRequest(secret string, requestPath string, timestamp string, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%s:%s:%s", requestPath, timestamp, nonce)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CertificateFingerprint returns the hex encoded SHA256 digest of given certificate
+func CertificateFingerprint(certificate *x509.Certificate) string {
+	if certificate == nil {
+		return ""
+	}
+	digest := sha256.Sum256(certificate.Raw)
+	return hex.EncodeToString(digest[:])
+}
+
+// verifySignature validates a signed request against AgentRegistrationSecret. A signed request is accepted once:
+// its nonce is remembered until the request expires.
+func (this *AgentRequest) verifySignature(now time.Time) error {
+	if this.Signature == "" || this.Timestamp == "" || this.Nonce == "" {
+		return fmt.Errorf("Agent request for %s is not signed", this.Hostname)
+	}
+	timestamp, err := strconv.ParseInt(this.Timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid timestamp on agent request for %s: %s", this.Hostname, this.Timestamp)
+	}
+	skew := now.Sub(time.Unix(timestamp, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	maxSkew := time.Duration(config.Config.AgentRegistrationMaxSkewSeconds) * time.Second
+	if skew > maxSkew {
+		return fmt.Errorf("Agent request for %s is expired; timestamp: %s", this.Hostname, this.Timestamp)
+	}
+	expectedSignature := SignAgentRequest(config.Config.AgentRegistrationSecret, this.RequestPath, this.Timestamp, this.Nonce)
+	if !hmac.Equal([]byte(expectedSignature), []byte(this.Signature)) {
+		return fmt.Errorf("Invalid signature on agent request for %s", this.Hostname)
+	}
+	// A request timestamped up to maxSkew in the future remains acceptable for 2*maxSkew
+	if err := seenAgentRequestNonces.Add(this.Nonce, true, 2*maxSkew+time.Second); err != nil {
+		return fmt.Errorf("Replayed agent request for %s; nonce: %s", this.Hostname, this.Nonce)
+	}
+	return nil
+}
+
+// verifyHostname validates the hostname an agent claims against its client certificate, or, lacking one,
+// against the address the request came from
+func (this *AgentRequest) verifyHostname() error {
+	if this.ClientCertificate != nil {
+		if err := this.ClientCertificate.VerifyHostname(this.Hostname); err != nil {
+			return fmt.Errorf("Agent client certificate does not match hostname %s: %+v", this.Hostname, err)
+		}
+		return nil
+	}
+	remoteIP, _, err := net.SplitHostPort(this.RemoteAddr)
+	if err != nil {
+		remoteIP = this.RemoteAddr
+	}
+	if ip := net.ParseIP(remoteIP); ip == nil {
+		return fmt.Errorf("Cannot parse source address of agent request for %s: %s", this.Hostname, this.RemoteAddr)
+	}
+	addresses := []string{this.Hostname}
+	if net.ParseIP(this.Hostname) == nil {
+		if addresses, err = lookupHost(this.Hostname); err != nil {
+			return fmt.Errorf("Cannot resolve agent hostname %s: %+v", this.Hostname, err)
+		}
+	}
+	for _, address := range addresses {
+		if net.ParseIP(address).Equal(net.ParseIP(remoteIP)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Agent hostname %s does not resolve to request source address %s", this.Hostname, remoteIP)
+}
+
+// isAgentAuthenticationConfigured returns true when any agent registration check is configured
+func isAgentAuthenticationConfigured() bool {
+	return config.Config.AgentRegistrationSecret != "" || config.Config.AgentRegistrationVerifyHostname || config.Config.AgentRegistrationPinCertificate
+}
+
+// authenticate applies configured checks (AgentRegistrationSecret, AgentRegistrationVerifyHostname) on this request.
+// With none configured, any request is accepted.
+func (this *AgentRequest) authenticate(now time.Time) error {
+	if config.Config.AgentRegistrationSecret != "" {
+		if err := this.verifySignature(now); err != nil {
+			return err
+		}
+	}
+	if config.Config.AgentRegistrationVerifyHostname {
+		if err := this.verifyHostname(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyCertificatePin validates the presented client certificate against the one pinned on former registration,
+// if any (see AgentRegistrationPinCertificate)
+func (this *AgentRequest) verifyCertificatePin(pinnedFingerprint string) error {
+	if !config.Config.AgentRegistrationPinCertificate || pinnedFingerprint == "" {
+		return nil
+	}
+	if CertificateFingerprint(this.ClientCertificate) != pinnedFingerprint {
+		return fmt.Errorf("Agent %s is pinned to a different client certificate; rotate the agent's token to re-pin", this.Hostname)
+	}
+	return nil
+}
+
+// hasValidCertificatePin returns true when certificates are pinned and this request presents the pinned certificate
+func (this *AgentRequest) hasValidCertificatePin(pinnedFingerprint string) bool {
+	return config.Config.AgentRegistrationPinCertificate && pinnedFingerprint != "" && this.verifyCertificatePin(pinnedFingerprint) == nil
+}
+
+// verifyReregistration validates a registration of an already registered agent: with agent authentication configured,
+// the agent must present its current token or its pinned certificate. Changing the token otherwise requires rotation.
+func (this *AgentRequest) verifyReregistration(token string, currentToken string, pinnedFingerprint string) error {
+	if currentToken == "" || !isAgentAuthenticationConfigured() {
+		return nil
+	}
+	if tokensEqual(token, currentToken) || this.hasValidCertificatePin(pinnedFingerprint) {
+		return nil
+	}
+	return fmt.Errorf("Agent %s is already registered; re-registration requires its current token or pinned certificate. Rotate the agent's token instead", this.Hostname)
+}
+
+// tokensEqual compares tokens in constant time
+func tokensEqual(token string, otherToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(otherToken)) == 1
+}
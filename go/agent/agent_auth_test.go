@@ -0,0 +1,188 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package agent
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+const submitAgentPath = "/api/submit-agent/db1.example.com/3002/e9e1bcd1e9f8c7ce"
+
+func withAgentRegistrationConfig(t *testing.T, secret string, verifyHostname bool, pinCertificate bool, f func()) {
+	originalSecret := config.Config.AgentRegistrationSecret
+	originalVerifyHostname := config.Config.AgentRegistrationVerifyHostname
+	originalPinCertificate := config.Config.AgentRegistrationPinCertificate
+	originalLookupHost := lookupHost
+	defer func() {
+		config.Config.AgentRegistrationSecret = originalSecret
+		config.Config.AgentRegistrationVerifyHostname = originalVerifyHostname
+		config.Config.AgentRegistrationPinCertificate = originalPinCertificate
+		lookupHost = originalLookupHost
+	}()
+	config.Config.AgentRegistrationSecret = secret
+	config.Config.AgentRegistrationVerifyHostname = verifyHostname
+	config.Config.AgentRegistrationPinCertificate = pinCertificate
+	lookupHost = func(hostname string) ([]string, error) {
+		if hostname == "db1.example.com" {
+			return []string{"10.0.0.11", "fd00::11"}, nil
+		}
+		return nil, fmt.Errorf("no such host: %s", hostname)
+	}
+	f()
+}
+
+var testAgentRequestNonce int64
+
+func newSignedAgentRequest(secret string, timestamp time.Time) *AgentRequest {
+	unixTimestamp := fmt.Sprintf("%d", timestamp.Unix())
+	nonce := fmt.Sprintf("%d", atomic.AddInt64(&testAgentRequestNonce, 1))
+	return &AgentRequest{
+		Hostname:    "db1.example.com",
+		RequestPath: submitAgentPath,
+		RemoteAddr:  "10.0.0.11:53412",
+		Timestamp:   unixTimestamp,
+		Nonce:       nonce,
+		Signature:   SignAgentRequest(secret, submitAgentPath, unixTimestamp, nonce),
+	}
+}
+
+func TestAgentRequestNoAuthentication(t *testing.T) {
+	withAgentRegistrationConfig(t, "", false, false, func() {
+		request := &AgentRequest{Hostname: "db1.example.com", RequestPath: submitAgentPath, RemoteAddr: "192.168.0.1:1234"}
+		test.S(t).ExpectNil(request.authenticate(time.Now()))
+	})
+}
+
+func TestAgentRequestSignature(t *testing.T) {
+	withAgentRegistrationConfig(t, "s3cr3t", false, false, func() {
+		now := time.Now()
+		test.S(t).ExpectNil(newSignedAgentRequest("s3cr3t", now).authenticate(now))
+		test.S(t).ExpectNil(newSignedAgentRequest("s3cr3t", now.Add(-time.Minute)).authenticate(now))
+		test.S(t).ExpectNotNil(newSignedAgentRequest("s3cr3t", now.Add(-time.Hour)).authenticate(now))
+		test.S(t).ExpectNotNil(newSignedAgentRequest("other", now).authenticate(now))
+
+		request := newSignedAgentRequest("s3cr3t", now)
+		request.RequestPath = "/api/submit-agent/rogue.example.com/3002/e9e1bcd1e9f8c7ce"
+		test.S(t).ExpectNotNil(request.authenticate(now))
+
+		request = newSignedAgentRequest("s3cr3t", now)
+		request.Signature = ""
+		test.S(t).ExpectNotNil(request.authenticate(now))
+
+		request = newSignedAgentRequest("s3cr3t", now)
+		request.Nonce = "tampered"
+		test.S(t).ExpectNotNil(request.authenticate(now))
+	})
+}
+
+func TestAgentRequestReplay(t *testing.T) {
+	withAgentRegistrationConfig(t, "s3cr3t", false, false, func() {
+		now := time.Now()
+		request := newSignedAgentRequest("s3cr3t", now)
+		test.S(t).ExpectNil(request.authenticate(now))
+		test.S(t).ExpectNotNil(request.authenticate(now))
+
+		unsigned := newSignedAgentRequest("s3cr3t", now)
+		unsigned.Nonce = ""
+		unsigned.Signature = SignAgentRequest("s3cr3t", submitAgentPath, unsigned.Timestamp, "")
+		test.S(t).ExpectNotNil(unsigned.authenticate(now))
+	})
+}
+
+func TestAgentRequestVerifyHostname(t *testing.T) {
+	withAgentRegistrationConfig(t, "", true, false, func() {
+		request := &AgentRequest{Hostname: "db1.example.com", RemoteAddr: "10.0.0.11:53412"}
+		test.S(t).ExpectNil(request.authenticate(time.Now()))
+		request.RemoteAddr = "[fd00::11]:53412"
+		test.S(t).ExpectNil(request.authenticate(time.Now()))
+		request.RemoteAddr = "10.0.0.66:53412"
+		test.S(t).ExpectNotNil(request.authenticate(time.Now()))
+
+		request = &AgentRequest{Hostname: "db2.example.com", RemoteAddr: "10.0.0.11:53412"}
+		test.S(t).ExpectNotNil(request.authenticate(time.Now()))
+		request = &AgentRequest{Hostname: "10.0.0.12", RemoteAddr: "10.0.0.12:53412"}
+		test.S(t).ExpectNil(request.authenticate(time.Now()))
+
+		// A client certificate takes precedence over source address
+		request = &AgentRequest{Hostname: "db1.example.com", RemoteAddr: "10.0.0.66:53412", ClientCertificate: &x509.Certificate{DNSNames: []string{"db1.example.com"}}}
+		test.S(t).ExpectNil(request.authenticate(time.Now()))
+		request.ClientCertificate = &x509.Certificate{DNSNames: []string{"db2.example.com"}}
+		test.S(t).ExpectNotNil(request.authenticate(time.Now()))
+	})
+}
+
+func TestAgentRequestCertificatePin(t *testing.T) {
+	certificate := &x509.Certificate{Raw: []byte("db1 certificate")}
+	otherCertificate := &x509.Certificate{Raw: []byte("rogue certificate")}
+	request := &AgentRequest{Hostname: "db1.example.com", ClientCertificate: certificate}
+	withAgentRegistrationConfig(t, "", false, true, func() {
+		test.S(t).ExpectNil(request.verifyCertificatePin(""))
+		test.S(t).ExpectNil(request.verifyCertificatePin(CertificateFingerprint(certificate)))
+		test.S(t).ExpectNotNil(request.verifyCertificatePin(CertificateFingerprint(otherCertificate)))
+		request.ClientCertificate = nil
+		test.S(t).ExpectNotNil(request.verifyCertificatePin(CertificateFingerprint(certificate)))
+	})
+	withAgentRegistrationConfig(t, "", false, false, func() {
+		test.S(t).ExpectNil(request.verifyCertificatePin(CertificateFingerprint(otherCertificate)))
+	})
+}
+
+func TestAgentRequestReregistration(t *testing.T) {
+	certificate := &x509.Certificate{Raw: []byte("db1 certificate")}
+	request := &AgentRequest{Hostname: "db1.example.com", ClientCertificate: certificate}
+	withAgentRegistrationConfig(t, "s3cr3t", false, false, func() {
+		// New agent
+		test.S(t).ExpectNil(request.verifyReregistration("0f2c4b4ad41c9b6e", "", ""))
+		// Same token
+		test.S(t).ExpectNil(request.verifyReregistration("e9e1bcd1e9f8c7ce", "e9e1bcd1e9f8c7ce", ""))
+		// Other token, with no certificate pinning
+		test.S(t).ExpectNotNil(request.verifyReregistration("0f2c4b4ad41c9b6e", "e9e1bcd1e9f8c7ce", CertificateFingerprint(certificate)))
+	})
+	withAgentRegistrationConfig(t, "", false, true, func() {
+		test.S(t).ExpectNil(request.verifyReregistration("0f2c4b4ad41c9b6e", "e9e1bcd1e9f8c7ce", CertificateFingerprint(certificate)))
+		// Nothing pinned
+		test.S(t).ExpectNotNil(request.verifyReregistration("0f2c4b4ad41c9b6e", "e9e1bcd1e9f8c7ce", ""))
+	})
+	withAgentRegistrationConfig(t, "", false, false, func() {
+		test.S(t).ExpectNil(request.verifyReregistration("0f2c4b4ad41c9b6e", "e9e1bcd1e9f8c7ce", ""))
+	})
+}
+
+func TestSubmitAgentRejected(t *testing.T) {
+	originalDatabaselessMode := config.Config.DatabaselessMode__experimental
+	defer func() { config.Config.DatabaselessMode__experimental = originalDatabaselessMode }()
+	config.Config.DatabaselessMode__experimental = true
+
+	withAgentRegistrationConfig(t, "s3cr3t", false, false, func() {
+		_, err := SubmitAgent("db1.example.com", 3002, "e9e1bcd1e9f8c7ce", &AgentRequest{Hostname: "db1.example.com", RequestPath: submitAgentPath})
+		test.S(t).ExpectNotNil(err)
+		hostname, err := SubmitAgent("db1.example.com", 3002, "e9e1bcd1e9f8c7ce", newSignedAgentRequest("s3cr3t", time.Now()))
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(hostname, "db1.example.com")
+
+		// Unknown agent (no backend in databaseless mode)
+		_, err = RotateAgentToken("db1.example.com", "e9e1bcd1e9f8c7ce", "0f2c4b4ad41c9b6e", newSignedAgentRequest("s3cr3t", time.Now()))
+		test.S(t).ExpectNotNil(err)
+	})
+}
@@ -0,0 +1,378 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/golib/sqlutils"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/db"
+	"github.com/outbrain/orchestrator/go/inst"
+)
+
+var customCommandNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+var customCommandExitStatusRegexp = regexp.MustCompile(`exit status ([0-9]+)`)
+
+// CustomCommandRequest is a request to execute a custom command on agents
+type CustomCommandRequest struct {
+	Command     string
+	Arguments   []string
+	RequestedBy string
+	CheckRoles  bool // whether RequestedBy must have a role allowed by AgentCustomCommands; CLI requests are trusted
+}
+
+// CustomCommandResult is the outcome of a custom command on a single agent
+type CustomCommandResult struct {
+	Hostname     string
+	Command      string
+	Arguments    []string
+	IsSuccessful bool
+	ExitStatus   int // -1 when the command could not be executed at all
+	Error        string
+	Output       string
+}
+
+// CustomCommandFanOutResult aggregates the outcome of a custom command executed on multiple agents
+type CustomCommandFanOutResult struct {
+	Command         string
+	Arguments       []string
+	CountHosts      int
+	CountSuccessful int
+	CountFailed     int
+	Results         []CustomCommandResult
+}
+
+// CustomCommandAudit is an audited custom command execution (or rejection)
+type CustomCommandAudit struct {
+	AuditId        int64
+	AuditTimestamp string
+	RequestedBy    string
+	CustomCommandResult
+}
+
+// getCustomCommandRule returns the AgentCustomCommands entry for given command, or nil if there is none
+func getCustomCommandRule(command string) *config.CommandRule {
+	for i := range config.Config.AgentCustomCommands {
+		if config.Config.AgentCustomCommands[i].Command == command {
+			return &config.Config.AgentCustomCommands[i]
+		}
+	}
+	return nil
+}
+
+// isUserInRoles checks whether given user has any of given roles (see AuthRoles). No roles, or "*", stand for any user
+func isUserInRoles(user string, roles []string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	for _, role := range roles {
+		if role == "*" {
+			return true
+		}
+		for _, roleUser := range config.Config.AuthRoles[role] {
+			if user != "" && roleUser == user {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateCustomCommand checks a request against the AgentCustomCommands allowlist: command, arguments & roles.
+// With no allowlist configured, any well formed command without arguments is valid.
+func validateCustomCommand(request *CustomCommandRequest) error {
+	if !customCommandNameRegexp.MatchString(request.Command) {
+		return fmt.Errorf("Invalid custom command name: %s", request.Command)
+	}
+	if len(config.Config.AgentCustomCommands) == 0 {
+		if len(request.Arguments) > 0 {
+			return fmt.Errorf("Custom command %s: arguments are only supported for commands listed in AgentCustomCommands", request.Command)
+		}
+		return nil
+	}
+	rule := getCustomCommandRule(request.Command)
+	if rule == nil {
+		return fmt.Errorf("Custom command %s is not listed in AgentCustomCommands", request.Command)
+	}
+	if len(request.Arguments) > len(rule.ArgumentPatterns) {
+		return fmt.Errorf("Custom command %s accepts at most %d arguments; got %d", request.Command, len(rule.ArgumentPatterns), len(request.Arguments))
+	}
+	for i, argument := range request.Arguments {
+		argumentRegexp, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", rule.ArgumentPatterns[i]))
+		if err != nil {
+			return fmt.Errorf("Custom command %s: invalid pattern for argument %d: %+v", request.Command, i+1, err)
+		}
+		if !argumentRegexp.MatchString(argument) {
+			return fmt.Errorf("Custom command %s: argument %d (%s) does not match %s", request.Command, i+1, argument, rule.ArgumentPatterns[i])
+		}
+	}
+	if request.CheckRoles && !isUserInRoles(request.RequestedBy, rule.Roles) {
+		return fmt.Errorf("User %s is not allowed to execute custom command %s", request.RequestedBy, request.Command)
+	}
+	return nil
+}
+
+// parseCustomCommandResponse reads the output or failure of a custom command from an agent's response
+func parseCustomCommandResponse(body []byte) (output string, commandErr error) {
+	apiResponse := struct {
+		Code    string
+		Message string
+	}{}
+	if err := json.Unmarshal(body, &apiResponse); err == nil && apiResponse.Code == "ERROR" {
+		return "", errors.New(apiResponse.Message)
+	}
+	if err := json.Unmarshal(body, &output); err == nil {
+		return output, nil
+	}
+	return string(body), nil
+}
+
+// executeCustomCommand executes a validated custom command on a single agent
+func executeCustomCommand(hostname string, request *CustomCommandRequest) *CustomCommandResult {
+	result := &CustomCommandResult{
+		Hostname:   hostname,
+		Command:    request.Command,
+		Arguments:  request.Arguments,
+		ExitStatus: -1,
+	}
+	command := fmt.Sprintf("custom-commands/%s", request.Command)
+	if len(request.Arguments) > 0 {
+		command = fmt.Sprintf("%s?%s", command, url.Values{"arg": request.Arguments}.Encode())
+	}
+	var commandErr error
+	onResponse := func(body []byte) {
+		result.Output, commandErr = parseCustomCommandResponse(body)
+		log.Debugf("output: %v", result.Output)
+	}
+	if _, err := executeAgentCommand(hostname, command, &onResponse); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if commandErr != nil {
+		result.Error = commandErr.Error()
+		result.ExitStatus = 1
+		if submatch := customCommandExitStatusRegexp.FindStringSubmatch(result.Error); len(submatch) > 1 {
+			result.ExitStatus, _ = strconv.Atoi(submatch[1])
+		}
+		return result
+	}
+	result.IsSuccessful = true
+	result.ExitStatus = 0
+	return result
+}
+
+// truncateCustomCommandOutput truncates output to AgentCustomCommandAuditOutputBytes, never splitting a UTF-8 character
+func truncateCustomCommandOutput(output string) string {
+	maxBytes := config.Config.AgentCustomCommandAuditOutputBytes
+	if maxBytes < 0 || len(output) <= maxBytes {
+		return output
+	}
+	for maxBytes > 0 && !utf8.RuneStart(output[maxBytes]) {
+		maxBytes--
+	}
+	return output[:maxBytes]
+}
+
+// auditCustomCommand writes down a custom command execution (or rejection) in agent_custom_command_audit
+func auditCustomCommand(result *CustomCommandResult, requestedBy string) error {
+	arguments, _ := json.Marshal(result.Arguments)
+	_, err := db.ExecOrchestrator(`
+			insert 
+				into agent_custom_command_audit (
+					audit_timestamp, hostname, command, arguments, requested_by, is_successful, exit_status, error_message, output
+				) VALUES (
+					NOW(), ?, ?, ?, ?, ?, ?, ?, ?
+				)
+			`,
+		result.Hostname,
+		result.Command,
+		string(arguments),
+		requestedBy,
+		result.IsSuccessful,
+		result.ExitStatus,
+		result.Error,
+		truncateCustomCommandOutput(result.Output),
+	)
+	return log.Errore(err)
+}
+
+// ExecuteCustomCommand validates and executes a custom command on a single agent. Execution, as well as rejection,
+// is audited.
+func ExecuteCustomCommand(hostname string, request *CustomCommandRequest) (*CustomCommandResult, error) {
+	fanOutResult, err := ExecuteCustomCommandOnHosts([]string{hostname}, request)
+	if err != nil {
+		return nil, err
+	}
+	result := &fanOutResult.Results[0]
+	if !result.IsSuccessful {
+		return result, fmt.Errorf("Custom command %s failed on %s: %s", request.Command, hostname, result.Error)
+	}
+	return result, nil
+}
+
+// ExecuteCustomCommandOnHosts validates a custom command, then executes it concurrently on given agents and
+// aggregates the results. A request failing validation is audited per host and not executed anywhere.
+func ExecuteCustomCommandOnHosts(hostnames []string, request *CustomCommandRequest) (*CustomCommandFanOutResult, error) {
+	if err := validateCustomCommand(request); err != nil {
+		for _, hostname := range hostnames {
+			auditCustomCommand(&CustomCommandResult{Hostname: hostname, Command: request.Command, Arguments: request.Arguments, ExitStatus: -1, Error: err.Error()}, request.RequestedBy)
+		}
+		return nil, log.Errore(err)
+	}
+	fanOutResult := &CustomCommandFanOutResult{
+		Command:    request.Command,
+		Arguments:  request.Arguments,
+		CountHosts: len(hostnames),
+		Results:    make([]CustomCommandResult, len(hostnames)),
+	}
+	var wg sync.WaitGroup
+	for i, hostname := range hostnames {
+		i, hostname := i, hostname
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := executeCustomCommand(hostname, request)
+			auditCustomCommand(result, request.RequestedBy)
+			fanOutResult.Results[i] = *result
+		}()
+	}
+	wg.Wait()
+	for _, result := range fanOutResult.Results {
+		if result.IsSuccessful {
+			fanOutResult.CountSuccessful++
+		} else {
+			fanOutResult.CountFailed++
+		}
+	}
+	return fanOutResult, nil
+}
+
+// filterAgentsHostnames returns those of given hostnames which have a known agent, in order and without duplicates
+func filterAgentsHostnames(hostnames []string) ([]string, error) {
+	agents, err := ReadAgents()
+	if err != nil {
+		return nil, err
+	}
+	agentsHostnames := make(map[string]bool)
+	for _, agent := range agents {
+		agentsHostnames[agent.Hostname] = true
+	}
+	result := []string{}
+	for _, hostname := range hostnames {
+		if agentsHostnames[hostname] {
+			result = append(result, hostname)
+			delete(agentsHostnames, hostname)
+		}
+	}
+	return result, nil
+}
+
+// ReadClusterAgentsHostnames returns the hostnames of agents running on instances of given cluster
+func ReadClusterAgentsHostnames(clusterName string) ([]string, error) {
+	instances, err := inst.ReadClusterInstances(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	hostnames := []string{}
+	for _, instance := range instances {
+		hostnames = append(hostnames, instance.Key.Hostname)
+	}
+	return filterAgentsHostnames(hostnames)
+}
+
+// ReadPoolAgentsHostnames returns the hostnames of agents running on instances of given pool
+func ReadPoolAgentsHostnames(pool string) ([]string, error) {
+	clusterPoolInstances, err := inst.ReadAllClusterPoolInstances()
+	if err != nil {
+		return nil, err
+	}
+	hostnames := []string{}
+	for _, clusterPoolInstance := range clusterPoolInstances {
+		if clusterPoolInstance.Pool == pool {
+			hostnames = append(hostnames, clusterPoolInstance.Hostname)
+		}
+	}
+	return filterAgentsHostnames(hostnames)
+}
+
+// ReadCustomCommandAudit returns a page of audited custom commands, optionally filtered by hostname
+func ReadCustomCommandAudit(hostname string, page int) ([]CustomCommandAudit, error) {
+	res := []CustomCommandAudit{}
+	query := `
+		select 
+			audit_id,
+			audit_timestamp,
+			hostname,
+			command,
+			arguments,
+			requested_by,
+			is_successful,
+			exit_status,
+			error_message,
+			output
+		from 
+			agent_custom_command_audit
+		where
+			? in ('', hostname)
+		order by
+			audit_timestamp desc, audit_id desc
+		limit ?
+		offset ?
+		`
+	args := sqlutils.Args(hostname, config.Config.AuditPageSize, page*config.Config.AuditPageSize)
+	err := db.QueryOrchestrator(query, args, func(m sqlutils.RowMap) error {
+		audit := CustomCommandAudit{}
+		audit.AuditId = m.GetInt64("audit_id")
+		audit.AuditTimestamp = m.GetString("audit_timestamp")
+		audit.RequestedBy = m.GetString("requested_by")
+		audit.Hostname = m.GetString("hostname")
+		audit.Command = m.GetString("command")
+		json.Unmarshal([]byte(m.GetString("arguments")), &audit.Arguments)
+		audit.IsSuccessful = m.GetBool("is_successful")
+		audit.ExitStatus = m.GetInt("exit_status")
+		audit.Error = m.GetString("error_message")
+		audit.Output = m.GetString("output")
+
+		res = append(res, audit)
+		return nil
+	})
+	if err != nil {
+		log.Errore(err)
+	}
+	return res, err
+}
+
+// ExpireCustomCommandAudit removes old rows from agent_custom_command_audit
+func ExpireCustomCommandAudit() error {
+	_, err := db.ExecOrchestrator(`
+			delete 
+				from agent_custom_command_audit 
+			where 
+				audit_timestamp < NOW() - INTERVAL ? DAY`,
+		config.Config.AuditPurgeDays,
+	)
+	return log.Errore(err)
+}
@@ -0,0 +1,110 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package agent
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+func withCustomCommandsConfig(f func()) {
+	originalCommands := config.Config.AgentCustomCommands
+	originalRoles := config.Config.AuthRoles
+	defer func() {
+		config.Config.AgentCustomCommands = originalCommands
+		config.Config.AuthRoles = originalRoles
+	}()
+	config.Config.AgentCustomCommands = []config.CommandRule{
+		{Command: "restart-mysql", Roles: []string{"dba"}},
+		{Command: "purge-relay-logs", ArgumentPatterns: []string{`[0-9]+`, `relay|binary`}, Roles: []string{"*"}},
+		{Command: "flush-logs"},
+	}
+	config.Config.AuthRoles = config.RoleUsers{
+		"dba": []string{"alice", "bob"},
+	}
+	f()
+}
+
+func TestValidateCustomCommandNoAllowlist(t *testing.T) {
+	originalCommands := config.Config.AgentCustomCommands
+	defer func() { config.Config.AgentCustomCommands = originalCommands }()
+	config.Config.AgentCustomCommands = []config.CommandRule{}
+
+	test.S(t).ExpectNil(validateCustomCommand(&CustomCommandRequest{Command: "anything"}))
+	test.S(t).ExpectNotNil(validateCustomCommand(&CustomCommandRequest{Command: "anything", Arguments: []string{"1"}}))
+	test.S(t).ExpectNotNil(validateCustomCommand(&CustomCommandRequest{Command: "../etc/passwd"}))
+	test.S(t).ExpectNotNil(validateCustomCommand(&CustomCommandRequest{Command: ""}))
+}
+
+func TestValidateCustomCommandAllowlist(t *testing.T) {
+	withCustomCommandsConfig(func() {
+		test.S(t).ExpectNil(validateCustomCommand(&CustomCommandRequest{Command: "flush-logs"}))
+		test.S(t).ExpectNotNil(validateCustomCommand(&CustomCommandRequest{Command: "drop-everything"}))
+		test.S(t).ExpectNotNil(validateCustomCommand(&CustomCommandRequest{Command: "flush-logs", Arguments: []string{"1"}}))
+	})
+}
+
+func TestValidateCustomCommandArguments(t *testing.T) {
+	withCustomCommandsConfig(func() {
+		test.S(t).ExpectNil(validateCustomCommand(&CustomCommandRequest{Command: "purge-relay-logs"}))
+		test.S(t).ExpectNil(validateCustomCommand(&CustomCommandRequest{Command: "purge-relay-logs", Arguments: []string{"7"}}))
+		test.S(t).ExpectNil(validateCustomCommand(&CustomCommandRequest{Command: "purge-relay-logs", Arguments: []string{"7", "binary"}}))
+		test.S(t).ExpectNotNil(validateCustomCommand(&CustomCommandRequest{Command: "purge-relay-logs", Arguments: []string{"7; rm -rf /"}}))
+		test.S(t).ExpectNotNil(validateCustomCommand(&CustomCommandRequest{Command: "purge-relay-logs", Arguments: []string{"7", "binaryx"}}))
+		test.S(t).ExpectNotNil(validateCustomCommand(&CustomCommandRequest{Command: "purge-relay-logs", Arguments: []string{"7", "relay", "more"}}))
+	})
+}
+
+func TestValidateCustomCommandRoles(t *testing.T) {
+	withCustomCommandsConfig(func() {
+		test.S(t).ExpectNil(validateCustomCommand(&CustomCommandRequest{Command: "restart-mysql", RequestedBy: "alice", CheckRoles: true}))
+		test.S(t).ExpectNotNil(validateCustomCommand(&CustomCommandRequest{Command: "restart-mysql", RequestedBy: "mallory", CheckRoles: true}))
+		test.S(t).ExpectNotNil(validateCustomCommand(&CustomCommandRequest{Command: "restart-mysql", RequestedBy: "", CheckRoles: true}))
+		test.S(t).ExpectNil(validateCustomCommand(&CustomCommandRequest{Command: "restart-mysql", RequestedBy: "cli"}))
+		test.S(t).ExpectNil(validateCustomCommand(&CustomCommandRequest{Command: "purge-relay-logs", RequestedBy: "mallory", CheckRoles: true}))
+		test.S(t).ExpectNil(validateCustomCommand(&CustomCommandRequest{Command: "flush-logs", RequestedBy: "mallory", CheckRoles: true}))
+	})
+}
+
+func TestParseCustomCommandResponse(t *testing.T) {
+	output, err := parseCustomCommandResponse([]byte(`"all good\n"`))
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(output, "all good\n")
+
+	output, err = parseCustomCommandResponse([]byte(`{"Code":"ERROR","Message":"exit status 3"}`))
+	test.S(t).ExpectNotNil(err)
+	test.S(t).ExpectEquals(err.Error(), "exit status 3")
+
+	output, err = parseCustomCommandResponse([]byte(`plain text`))
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(output, "plain text")
+}
+
+func TestTruncateCustomCommandOutput(t *testing.T) {
+	originalBytes := config.Config.AgentCustomCommandAuditOutputBytes
+	defer func() { config.Config.AgentCustomCommandAuditOutputBytes = originalBytes }()
+
+	config.Config.AgentCustomCommandAuditOutputBytes = 4
+	test.S(t).ExpectEquals(truncateCustomCommandOutput("abcdefgh"), "abcd")
+	test.S(t).ExpectEquals(truncateCustomCommandOutput("ab"), "ab")
+	// "é" and "€" take 2 and 3 bytes, respectively
+	test.S(t).ExpectEquals(truncateCustomCommandOutput("abcé"), "abc")
+	test.S(t).ExpectEquals(truncateCustomCommandOutput("a€bc"), "a€")
+	test.S(t).ExpectEquals(truncateCustomCommandOutput("ab€c"), "ab")
+}
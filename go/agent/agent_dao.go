@@ -25,6 +25,7 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/outbrain/golib/log"
@@ -32,14 +33,29 @@ import (
 	"github.com/outbrain/orchestrator/go/config"
 	"github.com/outbrain/orchestrator/go/db"
 	"github.com/outbrain/orchestrator/go/inst"
+	"github.com/pmylund/go-cache"
 )
 
 var SeededAgents chan *Agent = make(chan *Agent)
 
+// unsupportedAgentInquiries remembers inquiries which agents of older versions do not serve, so as not to make them
+// on every poll. Entries expire, as agents may be upgraded.
+var unsupportedAgentInquiries = cache.New(time.Hour, time.Minute)
+
+// readAgentBasicInfoFunc looks up an agent's address and token. Tests point it at fake agents.
+var readAgentBasicInfoFunc = readAgentBasicInfo
+
+// httpClientMutex guards httpTimeout, httpTransport and httpClient, which are replaced upon HttpTimeoutSeconds change
+var httpClientMutex sync.RWMutex
+
 var httpTimeout = time.Duration(time.Duration(config.Config.HttpTimeoutSeconds) * time.Second)
 
 func dialTimeout(network, addr string) (net.Conn, error) {
-	return net.DialTimeout(network, addr, httpTimeout)
+	httpClientMutex.RLock()
+	timeout := httpTimeout
+	httpClientMutex.RUnlock()
+
+	return net.DialTimeout(network, addr, timeout)
 }
 
 var httpTransport = &http.Transport{
@@ -49,9 +65,30 @@ var httpTransport = &http.Transport{
 }
 var httpClient = &http.Client{Transport: httpTransport}
 
+func init() {
+	config.OnChange(func(changes config.ConfigurationChanges) {
+		if changes.HasField("HttpTimeoutSeconds") {
+			httpClientMutex.Lock()
+			defer httpClientMutex.Unlock()
+
+			httpTimeout = time.Duration(config.Config.HttpTimeoutSeconds) * time.Second
+			httpTransport = &http.Transport{
+				TLSClientConfig:       &tls.Config{InsecureSkipVerify: config.Config.AgentSSLSkipVerify},
+				Dial:                  dialTimeout,
+				ResponseHeaderTimeout: httpTimeout,
+			}
+			httpClient = &http.Client{Transport: httpTransport}
+		}
+	})
+}
+
 // httpGet is a convenience method for getting http response from URL, optionaly skipping SSL cert verification
 func httpGet(url string) (resp *http.Response, err error) {
-	return httpClient.Get(url)
+	httpClientMutex.RLock()
+	client := httpClient
+	httpClientMutex.RUnlock()
+
+	return client.Get(url)
 }
 
 // AuditAgentOperation creates and writes a new audit entry by given agent
@@ -83,19 +120,67 @@ func readResponse(res *http.Response, err error) ([]byte, error) {
 	return body, nil
 }
 
-// SubmitAgent submits a new agent for listing
-func SubmitAgent(hostname string, port int, token string) (string, error) {
-	_, err := db.ExecOrchestrator(`
-			replace 
+// readAgentRegistration returns the token and pinned certificate fingerprint of a registered agent. An unknown
+// agent has an empty token.
+func readAgentRegistration(hostname string) (token string, certificateFingerprint string, err error) {
+	query := `
+		select 
+			token,
+			certificate_fingerprint
+		from 
+			host_agent
+		where
+			hostname = ?
+		`
+	err = db.QueryOrchestrator(query, sqlutils.Args(hostname), func(m sqlutils.RowMap) error {
+		token = m.GetString("token")
+		certificateFingerprint = m.GetString("certificate_fingerprint")
+		return nil
+	})
+	return token, certificateFingerprint, err
+}
+
+// rejectAgentRequest audits and returns a failed agent authentication
+func rejectAgentRequest(hostname string, err error) error {
+	auditAgentOperation("agent-request-rejected", &Agent{Hostname: hostname}, err.Error())
+	return log.Errore(err)
+}
+
+// SubmitAgent submits a new agent for listing. The request is authenticated as configured (signature, hostname
+// verification, certificate pinning) before the agent's hostname, port & token are trusted. An already registered
+// agent must further present its current token or its pinned certificate.
+func SubmitAgent(hostname string, port int, token string, request *AgentRequest) (string, error) {
+	if err := request.authenticate(time.Now()); err != nil {
+		return "", rejectAgentRequest(hostname, err)
+	}
+	currentToken, pinnedFingerprint, err := readAgentRegistration(hostname)
+	if err != nil {
+		return "", log.Errore(err)
+	}
+	if err := request.verifyCertificatePin(pinnedFingerprint); err != nil {
+		return "", rejectAgentRequest(hostname, err)
+	}
+	if err := request.verifyReregistration(token, currentToken, pinnedFingerprint); err != nil {
+		return "", rejectAgentRequest(hostname, err)
+	}
+	// An empty fingerprint (no client certificate) never replaces a pinned one
+	_, err = db.ExecOrchestrator(`
+			insert 
 				into host_agent (
-					hostname, port, token, last_submitted
+					hostname, port, token, certificate_fingerprint, last_submitted
 				) VALUES (
-					?, ?, ?, NOW()
+					?, ?, ?, ?, NOW()
 				)
+				on duplicate key update
+					port = values(port),
+					token = values(token),
+					certificate_fingerprint = if(values(certificate_fingerprint) = '', certificate_fingerprint, values(certificate_fingerprint)),
+					last_submitted = values(last_submitted)
 			`,
 		hostname,
 		port,
 		token,
+		CertificateFingerprint(request.ClientCertificate),
 	)
 	if err != nil {
 		return "", log.Errore(err)
@@ -109,6 +194,48 @@ func SubmitAgent(hostname string, port int, token string) (string, error) {
 	return hostname, err
 }
 
+// RotateAgentToken replaces a registered agent's token, given its current token. The agent's client certificate,
+// if presented, is re-pinned.
+func RotateAgentToken(hostname string, token string, newToken string, request *AgentRequest) (string, error) {
+	if err := request.authenticate(time.Now()); err != nil {
+		return "", rejectAgentRequest(hostname, err)
+	}
+	if newToken == "" {
+		return "", log.Errorf("Empty new token for agent %s", hostname)
+	}
+	currentToken, _, err := readAgentRegistration(hostname)
+	if err != nil {
+		return "", log.Errore(err)
+	}
+	if currentToken == "" {
+		return "", rejectAgentRequest(hostname, fmt.Errorf("Unknown agent: %s", hostname))
+	}
+	if !tokensEqual(token, currentToken) {
+		return "", rejectAgentRequest(hostname, fmt.Errorf("Invalid token for agent %s", hostname))
+	}
+	_, err = db.ExecOrchestrator(`
+			update 
+				host_agent 
+			set
+				token = ?,
+				certificate_fingerprint = if(? = '', certificate_fingerprint, ?),
+				last_submitted = NOW()
+			where 
+				hostname = ?
+			`,
+		newToken,
+		CertificateFingerprint(request.ClientCertificate),
+		CertificateFingerprint(request.ClientCertificate),
+		hostname,
+	)
+	if err != nil {
+		return "", log.Errore(err)
+	}
+	auditAgentOperation("rotate-agent-token", &Agent{Hostname: hostname}, "token rotated")
+
+	return hostname, nil
+}
+
 // If a mysql port is available, try to discover against it
 func DiscoverAgentInstance(hostname string, port int) error {
 	agent, err := GetAgent(hostname)
@@ -129,7 +256,25 @@ func DiscoverAgentInstance(hostname string, port int) error {
 
 // ForgetLongUnseenAgents will remove entries of all agents that have long since been last seen.
 func ForgetLongUnseenAgents() error {
-	_, err := db.ExecOrchestrator(`
+	forgottenHostnames := []string{}
+	query := `
+		select 
+			hostname 
+		from 
+			host_agent 
+		where 
+			last_submitted < NOW() - interval ? hour`
+	err := db.QueryOrchestrator(query, sqlutils.Args(config.Config.UnseenAgentForgetHours), func(m sqlutils.RowMap) error {
+		forgottenHostnames = append(forgottenHostnames, m.GetString("hostname"))
+		return nil
+	})
+	if err != nil {
+		return log.Errore(err)
+	}
+	for _, hostname := range forgottenHostnames {
+		unregisterAgentInventoryGauges(hostname)
+	}
+	_, err = db.ExecOrchestrator(`
 			delete 
 				from host_agent 
 			where 
@@ -250,7 +395,8 @@ func UpdateAgentLastChecked(hostname string) error {
 	return nil
 }
 
-// UpdateAgentInfo  updates some agent state in backend table
+// UpdateAgentInfo  updates some agent state in backend table, including the host's inventory as
+// used by replication analysis
 func UpdateAgentInfo(hostname string, agent Agent) error {
 	_, err := db.ExecOrchestrator(`
         	update 
@@ -258,16 +404,29 @@ func UpdateAgentInfo(hostname string, agent Agent) error {
         	set
         		last_seen = NOW(),
         		mysql_port = ?,
-        		count_mysql_snapshots = ?
+        		count_mysql_snapshots = ?,
+        		mysql_running = ?,
+        		mysql_status_valid = ?,
+        		mysql_disk_usage = ?,
+        		mysql_datadir_disk_free = ?,
+        		mysql_datadir_disk_used_percent = ?,
+        		mysql_error_log_crash_marker = ?
 			where 
 				hostname = ?`,
 		agent.MySQLPort,
 		len(agent.LogicalVolumes),
+		agent.MySQLRunning,
+		agent.MySQLStatusValid,
+		agent.MySQLDiskUsage,
+		agent.MySQLDatadirDiskFree,
+		agent.DatadirDiskUsedPercent(),
+		agent.ErrorLogCrashMarker(),
 		hostname,
 	)
 	if err != nil {
 		return log.Errore(err)
 	}
+	updateAgentInventoryGauges(hostname, &agent)
 
 	return nil
 }
@@ -285,12 +444,13 @@ func baseAgentUri(agentHostname string, agentPort int) string {
 
 // GetAgent gets a single agent status from the agent service. This involves multiple HTTP requests.
 func GetAgent(hostname string) (Agent, error) {
-	agent, token, err := readAgentBasicInfo(hostname)
+	agent, token, err := readAgentBasicInfoFunc(hostname)
 	if err != nil {
 		return agent, log.Errore(err)
 	}
 
 	// All seems to be in order. Now make some inquiries from orchestrator-agent service:
+	countRespondingInquiries := 0
 	{
 		uri := baseAgentUri(agent.Hostname, agent.Port)
 		log.Debugf("orchestrator-agent uri: %s", uri)
@@ -303,6 +463,8 @@ func GetAgent(hostname string) (Agent, error) {
 			}
 			if err != nil {
 				log.Errore(err)
+			} else {
+				countRespondingInquiries++
 			}
 		}
 		{
@@ -313,6 +475,8 @@ func GetAgent(hostname string) (Agent, error) {
 			}
 			if err != nil {
 				log.Errore(err)
+			} else {
+				countRespondingInquiries++
 			}
 		}
 		{
@@ -323,6 +487,8 @@ func GetAgent(hostname string) (Agent, error) {
 			}
 			if err != nil {
 				log.Errore(err)
+			} else {
+				countRespondingInquiries++
 			}
 		}
 		{
@@ -333,15 +499,23 @@ func GetAgent(hostname string) (Agent, error) {
 			}
 			if err != nil {
 				log.Errore(err)
+			} else {
+				countRespondingInquiries++
 			}
 		}
 		{
 			mySQLRunningUri := fmt.Sprintf("%s/mysql-status?token=%s", uri, token)
-			body, err := readResponse(httpGet(mySQLRunningUri))
+			response, err := httpGet(mySQLRunningUri)
+			// The agent's status command exits with non-zero code when MySQL is not running, and the agent then responds
+			// with an error. MySQL status is only unknown when the agent does not respond at all.
+			agent.MySQLStatusValid = (err == nil)
+			body, err := readResponse(response, err)
 			if err == nil {
 				err = json.Unmarshal(body, &agent.MySQLRunning)
 			}
-			// Actually an error is OK here since "status" returns with non-zero exit code when MySQL not running
+			if err != nil {
+				agent.MySQLRunning = false
+			}
 		}
 		{
 			mySQLRunningUri := fmt.Sprintf("%s/mysql-port?token=%s", uri, token)
@@ -351,6 +525,8 @@ func GetAgent(hostname string) (Agent, error) {
 			}
 			if err != nil {
 				log.Errore(err)
+			} else {
+				countRespondingInquiries++
 			}
 		}
 		{
@@ -361,6 +537,8 @@ func GetAgent(hostname string) (Agent, error) {
 			}
 			if err != nil {
 				log.Errore(err)
+			} else {
+				countRespondingInquiries++
 			}
 		}
 		{
@@ -371,6 +549,31 @@ func GetAgent(hostname string) (Agent, error) {
 			}
 			if err != nil {
 				log.Errore(err)
+			} else {
+				countRespondingInquiries++
+			}
+		}
+		{
+			// Not served by older agents, in which case datadir disk usage percent is unknown
+			unsupportedInquiryKey := fmt.Sprintf("%s:mysql-datadir-total-space", hostname)
+			if _, unsupported := unsupportedAgentInquiries.Get(unsupportedInquiryKey); !unsupported {
+				mySQLDatadirDiskTotalUri := fmt.Sprintf("%s/mysql-datadir-total-space?token=%s", uri, token)
+				response, err := httpGet(mySQLDatadirDiskTotalUri)
+				if err == nil && response.StatusCode == http.StatusNotFound {
+					response.Body.Close()
+					log.Debugf("Agent %s does not serve mysql-datadir-total-space", hostname)
+					unsupportedAgentInquiries.Set(unsupportedInquiryKey, true, cache.DefaultExpiration)
+				} else {
+					body, err := readResponse(response, err)
+					if err == nil {
+						err = json.Unmarshal(body, &agent.MySQLDatadirDiskTotal)
+					}
+					if err != nil {
+						log.Errore(err)
+					} else {
+						countRespondingInquiries++
+					}
+				}
 			}
 		}
 		{
@@ -381,15 +584,20 @@ func GetAgent(hostname string) (Agent, error) {
 			}
 			if err != nil {
 				log.Errore(err)
+			} else {
+				countRespondingInquiries++
 			}
 		}
 	}
+	if countRespondingInquiries == 0 {
+		return agent, fmt.Errorf("Agent %s did not respond to any inquiry", hostname)
+	}
 	return agent, err
 }
 
 // executeAgentCommand requests an agent to execute a command via HTTP api
 func executeAgentCommand(hostname string, command string, onResponse *func([]byte)) (Agent, error) {
-	agent, token, err := readAgentBasicInfo(hostname)
+	agent, token, err := readAgentBasicInfoFunc(hostname)
 	if err != nil {
 		return agent, err
 	}
@@ -468,14 +676,47 @@ func AbortSeedCommand(hostname string, seedId int64) (Agent, error) {
 	return executeAgentCommand(hostname, fmt.Sprintf("abort-seed/%d", seedId), nil)
 }
 
-func CustomCommand(hostname string, cmd string) (output string, err error) {
+// ReceiveSeedData requests an agent to start receiving streamed seed data of given method
+func ReceiveSeedData(hostname string, seedMethod SeedMethod, seedId int64) (Agent, error) {
+	return executeAgentCommand(hostname, fmt.Sprintf("receive-seed-data/%s/%d", seedMethod, seedId), nil)
+}
+
+// SendSeedData requests an agent to start streaming seed data of given method to target host
+func SendSeedData(hostname string, seedMethod SeedMethod, targetHostname string, seedId int64) (Agent, error) {
+	return executeAgentCommand(hostname, fmt.Sprintf("send-seed-data/%s/%s/%d", seedMethod, targetHostname, seedId), nil)
+}
+
+// PrepareSeedData requests an agent to make received seed data usable by MySQL (e.g. apply redo log of a physical backup)
+func PrepareSeedData(hostname string, seedMethod SeedMethod, seedId int64) (Agent, error) {
+	return executeAgentCommand(hostname, fmt.Sprintf("prepare-seed-data/%s/%d", seedMethod, seedId), nil)
+}
+
+// seedReplicationCoordinates asks the target agent of a seed for the replication positions captured with the seed data
+func seedReplicationCoordinates(hostname string, seedId int64) (Agent, *SeedReplicationCoordinates, error) {
+	coordinates := &SeedReplicationCoordinates{}
+	var unmarshalErr error
 	onResponse := func(body []byte) {
-		output = string(body)
-		log.Debugf("output: %v", output)
+		unmarshalErr = json.Unmarshal(body, coordinates)
 	}
+	agent, err := executeAgentCommand(hostname, fmt.Sprintf("seed-replication-coordinates/%d", seedId), &onResponse)
+	if err == nil && unmarshalErr != nil {
+		err = log.Errore(unmarshalErr)
+	}
+	return agent, coordinates, err
+}
 
-	_, err = executeAgentCommand(hostname, fmt.Sprintf("custom-commands/%s", cmd), &onResponse)
-	return output, err
+// seedProgress asks an agent how far it got in a streaming seed
+func seedProgress(hostname string, seedId int64) (Agent, *SeedProgress, error) {
+	progress := &SeedProgress{}
+	var unmarshalErr error
+	onResponse := func(body []byte) {
+		unmarshalErr = json.Unmarshal(body, progress)
+	}
+	agent, err := executeAgentCommand(hostname, fmt.Sprintf("seed-progress/%d", seedId), &onResponse)
+	if err == nil && unmarshalErr != nil {
+		err = log.Errore(unmarshalErr)
+	}
+	return agent, progress, err
 }
 
 // seedCommandCompleted checks an agent to see if it thinks a seed was completed.
@@ -519,17 +760,18 @@ func PostCopy(hostname string) (Agent, error) {
 }
 
 // SubmitSeedEntry submits a new seed operation entry, returning its unique ID
-func SubmitSeedEntry(targetHostname string, sourceHostname string) (int64, error) {
+func SubmitSeedEntry(targetHostname string, sourceHostname string, seedMethod SeedMethod) (int64, error) {
 	res, err := db.ExecOrchestrator(`
 			insert 
 				into agent_seed (
-					target_hostname, source_hostname, start_timestamp
+					target_hostname, source_hostname, seed_method, start_timestamp
 				) VALUES (
-					?, ?, NOW()
+					?, ?, ?, NOW()
 				)
 			`,
 		targetHostname,
 		sourceHostname,
+		string(seedMethod),
 	)
 	if err != nil {
 		return 0, log.Errore(err)
@@ -560,6 +802,39 @@ func updateSeedComplete(seedId int64, seedError error) error {
 	return nil
 }
 
+// updateSeedProgress records the amount of data copied so far
+func updateSeedProgress(seedId int64, bytesCopied int64, totalBytes int64) error {
+	_, err := db.ExecOrchestrator(`
+			update 
+				agent_seed
+					set bytes_copied = ?,
+					total_bytes = ?
+				where
+					agent_seed_id = ?
+			`,
+		bytesCopied,
+		totalBytes,
+		seedId,
+	)
+	return log.Errore(err)
+}
+
+// resetSeedForResume marks a seed as in progress again
+func resetSeedForResume(seedId int64) error {
+	_, err := db.ExecOrchestrator(`
+			update 
+				agent_seed
+					set end_timestamp = '1971-01-01 00:00:00',
+					is_complete = 0,
+					is_successful = 0
+				where
+					agent_seed_id = ?
+			`,
+		seedId,
+	)
+	return log.Errore(err)
+}
+
 // submitSeedStateEntry submits a seed state: a single step in the overall seed process
 func submitSeedStateEntry(seedId int64, action string, errorMessage string) (int64, error) {
 	res, err := db.ExecOrchestrator(`
@@ -601,6 +876,58 @@ func updateSeedStateEntry(seedStateId int64, reason error) error {
 	return reason
 }
 
+// markSeedStateStepComplete signs a seed state as the successful completion of a seed step, which
+// is then skipped when the seed is resumed
+func markSeedStateStepComplete(seedStateId int64, step string) error {
+	_, err := db.ExecOrchestrator(`
+			update 
+				agent_seed_state
+					set seed_step = ?
+				where
+					agent_seed_state_id = ?
+			`,
+		step,
+		seedStateId,
+	)
+	return log.Errore(err)
+}
+
+// unmarkSeedStepComplete has a completed step of given seed executed anew should the seed be resumed
+func unmarkSeedStepComplete(seedId int64, step string) error {
+	_, err := db.ExecOrchestrator(`
+			update
+				agent_seed_state
+					set seed_step = ''
+				where
+					agent_seed_id = ?
+					and seed_step = ?
+			`,
+		seedId,
+		step,
+	)
+	return log.Errore(err)
+}
+
+// readCompletedSeedSteps returns the steps of given seed which have completed successfully
+func readCompletedSeedSteps(seedId int64) (map[string]bool, error) {
+	completedSteps := make(map[string]bool)
+	query := `
+		select 
+			seed_step
+		from 
+			agent_seed_state
+		where
+			agent_seed_id = ?
+			and seed_step != ''
+			and error_message = ''
+		`
+	err := db.QueryOrchestrator(query, sqlutils.Args(seedId), func(m sqlutils.RowMap) error {
+		completedSteps[m.GetString("seed_step")] = true
+		return nil
+	})
+	return completedSteps, log.Errore(err)
+}
+
 // FailStaleSeeds marks as failed seeds where no progress have been seen recently
 func FailStaleSeeds() error {
 	_, err := db.ExecOrchestrator(`
@@ -624,160 +951,50 @@ func FailStaleSeeds() error {
 	return err
 }
 
-// executeSeed is *the* function for taking a seed. It is a complex operation of testing, preparing, re-testing
-// agents on both sides, initiating data transfer, following up, awaiting completion, diagnosing errors, claning up.
-func executeSeed(seedId int64, targetHostname string, sourceHostname string) error {
-
-	var err error
-	var seedStateId int64
-
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("getting target agent info for %s", targetHostname), "")
-	targetAgent, err := GetAgent(targetHostname)
-	SeededAgents <- &targetAgent
-	if err != nil {
-		return updateSeedStateEntry(seedStateId, err)
-	}
-
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("getting source agent info for %s", sourceHostname), "")
-	sourceAgent, err := GetAgent(sourceHostname)
-	if err != nil {
-		return updateSeedStateEntry(seedStateId, err)
-	}
-
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("Checking MySQL status on target %s", targetHostname), "")
-	if targetAgent.MySQLRunning {
-		return updateSeedStateEntry(seedStateId, errors.New("MySQL is running on target host. Cowardly refusing to proceeed. Please stop the MySQL service"))
-	}
-
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("Looking up available snapshots on source %s", sourceHostname), "")
-	if len(sourceAgent.LogicalVolumes) == 0 {
-		return updateSeedStateEntry(seedStateId, errors.New("No logical volumes found on source host"))
-	}
-
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("Checking mount point on source %s", sourceHostname), "")
-	if sourceAgent.MountPoint.IsMounted {
-		return updateSeedStateEntry(seedStateId, errors.New("Volume already mounted on source host; please unmount"))
-	}
-
-	seedFromLogicalVolume := sourceAgent.LogicalVolumes[0]
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("Mounting logical volume: %s", seedFromLogicalVolume.Path), "")
-	_, err = MountLV(sourceHostname, seedFromLogicalVolume.Path)
-	if err != nil {
-		return updateSeedStateEntry(seedStateId, err)
+// Seed is the entry point for making a seed
+func Seed(targetHostname string, sourceHostname string, seedMethod SeedMethod) (int64, error) {
+	if targetHostname == sourceHostname {
+		return 0, log.Errorf("Cannot seed %s onto itself", targetHostname)
 	}
-	sourceAgent, err = GetAgent(sourceHostname)
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("MySQL data volume on source host %s is %d bytes", sourceHostname, sourceAgent.MountPoint.MySQLDiskUsage), "")
-
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("Erasing MySQL data on %s", targetHostname), "")
-	_, err = deleteMySQLDatadir(targetHostname)
-	if err != nil {
-		return updateSeedStateEntry(seedStateId, err)
+	if _, err := getSeedSteps(seedMethod); err != nil {
+		return 0, log.Errore(err)
 	}
-
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("Aquiring target host datadir free space on %s", targetHostname), "")
-	targetAgent, err = GetAgent(targetHostname)
+	seedId, err := SubmitSeedEntry(targetHostname, sourceHostname, seedMethod)
 	if err != nil {
-		return updateSeedStateEntry(seedStateId, err)
-	}
-
-	if sourceAgent.MountPoint.MySQLDiskUsage > targetAgent.MySQLDatadirDiskFree {
-		Unmount(sourceHostname)
-		return updateSeedStateEntry(seedStateId, fmt.Errorf("Not enough disk space on target host %s. Required: %d, available: %d. Bailing out.", targetHostname, sourceAgent.MountPoint.MySQLDiskUsage, targetAgent.MySQLDatadirDiskFree))
+		return 0, log.Errore(err)
 	}
 
-	// ...
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("%s will now receive data in background", targetHostname), "")
-	ReceiveMySQLSeedData(targetHostname, seedId)
-
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("Waiting some time for %s to start listening for incoming data", targetHostname), "")
-	time.Sleep(2 * time.Second)
-
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("%s will now send data to %s in background", sourceHostname, targetHostname), "")
-	SendMySQLSeedData(sourceHostname, targetHostname, seedId)
-
-	copyComplete := false
-	numStaleIterations := 0
-	var bytesCopied int64 = 0
-
-	for !copyComplete {
-		targetAgentPoll, err := GetAgent(targetHostname)
-		if err != nil {
-			return log.Errore(err)
-		}
-
-		if targetAgentPoll.MySQLDiskUsage == bytesCopied {
-			numStaleIterations++
-		}
-		bytesCopied = targetAgentPoll.MySQLDiskUsage
-
-		copyFailed := false
-		if _, commandCompleted, _ := seedCommandCompleted(targetHostname, seedId); commandCompleted {
-			copyComplete = true
-			if _, commandSucceeded, _ := seedCommandSucceeded(targetHostname, seedId); !commandSucceeded {
-				// failed.
-				copyFailed = true
-			}
-		}
-		if numStaleIterations > 10 {
-			copyFailed = true
-		}
-		if copyFailed {
-			AbortSeedCommand(sourceHostname, seedId)
-			AbortSeedCommand(targetHostname, seedId)
-			Unmount(sourceHostname)
-			return updateSeedStateEntry(seedStateId, errors.New("10 iterations have passed without progress. Bailing out."))
-		}
-
-		var copyPct int64 = 0
-		if sourceAgent.MountPoint.MySQLDiskUsage > 0 {
-			copyPct = 100 * bytesCopied / sourceAgent.MountPoint.MySQLDiskUsage
-		}
-		seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("Copied %d/%d bytes (%d%%)", bytesCopied, sourceAgent.MountPoint.MySQLDiskUsage, copyPct), "")
+	go func() {
+		err := executeSeed(seedId, seedMethod, targetHostname, sourceHostname)
+		updateSeedComplete(seedId, err)
+	}()
 
-		if !copyComplete {
-			time.Sleep(30 * time.Second)
-		}
-	}
+	return seedId, nil
+}
 
-	// Cleanup:
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("Executing post-copy command on %s", targetHostname), "")
-	_, err = PostCopy(targetHostname)
+// ResumeSeed continues a failed, aborted or stale seed, skipping those steps which have already completed
+func ResumeSeed(seedId int64) (int64, error) {
+	seedOperations, err := AgentSeedDetails(seedId)
 	if err != nil {
-		return updateSeedStateEntry(seedStateId, err)
+		return 0, log.Errore(err)
 	}
-
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("Unmounting logical volume: %s", seedFromLogicalVolume.Path), "")
-	_, err = Unmount(sourceHostname)
-	if err != nil {
-		return updateSeedStateEntry(seedStateId, err)
+	if len(seedOperations) == 0 {
+		return 0, log.Errorf("Seed %d not found", seedId)
 	}
-
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("Starting MySQL on target: %s", targetHostname), "")
-	_, err = MySQLStart(targetHostname)
-	if err != nil {
-		return updateSeedStateEntry(seedStateId, err)
+	seedOperation := seedOperations[0]
+	if seedOperation.IsSuccessful {
+		return 0, log.Errorf("Seed %d has already completed successfully", seedId)
 	}
-
-	seedStateId, _ = submitSeedStateEntry(seedId, fmt.Sprintf("Submitting MySQL instance for discovery: %s", targetHostname), "")
-	SeededAgents <- &targetAgent
-
-	seedStateId, _ = submitSeedStateEntry(seedId, "Done", "")
-
-	return nil
-}
-
-// Seed is the entry point for making a seed
-func Seed(targetHostname string, sourceHostname string) (int64, error) {
-	if targetHostname == sourceHostname {
-		return 0, log.Errorf("Cannot seed %s onto itself", targetHostname)
+	if isSeedExecuting(seedId) {
+		return 0, log.Errorf("Seed %d is already being executed", seedId)
 	}
-	seedId, err := SubmitSeedEntry(targetHostname, sourceHostname)
-	if err != nil {
+	if err := resetSeedForResume(seedId); err != nil {
 		return 0, log.Errore(err)
 	}
+	submitSeedStateEntry(seedId, fmt.Sprintf("Resuming %s seed", seedOperation.SeedMethod), "")
 
 	go func() {
-		err := executeSeed(seedId, targetHostname, sourceHostname)
+		err := executeSeed(seedId, seedOperation.SeedMethod, seedOperation.TargetHostname, seedOperation.SourceHostname)
 		updateSeedComplete(seedId, err)
 	}()
 
@@ -792,10 +1009,13 @@ func readSeeds(whereCondition string, args []interface{}, limit string) ([]SeedO
 			agent_seed_id,
 			target_hostname,
 			source_hostname,
+			seed_method,
 			start_timestamp,
 			end_timestamp,
 			is_complete,
-			is_successful
+			is_successful,
+			bytes_copied,
+			total_bytes
 		from 
 			agent_seed
 		%s
@@ -808,10 +1028,13 @@ func readSeeds(whereCondition string, args []interface{}, limit string) ([]SeedO
 		seedOperation.SeedId = m.GetInt64("agent_seed_id")
 		seedOperation.TargetHostname = m.GetString("target_hostname")
 		seedOperation.SourceHostname = m.GetString("source_hostname")
+		seedOperation.SeedMethod = SeedMethod(m.GetString("seed_method"))
 		seedOperation.StartTimestamp = m.GetString("start_timestamp")
 		seedOperation.EndTimestamp = m.GetString("end_timestamp")
 		seedOperation.IsComplete = m.GetBool("is_complete")
 		seedOperation.IsSuccessful = m.GetBool("is_successful")
+		seedOperation.BytesCopied = m.GetInt64("bytes_copied")
+		seedOperation.TotalBytes = m.GetInt64("total_bytes")
 
 		res = append(res, seedOperation)
 		return nil
@@ -872,7 +1095,8 @@ func ReadSeedStates(seedId int64) ([]SeedOperationState, error) {
 			agent_seed_id,
 			state_timestamp,
 			state_action,
-			error_message
+			error_message,
+			seed_step
 		from 
 			agent_seed_state
 		where
@@ -887,6 +1111,7 @@ func ReadSeedStates(seedId int64) ([]SeedOperationState, error) {
 		seedState.StateTimestamp = m.GetString("state_timestamp")
 		seedState.Action = m.GetString("state_action")
 		seedState.ErrorMessage = m.GetString("error_message")
+		seedState.Step = m.GetString("seed_step")
 
 		res = append(res, seedState)
 		return nil
@@ -0,0 +1,61 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// Per host gauges, reflecting agents' inventory as last polled. Gauges are named agent.<hostname>.<gauge>,
+// where dots in hostname are converted to underscores.
+const (
+	mysqlRunningGaugeName           = "mysql_running"
+	datadirDiskUsedPercentGaugeName = "datadir_disk_used_percent"
+	datadirDiskFreeGaugeName        = "datadir_disk_free_bytes"
+	errorLogCrashMarkerGaugeName    = "error_log_crash_marker"
+)
+
+var agentInventoryGaugeNames = []string{mysqlRunningGaugeName, datadirDiskUsedPercentGaugeName, datadirDiskFreeGaugeName, errorLogCrashMarkerGaugeName}
+
+func agentInventoryMetricName(hostname string, gaugeName string) string {
+	return fmt.Sprintf("agent.%s.%s", strings.Replace(hostname, ".", "_", -1), gaugeName)
+}
+
+func boolGaugeValue(value bool) int64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// updateAgentInventoryGauges sets the per host gauges of given agent
+func updateAgentInventoryGauges(hostname string, agent *Agent) {
+	metrics.GetOrRegisterGauge(agentInventoryMetricName(hostname, mysqlRunningGaugeName), metrics.DefaultRegistry).Update(boolGaugeValue(agent.MySQLRunning))
+	metrics.GetOrRegisterGaugeFloat64(agentInventoryMetricName(hostname, datadirDiskUsedPercentGaugeName), metrics.DefaultRegistry).Update(agent.DatadirDiskUsedPercent())
+	metrics.GetOrRegisterGauge(agentInventoryMetricName(hostname, datadirDiskFreeGaugeName), metrics.DefaultRegistry).Update(agent.MySQLDatadirDiskFree)
+	metrics.GetOrRegisterGauge(agentInventoryMetricName(hostname, errorLogCrashMarkerGaugeName), metrics.DefaultRegistry).Update(boolGaugeValue(agent.ErrorLogCrashMarker() != ""))
+}
+
+// unregisterAgentInventoryGauges removes the per host gauges of a forgotten agent
+func unregisterAgentInventoryGauges(hostname string) {
+	for _, gaugeName := range agentInventoryGaugeNames {
+		metrics.Unregister(agentInventoryMetricName(hostname, gaugeName))
+	}
+}
@@ -0,0 +1,677 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/inst"
+)
+
+// Polling interval & patience when following up on data transfer
+var seedProgressPollInterval = 30 * time.Second
+var seedStartListeningWait = 2 * time.Second
+
+const maxSeedStaleIterations = 10
+
+// seedContext holds what is known of an executing seed. It is rebuilt on resume.
+type seedContext struct {
+	seedId                int64
+	seedMethod            SeedMethod
+	targetHostname        string
+	sourceHostname        string
+	targetAgent           Agent
+	sourceAgent           Agent
+	seedFromLogicalVolume LogicalVolume
+	totalBytes            int64
+	coordinates           *SeedReplicationCoordinates
+	skipReplicationSetup  bool
+}
+
+func (this *seedContext) targetKey() *inst.InstanceKey {
+	return this.targetAgent.GetInstance()
+}
+
+func (this *seedContext) sourceKey() *inst.InstanceKey {
+	return this.sourceAgent.GetInstance()
+}
+
+// unmarkSeedStepCompleteFunc is used by undoSeedSteps. Tests point it at a recorder.
+var unmarkSeedStepCompleteFunc = unmarkSeedStepComplete
+
+// undoSeedSteps has given completed steps executed anew should the seed be resumed, as cleanup has undone their
+// effect: the snapshot unmounted, or a partial copy left in the target datadir
+func (this *seedContext) undoSeedSteps(steps ...seedStep) {
+	for _, step := range steps {
+		unmarkSeedStepCompleteFunc(this.seedId, step.name)
+	}
+}
+
+// seedStep is a single step in a seed. Completed steps are signed in agent_seed_state and are skipped
+// on resume, unless repeatable (these collect information the following steps depend on).
+type seedStep struct {
+	name        string
+	description func(context *seedContext) string
+	execute     func(context *seedContext) error
+	repeatable  bool
+}
+
+var executingSeeds = make(map[int64]bool)
+var executingSeedsMutex sync.Mutex
+
+func isSeedExecuting(seedId int64) bool {
+	executingSeedsMutex.Lock()
+	defer executingSeedsMutex.Unlock()
+	return executingSeeds[seedId]
+}
+
+// getSeedSteps returns the steps making for a seed of given method
+func getSeedSteps(seedMethod SeedMethod) ([]seedStep, error) {
+	steps := []seedStep{targetAgentInfoStep, sourceAgentInfoStep, targetMySQLStatusStep}
+	switch seedMethod {
+	case SeedMethodLVM:
+		steps = append(steps, sourceSnapshotsStep, sourceMountPointStep, mountLogicalVolumeStep, eraseTargetDatadirStep, targetDiskSpaceStep, lvmCopyStep, postCopyStep, unmountLogicalVolumeStep, startTargetMySQLStep)
+	case SeedMethodXtrabackup:
+		steps = append(steps, eraseTargetDatadirStep, targetDiskSpaceStep, streamingCopyStep, prepareSeedDataStep, postCopyStep, startTargetMySQLStep)
+	case SeedMethodMysqldump, SeedMethodMydumper:
+		steps = append(steps, targetDiskSpaceStep, streamingCopyStep)
+	default:
+		return steps, fmt.Errorf("Unsupported seed method: %s", seedMethod)
+	}
+	if config.Config.SeedSetupReplication {
+		steps = append(steps, replicationCoordinatesStep, changeMasterStep, startReplicationStep, awaitReplicationStep)
+	}
+	steps = append(steps, submitDiscoveryStep)
+	if config.Config.SeedSetupReplication {
+		steps = append(steps, registerInstanceStep)
+	}
+	return steps, nil
+}
+
+// executeSeed is *the* function for taking a seed. It is a complex operation of testing, preparing, re-testing
+// agents on both sides, initiating data transfer, following up, awaiting completion, diagnosing errors, claning up.
+// The actual steps depend on the seed method. Steps completed by a previous execution of this seed are skipped.
+func executeSeed(seedId int64, seedMethod SeedMethod, targetHostname string, sourceHostname string) error {
+	executingSeedsMutex.Lock()
+	executingSeeds[seedId] = true
+	executingSeedsMutex.Unlock()
+	defer func() {
+		executingSeedsMutex.Lock()
+		defer executingSeedsMutex.Unlock()
+		delete(executingSeeds, seedId)
+	}()
+
+	steps, err := getSeedSteps(seedMethod)
+	if err != nil {
+		return log.Errore(err)
+	}
+	completedSteps, err := readCompletedSeedSteps(seedId)
+	if err != nil {
+		return log.Errore(err)
+	}
+	context := &seedContext{
+		seedId:         seedId,
+		seedMethod:     seedMethod,
+		targetHostname: targetHostname,
+		sourceHostname: sourceHostname,
+	}
+	return executeSeedSteps(context, steps, completedSteps)
+}
+
+// executeSeedSteps runs given steps in order, bailing out on first error
+func executeSeedSteps(context *seedContext, steps []seedStep, completedSteps map[string]bool) error {
+	for _, step := range steps {
+		if completedSteps[step.name] && !step.repeatable {
+			continue
+		}
+		seedStateId, _ := submitSeedStateEntry(context.seedId, step.description(context), "")
+		if err := step.execute(context); err != nil {
+			return updateSeedStateEntry(seedStateId, err)
+		}
+		markSeedStateStepComplete(seedStateId, step.name)
+	}
+	submitSeedStateEntry(context.seedId, "Done", "")
+	return nil
+}
+
+var targetAgentInfoStep = seedStep{
+	name: "target-agent-info",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("getting target agent info for %s", context.targetHostname)
+	},
+	execute: func(context *seedContext) (err error) {
+		context.targetAgent, err = GetAgent(context.targetHostname)
+		SeededAgents <- &context.targetAgent
+		return err
+	},
+	repeatable: true,
+}
+
+var sourceAgentInfoStep = seedStep{
+	name: "source-agent-info",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("getting source agent info for %s", context.sourceHostname)
+	},
+	execute: func(context *seedContext) (err error) {
+		if context.sourceAgent, err = GetAgent(context.sourceHostname); err != nil {
+			return err
+		}
+		context.totalBytes = context.sourceAgent.MySQLDiskUsage
+		if context.seedMethod == SeedMethodLVM {
+			if len(context.sourceAgent.LogicalVolumes) > 0 {
+				context.seedFromLogicalVolume = context.sourceAgent.LogicalVolumes[0]
+			}
+			// Until the snapshot is mounted we do not know how much data there is to copy
+			context.totalBytes = context.sourceAgent.MountPoint.MySQLDiskUsage
+		}
+		return nil
+	},
+	repeatable: true,
+}
+
+var targetMySQLStatusStep = seedStep{
+	name: "target-mysql-status",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Checking MySQL status on target %s", context.targetHostname)
+	},
+	execute: func(context *seedContext) error {
+		if context.seedMethod.IsPhysical() && context.targetAgent.MySQLRunning {
+			return errors.New("MySQL is running on target host. Cowardly refusing to proceeed. Please stop the MySQL service")
+		}
+		if !context.seedMethod.IsPhysical() && !context.targetAgent.MySQLRunning {
+			return fmt.Errorf("MySQL is not running on target host. A %s seed imports data into a running server. Please start the MySQL service", context.seedMethod)
+		}
+		return nil
+	},
+}
+
+var sourceSnapshotsStep = seedStep{
+	name: "source-snapshots",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Looking up available snapshots on source %s", context.sourceHostname)
+	},
+	execute: func(context *seedContext) error {
+		if len(context.sourceAgent.LogicalVolumes) == 0 {
+			return errors.New("No logical volumes found on source host")
+		}
+		return nil
+	},
+}
+
+var sourceMountPointStep = seedStep{
+	name: "source-mount-point",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Checking mount point on source %s", context.sourceHostname)
+	},
+	execute: func(context *seedContext) error {
+		if context.sourceAgent.MountPoint.IsMounted {
+			return errors.New("Volume already mounted on source host; please unmount")
+		}
+		return nil
+	},
+}
+
+var mountLogicalVolumeStep = seedStep{
+	name: "mount-logical-volume",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Mounting logical volume: %s", context.seedFromLogicalVolume.Path)
+	},
+	execute: func(context *seedContext) (err error) {
+		if _, err = MountLV(context.sourceHostname, context.seedFromLogicalVolume.Path); err != nil {
+			return err
+		}
+		if context.sourceAgent, err = GetAgent(context.sourceHostname); err != nil {
+			return err
+		}
+		context.totalBytes = context.sourceAgent.MountPoint.MySQLDiskUsage
+		submitSeedStateEntry(context.seedId, fmt.Sprintf("MySQL data volume on source host %s is %d bytes", context.sourceHostname, context.totalBytes), "")
+		return nil
+	},
+}
+
+var eraseTargetDatadirStep = seedStep{
+	name: "erase-target-datadir",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Erasing MySQL data on %s", context.targetHostname)
+	},
+	execute: func(context *seedContext) error {
+		_, err := deleteMySQLDatadir(context.targetHostname)
+		return err
+	},
+}
+
+var targetDiskSpaceStep = seedStep{
+	name: "target-disk-space",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Aquiring target host datadir free space on %s", context.targetHostname)
+	},
+	execute: func(context *seedContext) (err error) {
+		if context.targetAgent, err = GetAgent(context.targetHostname); err != nil {
+			return err
+		}
+		if context.totalBytes > context.targetAgent.MySQLDatadirDiskFree {
+			if context.seedMethod == SeedMethodLVM {
+				Unmount(context.sourceHostname)
+				context.undoSeedSteps(mountLogicalVolumeStep)
+			}
+			return fmt.Errorf("Not enough disk space on target host %s. Required: %d, available: %d. Bailing out.", context.targetHostname, context.totalBytes, context.targetAgent.MySQLDatadirDiskFree)
+		}
+		return nil
+	},
+}
+
+// recordSeedProgress writes down copied bytes both as a seed state and on the seed itself
+func recordSeedProgress(context *seedContext, bytesCopied int64, totalBytes int64) int64 {
+	updateSeedProgress(context.seedId, bytesCopied, totalBytes)
+	seedStateId, _ := submitSeedStateEntry(context.seedId, fmt.Sprintf("Copied %d/%d bytes (%d%%)", bytesCopied, totalBytes, progressPercent(bytesCopied, totalBytes)), "")
+	return seedStateId
+}
+
+// lvmCopyStep streams a mounted snapshot to target host. The agents do not report progress, which is
+// deduced from the growth of MySQL data on target host.
+var lvmCopyStep = seedStep{
+	name: "copy",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("%s will now receive data in background", context.targetHostname)
+	},
+	execute: func(context *seedContext) error {
+		if _, commandCompleted, _ := seedCommandCompleted(context.targetHostname, context.seedId); commandCompleted {
+			if _, commandSucceeded, _ := seedCommandSucceeded(context.targetHostname, context.seedId); commandSucceeded {
+				// Resuming a seed whose data transfer has completed since
+				return nil
+			}
+		}
+		ReceiveMySQLSeedData(context.targetHostname, context.seedId)
+
+		submitSeedStateEntry(context.seedId, fmt.Sprintf("Waiting some time for %s to start listening for incoming data", context.targetHostname), "")
+		time.Sleep(seedStartListeningWait)
+
+		submitSeedStateEntry(context.seedId, fmt.Sprintf("%s will now send data to %s in background", context.sourceHostname, context.targetHostname), "")
+		SendMySQLSeedData(context.sourceHostname, context.targetHostname, context.seedId)
+
+		copyComplete := false
+		numStaleIterations := 0
+		var bytesCopied int64 = 0
+
+		for !copyComplete {
+			targetAgentPoll, err := GetAgent(context.targetHostname)
+			if err != nil {
+				return log.Errore(err)
+			}
+
+			if targetAgentPoll.MySQLDiskUsage == bytesCopied {
+				numStaleIterations++
+			}
+			bytesCopied = targetAgentPoll.MySQLDiskUsage
+
+			copyFailed := false
+			if _, commandCompleted, _ := seedCommandCompleted(context.targetHostname, context.seedId); commandCompleted {
+				copyComplete = true
+				if _, commandSucceeded, _ := seedCommandSucceeded(context.targetHostname, context.seedId); !commandSucceeded {
+					// failed.
+					copyFailed = true
+				}
+			}
+			if numStaleIterations > maxSeedStaleIterations {
+				copyFailed = true
+			}
+			if copyFailed {
+				AbortSeedCommand(context.sourceHostname, context.seedId)
+				AbortSeedCommand(context.targetHostname, context.seedId)
+				Unmount(context.sourceHostname)
+				context.undoSeedSteps(mountLogicalVolumeStep, eraseTargetDatadirStep)
+				return errors.New("10 iterations have passed without progress. Bailing out.")
+			}
+			recordSeedProgress(context, bytesCopied, context.totalBytes)
+
+			if !copyComplete {
+				time.Sleep(seedProgressPollInterval)
+			}
+		}
+		return nil
+	},
+}
+
+// streamingCopyStep has the source agent stream a backup (physical or logical, as per seed method) to the
+// target agent, following up on progress as reported by the target agent.
+var streamingCopyStep = seedStep{
+	name: "copy",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Streaming %s data from %s to %s", context.seedMethod, context.sourceHostname, context.targetHostname)
+	},
+	execute: func(context *seedContext) error {
+		if _, progress, err := seedProgress(context.targetHostname, context.seedId); err == nil && progress.IsComplete && progress.IsSuccessful {
+			// Resuming a seed whose data transfer has completed since
+			recordSeedProgress(context, progress.BytesCopied, progress.TotalBytes)
+			return nil
+		}
+		if _, err := ReceiveSeedData(context.targetHostname, context.seedMethod, context.seedId); err != nil {
+			return err
+		}
+		submitSeedStateEntry(context.seedId, fmt.Sprintf("Waiting some time for %s to start listening for incoming data", context.targetHostname), "")
+		time.Sleep(seedStartListeningWait)
+
+		if _, err := SendSeedData(context.sourceHostname, context.seedMethod, context.targetHostname, context.seedId); err != nil {
+			AbortSeedCommand(context.targetHostname, context.seedId)
+			context.undoSeedSteps(eraseTargetDatadirStep)
+			return err
+		}
+		abort := func(err error) error {
+			AbortSeedCommand(context.sourceHostname, context.seedId)
+			AbortSeedCommand(context.targetHostname, context.seedId)
+			// A physical copy may have been partially written; have the datadir erased again before resending
+			context.undoSeedSteps(eraseTargetDatadirStep)
+			return err
+		}
+
+		numStaleIterations := 0
+		var bytesCopied int64 = -1
+		for {
+			_, progress, err := seedProgress(context.targetHostname, context.seedId)
+			if err != nil {
+				return abort(err)
+			}
+			if progress.BytesCopied == bytesCopied {
+				numStaleIterations++
+			} else {
+				numStaleIterations = 0
+			}
+			bytesCopied = progress.BytesCopied
+			totalBytes := progress.TotalBytes
+			if totalBytes == 0 {
+				// Agent does not know; go by source data size
+				totalBytes = context.totalBytes
+			}
+			recordSeedProgress(context, bytesCopied, totalBytes)
+
+			if progress.IsComplete {
+				if !progress.IsSuccessful {
+					return abort(fmt.Errorf("Data transfer failed: %s", progress.Error))
+				}
+				return nil
+			}
+			if numStaleIterations > maxSeedStaleIterations {
+				return abort(fmt.Errorf("%d iterations have passed without progress. Bailing out.", maxSeedStaleIterations))
+			}
+			time.Sleep(seedProgressPollInterval)
+		}
+	},
+}
+
+var prepareSeedDataStep = seedStep{
+	name: "prepare-seed-data",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Preparing %s data on %s", context.seedMethod, context.targetHostname)
+	},
+	execute: func(context *seedContext) error {
+		_, err := PrepareSeedData(context.targetHostname, context.seedMethod, context.seedId)
+		return err
+	},
+}
+
+var postCopyStep = seedStep{
+	name: "post-copy",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Executing post-copy command on %s", context.targetHostname)
+	},
+	execute: func(context *seedContext) error {
+		_, err := PostCopy(context.targetHostname)
+		return err
+	},
+}
+
+var unmountLogicalVolumeStep = seedStep{
+	name: "unmount-logical-volume",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Unmounting logical volume: %s", context.seedFromLogicalVolume.Path)
+	},
+	execute: func(context *seedContext) error {
+		_, err := Unmount(context.sourceHostname)
+		return err
+	},
+}
+
+var startTargetMySQLStep = seedStep{
+	name: "start-mysql",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Starting MySQL on target: %s", context.targetHostname)
+	},
+	execute: func(context *seedContext) error {
+		_, err := MySQLStart(context.targetHostname)
+		return err
+	},
+}
+
+var submitDiscoveryStep = seedStep{
+	name: "submit-discovery",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Submitting MySQL instance for discovery: %s", context.targetHostname)
+	},
+	execute: func(context *seedContext) error {
+		SeededAgents <- &context.targetAgent
+		return nil
+	},
+}
+
+// seedReplicationMaster decides which server a seeded target should replicate from, and at which coordinates.
+// The seed source is preferred, provided its binary logs include all changes; otherwise the source's master is used.
+// With Oracle GTID, coordinates are not required: replication positions itself.
+func seedReplicationMaster(source *inst.Instance, coordinates *SeedReplicationCoordinates) (masterKey *inst.InstanceKey, masterCoordinates *inst.BinlogCoordinates, useGTID bool, err error) {
+	useGTID = coordinates.ExecutedGtidSet != "" && source.SupportsOracleGTID
+	sourceLogsAllChanges := source.LogBinEnabled && (!source.IsSlave() || source.LogSlaveUpdatesEnabled)
+	if sourceLogsAllChanges {
+		masterKey, masterCoordinates = &source.Key, &coordinates.BinlogCoordinates
+	} else if source.IsSlave() {
+		masterKey, masterCoordinates = &source.MasterKey, &coordinates.MasterBinlogCoordinates
+	} else {
+		return nil, nil, false, fmt.Errorf("Seed source %+v has no binary logs and no master; nothing to replicate from", source.Key)
+	}
+	if masterCoordinates.IsEmpty() && !useGTID {
+		return nil, nil, false, fmt.Errorf("No replication coordinates captured for %+v", *masterKey)
+	}
+	return masterKey, masterCoordinates, useGTID, nil
+}
+
+var replicationCoordinatesStep = seedStep{
+	name: "replication-coordinates",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Reading replication coordinates captured by %s", context.targetHostname)
+	},
+	execute: func(context *seedContext) error {
+		context.skipReplicationSetup = false
+		_, coordinates, err := seedReplicationCoordinates(context.targetHostname, context.seedId)
+		if err == nil {
+			context.coordinates = coordinates
+			return nil
+		}
+		// Agent does not capture coordinates. Data may still have replication configuration along with it.
+		context.coordinates = nil
+		target, err := inst.ReadTopologyInstance(context.targetKey())
+		if err != nil {
+			return err
+		}
+		if !target.IsSlave() {
+			context.skipReplicationSetup = true
+			submitSeedStateEntry(context.seedId, fmt.Sprintf("Warning: no replication coordinates captured by %s and %+v is not a slave; skipping replication setup", context.targetHostname, target.Key), "")
+		}
+		return nil
+	},
+	repeatable: true,
+}
+
+var changeMasterStep = seedStep{
+	name: "change-master",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Setting up replication on %+v", *context.targetKey())
+	},
+	execute: func(context *seedContext) error {
+		if context.skipReplicationSetup {
+			return nil
+		}
+		target, err := inst.ReadTopologyInstance(context.targetKey())
+		if err != nil {
+			return err
+		}
+		if context.coordinates == nil {
+			if target.IsSlave() {
+				submitSeedStateEntry(context.seedId, fmt.Sprintf("No replication coordinates captured; keeping replication from %+v as copied along with data", target.MasterKey), "")
+			}
+			return nil
+		}
+		source, err := inst.ReadTopologyInstance(context.sourceKey())
+		if err != nil {
+			return err
+		}
+		masterKey, masterCoordinates, useGTID, err := seedReplicationMaster(source, context.coordinates)
+		if err != nil {
+			return err
+		}
+		if target.IsSlave() {
+			if target, err = inst.StopSlave(&target.Key); err != nil {
+				return err
+			}
+		}
+		var gtidHint inst.OperationGTIDHint = inst.GTIDHintNeutral
+		if useGTID && target.SupportsOracleGTID {
+			gtidHint = inst.GTIDHintForce
+			if context.seedMethod.IsPhysical() {
+				// Binary logs are not part of a physical copy; what the source has executed must be declared as purged
+				if _, err := inst.ResetMaster(&target.Key); err != nil {
+					return err
+				}
+				if _, err := inst.SetGTIDPurged(&target.Key, context.coordinates.ExecutedGtidSet); err != nil {
+					return err
+				}
+			}
+		} else if masterCoordinates.IsEmpty() {
+			return fmt.Errorf("%+v does not support GTID and no binary log coordinates were captured", target.Key)
+		}
+		submitSeedStateEntry(context.seedId, fmt.Sprintf("Replicating %+v from %+v at %+v (GTID: %t)", target.Key, *masterKey, *masterCoordinates, gtidHint == inst.GTIDHintForce), "")
+		if target, err = inst.ChangeMasterTo(&target.Key, masterKey, masterCoordinates, false, gtidHint); err != nil {
+			return err
+		}
+		if !target.HasReplicationCredentials {
+			replicationUser, replicationPassword, err := inst.ReadReplicationCredentials(&source.Key)
+			if err != nil {
+				return fmt.Errorf("No replication credentials on %+v, and cannot read them from %+v: %+v", target.Key, source.Key, err)
+			}
+			if _, err := inst.ChangeMasterCredentials(&target.Key, replicationUser, replicationPassword); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+var startReplicationStep = seedStep{
+	name: "start-replication",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Starting replication on %+v", *context.targetKey())
+	},
+	execute: func(context *seedContext) error {
+		if context.skipReplicationSetup {
+			return nil
+		}
+		_, err := inst.StartSlave(context.targetKey())
+		return err
+	},
+}
+
+// awaitReplicationStep waits for seeded target to catch up with its master, bailing out on replication
+// error or when lag does not decrease for a while
+var awaitReplicationStep = seedStep{
+	name: "await-replication",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Waiting for %+v to catch up with its master", *context.targetKey())
+	},
+	execute: func(context *seedContext) error {
+		if context.skipReplicationSetup {
+			return nil
+		}
+		numStaleIterations := 0
+		var lagSeconds int64 = -1
+		for {
+			target, err := inst.ReadTopologyInstance(context.targetKey())
+			if err != nil {
+				return err
+			}
+			if !target.Slave_SQL_Running && target.LastSQLError != "" {
+				return fmt.Errorf("SQL thread stopped on %+v: %s", target.Key, target.LastSQLError)
+			}
+			if !target.Slave_IO_Running && target.LastIOError != "" {
+				return fmt.Errorf("IO thread stopped on %+v: %s", target.Key, target.LastIOError)
+			}
+			if target.SlaveRunning() && target.IsUpToDate && target.SecondsBehindMaster.Valid && target.HasReasonableMaintenanceReplicationLag() {
+				submitSeedStateEntry(context.seedId, fmt.Sprintf("%+v is replicating from %+v, lag: %s", target.Key, target.MasterKey, target.LagStatusString()), "")
+				return nil
+			}
+			if target.SecondsBehindMaster.Valid && (lagSeconds < 0 || target.SecondsBehindMaster.Int64 < lagSeconds) {
+				numStaleIterations = 0
+			} else {
+				numStaleIterations++
+			}
+			if target.SecondsBehindMaster.Valid {
+				lagSeconds = target.SecondsBehindMaster.Int64
+			}
+			submitSeedStateEntry(context.seedId, fmt.Sprintf("%+v replication lag: %s", target.Key, target.LagStatusString()), "")
+			if numStaleIterations > maxSeedStaleIterations {
+				return fmt.Errorf("%+v is not catching up with its master after %d iterations. Bailing out.", target.Key, maxSeedStaleIterations)
+			}
+			time.Sleep(seedProgressPollInterval)
+		}
+	},
+}
+
+// registerInstanceStep makes sure the seeded target is known in its cluster, and places it in the source's pools
+var registerInstanceStep = seedStep{
+	name: "register-instance",
+	description: func(context *seedContext) string {
+		return fmt.Sprintf("Registering %+v in cluster and pools of %+v", *context.targetKey(), *context.sourceKey())
+	},
+	execute: func(context *seedContext) error {
+		if context.skipReplicationSetup {
+			return nil
+		}
+		target, err := inst.ReadTopologyInstance(context.targetKey())
+		if err != nil {
+			return err
+		}
+		source, found, err := inst.ReadInstance(context.sourceKey())
+		if err == nil && found && target.ClusterName != source.ClusterName {
+			submitSeedStateEntry(context.seedId, fmt.Sprintf("%+v is in cluster %s; source %+v is in cluster %s", target.Key, target.ClusterName, source.Key, source.ClusterName), "")
+		}
+		pools, err := inst.ReadInstancePools(context.sourceKey())
+		if err != nil {
+			return err
+		}
+		for _, pool := range pools {
+			if err := inst.RegisterPoolInstance(pool, &target.Key); err != nil {
+				return err
+			}
+		}
+		if len(pools) > 0 {
+			submitSeedStateEntry(context.seedId, fmt.Sprintf("%+v registered in pools: %s", target.Key, strings.Join(pools, ", ")), "")
+		}
+		return nil
+	},
+}
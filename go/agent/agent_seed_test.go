@@ -0,0 +1,521 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package agent
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/inst"
+)
+
+var drainSeededAgentsOnce sync.Once
+
+// fakeAgent is an orchestrator-agent lookalike, serving just enough of the agent API for seeding
+type fakeAgent struct {
+	mutex           sync.Mutex
+	server          *httptest.Server
+	commands        []string
+	mySQLRunning    bool
+	mySQLDiskUsage  int64
+	datadirDiskFree int64
+	statusFailing   bool
+	unsupported     map[string]int
+	logicalVolumes  []LogicalVolume
+	mount           Mount
+	progress        []SeedProgress
+	receiving       bool
+}
+
+var agentInfoCommands = map[string]bool{
+	"available-snapshots-local":     true,
+	"available-snapshots":           true,
+	"lvs-snapshots":                 true,
+	"mount":                         true,
+	"mysql-status":                  true,
+	"mysql-port":                    true,
+	"mysql-du":                      true,
+	"mysql-datadir-available-space": true,
+	"mysql-datadir-total-space":     true,
+	"mysql-error-log-tail":          true,
+}
+
+func newFakeAgent() *fakeAgent {
+	agent := &fakeAgent{}
+	agent.server = httptest.NewServer(http.HandlerFunc(agent.serve))
+	return agent
+}
+
+func (this *fakeAgent) serve(w http.ResponseWriter, r *http.Request) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	command := strings.TrimPrefix(r.URL.Path, "/api/")
+	if command == "mysql-status" && this.statusFailing {
+		http.Error(w, "cannot determine MySQL status", http.StatusInternalServerError)
+		return
+	}
+	if _, unsupported := this.unsupported[command]; unsupported {
+		this.unsupported[command]++
+		http.NotFound(w, r)
+		return
+	}
+	var response interface{} = true
+	switch command {
+	case "available-snapshots-local", "available-snapshots", "mysql-error-log-tail":
+		response = []string{}
+	case "lvs-snapshots":
+		response = append([]LogicalVolume{}, this.logicalVolumes...)
+	case "mount":
+		response = this.mount
+	case "mysql-status":
+		response = this.mySQLRunning
+	case "mysql-port":
+		response = 3306
+	case "mysql-du":
+		response = this.mySQLDiskUsage
+	case "mysql-datadir-available-space":
+		response = this.datadirDiskFree
+	case "mysql-datadir-total-space":
+		response = 4 * this.datadirDiskFree
+	}
+	if command == "mountlv" {
+		this.mount.IsMounted = true
+	}
+	if command == "umount" {
+		this.mount.IsMounted = false
+	}
+	if strings.HasPrefix(command, "receive-seed-data/") {
+		this.receiving = true
+	}
+	if strings.HasPrefix(command, "seed-progress/") {
+		progress := SeedProgress{}
+		if this.receiving && len(this.progress) > 0 {
+			progress = this.progress[0]
+			if len(this.progress) > 1 {
+				this.progress = this.progress[1:]
+			}
+		}
+		response = progress
+	}
+	if !agentInfoCommands[command] {
+		this.commands = append(this.commands, command)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func (this *fakeAgent) basicInfo(hostname string) Agent {
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(this.server.URL, "http://"))
+	agent := Agent{Hostname: host}
+	agent.Port, _ = strconv.Atoi(port)
+	return agent
+}
+
+func (this *fakeAgent) issuedCommands() []string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return append([]string{}, this.commands...)
+}
+
+func (this *fakeAgent) hasIssued(command string) bool {
+	for _, issued := range this.issuedCommands() {
+		if issued == command {
+			return true
+		}
+	}
+	return false
+}
+
+// withFakeAgents points the agent API at given fake target & source agents, with no backend database
+func withFakeAgents(t *testing.T, target *fakeAgent, source *fakeAgent, f func()) {
+	drainSeededAgentsOnce.Do(func() {
+		go func() {
+			for range SeededAgents {
+			}
+		}()
+	})
+	originalReader := readAgentBasicInfoFunc
+	originalDatabaselessMode := config.Config.DatabaselessMode__experimental
+	originalSetupReplication := config.Config.SeedSetupReplication
+	originalPollInterval := seedProgressPollInterval
+	originalListeningWait := seedStartListeningWait
+	defer func() {
+		readAgentBasicInfoFunc = originalReader
+		config.Config.DatabaselessMode__experimental = originalDatabaselessMode
+		config.Config.SeedSetupReplication = originalSetupReplication
+		seedProgressPollInterval = originalPollInterval
+		seedStartListeningWait = originalListeningWait
+		target.server.Close()
+		source.server.Close()
+	}()
+	readAgentBasicInfoFunc = func(hostname string) (Agent, string, error) {
+		switch hostname {
+		case "target":
+			return target.basicInfo(hostname), "token", nil
+		case "source":
+			return source.basicInfo(hostname), "token", nil
+		}
+		t.Fatalf("unexpected agent: %s", hostname)
+		return Agent{}, "", nil
+	}
+	config.Config.DatabaselessMode__experimental = true
+	// There are no MySQL servers behind fake agents
+	config.Config.SeedSetupReplication = false
+	seedProgressPollInterval = time.Millisecond
+	seedStartListeningWait = 0
+	f()
+}
+
+func newFakeSeedAgents() (target *fakeAgent, source *fakeAgent) {
+	target = newFakeAgent()
+	target.datadirDiskFree = 5000
+	source = newFakeAgent()
+	source.mySQLRunning = true
+	source.mySQLDiskUsage = 1000
+	return target, source
+}
+
+func TestGetAgentInventory(t *testing.T) {
+	target, source := newFakeSeedAgents()
+	withFakeAgents(t, target, source, func() {
+		agent, err := GetAgent("target")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(agent.MySQLStatusValid)
+		test.S(t).ExpectFalse(agent.MySQLRunning)
+		test.S(t).ExpectEquals(agent.MySQLDatadirDiskTotal, int64(20000))
+		test.S(t).ExpectEquals(agent.DatadirDiskUsedPercent(), float64(75))
+
+		// The agent responds with an error when MySQL is not running
+		source.mutex.Lock()
+		source.statusFailing = true
+		source.mutex.Unlock()
+		agent, err = GetAgent("source")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(agent.MySQLStatusValid)
+		test.S(t).ExpectFalse(agent.MySQLRunning)
+	})
+}
+
+func TestGetAgentInventoryUnsupportedInquiry(t *testing.T) {
+	target, source := newFakeSeedAgents()
+	target.unsupported = map[string]int{"mysql-datadir-total-space": 0}
+	withFakeAgents(t, target, source, func() {
+		defer unsupportedAgentInquiries.Flush()
+
+		for i := 0; i < 3; i++ {
+			agent, err := GetAgent("target")
+			test.S(t).ExpectNil(err)
+			test.S(t).ExpectEquals(agent.MySQLDatadirDiskFree, int64(5000))
+			test.S(t).ExpectEquals(agent.MySQLDatadirDiskTotal, int64(0))
+			test.S(t).ExpectEquals(agent.DatadirDiskUsedPercent(), float64(0))
+		}
+		target.mutex.Lock()
+		defer target.mutex.Unlock()
+		test.S(t).ExpectEquals(target.unsupported["mysql-datadir-total-space"], 1)
+	})
+}
+
+func TestExecuteSeedXtrabackup(t *testing.T) {
+	target, source := newFakeSeedAgents()
+	target.progress = []SeedProgress{
+		{BytesCopied: 100, TotalBytes: 1000},
+		{BytesCopied: 600, TotalBytes: 1000},
+		{BytesCopied: 1000, TotalBytes: 1000, IsComplete: true, IsSuccessful: true},
+	}
+	withFakeAgents(t, target, source, func() {
+		err := executeSeed(7, SeedMethodXtrabackup, "target", "source")
+		test.S(t).ExpectNil(err)
+	})
+	test.S(t).ExpectEquals(strings.Join(target.issuedCommands(), ","), strings.Join([]string{
+		"delete-mysql-datadir",
+		"seed-progress/7",
+		"receive-seed-data/xtrabackup/7",
+		"seed-progress/7",
+		"seed-progress/7",
+		"seed-progress/7",
+		"prepare-seed-data/xtrabackup/7",
+		"post-copy",
+		"mysql-start",
+	}, ","))
+	test.S(t).ExpectEquals(strings.Join(source.issuedCommands(), ","), "send-seed-data/xtrabackup/target/7")
+	test.S(t).ExpectFalse(isSeedExecuting(7))
+}
+
+func TestExecuteSeedMysqldump(t *testing.T) {
+	target, source := newFakeSeedAgents()
+	target.mySQLRunning = true
+	target.progress = []SeedProgress{
+		{BytesCopied: 300},
+		{BytesCopied: 800, IsComplete: true, IsSuccessful: true},
+	}
+	withFakeAgents(t, target, source, func() {
+		err := executeSeed(8, SeedMethodMysqldump, "target", "source")
+		test.S(t).ExpectNil(err)
+	})
+	// Logical copy: data is imported into the running server, which is left intact
+	test.S(t).ExpectFalse(target.hasIssued("delete-mysql-datadir"))
+	test.S(t).ExpectFalse(target.hasIssued("mysql-start"))
+	test.S(t).ExpectTrue(target.hasIssued("receive-seed-data/mysqldump/8"))
+	test.S(t).ExpectTrue(source.hasIssued("send-seed-data/mysqldump/target/8"))
+}
+
+func TestExecuteSeedTargetMySQLStatus(t *testing.T) {
+	{
+		target, source := newFakeSeedAgents()
+		withFakeAgents(t, target, source, func() {
+			err := executeSeed(9, SeedMethodMydumper, "target", "source")
+			test.S(t).ExpectNotNil(err)
+		})
+		test.S(t).ExpectFalse(target.hasIssued("receive-seed-data/mydumper/9"))
+	}
+	{
+		target, source := newFakeSeedAgents()
+		target.mySQLRunning = true
+		withFakeAgents(t, target, source, func() {
+			err := executeSeed(9, SeedMethodXtrabackup, "target", "source")
+			test.S(t).ExpectNotNil(err)
+		})
+		test.S(t).ExpectFalse(target.hasIssued("delete-mysql-datadir"))
+	}
+}
+
+func TestExecuteSeedFailedTransfer(t *testing.T) {
+	target, source := newFakeSeedAgents()
+	target.progress = []SeedProgress{
+		{BytesCopied: 100, TotalBytes: 1000},
+		{BytesCopied: 200, TotalBytes: 1000, IsComplete: true, IsSuccessful: false, Error: "broken pipe"},
+	}
+	withFakeAgents(t, target, source, func() {
+		err := executeSeed(10, SeedMethodXtrabackup, "target", "source")
+		test.S(t).ExpectNotNil(err)
+		test.S(t).ExpectTrue(strings.Contains(err.Error(), "broken pipe"))
+	})
+	test.S(t).ExpectTrue(target.hasIssued("abort-seed/10"))
+	test.S(t).ExpectTrue(source.hasIssued("abort-seed/10"))
+	test.S(t).ExpectFalse(target.hasIssued("prepare-seed-data/xtrabackup/10"))
+}
+
+// withUndoneSeedSteps collects the steps whose completion is unmarked by cleanup
+func withUndoneSeedSteps(f func(undoneSteps *[]string)) {
+	defer func(unmarkFunc func(int64, string) error) { unmarkSeedStepCompleteFunc = unmarkFunc }(unmarkSeedStepCompleteFunc)
+	undoneSteps := []string{}
+	unmarkSeedStepCompleteFunc = func(seedId int64, step string) error {
+		undoneSteps = append(undoneSteps, step)
+		return nil
+	}
+	f(&undoneSteps)
+}
+
+func TestExecuteSeedFailedTransferUndoesErase(t *testing.T) {
+	target, source := newFakeSeedAgents()
+	target.progress = []SeedProgress{
+		{BytesCopied: 200, TotalBytes: 1000, IsComplete: true, IsSuccessful: false, Error: "broken pipe"},
+	}
+	withUndoneSeedSteps(func(undoneSteps *[]string) {
+		withFakeAgents(t, target, source, func() {
+			err := executeSeed(14, SeedMethodXtrabackup, "target", "source")
+			test.S(t).ExpectNotNil(err)
+		})
+		test.S(t).ExpectTrue(target.hasIssued("delete-mysql-datadir"))
+		test.S(t).ExpectEquals(strings.Join(*undoneSteps, ","), "erase-target-datadir")
+	})
+}
+
+func TestExecuteSeedLVMNotEnoughSpaceUndoesMount(t *testing.T) {
+	target, source := newFakeSeedAgents()
+	source.logicalVolumes = []LogicalVolume{{Name: "snap1", Path: "/dev/vg/snap1", IsSnapshot: true}}
+	source.mount = Mount{MySQLDiskUsage: 100000}
+	withUndoneSeedSteps(func(undoneSteps *[]string) {
+		withFakeAgents(t, target, source, func() {
+			err := executeSeed(15, SeedMethodLVM, "target", "source")
+			test.S(t).ExpectNotNil(err)
+			test.S(t).ExpectTrue(strings.Contains(err.Error(), "Not enough disk space"))
+		})
+		test.S(t).ExpectTrue(source.hasIssued("mountlv"))
+		test.S(t).ExpectTrue(source.hasIssued("umount"))
+		test.S(t).ExpectEquals(strings.Join(*undoneSteps, ","), "mount-logical-volume")
+	})
+}
+
+func TestExecuteSeedNoProgress(t *testing.T) {
+	target, source := newFakeSeedAgents()
+	target.progress = []SeedProgress{
+		{BytesCopied: 100, TotalBytes: 1000},
+	}
+	withFakeAgents(t, target, source, func() {
+		err := executeSeed(11, SeedMethodXtrabackup, "target", "source")
+		test.S(t).ExpectNotNil(err)
+	})
+	test.S(t).ExpectTrue(target.hasIssued("abort-seed/11"))
+}
+
+func TestExecuteSeedStepsResume(t *testing.T) {
+	target, source := newFakeSeedAgents()
+	withFakeAgents(t, target, source, func() {
+		steps, err := getSeedSteps(SeedMethodXtrabackup)
+		test.S(t).ExpectNil(err)
+		completedSteps := map[string]bool{
+			"target-agent-info":    true,
+			"source-agent-info":    true,
+			"target-mysql-status":  true,
+			"erase-target-datadir": true,
+			"target-disk-space":    true,
+			"copy":                 true,
+		}
+		context := &seedContext{seedId: 12, seedMethod: SeedMethodXtrabackup, targetHostname: "target", sourceHostname: "source"}
+		err = executeSeedSteps(context, steps, completedSteps)
+		test.S(t).ExpectNil(err)
+		// Agent info is always re-read
+		test.S(t).ExpectEquals(context.totalBytes, int64(1000))
+	})
+	test.S(t).ExpectEquals(strings.Join(target.issuedCommands(), ","), "prepare-seed-data/xtrabackup/12,post-copy,mysql-start")
+	test.S(t).ExpectEquals(len(source.issuedCommands()), 0)
+}
+
+func TestExecuteSeedResumeCompletedTransfer(t *testing.T) {
+	target, source := newFakeSeedAgents()
+	// Transfer completed while orchestrator was away
+	target.receiving = true
+	target.progress = []SeedProgress{
+		{BytesCopied: 1000, TotalBytes: 1000, IsComplete: true, IsSuccessful: true},
+	}
+	withFakeAgents(t, target, source, func() {
+		err := executeSeed(13, SeedMethodXtrabackup, "target", "source")
+		test.S(t).ExpectNil(err)
+	})
+	test.S(t).ExpectFalse(target.hasIssued("receive-seed-data/xtrabackup/13"))
+	test.S(t).ExpectFalse(source.hasIssued("send-seed-data/xtrabackup/target/13"))
+	test.S(t).ExpectTrue(target.hasIssued("prepare-seed-data/xtrabackup/13"))
+}
+
+func TestGetSeedSteps(t *testing.T) {
+	for _, seedMethod := range []SeedMethod{SeedMethodLVM, SeedMethodXtrabackup, SeedMethodMysqldump, SeedMethodMydumper} {
+		steps, err := getSeedSteps(seedMethod)
+		test.S(t).ExpectNil(err)
+		stepNames := make(map[string]bool)
+		for _, step := range steps {
+			test.S(t).ExpectFalse(stepNames[step.name])
+			stepNames[step.name] = true
+		}
+		test.S(t).ExpectTrue(stepNames["copy"])
+	}
+	_, err := getSeedSteps(SeedMethod("rsync"))
+	test.S(t).ExpectNotNil(err)
+}
+
+func TestGetSeedStepsSetupReplication(t *testing.T) {
+	stepNames := func() string {
+		steps, _ := getSeedSteps(SeedMethodMysqldump)
+		names := []string{}
+		for _, step := range steps {
+			names = append(names, step.name)
+		}
+		return strings.Join(names, ",")
+	}
+	originalSetupReplication := config.Config.SeedSetupReplication
+	defer func() { config.Config.SeedSetupReplication = originalSetupReplication }()
+
+	config.Config.SeedSetupReplication = true
+	test.S(t).ExpectEquals(stepNames(), "target-agent-info,source-agent-info,target-mysql-status,target-disk-space,copy,replication-coordinates,change-master,start-replication,await-replication,submit-discovery,register-instance")
+	config.Config.SeedSetupReplication = false
+	test.S(t).ExpectEquals(stepNames(), "target-agent-info,source-agent-info,target-mysql-status,target-disk-space,copy,submit-discovery")
+}
+
+func TestSeedStepsSkipReplicationSetup(t *testing.T) {
+	context := &seedContext{seedId: 7, skipReplicationSetup: true}
+	// None of these may reach for the target or source MySQL servers, of which there are none
+	for _, step := range []seedStep{changeMasterStep, startReplicationStep, awaitReplicationStep, registerInstanceStep} {
+		test.S(t).ExpectNil(step.execute(context))
+	}
+}
+
+func newSeedSourceInstance(logBin bool, logSlaveUpdates bool, isSlave bool) *inst.Instance {
+	source := inst.NewInstance()
+	source.Key = inst.InstanceKey{Hostname: "source", Port: 3306}
+	source.LogBinEnabled = logBin
+	source.LogSlaveUpdatesEnabled = logSlaveUpdates
+	if isSlave {
+		source.MasterKey = inst.InstanceKey{Hostname: "master", Port: 3306}
+		source.ReadBinlogCoordinates = inst.BinlogCoordinates{LogFile: "mysql-bin.000031", LogPos: 4}
+	}
+	return source
+}
+
+func TestSeedReplicationMaster(t *testing.T) {
+	coordinates := &SeedReplicationCoordinates{
+		BinlogCoordinates:       inst.BinlogCoordinates{LogFile: "source-bin.000012", LogPos: 1560},
+		MasterBinlogCoordinates: inst.BinlogCoordinates{LogFile: "mysql-bin.000031", LogPos: 870},
+	}
+	{
+		// A master: replicate from it
+		masterKey, masterCoordinates, useGTID, err := seedReplicationMaster(newSeedSourceInstance(true, false, false), coordinates)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(masterKey.Hostname, "source")
+		test.S(t).ExpectEquals(masterCoordinates.LogFile, "source-bin.000012")
+		test.S(t).ExpectFalse(useGTID)
+	}
+	{
+		// A slave logging its updates: replicate from it
+		masterKey, masterCoordinates, _, err := seedReplicationMaster(newSeedSourceInstance(true, true, true), coordinates)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(masterKey.Hostname, "source")
+		test.S(t).ExpectEquals(masterCoordinates.LogPos, int64(1560))
+	}
+	{
+		// A slave not logging its updates: become its sibling
+		masterKey, masterCoordinates, _, err := seedReplicationMaster(newSeedSourceInstance(true, false, true), coordinates)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(masterKey.Hostname, "master")
+		test.S(t).ExpectEquals(masterCoordinates.LogFile, "mysql-bin.000031")
+		test.S(t).ExpectEquals(masterCoordinates.LogPos, int64(870))
+	}
+	{
+		_, _, _, err := seedReplicationMaster(newSeedSourceInstance(false, false, false), coordinates)
+		test.S(t).ExpectNotNil(err)
+	}
+	{
+		_, _, _, err := seedReplicationMaster(newSeedSourceInstance(true, false, true), &SeedReplicationCoordinates{})
+		test.S(t).ExpectNotNil(err)
+	}
+	{
+		// GTID positions itself
+		source := newSeedSourceInstance(true, false, true)
+		source.SupportsOracleGTID = true
+		masterKey, _, useGTID, err := seedReplicationMaster(source, &SeedReplicationCoordinates{ExecutedGtidSet: "00020192-1111-1111-1111-111111111111:1-7300"})
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(masterKey.Hostname, "master")
+		test.S(t).ExpectTrue(useGTID)
+	}
+}
+
+func TestSeedProgressPercent(t *testing.T) {
+	seedOperation := SeedOperation{BytesCopied: 250, TotalBytes: 1000}
+	test.S(t).ExpectEquals(seedOperation.ProgressPercent(), int64(25))
+	seedOperation = SeedOperation{BytesCopied: 1200, TotalBytes: 1000}
+	test.S(t).ExpectEquals(seedOperation.ProgressPercent(), int64(100))
+	seedOperation = SeedOperation{BytesCopied: 1200}
+	test.S(t).ExpectEquals(seedOperation.ProgressPercent(), int64(0))
+}
@@ -0,0 +1,58 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package agent
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+func TestDatadirDiskUsedPercent(t *testing.T) {
+	agent := Agent{}
+	test.S(t).ExpectEquals(agent.DatadirDiskUsedPercent(), float64(0))
+	agent.MySQLDiskUsage = 30000
+	agent.MySQLDatadirDiskFree = 10000
+	// Total space unknown
+	test.S(t).ExpectEquals(agent.DatadirDiskUsedPercent(), float64(0))
+	// Files other than MySQL's take up space as well
+	agent.MySQLDatadirDiskTotal = 100000
+	test.S(t).ExpectEquals(agent.DatadirDiskUsedPercent(), float64(90))
+	agent.MySQLDatadirDiskFree = 0
+	test.S(t).ExpectEquals(agent.DatadirDiskUsedPercent(), float64(100))
+}
+
+func TestErrorLogCrashMarker(t *testing.T) {
+	originalCrashPatterns := config.Config.AgentErrorLogCrashPatterns
+	defer func() { config.Config.AgentErrorLogCrashPatterns = originalCrashPatterns }()
+
+	agent := Agent{}
+	test.S(t).ExpectEquals(agent.ErrorLogCrashMarker(), "")
+	agent.MySQLErrorLogTail = []string{
+		"161018 10:15:34 [ERROR] mysqld got signal 11 ;",
+		"161018 10:15:40 mysqld_safe mysqld restarted",
+		"161018 10:15:41 [Note] InnoDB: Starting crash recovery.",
+	}
+	test.S(t).ExpectEquals(agent.ErrorLogCrashMarker(), "161018 10:15:40 mysqld_safe mysqld restarted")
+
+	config.Config.AgentErrorLogCrashPatterns = []string{`[(`, `got signal [0-9]+`}
+	test.S(t).ExpectEquals(agent.ErrorLogCrashMarker(), "161018 10:15:34 [ERROR] mysqld got signal 11 ;")
+
+	config.Config.AgentErrorLogCrashPatterns = []string{}
+	test.S(t).ExpectEquals(agent.ErrorLogCrashMarker(), "")
+}
@@ -17,6 +17,7 @@
 package app
 
 import (
+	"bufio"
 	"fmt"
 	"net"
 	"os"
@@ -71,6 +72,15 @@ Usage for most commands:
 `, commandsListing())
 }
 
+// ValidateConfiguration validates given configuration files, including expressions of custom analysis rules
+func ValidateConfiguration(fileNames []string) *config.ConfigurationValidation {
+	validation := config.ValidateFiles(fileNames...)
+	for _, err := range inst.ValidateCustomAnalysisRules(validation.Configuration.CustomAnalysisRules) {
+		validation.AddError("CustomAnalysisRules: %+v", err)
+	}
+	return validation
+}
+
 // getClusterName will make a best effort to deduce a cluster name using either a given alias
 // or an instanceKey. First attempt is at alias, and if that doesn't work, we try instanceKey.
 func getClusterName(clusterAlias string, instanceKey *inst.InstanceKey) (clusterName string) {
@@ -203,21 +213,35 @@ func Cli(command string, strict bool, instance string, destination string, owner
 		skipDatabaseCommands = true
 	case "dump-config":
 		skipDatabaseCommands = true
+	case "validate-config":
+		skipDatabaseCommands = true
 	}
 
 	if !skipDatabaseCommands {
 		process.ContinuousRegistration(string(process.OrchestratorExecutionCliMode), command)
 	}
+	switch command {
+	case "stop-slave", "start-slave", "repoint", "relocate", "relocate-below", "move-up", "move-below", "move-gtid", "match", "match-below":
+	default:
+		if *config.RuntimeCLIFlags.ReplicationChannel != "" {
+			log.Fatalf("-channel is only supported by stop-slave, start-slave, repoint, relocate, move-up, move-below, move-gtid and match; not by %s", command)
+		}
+	}
 	// begin commands
 	switch command {
 	// smart mode
-	case registerCliCommand("relocate", "Smart relocation", `Relocate a slave beneath another instance`), registerCliCommand("relocate-below", "Smart relocation", `Synonym to 'relocate', will be deprecated`):
+	case registerCliCommand("relocate", "Smart relocation", `Relocate a slave beneath another instance. On a multi-source instance, -channel picks the replication channel to relocate`), registerCliCommand("relocate-below", "Smart relocation", `Synonym to 'relocate', will be deprecated`):
 		{
 			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
 			if destinationKey == nil {
 				log.Fatal("Cannot deduce destination:", destination)
 			}
-			_, err := inst.RelocateBelow(instanceKey, destinationKey)
+			var err error
+			if channel := *config.RuntimeCLIFlags.ReplicationChannel; channel != "" {
+				_, err = inst.RelocateBelowChannel(instanceKey, channel, destinationKey)
+			} else {
+				_, err = inst.RelocateBelow(instanceKey, destinationKey)
+			}
 			if err != nil {
 				log.Fatale(err)
 			}
@@ -264,10 +288,16 @@ func Cli(command string, strict bool, instance string, destination string, owner
 		}
 		// General replication commands
 		// move, binlog file:pos
-	case registerCliCommand("move-up", "Classic file:pos relocation", `Move a slave one level up the topology`):
+	case registerCliCommand("move-up", "Classic file:pos relocation", `Move a slave one level up the topology. On a multi-source instance, -channel picks the replication channel to move`):
 		{
 			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
-			instance, err := inst.MoveUp(instanceKey)
+			var instance *inst.Instance
+			var err error
+			if channel := *config.RuntimeCLIFlags.ReplicationChannel; channel != "" {
+				instance, err = inst.MoveUpChannel(instanceKey, channel)
+			} else {
+				instance, err = inst.MoveUp(instanceKey)
+			}
 			if err != nil {
 				log.Fatale(err)
 			}
@@ -292,13 +322,18 @@ func Cli(command string, strict bool, instance string, destination string, owner
 				}
 			}
 		}
-	case registerCliCommand("move-below", "Classic file:pos relocation", `Moves a slave beneath its sibling. Both slaves must be actively replicating from same master.`):
+	case registerCliCommand("move-below", "Classic file:pos relocation", `Moves a slave beneath its sibling. Both slaves must be actively replicating from same master. On a multi-source instance, -channel picks the replication channel to move`):
 		{
 			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
 			if destinationKey == nil {
 				log.Fatal("Cannot deduce destination/sibling:", destination)
 			}
-			_, err := inst.MoveBelow(instanceKey, destinationKey)
+			var err error
+			if channel := *config.RuntimeCLIFlags.ReplicationChannel; channel != "" {
+				_, err = inst.MoveBelowChannel(instanceKey, channel, destinationKey)
+			} else {
+				_, err = inst.MoveBelow(instanceKey, destinationKey)
+			}
 			if err != nil {
 				log.Fatale(err)
 			}
@@ -316,11 +351,17 @@ func Cli(command string, strict bool, instance string, destination string, owner
 			}
 			fmt.Println(fmt.Sprintf("%s<%s", instanceKey.DisplayString(), destinationKey.DisplayString()))
 		}
-	case registerCliCommand("repoint", "Classic file:pos relocation", `Make the given instance replicate from another instance without changing the binglog coordinates. Use with care`):
+	case registerCliCommand("repoint", "Classic file:pos relocation", `Make the given instance replicate from another instance without changing the binglog coordinates. Use with care. On a multi-source instance, -channel picks the replication channel to repoint`):
 		{
 			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
 			// destinationKey can be null, in which case the instance repoints to its existing master
-			instance, err := inst.Repoint(instanceKey, destinationKey, inst.GTIDHintNeutral)
+			var instance *inst.Instance
+			var err error
+			if channel := *config.RuntimeCLIFlags.ReplicationChannel; channel != "" {
+				instance, err = inst.RepointChannel(instanceKey, channel, destinationKey, inst.GTIDHintNeutral)
+			} else {
+				instance, err = inst.Repoint(instanceKey, destinationKey, inst.GTIDHintNeutral)
+			}
 			if err != nil {
 				log.Fatale(err)
 			}
@@ -406,13 +447,18 @@ func Cli(command string, strict bool, instance string, destination string, owner
 			}
 		}
 	// move, GTID
-	case registerCliCommand("move-gtid", "GTID relocation", `Move a slave beneath another instance.`):
+	case registerCliCommand("move-gtid", "GTID relocation", `Move a slave beneath another instance. On a multi-source instance, -channel picks the replication channel to move`):
 		{
 			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
 			if destinationKey == nil {
 				log.Fatal("Cannot deduce destination:", destination)
 			}
-			_, err := inst.MoveBelowGTID(instanceKey, destinationKey)
+			var err error
+			if channel := *config.RuntimeCLIFlags.ReplicationChannel; channel != "" {
+				_, err = inst.MoveBelowGTIDChannel(instanceKey, channel, destinationKey)
+			} else {
+				_, err = inst.MoveBelowGTID(instanceKey, destinationKey)
+			}
 			if err != nil {
 				log.Fatale(err)
 			}
@@ -457,14 +503,19 @@ func Cli(command string, strict bool, instance string, destination string, owner
 			}
 		}
 		// Pseudo-GTID
-	case registerCliCommand("match", "Pseudo-GTID relocation", `Matches a slave beneath another (destination) instance using Pseudo-GTID`),
+	case registerCliCommand("match", "Pseudo-GTID relocation", `Matches a slave beneath another (destination) instance using Pseudo-GTID. On a multi-source instance, -channel picks the replication channel to match`),
 		registerCliCommand("match-below", "Pseudo-GTID relocation", `Synonym to 'match', will be deprecated`):
 		{
 			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
 			if destinationKey == nil {
 				log.Fatal("Cannot deduce destination:", destination)
 			}
-			_, _, err := inst.MatchBelow(instanceKey, destinationKey, true)
+			var err error
+			if channel := *config.RuntimeCLIFlags.ReplicationChannel; channel != "" {
+				_, _, err = inst.MatchBelowChannel(instanceKey, channel, destinationKey, true)
+			} else {
+				_, _, err = inst.MatchBelow(instanceKey, destinationKey, true)
+			}
 			if err != nil {
 				log.Fatale(err)
 			}
@@ -570,6 +621,45 @@ func Cli(command string, strict bool, instance string, destination string, owner
 			}
 			fmt.Println(instanceKey.DisplayString())
 		}
+	case registerCliCommand("enable-replication-tls", "Replication, general", `Turn on TLS for the replication of a slave, using the MySQLReplicationSSL* certificate files`):
+		{
+			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
+			_, err := inst.EnableReplicationTLS(instanceKey)
+			if err != nil {
+				log.Fatale(err)
+			}
+			fmt.Println(instanceKey.DisplayString())
+		}
+	case registerCliCommand("disable-replication-tls", "Replication, general", `Turn off TLS for the replication of a slave`):
+		{
+			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
+			_, err := inst.DisableReplicationTLS(instanceKey)
+			if err != nil {
+				log.Fatale(err)
+			}
+			fmt.Println(instanceKey.DisplayString())
+		}
+	case registerCliCommand("rotate-replication-credentials", "Replication, general", `Apply new replication credentials on all slaves of a cluster, one slave at a time, rolling back on failure`):
+		{
+			clusterName := getClusterName(clusterAlias, instanceKey)
+			replicationUser := *config.RuntimeCLIFlags.ReplicationUser
+			if replicationUser == "" {
+				log.Fatal("--replication-user must be provided for rotate-replication-credentials")
+			}
+			// The password is read from standard input so as to not appear in the process list
+			replicationPassword, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && replicationPassword == "" {
+				log.Fatalf("Cannot read replication password from standard input: %+v", err)
+			}
+			replicationPassword = strings.TrimRight(replicationPassword, "\r\n")
+			rotation, err := inst.RotateReplicationCredentials(clusterName, replicationUser, replicationPassword)
+			if err != nil {
+				log.Fatale(err)
+			}
+			for _, key := range rotation.Rotated {
+				fmt.Println(key.DisplayString())
+			}
+		}
 	case registerCliCommand("reset-master-gtid-remove-own-uuid", "Replication, general", `Reset master on instance, remove GTID entries generated by instance`):
 		{
 			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
@@ -588,19 +678,29 @@ func Cli(command string, strict bool, instance string, destination string, owner
 			}
 			fmt.Println(instanceKey.DisplayString())
 		}
-	case registerCliCommand("stop-slave", "Replication, general", `Issue a STOP SLAVE on an instance`):
+	case registerCliCommand("stop-slave", "Replication, general", `Issue a STOP SLAVE on an instance. On a multi-source instance all replication channels are stopped, unless -channel picks one`):
 		{
 			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
-			_, err := inst.StopSlave(instanceKey)
+			var err error
+			if channel := *config.RuntimeCLIFlags.ReplicationChannel; channel != "" {
+				_, err = inst.StopSlaveChannel(instanceKey, channel)
+			} else {
+				_, err = inst.StopSlave(instanceKey)
+			}
 			if err != nil {
 				log.Fatale(err)
 			}
 			fmt.Println(instanceKey.DisplayString())
 		}
-	case registerCliCommand("start-slave", "Replication, general", `Issue a START SLAVE on an instance`):
+	case registerCliCommand("start-slave", "Replication, general", `Issue a START SLAVE on an instance. On a multi-source instance all replication channels are started, unless -channel picks one`):
 		{
 			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
-			_, err := inst.StartSlave(instanceKey)
+			var err error
+			if channel := *config.RuntimeCLIFlags.ReplicationChannel; channel != "" {
+				_, err = inst.StartSlaveChannel(instanceKey, channel)
+			} else {
+				_, err = inst.StartSlave(instanceKey)
+			}
 			if err != nil {
 				log.Fatale(err)
 			}
@@ -723,6 +823,15 @@ func Cli(command string, strict bool, instance string, destination string, owner
 			}
 			fmt.Println(instanceKey.DisplayString())
 		}
+	case registerCliCommand("fix-read-only", "Instance", `Set read_only on an instance according to its role: masters writeable, slaves read-only`):
+		{
+			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
+			fixedInstance, err := inst.FixReadOnly(instanceKey)
+			if err != nil {
+				log.Fatale(err)
+			}
+			fmt.Println(fmt.Sprintf("%s read_only=%t", instanceKey.DisplayString(), fixedInstance.ReadOnly))
+		}
 		// Binary log operations
 	case registerCliCommand("flush-binary-logs", "Binary logs", `Flush binary logs on an instance`):
 		{
@@ -771,6 +880,34 @@ func Cli(command string, strict bool, instance string, destination string, owner
 			}
 			fmt.Println(fmt.Sprintf("%+v:%s", *coordinates, text))
 		}
+	case registerCliCommand("inject-pseudo-gtid", "Binary logs", `Inject a Pseudo-GTID entry on an instance, in the format used by AutoPseudoGTID`):
+		{
+			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
+			if instanceKey == nil {
+				log.Fatalf("Unresolved instance")
+			}
+			hint, coordinates, err := inst.InjectPseudoGTID(instanceKey)
+			if err != nil {
+				log.Fatale(err)
+			}
+			fmt.Println(fmt.Sprintf("%+v:%s", *coordinates, hint))
+		}
+	case registerCliCommand("pseudo-gtid-injection-status", "Binary logs", `Show status of automated Pseudo-GTID injection on cluster masters`):
+		{
+			var statuses []inst.PseudoGTIDInjectionStatus
+			var err error
+			if clusterAlias != "" || instanceKey != nil {
+				statuses, err = inst.ReadClusterPseudoGTIDInjectionStatuses(getClusterName(clusterAlias, instanceKey))
+			} else {
+				statuses, err = inst.ReadPseudoGTIDInjectionStatuses()
+			}
+			if err != nil {
+				log.Fatale(err)
+			}
+			for _, status := range statuses {
+				fmt.Println(fmt.Sprintf("%s\t%s\t%s\t%d\t%d\t%s", status.Key.DisplayString(), status.ClusterName, status.LastInjectedTimestamp, status.ConsecutiveFailures, status.CountLaggingSlaves, status.LaggingSlaves.ToCommaDelimitedList()))
+			}
+		}
 	case registerCliCommand("find-binlog-entry", "Binary logs", `Get binlog file:pos of entry given by --pattern (exact full match, not a regular expression) in a given instance`):
 		{
 			if pattern == "" {
@@ -903,15 +1040,47 @@ func Cli(command string, strict bool, instance string, destination string, owner
 				}
 			}
 		}
-	case registerCliCommand("topology", "Information", `Show an ascii-graph of a replication topology, given a member of that topology`):
+	case registerCliCommand("topology", "Information", `Show an ascii-graph of a replication topology, given a member of that topology. See --format for json, dot, mermaid`):
 		{
 			clusterName := getClusterName(clusterAlias, instanceKey)
-			output, err := inst.ASCIITopology(clusterName, pattern)
+			output, err := inst.ExportTopology(clusterName, pattern, *config.RuntimeCLIFlags.Format)
 			if err != nil {
 				log.Fatale(err)
 			}
 			fmt.Println(output)
 		}
+	case registerCliCommand("topology-diff", "Information", `Show the differences in a replication topology between two points in time, based on topology snapshots`):
+		{
+			clusterName := getClusterName(clusterAlias, instanceKey)
+			from, err := inst.ParseTopologyHistoryTime(*config.RuntimeCLIFlags.HistoryFrom)
+			if err != nil {
+				log.Fatale(err)
+			}
+			if from.IsZero() {
+				log.Fatal("--from option required")
+			}
+			to, err := inst.ParseTopologyHistoryTime(*config.RuntimeCLIFlags.HistoryTo)
+			if err != nil {
+				log.Fatale(err)
+			}
+			diff, err := inst.DiffClusterTopology(clusterName, from, to)
+			if err != nil {
+				log.Fatale(err)
+			}
+			log.Infof("Comparing %s at %s with %s", clusterName, diff.FromTimestamp, diff.ToTimestamp)
+			for _, instanceKey := range diff.AddedInstances {
+				fmt.Println(fmt.Sprintf("+ %s", instanceKey.DisplayString()))
+			}
+			for _, instanceKey := range diff.RemovedInstances {
+				fmt.Println(fmt.Sprintf("- %s", instanceKey.DisplayString()))
+			}
+			for _, move := range diff.MovedInstances {
+				fmt.Println(fmt.Sprintf("> %s %s -> %s", move.Key.DisplayString(), move.FromMasterKey.DisplayString(), move.ToMasterKey.DisplayString()))
+			}
+			for _, reconfiguration := range diff.ReconfiguredInstances {
+				fmt.Println(fmt.Sprintf("~ %s %s", reconfiguration.Key.DisplayString(), strings.Join(reconfiguration.Changes, ", ")))
+			}
+		}
 	case registerCliCommand("all-instances", "Information", `The complete list of known instances`):
 		{
 			instances, err := inst.FindInstances(".")
@@ -978,6 +1147,23 @@ func Cli(command string, strict bool, instance string, destination string, owner
 				fmt.Println(clusterInstance.Key.DisplayString())
 			}
 		}
+	case registerCliCommand("which-cluster-replication-tls", "Information", `Output the slaves of a cluster, each marked with whether it replicates over TLS`):
+		{
+			clusterName := getClusterName(clusterAlias, instanceKey)
+			clusterReplicationTLS, err := inst.ReadClusterReplicationTLS(clusterName)
+			if err != nil {
+				log.Fatale(err)
+			}
+			for _, key := range clusterReplicationTLS.Encrypted {
+				fmt.Println(fmt.Sprintf("%s\ttls", key.DisplayString()))
+			}
+			for _, key := range clusterReplicationTLS.Unencrypted {
+				fmt.Println(fmt.Sprintf("%s\tno-tls", key.DisplayString()))
+			}
+			if len(clusterReplicationTLS.Unencrypted) > 0 {
+				log.Fatalf("%d slaves of %s do not replicate over TLS", len(clusterReplicationTLS.Unencrypted), clusterName)
+			}
+		}
 	case registerCliCommand("which-cluster-osc-slaves", "Information", `Output a list of slaves in a cluster, that could serve as a pt-online-schema-change operation control slaves`):
 		{
 			clusterName := getClusterName(clusterAlias, instanceKey)
@@ -1110,6 +1296,25 @@ func Cli(command string, strict bool, instance string, destination string, owner
 			}
 			fmt.Println(instanceKey.DisplayString())
 		}
+	case registerCliCommand("cluster-locks", "Instance management", `List currently held cluster locks`):
+		{
+			clusterLocks, err := inst.ReadActiveClusterLocks()
+			if err != nil {
+				log.Fatale(err)
+			}
+			for _, clusterLock := range clusterLocks {
+				fmt.Println(fmt.Sprintf("%s\t%s\t%s\t%s", clusterLock.ClusterName, clusterLock.Owner, clusterLock.BeginTimestamp, clusterLock.Reason))
+			}
+		}
+	case registerCliCommand("end-cluster-lock", "Instance management", `Forcibly release the lock held on a cluster`):
+		{
+			clusterName := getClusterName(clusterAlias, instanceKey)
+			err := inst.ForceEndClusterLock(clusterName, fmt.Sprintf("Triggered via command line by %s", inst.GetMaintenanceOwner()))
+			if err != nil {
+				log.Fatale(err)
+			}
+			fmt.Println(clusterName)
+		}
 	case registerCliCommand("begin-downtime", "Instance management", `Mark an instance as downtimed`):
 		{
 			instanceKey = deduceInstanceKeyIfNeeded(instance, instanceKey, true)
@@ -1271,6 +1476,20 @@ func Cli(command string, strict bool, instance string, destination string, owner
 				log.Fatale(err)
 			}
 		}
+	case registerCliCommand("submit-masters-to-kv-stores", "Meta", `Rewrite writeable masters and replicating slaves of all clusters (or of given cluster) into configured key-value stores`):
+		{
+			clusterName := ""
+			if clusterAlias != "" || instanceKey != nil {
+				clusterName = getClusterName(clusterAlias, instanceKey)
+			}
+			kvPairs, _, err := logic.SubmitMastersToKVStores(clusterName, true)
+			if err != nil {
+				log.Fatale(err)
+			}
+			for _, kvPair := range kvPairs {
+				fmt.Println(kvPair.String())
+			}
+		}
 	case registerCliCommand("continuous", "Meta", `Enter continuous mode, and actively poll for instances, diagnose problems, do maintenance`):
 		{
 			logic.ContinuousDiscovery()
@@ -1325,6 +1544,20 @@ func Cli(command string, strict bool, instance string, destination string, owner
 			jsonString := config.Config.ToJSONString()
 			fmt.Println(jsonString)
 		}
+	case registerCliCommand("validate-config", "Meta", `Validate configuration files: unknown fields, regular expressions, placeholders, value ranges, files. Exits with error on invalid configuration`):
+		{
+			validation := ValidateConfiguration(config.ReadFileNames())
+			for _, warning := range validation.Warnings {
+				fmt.Println("WARNING:", warning)
+			}
+			for _, validationError := range validation.Errors {
+				fmt.Println("ERROR:", validationError)
+			}
+			if !validation.IsValid() {
+				log.Fatalf("Invalid configuration: %d errors in %s", len(validation.Errors), strings.Join(validation.FileNames, ", "))
+			}
+			fmt.Printf("Configuration is valid: %s\n", strings.Join(validation.FileNames, ", "))
+		}
 	case registerCliCommand("redeploy-internal-db", "Meta, internal", `Force internal schema migration to current backend structure`):
 		{
 			config.RuntimeCLIFlags.ConfiguredVersion = ""
@@ -1333,12 +1566,17 @@ func Cli(command string, strict bool, instance string, destination string, owner
 		}
 	case registerCliCommand("custom-command", "Agent", "Execute a custom command on the agent as defined in the agent conf"):
 		{
-			output, err := agent.CustomCommand(hostnameFlag, pattern)
+			request := &agent.CustomCommandRequest{
+				Command:     pattern,
+				Arguments:   config.RuntimeCLIFlags.CommandArguments,
+				RequestedBy: "cli",
+			}
+			result, err := agent.ExecuteCustomCommand(hostnameFlag, request)
 			if err != nil {
 				log.Fatale(err)
 			}
 
-			fmt.Printf("%v\n", output)
+			fmt.Printf("%v\n", result.Output)
 		}
 	// Help
 	case "help":
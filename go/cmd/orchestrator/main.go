@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/outbrain/golib/log"
 	"github.com/outbrain/golib/math"
@@ -342,6 +343,29 @@ Cheatsheet:
 
             orchestrator -c disable-gtid -i slave.replicating.via.gtid.com
 
+        enable-replication-tls
+            Turn on TLS for the replication of a slave (CHANGE MASTER TO MASTER_SSL=1), setting the certificate files
+            configured by MySQLReplicationSSLCAFile, MySQLReplicationSSLCertFile and MySQLReplicationSSLPrivateKeyFile.
+            Replication is stopped for a short duration. TLS settings are thereafter kept when orchestrator repoints
+            the slave. Example:
+
+            orchestrator -c enable-replication-tls -i slave.to.encrypt.com
+
+        disable-replication-tls
+            Turn off TLS for the replication of a slave. Example:
+
+            orchestrator -c disable-replication-tls -i slave.to.decrypt.com
+
+        rotate-replication-credentials
+            Apply new replication credentials (MASTER_USER, MASTER_PASSWORD) on all slaves of a cluster, indicated by
+            instance or alias. The user is given by --replication-user; the password is read from standard input, and
+            is neither logged nor audited. Slaves are handled one at a time: each is put in maintenance (a slave already
+            in maintenance aborts the operation), has its IO thread stopped, its credentials changed, and is verified to
+            reconnect to its master. Upon failure, all slaves handled so far are rolled back to their previous credentials,
+            which requires master_info_repository=TABLE. Example:
+
+            echo "new_password" | orchestrator -c rotate-replication-credentials -alias some_alias --replication-user repl
+
         reset-master-gtid-remove-own-uuid
             Assuming GTID is enabled, Reset master on instance, remove GTID entries generated by the instance.
             This operation is only allowed on Oracle-GTID enabled servers that have no slaves.
@@ -438,6 +462,13 @@ Cheatsheet:
             orchestrator -c set-writeable
                 -i not given, implicitly assumed local hostname
 
+        fix-read-only
+            Set read_only on an instance according to its role: a master (not replicating from anywhere) is made
+            writeable, a slave is made read-only. Co-masters and binlog servers are not supported. The instance
+            is put under maintenance while changed. Remedies WritableSlave and ReadOnlyMaster analysis. Example:
+
+            orchestrator -c fix-read-only -i slave.that.should.be.read.only.com
+
     Binlog commands
         Commands that investigate/work on binary logs
 
@@ -462,6 +493,22 @@ Cheatsheet:
 
             orchestrator -c last-pseudo-gtid -i instance.with.possible.pseudo-gtid.injection
 
+        inject-pseudo-gtid
+            Inject a Pseudo-GTID entry on an instance, in the same format used by AutoPseudoGTID (in config). The entry
+            is a "drop view if exists" statement on PseudoGTIDSchema (which need not exist).
+            Prints out the binlog coordinates following the injection, and the injected entry. Example:
+
+            orchestrator -c inject-pseudo-gtid -i writeable.master.com
+
+        pseudo-gtid-injection-status
+            Show status of automated Pseudo-GTID injection (AutoPseudoGTID in config): per master, the last time an entry was
+            injected, the number of consecutive failed injections, and the slaves not seen to execute injected entries within
+            AutoPseudoGTIDVerificationSeconds. Examples:
+
+            orchestrator -c pseudo-gtid-injection-status
+
+            orchestrator -c pseudo-gtid-injection-status -alias mycluster
+
         find-binlog-entry
             Get binlog file:pos of entry given by --pattern (exact full match, not a regular expression) in a given instance.
             This will search the instance's binary logs starting with most recent, and terminate as soon as an exact match is found.
@@ -560,6 +607,28 @@ Cheatsheet:
             and not from synchronuous investigation of the instances. The generated topology may include
             instances that are dead, or whose replication is broken.
 
+            Use --format to get a machine friendly nested replication tree, annotated with lag, read_only,
+            binlog format, GTID mode, downtime and problems:
+
+            orchestrator -c topology -alias mycluster --format=json
+
+            orchestrator -c topology -alias mycluster --format=dot | dot -Tpng -o mycluster.png
+
+            orchestrator -c topology -alias mycluster --format=mermaid
+
+        topology-diff
+            Show the differences in a replication topology between two points in time. This is based on topology
+            snapshots (see snapshot-topologies, SnapshotTopologiesIntervalHours); each point in time is matched with
+            the latest snapshot taken at or before it. Output lists added (+), removed (-), moved (>) and reconfigured (~)
+            instances. A moved instance is listed along with its previous and new masters; a reconfigured instance
+            lists changes in read_only, GTID mode, version, data center and downtime.
+            Examples:
+
+            orchestrator -c topology-diff -alias mycluster --from="2016-05-01 03:14"
+                --to not given; compare with current topology
+
+            orchestrator -c topology-diff -i instance.belonging.to.a.topology.com --from="2016-05-01 03:00" --to="2016-05-01 04:00"
+
         all-instances
             List the complete known set of instances. Similar to '-c find -pattern "."'
 			Example:
@@ -595,6 +664,12 @@ Cheatsheet:
             orchestrator -c which-cluster-instances -alias some_alias
                 assuming some_alias is a known cluster alias (see ClusterNameToAlias or DetectClusterAliasQuery configuration)
 
+        which-cluster-replication-tls
+            Output the slaves of a cluster, indicated by instance or alias, one per line, each followed by "tls" or
+            "no-tls". Exits with error when any slave does not replicate over TLS. Example:
+
+            orchestrator -c which-cluster-replication-tls -alias some_alias
+
         which-cluster-domain
             Output the domain name of given cluster, indicated by instance or alias. This depends on
 						the DetectClusterDomainQuery configuration. Example:
@@ -708,6 +783,26 @@ Cheatsheet:
 
             orchestrator -c snapshot-topologies
 
+        submit-masters-to-kv-stores
+            Rewrite the writeable master and replicating slaves of each cluster into configured key-value stores
+            (Consul, etcd; see ConsulAddress, EtcdAddress). Orchestrator normally only writes entries as they change;
+            this command forces a full resync. Limit to a single cluster via -alias or -i. Examples:
+
+            orchestrator -c submit-masters-to-kv-stores
+
+            orchestrator -c submit-masters-to-kv-stores -alias mycluster
+
+        validate-config
+            Validate configuration files without connecting to the backend database. Reports unknown or misspelled
+            fields, deprecated and removed settings, invalid regular expressions and cluster filters, unknown
+            placeholders in hook commands, out of range values, unreadable certificate and credential files, and
+            contradicting settings. Exits with non-zero code when errors are found; warnings alone do not fail.
+            Validation can also be enforced on startup via --strict-config. Examples:
+
+            orchestrator -c validate-config
+
+            orchestrator -c validate-config --config /etc/orchestrator.conf.json
+
     Orchestrator instance management
         These command dig into the way orchestrator manages instances and operations on instances
 
@@ -758,6 +853,24 @@ Cheatsheet:
 
             orchestrator -c end-maintenance -i locked.instance.com
 
+        cluster-locks
+            List currently held cluster locks. A cluster lock is implicitly acquired by operations refactoring multiple
+            instances of a cluster (e.g. regroup-slaves, relocate-slaves, make-master) so that no two such operations,
+            or such an operation and a recovery, run on the same cluster at the same time.
+            Example:
+
+            orchestrator -c cluster-locks
+
+        end-cluster-lock
+            Forcibly release the lock held on a cluster, whoever holds it. Cluster locks are released by the operation
+            which acquired them, and orchestrator automatically expires them after ClusterLockExpireMinutes (in config).
+            Use this to release a lock left behind by an interrupted operation.
+            Examples:
+
+            orchestrator -c end-cluster-lock -alias mycluster
+
+            orchestrator -c end-cluster-lock -i instance.in.locked.cluster.com
+
         begin-downtime
             Mark an instance as downtimed. A downtimed instance is assumed to be taken care of, and recovery-analysis does
             not apply for such an instance. As result, no recommendation for recovery, and no automated-recovery are issued
@@ -978,6 +1091,7 @@ func main() {
 	configFile := flag.String("config", "", "config file name")
 	command := flag.String("c", "", "command, required. See full list of commands via 'orchestrator -c help'")
 	strict := flag.Bool("strict", false, "strict mode (more checks, slower)")
+	config.RuntimeCLIFlags.StrictConfig = flag.Bool("strict-config", false, "Validate configuration files upon startup, and bail out on any error (see validate-config). Also refuse configuration reloads on any error")
 	instance := flag.String("i", "", "instance, host_fqdn[:port] (e.g. db.company.com:3306, db.company.com)")
 	sibling := flag.String("s", "", "sibling instance, host_fqdn[:port]")
 	destination := flag.String("d", "", "destination instance, host_fqdn[:port] (synonym to -s)")
@@ -1001,6 +1115,12 @@ func main() {
 	config.RuntimeCLIFlags.Statement = flag.String("statement", "", "Statement/hint")
 	config.RuntimeCLIFlags.GrabElection = flag.Bool("grab-election", false, "Grab leadership (only applies to continuous mode)")
 	config.RuntimeCLIFlags.PromotionRule = flag.String("promotion-rule", "prefer", "Promotion rule for register-andidate (prefer|neutral|must_not)")
+	config.RuntimeCLIFlags.HistoryFrom = flag.String("from", "", "Point in time to compare topology from (applies for topology-diff). Unix timestamp or 'YYYY-MM-DD hh:mm[:ss]'")
+	config.RuntimeCLIFlags.Format = flag.String("format", "", "Output format (applies for topology): ascii|json|dot|mermaid. Defaults to ascii")
+	config.RuntimeCLIFlags.HistoryTo = flag.String("to", "", "Point in time to compare topology to (applies for topology-diff). Defaults to current topology")
+	config.RuntimeCLIFlags.ReplicationUser = flag.String("replication-user", "", "Replication user (applies for rotate-replication-credentials). The password is read from standard input")
+	config.RuntimeCLIFlags.ReplicationChannel = flag.String("channel", "", "Replication channel (MySQL) or connection name (MariaDB) of a multi-source slave (applies for stop-slave, start-slave, repoint)")
+	flag.Var(&config.RuntimeCLIFlags.CommandArguments, "arg", "Custom command argument (applies for custom-command). May be given multiple times")
 	config.RuntimeCLIFlags.Version = flag.Bool("version", false, "Print version and exit")
 	flag.Parse()
 
@@ -1045,6 +1165,18 @@ func main() {
 	} else {
 		config.Read("/etc/orchestrator.conf.json", "conf/orchestrator.conf.json", "orchestrator.conf.json")
 	}
+	if *config.RuntimeCLIFlags.StrictConfig {
+		validation := app.ValidateConfiguration(config.ReadFileNames())
+		for _, warning := range validation.Warnings {
+			log.Warning(warning)
+		}
+		for _, validationError := range validation.Errors {
+			log.Errorf("%s", validationError)
+		}
+		if !validation.IsValid() {
+			log.Fatalf("Invalid configuration: %d errors in %s. Run with -c validate-config for details", len(validation.Errors), strings.Join(validation.FileNames, ", "))
+		}
+	}
 	if *config.RuntimeCLIFlags.Databaseless {
 		config.Config.DatabaselessMode__experimental = true
 	}
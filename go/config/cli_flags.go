@@ -16,6 +16,22 @@
 
 package config
 
+import (
+	"strings"
+)
+
+// StringsFlag is a flag which may be given multiple times, collecting all values
+type StringsFlag []string
+
+func (this *StringsFlag) String() string {
+	return strings.Join(*this, " ")
+}
+
+func (this *StringsFlag) Set(value string) error {
+	*this = append(*this, value)
+	return nil
+}
+
 // CLIFlags stores some command line flags that are globally available in the process' lifetime
 type CLIFlags struct {
 	Noop               *bool
@@ -27,6 +43,13 @@ type CLIFlags struct {
 	Version            *bool
 	Statement          *string
 	PromotionRule      *string
+	HistoryFrom        *string
+	HistoryTo          *string
+	Format             *string
+	CommandArguments   StringsFlag
+	ReplicationChannel *string
+	ReplicationUser    *string
+	StrictConfig       *bool
 	ConfiguredVersion  string
 }
 
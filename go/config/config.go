@@ -18,6 +18,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"regexp"
 
@@ -30,6 +31,36 @@ var (
 	envVariableRegexp = regexp.MustCompile("[$][{](.*)[}]")
 )
 
+// AnalysisRule is a user defined replication analysis rule. See CustomAnalysisRules
+type AnalysisRule struct {
+	Name                 string   // Reported analysis code, e.g. "SlaveWithReplicationFilters"
+	Description          string   // Human friendly description of the problem
+	Expression           string   // Boolean expression over Instance, Master and Analysis fields, e.g. `Instance.HasReplicationFilters && Instance.ClusterName =~ "^orders"`
+	Severity             string   // One of "info", "warning" (default), "error"
+	OnDetectionProcesses []string // Processes to execute when the rule starts matching an instance. Uses same placeholders as OnFailureDetectionProcesses
+}
+
+// CommandRule allows execution of an agent custom command. See AgentCustomCommands
+type CommandRule struct {
+	Command          string   // Custom command name, as configured on the agents
+	ArgumentPatterns []string // Regular expression per positional argument, which the argument must fully match. No patterns: no arguments allowed
+	Roles            []string // Roles (see AuthRoles) allowed to execute the command. Empty, or "*": any user allowed to make changes
+}
+
+// QueryKillPolicy makes orchestrator kill long running queries. See LongRunningQueryKillPolicies
+type QueryKillPolicy struct {
+	Name           string // Policy name, as audited
+	ClusterPattern string // Regular expression a cluster name or alias must match. Empty: any cluster
+	UserPattern    string // Regular expression the query's user must match. Empty: any user
+	CommandPattern string // Regular expression the query's text (PROCESSLIST's Info) must match. Empty: any query
+	MaxTimeSeconds int64  // Queries running for longer than this many seconds are killed
+	SlavesOnly     bool   // When true, only kill queries running on slaves
+	DryRun         bool   // When true, queries are audited as would-be killed, but not killed
+}
+
+// RoleUsers maps a role name onto the users having that role
+type RoleUsers map[string][]string
+
 // Configuration makes for orchestrator configuration input, which can be provided by user via JSON formatted file.
 // Some of the parameteres have reasonable default values, and some (like database credentials) are
 // strictly expected from user.
@@ -47,6 +78,10 @@ type Configuration struct {
 	MySQLTopologySSLCAFile                       string // Certificate Authority PEM file used to authenticate with a Topology mysql instance with TLS
 	MySQLTopologySSLSkipVerify                   bool   // If true, do not strictly validate mutual TLS certs for Topology mysql instances
 	MySQLTopologyUseMutualTLS                    bool   // Turn on TLS authentication with the Topology MySQL instances
+	MySQLReplicationSSLCAFile                    string // Certificate Authority PEM file, as found on the slaves' hosts, set as MASTER_SSL_CA by enable-replication-tls
+	MySQLReplicationSSLCertFile                  string // Certificate PEM file, as found on the slaves' hosts, set as MASTER_SSL_CERT by enable-replication-tls
+	MySQLReplicationSSLPrivateKeyFile            string // Private key file, as found on the slaves' hosts, set as MASTER_SSL_KEY by enable-replication-tls
+	MySQLReplicationSSLVerifyServerCert          bool   // When true, enable-replication-tls sets MASTER_SSL_VERIFY_SERVER_CERT=1
 	MySQLTopologyMaxPoolConnections              int    // Max concurrent connections on any topology instance
 	DatabaselessMode__experimental               bool   // !!!EXPERIMENTAL!!! Orchestrator will execute without speaking to a backend database; super-standalone mode
 	MySQLOrchestratorHost                        string
@@ -85,6 +120,7 @@ type Configuration struct {
 	ReasonableMaintenanceReplicationLagSeconds   int      // Above this value move-up and move-below are blocked
 	MaintenanceExpireMinutes                     uint     // Minutes after which a maintenance flag is considered stale and is cleared
 	MaintenancePurgeDays                         uint     // Days after which maintenance entries are purged from the database
+	ClusterLockExpireMinutes                     uint     // Minutes after which a cluster lock is considered stale and is released
 	CandidateInstanceExpireMinutes               uint     // Minutes after which a suggestion to use an instance as a candidate slave (to be preferably promoted on master failover) is expired.
 	AuditLogFile                                 string   // Name of log file for audit operations. Disabled when empty.
 	AuditToSyslog                                bool     // If true, audit messages are written to syslog
@@ -97,6 +133,7 @@ type Configuration struct {
 	HTTPAuthPassword                             string            // Password for HTTP Basic authentication
 	AuthUserHeader                               string            // HTTP header indicating auth user, when AuthenticationMethod is "proxy"
 	PowerAuthUsers                               []string          // On AuthenticationMethod == "proxy", list of users that can make changes. All others are read-only.
+	AuthRoles                                    RoleUsers         // Maps role names onto authenticated user names ("basic", "multi" and "proxy" authentication methods). Used by AgentCustomCommands
 	AccessTokenUseExpirySeconds                  uint              // Time by which an issued token must be used
 	AccessTokenExpiryMinutes                     uint              // Time after which HTTP access token expires
 	ClusterNameToAlias                           map[string]string // map between regex matching cluster name to a human friendly alias
@@ -120,6 +157,10 @@ type Configuration struct {
 	AgentSSLCertFile                             string            // Name of Agent SSL certification file, applies only when AgentsUseSSL = true
 	AgentSSLCAFile                               string            // Name of the Agent Certificate Authority file, applies only when AgentsUseSSL = true
 	AgentSSLValidOUs                             []string          // Valid organizational units when using mutual TLS to communicate with the agents
+	AgentRegistrationSecret                      string            // When set, agent registration & token rotation requests must be signed with HMAC-SHA256 using this secret. May be given as "${SOME_ENV_VARIABLE}"
+	AgentRegistrationMaxSkewSeconds              uint              // Maximum age (or clock skew) of a signed agent registration request
+	AgentRegistrationVerifyHostname              bool              // When true, the hostname an agent registers with must match its client certificate (mutual TLS) or resolve to the request's source IP
+	AgentRegistrationPinCertificate              bool              // When true, an agent's client certificate is pinned on registration; a later registration for same host with a different certificate is rejected unless rotating token
 	UseSSL                                       bool              // Use SSL on the server web port
 	UseMutualTLS                                 bool              // When "true" Use mutual TLS for the server's web and API connections
 	SSLSkipVerify                                bool              // When using SSL, should we ignore SSL certification error
@@ -135,16 +176,29 @@ type Configuration struct {
 	AgentAutoDiscover                            bool              // If true, instances should automatically discover when an agent is submitted
 	UnseenAgentForgetHours                       uint              // Number of hours after which an unseen agent is forgotten
 	StaleSeedFailMinutes                         uint              // Number of minutes after which a stale (no progress) seed is considered failed.
+	AgentDatadirDiskUsedPercentThreshold         float64           // Percent of datadir disk space in use, reported by agent, above which analysis reports DatadirDiskAlmostFull. 0 to disable
+	AgentDatadirDiskFreeThresholdBytes           int64             // Free datadir disk space, reported by agent, below which analysis reports DatadirDiskAlmostFull. 0 to disable
+	AgentErrorLogCrashPatterns                   []string          // Regular expressions which, when matching a line in the MySQL error log tail reported by agent, make for ErrorLogCrashMarker analysis
+	AgentCustomCommands                          []CommandRule     // Allowlist of agent custom commands. When empty, any custom command may be executed by users allowed to make changes
+	AgentCustomCommandAuditOutputBytes           int               // Length to which custom command output is truncated in agent_custom_command_audit
+	LongRunningQueryKillPolicies                 []QueryKillPolicy // Policies by which the elected orchestrator kills long running queries after polling an instance. Requires ReadLongRunningQueries
 	SeedAcceptableBytesDiff                      int64             // Difference in bytes between seed source & target data size that is still considered as successful copy
+	SeedSetupReplication                         bool              // If true, a seeded target host is set to replicate from seed source (or its master) at captured coordinates, awaited to catch up and registered in source's pools
 	PseudoGTIDPattern                            string            // Pattern to look for in binary logs that makes for a unique entry (pseudo GTID). When empty, Pseudo-GTID based refactoring is disabled.
 	PseudoGTIDPatternIsFixedSubstring            bool              // If true, then PseudoGTIDPattern is not treated as regular expression but as fixed substring, and can boost search time
 	PseudoGTIDMonotonicHint                      string            // subtring in Pseudo-GTID entry which indicates Pseudo-GTID entries are expected to be monotonically increasing
 	DetectPseudoGTIDQuery                        string            // Optional query which is used to authoritatively decide whether pseudo gtid is enabled on instance
+	AutoPseudoGTID                               bool              // When true, the elected orchestrator node injects Pseudo-GTID entries into all writeable cluster masters. PseudoGTIDPattern, PseudoGTIDPatternIsFixedSubstring and PseudoGTIDMonotonicHint are then implicitly set to match injected entries
+	AutoPseudoGTIDIntervalSeconds                uint              // Interval between Pseudo-GTID injections (when AutoPseudoGTID is true)
+	AutoPseudoGTIDVerificationSeconds            uint              // Number of seconds after which an injected Pseudo-GTID entry is expected to have been executed by all (non downtimed) slaves of the master
+	PseudoGTIDSchema                             string            // Schema name used in injected Pseudo-GTID entries (`drop view if exists` statements). The schema does not need to exist
 	PseudoGTIDCoordinatesHistoryHeuristicMinutes int               // Significantly reducing Pseudo-GTID lookup time, this indicates the most recent N minutes binlog position where search for Pseudo-GTID will heuristically begin (there is a fallback on fullscan if unsuccessful)
 	BinlogEventsChunkSize                        int               // Chunk size (X) for SHOW BINLOG|RELAYLOG EVENTS LIMIT ?,X statements. Smaller means less locking and mroe work to be done
 	BufferBinlogEvents                           bool              // Should we used buffered read on SHOW BINLOG|RELAYLOG EVENTS -- releases the database lock sooner (recommended)
+	StreamBinlogEvents                           bool              // If true, binary logs are scanned by connecting as a replication client (COM_BINLOG_DUMP), which is faster and holds no locks on the master, rather than via SHOW BINLOG EVENTS. Requires REPLICATION SLAVE privilege. Relay logs are always read via SHOW RELAYLOG EVENTS
 	SkipBinlogEventsContaining                   []string          // When scanning/comparing binlogs for Pseudo-GTID, skip entries containing given texts. These are NOT regular expressions (would consume too much CPU while scanning binlogs), just substrings to find.
 	ReduceReplicationAnalysisCount               bool              // When true, replication analysis will only report instances where possibility of handled problems is possible in the first place (e.g. will not report most leaf nodes, that are mostly uninteresting). When false, provides an entry for every known instance
+	CustomAnalysisRules                          []AnalysisRule    // User defined detections, reported along with replication analysis and in problems. These never lead to recovery
 	FailureDetectionPeriodBlockMinutes           int               // The time for which an instance's failure discovery is kept "active", so as to avoid concurrent "discoveries" of the instance's failure; this preceeds any recovery process, if any.
 	RecoveryPollSeconds                          int               // Interval between checks for a recovery scenario and initiation of a recovery process
 	RecoveryPeriodBlockMinutes                   int               // (supported for backwards compatibility but please use newer `RecoveryPeriodBlockSeconds` instead) The time for which an instance's recovery is kept "active", so as to avoid concurrent recoveries on smae instance as well as flapping
@@ -152,6 +206,9 @@ type Configuration struct {
 	RecoveryIgnoreHostnameFilters                []string          // Recovery analysis will completely ignore hosts matching given patterns
 	RecoverMasterClusterFilters                  []string          // Only do master recovery on clusters matching these regexp patterns (of course the ".*" pattern matches everything)
 	RecoverIntermediateMasterClusterFilters      []string          // Only do IM recovery on clusters matching these regexp patterns (of course the ".*" pattern matches everything)
+	DeadMasterConfirmationSeconds                int               // When > 0, a DeadMaster is confirmed from the slaves' point of view before it is recovered: each slave is sampled twice, this many seconds apart, and votes on whether the master is gone. 0 disables confirmation
+	DeadMasterConfirmationQuorum                 float64           // Fraction (0, 1] of readable slaves which must agree the master is dead for DeadMaster recovery to proceed. Only applies when DeadMasterConfirmationSeconds > 0
+	MasterFailoverNodesQuorum                    float64           // When > 0, master recovery only proceeds if at least this fraction (0, 1] of healthy orchestrator nodes consider the master unreachable. Non-elected nodes probe failed masters and publish their opinion. 0 disables
 	OnFailureDetectionProcesses                  []string          // Processes to execute when detecting a failover scenario (before making a decision whether to failover or not). May and should use some of these placeholders: {failureType}, {failureDescription}, {failedHost}, {failureCluster}, {failureClusterAlias}, {failureClusterDomain}, {failedPort}, {successorHost}, {successorPort}, {successorAlias}, {countSlaves}, {slaveHosts}, {isDowntimed}, {autoMasterRecovery}, {autoIntermediateMasterRecovery}
 	PreFailoverProcesses                         []string          // Processes to execute before doing a failover (aborting operation should any once of them exits with non-zero code; order of execution undefined). May and should use some of these placeholders: {failureType}, {failureDescription}, {failedHost}, {failureCluster}, {failureClusterAlias}, {failureClusterDomain}, {failedPort}, {successorHost}, {successorPort}, {successorAlias}, {countSlaves}, {slaveHosts}, {isDowntimed}
 	PostFailoverProcesses                        []string          // Processes to execute after doing a failover (order of execution undefined). May and should use some of these placeholders: {failureType}, {failureDescription}, {failedHost}, {failureCluster}, {failureClusterAlias}, {failureClusterDomain}, {failedPort}, {successorHost}, {successorPort}, {successorAlias}, {countSlaves}, {slaveHosts}, {isDowntimed}, {isSuccessful}, {lostSlaves}
@@ -163,7 +220,23 @@ type Configuration struct {
 	ApplyMySQLPromotionAfterMasterFailover       bool              // Should orchestrator take upon itself to apply MySQL master promotion: set read_only=0, detach replication, etc.
 	MasterFailoverLostInstancesDowntimeMinutes   uint              // Number of minutes to downtime any server that was lost after a master failover (including failed master & lost slaves). 0 to disable
 	MasterFailoverDetachSlaveMasterHost          bool              // Should orchestrator issue a detach-slave-master-host on newly promoted master (this makes sure the new master will not attempt to replicate old master if that comes back to life). Defaults 'false'. Meaningless if ApplyMySQLPromotionAfterMasterFailover is 'true'.
+	PreemptClusterLockOnRecovery                 bool              // When 'true', a recovery preempts (forcibly releases) a cluster lock held by another operation on the failed cluster. When 'false', recovery is not attempted while the cluster is locked
 	PostponeSlaveRecoveryOnLagMinutes            uint              // On crash recovery, slaves that are lagging more than given minutes are only resurrected late in the recovery process, after master/IM has been elected and processes executed. Value of 0 disables this feature
+	ConsulAddress                                string            // Address of a Consul agent (e.g. "127.0.0.1:8500"). When set, cluster masters and replicas are published to Consul's KV store
+	ConsulScheme                                 string            // Scheme used to access Consul: "http" or "https"
+	ConsulAclToken                               string            // Optional ACL token sent to Consul on KV writes
+	EtcdAddress                                  string            // Address of an etcd member (e.g. "127.0.0.1:2379"). When set, cluster masters and replicas are published to etcd via its v2 keys API
+	EtcdScheme                                   string            // Scheme used to access etcd: "http" or "https"
+	KVClusterMasterPrefix                        string            // Prefix of KV keys holding cluster masters; key is <prefix>/<cluster alias>, value is host:port
+	KVClusterReplicaPrefix                       string            // Prefix of KV keys holding cluster replicas; key is <prefix>/<cluster alias>/<host:port>, value is host:port
+	DNSUpdateServer                              string            // Address (host:port) of the primary DNS server to which dynamic updates (RFC 2136) are sent upon master failover. When empty, DNS is not updated
+	DNSUpdateZone                                string            // Zone in which the cluster domain record is updated. When empty, deduced as the cluster domain's parent domain
+	DNSUpdateRecordType                          string            // Type of cluster domain record pointing at the master: "CNAME" (to master's hostname) or "A" (to master's IPv4 address)
+	DNSUpdateTTL                                 uint              // TTL of the updated cluster domain record
+	DNSUpdateTSIGKeyName                         string            // Name of TSIG key by which updates are signed. When empty, updates are not signed
+	DNSUpdateTSIGAlgorithm                       string            // TSIG algorithm: hmac-sha256 (default), hmac-sha512, hmac-sha1 or hmac-md5
+	DNSUpdateTSIGSecret                          string            // Base64 encoded TSIG secret
+	DNSUpdateVerifySeconds                       uint              // Number of seconds for which an updated record is looked up on DNSUpdateServer until it resolves to the promoted master
 	OSCIgnoreHostnameFilters                     []string          // OSC slaves recommendation will ignore slave hostnames matching given patterns
 	GraphiteAddr                                 string            // Optional; address of graphite port. If supplied, metrics will be written here
 	GraphitePath                                 string            // Prefix for graphite path. May include {hostname} magic placeholder
@@ -208,6 +281,7 @@ func newConfiguration() *Configuration {
 		DefaultInstancePort:                          3306,
 		InstancePollSeconds:                          5,
 		ReadLongRunningQueries:                       true,
+		LongRunningQueryKillPolicies:                 []QueryKillPolicy{},
 		BinlogFileHistoryDays:                        0,
 		UnseenInstanceForgetHours:                    240,
 		SnapshotTopologiesIntervalHours:              0,
@@ -228,6 +302,7 @@ func newConfiguration() *Configuration {
 		ReasonableMaintenanceReplicationLagSeconds:   20,
 		MaintenanceExpireMinutes:                     10,
 		MaintenancePurgeDays:                         365,
+		ClusterLockExpireMinutes:                     10,
 		CandidateInstanceExpireMinutes:               60,
 		AuditLogFile:                                 "",
 		AuditToSyslog:                                false,
@@ -240,6 +315,7 @@ func newConfiguration() *Configuration {
 		HTTPAuthPassword:                             "",
 		AuthUserHeader:                               "X-Forwarded-User",
 		PowerAuthUsers:                               []string{"*"},
+		AuthRoles:                                    RoleUsers{},
 		AccessTokenUseExpirySeconds:                  60,
 		AccessTokenExpiryMinutes:                     1440,
 		ClusterNameToAlias:                           make(map[string]string),
@@ -259,6 +335,10 @@ func newConfiguration() *Configuration {
 		AgentsUseSSL:                                 false,
 		AgentsUseMutualTLS:                           false,
 		AgentSSLValidOUs:                             []string{},
+		AgentRegistrationSecret:                      "",
+		AgentRegistrationMaxSkewSeconds:              300,
+		AgentRegistrationVerifyHostname:              false,
+		AgentRegistrationPinCertificate:              false,
 		AgentSSLSkipVerify:                           false,
 		AgentSSLPrivateKeyFile:                       "",
 		AgentSSLCertFile:                             "",
@@ -275,16 +355,28 @@ func newConfiguration() *Configuration {
 		AgentAutoDiscover:                            false,
 		UnseenAgentForgetHours:                       6,
 		StaleSeedFailMinutes:                         60,
+		AgentDatadirDiskUsedPercentThreshold:         90,
+		AgentDatadirDiskFreeThresholdBytes:           0,
+		AgentErrorLogCrashPatterns:                   []string{`mysqld got signal`, `Assertion failure`, `InnoDB: Database page corruption`, `mysqld_safe mysqld restarted`, `Out of memory`},
+		AgentCustomCommands:                          []CommandRule{},
+		AgentCustomCommandAuditOutputBytes:           4096,
 		SeedAcceptableBytesDiff:                      8192,
+		SeedSetupReplication:                         false,
 		PseudoGTIDPattern:                            "",
 		PseudoGTIDPatternIsFixedSubstring:            false,
 		PseudoGTIDMonotonicHint:                      "",
 		DetectPseudoGTIDQuery:                        "",
+		AutoPseudoGTID:                               false,
+		AutoPseudoGTIDIntervalSeconds:                5,
+		AutoPseudoGTIDVerificationSeconds:            60,
+		PseudoGTIDSchema:                             "_pseudo_gtid_",
 		PseudoGTIDCoordinatesHistoryHeuristicMinutes: 2,
 		BinlogEventsChunkSize:                        10000,
 		BufferBinlogEvents:                           true,
+		StreamBinlogEvents:                           false,
 		SkipBinlogEventsContaining:                   []string{},
 		ReduceReplicationAnalysisCount:               true,
+		CustomAnalysisRules:                          []AnalysisRule{},
 		FailureDetectionPeriodBlockMinutes:           60,
 		RecoveryPollSeconds:                          10,
 		RecoveryPeriodBlockMinutes:                   60,
@@ -292,6 +384,9 @@ func newConfiguration() *Configuration {
 		RecoveryIgnoreHostnameFilters:                []string{},
 		RecoverMasterClusterFilters:                  []string{},
 		RecoverIntermediateMasterClusterFilters:      []string{},
+		DeadMasterConfirmationSeconds:                0,
+		DeadMasterConfirmationQuorum:                 1,
+		MasterFailoverNodesQuorum:                    0,
 		OnFailureDetectionProcesses:                  []string{},
 		PreFailoverProcesses:                         []string{},
 		PostMasterFailoverProcesses:                  []string{},
@@ -303,7 +398,23 @@ func newConfiguration() *Configuration {
 		ApplyMySQLPromotionAfterMasterFailover:       false,
 		MasterFailoverLostInstancesDowntimeMinutes:   0,
 		MasterFailoverDetachSlaveMasterHost:          false,
+		PreemptClusterLockOnRecovery:                 false,
 		PostponeSlaveRecoveryOnLagMinutes:            0,
+		ConsulAddress:                                "",
+		ConsulScheme:                                 "http",
+		ConsulAclToken:                               "",
+		EtcdAddress:                                  "",
+		EtcdScheme:                                   "http",
+		KVClusterMasterPrefix:                        "mysql/master",
+		KVClusterReplicaPrefix:                       "mysql/replica",
+		DNSUpdateServer:                              "",
+		DNSUpdateZone:                                "",
+		DNSUpdateRecordType:                          "CNAME",
+		DNSUpdateTTL:                                 60,
+		DNSUpdateTSIGKeyName:                         "",
+		DNSUpdateTSIGAlgorithm:                       "hmac-sha256",
+		DNSUpdateTSIGSecret:                          "",
+		DNSUpdateVerifySeconds:                       10,
 		OSCIgnoreHostnameFilters:                     []string{},
 		GraphiteAddr:                                 "",
 		GraphitePath:                                 "",
@@ -312,61 +423,85 @@ func newConfiguration() *Configuration {
 	}
 }
 
-func postReadAdjustments() {
-	if Config.MySQLOrchestratorCredentialsConfigFile != "" {
+func (this *Configuration) postReadAdjustments() {
+	if this.MySQLOrchestratorCredentialsConfigFile != "" {
 		mySQLConfig := struct {
 			Client struct {
 				User     string
 				Password string
 			}
 		}{}
-		err := gcfg.ReadFileInto(&mySQLConfig, Config.MySQLOrchestratorCredentialsConfigFile)
+		err := gcfg.ReadFileInto(&mySQLConfig, this.MySQLOrchestratorCredentialsConfigFile)
 		if err != nil {
 			log.Fatalf("Failed to parse gcfg data from file: %+v", err)
 		} else {
-			log.Debugf("Parsed orchestrator credentials from %s", Config.MySQLOrchestratorCredentialsConfigFile)
-			Config.MySQLOrchestratorUser = mySQLConfig.Client.User
-			Config.MySQLOrchestratorPassword = mySQLConfig.Client.Password
+			log.Debugf("Parsed orchestrator credentials from %s", this.MySQLOrchestratorCredentialsConfigFile)
+			this.MySQLOrchestratorUser = mySQLConfig.Client.User
+			this.MySQLOrchestratorPassword = mySQLConfig.Client.Password
 		}
 	}
 	{
 		// We accept password in the form "${SOME_ENV_VARIABLE}" in which case we pull
 		// the given variable from os env
-		submatch := envVariableRegexp.FindStringSubmatch(Config.MySQLOrchestratorPassword)
+		submatch := envVariableRegexp.FindStringSubmatch(this.MySQLOrchestratorPassword)
 		if len(submatch) > 1 {
-			Config.MySQLOrchestratorPassword = os.Getenv(submatch[1])
+			this.MySQLOrchestratorPassword = os.Getenv(submatch[1])
 		}
 	}
-	if Config.MySQLTopologyCredentialsConfigFile != "" {
+	if this.MySQLTopologyCredentialsConfigFile != "" {
 		mySQLConfig := struct {
 			Client struct {
 				User     string
 				Password string
 			}
 		}{}
-		err := gcfg.ReadFileInto(&mySQLConfig, Config.MySQLTopologyCredentialsConfigFile)
+		err := gcfg.ReadFileInto(&mySQLConfig, this.MySQLTopologyCredentialsConfigFile)
 		if err != nil {
 			log.Fatalf("Failed to parse gcfg data from file: %+v", err)
 		} else {
-			log.Debugf("Parsed topology credentials from %s", Config.MySQLTopologyCredentialsConfigFile)
-			Config.MySQLTopologyUser = mySQLConfig.Client.User
-			Config.MySQLTopologyPassword = mySQLConfig.Client.Password
+			log.Debugf("Parsed topology credentials from %s", this.MySQLTopologyCredentialsConfigFile)
+			this.MySQLTopologyUser = mySQLConfig.Client.User
+			this.MySQLTopologyPassword = mySQLConfig.Client.Password
 		}
 	}
 	{
 		// We accept password in the form "${SOME_ENV_VARIABLE}" in which case we pull
 		// the given variable from os env
-		submatch := envVariableRegexp.FindStringSubmatch(Config.MySQLTopologyPassword)
+		submatch := envVariableRegexp.FindStringSubmatch(this.MySQLTopologyPassword)
 		if len(submatch) > 1 {
-			Config.MySQLTopologyPassword = os.Getenv(submatch[1])
+			this.MySQLTopologyPassword = os.Getenv(submatch[1])
 		}
 	}
 
-	if Config.RecoveryPeriodBlockSeconds == 0 && Config.RecoveryPeriodBlockMinutes > 0 {
+	{
+		submatch := envVariableRegexp.FindStringSubmatch(this.AgentRegistrationSecret)
+		if len(submatch) > 1 {
+			this.AgentRegistrationSecret = os.Getenv(submatch[1])
+		}
+	}
+
+	if this.RecoveryPeriodBlockSeconds == 0 && this.RecoveryPeriodBlockMinutes > 0 {
 		// RecoveryPeriodBlockSeconds is a newer addition that overrides RecoveryPeriodBlockMinutes
 		// The code does not consider RecoveryPeriodBlockMinutes anymore, but RecoveryPeriodBlockMinutes
 		// still supported in config file for backwards compatibility
-		Config.RecoveryPeriodBlockSeconds = Config.RecoveryPeriodBlockMinutes * 60
+		this.RecoveryPeriodBlockSeconds = this.RecoveryPeriodBlockMinutes * 60
+	}
+	if this.DeadMasterConfirmationQuorum <= 0 || this.DeadMasterConfirmationQuorum > 1 {
+		// A quorum is a fraction of voting slaves; anything outside (0, 1] falls back to requiring all votes
+		this.DeadMasterConfirmationQuorum = 1
+	}
+	if this.MasterFailoverNodesQuorum > 1 {
+		this.MasterFailoverNodesQuorum = 1
+	}
+	if this.AutoPseudoGTID {
+		// Injected entries are of the form:
+		//   drop view if exists `_pseudo_gtid_`.`_asc:<hex timestamp>:<hex counter>:<token>`
+		this.PseudoGTIDPattern = fmt.Sprintf("`%s`.`_asc:", this.PseudoGTIDSchema)
+		this.PseudoGTIDPatternIsFixedSubstring = true
+		this.PseudoGTIDMonotonicHint = "asc:"
+		if this.AutoPseudoGTIDIntervalSeconds == 0 {
+			this.AutoPseudoGTIDIntervalSeconds = 5
+		}
 	}
 }
 
@@ -382,7 +517,7 @@ func read(fileName string) (*Configuration, error) {
 		} else {
 			log.Fatal("Cannot read config file:", fileName, err)
 		}
-		postReadAdjustments()
+		Config.postReadAdjustments()
 	}
 	return Config, err
 }
@@ -394,6 +529,7 @@ func Read(fileNames ...string) *Configuration {
 		read(fileName)
 	}
 	readFileNames = fileNames
+	notifyChange(DiffConfigurations(newConfiguration(), Config))
 	return Config
 }
 
@@ -404,13 +540,16 @@ func ForceRead(fileName string) *Configuration {
 		log.Fatal("Cannot read config file:", fileName, err)
 	}
 	readFileNames = []string{fileName}
+	notifyChange(DiffConfigurations(newConfiguration(), Config))
 	return Config
 }
 
-// Reload re-reads configuration from last used files
-func Reload() *Configuration {
+// ReadFileNames returns the names of existing configuration files, in order of reading
+func ReadFileNames() (fileNames []string) {
 	for _, fileName := range readFileNames {
-		read(fileName)
+		if _, err := os.Stat(fileName); err == nil {
+			fileNames = append(fileNames, fileName)
+		}
 	}
-	return Config
+	return fileNames
 }
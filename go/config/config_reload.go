@@ -0,0 +1,204 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/outbrain/golib/log"
+)
+
+const redactedValue = "********"
+
+// nonReloadableFields are only applied on startup: listeners, backend connections, TLS setup, authentication and
+// timers set up once. Reloading a changed value of any of these is refused.
+var nonReloadableFields = []string{
+	"ListenAddress", "ListenSocket", "AgentsServerPort", "ServeAgentsHttp",
+	"MySQLOrchestratorHost", "MySQLOrchestratorPort", "MySQLOrchestratorDatabase", "MySQLOrchestratorUser",
+	"MySQLOrchestratorPassword", "MySQLOrchestratorCredentialsConfigFile", "MySQLOrchestratorSSLPrivateKeyFile",
+	"MySQLOrchestratorSSLCertFile", "MySQLOrchestratorSSLCAFile", "MySQLOrchestratorSSLSkipVerify",
+	"MySQLOrchestratorUseMutualTLS", "DatabaselessMode__experimental",
+	"UseSSL", "UseMutualTLS", "SSLSkipVerify", "SSLPrivateKeyFile", "SSLCertFile", "SSLCAFile", "SSLValidOUs",
+	"AgentsUseSSL", "AgentsUseMutualTLS", "AgentSSLSkipVerify", "AgentSSLPrivateKeyFile", "AgentSSLCertFile", "AgentSSLCAFile", "AgentSSLValidOUs",
+	"AuthenticationMethod", "HTTPAuthUser", "HTTPAuthPassword", "StatusEndpoint",
+	"Debug", "EnableSyslog", "AuditToSyslog",
+	"SnapshotTopologiesIntervalHours", "AutoPseudoGTID", "AutoPseudoGTIDIntervalSeconds",
+	"GraphiteAddr", "GraphitePath", "GraphiteConvertHostnameDotsToUnderscores", "GraphitePollSeconds",
+}
+
+var reloadMutex sync.Mutex
+var changeListenersMutex sync.Mutex
+var changeListeners [](func(changes ConfigurationChanges))
+
+// ConfigurationChange describes a single field whose value has changed. Values of secret fields are redacted.
+type ConfigurationChange struct {
+	Field    string
+	OldValue interface{}
+	NewValue interface{}
+}
+
+func (this *ConfigurationChange) String() string {
+	return fmt.Sprintf("%s: %+v -> %+v", this.Field, this.OldValue, this.NewValue)
+}
+
+// ConfigurationChanges is a list of changes, ordered by field declaration
+type ConfigurationChanges []ConfigurationChange
+
+// HasField returns true when any of given fields has changed
+func (this ConfigurationChanges) HasField(fieldNames ...string) bool {
+	for _, change := range this {
+		for _, fieldName := range fieldNames {
+			if change.Field == fieldName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FieldNames returns the names of changed fields
+func (this ConfigurationChanges) FieldNames() (fieldNames []string) {
+	for _, change := range this {
+		fieldNames = append(fieldNames, change.Field)
+	}
+	return fieldNames
+}
+
+func (this ConfigurationChanges) String() string {
+	descriptions := []string{}
+	for _, change := range this {
+		descriptions = append(descriptions, change.String())
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// isSecretField returns true for fields holding passwords, secrets and tokens
+func isSecretField(fieldName string) bool {
+	return strings.Contains(fieldName, "Password") || strings.Contains(fieldName, "Secret") || strings.Contains(fieldName, "Token")
+}
+
+// redactedFieldValue returns the value of given field, masking non empty secrets
+func redactedFieldValue(fieldName string, value reflect.Value) interface{} {
+	if isSecretField(fieldName) && value.Kind() == reflect.String && value.String() != "" {
+		return redactedValue
+	}
+	return value.Interface()
+}
+
+// DiffConfigurations returns the fields whose values differ between given configurations
+func DiffConfigurations(from *Configuration, to *Configuration) (changes ConfigurationChanges) {
+	fromValue := reflect.ValueOf(from).Elem()
+	toValue := reflect.ValueOf(to).Elem()
+	configurationType := fromValue.Type()
+	for i := 0; i < configurationType.NumField(); i++ {
+		fieldName := configurationType.Field(i).Name
+		if reflect.DeepEqual(fromValue.Field(i).Interface(), toValue.Field(i).Interface()) {
+			continue
+		}
+		changes = append(changes, ConfigurationChange{
+			Field:    fieldName,
+			OldValue: redactedFieldValue(fieldName, fromValue.Field(i)),
+			NewValue: redactedFieldValue(fieldName, toValue.Field(i)),
+		})
+	}
+	return changes
+}
+
+// RedactedConfiguration returns a copy of the effective configuration, where passwords, secrets and tokens are masked
+func RedactedConfiguration() *Configuration {
+	redacted := *Config
+	fields := reflect.ValueOf(&redacted).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		fieldName := fields.Type().Field(i).Name
+		if masked, ok := redactedFieldValue(fieldName, fields.Field(i)).(string); ok {
+			fields.Field(i).SetString(masked)
+		}
+	}
+	return &redacted
+}
+
+// OnChange registers a function to be called with the changed fields whenever configuration is read or reloaded.
+// Subsystems which derive state from configuration (caches, timers, clients) use this to rebuild such state.
+func OnChange(f func(changes ConfigurationChanges)) {
+	changeListenersMutex.Lock()
+	defer changeListenersMutex.Unlock()
+
+	changeListeners = append(changeListeners, f)
+}
+
+// notifyChange calls upon registered listeners, given there are any changes
+func notifyChange(changes ConfigurationChanges) {
+	if len(changes) == 0 {
+		return
+	}
+	changeListenersMutex.Lock()
+	listeners := changeListeners
+	changeListenersMutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(changes)
+	}
+}
+
+// Reload re-reads configuration from last used files. The files are first validated, and the reloaded configuration
+// is compared to the effective one: nothing is applied upon blocking validation errors (any error, with
+// --strict-config) or when a non reloadable field has changed. Otherwise the new configuration is applied, and
+// subsystems are notified of the changes.
+func Reload() (changes ConfigurationChanges, err error) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	fileNames := ReadFileNames()
+	if len(fileNames) == 0 {
+		return changes, fmt.Errorf("No configuration files to reload from")
+	}
+	validation := ValidateFiles(fileNames...)
+	for _, warning := range validation.Warnings {
+		log.Warning(warning)
+	}
+	strict := RuntimeCLIFlags.StrictConfig != nil && *RuntimeCLIFlags.StrictConfig
+	if blockingErrors := validation.BlockingErrors(strict); len(blockingErrors) > 0 {
+		return changes, fmt.Errorf("Configuration not reloaded: %d errors in %s: %s", len(blockingErrors), strings.Join(fileNames, ", "), strings.Join(blockingErrors, "; "))
+	}
+	for _, validationError := range validation.Errors {
+		log.Warningf("Reloading configuration despite: %s", validationError)
+	}
+	reloaded := validation.Configuration
+	reloaded.postReadAdjustments()
+	if RuntimeCLIFlags.Databaseless != nil && *RuntimeCLIFlags.Databaseless {
+		reloaded.DatabaselessMode__experimental = true
+	}
+
+	changes = DiffConfigurations(Config, reloaded)
+	restartFields := []string{}
+	for _, fieldName := range nonReloadableFields {
+		if changes.HasField(fieldName) {
+			restartFields = append(restartFields, fieldName)
+		}
+	}
+	if len(restartFields) > 0 {
+		return changes, fmt.Errorf("Configuration not reloaded: changes to %s require a restart", strings.Join(restartFields, ", "))
+	}
+
+	*Config = *reloaded
+	log.Infof("Reloaded configuration from %s: %d changes", strings.Join(fileNames, ", "), len(changes))
+	notifyChange(changes)
+	return changes, nil
+}
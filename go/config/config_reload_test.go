@@ -0,0 +1,128 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+// withConfigFile has the global configuration freshly read from given content, and restores it afterwards
+func withConfigFile(t *testing.T, content string, f func(fileName string)) {
+	originalConfig := Config
+	originalFileNames := readFileNames
+	defer func() {
+		Config = originalConfig
+		readFileNames = originalFileNames
+	}()
+
+	fileName := writeTempConfigFile(t, content)
+	defer os.Remove(fileName)
+	Config = newConfiguration()
+	Read(fileName)
+	f(fileName)
+}
+
+func TestDiffConfigurations(t *testing.T) {
+	from := newConfiguration()
+	to := newConfiguration()
+	test.S(t).ExpectEquals(len(DiffConfigurations(from, to)), 0)
+
+	to.InstancePollSeconds = from.InstancePollSeconds + 1
+	to.RecoverMasterClusterFilters = []string{"mycluster"}
+	to.MySQLTopologyPassword = "secret"
+	changes := DiffConfigurations(from, to)
+	test.S(t).ExpectEquals(len(changes), 3)
+	test.S(t).ExpectTrue(changes.HasField("InstancePollSeconds"))
+	test.S(t).ExpectTrue(changes.HasField("NoSuchField", "RecoverMasterClusterFilters"))
+	test.S(t).ExpectFalse(changes.HasField("RecoveryPollSeconds"))
+	for _, change := range changes {
+		if change.Field == "MySQLTopologyPassword" {
+			test.S(t).ExpectEquals(change.OldValue, "")
+			test.S(t).ExpectEquals(change.NewValue, redactedValue)
+		}
+	}
+}
+
+func TestRedactedConfiguration(t *testing.T) {
+	withConfigFile(t, `{"MySQLTopologyUser": "orchestrator", "MySQLTopologyPassword": "secret", "ConsulAclToken": "token"}`, func(fileName string) {
+		redacted := RedactedConfiguration()
+		test.S(t).ExpectEquals(redacted.MySQLTopologyUser, "orchestrator")
+		test.S(t).ExpectEquals(redacted.MySQLTopologyPassword, redactedValue)
+		test.S(t).ExpectEquals(redacted.ConsulAclToken, redactedValue)
+		test.S(t).ExpectEquals(redacted.HTTPAuthPassword, "")
+		test.S(t).ExpectEquals(Config.MySQLTopologyPassword, "secret")
+	})
+}
+
+func TestReload(t *testing.T) {
+	withConfigFile(t, `{"InstancePollSeconds": 5}`, func(fileName string) {
+		var notified ConfigurationChanges
+		OnChange(func(changes ConfigurationChanges) { notified = changes })
+
+		changes, err := Reload()
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(len(changes), 0)
+		test.S(t).ExpectEquals(len(notified), 0)
+
+		test.S(t).ExpectNil(ioutil.WriteFile(fileName, []byte(`{"InstancePollSeconds": 7, "RecoveryPollSeconds": 3}`), 0644))
+		changes, err = Reload()
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(len(changes), 2)
+		test.S(t).ExpectEquals(len(notified), 2)
+		test.S(t).ExpectEquals(Config.InstancePollSeconds, uint(7))
+		test.S(t).ExpectEquals(Config.RecoveryPollSeconds, 3)
+	})
+}
+
+func TestReloadRejected(t *testing.T) {
+	withConfigFile(t, `{"InstancePollSeconds": 5, "ListenAddress": ":3000"}`, func(fileName string) {
+		test.S(t).ExpectNil(ioutil.WriteFile(fileName, []byte(`{"InstancePollSeconds": 7, "ListenAddress": ":3001"}`), 0644))
+		changes, err := Reload()
+		test.S(t).ExpectNotNil(err)
+		test.S(t).ExpectTrue(changes.HasField("ListenAddress"))
+		test.S(t).ExpectEquals(Config.InstancePollSeconds, uint(5))
+
+		test.S(t).ExpectNil(ioutil.WriteFile(fileName, []byte(`{"InstancePollSeconds": 7, "ListenAddress": ":3000", "RecoverMasterClusterFilters": ["(broken"]}`), 0644))
+		_, err = Reload()
+		test.S(t).ExpectNotNil(err)
+		test.S(t).ExpectEquals(Config.InstancePollSeconds, uint(5))
+	})
+}
+
+func TestReloadIgnorableErrors(t *testing.T) {
+	withConfigFile(t, `{"InstancePollSeconds": 5, "ListenAddress": ":3000"}`, func(fileName string) {
+		strictConfig := RuntimeCLIFlags.StrictConfig
+		defer func() { RuntimeCLIFlags.StrictConfig = strictConfig }()
+		strict := true
+		RuntimeCLIFlags.StrictConfig = &strict
+
+		test.S(t).ExpectNil(ioutil.WriteFile(fileName, []byte(`{"InstancePollSeconds": 7, "ListenAddress": ":3000", "InstancePollSecond": 7}`), 0644))
+		_, err := Reload()
+		test.S(t).ExpectNotNil(err)
+		test.S(t).ExpectEquals(Config.InstancePollSeconds, uint(5))
+
+		strict = false
+		changes, err := Reload()
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(changes.HasField("InstancePollSeconds"))
+		test.S(t).ExpectEquals(Config.InstancePollSeconds, uint(7))
+	})
+}
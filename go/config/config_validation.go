@@ -0,0 +1,435 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/gcfg.v1"
+)
+
+// deprecatedFields are still honored, but have better alternatives
+var deprecatedFields = map[string]string{
+	"RecoveryPeriodBlockMinutes": "use RecoveryPeriodBlockSeconds instead",
+}
+
+// removedFields are no longer configurable, and are ignored when given
+var removedFields = map[string]string{
+	"DiscoveryPollSeconds": "discovery polls every second",
+}
+
+// recoveryProcessPlaceholders are replaced in recovery processes and custom analysis rules' processes
+var recoveryProcessPlaceholders = []string{
+	"failureType", "failureDescription", "failedHost", "failedPort", "failureCluster", "failureClusterAlias",
+	"failureClusterDomain", "countSlaves", "isDowntimed", "autoMasterRecovery", "autoIntermediateMasterRecovery",
+	"orchestratorHost", "isSuccessful", "successorHost", "successorPort", "successorAlias", "lostSlaves", "slaveHosts",
+}
+
+// placeholderRegexp matches {placeholder}, as well as shell's ${variable}, which is then skipped
+var placeholderRegexp = regexp.MustCompile(`[$]?{([a-zA-Z]+)}`)
+
+// ConfigurationValidation lists the problems found in configuration files.
+// Errors make for an invalid configuration; warnings do not.
+type ConfigurationValidation struct {
+	FileNames     []string
+	Errors        []string
+	Warnings      []string
+	Configuration *Configuration
+	givenFields   map[string]bool
+	// ignorableErrors indexes the Errors which do not prevent applying the configuration
+	ignorableErrors map[int]bool
+}
+
+func (this *ConfigurationValidation) AddError(format string, args ...interface{}) {
+	this.Errors = append(this.Errors, fmt.Sprintf(format, args...))
+}
+
+// AddIgnorableError adds an error which does not prevent applying the configuration, such as an unknown field,
+// which is merely ignored. See BlockingErrors
+func (this *ConfigurationValidation) AddIgnorableError(format string, args ...interface{}) {
+	if this.ignorableErrors == nil {
+		this.ignorableErrors = make(map[int]bool)
+	}
+	this.ignorableErrors[len(this.Errors)] = true
+	this.AddError(format, args...)
+}
+
+func (this *ConfigurationValidation) AddWarning(format string, args ...interface{}) {
+	this.Warnings = append(this.Warnings, fmt.Sprintf(format, args...))
+}
+
+// isGiven returns true when all given top level fields appear in any of the validated files, as opposed to
+// taking their default values
+func (this *ConfigurationValidation) isGiven(fieldNames ...string) bool {
+	for _, fieldName := range fieldNames {
+		if !this.givenFields[fieldName] {
+			return false
+		}
+	}
+	return true
+}
+
+// IsValid returns true when no errors were found
+func (this *ConfigurationValidation) IsValid() bool {
+	return len(this.Errors) == 0
+}
+
+// BlockingErrors returns the errors which prevent applying the configuration: on strict validation these are all
+// errors, otherwise ignorable errors are excluded
+func (this *ConfigurationValidation) BlockingErrors(strict bool) (blockingErrors []string) {
+	for i, validationError := range this.Errors {
+		if strict || !this.ignorableErrors[i] {
+			blockingErrors = append(blockingErrors, validationError)
+		}
+	}
+	return blockingErrors
+}
+
+// ValidateFiles validates given configuration files, as if read in order: each file's fields must be known and
+// well typed, and the resulting configuration must make sense. Files are not applied onto the running configuration.
+func ValidateFiles(fileNames ...string) *ConfigurationValidation {
+	validation := &ConfigurationValidation{FileNames: fileNames, Configuration: newConfiguration(), givenFields: make(map[string]bool)}
+	for _, fileName := range fileNames {
+		content, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			validation.AddError("Cannot read config file %s: %+v", fileName, err)
+			continue
+		}
+		rawFields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(content, &rawFields); err != nil {
+			validation.AddError("%s: invalid JSON: %+v", fileName, err)
+			continue
+		}
+		validateFields(fileName, "", rawFields, reflect.TypeOf(Configuration{}), validation)
+		if err := json.Unmarshal(content, validation.Configuration); err != nil {
+			validation.AddError("%s: %+v", fileName, err)
+		}
+	}
+	validation.Configuration.validate(validation)
+	return validation
+}
+
+// validateFields checks the given JSON object's fields against the given struct type: unknown fields are errors, fields
+// only matching case-insensitively (which encoding/json accepts) and deprecated fields are warnings. Arrays of
+// structs are validated recursively. Fields named with a leading "#" are comments, as used in the sample configuration
+// files, and are skipped.
+func validateFields(fileName string, path string, rawFields map[string]json.RawMessage, structType reflect.Type, validation *ConfigurationValidation) {
+	names := []string{}
+	for name := range rawFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fieldPath := path + name
+		if strings.HasPrefix(name, "#") {
+			continue
+		}
+		if reason, found := removedFields[name]; found && path == "" {
+			validation.AddWarning("%s: %s is no longer supported and is ignored: %s", fileName, fieldPath, reason)
+			continue
+		}
+		field, found := structType.FieldByName(name)
+		if !found {
+			for i := 0; i < structType.NumField(); i++ {
+				if strings.EqualFold(structType.Field(i).Name, name) {
+					field, found = structType.Field(i), true
+					validation.AddWarning("%s: %s should be spelled %s", fileName, fieldPath, path+field.Name)
+					break
+				}
+			}
+		}
+		if !found {
+			validation.AddIgnorableError("%s: unknown field %s", fileName, fieldPath)
+			continue
+		}
+		if path == "" {
+			validation.givenFields[field.Name] = true
+		}
+		if reason, found := deprecatedFields[field.Name]; found && path == "" {
+			validation.AddWarning("%s: %s is deprecated: %s", fileName, fieldPath, reason)
+		}
+		if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct {
+			elements := []map[string]json.RawMessage{}
+			if err := json.Unmarshal(rawFields[name], &elements); err != nil {
+				// Type errors are reported when decoding the configuration
+				continue
+			}
+			for i, element := range elements {
+				validateFields(fileName, fmt.Sprintf("%s[%d].", fieldPath, i), element, field.Type.Elem(), validation)
+			}
+		}
+	}
+}
+
+func validateRegexp(validation *ConfigurationValidation, fieldName string, pattern string) *regexp.Regexp {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		validation.AddError("%s: invalid regular expression %q: %+v", fieldName, pattern, err)
+	}
+	return compiled
+}
+
+func validateRegexps(validation *ConfigurationValidation, fieldName string, patterns []string) {
+	for _, pattern := range patterns {
+		validateRegexp(validation, fieldName, pattern)
+	}
+}
+
+// validateClusterFilters validates cluster filters, which are either "*", "alias=<alias>", "alias~=<regexp>" or a cluster name regexp
+func validateClusterFilters(validation *ConfigurationValidation, fieldName string, filters []string) {
+	for _, filter := range filters {
+		switch {
+		case filter == "*", strings.HasPrefix(filter, "alias="):
+		case strings.HasPrefix(filter, "alias~="):
+			validateRegexp(validation, fieldName, strings.SplitN(filter, "~=", 2)[1])
+		default:
+			validateRegexp(validation, fieldName, filter)
+		}
+	}
+}
+
+func validatePlaceholders(validation *ConfigurationValidation, fieldName string, commands []string, placeholders []string) {
+	known := make(map[string]bool)
+	for _, placeholder := range placeholders {
+		known[placeholder] = true
+	}
+	for _, command := range commands {
+		for _, submatch := range placeholderRegexp.FindAllStringSubmatch(command, -1) {
+			if strings.HasPrefix(submatch[0], "$") {
+				continue
+			}
+			if !known[submatch[1]] {
+				validation.AddIgnorableError("%s: unknown placeholder {%s} in %q", fieldName, submatch[1], command)
+			}
+		}
+	}
+}
+
+func validateReadableFile(validation *ConfigurationValidation, fieldName string, fileName string) bool {
+	if fileName == "" {
+		return false
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		validation.AddError("%s: cannot read %s: %+v", fieldName, fileName, err)
+		return false
+	}
+	file.Close()
+	return true
+}
+
+func validateCredentialsFile(validation *ConfigurationValidation, fieldName string, fileName string) {
+	if !validateReadableFile(validation, fieldName, fileName) {
+		return
+	}
+	mySQLConfig := struct {
+		Client struct {
+			User     string
+			Password string
+		}
+	}{}
+	if err := gcfg.ReadFileInto(&mySQLConfig, fileName); err != nil {
+		validation.AddError("%s: cannot parse %s: %+v", fieldName, fileName, err)
+	}
+}
+
+func validateOneOf(validation *ConfigurationValidation, fieldName string, value string, allowed ...string) {
+	for _, allowedValue := range allowed {
+		if strings.EqualFold(value, allowedValue) {
+			return
+		}
+	}
+	validation.AddError("%s: unsupported value %q. Expected one of: %s", fieldName, value, strings.Join(allowed, ", "))
+}
+
+func validatePort(validation *ConfigurationValidation, fieldName string, port int64) {
+	if port <= 0 || port > 65535 {
+		validation.AddError("%s: %d is not a valid port", fieldName, port)
+	}
+}
+
+func validatePositive(validation *ConfigurationValidation, fieldName string, value int64) {
+	if value <= 0 {
+		validation.AddError("%s: must be positive; got %d", fieldName, value)
+	}
+}
+
+func validateNonNegative(validation *ConfigurationValidation, fieldName string, value int64) {
+	if value < 0 {
+		validation.AddError("%s: must not be negative; got %d", fieldName, value)
+	}
+}
+
+// validate checks this configuration for invalid regular expressions and placeholders, out of range values,
+// unreadable files and contradicting settings
+func (this *Configuration) validate(validation *ConfigurationValidation) {
+	// Regular expressions
+	validateRegexp(validation, "RejectHostnameResolvePattern", this.RejectHostnameResolvePattern)
+	for fieldName, pattern := range map[string]string{"DataCenterPattern": this.DataCenterPattern, "PhysicalEnvironmentPattern": this.PhysicalEnvironmentPattern} {
+		if compiled := validateRegexp(validation, fieldName, pattern); compiled != nil && pattern != "" && compiled.NumSubexp() == 0 {
+			validation.AddError("%s: %q must have a capturing group", fieldName, pattern)
+		}
+	}
+	if !this.PseudoGTIDPatternIsFixedSubstring && !this.AutoPseudoGTID {
+		validateRegexp(validation, "PseudoGTIDPattern", this.PseudoGTIDPattern)
+	}
+	validateRegexps(validation, "ProblemIgnoreHostnameFilters", this.ProblemIgnoreHostnameFilters)
+	validateRegexps(validation, "PromotionIgnoreHostnameFilters", this.PromotionIgnoreHostnameFilters)
+	validateRegexps(validation, "RecoveryIgnoreHostnameFilters", this.RecoveryIgnoreHostnameFilters)
+	validateRegexps(validation, "OSCIgnoreHostnameFilters", this.OSCIgnoreHostnameFilters)
+	validateRegexps(validation, "AgentErrorLogCrashPatterns", this.AgentErrorLogCrashPatterns)
+	validateClusterFilters(validation, "RecoverMasterClusterFilters", this.RecoverMasterClusterFilters)
+	validateClusterFilters(validation, "RecoverIntermediateMasterClusterFilters", this.RecoverIntermediateMasterClusterFilters)
+	for i, commandRule := range this.AgentCustomCommands {
+		fieldName := fmt.Sprintf("AgentCustomCommands[%d]", i)
+		if commandRule.Command == "" {
+			validation.AddError("%s: Command must not be empty", fieldName)
+		}
+		validateRegexps(validation, fieldName+".ArgumentPatterns", commandRule.ArgumentPatterns)
+	}
+	for i, policy := range this.LongRunningQueryKillPolicies {
+		fieldName := fmt.Sprintf("LongRunningQueryKillPolicies[%d]", i)
+		validateRegexp(validation, fieldName+".ClusterPattern", policy.ClusterPattern)
+		validateRegexp(validation, fieldName+".UserPattern", policy.UserPattern)
+		validateRegexp(validation, fieldName+".CommandPattern", policy.CommandPattern)
+		validatePositive(validation, fieldName+".MaxTimeSeconds", policy.MaxTimeSeconds)
+	}
+
+	// Placeholders
+	validatePlaceholders(validation, "OnFailureDetectionProcesses", this.OnFailureDetectionProcesses, recoveryProcessPlaceholders)
+	validatePlaceholders(validation, "PreFailoverProcesses", this.PreFailoverProcesses, recoveryProcessPlaceholders)
+	validatePlaceholders(validation, "PostFailoverProcesses", this.PostFailoverProcesses, recoveryProcessPlaceholders)
+	validatePlaceholders(validation, "PostUnsuccessfulFailoverProcesses", this.PostUnsuccessfulFailoverProcesses, recoveryProcessPlaceholders)
+	validatePlaceholders(validation, "PostMasterFailoverProcesses", this.PostMasterFailoverProcesses, recoveryProcessPlaceholders)
+	validatePlaceholders(validation, "PostIntermediateMasterFailoverProcesses", this.PostIntermediateMasterFailoverProcesses, recoveryProcessPlaceholders)
+	for i, rule := range this.CustomAnalysisRules {
+		validatePlaceholders(validation, fmt.Sprintf("CustomAnalysisRules[%d].OnDetectionProcesses", i), rule.OnDetectionProcesses, recoveryProcessPlaceholders)
+	}
+	validatePlaceholders(validation, "GraphitePath", []string{this.GraphitePath}, []string{"hostname"})
+
+	// Numeric ranges
+	validatePort(validation, "MySQLOrchestratorPort", int64(this.MySQLOrchestratorPort))
+	validatePort(validation, "DefaultInstancePort", int64(this.DefaultInstancePort))
+	validatePositive(validation, "InstancePollSeconds", int64(this.InstancePollSeconds))
+	validatePositive(validation, "RecoveryPollSeconds", int64(this.RecoveryPollSeconds))
+	validatePositive(validation, "HttpTimeoutSeconds", int64(this.HttpTimeoutSeconds))
+	validatePositive(validation, "MySQLConnectTimeoutSeconds", int64(this.MySQLConnectTimeoutSeconds))
+	validatePositive(validation, "MySQLTopologyMaxPoolConnections", int64(this.MySQLTopologyMaxPoolConnections))
+	validatePositive(validation, "AuditPageSize", int64(this.AuditPageSize))
+	validatePositive(validation, "BinlogEventsChunkSize", int64(this.BinlogEventsChunkSize))
+	validateNonNegative(validation, "RecoveryPeriodBlockSeconds", int64(this.RecoveryPeriodBlockSeconds))
+	validateNonNegative(validation, "FailureDetectionPeriodBlockMinutes", int64(this.FailureDetectionPeriodBlockMinutes))
+	validateNonNegative(validation, "DeadMasterConfirmationSeconds", int64(this.DeadMasterConfirmationSeconds))
+	validateNonNegative(validation, "GraphitePollSeconds", int64(this.GraphitePollSeconds))
+	if this.DeadMasterConfirmationQuorum <= 0 || this.DeadMasterConfirmationQuorum > 1 {
+		validation.AddError("DeadMasterConfirmationQuorum: must be within (0, 1]; got %v", this.DeadMasterConfirmationQuorum)
+	}
+	if this.MasterFailoverNodesQuorum < 0 || this.MasterFailoverNodesQuorum > 1 {
+		validation.AddError("MasterFailoverNodesQuorum: must be within [0, 1]; got %v", this.MasterFailoverNodesQuorum)
+	}
+	if this.AgentDatadirDiskUsedPercentThreshold < 0 || this.AgentDatadirDiskUsedPercentThreshold > 100 {
+		validation.AddError("AgentDatadirDiskUsedPercentThreshold: must be within [0, 100]; got %v", this.AgentDatadirDiskUsedPercentThreshold)
+	}
+	if this.DNSUpdateTTL > 1<<31-1 {
+		validation.AddError("DNSUpdateTTL: %d exceeds maximum TTL", this.DNSUpdateTTL)
+	}
+
+	// Enumerations
+	validateOneOf(validation, "HostnameResolveMethod", this.HostnameResolveMethod, "default", "cname", "none")
+	validateOneOf(validation, "MySQLHostnameResolveMethod", this.MySQLHostnameResolveMethod, "default", "hostname", "@@hostname", "report_host", "@@report_host", "none")
+	validateOneOf(validation, "AuthenticationMethod", this.AuthenticationMethod, "", "basic", "multi", "proxy", "token")
+	validateOneOf(validation, "ConsulScheme", this.ConsulScheme, "http", "https")
+	validateOneOf(validation, "EtcdScheme", this.EtcdScheme, "http", "https")
+	validateOneOf(validation, "DNSUpdateRecordType", this.DNSUpdateRecordType, "A", "CNAME")
+	validateOneOf(validation, "DNSUpdateTSIGAlgorithm", strings.TrimSuffix(this.DNSUpdateTSIGAlgorithm, "."), "", "hmac-md5", "hmac-sha1", "hmac-sha256", "hmac-sha512")
+
+	// Files
+	validateReadableFile(validation, "SSLPrivateKeyFile", this.SSLPrivateKeyFile)
+	validateReadableFile(validation, "SSLCertFile", this.SSLCertFile)
+	validateReadableFile(validation, "SSLCAFile", this.SSLCAFile)
+	validateReadableFile(validation, "AgentSSLPrivateKeyFile", this.AgentSSLPrivateKeyFile)
+	validateReadableFile(validation, "AgentSSLCertFile", this.AgentSSLCertFile)
+	validateReadableFile(validation, "AgentSSLCAFile", this.AgentSSLCAFile)
+	validateReadableFile(validation, "MySQLTopologySSLPrivateKeyFile", this.MySQLTopologySSLPrivateKeyFile)
+	validateReadableFile(validation, "MySQLTopologySSLCertFile", this.MySQLTopologySSLCertFile)
+	validateReadableFile(validation, "MySQLTopologySSLCAFile", this.MySQLTopologySSLCAFile)
+	validateReadableFile(validation, "MySQLOrchestratorSSLPrivateKeyFile", this.MySQLOrchestratorSSLPrivateKeyFile)
+	validateReadableFile(validation, "MySQLOrchestratorSSLCertFile", this.MySQLOrchestratorSSLCertFile)
+	validateReadableFile(validation, "MySQLOrchestratorSSLCAFile", this.MySQLOrchestratorSSLCAFile)
+	validateCredentialsFile(validation, "MySQLTopologyCredentialsConfigFile", this.MySQLTopologyCredentialsConfigFile)
+	validateCredentialsFile(validation, "MySQLOrchestratorCredentialsConfigFile", this.MySQLOrchestratorCredentialsConfigFile)
+	if this.AuditLogFile != "" {
+		if _, err := os.Stat(filepath.Dir(this.AuditLogFile)); err != nil {
+			validation.AddError("AuditLogFile: directory of %s does not exist", this.AuditLogFile)
+		}
+	}
+
+	// Contradicting settings
+	if this.UseSSL && (this.SSLCertFile == "" || this.SSLPrivateKeyFile == "") {
+		validation.AddError("UseSSL requires SSLCertFile and SSLPrivateKeyFile")
+	}
+	if this.UseMutualTLS && !this.UseSSL {
+		validation.AddError("UseMutualTLS requires UseSSL")
+	}
+	if this.AgentsUseSSL && (this.AgentSSLCertFile == "" || this.AgentSSLPrivateKeyFile == "") {
+		validation.AddError("AgentsUseSSL requires AgentSSLCertFile and AgentSSLPrivateKeyFile")
+	}
+	if this.AgentsUseMutualTLS && !this.AgentsUseSSL {
+		validation.AddError("AgentsUseMutualTLS requires AgentsUseSSL")
+	}
+	if strings.EqualFold(this.AuthenticationMethod, "multi") && this.HTTPAuthUser == "" {
+		validation.AddError("AuthenticationMethod 'multi' requires HTTPAuthUser")
+	}
+	if strings.EqualFold(this.AuthenticationMethod, "basic") && this.HTTPAuthUser == "" {
+		validation.AddWarning("AuthenticationMethod is 'basic' but HTTPAuthUser is empty: running without authentication")
+	}
+	if len(this.LongRunningQueryKillPolicies) > 0 && !this.ReadLongRunningQueries {
+		validation.AddError("LongRunningQueryKillPolicies require ReadLongRunningQueries")
+	}
+	if this.DNSUpdateTSIGKeyName != "" {
+		if _, err := base64.StdEncoding.DecodeString(this.DNSUpdateTSIGSecret); err != nil || this.DNSUpdateTSIGSecret == "" {
+			validation.AddError("DNSUpdateTSIGSecret: a base64 encoded secret is required with DNSUpdateTSIGKeyName")
+		}
+	}
+	if this.ListenSocket != "" && this.ListenAddress != "" {
+		validation.AddWarning("ListenSocket is given, hence ListenAddress %s is ignored", this.ListenAddress)
+	}
+	if this.AutoPseudoGTID && this.PseudoGTIDPattern != "" {
+		validation.AddWarning("AutoPseudoGTID overrides PseudoGTIDPattern %q", this.PseudoGTIDPattern)
+	}
+	if validation.isGiven("RecoveryPeriodBlockSeconds", "RecoveryPeriodBlockMinutes") && this.RecoveryPeriodBlockSeconds != this.RecoveryPeriodBlockMinutes*60 {
+		validation.AddWarning("RecoveryPeriodBlockSeconds overrides RecoveryPeriodBlockMinutes")
+	}
+	if this.MySQLOrchestratorCredentialsConfigFile != "" && this.MySQLOrchestratorPassword != "" {
+		validation.AddWarning("MySQLOrchestratorCredentialsConfigFile overrides MySQLOrchestratorUser and MySQLOrchestratorPassword")
+	}
+	if this.MySQLTopologyCredentialsConfigFile != "" && this.MySQLTopologyPassword != "" {
+		validation.AddWarning("MySQLTopologyCredentialsConfigFile overrides MySQLTopologyUser and MySQLTopologyPassword")
+	}
+	if this.DatabaselessMode__experimental {
+		validation.AddWarning("DatabaselessMode__experimental is experimental")
+	}
+}
@@ -0,0 +1,142 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func writeTempConfigFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "orchestrator-config-validation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func validationContains(messages []string, substring string) bool {
+	for _, message := range messages {
+		if strings.Contains(message, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateDefaultConfiguration(t *testing.T) {
+	validation := &ConfigurationValidation{}
+	newConfiguration().validate(validation)
+	test.S(t).ExpectTrue(validation.IsValid())
+}
+
+func TestValidateFilesUnknownFields(t *testing.T) {
+	fileName := writeTempConfigFile(t, `{
+		"InstancePollSecond": 5,
+		"instancepollseconds": 5,
+		"RecoveryPeriodBlockMinutes": 1,
+		"DiscoveryPollSeconds": 1
+	}`)
+	defer os.Remove(fileName)
+
+	validation := ValidateFiles(fileName)
+	test.S(t).ExpectFalse(validation.IsValid())
+	test.S(t).ExpectEquals(len(validation.Errors), 1)
+	test.S(t).ExpectTrue(validationContains(validation.Errors, "unknown field InstancePollSecond"))
+	test.S(t).ExpectTrue(validationContains(validation.Warnings, "instancepollseconds should be spelled InstancePollSeconds"))
+	test.S(t).ExpectTrue(validationContains(validation.Warnings, "RecoveryPeriodBlockMinutes is deprecated"))
+	test.S(t).ExpectTrue(validationContains(validation.Warnings, "DiscoveryPollSeconds is no longer supported"))
+}
+
+func TestValidateFilesValues(t *testing.T) {
+	fileName := writeTempConfigFile(t, `{
+		"RecoverMasterClusterFilters": ["(broken", "alias=mycluster"],
+		"PostMasterFailoverProcesses": ["echo {failedHost} {succesorHost} ${HOME}"],
+		"DeadMasterConfirmationQuorum": 1.5
+	}`)
+	defer os.Remove(fileName)
+
+	validation := ValidateFiles(fileName)
+	test.S(t).ExpectEquals(len(validation.Errors), 3)
+	test.S(t).ExpectTrue(validationContains(validation.Errors, "RecoverMasterClusterFilters: invalid regular expression"))
+	test.S(t).ExpectTrue(validationContains(validation.Errors, "unknown placeholder {succesorHost}"))
+	test.S(t).ExpectFalse(validationContains(validation.Errors, "unknown placeholder {HOME}"))
+	test.S(t).ExpectTrue(validationContains(validation.Errors, "DeadMasterConfirmationQuorum"))
+}
+
+func TestValidateFilesInvalidJSON(t *testing.T) {
+	fileName := writeTempConfigFile(t, `{"ListenAddress": `)
+	defer os.Remove(fileName)
+
+	validation := ValidateFiles(fileName)
+	test.S(t).ExpectFalse(validation.IsValid())
+	test.S(t).ExpectTrue(validationContains(validation.Errors, "invalid JSON"))
+}
+
+func TestValidateFilesRecoveryPeriodBlock(t *testing.T) {
+	secondsOnly := writeTempConfigFile(t, `{"RecoveryPeriodBlockSeconds": 300}`)
+	defer os.Remove(secondsOnly)
+	validation := ValidateFiles(secondsOnly)
+	test.S(t).ExpectFalse(validationContains(validation.Warnings, "RecoveryPeriodBlockSeconds overrides RecoveryPeriodBlockMinutes"))
+
+	minutesOnly := writeTempConfigFile(t, `{"RecoveryPeriodBlockMinutes": 10}`)
+	defer os.Remove(minutesOnly)
+	validation = ValidateFiles(secondsOnly, minutesOnly)
+	test.S(t).ExpectTrue(validationContains(validation.Warnings, "RecoveryPeriodBlockSeconds overrides RecoveryPeriodBlockMinutes"))
+}
+
+func TestValidationBlockingErrors(t *testing.T) {
+	validation := &ConfigurationValidation{}
+	validation.AddIgnorableError("unknown field %s", "InstancePollSecond")
+	validation.AddError("invalid regular expression")
+	validation.AddIgnorableError("unknown placeholder {%s}", "succesorHost")
+
+	test.S(t).ExpectFalse(validation.IsValid())
+	test.S(t).ExpectEquals(len(validation.BlockingErrors(true)), 3)
+	blockingErrors := validation.BlockingErrors(false)
+	test.S(t).ExpectEquals(len(blockingErrors), 1)
+	test.S(t).ExpectEquals(blockingErrors[0], "invalid regular expression")
+}
+
+func TestValidateFilesCommentFields(t *testing.T) {
+	fileName := writeTempConfigFile(t, `{
+		"#": "a comment",
+		"InstancePollSeconds": 5,
+		"#": "another comment",
+		"LongRunningQueryKillPolicies": [{"#comment": "a nested comment", "Name": "reports", "MaxTimeSeconds": 300}]
+	}`)
+	defer os.Remove(fileName)
+
+	validation := ValidateFiles(fileName)
+	test.S(t).ExpectFalse(validationContains(validation.Errors, "unknown field"))
+	test.S(t).ExpectEquals(validation.Configuration.InstancePollSeconds, uint(5))
+}
+
+func TestValidateSampleConfigurationFiles(t *testing.T) {
+	for _, fileName := range []string{"../../conf/orchestrator-sample.conf.json", "../../conf/orchestrator-simple.conf.json"} {
+		validation := ValidateFiles(fileName)
+		test.S(t).ExpectEquals(strings.Join(validation.BlockingErrors(true), "; "), "")
+	}
+}
@@ -498,6 +498,93 @@ var generateSQLBase = []string{
 		  PRIMARY KEY (deployed_version)
 		) ENGINE=InnoDB DEFAULT CHARSET=ascii
 	`,
+	`
+		CREATE TABLE IF NOT EXISTS database_cluster_lock (
+		  cluster_name varchar(128) NOT NULL,
+		  lock_token varchar(128) NOT NULL,
+		  owner varchar(128) CHARACTER SET utf8 NOT NULL,
+		  reason text CHARACTER SET utf8 NOT NULL,
+		  begin_timestamp timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		  end_timestamp timestamp NOT NULL DEFAULT '1971-01-01 00:00:00',
+		  processing_node_hostname varchar(128) NOT NULL,
+		  processing_node_token varchar(128) NOT NULL,
+		  PRIMARY KEY (cluster_name),
+		  UNIQUE KEY lock_token_idx (lock_token),
+		  KEY end_timestamp_idx (end_timestamp)
+		) ENGINE=InnoDB DEFAULT CHARSET=ascii
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS pseudo_gtid_injection (
+		  hostname varchar(128) NOT NULL,
+		  port smallint(5) unsigned NOT NULL,
+		  cluster_name varchar(128) NOT NULL,
+		  last_attempt_timestamp timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		  last_injected_timestamp timestamp NOT NULL DEFAULT '1971-01-01 00:00:00',
+		  last_injected_hint varchar(128) NOT NULL DEFAULT '',
+		  consecutive_failures int unsigned NOT NULL DEFAULT 0,
+		  last_error text CHARACTER SET utf8 NOT NULL,
+		  last_verified_timestamp timestamp NOT NULL DEFAULT '1971-01-01 00:00:00',
+		  last_verified_hint varchar(128) NOT NULL DEFAULT '',
+		  count_lagging_slaves int unsigned NOT NULL DEFAULT 0,
+		  lagging_slaves text CHARACTER SET ascii NOT NULL,
+		  PRIMARY KEY (hostname, port),
+		  KEY cluster_name_idx (cluster_name)
+		) ENGINE=InnoDB DEFAULT CHARSET=ascii
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS node_instance_reachability (
+		  hostname varchar(128) CHARACTER SET ascii NOT NULL,
+		  port smallint unsigned NOT NULL,
+		  node_hostname varchar(128) CHARACTER SET ascii NOT NULL,
+		  node_token varchar(128) NOT NULL,
+		  is_reachable tinyint unsigned NOT NULL,
+		  probe_error text CHARACTER SET utf8 NOT NULL,
+		  last_probed timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		  PRIMARY KEY (hostname, port, node_hostname),
+		  KEY last_probed_idx (last_probed)
+		) ENGINE=InnoDB DEFAULT CHARSET=ascii
+	`, `
+		CREATE TABLE IF NOT EXISTS agent_custom_command_audit (
+		  audit_id bigint unsigned NOT NULL AUTO_INCREMENT,
+		  audit_timestamp timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		  hostname varchar(128) CHARACTER SET ascii NOT NULL,
+		  command varchar(128) CHARACTER SET ascii NOT NULL,
+		  arguments text CHARACTER SET utf8 NOT NULL,
+		  requested_by varchar(128) CHARACTER SET utf8 NOT NULL,
+		  is_successful tinyint unsigned NOT NULL,
+		  exit_status int NOT NULL,
+		  error_message text CHARACTER SET utf8 NOT NULL,
+		  output text CHARACTER SET utf8 NOT NULL,
+		  PRIMARY KEY (audit_id),
+		  KEY audit_timestamp_idx (audit_timestamp),
+		  KEY hostname_idx (hostname, audit_timestamp)
+		) ENGINE=InnoDB DEFAULT CHARSET=ascii
+	`,
+	`
+		CREATE TABLE IF NOT EXISTS database_instance_replication_channel (
+		  hostname varchar(128) CHARACTER SET ascii NOT NULL,
+		  port smallint(5) unsigned NOT NULL,
+		  channel_name varchar(64) CHARACTER SET utf8 NOT NULL,
+		  master_host varchar(128) CHARACTER SET ascii NOT NULL,
+		  master_port smallint(5) unsigned NOT NULL,
+		  slave_sql_running tinyint(3) unsigned NOT NULL,
+		  slave_io_running tinyint(3) unsigned NOT NULL,
+		  oracle_gtid tinyint(3) unsigned NOT NULL,
+		  mariadb_gtid tinyint(3) unsigned NOT NULL,
+		  master_log_file varchar(128) CHARACTER SET ascii NOT NULL,
+		  read_master_log_pos bigint(20) unsigned NOT NULL,
+		  relay_master_log_file varchar(128) CHARACTER SET ascii NOT NULL,
+		  exec_master_log_pos bigint(20) unsigned NOT NULL,
+		  relay_log_file varchar(128) CHARACTER SET ascii NOT NULL,
+		  relay_log_pos bigint(20) unsigned NOT NULL,
+		  last_sql_error text NOT NULL,
+		  last_io_error text NOT NULL,
+		  seconds_behind_master bigint(20) unsigned DEFAULT NULL,
+		  last_seen timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		  PRIMARY KEY (hostname, port, channel_name),
+		  KEY master_host_port_idx (master_host, master_port)
+		) ENGINE=InnoDB DEFAULT CHARSET=ascii
+	`,
 }
 
 // generateSQLPatches contains DDLs for patching schema to the latest version.
@@ -841,6 +928,212 @@ var generateSQLPatches = []string{
 			database_instance_maintenance
 			ADD COLUMN explicitly_bounded TINYINT UNSIGNED NOT NULL
 	`,
+	`
+		ALTER TABLE
+			database_instance_topology_history
+			ADD COLUMN binary_log_file varchar(128) NOT NULL DEFAULT '',
+			ADD COLUMN binary_log_pos bigint(20) unsigned NOT NULL DEFAULT 0,
+			ADD COLUMN relay_master_log_file varchar(128) NOT NULL DEFAULT '',
+			ADD COLUMN exec_master_log_pos bigint(20) unsigned NOT NULL DEFAULT 0,
+			ADD COLUMN seconds_behind_master bigint(20) unsigned DEFAULT NULL,
+			ADD COLUMN slave_lag_seconds bigint(20) unsigned DEFAULT NULL,
+			ADD COLUMN read_only TINYINT UNSIGNED NOT NULL DEFAULT 0,
+			ADD COLUMN oracle_gtid TINYINT UNSIGNED NOT NULL DEFAULT 0,
+			ADD COLUMN mariadb_gtid TINYINT UNSIGNED NOT NULL DEFAULT 0,
+			ADD COLUMN data_center varchar(32) CHARACTER SET ascii NOT NULL DEFAULT '',
+			ADD COLUMN is_downtimed TINYINT UNSIGNED NOT NULL DEFAULT 0
+	`,
+	`
+		ALTER TABLE
+			database_instance_topology_history
+			ADD KEY cluster_name_snapshot_idx (cluster_name(128), snapshot_unix_timestamp)
+	`,
+	`
+		ALTER TABLE
+			database_instance_last_analysis
+			MODIFY analysis varchar(512) NOT NULL
+	`,
+	`
+		ALTER TABLE
+			database_instance_analysis_changelog
+			MODIFY analysis varchar(512) NOT NULL
+	`,
+	`
+		ALTER TABLE
+			topology_recovery
+			ADD COLUMN dead_master_confirmation text CHARACTER SET ascii DEFAULT NULL
+	`,
+	`
+		ALTER TABLE
+			agent_seed
+			ADD COLUMN seed_method varchar(32) NOT NULL DEFAULT 'lvm' AFTER source_hostname,
+			ADD COLUMN bytes_copied bigint unsigned NOT NULL DEFAULT 0,
+			ADD COLUMN total_bytes bigint unsigned NOT NULL DEFAULT 0
+	`,
+	`
+		ALTER TABLE
+			agent_seed_state
+			ADD COLUMN seed_step varchar(64) NOT NULL DEFAULT ''
+	`,
+	`
+		ALTER TABLE
+			host_agent
+			ADD COLUMN mysql_running tinyint unsigned NOT NULL DEFAULT 0,
+			ADD COLUMN mysql_disk_usage bigint unsigned NOT NULL DEFAULT 0,
+			ADD COLUMN mysql_datadir_disk_free bigint unsigned NOT NULL DEFAULT 0,
+			ADD COLUMN mysql_datadir_disk_used_percent float NOT NULL DEFAULT 0,
+			ADD COLUMN mysql_error_log_crash_marker varchar(1024) CHARACTER SET utf8 NOT NULL DEFAULT ''
+	`,
+	`
+		ALTER TABLE
+			host_agent
+			ADD COLUMN certificate_fingerprint varchar(128) NOT NULL DEFAULT ''
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN replication_channel varchar(64) CHARACTER SET utf8 NOT NULL DEFAULT '' AFTER master_port
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN is_multi_source tinyint(3) unsigned NOT NULL DEFAULT 0 AFTER replication_channel
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN replication_group_name varchar(64) CHARACTER SET ascii NOT NULL DEFAULT '' AFTER is_multi_source
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN replication_group_is_single_primary_mode tinyint(3) unsigned NOT NULL DEFAULT 1 AFTER replication_group_name
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN replication_group_member_state varchar(16) CHARACTER SET ascii NOT NULL DEFAULT '' AFTER replication_group_is_single_primary_mode
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN replication_group_member_role varchar(16) CHARACTER SET ascii NOT NULL DEFAULT '' AFTER replication_group_member_state
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN replication_group_members text CHARACTER SET ascii NOT NULL AFTER replication_group_member_role
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN replication_group_primary_host varchar(128) CHARACTER SET ascii NOT NULL DEFAULT '' AFTER replication_group_members
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN replication_group_primary_port smallint(5) unsigned NOT NULL DEFAULT 0 AFTER replication_group_primary_host
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN replication_group_primary_changed_timestamp timestamp NOT NULL DEFAULT '1971-01-01 00:00:00' AFTER replication_group_primary_port
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD INDEX replication_group_name_idx (replication_group_name)
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN galera_cluster_state_uuid varchar(64) CHARACTER SET ascii NOT NULL DEFAULT '' AFTER replication_group_primary_changed_timestamp
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN galera_cluster_status varchar(32) CHARACTER SET ascii NOT NULL DEFAULT '' AFTER galera_cluster_state_uuid
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN galera_local_state_comment varchar(32) CHARACTER SET ascii NOT NULL DEFAULT '' AFTER galera_cluster_status
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN galera_cluster_size int(10) unsigned NOT NULL DEFAULT 0 AFTER galera_local_state_comment
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN galera_flow_control_paused double NOT NULL DEFAULT 0 AFTER galera_cluster_size
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN galera_flow_control_sent bigint(20) unsigned NOT NULL DEFAULT 0 AFTER galera_flow_control_paused
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN galera_flow_control_recv bigint(20) unsigned NOT NULL DEFAULT 0 AFTER galera_flow_control_sent
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD INDEX galera_cluster_state_uuid_idx (galera_cluster_state_uuid)
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN master_ssl_ca varchar(255) NOT NULL DEFAULT '' AFTER allow_tls
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN master_ssl_capath varchar(255) NOT NULL DEFAULT '' AFTER master_ssl_ca
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN master_ssl_cert varchar(255) NOT NULL DEFAULT '' AFTER master_ssl_capath
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN master_ssl_cipher varchar(255) NOT NULL DEFAULT '' AFTER master_ssl_cert
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN master_ssl_key varchar(255) NOT NULL DEFAULT '' AFTER master_ssl_cipher
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN master_ssl_crl varchar(255) NOT NULL DEFAULT '' AFTER master_ssl_key
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN master_ssl_crlpath varchar(255) NOT NULL DEFAULT '' AFTER master_ssl_crl
+	`,
+	`
+		ALTER TABLE
+			database_instance
+			ADD COLUMN master_ssl_verify_server_cert TINYINT UNSIGNED NOT NULL DEFAULT 0 AFTER master_ssl_crlpath
+	`,
+	`
+		ALTER TABLE
+			topology_recovery
+			ADD COLUMN dns_update text CHARACTER SET ascii DEFAULT NULL
+	`,
+	`
+		ALTER TABLE
+			host_agent
+			ADD COLUMN mysql_status_valid tinyint unsigned NOT NULL DEFAULT 0 AFTER mysql_running
+	`,
 }
 
 // Track if a TLS has already been configured for topology
@@ -0,0 +1,260 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package dns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+const testSecret = "c2VjcmV0LXNoYXJlZC13aXRoLXRoZS1kbnMtc2VydmVy"
+
+// fakeDNSServer is an in-memory authoritative server for a single zone, accepting TSIG signed
+// dynamic updates and answering queries, listening on a local UDP port
+type fakeDNSServer struct {
+	mutex   sync.Mutex
+	conn    net.PacketConn
+	zone    string
+	key     *TSIGKey
+	records map[string][]ResourceRecord
+	updates int
+}
+
+func newFakeDNSServer(t *testing.T, zone string, key *TSIGKey) *fakeDNSServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	test.S(t).ExpectNil(err)
+	server := &fakeDNSServer{conn: conn, zone: Fqdn(zone), key: key, records: make(map[string][]ResourceRecord)}
+	go server.serve()
+	return server
+}
+
+func (this *fakeDNSServer) address() string {
+	return this.conn.LocalAddr().String()
+}
+
+func recordsKey(name string, recordType uint16) string {
+	return fmt.Sprintf("%s/%d", strings.ToLower(Fqdn(name)), recordType)
+}
+
+func (this *fakeDNSServer) addRecord(t *testing.T, name string, recordType uint16, ttl uint32, value string) {
+	record, err := NewRecord(name, recordType, ttl, value)
+	test.S(t).ExpectNil(err)
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	key := recordsKey(name, recordType)
+	this.records[key] = append(this.records[key], record)
+}
+
+func (this *fakeDNSServer) serve() {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := this.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if response := this.handle(append([]byte{}, buf[:n]...)); response != nil {
+			this.conn.WriteTo(response, addr)
+		}
+	}
+}
+
+func (this *fakeDNSServer) respond(request *Message, rcode uint16, answers []ResourceRecord) []byte {
+	response := &Message{Id: request.Id, Flags: flagResponse | request.Opcode()<<11 | rcode, Questions: request.Questions}
+	msg, _ := response.Pack()
+	// Answers' owner names are compressed, pointing at the question
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(answers)))
+	for _, answer := range answers {
+		msg = append(msg, 0xC0, 12)
+		msg = appendUint16(msg, answer.Type)
+		msg = appendUint16(msg, answer.Class)
+		msg = appendUint32(msg, answer.TTL)
+		msg = appendUint16(msg, uint16(len(answer.Data)))
+		msg = append(msg, answer.Data...)
+	}
+	return msg
+}
+
+func (this *fakeDNSServer) handle(msg []byte) []byte {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	request, err := Unpack(msg)
+	if err != nil || len(request.Questions) != 1 {
+		return nil
+	}
+	question := request.Questions[0]
+	if request.Opcode() == opcodeQuery {
+		answers, found := this.records[recordsKey(question.Name, question.Type)]
+		if !found {
+			return this.respond(request, rcodeNXDomain, nil)
+		}
+		return this.respond(request, 0, answers)
+	}
+
+	var requestMAC []byte
+	if this.key != nil {
+		if requestMAC, err = this.key.Verify(msg, nil, time.Now()); err != nil {
+			return this.respond(request, 9, nil)
+		}
+	}
+	rcode := uint16(0)
+	if !strings.EqualFold(question.Name, this.zone) {
+		rcode = 10
+	} else {
+		for _, record := range request.Authorities {
+			key := recordsKey(record.Name, record.Type)
+			switch record.Class {
+			case ClassANY:
+				delete(this.records, key)
+			case ClassIN:
+				this.records[key] = append(this.records[key], record)
+			}
+		}
+		this.updates++
+	}
+	response := this.respond(request, rcode, nil)
+	if this.key != nil {
+		response, _, _ = this.key.Sign(response, requestMAC, time.Now())
+	}
+	return response
+}
+
+func TestPackUnpack(t *testing.T) {
+	record, err := NewRecord("db.example.com", TypeCNAME, 60, "host1.example.com")
+	test.S(t).ExpectNil(err)
+	message := &Message{Id: 17, Flags: opcodeUpdate << 11, Questions: []Question{{Name: "example.com.", Type: TypeSOA, Class: ClassIN}}, Authorities: []ResourceRecord{record}}
+	msg, err := message.Pack()
+	test.S(t).ExpectNil(err)
+
+	unpacked, err := Unpack(msg)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(unpacked.Id, uint16(17))
+	test.S(t).ExpectEquals(unpacked.Opcode(), opcodeUpdate)
+	test.S(t).ExpectEquals(unpacked.Questions[0].Name, "example.com.")
+	test.S(t).ExpectEquals(len(unpacked.Authorities), 1)
+	test.S(t).ExpectEquals(unpacked.Authorities[0].Name, "db.example.com.")
+	test.S(t).ExpectEquals(unpacked.Authorities[0].Value, "host1.example.com.")
+	test.S(t).ExpectEquals(unpacked.Authorities[0].TTL, uint32(60))
+}
+
+func TestNewRecordInvalid(t *testing.T) {
+	_, err := NewRecord("db.example.com", TypeA, 60, "host1.example.com")
+	test.S(t).ExpectNotNil(err)
+	_, err = RecordTypeFromString("MX")
+	test.S(t).ExpectNotNil(err)
+}
+
+func TestTSIGSignVerify(t *testing.T) {
+	key, err := NewTSIGKey("orchestrator-key", "hmac-sha256", testSecret)
+	test.S(t).ExpectNil(err)
+	message := &Message{Id: 1234, Questions: []Question{{Name: "example.com.", Type: TypeSOA, Class: ClassIN}}}
+	msg, _ := message.Pack()
+
+	now := time.Now()
+	signed, mac, err := key.Sign(msg, nil, now)
+	test.S(t).ExpectNil(err)
+	verifiedMAC, err := key.Verify(signed, nil, now)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectTrue(string(verifiedMAC) == string(mac))
+
+	_, err = key.Verify(signed, nil, now.Add(time.Hour))
+	test.S(t).ExpectNotNil(err)
+
+	otherKey, _ := NewTSIGKey("orchestrator-key", "hmac-sha256", "b3RoZXItc2VjcmV0")
+	_, err = otherKey.Verify(signed, nil, now)
+	test.S(t).ExpectNotNil(err)
+
+	tampered := append([]byte{}, signed...)
+	tampered[13] ^= 0x01
+	_, err = key.Verify(tampered, nil, now)
+	test.S(t).ExpectNotNil(err)
+}
+
+func TestNewTSIGKeyInvalid(t *testing.T) {
+	_, err := NewTSIGKey("orchestrator-key", "hmac-sha3", testSecret)
+	test.S(t).ExpectNotNil(err)
+	_, err = NewTSIGKey("orchestrator-key", "hmac-sha256", "not base64!")
+	test.S(t).ExpectNotNil(err)
+	key, err := NewTSIGKey("orchestrator-key", "HMAC-MD5", testSecret)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(key.Algorithm, "hmac-md5.sig-alg.reg.int.")
+}
+
+func TestUpdateRecordCNAME(t *testing.T) {
+	key, _ := NewTSIGKey("orchestrator-key", "hmac-sha256", testSecret)
+	server := newFakeDNSServer(t, "example.com", key)
+	defer server.conn.Close()
+	server.addRecord(t, "db.example.com", TypeCNAME, 300, "host1.example.com")
+
+	client := NewClient(server.address(), key, time.Second)
+	recordUpdate := client.UpdateRecord("example.com", "db.example.com", "cname", "host2.example.com", 60, 0)
+	test.S(t).ExpectEquals(recordUpdate.Error, "")
+	test.S(t).ExpectTrue(recordUpdate.Updated)
+	test.S(t).ExpectTrue(recordUpdate.Verified)
+	test.S(t).ExpectEquals(recordUpdate.Type, "CNAME")
+	test.S(t).ExpectEquals(recordUpdate.Value, "host2.example.com.")
+	test.S(t).ExpectEquals(len(recordUpdate.PreviousValues), 1)
+	test.S(t).ExpectEquals(recordUpdate.PreviousValues[0], "host1.example.com.")
+	test.S(t).ExpectEquals(recordUpdate.PreviousTTL, uint32(300))
+
+	values, ttl, err := client.Lookup("db.example.com", TypeCNAME)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(values), 1)
+	test.S(t).ExpectEquals(values[0], "host2.example.com.")
+	test.S(t).ExpectEquals(ttl, uint32(60))
+}
+
+func TestUpdateRecordA(t *testing.T) {
+	server := newFakeDNSServer(t, "example.com", nil)
+	defer server.conn.Close()
+
+	client := NewClient(server.address(), nil, time.Second)
+	recordUpdate := client.UpdateRecord("example.com", "db.example.com", "A", "10.0.0.2", 30, 0)
+	test.S(t).ExpectEquals(recordUpdate.Error, "")
+	test.S(t).ExpectTrue(recordUpdate.Verified)
+	test.S(t).ExpectEquals(len(recordUpdate.PreviousValues), 0)
+	test.S(t).ExpectEquals(server.updates, 1)
+}
+
+func TestUpdateRecordBadKey(t *testing.T) {
+	serverKey, _ := NewTSIGKey("orchestrator-key", "hmac-sha256", testSecret)
+	server := newFakeDNSServer(t, "example.com", serverKey)
+	defer server.conn.Close()
+
+	clientKey, _ := NewTSIGKey("orchestrator-key", "hmac-sha256", "b3RoZXItc2VjcmV0")
+	recordUpdate := NewClient(server.address(), clientKey, time.Second).UpdateRecord("example.com", "db.example.com", "CNAME", "host2.example.com", 60, 0)
+	test.S(t).ExpectFalse(recordUpdate.Updated)
+	test.S(t).ExpectFalse(recordUpdate.Verified)
+	test.S(t).ExpectNotEquals(recordUpdate.Error, "")
+	test.S(t).ExpectEquals(server.updates, 0)
+}
+
+func TestUpdateRecordNotZone(t *testing.T) {
+	server := newFakeDNSServer(t, "example.com", nil)
+	defer server.conn.Close()
+
+	recordUpdate := NewClient(server.address(), nil, time.Second).UpdateRecord("example.org", "db.example.org", "CNAME", "host2.example.com", 60, 0)
+	test.S(t).ExpectFalse(recordUpdate.Updated)
+	test.S(t).ExpectTrue(strings.Contains(recordUpdate.Error, "NOTZONE"))
+}
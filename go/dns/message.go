@@ -0,0 +1,322 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package dns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// This is a minimal implementation of the DNS wire format (RFC 1035), sufficient for dynamic
+// updates (RFC 2136) signed with TSIG (RFC 8945), and for verifying their outcome via lookup.
+
+const (
+	TypeA     uint16 = 1
+	TypeCNAME uint16 = 5
+	TypeSOA   uint16 = 6
+	TypeTSIG  uint16 = 250
+
+	ClassIN   uint16 = 1
+	ClassNONE uint16 = 254
+	ClassANY  uint16 = 255
+
+	opcodeQuery  uint16 = 0
+	opcodeUpdate uint16 = 5
+
+	rcodeNXDomain uint16 = 3
+
+	flagResponse  uint16 = 1 << 15
+	flagTruncated uint16 = 1 << 9
+)
+
+var rcodeNames = map[uint16]string{
+	0:  "NOERROR",
+	1:  "FORMERR",
+	2:  "SERVFAIL",
+	3:  "NXDOMAIN",
+	4:  "NOTIMP",
+	5:  "REFUSED",
+	6:  "YXDOMAIN",
+	7:  "YXRRSET",
+	8:  "NXRRSET",
+	9:  "NOTAUTH",
+	10: "NOTZONE",
+	16: "BADSIG",
+	17: "BADKEY",
+	18: "BADTIME",
+}
+
+// RcodeName returns the mnemonic of a DNS response code
+func RcodeName(rcode uint16) string {
+	if name, found := rcodeNames[rcode]; found {
+		return name
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// RecordTypeFromString parses a record type mnemonic; only A and CNAME are supported
+func RecordTypeFromString(recordType string) (uint16, error) {
+	switch strings.ToUpper(recordType) {
+	case "A":
+		return TypeA, nil
+	case "CNAME":
+		return TypeCNAME, nil
+	}
+	return 0, fmt.Errorf("Unsupported DNS record type: %s. Supported: A, CNAME", recordType)
+}
+
+// Fqdn returns given name with a trailing dot
+func Fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// Question is an entry in a message's question (or, in updates, zone) section
+type Question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// ResourceRecord is a DNS resource record. Data holds the raw rdata; Value is its textual
+// presentation, for the record types orchestrator handles.
+type ResourceRecord struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	Data  []byte
+	Value string
+}
+
+// Message is a DNS message. In update messages, Questions is the zone section, Answers the
+// prerequisite section and Authorities the update section.
+type Message struct {
+	Id          uint16
+	Flags       uint16
+	Questions   []Question
+	Answers     []ResourceRecord
+	Authorities []ResourceRecord
+	Additionals []ResourceRecord
+}
+
+func (this *Message) Opcode() uint16 {
+	return (this.Flags >> 11) & 0xF
+}
+
+func (this *Message) Rcode() uint16 {
+	return this.Flags & 0xF
+}
+
+// NewRecord creates a resource record of given type, encoding given value as its rdata
+func NewRecord(name string, recordType uint16, ttl uint32, value string) (ResourceRecord, error) {
+	record := ResourceRecord{Name: Fqdn(name), Type: recordType, Class: ClassIN, TTL: ttl, Value: value}
+	switch recordType {
+	case TypeA:
+		ip := net.ParseIP(value).To4()
+		if ip == nil {
+			return record, fmt.Errorf("Invalid IPv4 address for A record: %s", value)
+		}
+		record.Data = []byte(ip)
+	case TypeCNAME:
+		record.Value = Fqdn(value)
+		data, err := appendName(nil, record.Value)
+		if err != nil {
+			return record, err
+		}
+		record.Data = data
+	default:
+		return record, fmt.Errorf("Unsupported record type: %d", recordType)
+	}
+	return record, nil
+}
+
+// appendName appends the uncompressed wire format of given domain name
+func appendName(buf []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return buf, fmt.Errorf("Invalid domain name: %s", name)
+			}
+			buf = append(buf, byte(len(label)))
+			buf = append(buf, label...)
+		}
+	}
+	return append(buf, 0), nil
+}
+
+func appendUint16(buf []byte, value uint16) []byte {
+	return append(buf, byte(value>>8), byte(value))
+}
+
+func appendUint32(buf []byte, value uint32) []byte {
+	return append(buf, byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+}
+
+func appendRecord(buf []byte, record *ResourceRecord) ([]byte, error) {
+	buf, err := appendName(buf, record.Name)
+	if err != nil {
+		return buf, err
+	}
+	buf = appendUint16(buf, record.Type)
+	buf = appendUint16(buf, record.Class)
+	buf = appendUint32(buf, record.TTL)
+	buf = appendUint16(buf, uint16(len(record.Data)))
+	return append(buf, record.Data...), nil
+}
+
+// Pack returns the wire format of this message
+func (this *Message) Pack() (buf []byte, err error) {
+	buf = appendUint16(buf, this.Id)
+	buf = appendUint16(buf, this.Flags)
+	buf = appendUint16(buf, uint16(len(this.Questions)))
+	buf = appendUint16(buf, uint16(len(this.Answers)))
+	buf = appendUint16(buf, uint16(len(this.Authorities)))
+	buf = appendUint16(buf, uint16(len(this.Additionals)))
+	for _, question := range this.Questions {
+		if buf, err = appendName(buf, question.Name); err != nil {
+			return buf, err
+		}
+		buf = appendUint16(buf, question.Type)
+		buf = appendUint16(buf, question.Class)
+	}
+	for _, section := range [][]ResourceRecord{this.Answers, this.Authorities, this.Additionals} {
+		for i := range section {
+			if buf, err = appendRecord(buf, &section[i]); err != nil {
+				return buf, err
+			}
+		}
+	}
+	return buf, nil
+}
+
+var errShortMessage = fmt.Errorf("DNS message too short")
+
+// readName reads a (possibly compressed) domain name at given offset, returning it along with
+// the offset following it
+func readName(msg []byte, offset int) (name string, next int, err error) {
+	labels := []string{}
+	next = -1
+	for jumps := 0; ; {
+		if offset >= len(msg) {
+			return name, next, errShortMessage
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if next < 0 {
+				next = offset + 1
+			}
+			return Fqdn(strings.Join(labels, ".")), next, nil
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(msg) {
+				return name, next, errShortMessage
+			}
+			if next < 0 {
+				next = offset + 2
+			}
+			if jumps++; jumps > 64 {
+				return name, next, fmt.Errorf("DNS name compression loop")
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3FFF)
+		default:
+			if offset+1+length > len(msg) {
+				return name, next, errShortMessage
+			}
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+func readRecord(msg []byte, offset int) (record ResourceRecord, next int, err error) {
+	if record.Name, offset, err = readName(msg, offset); err != nil {
+		return record, offset, err
+	}
+	if offset+10 > len(msg) {
+		return record, offset, errShortMessage
+	}
+	record.Type = binary.BigEndian.Uint16(msg[offset:])
+	record.Class = binary.BigEndian.Uint16(msg[offset+2:])
+	record.TTL = binary.BigEndian.Uint32(msg[offset+4:])
+	dataLength := int(binary.BigEndian.Uint16(msg[offset+8:]))
+	offset += 10
+	if offset+dataLength > len(msg) {
+		return record, offset, errShortMessage
+	}
+	record.Data = msg[offset : offset+dataLength]
+	switch {
+	case record.Type == TypeA && dataLength == 4:
+		record.Value = net.IP(record.Data).String()
+	case record.Type == TypeCNAME && dataLength > 0:
+		if record.Value, _, err = readName(msg, offset); err != nil {
+			return record, offset, err
+		}
+		// Rewrite rdata uncompressed, so that it stands on its own
+		if record.Data, err = appendName(nil, record.Value); err != nil {
+			return record, offset, err
+		}
+	}
+	return record, offset + dataLength, nil
+}
+
+// Unpack parses a message from its wire format
+func Unpack(msg []byte) (message *Message, err error) {
+	if len(msg) < 12 {
+		return nil, errShortMessage
+	}
+	message = &Message{
+		Id:    binary.BigEndian.Uint16(msg[0:]),
+		Flags: binary.BigEndian.Uint16(msg[2:]),
+	}
+	questionsCount := int(binary.BigEndian.Uint16(msg[4:]))
+	counts := []int{
+		int(binary.BigEndian.Uint16(msg[6:])),
+		int(binary.BigEndian.Uint16(msg[8:])),
+		int(binary.BigEndian.Uint16(msg[10:])),
+	}
+	offset := 12
+	for i := 0; i < questionsCount; i++ {
+		question := Question{}
+		if question.Name, offset, err = readName(msg, offset); err != nil {
+			return message, err
+		}
+		if offset+4 > len(msg) {
+			return message, errShortMessage
+		}
+		question.Type = binary.BigEndian.Uint16(msg[offset:])
+		question.Class = binary.BigEndian.Uint16(msg[offset+2:])
+		offset += 4
+		message.Questions = append(message.Questions, question)
+	}
+	sections := [](*[]ResourceRecord){&message.Answers, &message.Authorities, &message.Additionals}
+	for i, section := range sections {
+		for j := 0; j < counts[i]; j++ {
+			var record ResourceRecord
+			if record, offset, err = readRecord(msg, offset); err != nil {
+				return message, err
+			}
+			*section = append(*section, record)
+		}
+	}
+	return message, nil
+}
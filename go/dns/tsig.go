@@ -0,0 +1,219 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package dns
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+const tsigFudgeSeconds = 300
+
+var tsigAlgorithms = map[string]func() hash.Hash{
+	"hmac-md5.sig-alg.reg.int.": md5.New,
+	"hmac-sha1.":                sha1.New,
+	"hmac-sha256.":              sha256.New,
+	"hmac-sha512.":              sha512.New,
+}
+
+// TSIGKey is a shared secret by which update messages are signed (RFC 8945)
+type TSIGKey struct {
+	Name      string
+	Algorithm string
+	Secret    []byte
+}
+
+// NewTSIGKey creates a key given its name, algorithm (e.g. "hmac-sha256") and base64 encoded secret
+func NewTSIGKey(name string, algorithm string, secret string) (*TSIGKey, error) {
+	if algorithm == "" {
+		algorithm = "hmac-sha256"
+	}
+	algorithm = Fqdn(strings.ToLower(algorithm))
+	if algorithm == "hmac-md5." {
+		algorithm = "hmac-md5.sig-alg.reg.int."
+	}
+	if _, found := tsigAlgorithms[algorithm]; !found {
+		return nil, fmt.Errorf("Unsupported TSIG algorithm: %s", algorithm)
+	}
+	decodedSecret, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode TSIG secret of key %s: %+v", name, err)
+	}
+	return &TSIGKey{Name: Fqdn(strings.ToLower(name)), Algorithm: algorithm, Secret: decodedSecret}, nil
+}
+
+// tsigRecordData is the rdata of a TSIG record
+type tsigRecordData struct {
+	Algorithm  string
+	TimeSigned uint64
+	Fudge      uint16
+	MAC        []byte
+	OriginalId uint16
+	Error      uint16
+	OtherData  []byte
+}
+
+func (this *tsigRecordData) pack() ([]byte, error) {
+	buf, err := appendName(nil, this.Algorithm)
+	if err != nil {
+		return buf, err
+	}
+	buf = appendUint16(buf, uint16(this.TimeSigned>>32))
+	buf = appendUint32(buf, uint32(this.TimeSigned))
+	buf = appendUint16(buf, this.Fudge)
+	buf = appendUint16(buf, uint16(len(this.MAC)))
+	buf = append(buf, this.MAC...)
+	buf = appendUint16(buf, this.OriginalId)
+	buf = appendUint16(buf, this.Error)
+	buf = appendUint16(buf, uint16(len(this.OtherData)))
+	return append(buf, this.OtherData...), nil
+}
+
+func unpackTSIGRecordData(data []byte) (tsig *tsigRecordData, err error) {
+	tsig = &tsigRecordData{}
+	offset := 0
+	if tsig.Algorithm, offset, err = readName(data, offset); err != nil {
+		return tsig, err
+	}
+	if offset+10 > len(data) {
+		return tsig, errShortMessage
+	}
+	tsig.TimeSigned = uint64(binary.BigEndian.Uint16(data[offset:]))<<32 | uint64(binary.BigEndian.Uint32(data[offset+2:]))
+	tsig.Fudge = binary.BigEndian.Uint16(data[offset+6:])
+	macSize := int(binary.BigEndian.Uint16(data[offset+8:]))
+	offset += 10
+	if offset+macSize+6 > len(data) {
+		return tsig, errShortMessage
+	}
+	tsig.MAC = data[offset : offset+macSize]
+	offset += macSize
+	tsig.OriginalId = binary.BigEndian.Uint16(data[offset:])
+	tsig.Error = binary.BigEndian.Uint16(data[offset+2:])
+	otherLength := int(binary.BigEndian.Uint16(data[offset+4:]))
+	offset += 6
+	if offset+otherLength > len(data) {
+		return tsig, errShortMessage
+	}
+	tsig.OtherData = data[offset : offset+otherLength]
+	return tsig, nil
+}
+
+// mac computes the TSIG MAC of a message. requestMAC is given when computing the MAC of a response.
+func (this *TSIGKey) mac(requestMAC []byte, msg []byte, tsig *tsigRecordData) ([]byte, error) {
+	mac := hmac.New(tsigAlgorithms[this.Algorithm], this.Secret)
+	if requestMAC != nil {
+		mac.Write(appendUint16(nil, uint16(len(requestMAC))))
+		mac.Write(requestMAC)
+	}
+	mac.Write(msg)
+
+	variables, err := appendName(nil, this.Name)
+	if err != nil {
+		return nil, err
+	}
+	variables = appendUint16(variables, ClassANY)
+	variables = appendUint32(variables, 0)
+	if variables, err = appendName(variables, tsig.Algorithm); err != nil {
+		return nil, err
+	}
+	variables = appendUint16(variables, uint16(tsig.TimeSigned>>32))
+	variables = appendUint32(variables, uint32(tsig.TimeSigned))
+	variables = appendUint16(variables, tsig.Fudge)
+	variables = appendUint16(variables, tsig.Error)
+	variables = appendUint16(variables, uint16(len(tsig.OtherData)))
+	variables = append(variables, tsig.OtherData...)
+	mac.Write(variables)
+	return mac.Sum(nil), nil
+}
+
+// Sign appends a TSIG record to a packed message. requestMAC is given when signing a response.
+// It returns the signed message along with its MAC.
+func (this *TSIGKey) Sign(msg []byte, requestMAC []byte, now time.Time) (signed []byte, mac []byte, err error) {
+	if len(msg) < 12 {
+		return nil, nil, errShortMessage
+	}
+	tsig := &tsigRecordData{
+		Algorithm:  this.Algorithm,
+		TimeSigned: uint64(now.Unix()),
+		Fudge:      tsigFudgeSeconds,
+		OriginalId: binary.BigEndian.Uint16(msg),
+	}
+	if tsig.MAC, err = this.mac(requestMAC, msg, tsig); err != nil {
+		return nil, nil, err
+	}
+	data, err := tsig.pack()
+	if err != nil {
+		return nil, nil, err
+	}
+	signed = append([]byte{}, msg...)
+	binary.BigEndian.PutUint16(signed[10:], binary.BigEndian.Uint16(signed[10:])+1)
+	signed, err = appendRecord(signed, &ResourceRecord{Name: this.Name, Type: TypeTSIG, Class: ClassANY, TTL: 0, Data: data})
+	return signed, tsig.MAC, err
+}
+
+// Verify validates the TSIG record terminating a packed message, returning the message's MAC.
+// requestMAC is given when verifying a response.
+func (this *TSIGKey) Verify(msg []byte, requestMAC []byte, now time.Time) (mac []byte, err error) {
+	message, err := Unpack(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(message.Additionals) == 0 || message.Additionals[len(message.Additionals)-1].Type != TypeTSIG {
+		return nil, fmt.Errorf("DNS message is not TSIG signed")
+	}
+	record := message.Additionals[len(message.Additionals)-1]
+	if !strings.EqualFold(record.Name, this.Name) {
+		return nil, fmt.Errorf("DNS message signed by unexpected key: %s", record.Name)
+	}
+	tsig, err := unpackTSIGRecordData(record.Data)
+	if err != nil {
+		return nil, err
+	}
+	if tsig.Error != 0 {
+		return nil, fmt.Errorf("TSIG error: %s", RcodeName(tsig.Error))
+	}
+	if !strings.EqualFold(tsig.Algorithm, this.Algorithm) {
+		return nil, fmt.Errorf("DNS message signed with unexpected algorithm: %s", tsig.Algorithm)
+	}
+	// The TSIG record is never compressed, and is the last one in the message
+	encodedName, _ := appendName(nil, record.Name)
+	unsigned := append([]byte{}, msg[:len(msg)-len(encodedName)-10-len(record.Data)]...)
+	binary.BigEndian.PutUint16(unsigned[0:], tsig.OriginalId)
+	binary.BigEndian.PutUint16(unsigned[10:], binary.BigEndian.Uint16(unsigned[10:])-1)
+
+	expectedMAC, err := this.mac(requestMAC, unsigned, tsig)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(expectedMAC, tsig.MAC) {
+		return nil, fmt.Errorf("TSIG verification failed: bad signature")
+	}
+	timeDiff := now.Unix() - int64(tsig.TimeSigned)
+	if timeDiff < -int64(tsig.Fudge) || timeDiff > int64(tsig.Fudge) {
+		return nil, fmt.Errorf("TSIG verification failed: time signed is out of fudge window")
+	}
+	return tsig.MAC, nil
+}
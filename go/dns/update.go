@@ -0,0 +1,222 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package dns
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Client talks to a single (typically the primary) DNS server
+type Client struct {
+	Server  string
+	Key     *TSIGKey
+	Timeout time.Duration
+}
+
+// NewClient creates a client of given server; port 53 is assumed when unspecified. key may be nil,
+// in which case updates are not signed.
+func NewClient(server string, key *TSIGKey, timeout time.Duration) *Client {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &Client{Server: server, Key: key, Timeout: timeout}
+}
+
+func newMessageId() uint16 {
+	buf := make([]byte, 2)
+	rand.Read(buf)
+	return binary.BigEndian.Uint16(buf)
+}
+
+// exchange sends given message over UDP and awaits its response. Updates are signed when the client
+// has a key, in which case the response's signature is verified as well.
+func (this *Client) exchange(message *Message, sign bool) (*Message, error) {
+	request, err := message.Pack()
+	if err != nil {
+		return nil, err
+	}
+	var requestMAC []byte
+	if sign {
+		if request, requestMAC, err = this.Key.Sign(request, nil, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := net.DialTimeout("udp", this.Server, this.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(this.Timeout))
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		responseBytes := buf[:n]
+		response, err := Unpack(responseBytes)
+		if err != nil {
+			return nil, err
+		}
+		if response.Id != message.Id || response.Flags&flagResponse == 0 {
+			// Not ours; keep waiting
+			continue
+		}
+		if response.Flags&flagTruncated != 0 {
+			return response, fmt.Errorf("Truncated DNS response from %s", this.Server)
+		}
+		if sign {
+			if _, err := this.Key.Verify(responseBytes, requestMAC, time.Now()); err != nil {
+				return response, fmt.Errorf("DNS response from %s: %+v", this.Server, err)
+			}
+		}
+		return response, nil
+	}
+}
+
+// Lookup queries the server for records of given name and type. A missing name or record is not an error;
+// it results in no values.
+func (this *Client) Lookup(name string, recordType uint16) (values []string, ttl uint32, err error) {
+	query := &Message{
+		Id:        newMessageId(),
+		Flags:     opcodeQuery << 11,
+		Questions: []Question{{Name: Fqdn(name), Type: recordType, Class: ClassIN}},
+	}
+	response, err := this.exchange(query, false)
+	if err != nil {
+		return values, ttl, err
+	}
+	if rcode := response.Rcode(); rcode != 0 && rcode != rcodeNXDomain {
+		return values, ttl, fmt.Errorf("DNS lookup of %s failed: %s", name, RcodeName(rcode))
+	}
+	for _, answer := range response.Answers {
+		if answer.Type == recordType && strings.EqualFold(answer.Name, Fqdn(name)) {
+			values = append(values, answer.Value)
+			ttl = answer.TTL
+		}
+	}
+	return values, ttl, nil
+}
+
+// Update replaces the RRset of given record's name and type, in given zone, with given record (RFC 2136)
+func (this *Client) Update(zone string, record ResourceRecord) error {
+	update := &Message{
+		Id:        newMessageId(),
+		Flags:     opcodeUpdate << 11,
+		Questions: []Question{{Name: Fqdn(zone), Type: TypeSOA, Class: ClassIN}},
+		Authorities: []ResourceRecord{
+			// Delete the existing RRset...
+			{Name: record.Name, Type: record.Type, Class: ClassANY, TTL: 0},
+			// ...and add the new record
+			record,
+		},
+	}
+	response, err := this.exchange(update, this.Key != nil)
+	if err != nil {
+		return err
+	}
+	if rcode := response.Rcode(); rcode != 0 {
+		return fmt.Errorf("DNS update of %s on %s failed: %s", record.Name, this.Server, RcodeName(rcode))
+	}
+	return nil
+}
+
+// RecordUpdate describes a DNS record update and its outcome
+type RecordUpdate struct {
+	Server         string
+	Zone           string
+	Name           string
+	Type           string
+	Value          string
+	TTL            uint32
+	PreviousValues []string
+	PreviousTTL    uint32
+	Updated        bool
+	Verified       bool
+	Error          string
+}
+
+// Description returns a human readable summary of this update
+func (this *RecordUpdate) Description() string {
+	description := fmt.Sprintf("%s %s -> %s (ttl %d) on %s", this.Name, this.Type, this.Value, this.TTL, this.Server)
+	if len(this.PreviousValues) > 0 {
+		description = fmt.Sprintf("%s; previously %s (ttl %d), which resolvers may cache for up to %d seconds", description, strings.Join(this.PreviousValues, ","), this.PreviousTTL, this.PreviousTTL)
+	}
+	switch {
+	case this.Error != "":
+		description = fmt.Sprintf("%s; error: %s", description, this.Error)
+	case this.Verified:
+		description = fmt.Sprintf("%s; verified", description)
+	case this.Updated:
+		description = fmt.Sprintf("%s; updated but not verified", description)
+	}
+	return description
+}
+
+func (this *RecordUpdate) fail(err error) *RecordUpdate {
+	this.Error = err.Error()
+	return this
+}
+
+// UpdateRecord points given name at given value, then verifies the update by lookup, retrying for up to
+// verifyTimeout. The previous values and their TTL are captured, as resolvers may still serve them until
+// that TTL expires.
+func (this *Client) UpdateRecord(zone string, name string, recordType string, value string, ttl uint32, verifyTimeout time.Duration) *RecordUpdate {
+	recordUpdate := &RecordUpdate{Server: this.Server, Zone: Fqdn(zone), Name: Fqdn(name), Type: strings.ToUpper(recordType), Value: value, TTL: ttl}
+	rrType, err := RecordTypeFromString(recordType)
+	if err != nil {
+		return recordUpdate.fail(err)
+	}
+	record, err := NewRecord(name, rrType, ttl, value)
+	if err != nil {
+		return recordUpdate.fail(err)
+	}
+	recordUpdate.Value = record.Value
+
+	if recordUpdate.PreviousValues, recordUpdate.PreviousTTL, err = this.Lookup(name, rrType); err != nil {
+		return recordUpdate.fail(err)
+	}
+	if err := this.Update(zone, record); err != nil {
+		return recordUpdate.fail(err)
+	}
+	recordUpdate.Updated = true
+
+	verifyDeadline := time.Now().Add(verifyTimeout)
+	for {
+		values, _, err := this.Lookup(name, rrType)
+		if err == nil && len(values) == 1 && strings.EqualFold(values[0], record.Value) {
+			recordUpdate.Verified = true
+			return recordUpdate
+		}
+		if time.Now().After(verifyDeadline) {
+			if err == nil {
+				err = fmt.Errorf("Verification failed: %s %s resolves to %s", recordUpdate.Name, recordUpdate.Type, strings.Join(values, ","))
+			}
+			return recordUpdate.fail(err)
+		}
+		time.Sleep(time.Second)
+	}
+}
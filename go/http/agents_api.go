@@ -33,15 +33,41 @@ type HttpAgentsAPI struct{}
 
 var AgentsAPI HttpAgentsAPI = HttpAgentsAPI{}
 
+// newAgentRequest extracts the identity an agent presents on given request
+func newAgentRequest(hostname string, req *http.Request) *agent.AgentRequest {
+	request := &agent.AgentRequest{
+		Hostname:    hostname,
+		RequestPath: req.URL.Path,
+		RemoteAddr:  req.RemoteAddr,
+		Timestamp:   req.URL.Query().Get("timestamp"),
+		Nonce:       req.URL.Query().Get("nonce"),
+		Signature:   req.URL.Query().Get("signature"),
+	}
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 && len(req.TLS.VerifiedChains[0]) > 0 {
+		request.ClientCertificate = req.TLS.VerifiedChains[0][0]
+	}
+	return request
+}
+
 // SubmitAgent registeres an agent. It is initiated by an agent to register itself.
-func (this *HttpAgentsAPI) SubmitAgent(params martini.Params, r render.Render) {
+func (this *HttpAgentsAPI) SubmitAgent(params martini.Params, r render.Render, req *http.Request) {
 	port, err := strconv.Atoi(params["port"])
 	if err != nil {
 		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
 		return
 	}
 
-	output, err := agent.SubmitAgent(params["host"], port, params["token"])
+	output, err := agent.SubmitAgent(params["host"], port, params["token"], newAgentRequest(params["host"], req))
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	r.JSON(200, output)
+}
+
+// RotateAgentToken replaces an agent's token. It is initiated by an agent, presenting its current token.
+func (this *HttpAgentsAPI) RotateAgentToken(params martini.Params, r render.Render, req *http.Request) {
+	output, err := agent.RotateAgentToken(params["host"], params["token"], params["newToken"], newAgentRequest(params["host"], req))
 	if err != nil {
 		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
 		return
@@ -123,6 +149,7 @@ func (this *HttpAgentsAPI) AgentPing(params martini.Params, r render.Render, req
 // RegisterRequests makes for the de-facto list of known API calls
 func (this *HttpAgentsAPI) RegisterRequests(m *martini.ClassicMartini) {
 	m.Get("/api/submit-agent/:host/:port/:token", this.SubmitAgent)
+	m.Get("/api/rotate-agent-token/:host/:token/:newToken", this.RotateAgentToken)
 	m.Get("/api/host-attribute/:host/:attrVame/:attrValue", this.SetHostAttribute)
 	m.Get("/api/host-attribute/attr/:attr/", this.GetHostAttributeByAttributeName)
 	m.Get("/api/agents-hosts", this.AgentsHosts)
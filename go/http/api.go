@@ -250,6 +250,66 @@ func (this *HttpAPI) Maintenance(params martini.Params, r render.Render, req *ht
 	r.JSON(200, instanceKeys)
 }
 
+// ClusterLocks provides list of currently held cluster locks, possibly for a given cluster
+func (this *HttpAPI) ClusterLocks(params martini.Params, r render.Render, req *http.Request) {
+	clusterLocks := []inst.ClusterLock{}
+	var err error
+	if clusterName := params["clusterName"]; clusterName != "" {
+		var clusterLock *inst.ClusterLock
+		clusterLock, err = inst.ReadClusterLock(clusterName)
+		if clusterLock != nil {
+			clusterLocks = append(clusterLocks, *clusterLock)
+		}
+	} else {
+		clusterLocks, err = inst.ReadActiveClusterLocks()
+	}
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	r.JSON(200, clusterLocks)
+}
+
+// PseudoGTIDInjectionStatus provides status of automated Pseudo-GTID injection on cluster masters, possibly for a given cluster
+func (this *HttpAPI) PseudoGTIDInjectionStatus(params martini.Params, r render.Render, req *http.Request) {
+	var statuses []inst.PseudoGTIDInjectionStatus
+	var err error
+	if clusterName := params["clusterName"]; clusterName != "" {
+		statuses, err = inst.ReadClusterPseudoGTIDInjectionStatuses(clusterName)
+	} else {
+		statuses, err = inst.ReadPseudoGTIDInjectionStatuses()
+	}
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	r.JSON(200, statuses)
+}
+
+// EndClusterLock forcibly releases the lock held on given cluster
+func (this *HttpAPI) EndClusterLock(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	userId := getUserId(req, user)
+	if userId == "" {
+		userId = inst.GetMaintenanceOwner()
+	}
+	clusterName := params["clusterName"]
+	err := inst.ForceEndClusterLock(clusterName, fmt.Sprintf("Triggered via API by %s", userId))
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Cluster lock ended: %+v", clusterName)})
+}
+
 // BeginDowntime sets a downtime flag with default duration
 func (this *HttpAPI) BeginDowntime(params martini.Params, r render.Render, req *http.Request, user auth.User) {
 	if !isAuthorizedForAction(req, user) {
@@ -519,6 +579,65 @@ func (this *HttpAPI) DisableGTID(params martini.Params, r render.Render, req *ht
 	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Disabled GTID on %+v", instance.Key), Details: instance})
 }
 
+// EnableReplicationTLS turns on TLS for the replication of a slave
+func (this *HttpAPI) EnableReplicationTLS(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	instance, err := inst.EnableReplicationTLS(&instanceKey)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Enabled replication TLS on %+v", instance.Key), Details: instance})
+}
+
+// DisableReplicationTLS turns off TLS for the replication of a slave
+func (this *HttpAPI) DisableReplicationTLS(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	instance, err := inst.DisableReplicationTLS(&instanceKey)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Disabled replication TLS on %+v", instance.Key), Details: instance})
+}
+
+// RotateReplicationCredentials applies new replication credentials on all slaves of a cluster. Credentials are
+// POSTed as "user" and "password" form values, so as to not appear in URLs or access logs.
+func (this *HttpAPI) RotateReplicationCredentials(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	clusterName := params["clusterName"]
+	rotation, err := inst.RotateReplicationCredentials(clusterName, req.PostFormValue("user"), req.PostFormValue("password"))
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error(), Details: rotation})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Rotated replication credentials on %d slaves of %+v", len(rotation.Rotated), clusterName), Details: rotation})
+}
+
 // MoveBelow attempts to move an instance below its supposed sibling
 func (this *HttpAPI) MoveBelow(params martini.Params, r render.Render, req *http.Request, user auth.User) {
 	if !isAuthorizedForAction(req, user) {
@@ -1070,6 +1189,200 @@ func (this *HttpAPI) StopSlave(params martini.Params, r render.Render, req *http
 	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Slave stopped: %+v", instance.Key), Details: instance})
 }
 
+// StopSlaveChannel stops replication of a given channel on a given (multi-source) instance
+func (this *HttpAPI) StopSlaveChannel(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	instance, err := inst.StopSlaveChannel(&instanceKey, params["channel"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Slave stopped: %+v, channel: %s", instance.Key, params["channel"]), Details: instance})
+}
+
+// StartSlaveChannel starts replication of a given channel on a given (multi-source) instance
+func (this *HttpAPI) StartSlaveChannel(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	instance, err := inst.StartSlaveChannel(&instanceKey, params["channel"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Slave started: %+v, channel: %s", instance.Key, params["channel"]), Details: instance})
+}
+
+// RepointChannel repoints a given replication channel of a (multi-source) instance onto another master,
+// keeping the channel's coordinates (or GTID position)
+func (this *HttpAPI) RepointChannel(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	belowKey, err := this.getInstanceKey(params["belowHost"], params["belowPort"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	instance, err := inst.RepointChannel(&instanceKey, params["channel"], &belowKey, inst.GTIDHintNeutral)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Channel %s of %+v repointed below %+v", params["channel"], instanceKey, belowKey), Details: instance})
+}
+
+// RelocateBelowChannel relocates a given replication channel of a (multi-source) instance below another instance
+func (this *HttpAPI) RelocateBelowChannel(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	belowKey, err := this.getInstanceKey(params["belowHost"], params["belowPort"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	instance, err := inst.RelocateBelowChannel(&instanceKey, params["channel"], &belowKey)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Channel %s of %+v relocated below %+v", params["channel"], instanceKey, belowKey), Details: instance})
+}
+
+// MoveUpChannel moves a given replication channel of a (multi-source) instance one level up the topology
+func (this *HttpAPI) MoveUpChannel(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	instance, err := inst.MoveUpChannel(&instanceKey, params["channel"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Channel %s of %+v moved up", params["channel"], instanceKey), Details: instance})
+}
+
+// MoveBelowChannel moves a given replication channel of a (multi-source) instance below its sibling
+func (this *HttpAPI) MoveBelowChannel(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	belowKey, err := this.getInstanceKey(params["belowHost"], params["belowPort"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	instance, err := inst.MoveBelowChannel(&instanceKey, params["channel"], &belowKey)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Channel %s of %+v moved below %+v", params["channel"], instanceKey, belowKey), Details: instance})
+}
+
+// MoveBelowGTIDChannel moves a given replication channel of a (multi-source) instance below another instance via GTID
+func (this *HttpAPI) MoveBelowGTIDChannel(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	belowKey, err := this.getInstanceKey(params["belowHost"], params["belowPort"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	instance, err := inst.MoveBelowGTIDChannel(&instanceKey, params["channel"], &belowKey)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Channel %s of %+v moved below %+v via GTID", params["channel"], instanceKey, belowKey), Details: instance})
+}
+
+// MatchBelowChannel matches a given replication channel of a (multi-source) instance below another instance via Pseudo-GTID
+func (this *HttpAPI) MatchBelowChannel(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	belowKey, err := this.getInstanceKey(params["belowHost"], params["belowPort"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	instance, matchedCoordinates, err := inst.MatchBelowChannel(&instanceKey, params["channel"], &belowKey, true)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Channel %s of %+v matched below %+v at %+v", params["channel"], instanceKey, belowKey, *matchedCoordinates), Details: instance})
+}
+
 // StopSlaveNicely stops replication on given instance, such that sql thead is aligned with IO thread
 func (this *HttpAPI) StopSlaveNicely(params martini.Params, r render.Render, req *http.Request, user auth.User) {
 	if !isAuthorizedForAction(req, user) {
@@ -1160,6 +1473,27 @@ func (this *HttpAPI) SetWriteable(params martini.Params, r render.Render, req *h
 	r.JSON(200, &APIResponse{Code: OK, Message: "Server set as writeable", Details: instance})
 }
 
+// FixReadOnly sets the global read_only variable according to the instance's role
+func (this *HttpAPI) FixReadOnly(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	instance, err := inst.FixReadOnly(&instanceKey)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Server read_only: %t", instance.ReadOnly), Details: instance})
+}
+
 // KillQuery kills a query running on a server
 func (this *HttpAPI) KillQuery(params martini.Params, r render.Render, req *http.Request, user auth.User) {
 	if !isAuthorizedForAction(req, user) {
@@ -1198,6 +1532,122 @@ func (this *HttpAPI) Cluster(params martini.Params, r render.Render, req *http.R
 	r.JSON(200, instances)
 }
 
+// ReplicationGroup returns the known members of the replication group given instance is a member of
+func (this *HttpAPI) ReplicationGroup(params martini.Params, r render.Render, req *http.Request) {
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	instance, found, err := inst.ReadInstance(&instanceKey)
+	if (!found) || (err != nil) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("Cannot read instance: %+v", instanceKey)})
+		return
+	}
+	if !instance.IsReplicationGroupMember() {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v is not a replication group member", instanceKey)})
+		return
+	}
+	instances, err := inst.ReadReplicationGroupInstances(instance.ReplicationGroupName)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	r.JSON(200, instances)
+}
+
+// GaleraCluster returns the known nodes of the Galera cluster given instance is a node of
+func (this *HttpAPI) GaleraCluster(params martini.Params, r render.Render, req *http.Request) {
+	instanceKey, err := this.getInstanceKey(params["host"], params["port"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: err.Error()})
+		return
+	}
+	instance, found, err := inst.ReadInstance(&instanceKey)
+	if (!found) || (err != nil) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("Cannot read instance: %+v", instanceKey)})
+		return
+	}
+	if !instance.IsGaleraNode() {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v is not a Galera node", instanceKey)})
+		return
+	}
+	instances, err := inst.ReadGaleraClusterInstances(instance.GaleraClusterStateUUID)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	r.JSON(200, instances)
+}
+
+// Topology provides the nested replication tree of given cluster, in a given format (json, dot, mermaid, ascii)
+func (this *HttpAPI) Topology(params martini.Params, r render.Render, req *http.Request) {
+	clusterName := params["clusterName"]
+	format := req.URL.Query().Get("format")
+	if format == "" || format == inst.TopologyFormatJSON {
+		tree, err := inst.GetTopologyTree(clusterName, "")
+		if err != nil {
+			r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+			return
+		}
+		r.JSON(200, tree)
+		return
+	}
+	output, err := inst.ExportTopology(clusterName, "", format)
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	r.Text(200, output)
+}
+
+// ClusterHistory provides the topology of given cluster as recorded by topology snapshots at a given point in time
+func (this *HttpAPI) ClusterHistory(params martini.Params, r render.Render, req *http.Request) {
+	at, err := inst.ParseTopologyHistoryTime(req.URL.Query().Get("at"))
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+	snapshot, err := inst.ReadClusterTopologyAt(params["clusterName"], at)
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	r.JSON(200, snapshot)
+}
+
+// ClusterDiff reports the differences in topology of given cluster between two points in time
+func (this *HttpAPI) ClusterDiff(params martini.Params, r render.Render, req *http.Request) {
+	from, err := inst.ParseTopologyHistoryTime(req.URL.Query().Get("from"))
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+	if from.IsZero() {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Missing 'from' argument"})
+		return
+	}
+	to, err := inst.ParseTopologyHistoryTime(req.URL.Query().Get("to"))
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+	diff, err := inst.DiffClusterTopology(params["clusterName"], from, to)
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	r.JSON(200, diff)
+}
+
 // ClusterByAlias provides list of instances in given cluster
 func (this *HttpAPI) ClusterByAlias(params martini.Params, r render.Render, req *http.Request) {
 	clusterName, err := inst.GetClusterByAlias(params["clusterAlias"])
@@ -1263,6 +1713,18 @@ func (this *HttpAPI) ClusterOSCSlaves(params martini.Params, r render.Render, re
 	r.JSON(200, instances)
 }
 
+// ClusterReplicationTLS lists the slaves of a cluster by whether they replicate over TLS
+func (this *HttpAPI) ClusterReplicationTLS(params martini.Params, r render.Render, req *http.Request) {
+	clusterReplicationTLS, err := inst.ReadClusterReplicationTLS(params["clusterName"])
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	r.JSON(200, clusterReplicationTLS)
+}
+
 // SetClusterAlias will change an alias for a given clustername
 func (this *HttpAPI) SetClusterAlias(params martini.Params, r render.Render, req *http.Request, user auth.User) {
 	if !isAuthorizedForAction(req, user) {
@@ -1395,6 +1857,21 @@ func (this *HttpAPI) ResetHostnameResolveCache(params martini.Params, r render.R
 	r.JSON(200, &APIResponse{Code: OK, Message: "Hostname cache cleared"})
 }
 
+// SubmitMastersToKVStores rewrites all clusters' (or given cluster's) masters and replicas into configured key-value stores
+func (this *HttpAPI) SubmitMastersToKVStores(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	kvPairs, submittedCount, err := logic.SubmitMastersToKVStores(params["clusterName"], true)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Submitted %d entries", submittedCount), Details: kvPairs})
+}
+
 // SubmitPoolInstances (re-)applies the list of hostnames for a given pool
 func (this *HttpAPI) SubmitPoolInstances(params martini.Params, r render.Render, req *http.Request, user auth.User) {
 	if !isAuthorizedForAction(req, user) {
@@ -1653,6 +2130,17 @@ func (this *HttpAPI) AgentMySQLStart(params martini.Params, r render.Render, req
 	r.JSON(200, output)
 }
 
+// newCustomCommandRequest builds a custom command request out of the "command" param and repeated "arg" query params
+func newCustomCommandRequest(params martini.Params, req *http.Request, user auth.User) *agent.CustomCommandRequest {
+	return &agent.CustomCommandRequest{
+		Command:     params["command"],
+		Arguments:   req.URL.Query()["arg"],
+		RequestedBy: getUserId(req, user),
+		CheckRoles:  true,
+	}
+}
+
+// AgentCustomCommand executes a custom command on a single agent. Arguments are given as repeated "arg" query params.
 func (this *HttpAPI) AgentCustomCommand(params martini.Params, r render.Render, req *http.Request, user auth.User) {
 	if !isAuthorizedForAction(req, user) {
 		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
@@ -1663,14 +2151,89 @@ func (this *HttpAPI) AgentCustomCommand(params martini.Params, r render.Render,
 		return
 	}
 
-	output, err := agent.CustomCommand(params["host"], params["cmd"])
+	result, err := agent.ExecuteCustomCommand(params["host"], newCustomCommandRequest(params, req, user))
 
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err), Details: result})
+		return
+	}
+
+	r.JSON(200, result.Output)
+}
+
+// agentCustomCommandOnHosts executes a custom command on given agents and responds with aggregated results
+func (this *HttpAPI) agentCustomCommandOnHosts(hostnames []string, params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	result, err := agent.ExecuteCustomCommandOnHosts(hostnames, newCustomCommandRequest(params, req, user))
 	if err != nil {
 		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
 		return
 	}
 
-	r.JSON(200, output)
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Custom command %s: %d hosts, %d successful, %d failed", result.Command, result.CountHosts, result.CountSuccessful, result.CountFailed), Details: result})
+}
+
+// AgentCustomCommandCluster executes a custom command on all agents of a given cluster
+func (this *HttpAPI) AgentCustomCommandCluster(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	if !config.Config.ServeAgentsHttp {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Agents not served"})
+		return
+	}
+	clusterName, err := inst.ReadClusterNameByAlias(params["clusterName"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+	hostnames, err := agent.ReadClusterAgentsHostnames(clusterName)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	this.agentCustomCommandOnHosts(hostnames, params, r, req, user)
+}
+
+// AgentCustomCommandPool executes a custom command on all agents of a given pool
+func (this *HttpAPI) AgentCustomCommandPool(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	if !config.Config.ServeAgentsHttp {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Agents not served"})
+		return
+	}
+	hostnames, err := agent.ReadPoolAgentsHostnames(params["pool"])
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	this.agentCustomCommandOnHosts(hostnames, params, r, req, user)
+}
+
+// AgentCustomCommandAudit lists audited custom commands, optionally filtered by host
+func (this *HttpAPI) AgentCustomCommandAudit(params martini.Params, r render.Render, req *http.Request) {
+	if !config.Config.ServeAgentsHttp {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Agents not served"})
+		return
+	}
+	page, err := strconv.Atoi(params["page"])
+	if err != nil || page < 0 {
+		page = 0
+	}
+
+	audits, err := agent.ReadCustomCommandAudit(params["host"], page)
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	r.JSON(200, audits)
 }
 
 // AgentSeed completely seeds a host with another host's snapshots. This is a complex operation
@@ -1685,7 +2248,11 @@ func (this *HttpAPI) AgentSeed(params martini.Params, r render.Render, req *http
 		return
 	}
 
-	output, err := agent.Seed(params["targetHost"], params["sourceHost"])
+	seedMethod := agent.SeedMethod(params["seedMethod"])
+	if seedMethod == "" {
+		seedMethod = agent.SeedMethodLVM
+	}
+	output, err := agent.Seed(params["targetHost"], params["sourceHost"], seedMethod)
 
 	if err != nil {
 		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
@@ -1824,6 +2391,32 @@ func (this *HttpAPI) AbortSeed(params martini.Params, r render.Render, req *http
 	r.JSON(200, err == nil)
 }
 
+// AgentResumeSeed continues a failed or aborted seed from where it stopped
+func (this *HttpAPI) AgentResumeSeed(params martini.Params, r render.Render, req *http.Request, user auth.User) {
+	if !isAuthorizedForAction(req, user) {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
+		return
+	}
+	if !config.Config.ServeAgentsHttp {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: "Agents not served"})
+		return
+	}
+
+	seedId, err := strconv.ParseInt(params["seedId"], 10, 0)
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+	output, err := agent.ResumeSeed(seedId)
+
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err)})
+		return
+	}
+
+	r.JSON(200, output)
+}
+
 // Headers is a self-test call which returns HTTP headers
 func (this *HttpAPI) Headers(params martini.Params, r render.Render, req *http.Request) {
 	r.JSON(200, req.Header)
@@ -1898,17 +2491,26 @@ func (this *HttpAPI) Reelect(params martini.Params, r render.Render, req *http.R
 
 }
 
-// ReloadConfiguration reloads confiug settings (not all of which will apply after change)
+// ReloadConfiguration reloads confiug settings. Reloading is refused when the files are invalid, or when a setting
+// which only applies on startup has changed. Details list the changed settings, secrets redacted.
 func (this *HttpAPI) ReloadConfiguration(params martini.Params, r render.Render, req *http.Request, user auth.User) {
 	if !isAuthorizedForAction(req, user) {
 		r.JSON(200, &APIResponse{Code: ERROR, Message: "Unauthorized"})
 		return
 	}
-	config.Reload()
-	inst.AuditOperation("reload-configuration", nil, "Triggered via API")
+	changes, err := logic.ReloadConfiguration("API")
+	if err != nil {
+		r.JSON(200, &APIResponse{Code: ERROR, Message: fmt.Sprintf("%+v", err), Details: changes})
+		return
+	}
+
+	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Config reloaded: %d changes", len(changes)), Details: changes})
 
-	r.JSON(200, &APIResponse{Code: OK, Message: fmt.Sprintf("Config reloaded")})
+}
 
+// Configuration returns the effective configuration, passwords, secrets and tokens redacted
+func (this *HttpAPI) Configuration(params martini.Params, r render.Render, req *http.Request) {
+	r.JSON(200, config.RedactedConfiguration())
 }
 
 // ReplicationAnalysis retuens list of issues
@@ -2246,11 +2848,22 @@ func (this *HttpAPI) RegisterRequests(m *martini.ClassicMartini) {
 	// Replication, general:
 	m.Get("/api/enable-gtid/:host/:port", this.EnableGTID)
 	m.Get("/api/disable-gtid/:host/:port", this.DisableGTID)
+	m.Get("/api/enable-replication-tls/:host/:port", this.EnableReplicationTLS)
+	m.Get("/api/disable-replication-tls/:host/:port", this.DisableReplicationTLS)
+	m.Post("/api/rotate-replication-credentials/:clusterName", this.RotateReplicationCredentials)
 	m.Get("/api/skip-query/:host/:port", this.SkipQuery)
 	m.Get("/api/start-slave/:host/:port", this.StartSlave)
 	m.Get("/api/restart-slave/:host/:port", this.RestartSlave)
 	m.Get("/api/stop-slave/:host/:port", this.StopSlave)
 	m.Get("/api/stop-slave-nice/:host/:port", this.StopSlaveNicely)
+	m.Get("/api/start-slave-channel/:host/:port/:channel", this.StartSlaveChannel)
+	m.Get("/api/stop-slave-channel/:host/:port/:channel", this.StopSlaveChannel)
+	m.Get("/api/repoint-channel/:host/:port/:channel/:belowHost/:belowPort", this.RepointChannel)
+	m.Get("/api/relocate-channel/:host/:port/:channel/:belowHost/:belowPort", this.RelocateBelowChannel)
+	m.Get("/api/move-up-channel/:host/:port/:channel", this.MoveUpChannel)
+	m.Get("/api/move-below-channel/:host/:port/:channel/:belowHost/:belowPort", this.MoveBelowChannel)
+	m.Get("/api/move-below-gtid-channel/:host/:port/:channel/:belowHost/:belowPort", this.MoveBelowGTIDChannel)
+	m.Get("/api/match-below-channel/:host/:port/:channel/:belowHost/:belowPort", this.MatchBelowChannel)
 	m.Get("/api/reset-slave/:host/:port", this.ResetSlave)
 	m.Get("/api/detach-slave/:host/:port", this.DetachSlave)
 	m.Get("/api/reattach-slave/:host/:port", this.ReattachSlave)
@@ -2259,6 +2872,7 @@ func (this *HttpAPI) RegisterRequests(m *martini.ClassicMartini) {
 	// Instance:
 	m.Get("/api/set-read-only/:host/:port", this.SetReadOnly)
 	m.Get("/api/set-writeable/:host/:port", this.SetWriteable)
+	m.Get("/api/fix-read-only/:host/:port", this.FixReadOnly)
 	m.Get("/api/kill-query/:host/:port/:process", this.KillQuery)
 
 	// Binary logs:
@@ -2279,12 +2893,21 @@ func (this *HttpAPI) RegisterRequests(m *martini.ClassicMartini) {
 
 	// Cluster
 	m.Get("/api/cluster/:clusterName", this.Cluster)
+	m.Get("/api/cluster/:clusterName/history", this.ClusterHistory)
+	m.Get("/api/cluster/:clusterName/diff", this.ClusterDiff)
+	m.Get("/api/topology/:clusterName", this.Topology)
 	m.Get("/api/cluster/alias/:clusterAlias", this.ClusterByAlias)
 	m.Get("/api/cluster/instance/:host/:port", this.ClusterByInstance)
+	m.Get("/api/replication-group/:host/:port", this.ReplicationGroup)
+	m.Get("/api/galera-cluster/:host/:port", this.GaleraCluster)
 	m.Get("/api/cluster-info/:clusterName", this.ClusterInfo)
 	m.Get("/api/cluster-info/alias/:clusterAlias", this.ClusterInfoByAlias)
 	m.Get("/api/cluster-osc-slaves/:clusterName", this.ClusterOSCSlaves)
+	m.Get("/api/cluster-replication-tls/:clusterName", this.ClusterReplicationTLS)
 	m.Get("/api/set-cluster-alias/:clusterName", this.SetClusterAlias)
+	m.Get("/api/cluster-locks/:clusterName", this.ClusterLocks)
+	m.Get("/api/end-cluster-lock/:clusterName", this.EndClusterLock)
+	m.Get("/api/pseudo-gtid-injection-status/:clusterName", this.PseudoGTIDInjectionStatus)
 	m.Get("/api/clusters", this.Clusters)
 	m.Get("/api/clusters-info", this.ClustersInfo)
 
@@ -2341,15 +2964,20 @@ func (this *HttpAPI) RegisterRequests(m *martini.ClassicMartini) {
 
 	// Meta
 	m.Get("/api/maintenance", this.Maintenance)
+	m.Get("/api/cluster-locks", this.ClusterLocks)
+	m.Get("/api/pseudo-gtid-injection-status", this.PseudoGTIDInjectionStatus)
 	m.Get("/api/headers", this.Headers)
 	m.Get("/api/health", this.Health)
 	m.Get("/api/lb-check", this.LBCheck)
 	m.Get("/api/grab-election", this.GrabElection)
 	m.Get("/api/reelect", this.Reelect)
 	m.Get("/api/reload-configuration", this.ReloadConfiguration)
+	m.Get("/api/configuration", this.Configuration)
 	m.Get("/api/reload-cluster-alias", this.ReloadClusterAlias)
 	m.Get("/api/hostname-resolve-cache", this.HostnameResolveCache)
 	m.Get("/api/reset-hostname-resolve-cache", this.ResetHostnameResolveCache)
+	m.Get("/api/submit-masters-to-kv-stores", this.SubmitMastersToKVStores)
+	m.Get("/api/submit-masters-to-kv-stores/:clusterName", this.SubmitMastersToKVStores)
 
 	// Agents
 	m.Get("/api/agents", this.Agents)
@@ -2361,12 +2989,19 @@ func (this *HttpAPI) RegisterRequests(m *martini.ClassicMartini) {
 	m.Get("/api/agent-mysql-stop/:host", this.AgentMySQLStop)
 	m.Get("/api/agent-mysql-start/:host", this.AgentMySQLStart)
 	m.Get("/api/agent-seed/:targetHost/:sourceHost", this.AgentSeed)
+	m.Get("/api/agent-seed/:targetHost/:sourceHost/:seedMethod", this.AgentSeed)
 	m.Get("/api/agent-active-seeds/:host", this.AgentActiveSeeds)
 	m.Get("/api/agent-recent-seeds/:host", this.AgentRecentSeeds)
 	m.Get("/api/agent-seed-details/:seedId", this.AgentSeedDetails)
 	m.Get("/api/agent-seed-states/:seedId", this.AgentSeedStates)
 	m.Get("/api/agent-abort-seed/:seedId", this.AbortSeed)
+	m.Get("/api/agent-resume-seed/:seedId", this.AgentResumeSeed)
 	m.Get("/api/agent-custom-command/:host/:command", this.AgentCustomCommand)
+	m.Get("/api/agent-custom-command-cluster/:clusterName/:command", this.AgentCustomCommandCluster)
+	m.Get("/api/agent-custom-command-pool/:pool/:command", this.AgentCustomCommandPool)
+	m.Get("/api/agent-custom-command-audit", this.AgentCustomCommandAudit)
+	m.Get("/api/agent-custom-command-audit/:host", this.AgentCustomCommandAudit)
+	m.Get("/api/agent-custom-command-audit/:host/:page", this.AgentCustomCommandAudit)
 	m.Get("/api/seeds", this.Seeds)
 
 	// Configurable status check endpoint
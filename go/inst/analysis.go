@@ -22,6 +22,7 @@ import (
 
 type AnalysisCode string
 type StructureAnalysisCode string
+type AgentAnalysisCode string
 
 const (
 	NoProblem                                             AnalysisCode = "NoProblem"
@@ -50,6 +51,16 @@ const (
 	AllIntermediateMasterSlavesNotReplicating                          = "AllIntermediateMasterSlavesNotReplicating"
 	FirstTierSlaveFailingToConnectToMaster                             = "FirstTierSlaveFailingToConnectToMaster"
 	BinlogServerFailingToConnectToMaster                               = "BinlogServerFailingToConnectToMaster"
+	MultipleWritersInCluster                                           = "MultipleWritersInCluster"
+	ReadOnlyMaster                                                     = "ReadOnlyMaster"
+	WritableSlave                                                      = "WritableSlave"
+	ReplicationGroupMemberUnreachable                                  = "ReplicationGroupMemberUnreachable"
+	ReplicationGroupMemberError                                        = "ReplicationGroupMemberError"
+	ReplicationGroupLostQuorum                                         = "ReplicationGroupLostQuorum"
+	ReplicationGroupPrimaryChanged                                     = "ReplicationGroupPrimaryChanged"
+	UnreachableGaleraNode                                              = "UnreachableGaleraNode"
+	GaleraNonPrimaryComponent                                          = "GaleraNonPrimaryComponent"
+	GaleraNodeDesynced                                                 = "GaleraNodeDesynced"
 )
 
 const (
@@ -59,6 +70,45 @@ const (
 	MultipleMajorVersionsLoggingSlaves                                   = "MultipleMajorVersionsLoggingSlaves"
 )
 
+const (
+	DatadirDiskAlmostFull      AgentAnalysisCode = "DatadirDiskAlmostFull"
+	MySQLNotRunningOnAgentHost                   = "MySQLNotRunningOnAgentHost"
+	ErrorLogCrashMarker                          = "ErrorLogCrashMarker"
+)
+
+// builtinAnalysisCodes lists all analysis codes orchestrator itself reports. Custom analysis rules may not reuse them.
+var builtinAnalysisCodes = map[string]bool{
+	string(NoProblem): true, DeadMasterWithoutSlaves: true, DeadMaster: true, DeadMasterAndSlaves: true,
+	DeadMasterAndSomeSlaves: true, UnreachableMasterWithStaleSlaves: true, UnreachableMaster: true,
+	MasterSingleSlaveNotReplicating: true, MasterSingleSlaveDead: true, AllMasterSlavesNotReplicating: true,
+	AllMasterSlavesNotReplicatingOrDead: true, AllMasterSlavesStale: true, MasterWithoutSlaves: true,
+	DeadCoMaster: true, DeadCoMasterAndSomeSlaves: true, UnreachableCoMaster: true, AllCoMasterSlavesNotReplicating: true,
+	DeadIntermediateMaster: true, DeadIntermediateMasterWithSingleSlave: true,
+	DeadIntermediateMasterWithSingleSlaveFailingToConnect: true, DeadIntermediateMasterAndSomeSlaves: true,
+	UnreachableIntermediateMaster: true, AllIntermediateMasterSlavesFailingToConnectOrDead: true,
+	AllIntermediateMasterSlavesNotReplicating: true, FirstTierSlaveFailingToConnectToMaster: true,
+	BinlogServerFailingToConnectToMaster: true, MultipleWritersInCluster: true, ReadOnlyMaster: true,
+	WritableSlave: true, ReplicationGroupMemberUnreachable: true, ReplicationGroupMemberError: true,
+	ReplicationGroupLostQuorum: true, ReplicationGroupPrimaryChanged: true, UnreachableGaleraNode: true,
+	GaleraNonPrimaryComponent: true, GaleraNodeDesynced: true,
+	string(StatementAndMixedLoggingSlavesStructureWarning): true, StatementAndRowLoggingSlavesStructureWarning: true,
+	MixedAndRowLoggingSlavesStructureWarning: true, MultipleMajorVersionsLoggingSlaves: true,
+	string(DatadirDiskAlmostFull): true, MySQLNotRunningOnAgentHost: true, ErrorLogCrashMarker: true,
+}
+
+// AgentAnalysis is a detection made on the inventory reported by the orchestrator-agent on the instance's host
+type AgentAnalysis struct {
+	Code        AgentAnalysisCode
+	Description string
+}
+
+// CustomAnalysis is a detection made by a user defined rule (see CustomAnalysisRules)
+type CustomAnalysis struct {
+	Code        AnalysisCode
+	Description string
+	Severity    string
+}
+
 // ReplicationAnalysis notes analysis on replication chain status, per instance
 type ReplicationAnalysis struct {
 	AnalyzedInstanceKey                     InstanceKey
@@ -66,6 +116,7 @@ type ReplicationAnalysis struct {
 	ClusterDetails                          ClusterInfo
 	IsMaster                                bool
 	IsCoMaster                              bool
+	IsClusterMaster                         bool
 	LastCheckValid                          bool
 	CountSlaves                             uint
 	CountValidSlaves                        uint
@@ -78,10 +129,16 @@ type ReplicationAnalysis struct {
 	Analysis                                AnalysisCode
 	Description                             string
 	StructureAnalysis                       []StructureAnalysisCode
+	CustomAnalysis                          []CustomAnalysis
+	AgentAnalysis                           []AgentAnalysis
 	IsDowntimed                             bool
 	DowntimeEndTimestamp                    string
 	DowntimeRemainingSeconds                int
 	IsBinlogServer                          bool
+	IsReadOnly                              bool
+	IsClusterWriter                         bool
+	CountClusterWriters                     uint
+	IsRecoveryInProgress                    bool
 	PseudoGTIDImmediateTopology             bool
 	OracleGTIDImmediateTopology             bool
 	MariaDBGTIDImmediateTopology            bool
@@ -90,6 +147,25 @@ type ReplicationAnalysis struct {
 	CountMixedBasedLoggingSlaves            uint
 	CountRowBasedLoggingSlaves              uint
 	CountDistinctMajorVersionsLoggingSlaves uint
+	HasAgent                                bool
+	AgentLastSeenValid                      bool
+	AgentMySQLRunning                       bool
+	AgentMySQLStatusValid                   bool
+	AgentDatadirDiskUsedPercent             float64
+	AgentDatadirDiskFree                    int64
+	AgentErrorLogCrashMarker                string
+
+	IsReplicationGroupMember                   bool
+	ReplicationGroupName                       string
+	ReplicationGroupMemberState                string
+	ReplicationGroupMemberRole                 string
+	CountReplicationGroupMembers               uint // as seen by the analyzed member
+	CountReachableReplicationGroupMembers      uint // as seen by the analyzed member
+	IsReplicationGroupMemberUnreachableByPeers bool
+	ReplicationGroupPrimaryRecentlyChanged     bool
+	IsGaleraNode                               bool
+	GaleraClusterStatus                        string
+	GaleraLocalStateComment                    string
 }
 
 type ReplicationAnalysisChangelog struct {
@@ -112,5 +188,24 @@ func (this *ReplicationAnalysis) AnalysisString() string {
 	for _, structureAnalysis := range this.StructureAnalysis {
 		result = append(result, string(structureAnalysis))
 	}
+	for _, customAnalysis := range this.CustomAnalysis {
+		result = append(result, string(customAnalysis.Code))
+	}
+	for _, agentAnalysis := range this.AgentAnalysis {
+		result = append(result, string(agentAnalysis.Code))
+	}
 	return strings.Join(result, ", ")
 }
+
+// auditedAnalysis returns the analysis as written to the analysis changelog: the analysis code, followed
+// by custom and agent analysis codes, if any, delimited by "+"
+func (this *ReplicationAnalysis) auditedAnalysis() AnalysisCode {
+	result := []string{string(this.Analysis)}
+	for _, customAnalysis := range this.CustomAnalysis {
+		result = append(result, string(customAnalysis.Code))
+	}
+	for _, agentAnalysis := range this.AgentAnalysis {
+		result = append(result, string(agentAnalysis.Code))
+	}
+	return AnalysisCode(strings.Join(result, "+"))
+}
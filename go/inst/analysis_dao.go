@@ -17,7 +17,6 @@
 package inst
 
 import (
-	"fmt"
 	"github.com/outbrain/golib/log"
 	"github.com/outbrain/golib/sqlutils"
 	"github.com/outbrain/orchestrator/go/config"
@@ -25,6 +24,9 @@ import (
 	"github.com/pmylund/go-cache"
 	"github.com/rcrowley/go-metrics"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -34,383 +36,304 @@ var analysisChangeWriteCounter = metrics.NewCounter()
 func init() {
 	metrics.Register("analysis.change.write.attempt", analysisChangeWriteAttemptCounter)
 	metrics.Register("analysis.change.write", analysisChangeWriteCounter)
+	config.OnChange(func(changes config.ConfigurationChanges) {
+		if changes.HasField("RecoveryPollSeconds") {
+			recentInstantAnalysisMutex.Lock()
+			defer recentInstantAnalysisMutex.Unlock()
+			recentInstantAnalysis = cache.New(time.Duration(config.Config.RecoveryPollSeconds*2)*time.Second, time.Second)
+		}
+	})
 }
 
+var recentInstantAnalysisMutex sync.RWMutex
 var recentInstantAnalysis = cache.New(time.Duration(config.Config.RecoveryPollSeconds*2)*time.Second, time.Second)
 
-// GetReplicationAnalysis will check for replication problems (dead master; unreachable master; etc)
-func GetReplicationAnalysis(clusterName string, includeDowntimed bool, auditAnalysis bool) ([]ReplicationAnalysis, error) {
-	result := []ReplicationAnalysis{}
+// getRecentInstantAnalysis returns the cache of recently written analysis, which is replaced upon RecoveryPollSeconds change
+func getRecentInstantAnalysis() *cache.Cache {
+	recentInstantAnalysisMutex.RLock()
+	defer recentInstantAnalysisMutex.RUnlock()
+	return recentInstantAnalysis
+}
 
-	args := sqlutils.Args(config.Config.InstancePollSeconds, clusterName)
-	analysisQueryReductionClause := ``
-	if config.Config.ReduceReplicationAnalysisCount {
-		analysisQueryReductionClause = `
-			HAVING
-				(MIN(
-		        		master_instance.last_checked <= master_instance.last_seen
-		        		AND master_instance.last_attempted_check <= master_instance.last_seen + INTERVAL (2 * ?) SECOND
-		        	) IS TRUE /* AS is_last_check_valid */) = 0
-				OR (IFNULL(SUM(slave_instance.last_checked <= slave_instance.last_seen
-		                    AND slave_instance.slave_io_running = 0
-		                    AND slave_instance.last_io_error RLIKE 'error (connecting|reconnecting) to master'
-		                    AND slave_instance.slave_sql_running = 1),
-		                0) /* AS count_slaves_failing_to_connect_to_master */ > 0)
-				OR (IFNULL(SUM(slave_instance.last_checked <= slave_instance.last_seen),
-		                0) /* AS count_valid_slaves */ < COUNT(slave_instance.server_id) /* AS count_slaves */)
-				OR (IFNULL(SUM(slave_instance.last_checked <= slave_instance.last_seen
-		                    AND slave_instance.slave_io_running != 0
-		                    AND slave_instance.slave_sql_running != 0),
-		                0) /* AS count_valid_replicating_slaves */ < COUNT(slave_instance.server_id) /* AS count_slaves */)
-				OR (MIN(
-		            master_instance.slave_sql_running = 1
-		            AND master_instance.slave_io_running = 0
-		            AND master_instance.last_io_error RLIKE 'error (connecting|reconnecting) to master'
-		          ) /* AS is_failing_to_connect_to_master */)
-				OR (COUNT(slave_instance.server_id) /* AS count_slaves */ > 0)
-			`
-		args = append(args, config.Config.InstancePollSeconds)
+// readClustersInRecovery returns clusters (and failed and successor instances) of incomplete recoveries, as well as
+// locked clusters
+func readClustersInRecovery() (clusters map[string]bool, instanceKeys map[InstanceKey]bool, err error) {
+	clusters = make(map[string]bool)
+	instanceKeys = make(map[InstanceKey]bool)
+	query := `
+		select
+			hostname,
+			port,
+			cluster_name,
+			ifnull(successor_hostname, '') as successor_hostname,
+			ifnull(successor_port, 0) as successor_port
+		from
+			topology_recovery
+		where
+			in_active_period = 1
+			and end_recovery is null
+		`
+	err = db.QueryOrchestratorRowsMap(query, func(m sqlutils.RowMap) error {
+		clusters[m.GetString("cluster_name")] = true
+		instanceKeys[InstanceKey{Hostname: m.GetString("hostname"), Port: m.GetInt("port")}] = true
+		instanceKeys[InstanceKey{Hostname: m.GetString("successor_hostname"), Port: m.GetInt("successor_port")}] = true
+		return nil
+	})
+	if err != nil {
+		return clusters, instanceKeys, log.Errore(err)
 	}
-	// "OR count_slaves > 0" above is a recent addition, which, granted, makes some previous conditions redundant.
-	// It gives more output, and more "NoProblem" messages that I am now interested in for purpose of auditing in database_instance_analysis_changelog
-	query := fmt.Sprintf(`
-		    SELECT
-		        master_instance.hostname,
-		        master_instance.port,
-		        MIN(master_instance.master_host) AS master_host,
-		        MIN(master_instance.master_port) AS master_port,
-		        MIN(master_instance.cluster_name) AS cluster_name,
-		        MIN(IFNULL(cluster_alias.alias, master_instance.cluster_name)) AS cluster_alias,
-		        MIN(
-		        		master_instance.last_checked <= master_instance.last_seen
-		        		AND master_instance.last_attempted_check <= master_instance.last_seen + INTERVAL (2 * ?) SECOND
-		        	) IS TRUE AS is_last_check_valid,
-		        MIN(master_instance.master_host IN ('' , '_')
-		            OR master_instance.master_port = 0
-								OR left(master_instance.master_host, 2) = '//') AS is_master,
-		        MIN(master_instance.is_co_master) AS is_co_master,
-		        MIN(CONCAT(master_instance.hostname,
-		                ':',
-		                master_instance.port) = master_instance.cluster_name) AS is_cluster_master,
-		        COUNT(slave_instance.server_id) AS count_slaves,
-		        IFNULL(SUM(slave_instance.last_checked <= slave_instance.last_seen),
-		                0) AS count_valid_slaves,
-		        IFNULL(SUM(slave_instance.last_checked <= slave_instance.last_seen
-		                    AND slave_instance.slave_io_running != 0
-		                    AND slave_instance.slave_sql_running != 0),
-		                0) AS count_valid_replicating_slaves,
-		        IFNULL(SUM(slave_instance.last_checked <= slave_instance.last_seen
-		                    AND slave_instance.slave_io_running = 0
-		                    AND slave_instance.last_io_error RLIKE 'error (connecting|reconnecting) to master'
-		                    AND slave_instance.slave_sql_running = 1),
-		                0) AS count_slaves_failing_to_connect_to_master,
-						IFNULL(SUM(
-									current_relay_log_file=prev_relay_log_file
-									and current_relay_log_pos=prev_relay_log_pos
-									and current_seen != prev_seen),
-								0) AS count_stale_slaves,
-		        MIN(master_instance.replication_depth) AS replication_depth,
-		        GROUP_CONCAT(slave_instance.Hostname, ':', slave_instance.Port) as slave_hosts,
-		        MIN(
-		            master_instance.slave_sql_running = 1
-		            AND master_instance.slave_io_running = 0
-		            AND master_instance.last_io_error RLIKE 'error (connecting|reconnecting) to master'
-		          ) AS is_failing_to_connect_to_master,
-		        MIN(
-				    		database_instance_downtime.downtime_active IS NULL
-				    		OR database_instance_downtime.end_timestamp < NOW()
-			    		) IS FALSE AS is_downtimed,
-			    	MIN(
-				    		IFNULL(database_instance_downtime.end_timestamp, '')
-				    	) AS downtime_end_timestamp,
-			    	MIN(
-				    		IFNULL(TIMESTAMPDIFF(SECOND, NOW(), database_instance_downtime.end_timestamp), 0)
-				    	) AS downtime_remaining_seconds,
-			    	MIN(
-				    		master_instance.binlog_server
-				    	) AS is_binlog_server,
-			    	MIN(
-				    		master_instance.pseudo_gtid
-				    	) AS is_pseudo_gtid,
-			    	MIN(
-				    		master_instance.supports_oracle_gtid
-				    	) AS supports_oracle_gtid,
-			    	SUM(
-				    		slave_instance.oracle_gtid
-				    	) AS count_oracle_gtid_slaves,
-			      IFNULL(SUM(slave_instance.last_checked <= slave_instance.last_seen
-	              AND slave_instance.oracle_gtid != 0),
-              0) AS count_valid_oracle_gtid_slaves,
-			    	SUM(
-				    		slave_instance.binlog_server
-				    	) AS count_binlog_server_slaves,
-		        IFNULL(SUM(slave_instance.last_checked <= slave_instance.last_seen
-                  AND slave_instance.binlog_server != 0),
-              0) AS count_valid_binlog_server_slaves,
-			    	MIN(
-				    		master_instance.mariadb_gtid
-				    	) AS is_mariadb_gtid,
-			    	SUM(
-				    		slave_instance.mariadb_gtid
-				    	) AS count_mariadb_gtid_slaves,
-		        IFNULL(SUM(slave_instance.last_checked <= slave_instance.last_seen
-                  AND slave_instance.mariadb_gtid != 0),
-              0) AS count_valid_mariadb_gtid_slaves,
-						IFNULL(SUM(slave_instance.log_bin
-							  AND slave_instance.log_slave_updates
-								AND slave_instance.binlog_format = 'STATEMENT'),
-              0) AS count_statement_based_loggin_slaves,
-						IFNULL(SUM(slave_instance.log_bin
-								AND slave_instance.log_slave_updates
-								AND slave_instance.binlog_format = 'MIXED'),
-              0) AS count_mixed_based_loggin_slaves,
-						IFNULL(SUM(slave_instance.log_bin
-								AND slave_instance.log_slave_updates
-								AND slave_instance.binlog_format = 'ROW'),
-              0) AS count_row_based_loggin_slaves,
-						COUNT(DISTINCT IF(
-							slave_instance.log_bin AND slave_instance.log_slave_updates,
-								substring_index(slave_instance.version, '.', 2),
-								NULL)
-						) AS count_distinct_logging_major_versions
-		    FROM
-		        database_instance master_instance
-		            LEFT JOIN
-		        hostname_resolve ON (master_instance.hostname = hostname_resolve.hostname)
-		            LEFT JOIN
-		        database_instance slave_instance ON (COALESCE(hostname_resolve.resolved_hostname,
-		                master_instance.hostname) = slave_instance.master_host
-		            	AND master_instance.port = slave_instance.master_port)
-		            LEFT JOIN
-		        database_instance_maintenance ON (master_instance.hostname = database_instance_maintenance.hostname
-		        		AND master_instance.port = database_instance_maintenance.port
-		        		AND database_instance_maintenance.maintenance_active = 1)
-		            LEFT JOIN
-		        database_instance_downtime ON (master_instance.hostname = database_instance_downtime.hostname
-		        		AND master_instance.port = database_instance_downtime.port
-		        		AND database_instance_downtime.downtime_active = 1)
-		        	LEFT JOIN
-		        cluster_alias ON (cluster_alias.cluster_name = master_instance.cluster_name)
-						  LEFT JOIN
-						database_instance_recent_relaylog_history ON (
-								slave_instance.hostname = database_instance_recent_relaylog_history.hostname
-		        		AND slave_instance.port = database_instance_recent_relaylog_history.port)
-		    WHERE
-		    	database_instance_maintenance.database_instance_maintenance_id IS NULL
-		    	AND ? IN ('', master_instance.cluster_name)
-		    GROUP BY
-			    master_instance.hostname,
-			    master_instance.port
-			%s
-		    ORDER BY
-			    is_master DESC ,
-			    is_cluster_master DESC,
-			    count_slaves DESC
-	`, analysisQueryReductionClause)
-	err := db.QueryOrchestrator(query, args, func(m sqlutils.RowMap) error {
-		a := ReplicationAnalysis{Analysis: NoProblem}
+	clusterLocks, err := ReadActiveClusterLocks()
+	if err != nil {
+		return clusters, instanceKeys, err
+	}
+	for _, clusterLock := range clusterLocks {
+		clusters[clusterLock.ClusterName] = true
+	}
+	return clusters, instanceKeys, nil
+}
 
-		a.IsMaster = m.GetBool("is_master")
-		a.IsCoMaster = m.GetBool("is_co_master")
-		a.AnalyzedInstanceKey = InstanceKey{Hostname: m.GetString("hostname"), Port: m.GetInt("port")}
-		a.AnalyzedInstanceMasterKey = InstanceKey{Hostname: m.GetString("master_host"), Port: m.GetInt("master_port")}
-		a.ClusterDetails.ClusterName = m.GetString("cluster_name")
-		a.ClusterDetails.ClusterAlias = m.GetString("cluster_alias")
-		a.LastCheckValid = m.GetBool("is_last_check_valid")
-		a.CountSlaves = m.GetUint("count_slaves")
-		a.CountValidSlaves = m.GetUint("count_valid_slaves")
-		a.CountValidReplicatingSlaves = m.GetUint("count_valid_replicating_slaves")
-		a.CountSlavesFailingToConnectToMaster = m.GetUint("count_slaves_failing_to_connect_to_master")
-		a.CountStaleSlaves = m.GetUint("count_stale_slaves")
-		a.ReplicationDepth = m.GetUint("replication_depth")
-		a.IsFailingToConnectToMaster = m.GetBool("is_failing_to_connect_to_master")
-		a.IsDowntimed = m.GetBool("is_downtimed")
-		a.DowntimeEndTimestamp = m.GetString("downtime_end_timestamp")
-		a.DowntimeRemainingSeconds = m.GetInt("downtime_remaining_seconds")
-		a.IsBinlogServer = m.GetBool("is_binlog_server")
-		a.ClusterDetails.ReadRecoveryInfo()
+// readAnalysisTopology loads the raw facts of all known instances, on which replication analysis is based
+func readAnalysisTopology() (*AnalysisTopology, error) {
+	instances := [](*AnalysisInstance){}
+	query := `
+		select
+			database_instance.hostname,
+			database_instance.port,
+			database_instance.master_host,
+			database_instance.master_port,
+			database_instance.replication_channel,
+			database_instance.cluster_name,
+			ifnull(cluster_alias.alias, database_instance.cluster_name) as cluster_alias,
+			database_instance.version,
+			database_instance.read_only,
+			(database_instance.last_checked <= database_instance.last_seen) is true as is_last_check_valid,
+			(database_instance.last_attempted_check <= database_instance.last_seen + interval (2 * ?) second) is true as is_last_attempted_check_valid,
+			database_instance.is_co_master,
+			database_instance.replication_depth,
+			database_instance.slave_sql_running,
+			database_instance.slave_io_running,
+			database_instance.last_io_error,
+			database_instance.binlog_server,
+			database_instance.pseudo_gtid,
+			database_instance.oracle_gtid,
+			database_instance.mariadb_gtid,
+			database_instance.log_bin,
+			database_instance.log_slave_updates,
+			database_instance.binlog_format,
+			(
+				database_instance_recent_relaylog_history.current_relay_log_file = database_instance_recent_relaylog_history.prev_relay_log_file
+				and database_instance_recent_relaylog_history.current_relay_log_pos = database_instance_recent_relaylog_history.prev_relay_log_pos
+				and database_instance_recent_relaylog_history.current_seen != database_instance_recent_relaylog_history.prev_seen
+			) is true as is_stale,
+			database_instance_maintenance.database_instance_maintenance_id is not null as in_maintenance,
+			(database_instance_downtime.end_timestamp >= now()) is true as is_downtimed,
+			ifnull(database_instance_downtime.end_timestamp, '') as downtime_end_timestamp,
+			ifnull(timestampdiff(second, now(), database_instance_downtime.end_timestamp), 0) as downtime_remaining_seconds,
+			host_agent.hostname is not null as has_agent,
+			(host_agent.last_seen >= now() - interval (2 * ?) minute) is true as is_agent_last_seen_valid,
+			ifnull(host_agent.mysql_running, 0) as agent_mysql_running,
+			ifnull(host_agent.mysql_status_valid, 0) as agent_mysql_status_valid,
+			ifnull(host_agent.mysql_datadir_disk_used_percent, 0) as agent_datadir_disk_used_percent,
+			ifnull(host_agent.mysql_datadir_disk_free, 0) as agent_datadir_disk_free,
+			ifnull(host_agent.mysql_error_log_crash_marker, '') as agent_error_log_crash_marker,
+			database_instance.replication_group_name,
+			database_instance.replication_group_member_state,
+			database_instance.replication_group_member_role,
+			database_instance.replication_group_members,
+			(database_instance.replication_group_primary_changed_timestamp >= now() - interval (2 * ?) second) is true as is_replication_group_primary_recently_changed,
+			database_instance.galera_cluster_state_uuid,
+			database_instance.galera_cluster_status,
+			database_instance.galera_local_state_comment
+		from
+			database_instance
+			left join cluster_alias on (cluster_alias.cluster_name = database_instance.cluster_name)
+			left join database_instance_recent_relaylog_history on (
+				database_instance.hostname = database_instance_recent_relaylog_history.hostname
+				and database_instance.port = database_instance_recent_relaylog_history.port)
+			left join database_instance_maintenance on (
+				database_instance.hostname = database_instance_maintenance.hostname
+				and database_instance.port = database_instance_maintenance.port
+				and database_instance_maintenance.maintenance_active = 1)
+			left join database_instance_downtime on (
+				database_instance.hostname = database_instance_downtime.hostname
+				and database_instance.port = database_instance_downtime.port
+				and database_instance_downtime.downtime_active = 1)
+			left join host_agent on (
+				database_instance.hostname = host_agent.hostname
+				and ifnull(host_agent.mysql_port, 0) in (0, database_instance.port))
+		`
+	err := db.QueryOrchestrator(query, sqlutils.Args(config.Config.InstancePollSeconds, config.Config.AgentPollMinutes, config.Config.InstancePollSeconds), func(m sqlutils.RowMap) error {
+		instance := &AnalysisInstance{}
 
-		a.SlaveHosts = *NewInstanceKeyMap()
-		a.SlaveHosts.ReadCommaDelimitedList(m.GetString("slave_hosts"))
+		instance.Key = InstanceKey{Hostname: m.GetString("hostname"), Port: m.GetInt("port")}
+		instance.MasterKey = InstanceKey{Hostname: m.GetString("master_host"), Port: m.GetInt("master_port")}
+		instance.ReplicationChannel = m.GetString("replication_channel")
+		instance.ClusterName = m.GetString("cluster_name")
+		instance.ClusterAlias = m.GetString("cluster_alias")
+		instance.Version = m.GetString("version")
+		instance.ReadOnly = m.GetBool("read_only")
+		instance.LastCheckValid = m.GetBool("is_last_check_valid")
+		instance.LastAttemptedCheckValid = m.GetBool("is_last_attempted_check_valid")
+		instance.IsCoMaster = m.GetBool("is_co_master")
+		instance.ReplicationDepth = m.GetUint("replication_depth")
+		instance.Slave_SQL_Running = m.GetBool("slave_sql_running")
+		instance.Slave_IO_Running = m.GetBool("slave_io_running")
+		instance.LastIOError = m.GetString("last_io_error")
+		instance.IsBinlogServer = m.GetBool("binlog_server")
+		instance.UsingPseudoGTID = m.GetBool("pseudo_gtid")
+		instance.UsingOracleGTID = m.GetBool("oracle_gtid")
+		instance.UsingMariaDBGTID = m.GetBool("mariadb_gtid")
+		instance.LogBinEnabled = m.GetBool("log_bin")
+		instance.LogSlaveUpdatesEnabled = m.GetBool("log_slave_updates")
+		instance.Binlog_format = m.GetString("binlog_format")
+		instance.IsStale = m.GetBool("is_stale")
+		instance.InMaintenance = m.GetBool("in_maintenance")
+		instance.IsDowntimed = m.GetBool("is_downtimed")
+		instance.DowntimeEndTimestamp = m.GetString("downtime_end_timestamp")
+		instance.DowntimeRemainingSeconds = m.GetInt("downtime_remaining_seconds")
+		instance.HasAgent = m.GetBool("has_agent")
+		instance.AgentLastSeenValid = m.GetBool("is_agent_last_seen_valid")
+		instance.AgentMySQLRunning = m.GetBool("agent_mysql_running")
+		instance.AgentMySQLStatusValid = m.GetBool("agent_mysql_status_valid")
+		instance.AgentDatadirUsedPercent, _ = strconv.ParseFloat(m.GetString("agent_datadir_disk_used_percent"), 64)
+		instance.AgentDatadirDiskFree = m.GetInt64("agent_datadir_disk_free")
+		instance.AgentErrorLogCrashMarker = m.GetString("agent_error_log_crash_marker")
+		instance.ReplicationGroupName = m.GetString("replication_group_name")
+		instance.ReplicationGroupMemberState = m.GetString("replication_group_member_state")
+		instance.ReplicationGroupMemberRole = m.GetString("replication_group_member_role")
+		instance.ReplicationGroupMembers, _ = replicationGroupMembersFromJSON(m.GetString("replication_group_members"))
+		instance.ReplicationGroupPrimaryRecentlyChanged = m.GetBool("is_replication_group_primary_recently_changed")
+		instance.GaleraClusterStateUUID = m.GetString("galera_cluster_state_uuid")
+		instance.GaleraClusterStatus = m.GetString("galera_cluster_status")
+		instance.GaleraLocalStateComment = m.GetString("galera_local_state_comment")
 
-		countValidOracleGTIDSlaves := m.GetUint("count_valid_oracle_gtid_slaves")
-		a.OracleGTIDImmediateTopology = countValidOracleGTIDSlaves == a.CountValidSlaves && a.CountValidSlaves > 0
-		countValidMariaDBGTIDSlaves := m.GetUint("count_valid_mariadb_gtid_slaves")
-		a.MariaDBGTIDImmediateTopology = countValidMariaDBGTIDSlaves == a.CountValidSlaves && a.CountValidSlaves > 0
-		countValidBinlogServerSlaves := m.GetUint("count_valid_binlog_server_slaves")
-		a.BinlogServerImmediateTopology = countValidBinlogServerSlaves == a.CountValidSlaves && a.CountValidSlaves > 0
-		a.PseudoGTIDImmediateTopology = m.GetBool("is_pseudo_gtid")
+		instances = append(instances, instance)
+		return nil
+	})
+	if err != nil {
+		return nil, log.Errore(err)
+	}
+	replicationChannels, err := readAllReplicationChannels()
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		instance.ReplicationChannels = replicationChannels[instance.Key]
+	}
+	hostnameResolves, err := readAllHostnameResolves()
+	if err != nil {
+		return nil, err
+	}
+	clustersInRecovery, instancesInRecovery, err := readClustersInRecovery()
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		instance.RecoveryInProgress = clustersInRecovery[instance.ClusterName] || instancesInRecovery[instance.Key]
+	}
+	resolvedHostnames := make(map[string]string)
+	for _, hostnameResolve := range hostnameResolves {
+		resolvedHostnames[hostnameResolve.hostname] = hostnameResolve.resolvedHostname
+	}
+	return NewAnalysisTopology(instances, resolvedHostnames), nil
+}
 
-		a.CountStatementBasedLoggingSlaves = m.GetUint("count_statement_based_loggin_slaves")
-		a.CountMixedBasedLoggingSlaves = m.GetUint("count_mixed_based_loggin_slaves")
-		a.CountRowBasedLoggingSlaves = m.GetUint("count_row_based_loggin_slaves")
-		a.CountDistinctMajorVersionsLoggingSlaves = m.GetUint("count_distinct_logging_major_versions")
+// applyCustomAnalysisRules evaluates user defined analysis rules on given analysis entries
+func applyCustomAnalysisRules(clusterName string, analysisEntries []ReplicationAnalysis) error {
+	rules := getCustomAnalysisRules()
+	if len(rules) == 0 {
+		return nil
+	}
+	instances, err := readInstancesByCondition(`? IN ('', cluster_name)`, sqlutils.Args(clusterName), "")
+	if err != nil {
+		return err
+	}
+	instancesMap := make(map[InstanceKey](*Instance))
+	for _, instance := range instances {
+		instancesMap[instance.Key] = instance
+	}
+	for i := range analysisEntries {
+		a := &analysisEntries[i]
+		instance, found := instancesMap[a.AnalyzedInstanceKey]
+		if !found {
+			continue
+		}
+		a.CustomAnalysis = evaluateCustomAnalysisRules(rules, a, instance, instancesMap[a.AnalyzedInstanceMasterKey])
+	}
+	return nil
+}
+
+// hasRecentCustomAnalysis returns true when the last audited analysis of given instance included custom (or agent) analysis
+func hasRecentCustomAnalysis(instanceKey *InstanceKey) bool {
+	if lastWrittenAnalysis, found := getRecentInstantAnalysis().Get(instanceKey.DisplayString()); found {
+		return strings.Contains(string(lastWrittenAnalysis.(AnalysisCode)), "+")
+	}
+	return false
+}
+
+// readAnalysisTopologyFunc loads the topology analysis is based on. Tests point it at fixture topologies.
+var readAnalysisTopologyFunc = readAnalysisTopology
+
+// isReducibleAnalysis returns true when the analyzed instance is known to be uninteresting: a well behaving leaf
+func isReducibleAnalysis(a *ReplicationAnalysis) bool {
+	return a.Analysis == NoProblem && a.LastCheckValid && a.CountSlaves == 0 && !a.IsFailingToConnectToMaster && !a.IsReplicationGroupMember && !a.IsGaleraNode && len(a.CustomAnalysis) == 0 && len(a.AgentAnalysis) == 0 && !hasRecentCustomAnalysis(&a.AnalyzedInstanceKey)
+}
+
+// GetReplicationAnalysis will check for replication problems (dead master; unreachable master; etc)
+func GetReplicationAnalysis(clusterName string, includeDowntimed bool, auditAnalysis bool) ([]ReplicationAnalysis, error) {
+	result := []ReplicationAnalysis{}
 
-		if a.IsMaster && !a.LastCheckValid && a.CountSlaves == 0 {
-			a.Analysis = DeadMasterWithoutSlaves
-			a.Description = "Master cannot be reached by orchestrator and has no slave"
-			//
-		} else if a.IsMaster && !a.LastCheckValid && a.CountValidSlaves == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = DeadMaster
-			a.Description = "Master cannot be reached by orchestrator and none of its slaves is replicating"
-			//
-		} else if a.IsMaster && !a.LastCheckValid && a.CountSlaves > 0 && a.CountValidSlaves == 0 && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = DeadMasterAndSlaves
-			a.Description = "Master cannot be reached by orchestrator and none of its slaves is replicating"
-			//
-		} else if a.IsMaster && !a.LastCheckValid && a.CountValidSlaves < a.CountSlaves && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = DeadMasterAndSomeSlaves
-			a.Description = "Master cannot be reached by orchestrator; some of its slaves are unreachable and none of its reachable slaves is replicating"
-			//
-		} else if a.IsMaster && !a.LastCheckValid && a.CountStaleSlaves == a.CountSlaves && a.CountValidReplicatingSlaves > 0 {
-			a.Analysis = UnreachableMasterWithStaleSlaves
-			a.Description = "Master cannot be reached by orchestrator and has running yet stale slaves"
-			//
-		} else if a.IsMaster && !a.LastCheckValid && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves > 0 {
-			a.Analysis = UnreachableMaster
-			a.Description = "Master cannot be reached by orchestrator but it has replicating slaves; possibly a network/host issue"
-			//
-		} else if a.IsMaster && a.LastCheckValid && a.CountSlaves == 1 && a.CountValidSlaves == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = MasterSingleSlaveNotReplicating
-			a.Description = "Master is reachable but its single slave is not replicating"
-			//
-		} else if a.IsMaster && a.LastCheckValid && a.CountSlaves == 1 && a.CountValidSlaves == 0 {
-			a.Analysis = MasterSingleSlaveDead
-			a.Description = "Master is reachable but its single slave is dead"
-			//
-		} else if a.IsMaster && a.LastCheckValid && a.CountSlaves > 1 && a.CountValidSlaves == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = AllMasterSlavesNotReplicating
-			a.Description = "Master is reachable but none of its slaves is replicating"
-			//
-		} else if a.IsMaster && a.LastCheckValid && a.CountSlaves > 1 && a.CountValidSlaves < a.CountSlaves && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = AllMasterSlavesNotReplicatingOrDead
-			a.Description = "Master is reachable but none of its slaves is replicating"
-			//
-		} else if a.IsMaster && a.LastCheckValid && a.CountSlaves > 1 && a.CountStaleSlaves == a.CountSlaves && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves > 0 {
-			a.Analysis = AllMasterSlavesStale
-			a.Description = "Master is reachable but all of its slaves are stale, although attempting to replicate"
-			//
-		} else /* co-master */ if a.IsCoMaster && !a.LastCheckValid && a.CountSlaves > 0 && a.CountValidSlaves == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = DeadCoMaster
-			a.Description = "Co-master cannot be reached by orchestrator and none of its slaves is replicating"
-			//
-		} else if a.IsCoMaster && !a.LastCheckValid && a.CountSlaves > 0 && a.CountValidSlaves < a.CountSlaves && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = DeadCoMasterAndSomeSlaves
-			a.Description = "Co-master cannot be reached by orchestrator; some of its slaves are unreachable and none of its reachable slaves is replicating"
-			//
-		} else if a.IsCoMaster && !a.LastCheckValid && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves > 0 {
-			a.Analysis = UnreachableCoMaster
-			a.Description = "Co-master cannot be reached by orchestrator but it has replicating slaves; possibly a network/host issue"
-			//
-		} else if a.IsCoMaster && a.LastCheckValid && a.CountSlaves > 0 && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = AllCoMasterSlavesNotReplicating
-			a.Description = "Co-master is reachable but none of its slaves is replicating"
-			//
-		} else /* intermediate-master */ if !a.IsMaster && !a.LastCheckValid && a.CountSlaves == 1 && a.CountValidSlaves == a.CountSlaves && a.CountSlavesFailingToConnectToMaster == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = DeadIntermediateMasterWithSingleSlaveFailingToConnect
-			a.Description = "Intermediate master cannot be reached by orchestrator and its (single) slave is failing to connect"
-			//
-		} else /* intermediate-master */ if !a.IsMaster && !a.LastCheckValid && a.CountSlaves == 1 && a.CountValidSlaves == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = DeadIntermediateMasterWithSingleSlave
-			a.Description = "Intermediate master cannot be reached by orchestrator and its (single) slave is not replicating"
-			//
-		} else /* intermediate-master */ if !a.IsMaster && !a.LastCheckValid && a.CountSlaves > 1 && a.CountValidSlaves == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = DeadIntermediateMaster
-			a.Description = "Intermediate master cannot be reached by orchestrator and none of its slaves is replicating"
-			//
-		} else if !a.IsMaster && !a.LastCheckValid && a.CountValidSlaves < a.CountSlaves && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = DeadIntermediateMasterAndSomeSlaves
-			a.Description = "Intermediate master cannot be reached by orchestrator; some of its slaves are unreachable and none of its reachable slaves is replicating"
-			//
-		} else if !a.IsMaster && !a.LastCheckValid && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves > 0 {
-			a.Analysis = UnreachableIntermediateMaster
-			a.Description = "Intermediate master cannot be reached by orchestrator but it has replicating slaves; possibly a network/host issue"
-			//
-		} else if !a.IsMaster && a.LastCheckValid && a.CountSlaves > 1 && a.CountValidReplicatingSlaves == 0 &&
-			a.CountSlavesFailingToConnectToMaster > 0 && a.CountSlavesFailingToConnectToMaster == a.CountValidSlaves {
-			// All slaves are either failing to connect to master (and at least one of these have to exist)
-			// or completely dead.
-			// Must have at least two slaves to reach such conclusion -- do note that the intermediate master is still
-			// reachable to orchestrator, so we base our conclusion on slaves only at this point.
-			a.Analysis = AllIntermediateMasterSlavesFailingToConnectOrDead
-			a.Description = "Intermediate master is reachable but all of its slaves are failing to connect"
-			//
-		} else if !a.IsMaster && a.LastCheckValid && a.CountSlaves > 0 && a.CountValidReplicatingSlaves == 0 {
-			a.Analysis = AllIntermediateMasterSlavesNotReplicating
-			a.Description = "Intermediate master is reachable but none of its slaves is replicating"
-			//
-		} else if a.IsBinlogServer && a.IsFailingToConnectToMaster {
-			a.Analysis = BinlogServerFailingToConnectToMaster
-			a.Description = "Binlog server is unable to connect to its master"
-			//
-		} else if a.ReplicationDepth == 1 && a.IsFailingToConnectToMaster {
-			a.Analysis = FirstTierSlaveFailingToConnectToMaster
-			a.Description = "1st tier slave (directly replicating from topology master) is unable to connect to the master"
-			//
+	topology, err := readAnalysisTopologyFunc()
+	if err != nil {
+		return result, err
+	}
+	analysisEntries := AnalyzeTopology(topology, clusterName)
+	if err := applyCustomAnalysisRules(clusterName, analysisEntries); err != nil {
+		return result, log.Errore(err)
+	}
+	for _, a := range analysisEntries {
+		if config.Config.ReduceReplicationAnalysisCount && isReducibleAnalysis(&a) {
+			continue
 		}
-		//		 else if a.IsMaster && a.CountSlaves == 0 {
-		//			a.Analysis = MasterWithoutSlaves
-		//			a.Description = "Master has no slaves"
-		//		}
+		a.ClusterDetails.ReadRecoveryInfo()
 
 		appendAnalysis := func(analysis *ReplicationAnalysis) {
-			if a.Analysis == NoProblem && len(a.StructureAnalysis) == 0 {
+			if analysis.Analysis == NoProblem && len(analysis.StructureAnalysis) == 0 && len(analysis.CustomAnalysis) == 0 && len(analysis.AgentAnalysis) == 0 {
 				return
 			}
 			skipThisHost := false
 			for _, filter := range config.Config.RecoveryIgnoreHostnameFilters {
-				if matched, _ := regexp.MatchString(filter, a.AnalyzedInstanceKey.Hostname); matched {
+				if matched, _ := regexp.MatchString(filter, analysis.AnalyzedInstanceKey.Hostname); matched {
 					skipThisHost = true
 				}
 			}
-			if a.IsDowntimed && !includeDowntimed {
+			if analysis.IsDowntimed && !includeDowntimed {
 				skipThisHost = true
 			}
 			if !skipThisHost {
-				result = append(result, a)
-			}
-		}
-
-		{
-			// Moving on to structure analysis
-			// We also do structural checks. See if there's potential danger in promotions
-			if a.IsMaster && a.CountStatementBasedLoggingSlaves > 0 && a.CountMixedBasedLoggingSlaves > 0 {
-				a.StructureAnalysis = append(a.StructureAnalysis, StatementAndMixedLoggingSlavesStructureWarning)
-			}
-			if a.IsMaster && a.CountStatementBasedLoggingSlaves > 0 && a.CountRowBasedLoggingSlaves > 0 {
-				a.StructureAnalysis = append(a.StructureAnalysis, StatementAndRowLoggingSlavesStructureWarning)
-			}
-			if a.IsMaster && a.CountMixedBasedLoggingSlaves > 0 && a.CountRowBasedLoggingSlaves > 0 {
-				a.StructureAnalysis = append(a.StructureAnalysis, MixedAndRowLoggingSlavesStructureWarning)
-			}
-			if a.IsMaster && a.CountDistinctMajorVersionsLoggingSlaves > 1 {
-				a.StructureAnalysis = append(a.StructureAnalysis, MultipleMajorVersionsLoggingSlaves)
+				result = append(result, *analysis)
 			}
 		}
 		appendAnalysis(&a)
 
-		if a.CountSlaves > 0 && auditAnalysis {
+		if auditAnalysis && (a.CountSlaves > 0 || a.Analysis != NoProblem || len(a.CustomAnalysis) > 0 || len(a.AgentAnalysis) > 0 || hasRecentCustomAnalysis(&a.AnalyzedInstanceKey)) {
 			// Interesting enough for analysis
-			go auditInstanceAnalysisInChangelog(&a.AnalyzedInstanceKey, a.Analysis)
+			analyzedInstanceKey := a.AnalyzedInstanceKey
+			go auditInstanceAnalysisInChangelog(&analyzedInstanceKey, a.auditedAnalysis())
 		}
-		return nil
-	})
-
-	if err != nil {
-		log.Errore(err)
 	}
-	return result, err
+	return result, nil
 }
 
 // auditInstanceAnalysisInChangelog will write down an instance's analysis in the database_instance_analysis_changelog table.
 // To not repeat recurring analysis code, the database_instance_last_analysis table is used, so that only changes to
 // analysis codes are written.
 func auditInstanceAnalysisInChangelog(instanceKey *InstanceKey, analysisCode AnalysisCode) error {
-	if lastWrittenAnalysis, found := recentInstantAnalysis.Get(instanceKey.DisplayString()); found {
+	if lastWrittenAnalysis, found := getRecentInstantAnalysis().Get(instanceKey.DisplayString()); found {
 		if lastWrittenAnalysis == analysisCode {
 			// Surely nothing new.
 			// And let's expand the timeout
-			recentInstantAnalysis.Set(instanceKey.DisplayString(), analysisCode, cache.DefaultExpiration)
+			getRecentInstantAnalysis().Set(instanceKey.DisplayString(), analysisCode, cache.DefaultExpiration)
 			return nil
 		}
 	}
@@ -436,7 +359,7 @@ func auditInstanceAnalysisInChangelog(instanceKey *InstanceKey, analysisCode Ana
 	if err != nil {
 		return log.Errore(err)
 	}
-	recentInstantAnalysis.Set(instanceKey.DisplayString(), analysisCode, cache.DefaultExpiration)
+	getRecentInstantAnalysis().Set(instanceKey.DisplayString(), analysisCode, cache.DefaultExpiration)
 	lastAnalysisChanged := (rows > 0)
 
 	if !lastAnalysisChanged {
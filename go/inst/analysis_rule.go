@@ -0,0 +1,536 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+// Custom analysis rules are user defined detections, configured via CustomAnalysisRules. Each rule is a boolean
+// expression over fields of the analyzed instance ("Instance"), of its master ("Master") and of its replication
+// analysis ("Analysis"). Examples:
+//
+//   Instance.HasReplicationFilters && Instance.ClusterName =~ "^orders"
+//   !Analysis.IsMaster && !Instance.ReadOnly
+//   Instance.Binlog_format != Master.Binlog_format
+//
+// Supported operators are: ||, &&, !, ==, !=, <, <=, >, >=, =~ and !~ (regular expression match), as well as
+// parentheses. Literals are true, false, numbers and strings, either single or double quoted (no escaping).
+// A field which cannot be evaluated (e.g. a field of an unknown master) never satisfies a comparison.
+// Custom analysis is reported, audited and may execute processes; it never leads to recovery.
+
+const (
+	AnalysisRuleSeverityInfo    = "info"
+	AnalysisRuleSeverityWarning = "warning"
+	AnalysisRuleSeverityError   = "error"
+)
+
+type analysisRuleType int
+
+const (
+	analysisRuleBoolType analysisRuleType = iota
+	analysisRuleNumberType
+	analysisRuleStringType
+)
+
+func (this analysisRuleType) String() string {
+	switch this {
+	case analysisRuleBoolType:
+		return "boolean"
+	case analysisRuleNumberType:
+		return "number"
+	}
+	return "string"
+}
+
+// analysisRuleEnvironment is what rule expressions evaluate against
+type analysisRuleEnvironment struct {
+	Instance *Instance
+	Master   *Instance
+	Analysis *ReplicationAnalysis
+}
+
+type analysisRuleNode interface {
+	evaluate(environment *analysisRuleEnvironment) interface{}
+	valueType() analysisRuleType
+}
+
+type analysisRuleLiteral struct {
+	value interface{}
+	vType analysisRuleType
+}
+
+func (this *analysisRuleLiteral) evaluate(environment *analysisRuleEnvironment) interface{} {
+	return this.value
+}
+
+func (this *analysisRuleLiteral) valueType() analysisRuleType { return this.vType }
+
+type analysisRuleField struct {
+	path  []string
+	vType analysisRuleType
+}
+
+var analysisRuleNullInt64Type = reflect.TypeOf(sql.NullInt64{})
+var analysisRuleInstanceKeyType = reflect.TypeOf(InstanceKey{})
+var analysisRuleBinlogCoordinatesType = reflect.TypeOf(BinlogCoordinates{})
+
+// newAnalysisRuleField validates given field path against the environment and returns a field node
+func newAnalysisRuleField(name string) (*analysisRuleField, error) {
+	path := strings.Split(name, ".")
+	if len(path) < 2 {
+		return nil, fmt.Errorf("unknown field: %s; fields are expected to be of the form Instance.<field>, Master.<field> or Analysis.<field>", name)
+	}
+	fieldType := reflect.TypeOf(analysisRuleEnvironment{})
+	for _, fieldName := range path {
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("unknown field: %s", name)
+		}
+		structField, found := fieldType.FieldByName(fieldName)
+		if !found || structField.PkgPath != "" {
+			return nil, fmt.Errorf("unknown field: %s", name)
+		}
+		fieldType = structField.Type
+	}
+	field := &analysisRuleField{path: path}
+	switch fieldType {
+	case analysisRuleNullInt64Type:
+		field.vType = analysisRuleNumberType
+		return field, nil
+	case analysisRuleInstanceKeyType, analysisRuleBinlogCoordinatesType:
+		field.vType = analysisRuleStringType
+		return field, nil
+	}
+	switch fieldType.Kind() {
+	case reflect.Bool:
+		field.vType = analysisRuleBoolType
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		field.vType = analysisRuleNumberType
+	case reflect.String:
+		field.vType = analysisRuleStringType
+	default:
+		return nil, fmt.Errorf("field %s is of unsupported type %s", name, fieldType)
+	}
+	return field, nil
+}
+
+func (this *analysisRuleField) evaluate(environment *analysisRuleEnvironment) interface{} {
+	value := reflect.ValueOf(*environment)
+	for _, fieldName := range this.path {
+		for value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return nil
+			}
+			value = value.Elem()
+		}
+		value = value.FieldByName(fieldName)
+	}
+	switch typedValue := value.Interface().(type) {
+	case sql.NullInt64:
+		if !typedValue.Valid {
+			return nil
+		}
+		return float64(typedValue.Int64)
+	case InstanceKey:
+		return typedValue.StringCode()
+	case BinlogCoordinates:
+		return typedValue.DisplayString()
+	}
+	switch value.Kind() {
+	case reflect.Bool:
+		return value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	}
+	return value.String()
+}
+
+func (this *analysisRuleField) valueType() analysisRuleType { return this.vType }
+
+type analysisRuleNot struct {
+	operand analysisRuleNode
+}
+
+func (this *analysisRuleNot) evaluate(environment *analysisRuleEnvironment) interface{} {
+	value := this.operand.evaluate(environment)
+	if value == nil {
+		return nil
+	}
+	return !value.(bool)
+}
+
+func (this *analysisRuleNot) valueType() analysisRuleType { return analysisRuleBoolType }
+
+type analysisRuleBinary struct {
+	operator string
+	left     analysisRuleNode
+	right    analysisRuleNode
+	regexp   *regexp.Regexp
+}
+
+// newAnalysisRuleBinary validates operand types for given operator and returns a binary node
+func newAnalysisRuleBinary(operator string, left analysisRuleNode, right analysisRuleNode) (*analysisRuleBinary, error) {
+	node := &analysisRuleBinary{operator: operator, left: left, right: right}
+	switch operator {
+	case "&&", "||":
+		if left.valueType() != analysisRuleBoolType || right.valueType() != analysisRuleBoolType {
+			return nil, fmt.Errorf("%s expects boolean operands, got %s and %s", operator, left.valueType(), right.valueType())
+		}
+	case "=~", "!~":
+		literal, isLiteral := right.(*analysisRuleLiteral)
+		if left.valueType() != analysisRuleStringType || !isLiteral || literal.vType != analysisRuleStringType {
+			return nil, fmt.Errorf("%s expects a string operand and a string literal pattern", operator)
+		}
+		compiled, err := regexp.Compile(literal.value.(string))
+		if err != nil {
+			return nil, err
+		}
+		node.regexp = compiled
+	case "<", "<=", ">", ">=":
+		if left.valueType() != right.valueType() || left.valueType() == analysisRuleBoolType {
+			return nil, fmt.Errorf("%s expects two numbers or two strings, got %s and %s", operator, left.valueType(), right.valueType())
+		}
+	case "==", "!=":
+		if left.valueType() != right.valueType() {
+			return nil, fmt.Errorf("%s expects operands of same type, got %s and %s", operator, left.valueType(), right.valueType())
+		}
+	}
+	return node, nil
+}
+
+func (this *analysisRuleBinary) evaluate(environment *analysisRuleEnvironment) interface{} {
+	left := this.left.evaluate(environment)
+	switch this.operator {
+	case "&&":
+		if left != true {
+			return false
+		}
+		return this.right.evaluate(environment) == true
+	case "||":
+		if left == true {
+			return true
+		}
+		return this.right.evaluate(environment) == true
+	}
+	right := this.right.evaluate(environment)
+	if left == nil || right == nil {
+		return false
+	}
+	switch this.operator {
+	case "=~":
+		return this.regexp.MatchString(left.(string))
+	case "!~":
+		return !this.regexp.MatchString(left.(string))
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	}
+	if this.left.valueType() == analysisRuleNumberType {
+		leftNumber, rightNumber := left.(float64), right.(float64)
+		switch this.operator {
+		case "<":
+			return leftNumber < rightNumber
+		case "<=":
+			return leftNumber <= rightNumber
+		case ">":
+			return leftNumber > rightNumber
+		}
+		return leftNumber >= rightNumber
+	}
+	leftString, rightString := left.(string), right.(string)
+	switch this.operator {
+	case "<":
+		return leftString < rightString
+	case "<=":
+		return leftString <= rightString
+	case ">":
+		return leftString > rightString
+	}
+	return leftString >= rightString
+}
+
+func (this *analysisRuleBinary) valueType() analysisRuleType { return analysisRuleBoolType }
+
+var analysisRuleOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")"}
+
+// tokenizeAnalysisRuleExpression splits an expression into operators, identifiers, numbers and quoted strings.
+// Quoted strings retain their quotes so as to be distinguishable from identifiers.
+func tokenizeAnalysisRuleExpression(expression string) (tokens []string, err error) {
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expression[i+1:], c)
+			if end < 0 {
+				return tokens, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, expression[i:i+end+2])
+			i += end + 2
+			continue
+		case c == '_' || c == '-' || c == '.' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			end := i + 1
+			for end < len(expression) {
+				c := expression[end]
+				if !(c == '_' || c == '.' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+					break
+				}
+				end++
+			}
+			tokens = append(tokens, expression[i:end])
+			i = end
+			continue
+		}
+		matched := false
+		for _, operator := range analysisRuleOperators {
+			if strings.HasPrefix(expression[i:], operator) {
+				tokens = append(tokens, operator)
+				i += len(operator)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return tokens, fmt.Errorf("unexpected character '%c' at position %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+// analysisRuleParser is a recursive descent parser over expression tokens
+type analysisRuleParser struct {
+	tokens []string
+	pos    int
+}
+
+func (this *analysisRuleParser) peek() string {
+	if this.pos < len(this.tokens) {
+		return this.tokens[this.pos]
+	}
+	return ""
+}
+
+func (this *analysisRuleParser) next() string {
+	token := this.peek()
+	this.pos++
+	return token
+}
+
+func (this *analysisRuleParser) parseOr() (analysisRuleNode, error) {
+	left, err := this.parseAnd()
+	for err == nil && this.peek() == "||" {
+		this.next()
+		var right analysisRuleNode
+		if right, err = this.parseAnd(); err == nil {
+			left, err = newAnalysisRuleBinary("||", left, right)
+		}
+	}
+	return left, err
+}
+
+func (this *analysisRuleParser) parseAnd() (analysisRuleNode, error) {
+	left, err := this.parseUnary()
+	for err == nil && this.peek() == "&&" {
+		this.next()
+		var right analysisRuleNode
+		if right, err = this.parseUnary(); err == nil {
+			left, err = newAnalysisRuleBinary("&&", left, right)
+		}
+	}
+	return left, err
+}
+
+func (this *analysisRuleParser) parseUnary() (analysisRuleNode, error) {
+	if this.peek() != "!" {
+		return this.parseComparison()
+	}
+	this.next()
+	operand, err := this.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if operand.valueType() != analysisRuleBoolType {
+		return nil, fmt.Errorf("! expects a boolean operand, got %s", operand.valueType())
+	}
+	return &analysisRuleNot{operand: operand}, nil
+}
+
+func (this *analysisRuleParser) parseComparison() (analysisRuleNode, error) {
+	left, err := this.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	switch operator := this.peek(); operator {
+	case "==", "!=", "<", "<=", ">", ">=", "=~", "!~":
+		this.next()
+		right, err := this.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return newAnalysisRuleBinary(operator, left, right)
+	}
+	return left, nil
+}
+
+func (this *analysisRuleParser) parsePrimary() (analysisRuleNode, error) {
+	token := this.next()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case token == "(":
+		node, err := this.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if this.next() != ")" {
+			return nil, fmt.Errorf("expected )")
+		}
+		return node, nil
+	case token == "true" || token == "false":
+		return &analysisRuleLiteral{value: token == "true", vType: analysisRuleBoolType}, nil
+	case token[0] == '"' || token[0] == '\'':
+		return &analysisRuleLiteral{value: token[1 : len(token)-1], vType: analysisRuleStringType}, nil
+	case token[0] == '-' || token[0] == '.' || (token[0] >= '0' && token[0] <= '9'):
+		number, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %s", token)
+		}
+		return &analysisRuleLiteral{value: number, vType: analysisRuleNumberType}, nil
+	case token[0] == '_' || (token[0] >= 'a' && token[0] <= 'z') || (token[0] >= 'A' && token[0] <= 'Z'):
+		return newAnalysisRuleField(token)
+	}
+	return nil, fmt.Errorf("unexpected token: %s", token)
+}
+
+// compileAnalysisRuleExpression parses and type checks a boolean rule expression
+func compileAnalysisRuleExpression(expression string) (analysisRuleNode, error) {
+	tokens, err := tokenizeAnalysisRuleExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	parser := &analysisRuleParser{tokens: tokens}
+	node, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(tokens) {
+		return nil, fmt.Errorf("unexpected token: %s", tokens[parser.pos])
+	}
+	if node.valueType() != analysisRuleBoolType {
+		return nil, fmt.Errorf("expression is of type %s; expected boolean", node.valueType())
+	}
+	return node, nil
+}
+
+// customAnalysisRule is a compiled config.AnalysisRule
+type customAnalysisRule struct {
+	config.AnalysisRule
+	expression analysisRuleNode
+}
+
+// compileCustomAnalysisRule validates and compiles a configured rule
+func compileCustomAnalysisRule(configRule config.AnalysisRule) (*customAnalysisRule, error) {
+	if configRule.Name == "" {
+		return nil, fmt.Errorf("Custom analysis rule has no name: %+v", configRule.Expression)
+	}
+	if builtinAnalysisCodes[configRule.Name] {
+		return nil, fmt.Errorf("Custom analysis rule %s: name is reserved by a built-in analysis code", configRule.Name)
+	}
+	switch configRule.Severity {
+	case "":
+		configRule.Severity = AnalysisRuleSeverityWarning
+	case AnalysisRuleSeverityInfo, AnalysisRuleSeverityWarning, AnalysisRuleSeverityError:
+	default:
+		return nil, fmt.Errorf("Custom analysis rule %s: unknown severity %s", configRule.Name, configRule.Severity)
+	}
+	expression, err := compileAnalysisRuleExpression(configRule.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("Custom analysis rule %s: %+v", configRule.Name, err)
+	}
+	return &customAnalysisRule{AnalysisRule: configRule, expression: expression}, nil
+}
+
+// ValidateCustomAnalysisRules compiles given rules, returning an error per invalid rule
+func ValidateCustomAnalysisRules(configRules []config.AnalysisRule) (errs []error) {
+	for _, configRule := range configRules {
+		if _, err := compileCustomAnalysisRule(configRule); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+var customAnalysisRulesMutex sync.Mutex
+var compiledCustomAnalysisRules = make(map[string]*customAnalysisRule)
+var failedCustomAnalysisRules = make(map[string]bool)
+
+// getCustomAnalysisRules returns the compiled configured rules. Invalid rules are reported once and are otherwise ignored.
+func getCustomAnalysisRules() (rules [](*customAnalysisRule)) {
+	customAnalysisRulesMutex.Lock()
+	defer customAnalysisRulesMutex.Unlock()
+
+	for _, configRule := range config.Config.CustomAnalysisRules {
+		ruleKey := fmt.Sprintf("%+v", configRule)
+		if failedCustomAnalysisRules[ruleKey] {
+			continue
+		}
+		rule, found := compiledCustomAnalysisRules[ruleKey]
+		if !found {
+			var err error
+			if rule, err = compileCustomAnalysisRule(configRule); err != nil {
+				log.Errore(err)
+				failedCustomAnalysisRules[ruleKey] = true
+				continue
+			}
+			compiledCustomAnalysisRules[ruleKey] = rule
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// evaluateCustomAnalysisRules returns the custom analysis of given instance
+func evaluateCustomAnalysisRules(rules [](*customAnalysisRule), analysis *ReplicationAnalysis, instance *Instance, master *Instance) (result []CustomAnalysis) {
+	environment := &analysisRuleEnvironment{Instance: instance, Master: master, Analysis: analysis}
+	for _, rule := range rules {
+		if rule.expression.evaluate(environment) == true {
+			result = append(result, CustomAnalysis{Code: AnalysisCode(rule.Name), Description: rule.Description, Severity: rule.Severity})
+		}
+	}
+	return result
+}
@@ -0,0 +1,162 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"database/sql"
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+func newAnalysisRuleTestEnvironment() *analysisRuleEnvironment {
+	master := &Instance{Key: InstanceKey{Hostname: "master", Port: 3306}, Binlog_format: "ROW", LogBinEnabled: true, ClusterName: "orders:3306"}
+	slave := &Instance{Key: InstanceKey{Hostname: "slave", Port: 3306}, MasterKey: master.Key, Binlog_format: "ROW", LogBinEnabled: true, ReadOnly: true, ClusterName: "orders:3306"}
+	slave.SecondsBehindMaster = sql.NullInt64{Int64: 7, Valid: true}
+	slave.HasReplicationCredentials = true
+	analysis := &ReplicationAnalysis{AnalyzedInstanceKey: slave.Key, AnalyzedInstanceMasterKey: master.Key, Analysis: NoProblem, ReplicationDepth: 1}
+	return &analysisRuleEnvironment{Instance: slave, Master: master, Analysis: analysis}
+}
+
+func evaluateAnalysisRuleExpression(t *testing.T, expression string, environment *analysisRuleEnvironment) interface{} {
+	node, err := compileAnalysisRuleExpression(expression)
+	if err != nil {
+		t.Errorf("%s: %+v", expression, err)
+		return nil
+	}
+	return node.evaluate(environment)
+}
+
+func TestAnalysisRuleExpressions(t *testing.T) {
+	environment := newAnalysisRuleTestEnvironment()
+	expectations := map[string]bool{
+		`true`:                                  true,
+		`!false`:                                true,
+		`Instance.ReadOnly`:                     true,
+		`!Instance.ReadOnly`:                    false,
+		`Instance.HasReplicationFilters`:        false,
+		`Instance.Binlog_format == "ROW"`:       true,
+		`Instance.Binlog_format == 'STATEMENT'`: false,
+		`Instance.Binlog_format != Master.Binlog_format`:              false,
+		`Instance.ClusterName =~ "^orders"`:                           true,
+		`Instance.ClusterName !~ "^orders"`:                           false,
+		`Instance.Key == "slave:3306"`:                                true,
+		`Instance.MasterKey.Hostname == Master.Key.Hostname`:          true,
+		`Instance.SecondsBehindMaster > 5`:                            true,
+		`Instance.SecondsBehindMaster >= 7.5`:                         false,
+		`Instance.SecondsBehindMaster < -1`:                           false,
+		`Analysis.ReplicationDepth == 1 && Analysis.CountSlaves <= 0`: true,
+		`Analysis.IsMaster || Analysis.Analysis == "NoProblem"`:       true,
+		`!(Analysis.IsMaster || Instance.ReadOnly)`:                   false,
+		`!Analysis.IsMaster && !Instance.HasReplicationCredentials`:   false,
+		`Instance.Version < "5.7" && Instance.Version >= ""`:          true,
+	}
+	for expression, expected := range expectations {
+		if result := evaluateAnalysisRuleExpression(t, expression, environment); result != expected {
+			t.Errorf("%s: expected %+v, got %+v", expression, expected, result)
+		}
+	}
+}
+
+func TestAnalysisRuleExpressionsUnknownValues(t *testing.T) {
+	environment := newAnalysisRuleTestEnvironment()
+	environment.Master = nil
+	environment.Instance.SecondsBehindMaster = sql.NullInt64{}
+
+	test.S(t).ExpectEquals(evaluateAnalysisRuleExpression(t, `Instance.Binlog_format != Master.Binlog_format`, environment), false)
+	test.S(t).ExpectEquals(evaluateAnalysisRuleExpression(t, `Instance.Binlog_format == Master.Binlog_format`, environment), false)
+	test.S(t).ExpectEquals(evaluateAnalysisRuleExpression(t, `Master.ReadOnly`, environment), nil)
+	test.S(t).ExpectEquals(evaluateAnalysisRuleExpression(t, `!Master.ReadOnly`, environment), nil)
+	test.S(t).ExpectEquals(evaluateAnalysisRuleExpression(t, `!Master.ReadOnly || Instance.ReadOnly`, environment), true)
+	test.S(t).ExpectEquals(evaluateAnalysisRuleExpression(t, `Instance.SecondsBehindMaster >= 0`, environment), false)
+}
+
+func TestAnalysisRuleExpressionsInvalid(t *testing.T) {
+	invalidExpressions := []string{
+		``,
+		`Instance`,
+		`ReadOnly`,
+		`Instance.NoSuchField`,
+		`Instance.SlaveHosts == ""`,
+		`Instance.ReadOnly == "1"`,
+		`Instance.Binlog_format`,
+		`Instance.Binlog_format =~ Master.Binlog_format`,
+		`Instance.Binlog_format =~ "("`,
+		`Instance.ReadOnly && Instance.Port`,
+		`Instance.Port > true`,
+		`!Instance.Version`,
+		`(Instance.ReadOnly`,
+		`Instance.ReadOnly)`,
+		`Instance.ReadOnly Instance.ReadOnly`,
+		`Instance.Binlog_format == "ROW`,
+		`Instance.ReadOnly & true`,
+		`Instance.Port == 1.2.3`,
+	}
+	for _, expression := range invalidExpressions {
+		if _, err := compileAnalysisRuleExpression(expression); err == nil {
+			t.Errorf("expected error compiling %s", expression)
+		}
+	}
+}
+
+func TestEvaluateCustomAnalysisRules(t *testing.T) {
+	rules := config.Config.CustomAnalysisRules
+	defer func() { config.Config.CustomAnalysisRules = rules }()
+
+	config.Config.CustomAnalysisRules = []config.AnalysisRule{
+		{Name: "WritableSlave", Expression: `!Analysis.IsMaster && !Instance.ReadOnly`, Severity: "error"},
+		{Name: "BinlogFormatDiffersFromMaster", Description: "differs", Expression: `Instance.LogBinEnabled && Instance.Binlog_format != Master.Binlog_format`},
+		{Name: "InvalidRule", Expression: `Instance.NoSuchField`},
+		{Name: "InvalidSeverity", Expression: `true`, Severity: "critical"},
+		{Expression: `true`},
+	}
+	compiledRules := getCustomAnalysisRules()
+	// WritableSlave is a built-in analysis code, hence rejected
+	test.S(t).ExpectEquals(len(compiledRules), 1)
+
+	environment := newAnalysisRuleTestEnvironment()
+	test.S(t).ExpectEquals(len(evaluateCustomAnalysisRules(compiledRules, environment.Analysis, environment.Instance, environment.Master)), 0)
+
+	environment.Instance.ReadOnly = false
+	environment.Instance.Binlog_format = "MIXED"
+	customAnalysis := evaluateCustomAnalysisRules(compiledRules, environment.Analysis, environment.Instance, environment.Master)
+	test.S(t).ExpectEquals(len(customAnalysis), 1)
+	test.S(t).ExpectEquals(customAnalysis[0], CustomAnalysis{Code: "BinlogFormatDiffersFromMaster", Description: "differs", Severity: AnalysisRuleSeverityWarning})
+
+	environment.Analysis.CustomAnalysis = customAnalysis
+	test.S(t).ExpectEquals(environment.Analysis.AnalysisString(), "BinlogFormatDiffersFromMaster")
+	test.S(t).ExpectEquals(environment.Analysis.auditedAnalysis(), AnalysisCode("NoProblem+BinlogFormatDiffersFromMaster"))
+
+	config.Config.CustomAnalysisRules = []config.AnalysisRule{}
+	test.S(t).ExpectEquals(len(getCustomAnalysisRules()), 0)
+}
+
+func TestCompileCustomAnalysisRuleReservedNames(t *testing.T) {
+	for _, name := range []string{"WritableSlave", "DeadMaster", "MultipleMajorVersionsLoggingSlaves", "DatadirDiskAlmostFull"} {
+		_, err := compileCustomAnalysisRule(config.AnalysisRule{Name: name, Expression: `true`})
+		test.S(t).ExpectNotNil(err)
+	}
+	rule, err := compileCustomAnalysisRule(config.AnalysisRule{Name: "SlaveWithoutReadOnly", Expression: `!Analysis.IsMaster && !Instance.ReadOnly`, Severity: "error"})
+	test.S(t).ExpectNil(err)
+
+	environment := newAnalysisRuleTestEnvironment()
+	environment.Instance.ReadOnly = false
+	customAnalysis := evaluateCustomAnalysisRules([]*customAnalysisRule{rule}, environment.Analysis, environment.Instance, environment.Master)
+	test.S(t).ExpectEquals(len(customAnalysis), 1)
+	test.S(t).ExpectEquals(customAnalysis[0], CustomAnalysis{Code: "SlaveWithoutReadOnly", Severity: AnalysisRuleSeverityError})
+}
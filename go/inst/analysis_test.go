@@ -0,0 +1,926 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+// Fixture builders for analysis tests. Instances are healthy by default; fixtures then break them as needed.
+
+func newAnalysisTestMaster(hostname string) *AnalysisInstance {
+	key := InstanceKey{Hostname: hostname, Port: 3306}
+	return &AnalysisInstance{
+		Key:                     key,
+		ClusterName:             key.StringCode(),
+		ClusterAlias:            hostname,
+		Version:                 "5.6.33-log",
+		LastCheckValid:          true,
+		LastAttemptedCheckValid: true,
+		LogBinEnabled:           true,
+		LogSlaveUpdatesEnabled:  true,
+		Binlog_format:           "ROW",
+	}
+}
+
+func newAnalysisTestSlave(hostname string, master *AnalysisInstance) *AnalysisInstance {
+	slave := newAnalysisTestMaster(hostname)
+	slave.MasterKey = master.Key
+	slave.ClusterName = master.ClusterName
+	slave.ClusterAlias = master.ClusterAlias
+	slave.ReplicationDepth = master.ReplicationDepth + 1
+	slave.Slave_SQL_Running = true
+	slave.Slave_IO_Running = true
+	slave.ReadOnly = true
+	return slave
+}
+
+func withDeadInstance(instance *AnalysisInstance) *AnalysisInstance {
+	instance.LastCheckValid = false
+	return instance
+}
+
+func withStoppedReplication(instance *AnalysisInstance) *AnalysisInstance {
+	instance.Slave_SQL_Running = false
+	instance.Slave_IO_Running = false
+	return instance
+}
+
+func withFailingToConnectToMaster(instance *AnalysisInstance) *AnalysisInstance {
+	instance.Slave_SQL_Running = true
+	instance.Slave_IO_Running = false
+	instance.LastIOError = "error reconnecting to master 'repl@master:3306' - retry-time: 60  retries: 86400"
+	return instance
+}
+
+func withStaleRelayLogs(instance *AnalysisInstance) *AnalysisInstance {
+	instance.IsStale = true
+	return instance
+}
+
+func withBinlogFormat(instance *AnalysisInstance, binlogFormat string) *AnalysisInstance {
+	instance.Binlog_format = binlogFormat
+	return instance
+}
+
+// newAnalysisTestCoMasters returns two co-masters, the first being the "active" one, in the sense of
+// being the cluster master; the second is the one typically analyzed
+func newAnalysisTestCoMasters() (*AnalysisInstance, *AnalysisInstance) {
+	coMaster1 := newAnalysisTestMaster("comaster1")
+	coMaster2 := newAnalysisTestSlave("comaster2", coMaster1)
+	coMaster2.ReplicationDepth = 0
+	coMaster1.MasterKey = coMaster2.Key
+	coMaster1.Slave_SQL_Running = true
+	coMaster1.Slave_IO_Running = true
+	coMaster1.IsCoMaster = true
+	coMaster2.IsCoMaster = true
+	return coMaster1, coMaster2
+}
+
+// newAnalysisTestReplicationGroup returns the members of a single-primary replication group, the first being the
+// primary. Each member sees all members as ONLINE
+func newAnalysisTestReplicationGroup(hostnames ...string) []*AnalysisInstance {
+	members := []ReplicationGroupMember{}
+	for i, hostname := range hostnames {
+		role := ReplicationGroupMemberRoleSecondary
+		if i == 0 {
+			role = ReplicationGroupMemberRolePrimary
+		}
+		members = append(members, ReplicationGroupMember{Key: InstanceKey{Hostname: hostname, Port: 3306}, State: ReplicationGroupMemberStateOnline, Role: role})
+	}
+	group := []*AnalysisInstance{}
+	for i, hostname := range hostnames {
+		instance := newAnalysisTestMaster(hostname)
+		instance.Version = "5.7.17-log"
+		instance.ClusterName = members[0].Key.StringCode()
+		instance.ClusterAlias = hostnames[0]
+		instance.ReadOnly = (i > 0)
+		instance.ReplicationGroupName = "8a94f357-aab4-11df-86ab-c80aa9429562"
+		instance.ReplicationGroupMemberState = members[i].State
+		instance.ReplicationGroupMemberRole = members[i].Role
+		instance.ReplicationGroupMembers = append([]ReplicationGroupMember{}, members...)
+		group = append(group, instance)
+	}
+	return group
+}
+
+// withReplicationGroupMemberSeenAs sets the state of given member, as seen by the other members of the group
+func withReplicationGroupMemberSeenAs(group []*AnalysisInstance, hostname string, state string) []*AnalysisInstance {
+	for _, instance := range group {
+		if instance.Key.Hostname == hostname {
+			continue
+		}
+		for i := range instance.ReplicationGroupMembers {
+			if instance.ReplicationGroupMembers[i].Key.Hostname == hostname {
+				instance.ReplicationGroupMembers[i].State = state
+			}
+		}
+	}
+	return group
+}
+
+// newAnalysisTestGaleraCluster returns the nodes of a healthy Galera cluster, named after the first node. All nodes
+// are writable
+func newAnalysisTestGaleraCluster(hostnames ...string) []*AnalysisInstance {
+	clusterKey := InstanceKey{Hostname: hostnames[0], Port: 3306}
+	cluster := []*AnalysisInstance{}
+	for _, hostname := range hostnames {
+		instance := newAnalysisTestMaster(hostname)
+		instance.Version = "5.6.30-76.3-56-log"
+		instance.ClusterName = clusterKey.StringCode()
+		instance.ClusterAlias = hostnames[0]
+		instance.GaleraClusterStateUUID = "e2b5b5a9-8f5c-11e6-9b7a-0242ac110002"
+		instance.GaleraClusterStatus = GaleraClusterStatusPrimary
+		instance.GaleraLocalStateComment = GaleraLocalStateCommentSynced
+		cluster = append(cluster, instance)
+	}
+	return cluster
+}
+
+type analysisTestFixture struct {
+	name              string
+	analyzedHostname  string
+	instances         func() []*AnalysisInstance
+	analysis          AnalysisCode
+	structureAnalysis []StructureAnalysisCode
+}
+
+var analysisTestFixtures = []analysisTestFixture{
+	{
+		name: "healthy topology", analyzedHostname: "master", analysis: NoProblem,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			return []*AnalysisInstance{master, newAnalysisTestSlave("slave1", master), newAnalysisTestSlave("slave2", master)}
+		},
+	},
+	{
+		// MasterWithoutSlaves is not reported
+		name: "master without slaves", analyzedHostname: "master", analysis: NoProblem,
+		instances: func() []*AnalysisInstance {
+			return []*AnalysisInstance{newAnalysisTestMaster("master")}
+		},
+	},
+	{
+		name: "dead master without slaves", analyzedHostname: "master", analysis: DeadMasterWithoutSlaves,
+		instances: func() []*AnalysisInstance {
+			return []*AnalysisInstance{withDeadInstance(newAnalysisTestMaster("master"))}
+		},
+	},
+	{
+		name: "dead master", analyzedHostname: "master", analysis: DeadMaster,
+		instances: func() []*AnalysisInstance {
+			master := withDeadInstance(newAnalysisTestMaster("master"))
+			return []*AnalysisInstance{
+				master,
+				withFailingToConnectToMaster(newAnalysisTestSlave("slave1", master)),
+				withFailingToConnectToMaster(newAnalysisTestSlave("slave2", master)),
+			}
+		},
+	},
+	{
+		name: "dead master, unseen last attempted check", analyzedHostname: "master", analysis: DeadMaster,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			master.LastAttemptedCheckValid = false
+			return []*AnalysisInstance{master, withFailingToConnectToMaster(newAnalysisTestSlave("slave1", master))}
+		},
+	},
+	{
+		name: "dead master and slaves", analyzedHostname: "master", analysis: DeadMasterAndSlaves,
+		instances: func() []*AnalysisInstance {
+			master := withDeadInstance(newAnalysisTestMaster("master"))
+			return []*AnalysisInstance{
+				master,
+				withDeadInstance(newAnalysisTestSlave("slave1", master)),
+				withDeadInstance(newAnalysisTestSlave("slave2", master)),
+			}
+		},
+	},
+	{
+		name: "dead master and some slaves", analyzedHostname: "master", analysis: DeadMasterAndSomeSlaves,
+		instances: func() []*AnalysisInstance {
+			master := withDeadInstance(newAnalysisTestMaster("master"))
+			return []*AnalysisInstance{
+				master,
+				withDeadInstance(newAnalysisTestSlave("slave1", master)),
+				withFailingToConnectToMaster(newAnalysisTestSlave("slave2", master)),
+			}
+		},
+	},
+	{
+		name: "unreachable master with stale slaves", analyzedHostname: "master", analysis: UnreachableMasterWithStaleSlaves,
+		instances: func() []*AnalysisInstance {
+			master := withDeadInstance(newAnalysisTestMaster("master"))
+			return []*AnalysisInstance{
+				master,
+				withStaleRelayLogs(newAnalysisTestSlave("slave1", master)),
+				withStaleRelayLogs(newAnalysisTestSlave("slave2", master)),
+			}
+		},
+	},
+	{
+		name: "unreachable master", analyzedHostname: "master", analysis: UnreachableMaster,
+		instances: func() []*AnalysisInstance {
+			master := withDeadInstance(newAnalysisTestMaster("master"))
+			return []*AnalysisInstance{
+				master,
+				withStaleRelayLogs(newAnalysisTestSlave("slave1", master)),
+				newAnalysisTestSlave("slave2", master),
+			}
+		},
+	},
+	{
+		name: "master single slave not replicating", analyzedHostname: "master", analysis: MasterSingleSlaveNotReplicating,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			return []*AnalysisInstance{master, withStoppedReplication(newAnalysisTestSlave("slave1", master))}
+		},
+	},
+	{
+		name: "master single slave dead", analyzedHostname: "master", analysis: MasterSingleSlaveDead,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			return []*AnalysisInstance{master, withDeadInstance(newAnalysisTestSlave("slave1", master))}
+		},
+	},
+	{
+		name: "all master slaves not replicating", analyzedHostname: "master", analysis: AllMasterSlavesNotReplicating,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			return []*AnalysisInstance{
+				master,
+				withStoppedReplication(newAnalysisTestSlave("slave1", master)),
+				withFailingToConnectToMaster(newAnalysisTestSlave("slave2", master)),
+			}
+		},
+	},
+	{
+		name: "all master slaves not replicating or dead", analyzedHostname: "master", analysis: AllMasterSlavesNotReplicatingOrDead,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			return []*AnalysisInstance{
+				master,
+				withStoppedReplication(newAnalysisTestSlave("slave1", master)),
+				withDeadInstance(newAnalysisTestSlave("slave2", master)),
+			}
+		},
+	},
+	{
+		name: "all master slaves stale", analyzedHostname: "master", analysis: AllMasterSlavesStale,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			return []*AnalysisInstance{
+				master,
+				withStaleRelayLogs(newAnalysisTestSlave("slave1", master)),
+				withStaleRelayLogs(newAnalysisTestSlave("slave2", master)),
+			}
+		},
+	},
+	{
+		name: "dead co-master", analyzedHostname: "comaster2", analysis: DeadCoMaster,
+		instances: func() []*AnalysisInstance {
+			coMaster1, coMaster2 := newAnalysisTestCoMasters()
+			withDeadInstance(coMaster2)
+			withFailingToConnectToMaster(coMaster1)
+			return []*AnalysisInstance{coMaster1, coMaster2, withStoppedReplication(newAnalysisTestSlave("slave1", coMaster2))}
+		},
+	},
+	{
+		name: "dead co-master and some slaves", analyzedHostname: "comaster2", analysis: DeadCoMasterAndSomeSlaves,
+		instances: func() []*AnalysisInstance {
+			coMaster1, coMaster2 := newAnalysisTestCoMasters()
+			withDeadInstance(coMaster2)
+			withFailingToConnectToMaster(coMaster1)
+			return []*AnalysisInstance{coMaster1, coMaster2, withDeadInstance(newAnalysisTestSlave("slave1", coMaster2))}
+		},
+	},
+	{
+		name: "unreachable co-master", analyzedHostname: "comaster2", analysis: UnreachableCoMaster,
+		instances: func() []*AnalysisInstance {
+			coMaster1, coMaster2 := newAnalysisTestCoMasters()
+			withDeadInstance(coMaster2)
+			return []*AnalysisInstance{coMaster1, coMaster2}
+		},
+	},
+	{
+		name: "all co-master slaves not replicating", analyzedHostname: "comaster2", analysis: AllCoMasterSlavesNotReplicating,
+		instances: func() []*AnalysisInstance {
+			coMaster1, coMaster2 := newAnalysisTestCoMasters()
+			withStoppedReplication(coMaster1)
+			return []*AnalysisInstance{coMaster1, coMaster2}
+		},
+	},
+	{
+		name: "dead intermediate master with single slave failing to connect", analyzedHostname: "intermediate", analysis: DeadIntermediateMasterWithSingleSlaveFailingToConnect,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			intermediate := withDeadInstance(newAnalysisTestSlave("intermediate", master))
+			return []*AnalysisInstance{master, intermediate, withFailingToConnectToMaster(newAnalysisTestSlave("slave1", intermediate))}
+		},
+	},
+	{
+		name: "dead intermediate master with single slave", analyzedHostname: "intermediate", analysis: DeadIntermediateMasterWithSingleSlave,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			intermediate := withDeadInstance(newAnalysisTestSlave("intermediate", master))
+			return []*AnalysisInstance{master, intermediate, withStoppedReplication(newAnalysisTestSlave("slave1", intermediate))}
+		},
+	},
+	{
+		name: "dead intermediate master", analyzedHostname: "intermediate", analysis: DeadIntermediateMaster,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			intermediate := withDeadInstance(newAnalysisTestSlave("intermediate", master))
+			return []*AnalysisInstance{
+				master,
+				intermediate,
+				withFailingToConnectToMaster(newAnalysisTestSlave("slave1", intermediate)),
+				withFailingToConnectToMaster(newAnalysisTestSlave("slave2", intermediate)),
+			}
+		},
+	},
+	{
+		name: "dead intermediate master and some slaves", analyzedHostname: "intermediate", analysis: DeadIntermediateMasterAndSomeSlaves,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			intermediate := withDeadInstance(newAnalysisTestSlave("intermediate", master))
+			return []*AnalysisInstance{
+				master,
+				intermediate,
+				withFailingToConnectToMaster(newAnalysisTestSlave("slave1", intermediate)),
+				withDeadInstance(newAnalysisTestSlave("slave2", intermediate)),
+			}
+		},
+	},
+	{
+		name: "unreachable intermediate master", analyzedHostname: "intermediate", analysis: UnreachableIntermediateMaster,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			intermediate := withDeadInstance(newAnalysisTestSlave("intermediate", master))
+			return []*AnalysisInstance{master, intermediate, newAnalysisTestSlave("slave1", intermediate)}
+		},
+	},
+	{
+		name: "all intermediate master slaves failing to connect or dead", analyzedHostname: "intermediate", analysis: AllIntermediateMasterSlavesFailingToConnectOrDead,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			intermediate := newAnalysisTestSlave("intermediate", master)
+			return []*AnalysisInstance{
+				master,
+				intermediate,
+				withFailingToConnectToMaster(newAnalysisTestSlave("slave1", intermediate)),
+				withDeadInstance(newAnalysisTestSlave("slave2", intermediate)),
+			}
+		},
+	},
+	{
+		name: "all intermediate master slaves not replicating", analyzedHostname: "intermediate", analysis: AllIntermediateMasterSlavesNotReplicating,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			intermediate := newAnalysisTestSlave("intermediate", master)
+			return []*AnalysisInstance{master, intermediate, withStoppedReplication(newAnalysisTestSlave("slave1", intermediate))}
+		},
+	},
+	{
+		name: "binlog server failing to connect to master", analyzedHostname: "binlogserver", analysis: BinlogServerFailingToConnectToMaster,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			binlogServer := withFailingToConnectToMaster(newAnalysisTestSlave("binlogserver", master))
+			binlogServer.IsBinlogServer = true
+			return []*AnalysisInstance{master, binlogServer}
+		},
+	},
+	{
+		name: "first tier slave failing to connect to master", analyzedHostname: "slave1", analysis: FirstTierSlaveFailingToConnectToMaster,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			return []*AnalysisInstance{master, withFailingToConnectToMaster(newAnalysisTestSlave("slave1", master))}
+		},
+	},
+	{
+		name: "second tier slave failing to connect to master", analyzedHostname: "slave1", analysis: NoProblem,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			intermediate := newAnalysisTestSlave("intermediate", master)
+			return []*AnalysisInstance{
+				master,
+				intermediate,
+				withFailingToConnectToMaster(newAnalysisTestSlave("slave1", intermediate)),
+				newAnalysisTestSlave("slave2", intermediate),
+			}
+		},
+	},
+	{
+		name: "writable slave", analyzedHostname: "slave1", analysis: WritableSlave,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			slave := newAnalysisTestSlave("slave1", master)
+			slave.ReadOnly = false
+			return []*AnalysisInstance{master, slave}
+		},
+	},
+	{
+		name: "writable co-master", analyzedHostname: "comaster2", analysis: NoProblem,
+		instances: func() []*AnalysisInstance {
+			coMaster1, coMaster2 := newAnalysisTestCoMasters()
+			coMaster2.ReadOnly = false
+			return []*AnalysisInstance{coMaster1, coMaster2}
+		},
+	},
+	{
+		name: "writable slave with stopped replication", analyzedHostname: "slave2", analysis: MultipleWritersInCluster,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			slave := withStoppedReplication(newAnalysisTestSlave("slave2", master))
+			slave.ReadOnly = false
+			return []*AnalysisInstance{master, newAnalysisTestSlave("slave1", master), slave}
+		},
+	},
+	{
+		name: "multiple writers: detached old master", analyzedHostname: "master", analysis: MultipleWritersInCluster,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			oldMaster := newAnalysisTestMaster("oldmaster")
+			oldMaster.ClusterAlias = master.ClusterAlias
+			return []*AnalysisInstance{master, newAnalysisTestSlave("slave1", master), oldMaster}
+		},
+	},
+	{
+		name: "multiple writers: dead old master", analyzedHostname: "master", analysis: NoProblem,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			oldMaster := withDeadInstance(newAnalysisTestMaster("oldmaster"))
+			oldMaster.ClusterAlias = master.ClusterAlias
+			return []*AnalysisInstance{master, newAnalysisTestSlave("slave1", master), oldMaster}
+		},
+	},
+	{
+		name: "read-only master", analyzedHostname: "master", analysis: ReadOnlyMaster,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			master.ReadOnly = true
+			return []*AnalysisInstance{master, newAnalysisTestSlave("slave1", master)}
+		},
+	},
+	{
+		name: "read-only master, recovery in progress", analyzedHostname: "master", analysis: NoProblem,
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			master.ReadOnly = true
+			master.RecoveryInProgress = true
+			return []*AnalysisInstance{master, newAnalysisTestSlave("slave1", master)}
+		},
+	},
+	{
+		name: "statement and mixed logging slaves", analyzedHostname: "master", analysis: NoProblem,
+		structureAnalysis: []StructureAnalysisCode{StatementAndMixedLoggingSlavesStructureWarning},
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			return []*AnalysisInstance{
+				master,
+				withBinlogFormat(newAnalysisTestSlave("slave1", master), "STATEMENT"),
+				withBinlogFormat(newAnalysisTestSlave("slave2", master), "MIXED"),
+			}
+		},
+	},
+	{
+		name: "statement and row logging slaves", analyzedHostname: "master", analysis: NoProblem,
+		structureAnalysis: []StructureAnalysisCode{StatementAndRowLoggingSlavesStructureWarning},
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			return []*AnalysisInstance{
+				master,
+				withBinlogFormat(newAnalysisTestSlave("slave1", master), "STATEMENT"),
+				withBinlogFormat(newAnalysisTestSlave("slave2", master), "ROW"),
+			}
+		},
+	},
+	{
+		name: "mixed and row logging slaves", analyzedHostname: "master", analysis: NoProblem,
+		structureAnalysis: []StructureAnalysisCode{MixedAndRowLoggingSlavesStructureWarning},
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			return []*AnalysisInstance{
+				master,
+				withBinlogFormat(newAnalysisTestSlave("slave1", master), "MIXED"),
+				withBinlogFormat(newAnalysisTestSlave("slave2", master), "ROW"),
+			}
+		},
+	},
+	{
+		name: "all binlog formats, one of which is not logging slave updates", analyzedHostname: "master", analysis: NoProblem,
+		structureAnalysis: []StructureAnalysisCode{StatementAndRowLoggingSlavesStructureWarning},
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			notLogging := withBinlogFormat(newAnalysisTestSlave("slave2", master), "MIXED")
+			notLogging.LogSlaveUpdatesEnabled = false
+			return []*AnalysisInstance{
+				master,
+				withBinlogFormat(newAnalysisTestSlave("slave1", master), "STATEMENT"),
+				notLogging,
+				withBinlogFormat(newAnalysisTestSlave("slave3", master), "ROW"),
+			}
+		},
+	},
+	{
+		name: "multiple major versions logging slaves", analyzedHostname: "master", analysis: NoProblem,
+		structureAnalysis: []StructureAnalysisCode{MultipleMajorVersionsLoggingSlaves},
+		instances: func() []*AnalysisInstance {
+			master := newAnalysisTestMaster("master")
+			slave2 := newAnalysisTestSlave("slave2", master)
+			slave2.Version = "5.7.16-log"
+			return []*AnalysisInstance{master, newAnalysisTestSlave("slave1", master), slave2}
+		},
+	},
+	{
+		name: "dead master with multiple structure warnings", analyzedHostname: "master", analysis: DeadMaster,
+		structureAnalysis: []StructureAnalysisCode{StatementAndRowLoggingSlavesStructureWarning, MultipleMajorVersionsLoggingSlaves},
+		instances: func() []*AnalysisInstance {
+			master := withDeadInstance(newAnalysisTestMaster("master"))
+			slave2 := withFailingToConnectToMaster(newAnalysisTestSlave("slave2", master))
+			slave2.Version = "5.7.16-log"
+			return []*AnalysisInstance{
+				master,
+				withBinlogFormat(withFailingToConnectToMaster(newAnalysisTestSlave("slave1", master)), "STATEMENT"),
+				slave2,
+			}
+		},
+	},
+	{
+		name: "healthy replication group: primary", analyzedHostname: "gr1", analysis: NoProblem,
+		instances: func() []*AnalysisInstance {
+			return newAnalysisTestReplicationGroup("gr1", "gr2", "gr3")
+		},
+	},
+	{
+		// A secondary is read-only and not replicating asynchronously; it is no ReadOnlyMaster
+		name: "healthy replication group: secondary", analyzedHostname: "gr2", analysis: NoProblem,
+		instances: func() []*AnalysisInstance {
+			return newAnalysisTestReplicationGroup("gr1", "gr2", "gr3")
+		},
+	},
+	{
+		name: "healthy multi-primary replication group", analyzedHostname: "gr2", analysis: NoProblem,
+		instances: func() []*AnalysisInstance {
+			group := newAnalysisTestReplicationGroup("gr1", "gr2", "gr3")
+			for _, instance := range group {
+				instance.ReadOnly = false
+				instance.ReplicationGroupMemberRole = ReplicationGroupMemberRolePrimary
+			}
+			return group
+		},
+	},
+	{
+		// The group elects a new primary by itself. This is not a DeadMaster
+		name: "dead replication group primary with slave", analyzedHostname: "gr1", analysis: ReplicationGroupMemberUnreachable,
+		instances: func() []*AnalysisInstance {
+			group := withReplicationGroupMemberSeenAs(newAnalysisTestReplicationGroup("gr1", "gr2", "gr3"), "gr1", ReplicationGroupMemberStateUnreachable)
+			withDeadInstance(group[0])
+			return append(group, withFailingToConnectToMaster(newAnalysisTestSlave("slave1", group[0])))
+		},
+	},
+	{
+		name: "replication group member reported unreachable by peers", analyzedHostname: "gr3", analysis: ReplicationGroupMemberUnreachable,
+		instances: func() []*AnalysisInstance {
+			return withReplicationGroupMemberSeenAs(newAnalysisTestReplicationGroup("gr1", "gr2", "gr3"), "gr3", ReplicationGroupMemberStateUnreachable)
+		},
+	},
+	{
+		name: "replication group member in error", analyzedHostname: "gr3", analysis: ReplicationGroupMemberError,
+		instances: func() []*AnalysisInstance {
+			group := newAnalysisTestReplicationGroup("gr1", "gr2", "gr3")
+			group[2].ReplicationGroupMemberState = ReplicationGroupMemberStateError
+			group[2].ReplicationGroupMembers = []ReplicationGroupMember{{Key: group[2].Key, State: ReplicationGroupMemberStateError}}
+			return group
+		},
+	},
+	{
+		name: "replication group lost quorum", analyzedHostname: "gr1", analysis: ReplicationGroupLostQuorum,
+		instances: func() []*AnalysisInstance {
+			group := newAnalysisTestReplicationGroup("gr1", "gr2", "gr3")
+			group[0].ReplicationGroupMembers[1].State = ReplicationGroupMemberStateUnreachable
+			group[0].ReplicationGroupMembers[2].State = ReplicationGroupMemberStateUnreachable
+			withDeadInstance(group[1])
+			withDeadInstance(group[2])
+			return group
+		},
+	},
+	{
+		name: "replication group primary changed", analyzedHostname: "gr1", analysis: ReplicationGroupPrimaryChanged,
+		instances: func() []*AnalysisInstance {
+			group := newAnalysisTestReplicationGroup("gr1", "gr2", "gr3")
+			for _, instance := range group {
+				instance.ReplicationGroupPrimaryRecentlyChanged = true
+			}
+			return group
+		},
+	},
+	{
+		name: "replication group primary changed: secondary", analyzedHostname: "gr2", analysis: NoProblem,
+		instances: func() []*AnalysisInstance {
+			group := newAnalysisTestReplicationGroup("gr1", "gr2", "gr3")
+			for _, instance := range group {
+				instance.ReplicationGroupPrimaryRecentlyChanged = true
+			}
+			return group
+		},
+	},
+	{
+		// All nodes of a Galera cluster are writable; together they make for a single writer
+		name: "healthy galera cluster", analyzedHostname: "pxc2", analysis: NoProblem,
+		instances: func() []*AnalysisInstance {
+			return newAnalysisTestGaleraCluster("pxc1", "pxc2", "pxc3")
+		},
+	},
+	{
+		// Galera nodes fail over by themselves. This is not a DeadMaster
+		name: "dead galera node with slave", analyzedHostname: "pxc1", analysis: UnreachableGaleraNode,
+		instances: func() []*AnalysisInstance {
+			cluster := newAnalysisTestGaleraCluster("pxc1", "pxc2", "pxc3")
+			withDeadInstance(cluster[0])
+			return append(cluster, withFailingToConnectToMaster(newAnalysisTestSlave("slave1", cluster[0])))
+		},
+	},
+	{
+		name: "galera node in non-primary component", analyzedHostname: "pxc3", analysis: GaleraNonPrimaryComponent,
+		instances: func() []*AnalysisInstance {
+			cluster := newAnalysisTestGaleraCluster("pxc1", "pxc2", "pxc3")
+			cluster[2].GaleraClusterStatus = "non-Primary"
+			cluster[2].GaleraLocalStateComment = "Initialized"
+			return cluster
+		},
+	},
+	{
+		name: "desynced galera node", analyzedHostname: "pxc2", analysis: GaleraNodeDesynced,
+		instances: func() []*AnalysisInstance {
+			cluster := newAnalysisTestGaleraCluster("pxc1", "pxc2", "pxc3")
+			cluster[1].GaleraLocalStateComment = GaleraLocalStateCommentDesynced
+			return cluster
+		},
+	},
+}
+
+func findAnalysis(analysisEntries []ReplicationAnalysis, hostname string) *ReplicationAnalysis {
+	for _, analysis := range analysisEntries {
+		if analysis.AnalyzedInstanceKey.Hostname == hostname {
+			return &analysis
+		}
+	}
+	return nil
+}
+
+func TestAnalyzeTopologyFixtures(t *testing.T) {
+	for _, fixture := range analysisTestFixtures {
+		analysisEntries := AnalyzeTopology(NewAnalysisTopology(fixture.instances(), nil), "")
+		analysis := findAnalysis(analysisEntries, fixture.analyzedHostname)
+		if analysis == nil {
+			t.Errorf("%s: no analysis for %s", fixture.name, fixture.analyzedHostname)
+			continue
+		}
+		if analysis.Analysis != fixture.analysis {
+			t.Errorf("%s: expected analysis %s, got %s", fixture.name, fixture.analysis, analysis.Analysis)
+		}
+		if len(analysis.StructureAnalysis) != len(fixture.structureAnalysis) {
+			t.Errorf("%s: expected structure analysis %+v, got %+v", fixture.name, fixture.structureAnalysis, analysis.StructureAnalysis)
+			continue
+		}
+		for i := range fixture.structureAnalysis {
+			if analysis.StructureAnalysis[i] != fixture.structureAnalysis[i] {
+				t.Errorf("%s: expected structure analysis %+v, got %+v", fixture.name, fixture.structureAnalysis, analysis.StructureAnalysis)
+			}
+		}
+		if analysis.Analysis != NoProblem {
+			test.S(t).ExpectTrue(analysis.Description != "")
+		}
+	}
+}
+
+func TestAnalyzeTopologyAggregates(t *testing.T) {
+	master := newAnalysisTestMaster("master")
+	master.UsingPseudoGTID = true
+	slave1 := newAnalysisTestSlave("slave1", master)
+	slave1.UsingOracleGTID = true
+	slave2 := withStaleRelayLogs(withFailingToConnectToMaster(newAnalysisTestSlave("slave2", master)))
+	slave2.UsingOracleGTID = true
+	slave3 := withDeadInstance(newAnalysisTestSlave("slave3", master))
+
+	analysisEntries := AnalyzeTopology(NewAnalysisTopology([]*AnalysisInstance{master, slave1, slave2, slave3}, nil), "")
+	test.S(t).ExpectEquals(len(analysisEntries), 4)
+	analysis := analysisEntries[0]
+	test.S(t).ExpectEquals(analysis.AnalyzedInstanceKey, master.Key)
+	test.S(t).ExpectTrue(analysis.IsMaster)
+	test.S(t).ExpectTrue(analysis.IsClusterMaster)
+	test.S(t).ExpectTrue(analysis.LastCheckValid)
+	test.S(t).ExpectEquals(analysis.CountSlaves, uint(3))
+	test.S(t).ExpectEquals(analysis.CountValidSlaves, uint(2))
+	test.S(t).ExpectEquals(analysis.CountValidReplicatingSlaves, uint(1))
+	test.S(t).ExpectEquals(analysis.CountSlavesFailingToConnectToMaster, uint(1))
+	test.S(t).ExpectEquals(analysis.CountStaleSlaves, uint(1))
+	test.S(t).ExpectEquals(analysis.CountRowBasedLoggingSlaves, uint(3))
+	test.S(t).ExpectEquals(len(analysis.SlaveHosts), 3)
+	test.S(t).ExpectTrue(analysis.SlaveHosts.HasKey(slave3.Key))
+	test.S(t).ExpectTrue(analysis.PseudoGTIDImmediateTopology)
+	test.S(t).ExpectTrue(analysis.OracleGTIDImmediateTopology)
+	test.S(t).ExpectFalse(analysis.MariaDBGTIDImmediateTopology)
+	test.S(t).ExpectFalse(analysis.BinlogServerImmediateTopology)
+
+	slaveAnalysis := findAnalysis(analysisEntries, "slave2")
+	test.S(t).ExpectFalse(slaveAnalysis.IsMaster)
+	test.S(t).ExpectEquals(slaveAnalysis.AnalyzedInstanceMasterKey, master.Key)
+	test.S(t).ExpectEquals(slaveAnalysis.ReplicationDepth, uint(1))
+	test.S(t).ExpectTrue(slaveAnalysis.IsFailingToConnectToMaster)
+}
+
+func TestAnalyzeTopologyResolvedHostnames(t *testing.T) {
+	master := withDeadInstance(newAnalysisTestMaster("master.example.com"))
+	slave := withFailingToConnectToMaster(newAnalysisTestSlave("slave1", master))
+	slave.MasterKey.Hostname = "10.0.0.1"
+
+	analysis := findAnalysis(AnalyzeTopology(NewAnalysisTopology([]*AnalysisInstance{master, slave}, nil), ""), "master.example.com")
+	test.S(t).ExpectEquals(analysis.Analysis, AnalysisCode(DeadMasterWithoutSlaves))
+
+	resolvedHostnames := map[string]string{"master.example.com": "10.0.0.1"}
+	analysis = findAnalysis(AnalyzeTopology(NewAnalysisTopology([]*AnalysisInstance{master, slave}, resolvedHostnames), ""), "master.example.com")
+	test.S(t).ExpectEquals(analysis.Analysis, AnalysisCode(DeadMaster))
+	test.S(t).ExpectEquals(analysis.CountSlaves, uint(1))
+}
+
+func TestAnalyzeTopologyMultiSource(t *testing.T) {
+	masterA := newAnalysisTestMaster("master-a")
+	masterB := withDeadInstance(newAnalysisTestMaster("master-b"))
+	slave := newAnalysisTestSlave("slave1", masterA)
+	slave.ReplicationChannel = "a"
+	slave.ReplicationChannels = []ReplicationChannel{
+		{Name: "a", MasterKey: masterA.Key, Slave_SQL_Running: true, Slave_IO_Running: true},
+		{Name: "b", MasterKey: masterB.Key, Slave_SQL_Running: true, Slave_IO_Running: false, LastIOError: "error reconnecting to master 'repl@master-b:3306'"},
+	}
+	topology := NewAnalysisTopology([]*AnalysisInstance{masterA, masterB, slave}, nil)
+
+	test.S(t).ExpectEquals(len(topology.GetSlaves(&masterA.Key)), 1)
+	test.S(t).ExpectEquals(len(topology.GetSlaves(&masterB.Key)), 1)
+	test.S(t).ExpectEquals(topology.GetSlaves(&masterB.Key)[0].ReplicationChannel, "b")
+	test.S(t).ExpectEquals(slave.MasterKey, masterA.Key)
+
+	analysisEntries := AnalyzeTopology(topology, "")
+	test.S(t).ExpectEquals(len(analysisEntries), 3)
+	test.S(t).ExpectEquals(findAnalysis(analysisEntries, "master-a").Analysis, AnalysisCode(NoProblem))
+	analysis := findAnalysis(analysisEntries, "master-b")
+	test.S(t).ExpectEquals(analysis.Analysis, AnalysisCode(DeadMaster))
+	test.S(t).ExpectEquals(analysis.CountSlaves, uint(1))
+	test.S(t).ExpectEquals(analysis.CountSlavesFailingToConnectToMaster, uint(1))
+}
+
+func TestAnalyzeTopologyMaintenanceAndClusters(t *testing.T) {
+	master := withDeadInstance(newAnalysisTestMaster("master"))
+	slave := withFailingToConnectToMaster(newAnalysisTestSlave("slave1", master))
+	otherMaster := newAnalysisTestMaster("other")
+	instances := []*AnalysisInstance{slave, otherMaster, master}
+
+	analysisEntries := AnalyzeTopology(NewAnalysisTopology(instances, nil), "")
+	test.S(t).ExpectEquals(len(analysisEntries), 3)
+	// Masters first, those with most slaves first
+	test.S(t).ExpectEquals(analysisEntries[0].AnalyzedInstanceKey, master.Key)
+	test.S(t).ExpectEquals(analysisEntries[1].AnalyzedInstanceKey, otherMaster.Key)
+	test.S(t).ExpectEquals(analysisEntries[2].AnalyzedInstanceKey, slave.Key)
+
+	analysisEntries = AnalyzeTopology(NewAnalysisTopology(instances, nil), "other:3306")
+	test.S(t).ExpectEquals(len(analysisEntries), 1)
+	test.S(t).ExpectEquals(analysisEntries[0].AnalyzedInstanceKey, otherMaster.Key)
+
+	// A master under maintenance is not analyzed, but still counts as a master to its slaves
+	master.InMaintenance = true
+	analysisEntries = AnalyzeTopology(NewAnalysisTopology(instances, nil), "master:3306")
+	test.S(t).ExpectEquals(len(analysisEntries), 1)
+	test.S(t).ExpectEquals(analysisEntries[0].Analysis, AnalysisCode(FirstTierSlaveFailingToConnectToMaster))
+}
+
+func TestAnalysisInstanceIsFailingToConnectToMaster(t *testing.T) {
+	instance := newAnalysisTestSlave("slave1", newAnalysisTestMaster("master"))
+	test.S(t).ExpectFalse(instance.IsFailingToConnectToMaster())
+	withFailingToConnectToMaster(instance)
+	test.S(t).ExpectTrue(instance.IsFailingToConnectToMaster())
+	instance.LastIOError = "Error connecting to master 'repl@master:3306' - retry-time: 60  retries: 1"
+	test.S(t).ExpectTrue(instance.IsFailingToConnectToMaster())
+	instance.LastIOError = "Got fatal error 1236 from master when reading data from binary log"
+	test.S(t).ExpectFalse(instance.IsFailingToConnectToMaster())
+	withFailingToConnectToMaster(instance)
+	instance.Slave_SQL_Running = false
+	test.S(t).ExpectFalse(instance.IsFailingToConnectToMaster())
+}
+
+func withAgent(instance *AnalysisInstance, diskUsedPercent float64, diskFree int64) *AnalysisInstance {
+	instance.HasAgent = true
+	instance.AgentLastSeenValid = true
+	instance.AgentMySQLRunning = true
+	instance.AgentMySQLStatusValid = true
+	instance.AgentDatadirUsedPercent = diskUsedPercent
+	instance.AgentDatadirDiskFree = diskFree
+	return instance
+}
+
+func agentAnalysisCodes(analysis ReplicationAnalysis) []AgentAnalysisCode {
+	codes := []AgentAnalysisCode{}
+	for _, agentAnalysis := range analysis.AgentAnalysis {
+		codes = append(codes, agentAnalysis.Code)
+	}
+	return codes
+}
+
+func TestAnalyzeAgent(t *testing.T) {
+	master := withAgent(newAnalysisTestMaster("master"), 40, 600000000000)
+	slave1 := withAgent(newAnalysisTestSlave("slave1", master), 93.5, 70000000000)
+	slave2 := withAgent(newAnalysisTestSlave("slave2", master), 40, 600000000000)
+	slave2.AgentMySQLRunning = false
+	slave2.AgentErrorLogCrashMarker = "161018 10:15:34 [ERROR] mysqld got signal 11 ;"
+	slave3 := newAnalysisTestSlave("slave3", master)
+	slave4 := withAgent(newAnalysisTestSlave("slave4", master), 97, 1000)
+	slave4.AgentLastSeenValid = false
+	slave5 := withAgent(newAnalysisTestSlave("slave5", master), 40, 600000000000)
+	slave5.AgentMySQLRunning = false
+	slave5.AgentMySQLStatusValid = false
+	analysisEntries := AnalyzeTopology(NewAnalysisTopology([]*AnalysisInstance{master, slave1, slave2, slave3, slave4, slave5}, nil), "")
+
+	test.S(t).ExpectEquals(len(findAnalysis(analysisEntries, "master").AgentAnalysis), 0)
+	analysis := findAnalysis(analysisEntries, "slave1")
+	test.S(t).ExpectEquals(analysis.Analysis, AnalysisCode(NoProblem))
+	test.S(t).ExpectEquals(len(analysis.AgentAnalysis), 1)
+	test.S(t).ExpectEquals(analysis.AgentAnalysis[0].Code, DatadirDiskAlmostFull)
+	test.S(t).ExpectEquals(analysis.AnalysisString(), "DatadirDiskAlmostFull")
+	test.S(t).ExpectEquals(string(analysis.auditedAnalysis()), "NoProblem+DatadirDiskAlmostFull")
+
+	analysis = findAnalysis(analysisEntries, "slave2")
+	test.S(t).ExpectEquals(len(analysis.AgentAnalysis), 2)
+	test.S(t).ExpectEquals(analysis.AgentAnalysis[0].Code, AgentAnalysisCode(MySQLNotRunningOnAgentHost))
+	test.S(t).ExpectEquals(analysis.AgentAnalysis[1].Code, AgentAnalysisCode(ErrorLogCrashMarker))
+
+	// No agent, or an agent not recently polled, make for no agent analysis
+	test.S(t).ExpectEquals(len(findAnalysis(analysisEntries, "slave3").AgentAnalysis), 0)
+	test.S(t).ExpectEquals(len(findAnalysis(analysisEntries, "slave4").AgentAnalysis), 0)
+	// An agent which failed the MySQL status inquiry does not know whether MySQL is running
+	test.S(t).ExpectEquals(len(findAnalysis(analysisEntries, "slave5").AgentAnalysis), 0)
+}
+
+func TestAnalyzeAgentDiskThresholds(t *testing.T) {
+	originalUsedPercentThreshold := config.Config.AgentDatadirDiskUsedPercentThreshold
+	originalFreeThreshold := config.Config.AgentDatadirDiskFreeThresholdBytes
+	defer func() {
+		config.Config.AgentDatadirDiskUsedPercentThreshold = originalUsedPercentThreshold
+		config.Config.AgentDatadirDiskFreeThresholdBytes = originalFreeThreshold
+	}()
+	analyze := func(instance *AnalysisInstance) []AgentAnalysisCode {
+		return agentAnalysisCodes(AnalyzeTopology(NewAnalysisTopology([]*AnalysisInstance{instance}, nil), "")[0])
+	}
+
+	config.Config.AgentDatadirDiskUsedPercentThreshold = 0
+	config.Config.AgentDatadirDiskFreeThresholdBytes = 10000000000
+	test.S(t).ExpectEquals(len(analyze(withAgent(newAnalysisTestMaster("master"), 70, 20000000000))), 0)
+	test.S(t).ExpectEquals(len(analyze(withAgent(newAnalysisTestMaster("master"), 70, 5000000000))), 1)
+	// No disk inventory reported at all
+	test.S(t).ExpectEquals(len(analyze(withAgent(newAnalysisTestMaster("master"), 0, 0))), 0)
+
+	config.Config.AgentDatadirDiskUsedPercentThreshold = 75
+	config.Config.AgentDatadirDiskFreeThresholdBytes = 0
+	test.S(t).ExpectEquals(len(analyze(withAgent(newAnalysisTestMaster("master"), 70, 5000000000))), 0)
+	test.S(t).ExpectEquals(len(analyze(withAgent(newAnalysisTestMaster("master"), 75, 5000000000))), 1)
+}
+
+func TestGetReplicationAnalysisReducesOnlyHealthyLeaves(t *testing.T) {
+	defer func(reduce bool, rules []config.AnalysisRule) {
+		config.Config.ReduceReplicationAnalysisCount = reduce
+		config.Config.CustomAnalysisRules = rules
+		readAnalysisTopologyFunc = readAnalysisTopology
+	}(config.Config.ReduceReplicationAnalysisCount, config.Config.CustomAnalysisRules)
+	config.Config.ReduceReplicationAnalysisCount = true
+	config.Config.CustomAnalysisRules = []config.AnalysisRule{}
+
+	master := newAnalysisTestMaster("master")
+	writableSlave := newAnalysisTestSlave("slave1", master)
+	writableSlave.ReadOnly = false
+	healthySlave := newAnalysisTestSlave("slave2", master)
+	readAnalysisTopologyFunc = func() (*AnalysisTopology, error) {
+		return NewAnalysisTopology([]*AnalysisInstance{master, writableSlave, healthySlave}, nil), nil
+	}
+
+	analysisEntries, err := GetReplicationAnalysis("", true, false)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(analysisEntries), 1)
+	test.S(t).ExpectEquals(analysisEntries[0].AnalyzedInstanceKey.Hostname, "slave1")
+	test.S(t).ExpectEquals(analysisEntries[0].Analysis, AnalysisCode(WritableSlave))
+}
@@ -0,0 +1,585 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+var failingToConnectToMasterRegexp = regexp.MustCompile(`(?i)error (connecting|reconnecting) to master`)
+
+// AnalysisInstance is the set of raw facts known about a single instance, as loaded from the backend database.
+// Replication analysis is computed solely based on these facts.
+type AnalysisInstance struct {
+	Key                      InstanceKey
+	MasterKey                InstanceKey
+	ClusterName              string
+	ClusterAlias             string
+	Version                  string
+	ReadOnly                 bool
+	LastCheckValid           bool // last check was successful, i.e. last_checked <= last_seen
+	LastAttemptedCheckValid  bool // no check has been attempted since instance was last seen, beyond grace period
+	IsCoMaster               bool
+	ReplicationDepth         uint
+	Slave_SQL_Running        bool
+	Slave_IO_Running         bool
+	LastIOError              string
+	IsStale                  bool // relay log position has not changed between the two most recent checks
+	IsBinlogServer           bool
+	UsingPseudoGTID          bool
+	UsingOracleGTID          bool
+	UsingMariaDBGTID         bool
+	LogBinEnabled            bool
+	LogSlaveUpdatesEnabled   bool
+	Binlog_format            string
+	InMaintenance            bool
+	IsDowntimed              bool
+	DowntimeEndTimestamp     string
+	DowntimeRemainingSeconds int
+	RecoveryInProgress       bool // a recovery or a cluster lock is in progress on this instance or on its cluster
+	HasAgent                 bool // an orchestrator-agent is known on this instance's host
+	AgentLastSeenValid       bool // the agent has been successfully polled recently, hence its inventory is up to date
+	AgentMySQLRunning        bool
+	AgentMySQLStatusValid    bool // the agent responded to its last MySQL status inquiry, hence AgentMySQLRunning is known
+	AgentDatadirUsedPercent  float64
+	AgentDatadirDiskFree     int64
+	AgentErrorLogCrashMarker string // most recent error log line matching AgentErrorLogCrashPatterns
+
+	ReplicationChannel  string               // the replication channel the replication facts above are of
+	ReplicationChannels []ReplicationChannel // all replication channels, on a multi-source instance
+
+	ReplicationGroupName                   string
+	ReplicationGroupMemberState            string
+	ReplicationGroupMemberRole             string
+	ReplicationGroupMembers                []ReplicationGroupMember // the group's members, as seen by this instance
+	ReplicationGroupPrimaryRecentlyChanged bool                     // this instance has recently seen its group's primary change
+
+	GaleraClusterStateUUID  string
+	GaleraClusterStatus     string
+	GaleraLocalStateComment string
+}
+
+// withReplicationChannel returns a copy of this instance, whose replication facts are those of given channel
+func (this *AnalysisInstance) withReplicationChannel(channel *ReplicationChannel) *AnalysisInstance {
+	instance := *this
+	instance.ReplicationChannel = channel.Name
+	instance.MasterKey = channel.MasterKey
+	instance.Slave_SQL_Running = channel.Slave_SQL_Running
+	instance.Slave_IO_Running = channel.Slave_IO_Running
+	instance.LastIOError = channel.LastIOError
+	instance.UsingOracleGTID = channel.UsingOracleGTID
+	instance.UsingMariaDBGTID = channel.UsingMariaDBGTID
+	// Relay log history is only kept for the primary channel
+	instance.IsStale = false
+	return &instance
+}
+
+// IsMaster returns true when this instance is not configured to replicate from anywhere
+func (this *AnalysisInstance) IsMaster() bool {
+	return this.MasterKey.Hostname == "" || this.MasterKey.Hostname == "_" || this.MasterKey.Port == 0 || strings.HasPrefix(this.MasterKey.Hostname, "//")
+}
+
+// IsClusterMaster returns true when this instance is the one its cluster is named by
+func (this *AnalysisInstance) IsClusterMaster() bool {
+	return this.Key.StringCode() == this.ClusterName
+}
+
+// IsFailingToConnectToMaster returns true when the IO thread is down due to connection problems while the SQL thread is running
+func (this *AnalysisInstance) IsFailingToConnectToMaster() bool {
+	return this.Slave_SQL_Running && !this.Slave_IO_Running && failingToConnectToMasterRegexp.MatchString(this.LastIOError)
+}
+
+// IsClusterWriter returns true when this instance is reachable, writeable and not replicating: either a master or
+// a slave whose replication is stopped
+func (this *AnalysisInstance) IsClusterWriter() bool {
+	if !this.LastCheckValid || !this.LastAttemptedCheckValid {
+		return false
+	}
+	return !this.ReadOnly && !this.IsBinlogServer && !(this.Slave_SQL_Running && this.Slave_IO_Running)
+}
+
+// IsReplicationGroupMember returns true when this instance is an active member of a replication group
+func (this *AnalysisInstance) IsReplicationGroupMember() bool {
+	return this.ReplicationGroupName != "" && this.ReplicationGroupMemberState != "" && this.ReplicationGroupMemberState != ReplicationGroupMemberStateOffline
+}
+
+// IsGaleraNode returns true when this instance is a Galera node
+func (this *AnalysisInstance) IsGaleraNode() bool {
+	return this.GaleraClusterStateUUID != ""
+}
+
+// multiWriterGroup returns a name for the virtually synchronous group (replication group, Galera cluster) this instance
+// is a member of, if any. All writers of such group make for a single writer.
+func (this *AnalysisInstance) multiWriterGroup() string {
+	if this.IsReplicationGroupMember() {
+		return "replication-group:" + this.ReplicationGroupName
+	}
+	if this.IsGaleraNode() {
+		return "galera:" + this.GaleraClusterStateUUID
+	}
+	return ""
+}
+
+// IsLoggingSlaveUpdates returns true when this instance writes replicated changes into its own binary logs
+func (this *AnalysisInstance) IsLoggingSlaveUpdates() bool {
+	return this.LogBinEnabled && this.LogSlaveUpdatesEnabled
+}
+
+// AnalysisTopology is an in-memory model of all known instances and the replication relations between them
+type AnalysisTopology struct {
+	Instances        []*AnalysisInstance
+	slaves           map[InstanceKey][]*AnalysisInstance
+	replicationGroup map[string][]*AnalysisInstance
+}
+
+// NewAnalysisTopology creates a topology model out of given instances. resolvedHostnames maps a hostname onto
+// its resolved hostname, which is how slaves refer to their masters. A multi-source instance is a slave of each
+// of its channels' masters, presented by the respective channel.
+func NewAnalysisTopology(instances []*AnalysisInstance, resolvedHostnames map[string]string) *AnalysisTopology {
+	topology := &AnalysisTopology{
+		Instances:        instances,
+		slaves:           make(map[InstanceKey][]*AnalysisInstance),
+		replicationGroup: make(map[string][]*AnalysisInstance),
+	}
+	mastersByResolvedKey := make(map[InstanceKey][]InstanceKey)
+	for _, instance := range instances {
+		resolvedKey := instance.Key
+		if resolvedHostname, found := resolvedHostnames[instance.Key.Hostname]; found && resolvedHostname != "" {
+			resolvedKey.Hostname = resolvedHostname
+		}
+		mastersByResolvedKey[resolvedKey] = append(mastersByResolvedKey[resolvedKey], instance.Key)
+	}
+	for _, instance := range instances {
+		if instance.IsReplicationGroupMember() {
+			topology.replicationGroup[instance.ReplicationGroupName] = append(topology.replicationGroup[instance.ReplicationGroupName], instance)
+		}
+		for _, masterKey := range mastersByResolvedKey[instance.MasterKey] {
+			topology.slaves[masterKey] = append(topology.slaves[masterKey], instance)
+		}
+		for i := range instance.ReplicationChannels {
+			channel := &instance.ReplicationChannels[i]
+			if channel.Name == instance.ReplicationChannel {
+				continue
+			}
+			for _, masterKey := range mastersByResolvedKey[channel.MasterKey] {
+				topology.slaves[masterKey] = append(topology.slaves[masterKey], instance.withReplicationChannel(channel))
+			}
+		}
+	}
+	return topology
+}
+
+// GetSlaves returns the instances replicating from given instance
+func (this *AnalysisTopology) GetSlaves(instanceKey *InstanceKey) []*AnalysisInstance {
+	return this.slaves[*instanceKey]
+}
+
+// GetReplicationGroupMembers returns the known members of given replication group
+func (this *AnalysisTopology) GetReplicationGroupMembers(groupName string) []*AnalysisInstance {
+	return this.replicationGroup[groupName]
+}
+
+// AnalyzeTopology returns the replication analysis of all instances in given cluster (or of all clusters, when
+// clusterName is empty). Instances under maintenance are not analyzed. No filtering for downtime or ignored hostnames is
+// applied, and entries with no problem are included as well.
+func AnalyzeTopology(topology *AnalysisTopology, clusterName string) []ReplicationAnalysis {
+	result := []ReplicationAnalysis{}
+	// Writers are counted per cluster alias, so that a detached old master, which has since become a cluster
+	// of its own, is still considered
+	clusterWriters := make(map[string]uint)
+	countedMultiWriterGroups := make(map[string]bool)
+	for _, instance := range topology.Instances {
+		if !instance.IsClusterWriter() {
+			continue
+		}
+		if multiWriterGroup := instance.multiWriterGroup(); multiWriterGroup != "" {
+			// A multi-primary replication group or a Galera cluster is a single writer
+			if countedMultiWriterGroups[multiWriterGroup] {
+				continue
+			}
+			countedMultiWriterGroups[multiWriterGroup] = true
+		}
+		clusterWriters[instance.ClusterAlias]++
+	}
+	for _, instance := range topology.Instances {
+		if instance.InMaintenance {
+			continue
+		}
+		if clusterName != "" && instance.ClusterName != clusterName {
+			continue
+		}
+		result = append(result, analyzeInstance(instance, topology.GetSlaves(&instance.Key), topology.GetReplicationGroupMembers(instance.ReplicationGroupName), clusterWriters[instance.ClusterAlias]))
+	}
+	sort.Stable(replicationAnalysisByTopologyOrder(result))
+	return result
+}
+
+// analyzeInstance aggregates facts of given instance, its slaves and its replication group peers (if any) and then analyzes them
+func analyzeInstance(instance *AnalysisInstance, slaves []*AnalysisInstance, replicationGroupMembers []*AnalysisInstance, countClusterWriters uint) ReplicationAnalysis {
+	a := ReplicationAnalysis{Analysis: NoProblem}
+
+	a.AnalyzedInstanceKey = instance.Key
+	a.AnalyzedInstanceMasterKey = instance.MasterKey
+	a.ClusterDetails.ClusterName = instance.ClusterName
+	a.ClusterDetails.ClusterAlias = instance.ClusterAlias
+	a.IsMaster = instance.IsMaster()
+	a.IsClusterMaster = instance.IsClusterMaster()
+	a.IsCoMaster = instance.IsCoMaster
+	a.LastCheckValid = instance.LastCheckValid && instance.LastAttemptedCheckValid
+	a.ReplicationDepth = instance.ReplicationDepth
+	a.IsFailingToConnectToMaster = instance.IsFailingToConnectToMaster()
+	a.IsDowntimed = instance.IsDowntimed
+	a.DowntimeEndTimestamp = instance.DowntimeEndTimestamp
+	a.DowntimeRemainingSeconds = instance.DowntimeRemainingSeconds
+	a.IsBinlogServer = instance.IsBinlogServer
+	a.IsReadOnly = instance.ReadOnly
+	a.IsClusterWriter = instance.IsClusterWriter()
+	a.CountClusterWriters = countClusterWriters
+	a.IsRecoveryInProgress = instance.RecoveryInProgress
+	a.PseudoGTIDImmediateTopology = instance.UsingPseudoGTID
+	a.HasAgent = instance.HasAgent
+	a.AgentLastSeenValid = instance.AgentLastSeenValid
+	a.AgentMySQLRunning = instance.AgentMySQLRunning
+	a.AgentMySQLStatusValid = instance.AgentMySQLStatusValid
+	a.AgentDatadirDiskUsedPercent = instance.AgentDatadirUsedPercent
+	a.AgentDatadirDiskFree = instance.AgentDatadirDiskFree
+	a.AgentErrorLogCrashMarker = instance.AgentErrorLogCrashMarker
+	a.SlaveHosts = *NewInstanceKeyMap()
+	if instance.IsReplicationGroupMember() {
+		aggregateReplicationGroupFacts(&a, instance, replicationGroupMembers)
+	}
+	if instance.IsGaleraNode() {
+		a.IsGaleraNode = true
+		a.GaleraClusterStatus = instance.GaleraClusterStatus
+		a.GaleraLocalStateComment = instance.GaleraLocalStateComment
+	}
+
+	var countValidOracleGTIDSlaves, countValidMariaDBGTIDSlaves, countValidBinlogServerSlaves uint
+	loggingMajorVersions := make(map[string]bool)
+	for _, slave := range slaves {
+		a.CountSlaves++
+		a.SlaveHosts.AddKey(slave.Key)
+		if slave.IsStale {
+			a.CountStaleSlaves++
+		}
+		if slave.IsLoggingSlaveUpdates() {
+			switch slave.Binlog_format {
+			case "STATEMENT":
+				a.CountStatementBasedLoggingSlaves++
+			case "MIXED":
+				a.CountMixedBasedLoggingSlaves++
+			case "ROW":
+				a.CountRowBasedLoggingSlaves++
+			}
+			loggingMajorVersions[strings.Join(MajorVersion(slave.Version), ".")] = true
+		}
+		if !slave.LastCheckValid {
+			continue
+		}
+		a.CountValidSlaves++
+		if slave.Slave_IO_Running && slave.Slave_SQL_Running {
+			a.CountValidReplicatingSlaves++
+		}
+		if slave.IsFailingToConnectToMaster() {
+			a.CountSlavesFailingToConnectToMaster++
+		}
+		if slave.UsingOracleGTID {
+			countValidOracleGTIDSlaves++
+		}
+		if slave.UsingMariaDBGTID {
+			countValidMariaDBGTIDSlaves++
+		}
+		if slave.IsBinlogServer {
+			countValidBinlogServerSlaves++
+		}
+	}
+	a.CountDistinctMajorVersionsLoggingSlaves = uint(len(loggingMajorVersions))
+	a.OracleGTIDImmediateTopology = countValidOracleGTIDSlaves == a.CountValidSlaves && a.CountValidSlaves > 0
+	a.MariaDBGTIDImmediateTopology = countValidMariaDBGTIDSlaves == a.CountValidSlaves && a.CountValidSlaves > 0
+	a.BinlogServerImmediateTopology = countValidBinlogServerSlaves == a.CountValidSlaves && a.CountValidSlaves > 0
+
+	analyzeReplication(&a)
+	analyzeStructure(&a)
+	analyzeAgent(&a)
+	return a
+}
+
+// aggregateReplicationGroupFacts sets the analysis facts of a replication group member, based on its own view of the
+// group as well as on its peers' views
+func aggregateReplicationGroupFacts(a *ReplicationAnalysis, instance *AnalysisInstance, peers []*AnalysisInstance) {
+	a.IsReplicationGroupMember = true
+	a.ReplicationGroupName = instance.ReplicationGroupName
+	a.ReplicationGroupMemberState = instance.ReplicationGroupMemberState
+	a.ReplicationGroupMemberRole = instance.ReplicationGroupMemberRole
+	a.ReplicationGroupPrimaryRecentlyChanged = instance.ReplicationGroupPrimaryRecentlyChanged
+	a.CountReplicationGroupMembers = uint(len(instance.ReplicationGroupMembers))
+	for _, member := range instance.ReplicationGroupMembers {
+		if member.IsReachable() {
+			a.CountReachableReplicationGroupMembers++
+		}
+	}
+	for _, peer := range peers {
+		if peer.Key.Equals(&instance.Key) || !peer.LastCheckValid {
+			continue
+		}
+		for _, member := range peer.ReplicationGroupMembers {
+			if member.Key.Equals(&instance.Key) && member.State == ReplicationGroupMemberStateUnreachable {
+				a.IsReplicationGroupMemberUnreachableByPeers = true
+			}
+		}
+	}
+}
+
+// analyzeReplicationGroup sets the analysis code and description of a replication group member, returning true when
+// a group problem is found. Membership and failover in a group are managed by the group itself, hence group members
+// are not subject to master analysis.
+func analyzeReplicationGroup(a *ReplicationAnalysis) bool {
+	if !a.LastCheckValid || a.IsReplicationGroupMemberUnreachableByPeers {
+		a.Analysis = ReplicationGroupMemberUnreachable
+		a.Description = "Replication group member cannot be reached by orchestrator or is reported unreachable by its peers"
+		//
+	} else if a.ReplicationGroupMemberState == ReplicationGroupMemberStateError {
+		a.Analysis = ReplicationGroupMemberError
+		a.Description = "Replication group member is in ERROR state and has left the group"
+		//
+	} else if a.CountReachableReplicationGroupMembers*2 <= a.CountReplicationGroupMembers {
+		a.Analysis = ReplicationGroupLostQuorum
+		a.Description = "Replication group member sees no majority of reachable members; the group cannot accept writes"
+		//
+	} else if a.ReplicationGroupMemberRole == ReplicationGroupMemberRolePrimary && a.ReplicationGroupPrimaryRecentlyChanged {
+		a.Analysis = ReplicationGroupPrimaryChanged
+		a.Description = "Replication group has recently elected this member as its new primary"
+		//
+	} else {
+		return false
+	}
+	return true
+}
+
+// analyzeGalera sets the analysis code and description of a Galera node, returning true when a problem is found.
+// Galera nodes are not subject to master analysis.
+func analyzeGalera(a *ReplicationAnalysis) bool {
+	if !a.LastCheckValid {
+		a.Analysis = UnreachableGaleraNode
+		a.Description = "Galera node cannot be reached by orchestrator"
+		//
+	} else if a.GaleraClusterStatus != GaleraClusterStatusPrimary {
+		a.Analysis = GaleraNonPrimaryComponent
+		a.Description = fmt.Sprintf("Galera node is in a %s component and does not serve queries", a.GaleraClusterStatus)
+		//
+	} else if a.GaleraLocalStateComment != GaleraLocalStateCommentSynced {
+		a.Analysis = GaleraNodeDesynced
+		a.Description = fmt.Sprintf("Galera node is not synced with its cluster: %s", a.GaleraLocalStateComment)
+		//
+	} else {
+		return false
+	}
+	return true
+}
+
+// analyzeReplication sets the analysis code and description based on the aggregated facts
+func analyzeReplication(a *ReplicationAnalysis) {
+	if a.IsReplicationGroupMember && analyzeReplicationGroup(a) {
+		return
+	}
+	if a.IsGaleraNode && analyzeGalera(a) {
+		return
+	}
+	if a.IsMaster && !a.LastCheckValid && a.CountSlaves == 0 {
+		a.Analysis = DeadMasterWithoutSlaves
+		a.Description = "Master cannot be reached by orchestrator and has no slave"
+		//
+	} else if a.IsMaster && !a.LastCheckValid && a.CountValidSlaves == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = DeadMaster
+		a.Description = "Master cannot be reached by orchestrator and none of its slaves is replicating"
+		//
+	} else if a.IsMaster && !a.LastCheckValid && a.CountSlaves > 0 && a.CountValidSlaves == 0 && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = DeadMasterAndSlaves
+		a.Description = "Master cannot be reached by orchestrator and none of its slaves is replicating"
+		//
+	} else if a.IsMaster && !a.LastCheckValid && a.CountValidSlaves < a.CountSlaves && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = DeadMasterAndSomeSlaves
+		a.Description = "Master cannot be reached by orchestrator; some of its slaves are unreachable and none of its reachable slaves is replicating"
+		//
+	} else if a.IsMaster && !a.LastCheckValid && a.CountStaleSlaves == a.CountSlaves && a.CountValidReplicatingSlaves > 0 {
+		a.Analysis = UnreachableMasterWithStaleSlaves
+		a.Description = "Master cannot be reached by orchestrator and has running yet stale slaves"
+		//
+	} else if a.IsMaster && !a.LastCheckValid && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves > 0 {
+		a.Analysis = UnreachableMaster
+		a.Description = "Master cannot be reached by orchestrator but it has replicating slaves; possibly a network/host issue"
+		//
+	} else if a.IsMaster && a.LastCheckValid && a.CountSlaves == 1 && a.CountValidSlaves == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = MasterSingleSlaveNotReplicating
+		a.Description = "Master is reachable but its single slave is not replicating"
+		//
+	} else if a.IsMaster && a.LastCheckValid && a.CountSlaves == 1 && a.CountValidSlaves == 0 {
+		a.Analysis = MasterSingleSlaveDead
+		a.Description = "Master is reachable but its single slave is dead"
+		//
+	} else if a.IsMaster && a.LastCheckValid && a.CountSlaves > 1 && a.CountValidSlaves == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = AllMasterSlavesNotReplicating
+		a.Description = "Master is reachable but none of its slaves is replicating"
+		//
+	} else if a.IsMaster && a.LastCheckValid && a.CountSlaves > 1 && a.CountValidSlaves < a.CountSlaves && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = AllMasterSlavesNotReplicatingOrDead
+		a.Description = "Master is reachable but none of its slaves is replicating"
+		//
+	} else if a.IsMaster && a.LastCheckValid && a.CountSlaves > 1 && a.CountStaleSlaves == a.CountSlaves && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves > 0 {
+		a.Analysis = AllMasterSlavesStale
+		a.Description = "Master is reachable but all of its slaves are stale, although attempting to replicate"
+		//
+	} else /* co-master */ if a.IsCoMaster && !a.LastCheckValid && a.CountSlaves > 0 && a.CountValidSlaves == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = DeadCoMaster
+		a.Description = "Co-master cannot be reached by orchestrator and none of its slaves is replicating"
+		//
+	} else if a.IsCoMaster && !a.LastCheckValid && a.CountSlaves > 0 && a.CountValidSlaves < a.CountSlaves && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = DeadCoMasterAndSomeSlaves
+		a.Description = "Co-master cannot be reached by orchestrator; some of its slaves are unreachable and none of its reachable slaves is replicating"
+		//
+	} else if a.IsCoMaster && !a.LastCheckValid && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves > 0 {
+		a.Analysis = UnreachableCoMaster
+		a.Description = "Co-master cannot be reached by orchestrator but it has replicating slaves; possibly a network/host issue"
+		//
+	} else if a.IsCoMaster && a.LastCheckValid && a.CountSlaves > 0 && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = AllCoMasterSlavesNotReplicating
+		a.Description = "Co-master is reachable but none of its slaves is replicating"
+		//
+	} else /* intermediate-master */ if !a.IsMaster && !a.LastCheckValid && a.CountSlaves == 1 && a.CountValidSlaves == a.CountSlaves && a.CountSlavesFailingToConnectToMaster == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = DeadIntermediateMasterWithSingleSlaveFailingToConnect
+		a.Description = "Intermediate master cannot be reached by orchestrator and its (single) slave is failing to connect"
+		//
+	} else /* intermediate-master */ if !a.IsMaster && !a.LastCheckValid && a.CountSlaves == 1 && a.CountValidSlaves == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = DeadIntermediateMasterWithSingleSlave
+		a.Description = "Intermediate master cannot be reached by orchestrator and its (single) slave is not replicating"
+		//
+	} else /* intermediate-master */ if !a.IsMaster && !a.LastCheckValid && a.CountSlaves > 1 && a.CountValidSlaves == a.CountSlaves && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = DeadIntermediateMaster
+		a.Description = "Intermediate master cannot be reached by orchestrator and none of its slaves is replicating"
+		//
+	} else if !a.IsMaster && !a.LastCheckValid && a.CountValidSlaves < a.CountSlaves && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = DeadIntermediateMasterAndSomeSlaves
+		a.Description = "Intermediate master cannot be reached by orchestrator; some of its slaves are unreachable and none of its reachable slaves is replicating"
+		//
+	} else if !a.IsMaster && !a.LastCheckValid && a.CountValidSlaves > 0 && a.CountValidReplicatingSlaves > 0 {
+		a.Analysis = UnreachableIntermediateMaster
+		a.Description = "Intermediate master cannot be reached by orchestrator but it has replicating slaves; possibly a network/host issue"
+		//
+	} else if !a.IsMaster && a.LastCheckValid && a.CountSlaves > 1 && a.CountValidReplicatingSlaves == 0 &&
+		a.CountSlavesFailingToConnectToMaster > 0 && a.CountSlavesFailingToConnectToMaster == a.CountValidSlaves {
+		// All slaves are either failing to connect to master (and at least one of these have to exist)
+		// or completely dead.
+		// Must have at least two slaves to reach such conclusion -- do note that the intermediate master is still
+		// reachable to orchestrator, so we base our conclusion on slaves only at this point.
+		a.Analysis = AllIntermediateMasterSlavesFailingToConnectOrDead
+		a.Description = "Intermediate master is reachable but all of its slaves are failing to connect"
+		//
+	} else if !a.IsMaster && a.LastCheckValid && a.CountSlaves > 0 && a.CountValidReplicatingSlaves == 0 {
+		a.Analysis = AllIntermediateMasterSlavesNotReplicating
+		a.Description = "Intermediate master is reachable but none of its slaves is replicating"
+		//
+	} else if a.IsBinlogServer && a.IsFailingToConnectToMaster {
+		a.Analysis = BinlogServerFailingToConnectToMaster
+		a.Description = "Binlog server is unable to connect to its master"
+		//
+	} else if a.ReplicationDepth == 1 && a.IsFailingToConnectToMaster {
+		a.Analysis = FirstTierSlaveFailingToConnectToMaster
+		a.Description = "1st tier slave (directly replicating from topology master) is unable to connect to the master"
+		//
+	} else if a.IsClusterWriter && a.CountClusterWriters > 1 {
+		a.Analysis = MultipleWritersInCluster
+		a.Description = "Instance is writeable and not replicating, as are other instances in its cluster; possibly split brain"
+		//
+	} else if a.IsMaster && a.LastCheckValid && a.IsReadOnly && !a.IsBinlogServer && !a.IsRecoveryInProgress && a.ReplicationGroupMemberRole != ReplicationGroupMemberRoleSecondary {
+		a.Analysis = ReadOnlyMaster
+		a.Description = "Master is read-only, and no recovery is in progress"
+		//
+	} else if !a.IsMaster && !a.IsCoMaster && a.LastCheckValid && !a.IsReadOnly && !a.IsBinlogServer {
+		a.Analysis = WritableSlave
+		a.Description = "Slave is writeable (read_only=0)"
+		//
+	}
+	//		 else if a.IsMaster && a.CountSlaves == 0 {
+	//			a.Analysis = MasterWithoutSlaves
+	//			a.Description = "Master has no slaves"
+	//		}
+}
+
+// analyzeStructure adds structural warnings: setups where a promotion is potentially dangerous
+func analyzeStructure(a *ReplicationAnalysis) {
+	if a.IsMaster && a.CountStatementBasedLoggingSlaves > 0 && a.CountMixedBasedLoggingSlaves > 0 {
+		a.StructureAnalysis = append(a.StructureAnalysis, StatementAndMixedLoggingSlavesStructureWarning)
+	}
+	if a.IsMaster && a.CountStatementBasedLoggingSlaves > 0 && a.CountRowBasedLoggingSlaves > 0 {
+		a.StructureAnalysis = append(a.StructureAnalysis, StatementAndRowLoggingSlavesStructureWarning)
+	}
+	if a.IsMaster && a.CountMixedBasedLoggingSlaves > 0 && a.CountRowBasedLoggingSlaves > 0 {
+		a.StructureAnalysis = append(a.StructureAnalysis, MixedAndRowLoggingSlavesStructureWarning)
+	}
+	if a.IsMaster && a.CountDistinctMajorVersionsLoggingSlaves > 1 {
+		a.StructureAnalysis = append(a.StructureAnalysis, MultipleMajorVersionsLoggingSlaves)
+	}
+}
+
+// analyzeAgent adds detections based on the host inventory reported by the instance's agent. An agent which has
+// not been polled recently has nothing to say
+func analyzeAgent(a *ReplicationAnalysis) {
+	if !a.HasAgent || !a.AgentLastSeenValid {
+		return
+	}
+	hasDiskInventory := a.AgentDatadirDiskUsedPercent > 0 || a.AgentDatadirDiskFree > 0
+	diskUsedPercentThreshold := config.Config.AgentDatadirDiskUsedPercentThreshold
+	diskFreeThreshold := config.Config.AgentDatadirDiskFreeThresholdBytes
+	if hasDiskInventory && ((diskUsedPercentThreshold > 0 && a.AgentDatadirDiskUsedPercent >= diskUsedPercentThreshold) || (diskFreeThreshold > 0 && a.AgentDatadirDiskFree < diskFreeThreshold)) {
+		a.AgentAnalysis = append(a.AgentAnalysis, AgentAnalysis{
+			Code:        DatadirDiskAlmostFull,
+			Description: fmt.Sprintf("Datadir disk is %.1f%% full; %d bytes free", a.AgentDatadirDiskUsedPercent, a.AgentDatadirDiskFree),
+		})
+	}
+	if a.AgentMySQLStatusValid && !a.AgentMySQLRunning {
+		a.AgentAnalysis = append(a.AgentAnalysis, AgentAnalysis{
+			Code:        MySQLNotRunningOnAgentHost,
+			Description: "Agent is up, but reports MySQL is not running",
+		})
+	}
+	if a.AgentErrorLogCrashMarker != "" {
+		a.AgentAnalysis = append(a.AgentAnalysis, AgentAnalysis{
+			Code:        ErrorLogCrashMarker,
+			Description: fmt.Sprintf("MySQL error log contains a crash marker: %s", a.AgentErrorLogCrashMarker),
+		})
+	}
+}
+
+// replicationAnalysisByTopologyOrder sorts masters first, then cluster masters, then by number of slaves, descending
+type replicationAnalysisByTopologyOrder []ReplicationAnalysis
+
+func (this replicationAnalysisByTopologyOrder) Len() int      { return len(this) }
+func (this replicationAnalysisByTopologyOrder) Swap(i, j int) { this[i], this[j] = this[j], this[i] }
+func (this replicationAnalysisByTopologyOrder) Less(i, j int) bool {
+	if this[i].IsMaster != this[j].IsMaster {
+		return this[i].IsMaster
+	}
+	if this[i].IsClusterMaster != this[j].IsClusterMaster {
+		return this[i].IsClusterMaster
+	}
+	return this[i].CountSlaves > this[j].CountSlaves
+}
@@ -0,0 +1,555 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Binary log event types, as listed in MySQL's and MariaDB's log_event.h
+const (
+	binlogQueryEvent             byte = 2
+	binlogStopEvent              byte = 3
+	binlogRotateEvent            byte = 4
+	binlogIntvarEvent            byte = 5
+	binlogRandEvent              byte = 13
+	binlogUserVarEvent           byte = 14
+	binlogFormatDescriptionEvent byte = 15
+	binlogXidEvent               byte = 16
+	binlogTableMapEvent          byte = 19
+	binlogWriteRowsEventV1       byte = 23
+	binlogUpdateRowsEventV1      byte = 24
+	binlogDeleteRowsEventV1      byte = 25
+	binlogRowsQueryEvent         byte = 29
+	binlogWriteRowsEventV2       byte = 30
+	binlogUpdateRowsEventV2      byte = 31
+	binlogDeleteRowsEventV2      byte = 32
+	binlogGTIDEvent              byte = 33
+	binlogAnonymousGTIDEvent     byte = 34
+	binlogPreviousGTIDsEvent     byte = 35
+	mariadbAnnotateRowsEvent     byte = 160
+	mariadbBinlogCheckpointEvent byte = 161
+	mariadbGTIDEvent             byte = 162
+	mariadbGTIDListEvent         byte = 163
+)
+
+// binlogEventTypeNames maps event types onto their names as presented by SHOW BINLOG EVENTS
+var binlogEventTypeNames = map[byte]string{
+	1:                            "Start_v3",
+	binlogQueryEvent:             "Query",
+	binlogStopEvent:              "Stop",
+	binlogRotateEvent:            "Rotate",
+	binlogIntvarEvent:            "Intvar",
+	6:                            "Load",
+	7:                            "Slave",
+	8:                            "Create_file",
+	9:                            "Append_block",
+	10:                           "Exec_load",
+	11:                           "Delete_file",
+	12:                           "New_load",
+	binlogRandEvent:              "RAND",
+	binlogUserVarEvent:           "User var",
+	binlogFormatDescriptionEvent: "Format_desc",
+	binlogXidEvent:               "Xid",
+	17:                           "Begin_load_query",
+	18:                           "Execute_load_query",
+	binlogTableMapEvent:          "Table_map",
+	binlogWriteRowsEventV1:       "Write_rows_v1",
+	binlogUpdateRowsEventV1:      "Update_rows_v1",
+	binlogDeleteRowsEventV1:      "Delete_rows_v1",
+	26:                           "Incident",
+	27:                           "Heartbeat",
+	28:                           "Ignorable",
+	binlogRowsQueryEvent:         "Rows_query",
+	binlogWriteRowsEventV2:       "Write_rows",
+	binlogUpdateRowsEventV2:      "Update_rows",
+	binlogDeleteRowsEventV2:      "Delete_rows",
+	binlogGTIDEvent:              "Gtid",
+	binlogAnonymousGTIDEvent:     "Anonymous_Gtid",
+	binlogPreviousGTIDsEvent:     "Previous_gtids",
+	36:                           "Transaction_context",
+	37:                           "View_change",
+	38:                           "XA_prepare",
+	mariadbAnnotateRowsEvent:     "Annotate_rows",
+	mariadbBinlogCheckpointEvent: "Binlog_checkpoint",
+	mariadbGTIDEvent:             "Gtid",
+	mariadbGTIDListEvent:         "Gtid_list",
+	164:                          "Start_encryption",
+}
+
+// binlogFileMagic is the header of any binary log file
+var binlogFileMagic = []byte{0xfe, 'b', 'i', 'n'}
+
+const (
+	binlogEventHeaderLength        = 19
+	binlogChecksumLength           = 4
+	binlogChecksumAlgorithmCRC32   = 1
+	binlogEventSuppressUseFlag     = 0x0008
+	binlogEventArtificialFlag      = 0x0020
+	binlogRowsStatementEndFlag     = 0x0001
+	mariadbGTIDStandaloneFlag      = 0x01
+	mariadbGTIDGroupCommitIDFlag   = 0x02
+	binlogQueryDefaultPostHeader   = 13
+	binlogRotateDefaultPostHeader  = 8
+	binlogTableIdDefaultPostHeader = 8
+)
+
+// binlogEventHeader is the common header of all binary log events (binlog format v4)
+type binlogEventHeader struct {
+	Timestamp uint32
+	EventType byte
+	ServerId  uint32
+	EventSize uint32
+	LogPos    uint32
+	Flags     uint16
+}
+
+// isArtificial returns true for events which do not actually exist in the binary log at their reported position,
+// such as the fake rotate event sent by the master at the beginning of a binlog dump
+func (this *binlogEventHeader) isArtificial() bool {
+	return this.LogPos == 0 || this.Flags&binlogEventArtificialFlag != 0
+}
+
+// binlogFormatDescription is the parsed format description event, which describes the layout of all following events
+type binlogFormatDescription struct {
+	BinlogVersion     uint16
+	ServerVersion     string
+	PostHeaderLengths []byte
+	HasChecksum       bool
+}
+
+// postHeaderLength returns the post-header length of given event type, or given default when unknown
+func (this *binlogFormatDescription) postHeaderLength(eventType byte, defaultLength int) int {
+	if this == nil || eventType == 0 || int(eventType) > len(this.PostHeaderLengths) {
+		return defaultLength
+	}
+	return int(this.PostHeaderLengths[eventType-1])
+}
+
+// serverVersionSupportsBinlogChecksum returns true when the given server version writes a checksum
+// algorithm descriptor in its format description event: MySQL 5.6.1 and above, MariaDB 5.3 and above
+func serverVersionSupportsBinlogChecksum(serverVersion string) bool {
+	numericVersion := strings.SplitN(serverVersion, "-", 2)[0]
+	tokens := strings.Split(numericVersion, ".")
+	version := []int{0, 0, 0}
+	for i := 0; i < len(tokens) && i < len(version); i++ {
+		version[i], _ = strconv.Atoi(tokens[i])
+	}
+	minimalVersion := []int{5, 6, 1}
+	if strings.Contains(serverVersion, "MariaDB") {
+		minimalVersion = []int{5, 3, 0}
+	}
+	for i := range version {
+		if version[i] != minimalVersion[i] {
+			return version[i] > minimalVersion[i]
+		}
+	}
+	return true
+}
+
+// parseBinlogFormatDescription parses the body of a format description event
+func parseBinlogFormatDescription(body []byte) (*binlogFormatDescription, error) {
+	// binlog version (2), server version (50), create timestamp (4), header length (1), post-header lengths
+	const fixedPartLength = 2 + 50 + 4 + 1
+	if len(body) < fixedPartLength {
+		return nil, fmt.Errorf("Format description event too short: %d bytes", len(body))
+	}
+	format := &binlogFormatDescription{
+		BinlogVersion: binary.LittleEndian.Uint16(body[0:2]),
+		ServerVersion: string(bytes.TrimRight(body[2:52], "\x00")),
+	}
+	if headerLength := body[56]; headerLength != binlogEventHeaderLength {
+		return nil, fmt.Errorf("Unsupported binlog event header length: %d", headerLength)
+	}
+	postHeaderLengths := body[fixedPartLength:]
+	if serverVersionSupportsBinlogChecksum(format.ServerVersion) {
+		// The format description event always carries the checksum algorithm descriptor and a checksum
+		if len(postHeaderLengths) < 1+binlogChecksumLength {
+			return nil, fmt.Errorf("Format description event too short: %d bytes", len(body))
+		}
+		algorithm := postHeaderLengths[len(postHeaderLengths)-1-binlogChecksumLength]
+		format.HasChecksum = (algorithm == binlogChecksumAlgorithmCRC32)
+		postHeaderLengths = postHeaderLengths[:len(postHeaderLengths)-1-binlogChecksumLength]
+	}
+	format.PostHeaderLengths = postHeaderLengths
+	return format, nil
+}
+
+// binlogEventsParser parses raw binary log events into BinlogEvent entries, keeping track of the
+// format description and of the binary log the events belong to
+type binlogEventsParser struct {
+	format  *binlogFormatDescription
+	logFile string
+}
+
+func newBinlogEventsParser(logFile string) *binlogEventsParser {
+	return &binlogEventsParser{logFile: logFile}
+}
+
+// parseBinlogEventHeader parses the common header of a raw event
+func parseBinlogEventHeader(data []byte) (*binlogEventHeader, error) {
+	if len(data) < binlogEventHeaderLength {
+		return nil, fmt.Errorf("Binlog event too short: %d bytes", len(data))
+	}
+	return &binlogEventHeader{
+		Timestamp: binary.LittleEndian.Uint32(data[0:4]),
+		EventType: data[4],
+		ServerId:  binary.LittleEndian.Uint32(data[5:9]),
+		EventSize: binary.LittleEndian.Uint32(data[9:13]),
+		LogPos:    binary.LittleEndian.Uint32(data[13:17]),
+		Flags:     binary.LittleEndian.Uint16(data[17:19]),
+	}, nil
+}
+
+// hasValidChecksum returns true when the raw event ends with the CRC32 of its content
+func hasValidChecksum(data []byte) bool {
+	if len(data) < binlogEventHeaderLength+binlogChecksumLength {
+		return false
+	}
+	contentLength := len(data) - binlogChecksumLength
+	return crc32.ChecksumIEEE(data[:contentLength]) == binary.LittleEndian.Uint32(data[contentLength:])
+}
+
+// parseEvent parses a raw binary log event, header included. It returns a nil event (and no error) for
+// artificial events, which are consumed to update the parser's state.
+func (this *binlogEventsParser) parseEvent(data []byte) (*BinlogEvent, error) {
+	header, err := parseBinlogEventHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if int(header.EventSize) != len(data) {
+		return nil, fmt.Errorf("Binlog event size mismatch at %s:%d: header indicates %d bytes, got %d", this.logFile, header.LogPos, header.EventSize, len(data))
+	}
+	body := data[binlogEventHeaderLength:]
+	switch {
+	case header.EventType == binlogFormatDescriptionEvent:
+		format, err := parseBinlogFormatDescription(body)
+		if err != nil {
+			return nil, err
+		}
+		if format.HasChecksum && !hasValidChecksum(data) {
+			return nil, fmt.Errorf("Binlog event checksum mismatch at %s:%d", this.logFile, header.LogPos)
+		}
+		this.format = format
+	case this.format == nil:
+		// No format description yet: this is the fake rotate event preceding a binlog dump.
+		// It is checksummed if the master's events are.
+		if hasValidChecksum(data) {
+			body = body[:len(body)-binlogChecksumLength]
+		}
+	case this.format.HasChecksum:
+		if !hasValidChecksum(data) {
+			return nil, fmt.Errorf("Binlog event checksum mismatch at %s:%d", this.logFile, header.LogPos)
+		}
+		body = body[:len(body)-binlogChecksumLength]
+	}
+
+	info, err := getBinlogEventInfo(header, body, this.format)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse binlog event at %s:%d: %+v", this.logFile, header.LogPos, err)
+	}
+	if header.isArtificial() {
+		if header.EventType == binlogRotateEvent {
+			this.logFile = strings.SplitN(info, ";", 2)[0]
+		}
+		return nil, nil
+	}
+	eventType, found := binlogEventTypeNames[header.EventType]
+	if !found {
+		eventType = fmt.Sprintf("Unknown_%d", header.EventType)
+	}
+	event := &BinlogEvent{
+		Coordinates:  BinlogCoordinates{LogFile: this.logFile, LogPos: int64(header.LogPos) - int64(header.EventSize), Type: BinaryLog},
+		NextEventPos: int64(header.LogPos),
+		EventType:    eventType,
+		Info:         info,
+	}
+	return event, nil
+}
+
+// binlogBodyReader reads little-endian values off an event body, remembering the first error
+type binlogBodyReader struct {
+	body   []byte
+	offset int
+	err    error
+}
+
+func (this *binlogBodyReader) read(length int) []byte {
+	if this.err != nil {
+		return nil
+	}
+	if length < 0 || this.offset+length > len(this.body) {
+		this.err = fmt.Errorf("event body too short: reading %d bytes at offset %d of %d", length, this.offset, len(this.body))
+		return nil
+	}
+	result := this.body[this.offset : this.offset+length]
+	this.offset += length
+	return result
+}
+
+func (this *binlogBodyReader) skip(length int) {
+	this.read(length)
+}
+
+func (this *binlogBodyReader) uint(length int) uint64 {
+	b := this.read(length)
+	var result uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		result = result<<8 | uint64(b[i])
+	}
+	return result
+}
+
+func (this *binlogBodyReader) rest() []byte {
+	return this.read(len(this.body) - this.offset)
+}
+
+// formatUUID formats 16 raw bytes as a server UUID
+func formatUUID(b []byte) string {
+	s := hex.EncodeToString(b)
+	return fmt.Sprintf("%s-%s-%s-%s-%s", s[0:8], s[8:12], s[12:16], s[16:20], s[20:32])
+}
+
+// readTableId reads a table id, which is 4 or 6 bytes long depending on the event's post-header length
+func readTableId(reader *binlogBodyReader, postHeaderLength int) uint64 {
+	if postHeaderLength == 6 {
+		return reader.uint(4)
+	}
+	return reader.uint(6)
+}
+
+// binlogCollationNames maps common collation ids onto collation names, as used by User var events
+var binlogCollationNames = map[uint64]string{
+	8:   "latin1_swedish_ci",
+	33:  "utf8_general_ci",
+	45:  "utf8mb4_general_ci",
+	46:  "utf8mb4_bin",
+	47:  "latin1_bin",
+	63:  "binary",
+	83:  "utf8_bin",
+	224: "utf8mb4_unicode_ci",
+	255: "utf8mb4_0900_ai_ci",
+}
+
+// getUserVarInfo presents a User var event the way SHOW BINLOG EVENTS does. Decimal values are not supported.
+func getUserVarInfo(reader *binlogBodyReader) string {
+	name := string(reader.read(int(reader.uint(4))))
+	prefix := fmt.Sprintf("@`%s`=", strings.Replace(name, "`", "``", -1))
+	if isNull := reader.uint(1); isNull != 0 {
+		return prefix + "NULL"
+	}
+	valueType := reader.uint(1)
+	collation := reader.uint(4)
+	value := reader.read(int(reader.uint(4)))
+	isUnsigned := len(reader.body) > reader.offset && reader.body[reader.offset]&0x01 != 0
+	if reader.err != nil {
+		return ""
+	}
+	switch valueType {
+	case 0:
+		// STRING_RESULT
+		collationName, found := binlogCollationNames[collation]
+		if !found {
+			collationName = fmt.Sprintf("collation_%d", collation)
+		}
+		charsetName := strings.SplitN(collationName, "_", 2)[0]
+		return fmt.Sprintf("%s_%s 0x%s COLLATE %s", prefix, charsetName, strings.ToUpper(hex.EncodeToString(value)), collationName)
+	case 1:
+		// REAL_RESULT
+		if len(value) == 8 {
+			return prefix + strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(value)), 'g', 14, 64)
+		}
+	case 2:
+		// INT_RESULT
+		if len(value) == 8 {
+			if isUnsigned {
+				return fmt.Sprintf("%s%d", prefix, binary.LittleEndian.Uint64(value))
+			}
+			return fmt.Sprintf("%s%d", prefix, int64(binary.LittleEndian.Uint64(value)))
+		}
+	}
+	return ""
+}
+
+// getBinlogEventInfo presents the event's content the same way SHOW BINLOG EVENTS does in its Info column.
+// Events which carry no meaningful info for orchestrator (e.g. LOAD DATA related events) are presented as empty text.
+func getBinlogEventInfo(header *binlogEventHeader, body []byte, format *binlogFormatDescription) (string, error) {
+	reader := &binlogBodyReader{body: body}
+	info := ""
+	switch header.EventType {
+	case binlogQueryEvent:
+		postHeaderLength := format.postHeaderLength(binlogQueryEvent, binlogQueryDefaultPostHeader)
+		reader.skip(8) // thread id, exec time
+		dbLength := int(reader.uint(1))
+		reader.skip(2) // error code
+		statusVarsLength := 0
+		if postHeaderLength >= binlogQueryDefaultPostHeader {
+			statusVarsLength = int(reader.uint(2))
+			reader.skip(postHeaderLength - binlogQueryDefaultPostHeader)
+		}
+		reader.skip(statusVarsLength)
+		db := string(reader.read(dbLength))
+		reader.skip(1)
+		query := string(reader.rest())
+		if header.Flags&binlogEventSuppressUseFlag == 0 && db != "" {
+			info = fmt.Sprintf("use `%s`; %s", strings.Replace(db, "`", "``", -1), query)
+		} else {
+			info = query
+		}
+	case binlogRotateEvent:
+		postHeaderLength := format.postHeaderLength(binlogRotateEvent, binlogRotateDefaultPostHeader)
+		position := uint64(4)
+		if postHeaderLength >= 8 {
+			position = reader.uint(8)
+			reader.skip(postHeaderLength - 8)
+		}
+		info = fmt.Sprintf("%s;pos=%d", reader.rest(), position)
+	case binlogIntvarEvent:
+		names := map[uint64]string{1: "LAST_INSERT_ID", 2: "INSERT_ID"}
+		name, found := names[reader.uint(1)]
+		if !found {
+			name = "INVALID_INT"
+		}
+		info = fmt.Sprintf("%s=%d", name, reader.uint(8))
+	case binlogRandEvent:
+		info = fmt.Sprintf("rand_seed1=%d,rand_seed2=%d", reader.uint(8), reader.uint(8))
+	case binlogUserVarEvent:
+		info = getUserVarInfo(reader)
+	case binlogFormatDescriptionEvent:
+		binlogVersion := reader.uint(2)
+		serverVersion := bytes.TrimRight(reader.read(50), "\x00")
+		info = fmt.Sprintf("Server ver: %s, Binlog ver: %d", serverVersion, binlogVersion)
+	case binlogXidEvent:
+		info = fmt.Sprintf("COMMIT /* xid=%d */", reader.uint(8))
+	case binlogTableMapEvent:
+		postHeaderLength := format.postHeaderLength(binlogTableMapEvent, binlogTableIdDefaultPostHeader)
+		tableId := readTableId(reader, postHeaderLength)
+		reader.offset = postHeaderLength
+		db := reader.read(int(reader.uint(1)))
+		reader.skip(1)
+		table := reader.read(int(reader.uint(1)))
+		info = fmt.Sprintf("table_id: %d (%s.%s)", tableId, db, table)
+	case binlogWriteRowsEventV1, binlogUpdateRowsEventV1, binlogDeleteRowsEventV1,
+		binlogWriteRowsEventV2, binlogUpdateRowsEventV2, binlogDeleteRowsEventV2:
+		postHeaderLength := format.postHeaderLength(header.EventType, binlogTableIdDefaultPostHeader)
+		tableId := readTableId(reader, postHeaderLength)
+		flags := reader.uint(2)
+		info = fmt.Sprintf("table_id: %d", tableId)
+		if flags&binlogRowsStatementEndFlag != 0 {
+			info = info + " flags: STMT_END_F"
+		}
+	case binlogRowsQueryEvent:
+		reader.skip(1) // length, truncated to 255; the actual query is the rest of the event
+		info = fmt.Sprintf("# %s", reader.rest())
+	case binlogGTIDEvent:
+		reader.skip(1) // flags
+		sid := reader.read(16)
+		gno := reader.uint(8)
+		if reader.err == nil {
+			info = fmt.Sprintf("SET @@SESSION.GTID_NEXT= '%s:%d'", formatUUID(sid), gno)
+		}
+	case binlogAnonymousGTIDEvent:
+		info = "SET @@SESSION.GTID_NEXT= 'ANONYMOUS'"
+	case binlogPreviousGTIDsEvent:
+		sidTexts := []string{}
+		numSids := reader.uint(8)
+		for i := uint64(0); i < numSids && reader.err == nil; i++ {
+			sidText := formatUUID(reader.read(16))
+			numIntervals := reader.uint(8)
+			for j := uint64(0); j < numIntervals && reader.err == nil; j++ {
+				start := reader.uint(8)
+				end := reader.uint(8) // exclusive
+				if end-start == 1 {
+					sidText = fmt.Sprintf("%s:%d", sidText, start)
+				} else {
+					sidText = fmt.Sprintf("%s:%d-%d", sidText, start, end-1)
+				}
+			}
+			sidTexts = append(sidTexts, sidText)
+		}
+		info = strings.Join(sidTexts, ",\n")
+	case mariadbAnnotateRowsEvent:
+		info = string(reader.rest())
+	case mariadbBinlogCheckpointEvent:
+		info = string(reader.read(int(reader.uint(4))))
+	case mariadbGTIDEvent:
+		sequence := reader.uint(8)
+		domainId := reader.uint(4)
+		flags := reader.uint(1)
+		prefix := "BEGIN GTID"
+		if flags&mariadbGTIDStandaloneFlag != 0 {
+			prefix = "GTID"
+		}
+		info = fmt.Sprintf("%s %d-%d-%d", prefix, domainId, header.ServerId, sequence)
+		if flags&mariadbGTIDGroupCommitIDFlag != 0 {
+			info = fmt.Sprintf("%s cid=%d", info, reader.uint(8))
+		}
+	case mariadbGTIDListEvent:
+		count := reader.uint(4) & 0x0fffffff
+		gtids := []string{}
+		for i := uint64(0); i < count && reader.err == nil; i++ {
+			gtids = append(gtids, fmt.Sprintf("%d-%d-%d", reader.uint(4), reader.uint(4), reader.uint(8)))
+		}
+		info = fmt.Sprintf("[%s]", strings.Join(gtids, ","))
+	}
+	return info, reader.err
+}
+
+// readBinlogFileEvents parses the events of a binary log file, as read from given reader.
+// onEvent is invoked per event; reading stops at the end of the file or when onEvent returns false.
+func readBinlogFileEvents(reader io.Reader, logFile string, onEvent func(event *BinlogEvent) bool) error {
+	magic := make([]byte, len(binlogFileMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		return err
+	}
+	if !bytes.Equal(magic, binlogFileMagic) {
+		return fmt.Errorf("%s is not a binary log file", logFile)
+	}
+	parser := newBinlogEventsParser(logFile)
+	for {
+		header := make([]byte, binlogEventHeaderLength)
+		if _, err := io.ReadFull(reader, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		eventSize := binary.LittleEndian.Uint32(header[9:13])
+		if eventSize < binlogEventHeaderLength {
+			return fmt.Errorf("Invalid binlog event size in %s: %d", logFile, eventSize)
+		}
+		data := make([]byte, eventSize)
+		copy(data, header)
+		if _, err := io.ReadFull(reader, data[binlogEventHeaderLength:]); err != nil {
+			return err
+		}
+		event, err := parser.parseEvent(data)
+		if err != nil {
+			return err
+		}
+		if event != nil && !onEvent(event) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,426 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+// Binlog fixtures in testdata:
+// - mysql-bin.000042: MySQL 5.6 (binlog_checksum=CRC32, gtid_mode=ON), with Pseudo-GTID entries, row based and statement based transactions, ending with a rotate
+// - mariadb-bin.000007: MariaDB 10.1 (binlog_checksum=NONE), with MariaDB GTIDs, annotated row events and a Pseudo-GTID entry, ending with a stop event
+
+const fixturePseudoGTID1 = "use `test`; drop view if exists `_pseudo_gtid_`.`_asc:57fb2a00:0000000000000001:9f8e7d6c`"
+const fixturePseudoGTID2 = "use `test`; drop view if exists `_pseudo_gtid_`.`_asc:57fb2a05:0000000000000002:9f8e7d6c`"
+
+func readBinlogFixture(t *testing.T, logFile string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", logFile))
+	test.S(t).ExpectNil(err)
+	return data
+}
+
+func readBinlogFixtureEvents(t *testing.T, logFile string) []BinlogEvent {
+	events := []BinlogEvent{}
+	err := readBinlogFileEvents(bytes.NewReader(readBinlogFixture(t, logFile)), logFile, func(event *BinlogEvent) bool {
+		events = append(events, *event)
+		return true
+	})
+	test.S(t).ExpectNil(err)
+	return events
+}
+
+func expectContiguousBinlogEvents(t *testing.T, events []BinlogEvent, logFile string) {
+	test.S(t).ExpectEquals(events[0].Coordinates.LogPos, int64(4))
+	for i, event := range events {
+		test.S(t).ExpectEquals(event.Coordinates.LogFile, logFile)
+		test.S(t).ExpectEquals(event.Coordinates.Type, BinaryLog)
+		if i > 0 {
+			test.S(t).ExpectEquals(event.Coordinates.LogPos, events[i-1].NextEventPos)
+		}
+	}
+}
+
+func TestReadBinlogFileEventsMySQL(t *testing.T) {
+	events := readBinlogFixtureEvents(t, "mysql-bin.000042")
+	test.S(t).ExpectEquals(len(events), 17)
+	expectContiguousBinlogEvents(t, events, "mysql-bin.000042")
+
+	expected := []struct {
+		eventType string
+		info      string
+	}{
+		{"Format_desc", "Server ver: 5.6.33-log, Binlog ver: 4"},
+		{"Previous_gtids", "c3d0a5f2-8f4e-11e6-9b4f-0242ac110002:1-5,\nd8e1b6a3-8f4e-11e6-9b4f-0242ac110003:1-3:7"},
+		{"Gtid", "SET @@SESSION.GTID_NEXT= 'c3d0a5f2-8f4e-11e6-9b4f-0242ac110002:6'"},
+		{"Query", fixturePseudoGTID1},
+		{"Gtid", "SET @@SESSION.GTID_NEXT= 'c3d0a5f2-8f4e-11e6-9b4f-0242ac110002:7'"},
+		{"Query", "BEGIN"},
+		{"Table_map", "table_id: 70 (test.t1)"},
+		{"Write_rows", "table_id: 70 flags: STMT_END_F"},
+		{"Xid", "COMMIT /* xid=1234 */"},
+		{"Gtid", "SET @@SESSION.GTID_NEXT= 'c3d0a5f2-8f4e-11e6-9b4f-0242ac110002:8'"},
+		{"Query", "BEGIN"},
+		{"Intvar", "INSERT_ID=5"},
+		{"Query", "use `test`; insert into t2 values (null)"},
+		{"Xid", "COMMIT /* xid=1235 */"},
+		{"Gtid", "SET @@SESSION.GTID_NEXT= 'c3d0a5f2-8f4e-11e6-9b4f-0242ac110002:9'"},
+		{"Query", fixturePseudoGTID2},
+		{"Rotate", "mysql-bin.000043;pos=4"},
+	}
+	for i, event := range events {
+		test.S(t).ExpectEquals(event.EventType, expected[i].eventType)
+		test.S(t).ExpectEquals(event.Info, expected[i].info)
+	}
+	test.S(t).ExpectEquals(events[0].NextEventPos, int64(120))
+	test.S(t).ExpectEquals(events[1].NextEventPos, int64(247))
+}
+
+func TestReadBinlogFileEventsMariaDB(t *testing.T) {
+	events := readBinlogFixtureEvents(t, "mariadb-bin.000007")
+	test.S(t).ExpectEquals(len(events), 11)
+	expectContiguousBinlogEvents(t, events, "mariadb-bin.000007")
+	test.S(t).ExpectEquals(events[0].NextEventPos, int64(249))
+
+	expected := []struct {
+		eventType string
+		info      string
+	}{
+		{"Format_desc", "Server ver: 10.1.19-MariaDB-log, Binlog ver: 4"},
+		{"Gtid_list", "[0-1-4]"},
+		{"Binlog_checkpoint", "mariadb-bin.000007"},
+		{"Gtid", "BEGIN GTID 0-1-5 cid=12"},
+		{"Annotate_rows", "insert into t1 values (18)"},
+		{"Table_map", "table_id: 71 (test.t1)"},
+		{"Write_rows_v1", "table_id: 71 flags: STMT_END_F"},
+		{"Xid", "COMMIT /* xid=77 */"},
+		{"Gtid", "GTID 0-1-6"},
+		{"Query", fixturePseudoGTID1},
+		{"Stop", ""},
+	}
+	for i, event := range events {
+		test.S(t).ExpectEquals(event.EventType, expected[i].eventType)
+		test.S(t).ExpectEquals(event.Info, expected[i].info)
+	}
+}
+
+func TestReadBinlogFileEventsStopsOnRequest(t *testing.T) {
+	events := []BinlogEvent{}
+	err := readBinlogFileEvents(bytes.NewReader(readBinlogFixture(t, "mysql-bin.000042")), "mysql-bin.000042", func(event *BinlogEvent) bool {
+		events = append(events, *event)
+		return event.Info != fixturePseudoGTID1
+	})
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(events), 4)
+}
+
+func TestReadBinlogFileEventsChecksumMismatch(t *testing.T) {
+	data := readBinlogFixture(t, "mysql-bin.000042")
+	corrupted := make([]byte, len(data))
+	copy(corrupted, data)
+	// Flip a byte within the query text of the first Pseudo-GTID entry
+	offset := bytes.Index(corrupted, []byte("57fb2a00"))
+	test.S(t).ExpectTrue(offset > 0)
+	corrupted[offset] = 'x'
+	err := readBinlogFileEvents(bytes.NewReader(corrupted), "mysql-bin.000042", func(event *BinlogEvent) bool { return true })
+	test.S(t).ExpectNotNil(err)
+
+	err = readBinlogFileEvents(bytes.NewReader(data[4:]), "mysql-bin.000042", func(event *BinlogEvent) bool { return true })
+	test.S(t).ExpectNotNil(err)
+}
+
+func TestBinlogFileEventsNormalizeInfo(t *testing.T) {
+	events := readBinlogFixtureEvents(t, "mysql-bin.000042")
+	mariadbEvents := readBinlogFixtureEvents(t, "mariadb-bin.000007")
+
+	xid := events[8]
+	xid.NormalizeInfo()
+	test.S(t).ExpectEquals(xid.Info, "COMMIT")
+
+	tableMap := events[6]
+	tableMap.NormalizeInfo()
+	mariadbTableMap := mariadbEvents[5]
+	mariadbTableMap.NormalizeInfo()
+	test.S(t).ExpectEquals(tableMap.Info, "table_id: ### (test.t1)")
+	test.S(t).ExpectEquals(mariadbTableMap.Info, tableMap.Info)
+
+	gtid := mariadbEvents[3]
+	gtid.NormalizeInfo()
+	test.S(t).ExpectEquals(gtid.Info, "BEGIN GTID 0-1-5")
+}
+
+func TestServerVersionSupportsBinlogChecksum(t *testing.T) {
+	test.S(t).ExpectFalse(serverVersionSupportsBinlogChecksum("5.5.40-log"))
+	test.S(t).ExpectFalse(serverVersionSupportsBinlogChecksum("5.6.0"))
+	test.S(t).ExpectTrue(serverVersionSupportsBinlogChecksum("5.6.1"))
+	test.S(t).ExpectTrue(serverVersionSupportsBinlogChecksum("5.7.16-log"))
+	test.S(t).ExpectTrue(serverVersionSupportsBinlogChecksum("5.5.52-MariaDB"))
+	test.S(t).ExpectTrue(serverVersionSupportsBinlogChecksum("10.1.19-MariaDB-log"))
+}
+
+// fakeBinlogMaster serves a binlog fixture over the MySQL replication protocol, just like a master serves
+// COM_BINLOG_DUMP requests
+type fakeBinlogMaster struct {
+	listener net.Listener
+	binlog   []byte
+	logFile  string
+	password string
+	scramble []byte
+	// connections counts accepted connections
+	connections int64
+}
+
+func newFakeBinlogMaster(t *testing.T, logFile string, password string) *fakeBinlogMaster {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	test.S(t).ExpectNil(err)
+	master := &fakeBinlogMaster{
+		listener: listener,
+		binlog:   readBinlogFixture(t, logFile),
+		logFile:  logFile,
+		password: password,
+		scramble: []byte("0123456789abcdefghij"),
+	}
+	go master.serve()
+	return master
+}
+
+func (this *fakeBinlogMaster) instanceKey() *InstanceKey {
+	return &InstanceKey{Hostname: "127.0.0.1", Port: this.listener.Addr().(*net.TCPAddr).Port}
+}
+
+func (this *fakeBinlogMaster) serve() {
+	for {
+		conn, err := this.listener.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt64(&this.connections, 1)
+		go this.handle(&binlogStreamConnection{conn: conn})
+	}
+}
+
+// checkNativePassword validates a mysql_native_password response the way the server does
+func (this *fakeBinlogMaster) checkNativePassword(response []byte) bool {
+	stage1 := sha1.Sum([]byte(this.password))
+	stored := sha1.Sum(stage1[:])
+	hash := sha1.New()
+	hash.Write(this.scramble)
+	hash.Write(stored[:])
+	candidate := hash.Sum(nil)
+	if len(response) != len(candidate) {
+		return false
+	}
+	for i := range candidate {
+		candidate[i] ^= response[i]
+	}
+	return sha1.Sum(candidate) == stored
+}
+
+// artificialEvent rewrites a raw (checksummed) event as an artificial one, the way the master sends
+// the format description event when dump starts past it
+func artificialEvent(data []byte) []byte {
+	result := make([]byte, len(data))
+	copy(result, data)
+	binary.LittleEndian.PutUint32(result[13:17], 0)
+	binary.LittleEndian.PutUint32(result[len(result)-4:], crc32.ChecksumIEEE(result[:len(result)-4]))
+	return result
+}
+
+func (this *fakeBinlogMaster) handle(conn *binlogStreamConnection) {
+	defer conn.Close()
+	handshake := []byte{10}
+	handshake = append(handshake, []byte("5.6.33-log\x00")...)
+	handshake = append(handshake, 1, 0, 0, 0)
+	handshake = append(handshake, this.scramble[0:8]...)
+	handshake = append(handshake, 0)
+	capabilities := mysqlClientLongPassword | mysqlClientLongFlag | mysqlClientProtocol41 | mysqlClientTransactions | mysqlClientSecureConnection | mysqlClientPluginAuth
+	handshake = append(handshake, byte(capabilities), byte(capabilities>>8), mysqlCharsetUTF8, 2, 0, byte(capabilities>>16), byte(capabilities>>24), 21)
+	handshake = append(handshake, make([]byte, 10)...)
+	handshake = append(handshake, this.scramble[8:]...)
+	handshake = append(handshake, 0)
+	handshake = append(handshake, []byte(nativePasswordPluginName+"\x00")...)
+	if conn.writePacket(handshake) != nil {
+		return
+	}
+	response, err := conn.readPacket()
+	if err != nil {
+		return
+	}
+	userEnd := bytes.IndexByte(response[32:], 0)
+	authLength := int(response[32+userEnd+1])
+	authResponse := response[32+userEnd+2 : 32+userEnd+2+authLength]
+	if !this.checkNativePassword(authResponse) {
+		conn.writePacket(append([]byte{mysqlPacketErr, 0x15, 0x04}, []byte("#28000Access denied")...))
+		return
+	}
+	conn.writePacket([]byte{mysqlPacketOK, 0, 0, 2, 0, 0, 0})
+
+	for {
+		conn.sequence = 0
+		command, err := conn.readPacket()
+		if err != nil {
+			return
+		}
+		switch command[0] {
+		case mysqlComQuery:
+			conn.writePacket([]byte{mysqlPacketOK, 0, 0, 2, 0, 0, 0})
+		case mysqlComBinlogDump:
+			pos := int(binary.LittleEndian.Uint32(command[1:5]))
+			logFile := string(command[11:])
+			if logFile != this.logFile {
+				conn.writePacket(append([]byte{mysqlPacketErr, 0xd4, 0x04}, []byte("#HY000Could not find first log file name in binary log index file")...))
+				return
+			}
+			rotateBody := make([]byte, 8)
+			binary.LittleEndian.PutUint64(rotateBody, uint64(pos))
+			rotateBody = append(rotateBody, []byte(logFile)...)
+			rotate := make([]byte, binlogEventHeaderLength, binlogEventHeaderLength+len(rotateBody)+4)
+			rotate[4] = binlogRotateEvent
+			binary.LittleEndian.PutUint32(rotate[9:13], uint32(binlogEventHeaderLength+len(rotateBody)+4))
+			binary.LittleEndian.PutUint16(rotate[17:19], binlogEventArtificialFlag)
+			rotate = append(rotate, rotateBody...)
+			rotate = append(rotate, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint32(rotate[len(rotate)-4:], crc32.ChecksumIEEE(rotate[:len(rotate)-4]))
+			if conn.writePacket(append([]byte{mysqlPacketOK}, rotate...)) != nil {
+				return
+			}
+			offset := 4
+			for offset < len(this.binlog) {
+				size := int(binary.LittleEndian.Uint32(this.binlog[offset+9 : offset+13]))
+				data := this.binlog[offset : offset+size]
+				if offset == 4 && pos > 4 {
+					data = artificialEvent(data)
+				}
+				if offset == 4 || offset >= pos {
+					if conn.writePacket(append([]byte{mysqlPacketOK}, data...)) != nil {
+						return
+					}
+				}
+				offset += size
+			}
+			conn.writePacket([]byte{mysqlPacketEOF, 0, 0, 0, 0})
+		default:
+			return
+		}
+	}
+}
+
+func withFakeBinlogMaster(t *testing.T, password string, f func(master *fakeBinlogMaster)) {
+	user, configPassword := config.Config.MySQLTopologyUser, config.Config.MySQLTopologyPassword
+	defer func() {
+		config.Config.MySQLTopologyUser, config.Config.MySQLTopologyPassword = user, configPassword
+	}()
+	config.Config.MySQLTopologyUser, config.Config.MySQLTopologyPassword = "orchestrator", password
+
+	master := newFakeBinlogMaster(t, "mysql-bin.000042", "s3cr3t")
+	defer master.listener.Close()
+	f(master)
+}
+
+func TestStreamBinlogEvents(t *testing.T) {
+	fileEvents := readBinlogFixtureEvents(t, "mysql-bin.000042")
+	withFakeBinlogMaster(t, "s3cr3t", func(master *fakeBinlogMaster) {
+		events := []BinlogEvent{}
+		streamed, err := streamBinlogEvents(master.instanceKey(), BinlogCoordinates{LogFile: "mysql-bin.000042", LogPos: 0}, func(event *BinlogEvent) bool {
+			events = append(events, *event)
+			return true
+		})
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(streamed)
+		test.S(t).ExpectEquals(len(events), len(fileEvents))
+		for i := range events {
+			test.S(t).ExpectTrue(events[i] == fileEvents[i])
+		}
+	})
+}
+
+func TestStreamBinlogEventsFromPosition(t *testing.T) {
+	fileEvents := readBinlogFixtureEvents(t, "mysql-bin.000042")
+	withFakeBinlogMaster(t, "s3cr3t", func(master *fakeBinlogMaster) {
+		startCoordinates := fileEvents[5].Coordinates
+		events := []BinlogEvent{}
+		streamed, err := streamBinlogEvents(master.instanceKey(), startCoordinates, func(event *BinlogEvent) bool {
+			events = append(events, *event)
+			return len(events) < 3
+		})
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(streamed)
+		test.S(t).ExpectEquals(len(events), 3)
+		for i := range events {
+			test.S(t).ExpectTrue(events[i] == fileEvents[5+i])
+		}
+	})
+}
+
+func TestStreamBinlogEventsErrors(t *testing.T) {
+	withFakeBinlogMaster(t, "wrong-password", func(master *fakeBinlogMaster) {
+		// Authentication failure: caller is expected to fall back to SHOW BINLOG EVENTS
+		streamed, err := streamBinlogEvents(master.instanceKey(), BinlogCoordinates{LogFile: "mysql-bin.000042", LogPos: 4}, func(event *BinlogEvent) bool { return true })
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectFalse(streamed)
+	})
+	withFakeBinlogMaster(t, "s3cr3t", func(master *fakeBinlogMaster) {
+		streamed, err := streamBinlogEvents(master.instanceKey(), BinlogCoordinates{LogFile: "mysql-bin.000001", LogPos: 4}, func(event *BinlogEvent) bool { return true })
+		test.S(t).ExpectNotNil(err)
+		test.S(t).ExpectTrue(streamed)
+	})
+}
+
+func TestBinlogEventsStreamReadChunks(t *testing.T) {
+	fileEvents := readBinlogFixtureEvents(t, "mysql-bin.000042")
+	withFakeBinlogMaster(t, "s3cr3t", func(master *fakeBinlogMaster) {
+		stream := newBinlogEventsStream(master.instanceKey())
+		defer stream.Close()
+
+		events := []BinlogEvent{}
+		coordinates := BinlogCoordinates{LogFile: "mysql-bin.000042", LogPos: 4, Type: BinaryLog}
+		for {
+			chunk, streamed, err := stream.readChunk(coordinates, 3)
+			test.S(t).ExpectNil(err)
+			test.S(t).ExpectTrue(streamed)
+			if len(chunk) == 0 {
+				break
+			}
+			test.S(t).ExpectTrue(len(chunk) <= 3)
+			events = append(events, chunk...)
+			coordinates = chunk[len(chunk)-1].NextBinlogCoordinates()
+		}
+		test.S(t).ExpectEquals(len(events), len(fileEvents))
+		for i := range events {
+			test.S(t).ExpectTrue(events[i] == fileEvents[i])
+		}
+		// All chunks were read over a single connection
+		test.S(t).ExpectEquals(atomic.LoadInt64(&master.connections), int64(1))
+
+		// Reading out of sequence requests a new dump
+		chunk, streamed, err := stream.readChunk(fileEvents[5].Coordinates, 2)
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(streamed)
+		test.S(t).ExpectEquals(len(chunk), 2)
+		test.S(t).ExpectTrue(chunk[0] == fileEvents[5])
+	})
+}
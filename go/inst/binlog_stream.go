@@ -0,0 +1,580 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/ssl"
+)
+
+// MySQL client/server protocol constants used by the binlog stream
+const (
+	mysqlClientLongPassword     uint32 = 0x00000001
+	mysqlClientLongFlag         uint32 = 0x00000004
+	mysqlClientProtocol41       uint32 = 0x00000200
+	mysqlClientSSL              uint32 = 0x00000800
+	mysqlClientTransactions     uint32 = 0x00002000
+	mysqlClientSecureConnection uint32 = 0x00008000
+	mysqlClientPluginAuth       uint32 = 0x00080000
+
+	mysqlComQuery      byte = 0x03
+	mysqlComBinlogDump byte = 0x12
+
+	mysqlPacketOK       byte = 0x00
+	mysqlPacketAuthMore byte = 0x01
+	mysqlPacketEOF      byte = 0xfe
+	mysqlPacketErr      byte = 0xff
+
+	mysqlMaxPacketLength       = 0xffffff
+	mysqlCharsetUTF8           = 33
+	mysqlErrorPacketHeaderSize = 3
+	mysqlBinlogDumpNonBlock    = 0x01
+	mysqlBinlogDumpServerId    = 0 // A zero server id makes the master not kill other dump threads with same id
+	binlogFileHeaderLength     = 4
+	binlogStreamReadTimeout    = time.Minute
+
+	nativePasswordPluginName  = "mysql_native_password"
+	cachingSHA2PasswordPlugin = "caching_sha2_password"
+)
+
+// binlogStreamConnection is a MySQL client connection which speaks just enough of the protocol to
+// authenticate and request a binlog dump (COM_BINLOG_DUMP), in the same way a replicating slave does.
+type binlogStreamConnection struct {
+	conn     net.Conn
+	sequence byte
+	useTLS   bool
+}
+
+func (this *binlogStreamConnection) readPacket() ([]byte, error) {
+	payload := []byte{}
+	for {
+		header := make([]byte, 4)
+		this.conn.SetReadDeadline(time.Now().Add(binlogStreamReadTimeout))
+		if _, err := io.ReadFull(this.conn, header); err != nil {
+			return nil, err
+		}
+		length := int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+		if header[3] != this.sequence {
+			return nil, fmt.Errorf("Unexpected packet sequence: got %d, expected %d", header[3], this.sequence)
+		}
+		this.sequence++
+		data := make([]byte, length)
+		if _, err := io.ReadFull(this.conn, data); err != nil {
+			return nil, err
+		}
+		payload = append(payload, data...)
+		if length < mysqlMaxPacketLength {
+			return payload, nil
+		}
+	}
+}
+
+func (this *binlogStreamConnection) writePacket(payload []byte) error {
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), this.sequence}
+	this.sequence++
+	_, err := this.conn.Write(append(header, payload...))
+	return err
+}
+
+// parseErrorPacket turns an ERR packet into an error
+func parseErrorPacket(packet []byte) error {
+	if len(packet) < mysqlErrorPacketHeaderSize {
+		return fmt.Errorf("Malformed error packet")
+	}
+	code := binary.LittleEndian.Uint16(packet[1:3])
+	message := packet[mysqlErrorPacketHeaderSize:]
+	if len(message) > 0 && message[0] == '#' && len(message) >= 6 {
+		message = message[6:]
+	}
+	return fmt.Errorf("Error %d: %s", code, message)
+}
+
+// scrambleNativePassword computes the mysql_native_password authentication response
+func scrambleNativePassword(scramble []byte, password string) []byte {
+	if password == "" {
+		return []byte{}
+	}
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	hash := sha1.New()
+	hash.Write(scramble)
+	hash.Write(stage2[:])
+	result := hash.Sum(nil)
+	for i := range result {
+		result[i] ^= stage1[i]
+	}
+	return result
+}
+
+// scrambleCachingSHA2Password computes the caching_sha2_password (fast) authentication response
+func scrambleCachingSHA2Password(scramble []byte, password string) []byte {
+	if password == "" {
+		return []byte{}
+	}
+	stage1 := sha256.Sum256([]byte(password))
+	stage2 := sha256.Sum256(stage1[:])
+	hash := sha256.New()
+	hash.Write(stage2[:])
+	hash.Write(scramble)
+	result := hash.Sum(nil)
+	for i := range result {
+		result[i] ^= stage1[i]
+	}
+	return result
+}
+
+// scramblePassword computes the authentication response for given authentication plugin
+func scramblePassword(pluginName string, scramble []byte, password string) ([]byte, error) {
+	switch pluginName {
+	case nativePasswordPluginName, "":
+		return scrambleNativePassword(scramble, password), nil
+	case cachingSHA2PasswordPlugin:
+		return scrambleCachingSHA2Password(scramble, password), nil
+	}
+	return nil, fmt.Errorf("Unsupported authentication plugin: %s", pluginName)
+}
+
+// encryptPassword encrypts the password with the server's public key, as required by caching_sha2_password
+// full authentication on a non-TLS connection
+func encryptPassword(publicKeyPEM []byte, scramble []byte, password string) ([]byte, error) {
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("Cannot decode server public key")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaPublicKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("Server public key is not an RSA key")
+	}
+	plain := append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), nil, rsaPublicKey, plain, nil)
+}
+
+// serverHandshake is the parsed initial handshake packet (protocol version 10)
+type serverHandshake struct {
+	capabilities uint32
+	scramble     []byte
+	pluginName   string
+}
+
+func parseServerHandshake(packet []byte) (*serverHandshake, error) {
+	if len(packet) == 0 {
+		return nil, fmt.Errorf("Empty handshake packet")
+	}
+	if packet[0] == mysqlPacketErr {
+		return nil, parseErrorPacket(packet)
+	}
+	if packet[0] != 10 {
+		return nil, fmt.Errorf("Unsupported protocol version: %d", packet[0])
+	}
+	serverVersionEnd := bytes.IndexByte(packet[1:], 0)
+	if serverVersionEnd < 0 {
+		return nil, fmt.Errorf("Malformed handshake packet")
+	}
+	reader := &binlogBodyReader{body: packet, offset: 1 + serverVersionEnd + 1}
+	reader.skip(4) // connection id
+	handshake := &serverHandshake{}
+	handshake.scramble = append(handshake.scramble, reader.read(8)...)
+	reader.skip(1)
+	handshake.capabilities = uint32(reader.uint(2))
+	if reader.offset < len(packet) {
+		reader.skip(1 + 2) // charset, status
+		handshake.capabilities |= uint32(reader.uint(2)) << 16
+		authDataLength := int(reader.uint(1))
+		reader.skip(10)
+		if handshake.capabilities&mysqlClientSecureConnection != 0 {
+			part2Length := authDataLength - 8
+			if part2Length < 13 {
+				part2Length = 13
+			}
+			handshake.scramble = append(handshake.scramble, bytes.TrimRight(reader.read(part2Length), "\x00")...)
+		}
+		if handshake.capabilities&mysqlClientPluginAuth != 0 {
+			handshake.pluginName = string(bytes.TrimRight(reader.rest(), "\x00"))
+		}
+	}
+	return handshake, reader.err
+}
+
+// authenticate performs the handshake & authentication phase of the connection
+func (this *binlogStreamConnection) authenticate(user string, password string, tlsConfig *tls.Config) error {
+	packet, err := this.readPacket()
+	if err != nil {
+		return err
+	}
+	handshake, err := parseServerHandshake(packet)
+	if err != nil {
+		return err
+	}
+	requiredCapabilities := mysqlClientProtocol41 | mysqlClientSecureConnection
+	if handshake.capabilities&requiredCapabilities != requiredCapabilities {
+		return fmt.Errorf("Server does not support protocol 4.1 secure authentication")
+	}
+	capabilities := (mysqlClientLongPassword | mysqlClientLongFlag | mysqlClientProtocol41 | mysqlClientTransactions | mysqlClientSecureConnection | mysqlClientPluginAuth) & handshake.capabilities
+
+	responsePrefix := make([]byte, 32)
+	binary.LittleEndian.PutUint32(responsePrefix[4:8], mysqlMaxPacketLength)
+	responsePrefix[8] = mysqlCharsetUTF8
+	if tlsConfig != nil {
+		if handshake.capabilities&mysqlClientSSL == 0 {
+			return fmt.Errorf("Server does not support TLS")
+		}
+		capabilities |= mysqlClientSSL
+		binary.LittleEndian.PutUint32(responsePrefix[0:4], capabilities)
+		if err := this.writePacket(responsePrefix); err != nil {
+			return err
+		}
+		tlsConn := tls.Client(this.conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		this.conn = tlsConn
+		this.useTLS = true
+	}
+	binary.LittleEndian.PutUint32(responsePrefix[0:4], capabilities)
+
+	pluginName := handshake.pluginName
+	scramble := handshake.scramble
+	authResponse, err := scramblePassword(pluginName, scramble, password)
+	if err != nil {
+		// Let the server switch us to a plugin we support
+		pluginName = nativePasswordPluginName
+		authResponse = scrambleNativePassword(scramble, password)
+	}
+	response := append(responsePrefix, []byte(user)...)
+	response = append(response, 0, byte(len(authResponse)))
+	response = append(response, authResponse...)
+	if capabilities&mysqlClientPluginAuth != 0 {
+		response = append(response, []byte(pluginName)...)
+		response = append(response, 0)
+	}
+	if err := this.writePacket(response); err != nil {
+		return err
+	}
+
+	for {
+		packet, err := this.readPacket()
+		if err != nil {
+			return err
+		}
+		if len(packet) == 0 {
+			return fmt.Errorf("Empty authentication response")
+		}
+		switch packet[0] {
+		case mysqlPacketOK:
+			return nil
+		case mysqlPacketErr:
+			return parseErrorPacket(packet)
+		case mysqlPacketEOF:
+			// Authentication switch request
+			pluginNameEnd := bytes.IndexByte(packet[1:], 0)
+			if pluginNameEnd < 0 {
+				return fmt.Errorf("Malformed authentication switch request")
+			}
+			pluginName = string(packet[1 : 1+pluginNameEnd])
+			scramble = bytes.TrimRight(packet[1+pluginNameEnd+1:], "\x00")
+			authResponse, err := scramblePassword(pluginName, scramble, password)
+			if err != nil {
+				return err
+			}
+			if err := this.writePacket(authResponse); err != nil {
+				return err
+			}
+		case mysqlPacketAuthMore:
+			if pluginName != cachingSHA2PasswordPlugin || len(packet) < 2 {
+				return fmt.Errorf("Unexpected authentication packet")
+			}
+			switch packet[1] {
+			case 3:
+				// fast authentication succeeded; OK packet follows
+			case 4:
+				// full authentication required
+				if this.useTLS {
+					if err := this.writePacket(append([]byte(password), 0)); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := this.writePacket([]byte{2}); err != nil {
+					return err
+				}
+				publicKeyPacket, err := this.readPacket()
+				if err != nil {
+					return err
+				}
+				if len(publicKeyPacket) == 0 || publicKeyPacket[0] != mysqlPacketAuthMore {
+					return fmt.Errorf("Cannot read server public key")
+				}
+				encryptedPassword, err := encryptPassword(publicKeyPacket[1:], scramble, password)
+				if err != nil {
+					return err
+				}
+				if err := this.writePacket(encryptedPassword); err != nil {
+					return err
+				}
+			default:
+				// Public key sent without being requested
+				return fmt.Errorf("Unexpected caching_sha2_password state: %d", packet[1])
+			}
+		default:
+			return fmt.Errorf("Unexpected authentication response: %d", packet[0])
+		}
+	}
+}
+
+// execute runs a statement which returns no result set
+func (this *binlogStreamConnection) execute(query string) error {
+	this.sequence = 0
+	if err := this.writePacket(append([]byte{mysqlComQuery}, []byte(query)...)); err != nil {
+		return err
+	}
+	packet, err := this.readPacket()
+	if err != nil {
+		return err
+	}
+	if len(packet) > 0 && packet[0] == mysqlPacketErr {
+		return parseErrorPacket(packet)
+	}
+	if len(packet) == 0 || packet[0] != mysqlPacketOK {
+		return fmt.Errorf("Unexpected response to %s", query)
+	}
+	return nil
+}
+
+// requestDump requests the binary log, starting at given file:pos. Events are then read via readDumpEvent.
+// The master is requested not to block: it terminates the stream when reaching the end of its binary logs.
+func (this *binlogStreamConnection) requestDump(logFile string, logPos int64) error {
+	if logPos < binlogFileHeaderLength {
+		logPos = binlogFileHeaderLength
+	}
+	command := make([]byte, 11, 11+len(logFile))
+	command[0] = mysqlComBinlogDump
+	binary.LittleEndian.PutUint32(command[1:5], uint32(logPos))
+	binary.LittleEndian.PutUint16(command[5:7], mysqlBinlogDumpNonBlock)
+	binary.LittleEndian.PutUint32(command[7:11], mysqlBinlogDumpServerId)
+	command = append(command, []byte(logFile)...)
+	this.sequence = 0
+	return this.writePacket(command)
+}
+
+// readDumpEvent returns the next raw event of a requested dump, or nil when the master has reached the end of
+// its binary logs
+func (this *binlogStreamConnection) readDumpEvent() ([]byte, error) {
+	packet, err := this.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if len(packet) == 0 {
+		return nil, fmt.Errorf("Empty binlog dump packet")
+	}
+	switch {
+	case packet[0] == mysqlPacketErr:
+		return nil, parseErrorPacket(packet)
+	case packet[0] == mysqlPacketEOF && len(packet) < 9:
+		return nil, nil
+	case packet[0] != mysqlPacketOK:
+		return nil, fmt.Errorf("Unexpected binlog dump packet: %d", packet[0])
+	}
+	return packet[1:], nil
+}
+
+// dump requests the binary log, starting at given file:pos, and hands each raw event to onEvent.
+// Dumping stops at the end of the master's binary logs, or early when onEvent returns false.
+func (this *binlogStreamConnection) dump(logFile string, logPos int64, onEvent func(data []byte) (bool, error)) error {
+	if err := this.requestDump(logFile, logPos); err != nil {
+		return err
+	}
+	for {
+		data, err := this.readDumpEvent()
+		if err != nil || data == nil {
+			return err
+		}
+		more, err := onEvent(data)
+		if err != nil || !more {
+			return err
+		}
+	}
+}
+
+func (this *binlogStreamConnection) Close() error {
+	return this.conn.Close()
+}
+
+// openBinlogStream connects and authenticates to given instance, using topology credentials
+func openBinlogStream(instanceKey *InstanceKey) (*binlogStreamConnection, error) {
+	var tlsConfig *tls.Config
+	if config.Config.MySQLTopologyUseMutualTLS {
+		var err error
+		if tlsConfig, err = ssl.NewTLSConfig(config.Config.MySQLTopologySSLCAFile, !config.Config.MySQLTopologySSLSkipVerify); err != nil {
+			return nil, err
+		}
+		tlsConfig.MinVersion = tls.VersionTLS10
+		tlsConfig.InsecureSkipVerify = config.Config.MySQLTopologySSLSkipVerify
+		tlsConfig.ServerName = instanceKey.Hostname
+		if err = ssl.AppendKeyPair(tlsConfig, config.Config.MySQLTopologySSLCertFile, config.Config.MySQLTopologySSLPrivateKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", instanceKey.Hostname, instanceKey.Port), time.Duration(config.Config.MySQLConnectTimeoutSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	stream := &binlogStreamConnection{conn: conn}
+	if err := stream.authenticate(config.Config.MySQLTopologyUser, config.Config.MySQLTopologyPassword, tlsConfig); err != nil {
+		stream.Close()
+		return nil, err
+	}
+	// Let the master know we can handle checksums (if it uses any). Servers with no checksum support reject this; that's fine.
+	stream.execute("SET @master_binlog_checksum = @@global.binlog_checksum")
+	stream.execute("SET @mariadb_slave_capability = 4")
+	return stream, nil
+}
+
+// streamBinlogEvents reads the events of a single binary log of given instance over a replication connection
+// (COM_BINLOG_DUMP), starting at given coordinates. onEvent is invoked per event; streaming stops at the end of
+// the binary log, or when onEvent returns false.
+// It returns streamed=false when the replication connection could not be established, in which case the caller
+// is expected to fall back to SHOW BINLOG EVENTS.
+func streamBinlogEvents(instanceKey *InstanceKey, startCoordinates BinlogCoordinates, onEvent func(event *BinlogEvent) bool) (streamed bool, err error) {
+	stream, err := openBinlogStream(instanceKey)
+	if err != nil {
+		log.Warningf("Cannot open binlog stream on %+v; falling back to SHOW BINLOG EVENTS: %+v", *instanceKey, err)
+		return false, nil
+	}
+	defer stream.Close()
+
+	parser := newBinlogEventsParser(startCoordinates.LogFile)
+	err = stream.dump(startCoordinates.LogFile, startCoordinates.LogPos, func(data []byte) (bool, error) {
+		event, err := parser.parseEvent(data)
+		if err != nil {
+			return false, err
+		}
+		if parser.logFile != startCoordinates.LogFile {
+			// Master moved on to next binary log
+			return false, nil
+		}
+		if event == nil {
+			return true, nil
+		}
+		if !onEvent(event) {
+			return false, nil
+		}
+		// A (non artificial) rotate event is the last event of a binary log
+		return event.EventType != binlogEventTypeNames[binlogRotateEvent], nil
+	})
+	return true, err
+}
+
+// binlogEventsStream reads chunks of binary log events over a single replication connection. The connection is
+// kept open for as long as chunks are read sequentially, so that a long scan does not connect and authenticate
+// per chunk. Close it when done reading.
+type binlogEventsStream struct {
+	instanceKey     *InstanceKey
+	stream          *binlogStreamConnection
+	parser          *binlogEventsParser
+	nextCoordinates BinlogCoordinates
+	unavailable     bool
+}
+
+func newBinlogEventsStream(instanceKey *InstanceKey) *binlogEventsStream {
+	return &binlogEventsStream{instanceKey: instanceKey}
+}
+
+// readChunk reads up to chunkSize events of a single binary log, starting at given coordinates. When these follow
+// the previously read chunk, the open dump is continued; otherwise a new dump is requested.
+// It returns streamed=false when the replication connection could not be established, in which case the caller
+// is expected to fall back to SHOW BINLOG EVENTS.
+func (this *binlogEventsStream) readChunk(startCoordinates BinlogCoordinates, chunkSize int) (events []BinlogEvent, streamed bool, err error) {
+	if this.unavailable {
+		return events, false, nil
+	}
+	if this.stream == nil || !this.nextCoordinates.Equals(&startCoordinates) {
+		this.Close()
+		stream, err := openBinlogStream(this.instanceKey)
+		if err != nil {
+			log.Warningf("Cannot open binlog stream on %+v; falling back to SHOW BINLOG EVENTS: %+v", *this.instanceKey, err)
+			this.unavailable = true
+			return events, false, nil
+		}
+		if err := stream.requestDump(startCoordinates.LogFile, startCoordinates.LogPos); err != nil {
+			stream.Close()
+			return events, true, err
+		}
+		this.stream = stream
+		this.parser = newBinlogEventsParser(startCoordinates.LogFile)
+	}
+	this.nextCoordinates = startCoordinates
+	for len(events) < chunkSize {
+		data, err := this.stream.readDumpEvent()
+		if err == nil && data == nil {
+			// End of binary logs; the master has terminated the dump
+			this.Close()
+			return events, true, nil
+		}
+		var event *BinlogEvent
+		if err == nil {
+			event, err = this.parser.parseEvent(data)
+		}
+		if err != nil {
+			this.Close()
+			return events, true, err
+		}
+		if this.parser.logFile != startCoordinates.LogFile {
+			// Master moved on to next binary log, which the dump continues with
+			this.nextCoordinates = BinlogCoordinates{LogFile: this.parser.logFile, LogPos: binlogFileHeaderLength, Type: BinaryLog}
+			return events, true, nil
+		}
+		if event == nil {
+			continue
+		}
+		events = append(events, *event)
+		this.nextCoordinates = event.NextBinlogCoordinates()
+		if event.EventType == binlogEventTypeNames[binlogRotateEvent] {
+			// A (non artificial) rotate event is the last event of a binary log
+			break
+		}
+	}
+	return events, true, nil
+}
+
+// Close closes the replication connection, if open
+func (this *binlogEventsStream) Close() {
+	if this.stream != nil {
+		this.stream.Close()
+		this.stream = nil
+	}
+}
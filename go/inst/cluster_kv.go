@@ -0,0 +1,74 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/kv"
+)
+
+// GetClusterMasterKVPair returns the key-value entry announcing given instance as master of given cluster
+func GetClusterMasterKVPair(clusterAlias string, masterKey *InstanceKey) *kv.KVPair {
+	key := fmt.Sprintf("%s/%s", strings.TrimSuffix(config.Config.KVClusterMasterPrefix, "/"), clusterAlias)
+	return kv.NewKVPair(key, masterKey.StringCode())
+}
+
+// GetClusterReplicaKVPrefix returns the prefix of all replica key-value entries of given cluster
+func GetClusterReplicaKVPrefix(clusterAlias string) string {
+	return fmt.Sprintf("%s/%s/", strings.TrimSuffix(config.Config.KVClusterReplicaPrefix, "/"), clusterAlias)
+}
+
+// GetClusterReplicaKVPair returns the key-value entry announcing given instance as a replica in given cluster
+func GetClusterReplicaKVPair(clusterAlias string, replicaKey *InstanceKey) *kv.KVPair {
+	key := GetClusterReplicaKVPrefix(clusterAlias) + replicaKey.StringCode()
+	return kv.NewKVPair(key, replicaKey.StringCode())
+}
+
+// GetClusterKVPairs returns the key-value entries of a cluster: its master, and any of its instances
+// which are healthy, replicating slaves
+func GetClusterKVPairs(clusterAlias string, master *Instance, clusterInstances [](*Instance)) (kvPairs [](*kv.KVPair)) {
+	kvPairs = append(kvPairs, GetClusterMasterKVPair(clusterAlias, &master.Key))
+	for _, instance := range clusterInstances {
+		if instance.Key.Equals(&master.Key) {
+			continue
+		}
+		if !instance.IsLastCheckValid || !instance.SlaveRunning() {
+			continue
+		}
+		kvPairs = append(kvPairs, GetClusterReplicaKVPair(clusterAlias, &instance.Key))
+	}
+	return kvPairs
+}
+
+// ReadClusterKVPairs reads the cluster of given master and returns its alias and key-value entries
+func ReadClusterKVPairs(master *Instance) (clusterAlias string, kvPairs [](*kv.KVPair), err error) {
+	clusterAlias, err = ReadAliasByClusterName(master.ClusterName)
+	if err != nil {
+		return clusterAlias, kvPairs, err
+	}
+	if clusterAlias == "" {
+		clusterAlias = master.ClusterName
+	}
+	clusterInstances, err := ReadClusterInstances(master.ClusterName)
+	if err != nil {
+		return clusterAlias, kvPairs, err
+	}
+	return clusterAlias, GetClusterKVPairs(clusterAlias, master, clusterInstances), nil
+}
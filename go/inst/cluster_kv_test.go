@@ -0,0 +1,73 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+func TestGetClusterMasterKVPair(t *testing.T) {
+	kvPair := GetClusterMasterKVPair("mycluster", &key1)
+	test.S(t).ExpectEquals(kvPair.Key, "mysql/master/mycluster")
+	test.S(t).ExpectEquals(kvPair.Value, "host1:3306")
+}
+
+func TestGetClusterKVPairsPrefixes(t *testing.T) {
+	defer func(masterPrefix, replicaPrefix string) {
+		config.Config.KVClusterMasterPrefix = masterPrefix
+		config.Config.KVClusterReplicaPrefix = replicaPrefix
+	}(config.Config.KVClusterMasterPrefix, config.Config.KVClusterReplicaPrefix)
+	config.Config.KVClusterMasterPrefix = "db/writer/"
+	config.Config.KVClusterReplicaPrefix = "db/reader"
+
+	test.S(t).ExpectEquals(GetClusterMasterKVPair("mycluster", &key1).Key, "db/writer/mycluster")
+	test.S(t).ExpectEquals(GetClusterReplicaKVPair("mycluster", &key2).Key, "db/reader/mycluster/host2:3306")
+}
+
+func TestGetClusterKVPairs(t *testing.T) {
+	master := NewInstance()
+	master.Key = key1
+	master.IsLastCheckValid = true
+	replica := NewInstance()
+	replica.Key = key2
+	replica.MasterKey = key1
+	replica.ReadBinlogCoordinates = BinlogCoordinates{LogFile: "mysql-bin.000001", LogPos: 4}
+	replica.Slave_SQL_Running = true
+	replica.Slave_IO_Running = true
+	replica.IsLastCheckValid = true
+	brokenReplica := NewInstance()
+	brokenReplica.Key = key3
+	brokenReplica.MasterKey = key1
+	brokenReplica.ReadBinlogCoordinates = BinlogCoordinates{LogFile: "mysql-bin.000001", LogPos: 4}
+	brokenReplica.Slave_SQL_Running = false
+	brokenReplica.Slave_IO_Running = true
+	brokenReplica.IsLastCheckValid = true
+
+	kvPairs := GetClusterKVPairs("mycluster", master, [](*Instance){master, replica, brokenReplica})
+	test.S(t).ExpectEquals(len(kvPairs), 2)
+	test.S(t).ExpectEquals(kvPairs[0].Key, "mysql/master/mycluster")
+	test.S(t).ExpectEquals(kvPairs[0].Value, "host1:3306")
+	test.S(t).ExpectEquals(kvPairs[1].Key, "mysql/replica/mycluster/host2:3306")
+	test.S(t).ExpectEquals(kvPairs[1].Value, "host2:3306")
+
+	replica.IsLastCheckValid = false
+	kvPairs = GetClusterKVPairs("mycluster", master, [](*Instance){master, replica, brokenReplica})
+	test.S(t).ExpectEquals(len(kvPairs), 1)
+}
@@ -0,0 +1,67 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+// ClusterLock is an advisory, cluster-wide lock (also in the database).
+// Operations which refactor multiple instances of a cluster (regroup, relocate-slaves, make-master etc.)
+// acquire it so that no two such operations (or an operation and a recovery) run on the same cluster concurrently.
+type ClusterLock struct {
+	ClusterName            string
+	LockToken              string
+	Owner                  string
+	Reason                 string
+	BeginTimestamp         string
+	EndTimestamp           string
+	SecondsElapsed         uint
+	ProcessingNodeHostname string
+	ProcessingNodeToken    string
+}
+
+// The following run topology operations without acquiring the cluster lock. They are used by recovery, which
+// acquires the cluster lock once and holds it throughout its topology changes. Caller is expected to hold the
+// cluster lock.
+
+// RegroupSlavesLocked is RegroupSlaves, without acquiring the cluster lock
+func RegroupSlavesLocked(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool, onCandidateSlaveChosen func(*Instance), postponedFunctionsContainer *PostponedFunctionsContainer) ([](*Instance), [](*Instance), [](*Instance), [](*Instance), *Instance, error) {
+	return regroupSlaves(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen, postponedFunctionsContainer)
+}
+
+// RegroupSlavesGTIDLocked is RegroupSlavesGTID, without acquiring the cluster lock
+func RegroupSlavesGTIDLocked(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool, onCandidateSlaveChosen func(*Instance)) ([](*Instance), [](*Instance), [](*Instance), *Instance, error) {
+	return regroupSlavesGTID(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen)
+}
+
+// RegroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServersLocked is RegroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers,
+// without acquiring the cluster lock
+func RegroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServersLocked(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool, onCandidateSlaveChosen func(*Instance), postponedFunctionsContainer *PostponedFunctionsContainer) ([](*Instance), [](*Instance), [](*Instance), [](*Instance), *Instance, error) {
+	return regroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen, postponedFunctionsContainer)
+}
+
+// RegroupSlavesBinlogServersLocked is RegroupSlavesBinlogServers, without acquiring the cluster lock
+func RegroupSlavesBinlogServersLocked(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool) (repointedBinlogServers [](*Instance), promotedBinlogServer *Instance, err error) {
+	return regroupSlavesBinlogServers(masterKey, returnSlaveEvenOnFailureToRegroup)
+}
+
+// RelocateSlavesLocked is RelocateSlaves, without acquiring the cluster lock
+func RelocateSlavesLocked(instanceKey, otherKey *InstanceKey, pattern string) (slaves [](*Instance), other *Instance, err error, errs []error) {
+	return relocateSlaves(instanceKey, otherKey, pattern)
+}
+
+// EnslaveMasterLocked is EnslaveMaster, without acquiring the cluster lock
+func EnslaveMasterLocked(instanceKey *InstanceKey) (*Instance, error) {
+	return enslaveMaster(instanceKey)
+}
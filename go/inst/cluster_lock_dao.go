@@ -0,0 +1,243 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"fmt"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/golib/sqlutils"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/db"
+	"github.com/outbrain/orchestrator/go/process"
+)
+
+// readClusterLocks reads cluster locks matching given condition
+func readClusterLocks(whereCondition string, args []interface{}) ([]ClusterLock, error) {
+	res := []ClusterLock{}
+	query := fmt.Sprintf(`
+		select
+			cluster_name,
+			lock_token,
+			owner,
+			reason,
+			begin_timestamp,
+			end_timestamp,
+			timestampdiff(second, begin_timestamp, now()) as seconds_elapsed,
+			processing_node_hostname,
+			processing_node_token
+		from
+			database_cluster_lock
+		where
+			end_timestamp > now()
+			%s
+		order by
+			cluster_name
+		`, whereCondition)
+	err := db.QueryOrchestrator(query, args, func(m sqlutils.RowMap) error {
+		clusterLock := ClusterLock{}
+		clusterLock.ClusterName = m.GetString("cluster_name")
+		clusterLock.LockToken = m.GetString("lock_token")
+		clusterLock.Owner = m.GetString("owner")
+		clusterLock.Reason = m.GetString("reason")
+		clusterLock.BeginTimestamp = m.GetString("begin_timestamp")
+		clusterLock.EndTimestamp = m.GetString("end_timestamp")
+		clusterLock.SecondsElapsed = m.GetUint("seconds_elapsed")
+		clusterLock.ProcessingNodeHostname = m.GetString("processing_node_hostname")
+		clusterLock.ProcessingNodeToken = m.GetString("processing_node_token")
+
+		res = append(res, clusterLock)
+		return nil
+	})
+
+	if err != nil {
+		log.Errore(err)
+	}
+	return res, err
+}
+
+// ReadActiveClusterLocks returns the list of currently held cluster locks
+func ReadActiveClusterLocks() ([]ClusterLock, error) {
+	return readClusterLocks(``, sqlutils.Args())
+}
+
+// ReadClusterLock returns the lock currently held on given cluster, or nil if the cluster is not locked
+func ReadClusterLock(clusterName string) (*ClusterLock, error) {
+	clusterLocks, err := readClusterLocks(`and cluster_name = ?`, sqlutils.Args(clusterName))
+	if err != nil {
+		return nil, err
+	}
+	if len(clusterLocks) == 0 {
+		return nil, nil
+	}
+	return &clusterLocks[0], nil
+}
+
+// releaseStaleClusterLock removes the lock on given cluster if it has expired or if the node holding it is gone
+func releaseStaleClusterLock(clusterName string) error {
+	res, err := db.ExecOrchestrator(`
+			delete
+				database_cluster_lock
+			from
+				database_cluster_lock
+				left join node_health on (processing_node_hostname = node_health.hostname AND processing_node_token = node_health.token)
+			where
+				cluster_name = ?
+				and (
+					end_timestamp < NOW()
+					or node_health.last_seen_active IS NULL
+				)
+			`,
+		clusterName,
+	)
+	if err != nil {
+		return log.Errore(err)
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected > 0 {
+		AuditOperation("expire-cluster-lock", nil, fmt.Sprintf("cluster: %s", clusterName))
+	}
+	return nil
+}
+
+// BeginClusterLock acquires the lock on given cluster. It fails if the cluster is already locked.
+// Returned lock token is to be used for releasing the lock via EndClusterLock.
+func BeginClusterLock(clusterName string, owner string, reason string) (string, error) {
+	if clusterName == "" {
+		return "", fmt.Errorf("BeginClusterLock: empty cluster name")
+	}
+	if err := releaseStaleClusterLock(clusterName); err != nil {
+		return "", err
+	}
+	lockToken := process.NewToken().Hash
+	res, err := db.ExecOrchestrator(`
+			insert ignore
+				into database_cluster_lock (
+					cluster_name, lock_token, owner, reason, begin_timestamp, end_timestamp,
+					processing_node_hostname, processing_node_token
+				) VALUES (
+					?, ?, ?, ?, NOW(), NOW() + INTERVAL ? MINUTE,
+					?, ?
+				)
+			`,
+		clusterName,
+		lockToken,
+		owner,
+		reason,
+		config.Config.ClusterLockExpireMinutes,
+		process.ThisHostname,
+		process.ProcessToken.Hash,
+	)
+	if err != nil {
+		return "", log.Errore(err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		if clusterLock, _ := ReadClusterLock(clusterName); clusterLock != nil {
+			return "", fmt.Errorf("Cannot lock cluster %s; it is locked by %s since %s; reason: %s", clusterName, clusterLock.Owner, clusterLock.BeginTimestamp, clusterLock.Reason)
+		}
+		return "", fmt.Errorf("Cannot lock cluster %s; lock reason: %s", clusterName, reason)
+	}
+	AuditOperation("begin-cluster-lock", nil, fmt.Sprintf("cluster: %s, owner: %s, reason: %s", clusterName, owner, reason))
+	return lockToken, nil
+}
+
+// BeginClusterLockByInstanceKey acquires the lock on the cluster given instance belongs to
+func BeginClusterLockByInstanceKey(instanceKey *InstanceKey, owner string, reason string) (string, error) {
+	clusterName, err := GetClusterName(instanceKey)
+	if err != nil {
+		return "", err
+	}
+	if clusterName == "" {
+		return "", fmt.Errorf("Cannot lock cluster of %+v: unable to deduce cluster name", *instanceKey)
+	}
+	return BeginClusterLock(clusterName, owner, fmt.Sprintf("%s: %+v", reason, instanceKey.DisplayString()))
+}
+
+// EndClusterLock releases a cluster lock via its lock token
+func EndClusterLock(lockToken string) error {
+	clusterLock, err := readClusterLocks(`and lock_token = ?`, sqlutils.Args(lockToken))
+	if err != nil {
+		return err
+	}
+	res, err := db.ExecOrchestrator(`
+			delete from
+				database_cluster_lock
+			where
+				lock_token = ?
+			`,
+		lockToken,
+	)
+	if err != nil {
+		return log.Errore(err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		// Lock has been expired or preempted in the meantime
+		return fmt.Errorf("Cluster lock not found; token = %+v", lockToken)
+	}
+	if len(clusterLock) > 0 {
+		AuditOperation("end-cluster-lock", nil, fmt.Sprintf("cluster: %s", clusterLock[0].ClusterName))
+	}
+	return nil
+}
+
+// ForceEndClusterLock releases the lock on given cluster, whoever holds it.
+// This is used by operators to release stuck locks, and by recovery to preempt a lock.
+func ForceEndClusterLock(clusterName string, reason string) error {
+	clusterLock, err := ReadClusterLock(clusterName)
+	if err != nil {
+		return err
+	}
+	if clusterLock == nil {
+		return fmt.Errorf("Cluster %s is not locked", clusterName)
+	}
+	res, err := db.ExecOrchestrator(`
+			delete from
+				database_cluster_lock
+			where
+				cluster_name = ?
+			`,
+		clusterName,
+	)
+	if err != nil {
+		return log.Errore(err)
+	}
+	if affected, _ := res.RowsAffected(); affected > 0 {
+		AuditOperation("force-end-cluster-lock", nil, fmt.Sprintf("cluster: %s, owner: %s, lock reason: %s; %s", clusterName, clusterLock.Owner, clusterLock.Reason, reason))
+	}
+	return nil
+}
+
+// ExpireClusterLocks releases cluster locks which have expired or are held by nodes which are no longer alive
+func ExpireClusterLocks() error {
+	res, err := db.ExecOrchestrator(`
+			delete
+				database_cluster_lock
+			from
+				database_cluster_lock
+				left join node_health on (processing_node_hostname = node_health.hostname AND processing_node_token = node_health.token)
+			where
+				end_timestamp < NOW()
+				or node_health.last_seen_active IS NULL
+			`,
+	)
+	if err != nil {
+		return log.Errore(err)
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected > 0 {
+		AuditOperation("expire-cluster-lock", nil, fmt.Sprintf("Expired: %d", rowsAffected))
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"strconv"
+)
+
+const (
+	GaleraClusterStatusPrimary      = "Primary"
+	GaleraLocalStateCommentSynced   = "Synced"
+	GaleraLocalStateCommentDesynced = "Donor/Desynced"
+)
+
+// applyGaleraStatus sets this instance's Galera facts given its wsrep_* status variables. An instance
+// reporting no cluster state UUID is not a Galera node
+func (this *Instance) applyGaleraStatus(wsrepStatus map[string]string) {
+	this.GaleraClusterStateUUID = wsrepStatus["wsrep_cluster_state_uuid"]
+	if this.GaleraClusterStateUUID == "" {
+		return
+	}
+	this.GaleraClusterStatus = wsrepStatus["wsrep_cluster_status"]
+	this.GaleraLocalStateComment = wsrepStatus["wsrep_local_state_comment"]
+	clusterSize, _ := strconv.ParseUint(wsrepStatus["wsrep_cluster_size"], 10, 0)
+	this.GaleraClusterSize = uint(clusterSize)
+	this.GaleraFlowControlPaused, _ = strconv.ParseFloat(wsrepStatus["wsrep_flow_control_paused"], 64)
+	this.GaleraFlowControlSent, _ = strconv.ParseUint(wsrepStatus["wsrep_flow_control_sent"], 10, 64)
+	this.GaleraFlowControlRecv, _ = strconv.ParseUint(wsrepStatus["wsrep_flow_control_recv"], 10, 64)
+}
+
+// IsGaleraNode returns true when this instance is a Galera (e.g. Percona XtraDB Cluster, MariaDB Galera Cluster) node
+func (this *Instance) IsGaleraNode() bool {
+	return this.GaleraClusterStateUUID != ""
+}
+
+// IsGaleraPrimaryComponent returns true when this Galera node is part of the primary component, hence serves queries
+func (this *Instance) IsGaleraPrimaryComponent() bool {
+	return this.GaleraClusterStatus == GaleraClusterStatusPrimary
+}
+
+// IsGaleraSynced returns true when this Galera node is in sync with its cluster
+func (this *Instance) IsGaleraSynced() bool {
+	return this.GaleraLocalStateComment == GaleraLocalStateCommentSynced
+}
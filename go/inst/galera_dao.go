@@ -0,0 +1,61 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/golib/sqlutils"
+	"github.com/outbrain/orchestrator/go/db"
+)
+
+// readGaleraClusterKey returns the key of the node a Galera cluster is named by, among the known nodes not replicating
+// asynchronously from elsewhere: the node the cluster is already named by, such that the cluster is not renamed as
+// nodes join; or else the node with smallest hostname and port
+func readGaleraClusterKey(clusterStateUUID string) (*InstanceKey, error) {
+	var galeraClusterKey *InstanceKey
+	query := `
+		select
+			hostname,
+			port
+		from
+			database_instance
+		where
+			galera_cluster_state_uuid = ?
+			and master_host in ('', '_')
+		order by
+			cluster_name = concat(hostname, ':', port) desc,
+			hostname asc,
+			port asc
+		limit 1
+		`
+	err := db.QueryOrchestrator(query, sqlutils.Args(clusterStateUUID), func(m sqlutils.RowMap) error {
+		galeraClusterKey = &InstanceKey{Hostname: m.GetString("hostname"), Port: m.GetInt("port")}
+		return nil
+	})
+	if err != nil {
+		return nil, log.Errore(err)
+	}
+	return galeraClusterKey, nil
+}
+
+// ReadGaleraClusterInstances returns the known nodes of the Galera cluster of given state UUID
+func ReadGaleraClusterInstances(clusterStateUUID string) ([](*Instance), error) {
+	condition := `
+		galera_cluster_state_uuid = ?
+	`
+	return readInstancesByCondition(condition, sqlutils.Args(clusterStateUUID), "hostname, port")
+}
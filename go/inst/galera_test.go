@@ -0,0 +1,64 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestApplyGaleraStatus(t *testing.T) {
+	instance := NewInstance()
+	instance.applyGaleraStatus(map[string]string{
+		"wsrep_cluster_state_uuid":  "e2b5b5a9-8f5c-11e6-9b7a-0242ac110002",
+		"wsrep_cluster_status":      "Primary",
+		"wsrep_local_state_comment": "Synced",
+		"wsrep_cluster_size":        "3",
+		"wsrep_flow_control_paused": "0.012345",
+		"wsrep_flow_control_sent":   "7",
+		"wsrep_flow_control_recv":   "19",
+	})
+	test.S(t).ExpectTrue(instance.IsGaleraNode())
+	test.S(t).ExpectTrue(instance.IsGaleraPrimaryComponent())
+	test.S(t).ExpectTrue(instance.IsGaleraSynced())
+	test.S(t).ExpectEquals(instance.GaleraClusterSize, uint(3))
+	test.S(t).ExpectEquals(instance.GaleraFlowControlPaused, 0.012345)
+	test.S(t).ExpectEquals(instance.GaleraFlowControlSent, uint64(7))
+	test.S(t).ExpectEquals(instance.GaleraFlowControlRecv, uint64(19))
+}
+
+func TestApplyGaleraStatusNonPrimary(t *testing.T) {
+	instance := NewInstance()
+	instance.applyGaleraStatus(map[string]string{
+		"wsrep_cluster_state_uuid":  "e2b5b5a9-8f5c-11e6-9b7a-0242ac110002",
+		"wsrep_cluster_status":      "non-Primary",
+		"wsrep_local_state_comment": "Initialized",
+	})
+	test.S(t).ExpectTrue(instance.IsGaleraNode())
+	test.S(t).ExpectFalse(instance.IsGaleraPrimaryComponent())
+	test.S(t).ExpectFalse(instance.IsGaleraSynced())
+}
+
+func TestApplyGaleraStatusNotGalera(t *testing.T) {
+	instance := NewInstance()
+	instance.applyGaleraStatus(map[string]string{
+		"wsrep_cluster_status": "Disconnected",
+	})
+	test.S(t).ExpectFalse(instance.IsGaleraNode())
+	test.S(t).ExpectEquals(instance.GaleraClusterStatus, "")
+}
@@ -84,6 +84,8 @@ type Instance struct {
 	SQLDelay               uint
 	ExecutedGtidSet        string
 	GtidPurged             string
+	ReplicationChannel     string               // name of the channel by which the above replication state is presented
+	ReplicationChannels    []ReplicationChannel // all channels, on a multi-source instance
 
 	SlaveLagSeconds                 sql.NullInt64
 	SlaveHosts                      InstanceKeyMap
@@ -96,6 +98,19 @@ type Instance struct {
 	HasReplicationCredentials       bool
 	ReplicationCredentialsAvailable bool
 	SemiSyncEnforced                bool
+	ReplicationGroupName            string
+	ReplicationGroupIsSinglePrimary bool
+	ReplicationGroupMemberState     string
+	ReplicationGroupMemberRole      string
+	ReplicationGroupMembers         []ReplicationGroupMember // the group's members, as seen by this instance
+	ReplicationGroupPrimaryKey      InstanceKey              // in single-primary mode
+	GaleraClusterStateUUID          string
+	GaleraClusterStatus             string // Primary, non-Primary or Disconnected
+	GaleraLocalStateComment         string // e.g. Synced, Donor/Desynced, Joining, Joined
+	GaleraClusterSize               uint
+	GaleraFlowControlPaused         float64 // fraction of time replication was paused due to flow control
+	GaleraFlowControlSent           uint64
+	GaleraFlowControlRecv           uint64
 
 	LastSeenTimestamp    string
 	IsLastCheckValid     bool
@@ -112,6 +127,10 @@ type Instance struct {
 	DowntimeEndTimestamp string
 	UnresolvedHostname   string
 	AllowTLS             bool
+	MasterSSL            MasterSSLSettings
+
+	CustomAnalysis []CustomAnalysis
+	AgentAnalysis  []AgentAnalysis
 }
 
 // NewInstance creates a new, empty instance
@@ -220,6 +239,12 @@ func (this *Instance) SlaveRunning() bool {
 	return this.IsSlave() && this.Slave_SQL_Running && this.Slave_IO_Running
 }
 
+// IsFailingToConnectToMaster returns true when the IO thread is down due to connection problems, as opposed to
+// having been stopped
+func (this *Instance) IsFailingToConnectToMaster() bool {
+	return !this.Slave_IO_Running && failingToConnectToMasterRegexp.MatchString(this.LastIOError)
+}
+
 // SQLThreadUpToDate returns true when the instance had consumed all relay logs.
 func (this *Instance) SQLThreadUpToDate() bool {
 	return this.ReadBinlogCoordinates.Equals(&this.ExecBinlogCoordinates)
@@ -82,6 +82,31 @@ func getLastPseudoGTIDEntryInBinlog(pseudoGTIDRegexp *regexp.Regexp, instanceKey
 	step := 0
 
 	entryText := ""
+	// onEntry handles a single binlog entry; it returns false when past the limitation
+	onEntry := func(pos int64, binlogEntryInfo string) bool {
+		if pseudoGTIDMatches(pseudoGTIDRegexp, binlogEntryInfo) {
+			if maxCoordinates != nil && maxCoordinates.SmallerThan(&BinlogCoordinates{LogFile: binlog, LogPos: pos}) {
+				// past the limitation
+				return false
+			}
+			binlogCoordinates.LogPos = pos
+			entryText = binlogEntryInfo
+			// Found a match. But we keep searching: we're interested in the LAST entry, and, alas,
+			// we can only search in ASCENDING order...
+		}
+		return true
+	}
+	if binlogType == BinaryLog && config.Config.StreamBinlogEvents {
+		streamed, err := streamBinlogEvents(instanceKey, BinlogCoordinates{LogFile: binlog, LogPos: nextPos, Type: BinaryLog}, func(event *BinlogEvent) bool {
+			return onEntry(event.Coordinates.LogPos, event.Info)
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		if streamed {
+			moreRowsExpected = false
+		}
+	}
 	for moreRowsExpected {
 		query := ""
 		if binlogCoordinates.Type == BinaryLog {
@@ -98,17 +123,8 @@ func getLastPseudoGTIDEntryInBinlog(pseudoGTIDRegexp *regexp.Regexp, instanceKey
 		err = queryRowsFunc(db, query, func(m sqlutils.RowMap) error {
 			moreRowsExpected = true
 			nextPos = m.GetInt64("End_log_pos")
-			binlogEntryInfo := m.GetString("Info")
-			if pseudoGTIDMatches(pseudoGTIDRegexp, binlogEntryInfo) {
-				if maxCoordinates != nil && maxCoordinates.SmallerThan(&BinlogCoordinates{LogFile: binlog, LogPos: m.GetInt64("Pos")}) {
-					// past the limitation
-					moreRowsExpected = false
-					return nil
-				}
-				binlogCoordinates.LogPos = m.GetInt64("Pos")
-				entryText = binlogEntryInfo
-				// Found a match. But we keep searching: we're interested in the LAST entry, and, alas,
-				// we can only search in ASCENDING order...
+			if !onEntry(m.GetInt64("Pos"), m.GetString("Info")) {
+				moreRowsExpected = false
 			}
 			return nil
 		})
@@ -222,6 +238,44 @@ func SearchEntryInBinlog(pseudoGTIDRegexp *regexp.Regexp, instanceKey *InstanceK
 		nextPos = minBinlogCoordinates.LogPos
 	}
 
+	// onEntry handles a single binlog entry; it returns false when the entry is found, or when there is
+	// no point in scanning the rest of the binlog
+	onEntry := func(pos int64, binlogEntryInfo string) bool {
+		if binlogEntryInfo == entryText {
+			// found it!
+			binlogCoordinates.LogPos = pos
+			return false
+		}
+		if monotonicPseudoGTIDEntries && !alreadyMatchedAscendingPseudoGTID {
+			// This part assumes we're searching for Pseudo-GTID.Typically that is the case, however this function can
+			// also be used for generic searches through the binary log.
+			// More heavyweight computation here. Need to verify whether the binlog entry we have is a pseudo-gtid entry
+			// We only want to check for ASCENDING once in the top of the binary log.
+			// If we find the first entry to be higher than the searched one, clearly we are done.
+			// If not, then by virtue of binary logs, we still have to full-scan the entrie binlog sequentially; we
+			// do not check again for ASCENDING (no point), so we save up CPU energy wasted in regexp.
+			if pseudoGTIDMatches(pseudoGTIDRegexp, binlogEntryInfo) {
+				alreadyMatchedAscendingPseudoGTID = true
+				log.Debugf("Matched ascending Pseudo-GTID entry in %+v", binlog)
+				if binlogEntryInfo > entryText {
+					// Entries ascending, and current entry is larger than the one we are searching for.
+					// There is no need to scan further on. We can skip the entire binlog
+					log.Debugf(`Pseudo GTID entries are monotonic and we hit "%+v" > "%+v"; skipping binlog %+v`, binlogEntryInfo, entryText, binlogCoordinates.LogFile)
+					return false
+				}
+			}
+		}
+		return true
+	}
+	if config.Config.StreamBinlogEvents {
+		streamed, err := streamBinlogEvents(instanceKey, BinlogCoordinates{LogFile: binlog, LogPos: nextPos, Type: BinaryLog}, func(event *BinlogEvent) bool {
+			return onEntry(event.Coordinates.LogPos, event.Info)
+		})
+		if streamed || err != nil {
+			return binlogCoordinates, (binlogCoordinates.LogPos != 0), err
+		}
+	}
+
 	//	commandToken := math.TernaryString(binlogCoordinates.Type == BinaryLog, "binlog", "relaylog")
 	for moreRowsExpected {
 		query := fmt.Sprintf("show binlog events in '%s' FROM %d LIMIT %d", binlog, nextPos, config.Config.BinlogEventsChunkSize)
@@ -244,30 +298,8 @@ func SearchEntryInBinlog(pseudoGTIDRegexp *regexp.Regexp, instanceKey *InstanceK
 			}
 			moreRowsExpected = true
 			nextPos = m.GetInt64("End_log_pos")
-			binlogEntryInfo := m.GetString("Info")
-			//
-			if binlogEntryInfo == entryText {
-				// found it!
-				binlogCoordinates.LogPos = m.GetInt64("Pos")
-			} else if monotonicPseudoGTIDEntries && !alreadyMatchedAscendingPseudoGTID {
-				// This part assumes we're searching for Pseudo-GTID.Typically that is the case, however this function can
-				// also be used for generic searches through the binary log.
-				// More heavyweight computation here. Need to verify whether the binlog entry we have is a pseudo-gtid entry
-				// We only want to check for ASCENDING once in the top of the binary log.
-				// If we find the first entry to be higher than the searched one, clearly we are done.
-				// If not, then by virtue of binary logs, we still have to full-scan the entrie binlog sequentially; we
-				// do not check again for ASCENDING (no point), so we save up CPU energy wasted in regexp.
-				if pseudoGTIDMatches(pseudoGTIDRegexp, binlogEntryInfo) {
-					alreadyMatchedAscendingPseudoGTID = true
-					log.Debugf("Matched ascending Pseudo-GTID entry in %+v", binlog)
-					if binlogEntryInfo > entryText {
-						// Entries ascending, and current entry is larger than the one we are searching for.
-						// There is no need to scan further on. We can skip the entire binlog
-						log.Debugf(`Pseudo GTID entries are monotonic and we hit "%+v" > "%+v"; skipping binlog %+v`, m.GetString("Info"), entryText, binlogCoordinates.LogFile)
-						skipRestOfBinlog = true
-						return nil
-					}
-				}
+			if !onEntry(m.GetInt64("Pos"), m.GetString("Info")) {
+				skipRestOfBinlog = true
 			}
 			return nil
 		})
@@ -346,8 +378,9 @@ func SearchEntryInInstanceBinlogs(instance *Instance, entryText string, monotoni
 	return nil, log.Errorf("Cannot match pseudo GTID entry in binlogs of %+v; err: %+v", instance.Key, err)
 }
 
-// Read (as much as possible of) a chunk of binary log events starting the given startingCoordinates
-func readBinlogEventsChunk(instanceKey *InstanceKey, startingCoordinates BinlogCoordinates) ([]BinlogEvent, error) {
+// Read (as much as possible of) a chunk of binary log events starting the given startingCoordinates.
+// Binary log events are read off given stream when streaming is enabled.
+func readBinlogEventsChunk(instanceKey *InstanceKey, startingCoordinates BinlogCoordinates, stream *binlogEventsStream) ([]BinlogEvent, error) {
 	events := []BinlogEvent{}
 	db, err := db.OpenTopology(instanceKey.Hostname, instanceKey.Port)
 	if err != nil {
@@ -357,6 +390,12 @@ func readBinlogEventsChunk(instanceKey *InstanceKey, startingCoordinates BinlogC
 	if startingCoordinates.LogFile == "" {
 		return events, log.Errorf("readBinlogEventsChunk: empty binlog file name for %+v.", *instanceKey)
 	}
+	if startingCoordinates.Type == BinaryLog && config.Config.StreamBinlogEvents && stream != nil {
+		streamedEvents, streamed, err := stream.readChunk(startingCoordinates, config.Config.BinlogEventsChunkSize)
+		if streamed || err != nil {
+			return streamedEvents, err
+		}
+	}
 	query := fmt.Sprintf("show %s events in '%s' FROM %d LIMIT %d", commandToken, startingCoordinates.LogFile, startingCoordinates.LogPos, config.Config.BinlogEventsChunkSize)
 	err = sqlutils.QueryRowsMap(db, query, func(m sqlutils.RowMap) error {
 		binlogEvent := BinlogEvent{}
@@ -375,7 +414,7 @@ func readBinlogEventsChunk(instanceKey *InstanceKey, startingCoordinates BinlogC
 
 // Return the next chunk of binlog events; skip to next binary log file if need be; return empty result only
 // if reached end of binary logs
-func getNextBinlogEventsChunk(instance *Instance, startingCoordinates BinlogCoordinates, numEmptyBinlogs int) ([]BinlogEvent, error) {
+func getNextBinlogEventsChunk(instance *Instance, startingCoordinates BinlogCoordinates, numEmptyBinlogs int, stream *binlogEventsStream) ([]BinlogEvent, error) {
 	if numEmptyBinlogs > maxEmptyBinlogFiles {
 		log.Debugf("Reached maxEmptyBinlogFiles (%d) at %+v", maxEmptyBinlogFiles, startingCoordinates)
 		// Give up and return empty results
@@ -393,7 +432,7 @@ func getNextBinlogEventsChunk(instance *Instance, startingCoordinates BinlogCoor
 		log.Debugf("Coordinates overflow: %+v; terminating search", startingCoordinates)
 		return []BinlogEvent{}, nil
 	}
-	events, err := readBinlogEventsChunk(&instance.Key, startingCoordinates)
+	events, err := readBinlogEventsChunk(&instance.Key, startingCoordinates, stream)
 	if err != nil {
 		return events, err
 	}
@@ -405,7 +444,7 @@ func getNextBinlogEventsChunk(instance *Instance, startingCoordinates BinlogCoor
 	// events are empty
 	if nextCoordinates, err := instance.GetNextBinaryLog(startingCoordinates); err == nil {
 		log.Debugf("Recursing into %+v", nextCoordinates)
-		return getNextBinlogEventsChunk(instance, nextCoordinates, numEmptyBinlogs+1)
+		return getNextBinlogEventsChunk(instance, nextCoordinates, numEmptyBinlogs+1, stream)
 	}
 	// on error
 	return events, err
@@ -422,13 +461,18 @@ func getNextBinlogEventsChunk(instance *Instance, startingCoordinates BinlogCoor
 func GetNextBinlogCoordinatesToMatch(instance *Instance, instanceCoordinates BinlogCoordinates, recordedInstanceRelayLogCoordinates BinlogCoordinates, maxBinlogCoordinates *BinlogCoordinates,
 	other *Instance, otherCoordinates BinlogCoordinates) (*BinlogCoordinates, int, error) {
 
+	// Each instance's binary logs are streamed over a single connection throughout the scan
+	instanceStream := newBinlogEventsStream(&instance.Key)
+	defer instanceStream.Close()
 	fetchNextEvents := func(binlogCoordinates BinlogCoordinates) ([]BinlogEvent, error) {
-		return getNextBinlogEventsChunk(instance, binlogCoordinates, 0)
+		return getNextBinlogEventsChunk(instance, binlogCoordinates, 0, instanceStream)
 	}
 	instanceCursor := NewBinlogEventCursor(instanceCoordinates, fetchNextEvents)
 
+	otherStream := newBinlogEventsStream(&other.Key)
+	defer otherStream.Close()
 	fetchOtherNextEvents := func(binlogCoordinates BinlogCoordinates) ([]BinlogEvent, error) {
-		return getNextBinlogEventsChunk(other, binlogCoordinates, 0)
+		return getNextBinlogEventsChunk(other, binlogCoordinates, 0, otherStream)
 	}
 	otherCursor := NewBinlogEventCursor(otherCoordinates, fetchOtherNextEvents)
 
@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/outbrain/golib/log"
@@ -48,8 +50,17 @@ func (this InstancesByCountSlaveHosts) Less(i, j int) bool {
 }
 
 // instanceKeyInformativeClusterName is a non-authoritative cache; used for auditing or general purpose.
+var instanceKeyInformativeClusterNameMutex sync.RWMutex
 var instanceKeyInformativeClusterName = cache.New(time.Duration(config.Config.InstancePollSeconds/2)*time.Second, time.Second)
 
+// getInstanceKeyInformativeClusterName returns the informative cluster name cache, which is replaced upon
+// InstancePollSeconds change
+func getInstanceKeyInformativeClusterName() *cache.Cache {
+	instanceKeyInformativeClusterNameMutex.RLock()
+	defer instanceKeyInformativeClusterNameMutex.RUnlock()
+	return instanceKeyInformativeClusterName
+}
+
 var readTopologyInstanceCounter = metrics.NewCounter()
 var readInstanceCounter = metrics.NewCounter()
 var writeInstanceCounter = metrics.NewCounter()
@@ -58,6 +69,13 @@ func init() {
 	metrics.Register("instance.read_topology", readTopologyInstanceCounter)
 	metrics.Register("instance.read", readInstanceCounter)
 	metrics.Register("instance.write", writeInstanceCounter)
+	config.OnChange(func(changes config.ConfigurationChanges) {
+		if changes.HasField("InstancePollSeconds") {
+			instanceKeyInformativeClusterNameMutex.Lock()
+			defer instanceKeyInformativeClusterNameMutex.Unlock()
+			instanceKeyInformativeClusterName = cache.New(time.Duration(config.Config.InstancePollSeconds/2)*time.Second, time.Second)
+		}
+	})
 }
 
 // ExecDBWriteFunc chooses how to execute a write onto the database: whether synchronuously or not
@@ -247,61 +265,83 @@ func ReadTopologyInstance(instanceKey *InstanceKey) (*Instance, error) {
 		// This can be overriden by later invocation of DetectPhysicalEnvironmentQuery
 	}
 
-	err = sqlutils.QueryRowsMap(db, "show slave status", func(m sqlutils.RowMap) error {
-		instance.HasReplicationCredentials = (m.GetString("Master_User") != "")
-		instance.Slave_IO_Running = (m.GetString("Slave_IO_Running") == "Yes")
-		if isMaxScale110 {
-			// Covering buggy MaxScale 1.1.0
-			instance.Slave_IO_Running = instance.Slave_IO_Running && (m.GetString("Slave_IO_State") == "Binlog Dump")
-		}
-		instance.Slave_SQL_Running = (m.GetString("Slave_SQL_Running") == "Yes")
-		instance.ReadBinlogCoordinates.LogFile = m.GetString("Master_Log_File")
-		instance.ReadBinlogCoordinates.LogPos = m.GetInt64("Read_Master_Log_Pos")
-		instance.ExecBinlogCoordinates.LogFile = m.GetString("Relay_Master_Log_File")
-		instance.ExecBinlogCoordinates.LogPos = m.GetInt64("Exec_Master_Log_Pos")
-		instance.IsDetached, _, _ = instance.ExecBinlogCoordinates.DetachedCoordinates()
-		instance.RelaylogCoordinates.LogFile = m.GetString("Relay_Log_File")
-		instance.RelaylogCoordinates.LogPos = m.GetInt64("Relay_Log_Pos")
-		instance.RelaylogCoordinates.Type = RelayLog
-		instance.LastSQLError = m.GetString("Last_SQL_Error")
-		instance.LastIOError = m.GetString("Last_IO_Error")
-		instance.SQLDelay = m.GetUintD("SQL_Delay", 0)
-		instance.UsingOracleGTID = (m.GetIntD("Auto_Position", 0) == 1)
-		instance.ExecutedGtidSet = m.GetStringD("Executed_Gtid_Set", "")
-		instance.UsingMariaDBGTID = (m.GetStringD("Using_Gtid", "No") != "No")
-		instance.HasReplicationFilters = ((m.GetStringD("Replicate_Do_DB", "") != "") || (m.GetStringD("Replicate_Ignore_DB", "") != "") || (m.GetStringD("Replicate_Do_Table", "") != "") || (m.GetStringD("Replicate_Ignore_Table", "") != "") || (m.GetStringD("Replicate_Wild_Do_Table", "") != "") || (m.GetStringD("Replicate_Wild_Ignore_Table", "") != ""))
-
-		masterHostname := m.GetString("Master_Host")
-		if isMaxScale110 {
-			// Buggy buggy maxscale 1.1.0. Reported Master_Host can be corrupted.
-			// Therefore we (currently) take @@hostname (which is masquarading as master host anyhow)
-			masterHostname = maxScaleMasterHostname
-		}
-		masterKey, err := NewInstanceKeyFromStrings(masterHostname, m.GetString("Master_Port"))
+	{
+		// Multi-source slaves list a row per channel (MySQL) or per named connection (MariaDB)
+		slaveStatusQuery := "show slave status"
+		if instance.IsMariaDB() && !instance.IsSmallerMajorVersionByString("10.0") {
+			slaveStatusQuery = "show all slaves status"
+		}
+		slaveStatusRows := []sqlutils.RowMap{}
+		err = sqlutils.QueryRowsMap(db, slaveStatusQuery, func(m sqlutils.RowMap) error {
+			if isReplicationGroupChannel(m.GetStringD("Channel_Name", "")) {
+				// Group Replication's internal channels are not asynchronous replication
+				return nil
+			}
+			slaveStatusRows = append(slaveStatusRows, m)
+			return nil
+		})
 		if err != nil {
-			logReadTopologyInstanceError(instanceKey, "NewInstanceKeyFromStrings", err)
-		}
-		masterKey.Hostname, resolveErr = ResolveHostname(masterKey.Hostname)
-		if resolveErr != nil {
-			logReadTopologyInstanceError(instanceKey, fmt.Sprintf("ResolveHostname(%+v)", masterKey.Hostname), resolveErr)
+			goto Cleanup
 		}
-		instance.MasterKey = *masterKey
-		instance.IsDetachedMaster = instance.MasterKey.IsDetached()
-		instance.SecondsBehindMaster = m.GetNullInt64("Seconds_Behind_Master")
-		if instance.SecondsBehindMaster.Valid && instance.SecondsBehindMaster.Int64 < 0 {
-			log.Warningf("Host: %+v, instance.SecondsBehindMaster < 0 [%+v], correcting to 0", instanceKey, instance.SecondsBehindMaster.Int64)
-			instance.SecondsBehindMaster.Int64 = 0
+		channels := []ReplicationChannel{}
+		for _, m := range slaveStatusRows {
+			channel := ReplicationChannel{}
+			channel.Name = m.GetStringD("Channel_Name", m.GetStringD("Connection_name", ""))
+			channel.Slave_IO_Running = (m.GetString("Slave_IO_Running") == "Yes")
+			if isMaxScale110 {
+				// Covering buggy MaxScale 1.1.0
+				channel.Slave_IO_Running = channel.Slave_IO_Running && (m.GetString("Slave_IO_State") == "Binlog Dump")
+			}
+			channel.Slave_SQL_Running = (m.GetString("Slave_SQL_Running") == "Yes")
+			channel.UsingOracleGTID = (m.GetIntD("Auto_Position", 0) == 1)
+			channel.UsingMariaDBGTID = (m.GetStringD("Using_Gtid", "No") != "No")
+			channel.ReadBinlogCoordinates.LogFile = m.GetString("Master_Log_File")
+			channel.ReadBinlogCoordinates.LogPos = m.GetInt64("Read_Master_Log_Pos")
+			channel.ExecBinlogCoordinates.LogFile = m.GetString("Relay_Master_Log_File")
+			channel.ExecBinlogCoordinates.LogPos = m.GetInt64("Exec_Master_Log_Pos")
+			channel.RelaylogCoordinates.LogFile = m.GetString("Relay_Log_File")
+			channel.RelaylogCoordinates.LogPos = m.GetInt64("Relay_Log_Pos")
+			channel.RelaylogCoordinates.Type = RelayLog
+			channel.LastSQLError = m.GetString("Last_SQL_Error")
+			channel.LastIOError = m.GetString("Last_IO_Error")
+			channel.MasterSSL = readMasterSSLSettings(m)
+
+			masterHostname := m.GetString("Master_Host")
+			if isMaxScale110 {
+				// Buggy buggy maxscale 1.1.0. Reported Master_Host can be corrupted.
+				// Therefore we (currently) take @@hostname (which is masquarading as master host anyhow)
+				masterHostname = maxScaleMasterHostname
+			}
+			masterKey, err := NewInstanceKeyFromStrings(masterHostname, m.GetString("Master_Port"))
+			if err != nil {
+				logReadTopologyInstanceError(instanceKey, "NewInstanceKeyFromStrings", err)
+			}
+			masterKey.Hostname, resolveErr = ResolveHostname(masterKey.Hostname)
+			if resolveErr != nil {
+				logReadTopologyInstanceError(instanceKey, fmt.Sprintf("ResolveHostname(%+v)", masterKey.Hostname), resolveErr)
+			}
+			channel.MasterKey = *masterKey
+			channel.SecondsBehindMaster = m.GetNullInt64("Seconds_Behind_Master")
+			if channel.SecondsBehindMaster.Valid && channel.SecondsBehindMaster.Int64 < 0 {
+				log.Warningf("Host: %+v, instance.SecondsBehindMaster < 0 [%+v], correcting to 0", instanceKey, channel.SecondsBehindMaster.Int64)
+				channel.SecondsBehindMaster.Int64 = 0
+			}
+			channels = append(channels, channel)
+		}
+		if primary := primaryReplicationChannelIndex(channels); primary >= 0 {
+			// The instance is presented by its primary channel. The rest are only listed in ReplicationChannels
+			m := slaveStatusRows[primary]
+			instance.applyReplicationChannel(&channels[primary])
+			if len(channels) > 1 {
+				instance.ReplicationChannels = channels
+			}
+			instance.HasReplicationCredentials = (m.GetString("Master_User") != "")
+			instance.SQLDelay = m.GetUintD("SQL_Delay", 0)
+			instance.ExecutedGtidSet = m.GetStringD("Executed_Gtid_Set", "")
+			instance.HasReplicationFilters = ((m.GetStringD("Replicate_Do_DB", "") != "") || (m.GetStringD("Replicate_Ignore_DB", "") != "") || (m.GetStringD("Replicate_Do_Table", "") != "") || (m.GetStringD("Replicate_Ignore_Table", "") != "") || (m.GetStringD("Replicate_Wild_Do_Table", "") != "") || (m.GetStringD("Replicate_Wild_Ignore_Table", "") != ""))
+			// Not breaking the flow even on error
+			slaveStatusFound = true
 		}
-		// And until told otherwise:
-		instance.SlaveLagSeconds = instance.SecondsBehindMaster
-
-		instance.AllowTLS = (m.GetString("Master_SSL_Allowed") == "Yes")
-		// Not breaking the flow even on error
-		slaveStatusFound = true
-		return nil
-	})
-	if err != nil {
-		goto Cleanup
 	}
 	if isMaxScale && !slaveStatusFound {
 		err = fmt.Errorf("No 'SHOW SLAVE STATUS' output found for a MaxScale instance: %+v", instanceKey)
@@ -384,7 +424,7 @@ func ReadTopologyInstance(instanceKey *InstanceKey) (*Instance, error) {
 				  from
 				    information_schema.processlist
 				  where
-				    time > 60
+				    time > ?
 				    and command != 'Sleep'
 				    and id != connection_id()
 				    and user != 'system user'
@@ -408,7 +448,7 @@ func ReadTopologyInstance(instanceKey *InstanceKey) (*Instance, error) {
 
 				longRunningProcesses = append(longRunningProcesses, process)
 				return nil
-			})
+			}, longRunningProcessSeconds())
 
 		logReadTopologyInstanceError(instanceKey, "processlist, long queries", err)
 	}
@@ -460,6 +500,59 @@ func ReadTopologyInstance(instanceKey *InstanceKey) (*Instance, error) {
 		logReadTopologyInstanceError(instanceKey, "DetectSemiSyncEnforcedQuery", err)
 	}
 
+	if instance.IsOracleMySQL() && !instance.IsSmallerMajorVersionByString("5.7") && !isMaxScale {
+		groupReplicationActive := false
+		err := db.QueryRow("select count(*) > 0 from information_schema.plugins where plugin_name='group_replication' and plugin_status='ACTIVE'").Scan(&groupReplicationActive)
+		logReadTopologyInstanceError(instanceKey, "information_schema.plugins", err)
+		if groupReplicationActive {
+			err := db.QueryRow("select @@global.group_replication_group_name, @@global.group_replication_single_primary_mode").Scan(&instance.ReplicationGroupName, &instance.ReplicationGroupIsSinglePrimary)
+			logReadTopologyInstanceError(instanceKey, "group_replication_group_name", err)
+
+			members := []ReplicationGroupMember{}
+			hasMemberRoles := true
+			err = sqlutils.QueryRowsMap(db, "select * from performance_schema.replication_group_members", func(m sqlutils.RowMap) error {
+				member := ReplicationGroupMember{
+					UUID:  m.GetString("MEMBER_ID"),
+					State: m.GetString("MEMBER_STATE"),
+					Role:  m.GetStringD("MEMBER_ROLE", ""),
+				}
+				if _, ok := m["MEMBER_ROLE"]; !ok {
+					hasMemberRoles = false
+				}
+				member.Key.Hostname, resolveErr = ResolveHostname(m.GetString("MEMBER_HOST"))
+				logReadTopologyInstanceError(instanceKey, "ResolveHostname: replication_group_members", resolveErr)
+				// MEMBER_PORT is NULL up to 5.7.17; members are then assumed to listen on same port
+				member.Key.Port = m.GetIntD("MEMBER_PORT", instance.Key.Port)
+				members = append(members, member)
+				return nil
+			})
+			logReadTopologyInstanceError(instanceKey, "replication_group_members", err)
+
+			primaryMemberUUID := ""
+			if instance.ReplicationGroupIsSinglePrimary && !hasMemberRoles {
+				// 5.7 does not list member roles, but does tell the primary member
+				err := db.QueryRow("select variable_value from performance_schema.global_status where variable_name='group_replication_primary_member'").Scan(&primaryMemberUUID)
+				logReadTopologyInstanceError(instanceKey, "group_replication_primary_member", err)
+			}
+			instance.applyReplicationGroupMembers(members, primaryMemberUUID)
+			if instance.IsReplicationGroupMember() {
+				err := auditReplicationGroupPrimaryChange(instance)
+				logReadTopologyInstanceError(instanceKey, "auditReplicationGroupPrimaryChange", err)
+			}
+		}
+	}
+
+	if !isMaxScale {
+		// Galera nodes (Percona XtraDB Cluster, MariaDB Galera Cluster) report wsrep_* status
+		wsrepStatus := make(map[string]string)
+		err := sqlutils.QueryRowsMap(db, "show global status like 'wsrep_%'", func(m sqlutils.RowMap) error {
+			wsrepStatus[m.GetString("Variable_name")] = m.GetString("Value")
+			return nil
+		})
+		logReadTopologyInstanceError(instanceKey, "show global status like 'wsrep_%'", err)
+		instance.applyGaleraStatus(wsrepStatus)
+	}
+
 	{
 		err = ReadInstanceClusterAttributes(instance)
 		logReadTopologyInstanceError(instanceKey, "ReadInstanceClusterAttributes", err)
@@ -489,7 +582,7 @@ func ReadTopologyInstance(instanceKey *InstanceKey) (*Instance, error) {
 		}
 	}
 
-	if instance.ReplicationDepth == 0 && config.Config.DetectClusterAliasQuery != "" && !isMaxScale {
+	if instance.ReplicationDepth == 0 && !instance.IsReplicationGroupSecondary() && config.Config.DetectClusterAliasQuery != "" && !isMaxScale {
 		// Only need to do on masters
 		clusterAlias := ""
 		err := db.QueryRow(config.Config.DetectClusterAliasQuery).Scan(&clusterAlias)
@@ -499,7 +592,7 @@ func ReadTopologyInstance(instanceKey *InstanceKey) (*Instance, error) {
 		}
 		instance.SuggestedClusterAlias = clusterAlias
 	}
-	if instance.ReplicationDepth == 0 && config.Config.DetectClusterDomainQuery != "" && !isMaxScale {
+	if instance.ReplicationDepth == 0 && !instance.IsReplicationGroupSecondary() && config.Config.DetectClusterDomainQuery != "" && !isMaxScale {
 		// Only need to do on masters
 		domainName := ""
 		if err := db.QueryRow(config.Config.DetectClusterDomainQuery).Scan(&domainName); err != nil {
@@ -542,6 +635,32 @@ func ReadInstanceClusterAttributes(instance *Instance) (err error) {
 	var masterReplicationDepth uint
 	masterDataFound := false
 
+	// Replication group members and Galera nodes are peers of the member their group (hence cluster) is named by,
+	// unless replicating asynchronously from elsewhere
+	clusterMasterKey := instance.MasterKey
+	isClusterPeer := false
+	if instance.IsReplicationGroupMember() && !instance.IsSlave() {
+		var namingKey *InstanceKey
+		if !instance.ReplicationGroupIsSinglePrimary {
+			if namingKey, err = readReplicationGroupNamingKey(instance.ReplicationGroupName); err != nil {
+				return err
+			}
+		}
+		if groupClusterKey := instance.replicationGroupClusterKey(namingKey); groupClusterKey != nil && !groupClusterKey.Equals(&instance.Key) {
+			clusterMasterKey = *groupClusterKey
+			isClusterPeer = true
+		}
+	} else if instance.IsGaleraNode() && !instance.IsSlave() {
+		galeraClusterKey, err := readGaleraClusterKey(instance.GaleraClusterStateUUID)
+		if err != nil {
+			return err
+		}
+		if galeraClusterKey != nil && !galeraClusterKey.Equals(&instance.Key) {
+			clusterMasterKey = *galeraClusterKey
+			isClusterPeer = true
+		}
+	}
+
 	// Read the cluster_name of the _master_ of our instance, derive it from there.
 	query := `
 			select
@@ -552,7 +671,7 @@ func ReadInstanceClusterAttributes(instance *Instance) (err error) {
 				from database_instance
 				where hostname=? and port=?
 	`
-	args := sqlutils.Args(instance.MasterKey.Hostname, instance.MasterKey.Port)
+	args := sqlutils.Args(clusterMasterKey.Hostname, clusterMasterKey.Port)
 
 	err = db.QueryOrchestrator(query, args, func(m sqlutils.RowMap) error {
 		masterClusterName = m.GetString("cluster_name")
@@ -571,12 +690,21 @@ func ReadInstanceClusterAttributes(instance *Instance) (err error) {
 	if masterDataFound {
 		replicationDepth = masterReplicationDepth + 1
 		clusterName = masterClusterName
+		if isClusterPeer {
+			replicationDepth = masterReplicationDepth
+		}
 	}
 	clusterNameByInstanceKey := instance.Key.StringCode()
 	if clusterName == "" {
 		// Nothing from master; we set it to be named after the instance itself
 		clusterName = clusterNameByInstanceKey
 	}
+	if isClusterPeer {
+		instance.ClusterName = clusterName
+		instance.ReplicationDepth = replicationDepth
+		instance.IsCoMaster = false
+		return nil
+	}
 
 	isCoMaster := false
 	if masterMasterKey.Equals(&instance.Key) {
@@ -638,6 +766,7 @@ func readInstanceRow(m sqlutils.RowMap) *Instance {
 	instance.LogSlaveUpdatesEnabled = m.GetBool("log_slave_updates")
 	instance.MasterKey.Hostname = m.GetString("master_host")
 	instance.MasterKey.Port = m.GetInt("master_port")
+	instance.ReplicationChannel = m.GetString("replication_channel")
 	instance.IsDetachedMaster = instance.MasterKey.IsDetached()
 	instance.Slave_SQL_Running = m.GetBool("slave_sql_running")
 	instance.Slave_IO_Running = m.GetBool("slave_io_running")
@@ -669,6 +798,20 @@ func readInstanceRow(m sqlutils.RowMap) *Instance {
 	instance.DataCenter = m.GetString("data_center")
 	instance.PhysicalEnvironment = m.GetString("physical_environment")
 	instance.SemiSyncEnforced = m.GetBool("semi_sync_enforced")
+	instance.ReplicationGroupName = m.GetString("replication_group_name")
+	instance.ReplicationGroupIsSinglePrimary = m.GetBool("replication_group_is_single_primary_mode")
+	instance.ReplicationGroupMemberState = m.GetString("replication_group_member_state")
+	instance.ReplicationGroupMemberRole = m.GetString("replication_group_member_role")
+	instance.ReplicationGroupMembers, _ = replicationGroupMembersFromJSON(m.GetString("replication_group_members"))
+	instance.ReplicationGroupPrimaryKey.Hostname = m.GetString("replication_group_primary_host")
+	instance.ReplicationGroupPrimaryKey.Port = m.GetInt("replication_group_primary_port")
+	instance.GaleraClusterStateUUID = m.GetString("galera_cluster_state_uuid")
+	instance.GaleraClusterStatus = m.GetString("galera_cluster_status")
+	instance.GaleraLocalStateComment = m.GetString("galera_local_state_comment")
+	instance.GaleraClusterSize = m.GetUint("galera_cluster_size")
+	instance.GaleraFlowControlPaused, _ = strconv.ParseFloat(m.GetString("galera_flow_control_paused"), 64)
+	instance.GaleraFlowControlSent = uint64(m.GetInt64("galera_flow_control_sent"))
+	instance.GaleraFlowControlRecv = uint64(m.GetInt64("galera_flow_control_recv"))
 	instance.ReplicationDepth = m.GetUint("replication_depth")
 	instance.IsCoMaster = m.GetBool("is_co_master")
 	instance.ReplicationCredentialsAvailable = m.GetBool("replication_credentials_available")
@@ -686,6 +829,17 @@ func readInstanceRow(m sqlutils.RowMap) *Instance {
 	instance.DowntimeEndTimestamp = m.GetString("downtime_end_timestamp")
 	instance.UnresolvedHostname = m.GetString("unresolved_hostname")
 	instance.AllowTLS = m.GetBool("allow_tls")
+	instance.MasterSSL = MasterSSLSettings{
+		Allowed:          instance.AllowTLS,
+		CA:               m.GetString("master_ssl_ca"),
+		CAPath:           m.GetString("master_ssl_capath"),
+		Cert:             m.GetString("master_ssl_cert"),
+		Cipher:           m.GetString("master_ssl_cipher"),
+		Key:              m.GetString("master_ssl_key"),
+		CRL:              m.GetString("master_ssl_crl"),
+		CRLPath:          m.GetString("master_ssl_crlpath"),
+		VerifyServerCert: m.GetBool("master_ssl_verify_server_cert"),
+	}
 	instance.InstanceAlias = m.GetString("instance_alias")
 
 	instance.SlaveHosts.ReadJson(slaveHostsJSON)
@@ -728,9 +882,13 @@ func readInstancesByCondition(condition string, args []interface{}, sort string)
 			%s
 			`, condition, sort)
 
+		multiSourceInstances := [](*Instance){}
 		err := db.QueryOrchestrator(query, args, func(m sqlutils.RowMap) error {
 			instance := readInstanceRow(m)
 			instances = append(instances, instance)
+			if m.GetBool("is_multi_source") {
+				multiSourceInstances = append(multiSourceInstances, instance)
+			}
 			return nil
 		})
 		if err != nil {
@@ -740,6 +898,10 @@ func readInstancesByCondition(condition string, args []interface{}, sort string)
 		if err != nil {
 			return instances, log.Errore(err)
 		}
+		err = populateInstancesReplicationChannels(multiSourceInstances)
+		if err != nil {
+			return instances, log.Errore(err)
+		}
 		return instances, err
 	}
 	instanceReadChan <- true
@@ -795,13 +957,22 @@ func ReadClusterWriteableMaster(clusterName string) ([](*Instance), error) {
 }
 
 // ReadWriteableClustersMasters returns writeable masters of all clusters, but only one
-// per cluster, in similar logic to ReadClusterWriteableMaster
+// per cluster, in similar logic to ReadClusterWriteableMaster. Of active-active co-masters, the one
+// last checked successfully is preferred, then the one the cluster is named after, so that the choice is stable.
 func ReadWriteableClustersMasters() (instances [](*Instance), err error) {
 	condition := `
 		read_only = 0
 		and (replication_depth = 0 or is_co_master)
 	`
-	allMasters, err := readInstancesByCondition(condition, sqlutils.Args(), "cluster_name asc, replication_depth asc")
+	sort := `
+		cluster_name asc,
+		replication_depth asc,
+		is_last_check_valid desc,
+		cluster_name = concat(hostname, ':', port) desc,
+		hostname asc,
+		port asc
+	`
+	allMasters, err := readInstancesByCondition(condition, sqlutils.Args(), sort)
 	if err != nil {
 		return instances, err
 	}
@@ -879,6 +1050,10 @@ func ReadProblemInstances(clusterName string) ([](*Instance), error) {
 	if err != nil {
 		return instances, err
 	}
+	instances, err = appendAnalysisProblemInstances(clusterName, instances)
+	if err != nil {
+		return instances, err
+	}
 	var reportedInstances [](*Instance)
 	for _, instance := range instances {
 		skip := false
@@ -897,6 +1072,46 @@ func ReadProblemInstances(clusterName string) ([](*Instance), error) {
 	return reportedInstances, nil
 }
 
+// appendAnalysisProblemInstances notes custom and agent analysis on given problem instances, and appends any other
+// instances where custom analysis rules apply or where agents report host problems
+func appendAnalysisProblemInstances(clusterName string, instances [](*Instance)) ([](*Instance), error) {
+	if len(getCustomAnalysisRules()) == 0 && !config.Config.ServeAgentsHttp {
+		return instances, nil
+	}
+	analysisEntries, err := GetReplicationAnalysis(clusterName, true, false)
+	if err != nil {
+		return instances, err
+	}
+	analysisMap := make(map[InstanceKey]ReplicationAnalysis)
+	for _, analysisEntry := range analysisEntries {
+		if len(analysisEntry.CustomAnalysis) > 0 || len(analysisEntry.AgentAnalysis) > 0 {
+			analysisMap[analysisEntry.AnalyzedInstanceKey] = analysisEntry
+		}
+	}
+	for _, instance := range instances {
+		if analysisEntry, found := analysisMap[instance.Key]; found {
+			instance.CustomAnalysis = analysisEntry.CustomAnalysis
+			instance.AgentAnalysis = analysisEntry.AgentAnalysis
+			delete(analysisMap, instance.Key)
+		}
+	}
+	for _, analysisEntry := range analysisEntries {
+		if _, found := analysisMap[analysisEntry.AnalyzedInstanceKey]; !found {
+			continue
+		}
+		instance, found, err := ReadInstance(&analysisEntry.AnalyzedInstanceKey)
+		if err != nil {
+			return instances, err
+		}
+		if found {
+			instance.CustomAnalysis = analysisEntry.CustomAnalysis
+			instance.AgentAnalysis = analysisEntry.AgentAnalysis
+			instances = append(instances, instance)
+		}
+	}
+	return instances, nil
+}
+
 // SearchInstances reads all instances qualifying for some searchString
 func SearchInstances(searchString string) ([](*Instance), error) {
 	searchString = strings.TrimSpace(searchString)
@@ -1475,7 +1690,7 @@ func PopulateInstancesAgents(instances [](*Instance)) error {
 }
 
 func GetClusterName(instanceKey *InstanceKey) (clusterName string, err error) {
-	if clusterName, found := instanceKeyInformativeClusterName.Get(instanceKey.StringCode()); found {
+	if clusterName, found := getInstanceKeyInformativeClusterName().Get(instanceKey.StringCode()); found {
 		return clusterName.(string), nil
 	}
 	query := `
@@ -1489,7 +1704,7 @@ func GetClusterName(instanceKey *InstanceKey) (clusterName string, err error) {
 			`
 	err = db.QueryOrchestrator(query, sqlutils.Args(instanceKey.Hostname, instanceKey.Port), func(m sqlutils.RowMap) error {
 		clusterName = m.GetString("cluster_name")
-		instanceKeyInformativeClusterName.Set(instanceKey.StringCode(), clusterName, cache.DefaultExpiration)
+		getInstanceKeyInformativeClusterName().Set(instanceKey.StringCode(), clusterName, cache.DefaultExpiration)
 		return nil
 	})
 
@@ -1668,6 +1883,22 @@ func writeInstance(instance *Instance, instanceWasActuallyFound bool, lastError
 					binary_log_pos=VALUES(binary_log_pos),
 					master_host=VALUES(master_host),
 					master_port=VALUES(master_port),
+					replication_channel=VALUES(replication_channel),
+					is_multi_source=VALUES(is_multi_source),
+					replication_group_name=VALUES(replication_group_name),
+					replication_group_is_single_primary_mode=VALUES(replication_group_is_single_primary_mode),
+					replication_group_member_state=VALUES(replication_group_member_state),
+					replication_group_member_role=VALUES(replication_group_member_role),
+					replication_group_members=VALUES(replication_group_members),
+					replication_group_primary_host=VALUES(replication_group_primary_host),
+					replication_group_primary_port=VALUES(replication_group_primary_port),
+					galera_cluster_state_uuid=VALUES(galera_cluster_state_uuid),
+					galera_cluster_status=VALUES(galera_cluster_status),
+					galera_local_state_comment=VALUES(galera_local_state_comment),
+					galera_cluster_size=VALUES(galera_cluster_size),
+					galera_flow_control_paused=VALUES(galera_flow_control_paused),
+					galera_flow_control_sent=VALUES(galera_flow_control_sent),
+					galera_flow_control_recv=VALUES(galera_flow_control_recv),
 					slave_sql_running=VALUES(slave_sql_running),
 					slave_io_running=VALUES(slave_io_running),
 					has_replication_filters=VALUES(has_replication_filters),
@@ -1699,6 +1930,14 @@ func writeInstance(instance *Instance, instanceWasActuallyFound bool, lastError
 					replication_credentials_available=VALUES(replication_credentials_available),
 					has_replication_credentials=VALUES(has_replication_credentials),
 					allow_tls=VALUES(allow_tls),
+					master_ssl_ca=VALUES(master_ssl_ca),
+					master_ssl_capath=VALUES(master_ssl_capath),
+					master_ssl_cert=VALUES(master_ssl_cert),
+					master_ssl_cipher=VALUES(master_ssl_cipher),
+					master_ssl_key=VALUES(master_ssl_key),
+					master_ssl_crl=VALUES(master_ssl_crl),
+					master_ssl_crlpath=VALUES(master_ssl_crlpath),
+					master_ssl_verify_server_cert=VALUES(master_ssl_verify_server_cert),
 					semi_sync_enforced=VALUES(semi_sync_enforced),
 					instance_alias=VALUES(instance_alias)
 				`
@@ -1727,6 +1966,22 @@ func writeInstance(instance *Instance, instanceWasActuallyFound bool, lastError
 				binary_log_pos,
 				master_host,
 				master_port,
+				replication_channel,
+				is_multi_source,
+				replication_group_name,
+				replication_group_is_single_primary_mode,
+				replication_group_member_state,
+				replication_group_member_role,
+				replication_group_members,
+				replication_group_primary_host,
+				replication_group_primary_port,
+				galera_cluster_state_uuid,
+				galera_cluster_status,
+				galera_local_state_comment,
+				galera_cluster_size,
+				galera_flow_control_paused,
+				galera_flow_control_sent,
+				galera_flow_control_recv,
 				slave_sql_running,
 				slave_io_running,
 				has_replication_filters,
@@ -1758,9 +2013,17 @@ func writeInstance(instance *Instance, instanceWasActuallyFound bool, lastError
 				replication_credentials_available,
 				has_replication_credentials,
 				allow_tls,
+				master_ssl_ca,
+				master_ssl_capath,
+				master_ssl_cert,
+				master_ssl_cipher,
+				master_ssl_key,
+				master_ssl_crl,
+				master_ssl_crlpath,
+				master_ssl_verify_server_cert,
 				semi_sync_enforced,
 				instance_alias
-			) values (?, ?, NOW(), NOW(), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			) values (?, ?, NOW(), NOW(), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 			%s
 			`, insertIgnore, onDuplicateKeyUpdate)
 
@@ -1780,6 +2043,22 @@ func writeInstance(instance *Instance, instanceWasActuallyFound bool, lastError
 			instance.SelfBinlogCoordinates.LogPos,
 			instance.MasterKey.Hostname,
 			instance.MasterKey.Port,
+			instance.ReplicationChannel,
+			instance.IsMultiSource(),
+			instance.ReplicationGroupName,
+			instance.ReplicationGroupIsSinglePrimary,
+			instance.ReplicationGroupMemberState,
+			instance.ReplicationGroupMemberRole,
+			replicationGroupMembersToJSON(instance.ReplicationGroupMembers),
+			instance.ReplicationGroupPrimaryKey.Hostname,
+			instance.ReplicationGroupPrimaryKey.Port,
+			instance.GaleraClusterStateUUID,
+			instance.GaleraClusterStatus,
+			instance.GaleraLocalStateComment,
+			instance.GaleraClusterSize,
+			instance.GaleraFlowControlPaused,
+			instance.GaleraFlowControlSent,
+			instance.GaleraFlowControlRecv,
 			instance.Slave_SQL_Running,
 			instance.Slave_IO_Running,
 			instance.HasReplicationFilters,
@@ -1811,6 +2090,14 @@ func writeInstance(instance *Instance, instanceWasActuallyFound bool, lastError
 			instance.ReplicationCredentialsAvailable,
 			instance.HasReplicationCredentials,
 			instance.AllowTLS,
+			instance.MasterSSL.CA,
+			instance.MasterSSL.CAPath,
+			instance.MasterSSL.Cert,
+			instance.MasterSSL.Cipher,
+			instance.MasterSSL.Key,
+			instance.MasterSSL.CRL,
+			instance.MasterSSL.CRLPath,
+			instance.MasterSSL.VerifyServerCert,
 			instance.SemiSyncEnforced,
 			instance.InstanceAlias,
 		)
@@ -1823,6 +2110,9 @@ func writeInstance(instance *Instance, instanceWasActuallyFound bool, lastError
         		update database_instance set last_seen = NOW() where hostname=? and port=?
         	`, instance.Key.Hostname, instance.Key.Port,
 			)
+			if err := writeInstanceReplicationChannels(instance); err != nil {
+				return err
+			}
 		} else {
 			log.Debugf("writeInstance: will not update database_instance due to error: %+v", lastError)
 		}
@@ -1897,6 +2187,14 @@ func ForgetInstance(instanceKey *InstanceKey) error {
 		instanceKey.Hostname,
 		instanceKey.Port,
 	)
+	db.ExecOrchestrator(`
+			delete
+				from database_instance_replication_channel
+			where
+				hostname = ? and port = ?`,
+		instanceKey.Hostname,
+		instanceKey.Port,
+	)
 	AuditOperation("forget", instanceKey, "")
 	return err
 }
@@ -1927,12 +2225,23 @@ func SnapshotTopologies() error {
 		_, err := db.ExecOrchestrator(`
         	insert ignore into
         		database_instance_topology_history (snapshot_unix_timestamp,
-        			hostname, port, master_host, master_port, cluster_name, version)
+        			hostname, port, master_host, master_port, cluster_name, version,
+        			binary_log_file, binary_log_pos, relay_master_log_file, exec_master_log_pos,
+        			seconds_behind_master, slave_lag_seconds, read_only, oracle_gtid, mariadb_gtid,
+        			data_center, is_downtimed)
         	select
         		UNIX_TIMESTAMP(NOW()),
-        		hostname, port, master_host, master_port, cluster_name, version
+        		hostname, port, master_host, master_port, cluster_name, version,
+        		binary_log_file, binary_log_pos, relay_master_log_file, exec_master_log_pos,
+        		seconds_behind_master, slave_lag_seconds, read_only, oracle_gtid, mariadb_gtid,
+        		data_center,
+        		(
+        			database_instance_downtime.downtime_active IS NULL
+        			or database_instance_downtime.end_timestamp < NOW()
+        		) is false
 			from
 				database_instance
+				left join database_instance_downtime using (hostname, port)
 				`,
 		)
 		if err != nil {
@@ -1960,15 +2269,7 @@ func ReadHistoryClusterInstances(clusterName string, historyTimestampPattern str
 			hostname, port`
 
 	err := db.QueryOrchestrator(query, sqlutils.Args(historyTimestampPattern, clusterName), func(m sqlutils.RowMap) error {
-		instance := NewInstance()
-
-		instance.Key.Hostname = m.GetString("hostname")
-		instance.Key.Port = m.GetInt("port")
-		instance.MasterKey.Hostname = m.GetString("master_host")
-		instance.MasterKey.Port = m.GetInt("master_port")
-		instance.ClusterName = m.GetString("cluster_name")
-
-		instances = append(instances, instance)
+		instances = append(instances, readTopologyHistoryRow(m))
 		return nil
 	})
 	if err != nil {
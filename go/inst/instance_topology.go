@@ -40,7 +40,7 @@ func getASCIITopologyEntry(depth int, instance *Instance, replicationMap map[*In
 	prefix := ""
 	if depth > 0 {
 		prefix = strings.Repeat(" ", (depth-1)*2)
-		if instance.SlaveRunning() && instance.IsLastCheckValid && instance.IsRecentlyChecked {
+		if (instance.SlaveRunning() || instance.ReplicationGroupMemberState == ReplicationGroupMemberStateOnline || instance.IsGaleraSynced()) && instance.IsLastCheckValid && instance.IsRecentlyChecked {
 			prefix += "+ "
 		} else {
 			prefix += "- "
@@ -50,6 +50,20 @@ func getASCIITopologyEntry(depth int, instance *Instance, replicationMap map[*In
 	if extendedOutput {
 		entry = fmt.Sprintf("%s %s", entry, instance.HumanReadableDescription())
 	}
+	if instance.IsMultiSource() {
+		// A multi-source slave is listed under the master of its primary channel; other upstreams are noted
+		upstreams := []string{}
+		for _, channel := range instance.AdditionalReplicationChannels() {
+			upstreams = append(upstreams, fmt.Sprintf("%s (channel '%s')", channel.MasterKey.DisplayString(), channel.Name))
+		}
+		entry = fmt.Sprintf("%s << also from: %s", entry, strings.Join(upstreams, ", "))
+	}
+	if instance.IsReplicationGroupMember() {
+		entry = fmt.Sprintf("%s << group member: %s, %s", entry, instance.ReplicationGroupMemberRole, instance.ReplicationGroupMemberState)
+	}
+	if instance.IsGaleraNode() {
+		entry = fmt.Sprintf("%s << galera node: %s, %s", entry, instance.GaleraClusterStatus, instance.GaleraLocalStateComment)
+	}
 	result := []string{entry}
 	for _, slave := range replicationMap[instance] {
 		slavesResult := getASCIITopologyEntry(depth+1, slave, replicationMap, extendedOutput)
@@ -58,38 +72,72 @@ func getASCIITopologyEntry(depth int, instance *Instance, replicationMap map[*In
 	return result
 }
 
-// ASCIITopology returns a string representation of the topology of given cluster.
-func ASCIITopology(clusterName string, historyTimestampPattern string) (result string, err error) {
-	var instances [](*Instance)
+// readTopologyInstances reads the instances of given cluster, either current or historical
+func readTopologyInstances(clusterName string, historyTimestampPattern string) (instances [](*Instance), err error) {
 	if historyTimestampPattern == "" {
-		instances, err = ReadClusterInstances(clusterName)
-	} else {
-		instances, err = ReadHistoryClusterInstances(clusterName, historyTimestampPattern)
-	}
-	if err != nil {
-		return "", err
+		return ReadClusterInstances(clusterName)
 	}
+	return ReadHistoryClusterInstances(clusterName, historyTimestampPattern)
+}
 
+// getReplicationMap maps each instance to its slaves within given list of instances. Replication group members and
+// Galera nodes are mapped under the member their cluster is named by.
+// It also returns the instances whose master is not in the list (normally the single master of the topology)
+func getReplicationMap(instances [](*Instance)) (replicationMap map[*Instance]([]*Instance), masterInstances [](*Instance)) {
 	instancesMap := make(map[InstanceKey](*Instance))
+	galeraClusterKeys := make(map[string]InstanceKey)
+	clusterNamingKeys := make(map[string]*InstanceKey)
 	for _, instance := range instances {
 		log.Debugf("instanceKey: %+v", instance.Key)
 		instancesMap[instance.Key] = instance
+		if instance.Key.StringCode() == instance.ClusterName {
+			clusterNamingKeys[instance.ClusterName] = &instance.Key
+		}
+		if instance.IsGaleraNode() && !instance.IsSlave() {
+			if galeraClusterKey, found := galeraClusterKeys[instance.GaleraClusterStateUUID]; !found || instance.Key.SmallerThan(&galeraClusterKey) {
+				galeraClusterKeys[instance.GaleraClusterStateUUID] = instance.Key
+			}
+		}
 	}
 
-	replicationMap := make(map[*Instance]([]*Instance))
-	var masterInstance *Instance
+	replicationMap = make(map[*Instance]([]*Instance))
 	// Investigate slaves:
 	for _, instance := range instances {
 		master, ok := instancesMap[instance.MasterKey]
+		if !ok && instance.IsReplicationGroupMember() && !instance.IsSlave() {
+			if groupClusterKey := instance.replicationGroupClusterKey(clusterNamingKeys[instance.ClusterName]); groupClusterKey != nil && !groupClusterKey.Equals(&instance.Key) {
+				master, ok = instancesMap[*groupClusterKey]
+			}
+		}
+		if !ok && instance.IsGaleraNode() && !instance.IsSlave() {
+			if galeraClusterKey := galeraClusterKeys[instance.GaleraClusterStateUUID]; !galeraClusterKey.Equals(&instance.Key) {
+				master, ok = instancesMap[galeraClusterKey]
+			}
+		}
 		if ok {
 			if _, ok := replicationMap[master]; !ok {
 				replicationMap[master] = [](*Instance){}
 			}
 			replicationMap[master] = append(replicationMap[master], instance)
 		} else {
-			masterInstance = instance
+			masterInstances = append(masterInstances, instance)
 		}
 	}
+	return replicationMap, masterInstances
+}
+
+// ASCIITopology returns a string representation of the topology of given cluster.
+func ASCIITopology(clusterName string, historyTimestampPattern string) (result string, err error) {
+	instances, err := readTopologyInstances(clusterName, historyTimestampPattern)
+	if err != nil {
+		return "", err
+	}
+
+	replicationMap, masterInstances := getReplicationMap(instances)
+	var masterInstance *Instance
+	if len(masterInstances) > 0 {
+		masterInstance = masterInstances[len(masterInstances)-1]
+	}
 	// Get entries:
 	var entries []string
 	if masterInstance != nil {
@@ -158,12 +206,21 @@ func InstanceIsMasterOf(allegedMaster, allegedSlave *Instance) bool {
 }
 
 // MoveEquivalent will attempt moving instance indicated by instanceKey below another instance,
-// based on known master coordinates equivalence
+// based on known master coordinates equivalence. On a multi-source instance, the replication channel
+// the instance is presented by is moved.
 func MoveEquivalent(instanceKey, otherKey *InstanceKey) (*Instance, error) {
 	instance, found, err := ReadInstance(instanceKey)
 	if err != nil || !found {
 		return instance, err
 	}
+	return moveEquivalent(instance, otherKey)
+}
+
+// moveEquivalent moves given instance, by the replication channel it is presented by, below another instance,
+// based on known master coordinates equivalence
+func moveEquivalent(instance *Instance, otherKey *InstanceKey) (*Instance, error) {
+	instanceKey := &instance.Key
+	channel := instance.ReplicationChannel
 	if instance.Key.Equals(otherKey) {
 		return instance, fmt.Errorf("MoveEquivalent: attempt to move an instance below itself %+v", instance.Key)
 	}
@@ -185,7 +242,7 @@ func MoveEquivalent(instanceKey, otherKey *InstanceKey) (*Instance, error) {
 	// Now if we DO get to happen on equivalent coordinates, we need to double check. For CHANGE MASTER to happen we must
 	// stop the slave anyhow. But then let's verify the position hasn't changed.
 	knownExecBinlogCoordinates := instance.ExecBinlogCoordinates
-	instance, err = StopSlave(instanceKey)
+	instance, err = StopSlaveChannel(instanceKey, channel)
 	if err != nil {
 		goto Cleanup
 	}
@@ -194,13 +251,13 @@ func MoveEquivalent(instanceKey, otherKey *InstanceKey) (*Instance, error) {
 		err = fmt.Errorf("MoveEquivalent(): ExecBinlogCoordinates changed after stopping replication on %+v; aborting", instance.Key)
 		goto Cleanup
 	}
-	instance, err = ChangeMasterTo(instanceKey, otherKey, binlogCoordinates, false, GTIDHintNeutral)
+	instance, err = ChangeMasterToChannel(instanceKey, channel, otherKey, binlogCoordinates, false, GTIDHintNeutral)
 
 Cleanup:
-	instance, _ = StartSlave(instanceKey)
+	instance, _ = StartSlaveChannel(instanceKey, channel)
 
 	if err == nil {
-		message := fmt.Sprintf("moved %+v via equivalence coordinates below %+v", *instanceKey, *otherKey)
+		message := fmt.Sprintf("moved %+v via equivalence coordinates below %+v%s", *instanceKey, *otherKey, replicationChannelAuditSuffix(instance, channel))
 		log.Debugf(message)
 		AuditOperation("move-equivalent", instanceKey, message)
 	}
@@ -209,12 +266,22 @@ Cleanup:
 
 // MoveUp will attempt moving instance indicated by instanceKey up the topology hierarchy.
 // It will perform all safety and sanity checks and will tamper with this instance's replication
-// as well as its master.
+// as well as its master. On a multi-source instance, the replication channel the instance is presented by is moved.
 func MoveUp(instanceKey *InstanceKey) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
 		return instance, err
 	}
+	return MoveUpChannel(instanceKey, instance.ReplicationChannel)
+}
+
+// MoveUpChannel moves a given replication channel of an instance up the topology hierarchy, such that it
+// replicates from its master's master. Other channels of a multi-source slave keep on replicating.
+func MoveUpChannel(instanceKey *InstanceKey, channel string) (*Instance, error) {
+	instance, err := readTopologyInstanceChannel(instanceKey, channel)
+	if err != nil {
+		return instance, err
+	}
 	if !instance.IsSlave() {
 		return instance, fmt.Errorf("instance is not a slave: %+v", instanceKey)
 	}
@@ -236,10 +303,11 @@ func MoveUp(instanceKey *InstanceKey) (*Instance, error) {
 	}
 	if master.IsBinlogServer() {
 		// Quick solution via binlog servers
-		return Repoint(instanceKey, &master.MasterKey, GTIDHintDeny)
+		return RepointChannel(instanceKey, channel, &master.MasterKey, GTIDHintDeny)
 	}
 
-	log.Infof("Will move %+v up the topology", *instanceKey)
+	log.Infof("Will move %+v, channel: '%s', up the topology", *instanceKey, channel)
+	masterChannel := master.ReplicationChannel
 
 	if maintenanceToken, merr := BeginMaintenance(instanceKey, GetMaintenanceOwner(), "move up"); merr != nil {
 		err = fmt.Errorf("Cannot begin maintenance on %+v", *instanceKey)
@@ -255,40 +323,40 @@ func MoveUp(instanceKey *InstanceKey) (*Instance, error) {
 	}
 
 	if !instance.UsingMariaDBGTID {
-		master, err = StopSlave(&master.Key)
+		master, err = StopSlaveChannel(&master.Key, masterChannel)
 		if err != nil {
 			goto Cleanup
 		}
 	}
 
-	instance, err = StopSlave(instanceKey)
+	instance, err = StopSlaveChannel(instanceKey, channel)
 	if err != nil {
 		goto Cleanup
 	}
 
 	if !instance.UsingMariaDBGTID {
-		instance, err = StartSlaveUntilMasterCoordinates(instanceKey, &master.SelfBinlogCoordinates)
+		instance, err = StartSlaveUntilMasterCoordinatesChannel(instanceKey, channel, &master.SelfBinlogCoordinates)
 		if err != nil {
 			goto Cleanup
 		}
 	}
 
 	// We can skip hostname unresolve; we just copy+paste whatever our master thinks of its master.
-	instance, err = ChangeMasterTo(instanceKey, &master.MasterKey, &master.ExecBinlogCoordinates, true, GTIDHintDeny)
+	instance, err = ChangeMasterToChannel(instanceKey, channel, &master.MasterKey, &master.ExecBinlogCoordinates, true, GTIDHintDeny)
 	if err != nil {
 		goto Cleanup
 	}
 
 Cleanup:
-	instance, _ = StartSlave(instanceKey)
+	instance, _ = StartSlaveChannel(instanceKey, channel)
 	if !instance.UsingMariaDBGTID {
-		master, _ = StartSlave(&master.Key)
+		master, _ = StartSlaveChannel(&master.Key, masterChannel)
 	}
 	if err != nil {
 		return instance, log.Errore(err)
 	}
 	// and we're done (pending deferred functions)
-	AuditOperation("move-up", instanceKey, fmt.Sprintf("moved up %+v. Previous master: %+v", *instanceKey, master.Key))
+	AuditOperation("move-up", instanceKey, fmt.Sprintf("moved up %+v%s. Previous master: %+v", *instanceKey, replicationChannelAuditSuffix(instance, channel), master.Key))
 
 	return instance, err
 }
@@ -297,6 +365,17 @@ Cleanup:
 // Clock-time, this is fater than moving one at a time. However this means all slaves of the given instance, and the instance itself,
 // will all stop replicating together.
 func MoveUpSlaves(instanceKey *InstanceKey, pattern string) ([](*Instance), *Instance, error, []error) {
+	lockToken, err := BeginClusterLockByInstanceKey(instanceKey, GetMaintenanceOwner(), "move-up-slaves")
+	if err != nil {
+		return nil, nil, err, nil
+	}
+	defer EndClusterLock(lockToken)
+
+	return moveUpSlaves(instanceKey, pattern)
+}
+
+// moveUpSlaves implements MoveUpSlaves. Caller is expected to hold the cluster lock.
+func moveUpSlaves(instanceKey *InstanceKey, pattern string) ([](*Instance), *Instance, error, []error) {
 	res := [](*Instance){}
 	errs := []error{}
 	slaveMutex := make(chan bool, 1)
@@ -315,7 +394,7 @@ func MoveUpSlaves(instanceKey *InstanceKey, pattern string) ([](*Instance), *Ins
 	}
 
 	if instance.IsBinlogServer() {
-		slaves, err, errors := RepointSlavesTo(instanceKey, pattern, &instance.MasterKey)
+		slaves, err, errors := repointSlavesTo(instanceKey, pattern, &instance.MasterKey)
 		// Bail out!
 		return slaves, instance, err, errors
 	}
@@ -424,21 +503,32 @@ Cleanup:
 
 // MoveBelow will attempt moving instance indicated by instanceKey below its supposed sibling indicated by sinblingKey.
 // It will perform all safety and sanity checks and will tamper with this instance's replication
-// as well as its sibling.
+// as well as its sibling. On a multi-source instance, the replication channel the instance is presented by is moved.
 func MoveBelow(instanceKey, siblingKey *InstanceKey) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
 		return instance, err
 	}
-	sibling, err := ReadTopologyInstance(siblingKey)
+	return MoveBelowChannel(instanceKey, instance.ReplicationChannel, siblingKey)
+}
+
+// MoveBelowChannel moves a given replication channel of an instance below its sibling: the sibling replicating
+// from the channel's master, by whichever of its own channels. Other channels of both keep on replicating.
+func MoveBelowChannel(instanceKey *InstanceKey, channel string, siblingKey *InstanceKey) (*Instance, error) {
+	instance, err := readTopologyInstanceChannel(instanceKey, channel)
 	if err != nil {
 		return instance, err
 	}
+	sibling, err := readTopologyInstanceByMaster(siblingKey, &instance.MasterKey)
+	if err != nil {
+		return instance, err
+	}
+	siblingChannel := sibling.ReplicationChannel
 
 	if sibling.IsBinlogServer() {
 		// Binlog server has same coordinates as master
 		// Easy solution!
-		return Repoint(instanceKey, &sibling.Key, GTIDHintDeny)
+		return RepointChannel(instanceKey, channel, &sibling.Key, GTIDHintDeny)
 	}
 
 	rinstance, _, _ := ReadInstance(&instance.Key)
@@ -457,7 +547,7 @@ func MoveBelow(instanceKey, siblingKey *InstanceKey) (*Instance, error) {
 	if canReplicate, err := instance.CanReplicateFrom(sibling); !canReplicate {
 		return instance, err
 	}
-	log.Infof("Will move %+v below %+v", instanceKey, siblingKey)
+	log.Infof("Will move %+v, channel: '%s', below %+v", instanceKey, channel, siblingKey)
 
 	if maintenanceToken, merr := BeginMaintenance(instanceKey, GetMaintenanceOwner(), fmt.Sprintf("move below %+v", *siblingKey)); merr != nil {
 		err = fmt.Errorf("Cannot begin maintenance on %+v", *instanceKey)
@@ -472,42 +562,42 @@ func MoveBelow(instanceKey, siblingKey *InstanceKey) (*Instance, error) {
 		defer EndMaintenance(maintenanceToken)
 	}
 
-	instance, err = StopSlave(instanceKey)
+	instance, err = StopSlaveChannel(instanceKey, channel)
 	if err != nil {
 		goto Cleanup
 	}
 
-	sibling, err = StopSlave(siblingKey)
+	sibling, err = StopSlaveChannel(siblingKey, siblingChannel)
 	if err != nil {
 		goto Cleanup
 	}
 	if instance.ExecBinlogCoordinates.SmallerThan(&sibling.ExecBinlogCoordinates) {
-		instance, err = StartSlaveUntilMasterCoordinates(instanceKey, &sibling.ExecBinlogCoordinates)
+		instance, err = StartSlaveUntilMasterCoordinatesChannel(instanceKey, channel, &sibling.ExecBinlogCoordinates)
 		if err != nil {
 			goto Cleanup
 		}
 	} else if sibling.ExecBinlogCoordinates.SmallerThan(&instance.ExecBinlogCoordinates) {
-		sibling, err = StartSlaveUntilMasterCoordinates(siblingKey, &instance.ExecBinlogCoordinates)
+		sibling, err = StartSlaveUntilMasterCoordinatesChannel(siblingKey, siblingChannel, &instance.ExecBinlogCoordinates)
 		if err != nil {
 			goto Cleanup
 		}
 	}
 	// At this point both siblings have executed exact same statements and are identical
 
-	instance, err = ChangeMasterTo(instanceKey, &sibling.Key, &sibling.SelfBinlogCoordinates, false, GTIDHintDeny)
+	instance, err = ChangeMasterToChannel(instanceKey, channel, &sibling.Key, &sibling.SelfBinlogCoordinates, false, GTIDHintDeny)
 	if err != nil {
 		goto Cleanup
 	}
 
 Cleanup:
-	instance, _ = StartSlave(instanceKey)
-	sibling, _ = StartSlave(siblingKey)
+	instance, _ = StartSlaveChannel(instanceKey, channel)
+	sibling, _ = StartSlaveChannel(siblingKey, siblingChannel)
 
 	if err != nil {
 		return instance, log.Errore(err)
 	}
 	// and we're done (pending deferred functions)
-	AuditOperation("move-below", instanceKey, fmt.Sprintf("moved %+v below %+v", *instanceKey, *siblingKey))
+	AuditOperation("move-below", instanceKey, fmt.Sprintf("moved %+v below %+v%s", *instanceKey, *siblingKey, replicationChannelAuditSuffix(instance, channel)))
 
 	return instance, err
 }
@@ -520,10 +610,12 @@ func canMoveViaGTID(instance, otherInstance *Instance) (isOracleGTID bool, isMar
 }
 
 // moveInstanceBelowViaGTID will attempt moving given instance below another instance using either Oracle GTID or MariaDB GTID.
+// On a multi-source instance, the replication channel the instance is presented by is moved.
 func moveInstanceBelowViaGTID(instance, otherInstance *Instance) (*Instance, error) {
 	_, _, canMove := canMoveViaGTID(instance, otherInstance)
 
 	instanceKey := &instance.Key
+	channel := instance.ReplicationChannel
 	otherInstanceKey := &otherInstance.Key
 	if !canMove {
 		return instance, fmt.Errorf("Cannot move via GTID as not both instances use GTID: %+v, %+v", *instanceKey, *otherInstanceKey)
@@ -548,32 +640,43 @@ func moveInstanceBelowViaGTID(instance, otherInstance *Instance) (*Instance, err
 		defer EndMaintenance(maintenanceToken)
 	}
 
-	instance, err = StopSlave(instanceKey)
+	instance, err = StopSlaveChannel(instanceKey, channel)
 	if err != nil {
 		goto Cleanup
 	}
 
-	instance, err = ChangeMasterTo(instanceKey, &otherInstance.Key, &otherInstance.SelfBinlogCoordinates, false, GTIDHintForce)
+	instance, err = ChangeMasterToChannel(instanceKey, channel, &otherInstance.Key, &otherInstance.SelfBinlogCoordinates, false, GTIDHintForce)
 	if err != nil {
 		goto Cleanup
 	}
 Cleanup:
-	instance, _ = StartSlave(instanceKey)
+	instance, _ = StartSlaveChannel(instanceKey, channel)
 	if err != nil {
 		return instance, log.Errore(err)
 	}
 	// and we're done (pending deferred functions)
-	AuditOperation("move-below-gtid", instanceKey, fmt.Sprintf("moved %+v below %+v", *instanceKey, *otherInstanceKey))
+	AuditOperation("move-below-gtid", instanceKey, fmt.Sprintf("moved %+v below %+v%s", *instanceKey, *otherInstanceKey, replicationChannelAuditSuffix(instance, channel)))
 
 	return instance, err
 }
 
 // MoveBelowGTID will attempt moving instance indicated by instanceKey below another instance using either Oracle GTID or MariaDB GTID.
+// On a multi-source instance, the replication channel the instance is presented by is moved.
 func MoveBelowGTID(instanceKey, otherKey *InstanceKey) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
 		return instance, err
 	}
+	return MoveBelowGTIDChannel(instanceKey, instance.ReplicationChannel, otherKey)
+}
+
+// MoveBelowGTIDChannel moves a given replication channel of an instance below another instance using either
+// Oracle GTID or MariaDB GTID. Other channels of a multi-source slave keep on replicating.
+func MoveBelowGTIDChannel(instanceKey *InstanceKey, channel string, otherKey *InstanceKey) (*Instance, error) {
+	instance, err := readTopologyInstanceChannel(instanceKey, channel)
+	if err != nil {
+		return instance, err
+	}
 	other, err := ReadTopologyInstance(otherKey)
 	if err != nil {
 		return instance, err
@@ -635,6 +738,17 @@ func moveSlavesViaGTID(slaves [](*Instance), other *Instance) (movedSlaves [](*I
 
 // MoveSlavesGTID will (attempt to) move all slaves of given master below given instance.
 func MoveSlavesGTID(masterKey *InstanceKey, belowKey *InstanceKey, pattern string) (movedSlaves [](*Instance), unmovedSlaves [](*Instance), err error, errs []error) {
+	lockToken, err := BeginClusterLockByInstanceKey(masterKey, GetMaintenanceOwner(), "move-slaves-gtid")
+	if err != nil {
+		return nil, nil, err, nil
+	}
+	defer EndClusterLock(lockToken)
+
+	return moveSlavesGTID(masterKey, belowKey, pattern)
+}
+
+// moveSlavesGTID implements MoveSlavesGTID. Caller is expected to hold the cluster lock.
+func moveSlavesGTID(masterKey *InstanceKey, belowKey *InstanceKey, pattern string) (movedSlaves [](*Instance), unmovedSlaves [](*Instance), err error, errs []error) {
 	belowInstance, err := ReadTopologyInstance(belowKey)
 	if err != nil {
 		// Can't access "below" ==> can't move slaves beneath it
@@ -664,11 +778,22 @@ func MoveSlavesGTID(masterKey *InstanceKey, belowKey *InstanceKey, pattern strin
 // Two use cases:
 // - masterKey is nil: use case is corrupted relay logs on slave
 // - masterKey is not nil: using Binlog servers (coordinates remain the same)
+// On a multi-source instance, the replication channel the instance is presented by is repointed.
 func Repoint(instanceKey *InstanceKey, masterKey *InstanceKey, gtidHint OperationGTIDHint) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
 		return instance, err
 	}
+	return RepointChannel(instanceKey, instance.ReplicationChannel, masterKey, gtidHint)
+}
+
+// RepointChannel connects a given replication channel of a slave to a master, using the channel's exact same
+// executing coordinates (or GTID, see gtidHint). Other channels of a multi-source slave keep on replicating.
+func RepointChannel(instanceKey *InstanceKey, channel string, masterKey *InstanceKey, gtidHint OperationGTIDHint) (*Instance, error) {
+	instance, err := readTopologyInstanceChannel(instanceKey, channel)
+	if err != nil {
+		return instance, err
+	}
 	if !instance.IsSlave() {
 		return instance, fmt.Errorf("instance is not a slave: %+v", *instanceKey)
 	}
@@ -699,7 +824,7 @@ func Repoint(instanceKey *InstanceKey, masterKey *InstanceKey, gtidHint Operatio
 		}
 	}
 
-	log.Infof("Will repoint %+v to master %+v", *instanceKey, *masterKey)
+	log.Infof("Will repoint %+v, channel: '%s', to master %+v", *instanceKey, channel, *masterKey)
 
 	if maintenanceToken, merr := BeginMaintenance(instanceKey, GetMaintenanceOwner(), "repoint"); merr != nil {
 		err = fmt.Errorf("Cannot begin maintenance on %+v", *instanceKey)
@@ -708,7 +833,7 @@ func Repoint(instanceKey *InstanceKey, masterKey *InstanceKey, gtidHint Operatio
 		defer EndMaintenance(maintenanceToken)
 	}
 
-	instance, err = StopSlave(instanceKey)
+	instance, err = StopSlaveChannel(instanceKey, channel)
 	if err != nil {
 		goto Cleanup
 	}
@@ -719,18 +844,22 @@ func Repoint(instanceKey *InstanceKey, masterKey *InstanceKey, gtidHint Operatio
 	if instance.ExecBinlogCoordinates.IsEmpty() {
 		instance.ExecBinlogCoordinates.LogFile = "orchestrator-unknown-log-file"
 	}
-	instance, err = ChangeMasterTo(instanceKey, masterKey, &instance.ExecBinlogCoordinates, !masterIsAccessible, gtidHint)
+	instance, err = ChangeMasterToChannel(instanceKey, channel, masterKey, &instance.ExecBinlogCoordinates, !masterIsAccessible, gtidHint)
 	if err != nil {
 		goto Cleanup
 	}
 
 Cleanup:
-	instance, _ = StartSlave(instanceKey)
+	instance, _ = StartSlaveChannel(instanceKey, channel)
 	if err != nil {
 		return instance, log.Errore(err)
 	}
 	// and we're done (pending deferred functions)
-	AuditOperation("repoint", instanceKey, fmt.Sprintf("slave %+v repointed to master: %+v", *instanceKey, *masterKey))
+	if instance.IsMultiSource() {
+		AuditOperation("repoint", instanceKey, fmt.Sprintf("slave %+v repointed channel '%s' to master: %+v", *instanceKey, channel, *masterKey))
+	} else {
+		AuditOperation("repoint", instanceKey, fmt.Sprintf("slave %+v repointed to master: %+v", *instanceKey, *masterKey))
+	}
 
 	return instance, err
 
@@ -792,6 +921,17 @@ func RepointTo(slaves [](*Instance), belowKey *InstanceKey) ([](*Instance), erro
 // RepointSlavesTo repoints slaves of a given instance (possibly filtered) onto another master.
 // Binlog Server is the major use case
 func RepointSlavesTo(instanceKey *InstanceKey, pattern string, belowKey *InstanceKey) ([](*Instance), error, []error) {
+	lockToken, err := BeginClusterLockByInstanceKey(instanceKey, GetMaintenanceOwner(), "repoint-slaves")
+	if err != nil {
+		return nil, err, nil
+	}
+	defer EndClusterLock(lockToken)
+
+	return repointSlavesTo(instanceKey, pattern, belowKey)
+}
+
+// repointSlavesTo implements RepointSlavesTo. Caller is expected to hold the cluster lock.
+func repointSlavesTo(instanceKey *InstanceKey, pattern string, belowKey *InstanceKey) ([](*Instance), error, []error) {
 	res := [](*Instance){}
 	errs := []error{}
 
@@ -821,6 +961,17 @@ func RepointSlaves(instanceKey *InstanceKey, pattern string) ([](*Instance), err
 // MakeCoMaster will attempt to make an instance co-master with its master, by making its master a slave of its own.
 // This only works out if the master is not replicating; the master does not have a known master (it may have an unknown master).
 func MakeCoMaster(instanceKey *InstanceKey) (*Instance, error) {
+	lockToken, err := BeginClusterLockByInstanceKey(instanceKey, GetMaintenanceOwner(), "make-co-master")
+	if err != nil {
+		return nil, err
+	}
+	defer EndClusterLock(lockToken)
+
+	return makeCoMaster(instanceKey)
+}
+
+// makeCoMaster implements MakeCoMaster. Caller is expected to hold the cluster lock.
+func makeCoMaster(instanceKey *InstanceKey) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
 		return instance, err
@@ -1181,6 +1332,63 @@ func DisableGTID(instanceKey *InstanceKey) (*Instance, error) {
 	return instance, err
 }
 
+// EnableReplicationTLS turns on TLS for the replication of given slave, setting the certificate files configured by
+// MySQLReplicationSSL*. Replication is stopped for a short duration.
+func EnableReplicationTLS(instanceKey *InstanceKey) (*Instance, error) {
+	masterSSL := configuredMasterSSLSettings()
+	return changeReplicationTLS(instanceKey, &masterSSL, "enable-replication-tls")
+}
+
+// DisableReplicationTLS turns off TLS for the replication of given slave. Replication is stopped for a short duration.
+func DisableReplicationTLS(instanceKey *InstanceKey) (*Instance, error) {
+	return changeReplicationTLS(instanceKey, &MasterSSLSettings{Allowed: false}, "disable-replication-tls")
+}
+
+func changeReplicationTLS(instanceKey *InstanceKey, masterSSL *MasterSSLSettings, operation string) (*Instance, error) {
+	instance, err := ReadTopologyInstance(instanceKey)
+	if err != nil {
+		return instance, err
+	}
+	if !instance.IsSlave() {
+		return instance, fmt.Errorf("instance is not a slave: %+v", *instanceKey)
+	}
+	if instance.AllowTLS == masterSSL.Allowed {
+		return instance, fmt.Errorf("%+v: replication TLS is already %t", *instanceKey, instance.AllowTLS)
+	}
+
+	log.Infof("Will attempt to %s on %+v", operation, *instanceKey)
+
+	if maintenanceToken, merr := BeginMaintenance(instanceKey, GetMaintenanceOwner(), operation); merr != nil {
+		err = fmt.Errorf("Cannot begin maintenance on %+v", *instanceKey)
+		goto Cleanup
+	} else {
+		defer EndMaintenance(maintenanceToken)
+	}
+
+	instance, err = StopSlave(instanceKey)
+	if err != nil {
+		goto Cleanup
+	}
+
+	instance, err = ChangeMasterSSL(instanceKey, masterSSL)
+	if err != nil {
+		goto Cleanup
+	}
+
+Cleanup:
+	instance, _ = StartSlave(instanceKey)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+	if instance.AllowTLS != masterSSL.Allowed {
+		return instance, fmt.Errorf("Cannot %s on %+v", operation, *instanceKey)
+	}
+	// and we're done (pending deferred functions)
+	AuditOperation(operation, instanceKey, fmt.Sprintf("replication TLS on %+v: %t", *instanceKey, instance.AllowTLS))
+
+	return instance, err
+}
+
 // ResetMasterGTIDOperation will issue a safe RESET MASTER on a slave that replicates via GTID:
 // It will make sure the gtid_purged set matches the executed set value as read just before the RESET.
 // this will enable new slaves to be attached to given instance without complaints about missing/purged entries.
@@ -1329,11 +1537,22 @@ func CorrelateBinlogCoordinates(instance *Instance, binlogCoordinates *BinlogCoo
 // The "other instance" could be the sibling of the moving instance any of its ancestors. It may actually be
 // a cousin of some sort (though unlikely). The only important thing is that the "other instance" is more
 // advanced in replication than given instance.
+// On a multi-source instance, the replication channel the instance is presented by is matched.
 func MatchBelow(instanceKey, otherKey *InstanceKey, requireInstanceMaintenance bool) (*Instance, *BinlogCoordinates, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
 		return instance, nil, err
 	}
+	return MatchBelowChannel(instanceKey, instance.ReplicationChannel, otherKey, requireInstanceMaintenance)
+}
+
+// MatchBelowChannel matches a given replication channel of an instance below another instance using Pseudo-GTID.
+// Other channels of a multi-source slave keep on replicating.
+func MatchBelowChannel(instanceKey *InstanceKey, channel string, otherKey *InstanceKey, requireInstanceMaintenance bool) (*Instance, *BinlogCoordinates, error) {
+	instance, err := readTopologyInstanceChannel(instanceKey, channel)
+	if err != nil {
+		return instance, nil, err
+	}
 	if config.Config.PseudoGTIDPattern == "" {
 		return instance, nil, fmt.Errorf("PseudoGTIDPattern not configured; cannot use Pseudo-GTID")
 	}
@@ -1362,7 +1581,7 @@ func MatchBelow(instanceKey, otherKey *InstanceKey, requireInstanceMaintenance b
 		goto Cleanup
 	}
 
-	log.Infof("Will match %+v below %+v", *instanceKey, *otherKey)
+	log.Infof("Will match %+v, channel: '%s', below %+v", *instanceKey, channel, *otherKey)
 
 	if requireInstanceMaintenance {
 		if maintenanceToken, merr := BeginMaintenance(instanceKey, GetMaintenanceOwner(), fmt.Sprintf("match below %+v", *otherKey)); merr != nil {
@@ -1374,7 +1593,7 @@ func MatchBelow(instanceKey, otherKey *InstanceKey, requireInstanceMaintenance b
 	}
 
 	log.Debugf("Stopping slave on %+v", *instanceKey)
-	instance, err = StopSlave(instanceKey)
+	instance, err = StopSlaveChannel(instanceKey, channel)
 	if err != nil {
 		goto Cleanup
 	}
@@ -1388,18 +1607,18 @@ func MatchBelow(instanceKey, otherKey *InstanceKey, requireInstanceMaintenance b
 	log.Debugf("%+v will match below %+v at %+v; validated events: %d", *instanceKey, *otherKey, *nextBinlogCoordinatesToMatch, countMatchedEvents)
 
 	// Drum roll......
-	instance, err = ChangeMasterTo(instanceKey, otherKey, nextBinlogCoordinatesToMatch, false, GTIDHintDeny)
+	instance, err = ChangeMasterToChannel(instanceKey, channel, otherKey, nextBinlogCoordinatesToMatch, false, GTIDHintDeny)
 	if err != nil {
 		goto Cleanup
 	}
 
 Cleanup:
-	instance, _ = StartSlave(instanceKey)
+	instance, _ = StartSlaveChannel(instanceKey, channel)
 	if err != nil {
 		return instance, nextBinlogCoordinatesToMatch, log.Errore(err)
 	}
 	// and we're done (pending deferred functions)
-	AuditOperation("match-below", instanceKey, fmt.Sprintf("matched %+v below %+v", *instanceKey, *otherKey))
+	AuditOperation("match-below", instanceKey, fmt.Sprintf("matched %+v below %+v%s", *instanceKey, *otherKey, replicationChannelAuditSuffix(instance, channel)))
 
 	return instance, nextBinlogCoordinatesToMatch, err
 }
@@ -1420,6 +1639,17 @@ func RematchSlave(instanceKey *InstanceKey, requireInstanceMaintenance bool) (*I
 // MakeMaster will take an instance, make all its siblings its slaves (via pseudo-GTID) and make it master
 // (stop its replicaiton, make writeable).
 func MakeMaster(instanceKey *InstanceKey) (*Instance, error) {
+	lockToken, err := BeginClusterLockByInstanceKey(instanceKey, GetMaintenanceOwner(), "make-master")
+	if err != nil {
+		return nil, err
+	}
+	defer EndClusterLock(lockToken)
+
+	return makeMaster(instanceKey)
+}
+
+// makeMaster implements MakeMaster. Caller is expected to hold the cluster lock.
+func makeMaster(instanceKey *InstanceKey) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
 		return instance, err
@@ -1476,6 +1706,17 @@ Cleanup:
 // EnslaveSiblings is a convenience method for turning sublings of a slave to be its subordinates.
 // This uses normal connected replication (does not utilize Pseudo-GTID)
 func EnslaveSiblings(instanceKey *InstanceKey) (*Instance, int, error) {
+	lockToken, err := BeginClusterLockByInstanceKey(instanceKey, GetMaintenanceOwner(), "enslave-siblings")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer EndClusterLock(lockToken)
+
+	return enslaveSiblings(instanceKey)
+}
+
+// enslaveSiblings implements EnslaveSiblings. Caller is expected to hold the cluster lock.
+func enslaveSiblings(instanceKey *InstanceKey) (*Instance, int, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
 		return instance, 0, err
@@ -1504,6 +1745,17 @@ func EnslaveSiblings(instanceKey *InstanceKey) (*Instance, int, error) {
 // Note that the master must itself be a slave; however the grandparent does not necessarily have to be reachable
 // and can in fact be dead.
 func EnslaveMaster(instanceKey *InstanceKey) (*Instance, error) {
+	lockToken, err := BeginClusterLockByInstanceKey(instanceKey, GetMaintenanceOwner(), "enslave-master")
+	if err != nil {
+		return nil, err
+	}
+	defer EndClusterLock(lockToken)
+
+	return enslaveMaster(instanceKey)
+}
+
+// enslaveMaster implements EnslaveMaster. Caller is expected to hold the cluster lock.
+func enslaveMaster(instanceKey *InstanceKey) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
 		return instance, err
@@ -1563,6 +1815,17 @@ Cleanup:
 // which is most advanced among its siblings.
 // This method utilizes Pseudo GTID
 func MakeLocalMaster(instanceKey *InstanceKey) (*Instance, error) {
+	lockToken, err := BeginClusterLockByInstanceKey(instanceKey, GetMaintenanceOwner(), "make-local-master")
+	if err != nil {
+		return nil, err
+	}
+	defer EndClusterLock(lockToken)
+
+	return makeLocalMaster(instanceKey)
+}
+
+// makeLocalMaster implements MakeLocalMaster. Caller is expected to hold the cluster lock.
+func makeLocalMaster(instanceKey *InstanceKey) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
 		return instance, err
@@ -1834,6 +2097,17 @@ func MultiMatchBelow(slaves [](*Instance), belowKey *InstanceKey, slavesAlreadyS
 
 // MultiMatchSlaves will match (via pseudo-gtid) all slaves of given master below given instance.
 func MultiMatchSlaves(masterKey *InstanceKey, belowKey *InstanceKey, pattern string) ([](*Instance), *Instance, error, []error) {
+	lockToken, err := BeginClusterLockByInstanceKey(masterKey, GetMaintenanceOwner(), "multi-match-slaves")
+	if err != nil {
+		return nil, nil, err, nil
+	}
+	defer EndClusterLock(lockToken)
+
+	return multiMatchSlaves(masterKey, belowKey, pattern)
+}
+
+// multiMatchSlaves implements MultiMatchSlaves. Caller is expected to hold the cluster lock.
+func multiMatchSlaves(masterKey *InstanceKey, belowKey *InstanceKey, pattern string) ([](*Instance), *Instance, error, []error) {
 	res := [](*Instance){}
 	errs := []error{}
 
@@ -1864,7 +2138,7 @@ func MultiMatchSlaves(masterKey *InstanceKey, belowKey *InstanceKey, pattern str
 		binlogCase = true
 	}
 	if binlogCase {
-		slaves, err, errors := RepointSlavesTo(masterKey, pattern, belowKey)
+		slaves, err, errors := repointSlavesTo(masterKey, pattern, belowKey)
 		// Bail out!
 		return slaves, masterInstance, err, errors
 	}
@@ -1912,6 +2186,17 @@ func MatchUp(instanceKey *InstanceKey, requireInstanceMaintenance bool) (*Instan
 // so that they become siblings of their master.
 // This should be called when the local master dies, and all its slaves are to be resurrected via Pseudo-GTID
 func MatchUpSlaves(masterKey *InstanceKey, pattern string) ([](*Instance), *Instance, error, []error) {
+	lockToken, err := BeginClusterLockByInstanceKey(masterKey, GetMaintenanceOwner(), "match-up-slaves")
+	if err != nil {
+		return nil, nil, err, nil
+	}
+	defer EndClusterLock(lockToken)
+
+	return matchUpSlaves(masterKey, pattern)
+}
+
+// matchUpSlaves implements MatchUpSlaves. Caller is expected to hold the cluster lock.
+func matchUpSlaves(masterKey *InstanceKey, pattern string) ([](*Instance), *Instance, error, []error) {
 	res := [](*Instance){}
 	errs := []error{}
 
@@ -1920,7 +2205,7 @@ func MatchUpSlaves(masterKey *InstanceKey, pattern string) ([](*Instance), *Inst
 		return res, nil, err, errs
 	}
 
-	return MultiMatchSlaves(masterKey, &masterInstance.MasterKey, pattern)
+	return multiMatchSlaves(masterKey, &masterInstance.MasterKey, pattern)
 }
 
 func isGenerallyValidAsBinlogSource(slave *Instance) bool {
@@ -2143,6 +2428,17 @@ func GetCandidateSlaveOfBinlogServerTopology(masterKey *InstanceKey) (candidateS
 
 // RegroupSlavesPseudoGTID will choose a candidate slave of a given instance, and enslave its siblings using pseudo-gtid
 func RegroupSlavesPseudoGTID(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool, onCandidateSlaveChosen func(*Instance), postponedFunctionsContainer *PostponedFunctionsContainer) ([](*Instance), [](*Instance), [](*Instance), [](*Instance), *Instance, error) {
+	lockToken, err := BeginClusterLockByInstanceKey(masterKey, GetMaintenanceOwner(), "regroup-slaves")
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+	defer EndClusterLock(lockToken)
+
+	return regroupSlavesPseudoGTID(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen, postponedFunctionsContainer)
+}
+
+// regroupSlavesPseudoGTID implements RegroupSlavesPseudoGTID. Caller is expected to hold the cluster lock.
+func regroupSlavesPseudoGTID(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool, onCandidateSlaveChosen func(*Instance), postponedFunctionsContainer *PostponedFunctionsContainer) ([](*Instance), [](*Instance), [](*Instance), [](*Instance), *Instance, error) {
 	candidateSlave, aheadSlaves, equalSlaves, laterSlaves, cannotReplicateSlaves, err := GetCandidateSlave(masterKey, true)
 	if err != nil {
 		if !returnSlaveEvenOnFailureToRegroup {
@@ -2224,6 +2520,17 @@ func getMostUpToDateActiveBinlogServer(masterKey *InstanceKey) (mostAdvancedBinl
 // of given instance. The function also drill in to slaves of binlog servers that are replicating from given instance,
 // and other recursive binlog servers, as long as they're in the same binlog-server-family.
 func RegroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool, onCandidateSlaveChosen func(*Instance), postponedFunctionsContainer *PostponedFunctionsContainer) ([](*Instance), [](*Instance), [](*Instance), [](*Instance), *Instance, error) {
+	lockToken, err := BeginClusterLockByInstanceKey(masterKey, GetMaintenanceOwner(), "regroup-slaves")
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+	defer EndClusterLock(lockToken)
+
+	return regroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen, postponedFunctionsContainer)
+}
+
+// regroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers implements RegroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers. Caller is expected to hold the cluster lock.
+func regroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool, onCandidateSlaveChosen func(*Instance), postponedFunctionsContainer *PostponedFunctionsContainer) ([](*Instance), [](*Instance), [](*Instance), [](*Instance), *Instance, error) {
 	// First, handle binlog server issues:
 	func() error {
 		log.Debugf("RegroupSlavesIncludingSubSlavesOfBinlogServers: starting on slaves of %+v", *masterKey)
@@ -2278,7 +2585,7 @@ func RegroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers(masterKey *Instanc
 			log.Debugf("RegroupSlavesIncludingSubSlavesOfBinlogServers: matching slaves of binlog server %+v below %+v", binlogServer.Key, candidateSlave.Key)
 			// Right now sequentially.
 			// At this point just do what you can, don't return an error
-			MultiMatchSlaves(&binlogServer.Key, &candidateSlave.Key, "")
+			multiMatchSlaves(&binlogServer.Key, &candidateSlave.Key, "")
 			log.Debugf("RegroupSlavesIncludingSubSlavesOfBinlogServers: done matching slaves of binlog server %+v below %+v", binlogServer.Key, candidateSlave.Key)
 		}
 		log.Debugf("RegroupSlavesIncludingSubSlavesOfBinlogServers: done handling binlog regrouping for %+v; will proceed with normal RegroupSlaves", *masterKey)
@@ -2286,11 +2593,22 @@ func RegroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers(masterKey *Instanc
 		return nil
 	}()
 	// Proceed to normal regroup:
-	return RegroupSlavesPseudoGTID(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen, postponedFunctionsContainer)
+	return regroupSlavesPseudoGTID(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen, postponedFunctionsContainer)
 }
 
 // RegroupSlavesGTID will choose a candidate slave of a given instance, and enslave its siblings using GTID
 func RegroupSlavesGTID(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool, onCandidateSlaveChosen func(*Instance)) ([](*Instance), [](*Instance), [](*Instance), *Instance, error) {
+	lockToken, err := BeginClusterLockByInstanceKey(masterKey, GetMaintenanceOwner(), "regroup-slaves-gtid")
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	defer EndClusterLock(lockToken)
+
+	return regroupSlavesGTID(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen)
+}
+
+// regroupSlavesGTID implements RegroupSlavesGTID. Caller is expected to hold the cluster lock.
+func regroupSlavesGTID(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool, onCandidateSlaveChosen func(*Instance)) ([](*Instance), [](*Instance), [](*Instance), *Instance, error) {
 	var emptySlaves [](*Instance)
 	candidateSlave, aheadSlaves, equalSlaves, laterSlaves, cannotReplicateSlaves, err := GetCandidateSlave(masterKey, true)
 	if err != nil {
@@ -2322,6 +2640,17 @@ func RegroupSlavesGTID(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup
 // RegroupSlavesBinlogServers works on a binlog-servers topology. It picks the most up-to-date BLS and repoints all other
 // BLS below it
 func RegroupSlavesBinlogServers(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool) (repointedBinlogServers [](*Instance), promotedBinlogServer *Instance, err error) {
+	lockToken, err := BeginClusterLockByInstanceKey(masterKey, GetMaintenanceOwner(), "regroup-slaves-bls")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer EndClusterLock(lockToken)
+
+	return regroupSlavesBinlogServers(masterKey, returnSlaveEvenOnFailureToRegroup)
+}
+
+// regroupSlavesBinlogServers implements RegroupSlavesBinlogServers. Caller is expected to hold the cluster lock.
+func regroupSlavesBinlogServers(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool) (repointedBinlogServers [](*Instance), promotedBinlogServer *Instance, err error) {
 	var binlogServerSlaves [](*Instance)
 	promotedBinlogServer, binlogServerSlaves, err = getMostUpToDateActiveBinlogServer(masterKey)
 
@@ -2348,6 +2677,20 @@ func RegroupSlavesBinlogServers(masterKey *InstanceKey, returnSlaveEvenOnFailure
 // RegroupSlaves is a "smart" method of promoting one slave over the others ("promoting" it on top of its siblings)
 // This method decides which strategy to use: GTID, Pseudo-GTID, Binlog Servers.
 func RegroupSlaves(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool,
+	onCandidateSlaveChosen func(*Instance),
+	postponedFunctionsContainer *PostponedFunctionsContainer) (
+	aheadSlaves [](*Instance), equalSlaves [](*Instance), laterSlaves [](*Instance), cannotReplicateSlaves [](*Instance), instance *Instance, err error) {
+	lockToken, err := BeginClusterLockByInstanceKey(masterKey, GetMaintenanceOwner(), "regroup-slaves")
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+	defer EndClusterLock(lockToken)
+
+	return regroupSlaves(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen, postponedFunctionsContainer)
+}
+
+// regroupSlaves implements RegroupSlaves. Caller is expected to hold the cluster lock.
+func regroupSlaves(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup bool,
 	onCandidateSlaveChosen func(*Instance),
 	postponedFunctionsContainer *PostponedFunctionsContainer) (
 	aheadSlaves [](*Instance), equalSlaves [](*Instance), laterSlaves [](*Instance), cannotReplicateSlaves [](*Instance), instance *Instance, err error) {
@@ -2380,44 +2723,46 @@ func RegroupSlaves(masterKey *InstanceKey, returnSlaveEvenOnFailureToRegroup boo
 	}
 	if allGTID {
 		log.Debugf("RegroupSlaves: using GTID to regroup slaves of %+v", *masterKey)
-		unmovedSlaves, movedSlaves, cannotReplicateSlaves, candidateSlave, err := RegroupSlavesGTID(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen)
+		unmovedSlaves, movedSlaves, cannotReplicateSlaves, candidateSlave, err := regroupSlavesGTID(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen)
 		return unmovedSlaves, emptySlaves, movedSlaves, cannotReplicateSlaves, candidateSlave, err
 	}
 	if allBinlogServers {
 		log.Debugf("RegroupSlaves: using binlog servers to regroup slaves of %+v", *masterKey)
-		movedSlaves, candidateSlave, err := RegroupSlavesBinlogServers(masterKey, returnSlaveEvenOnFailureToRegroup)
+		movedSlaves, candidateSlave, err := regroupSlavesBinlogServers(masterKey, returnSlaveEvenOnFailureToRegroup)
 		return emptySlaves, emptySlaves, movedSlaves, cannotReplicateSlaves, candidateSlave, err
 	}
 	if allPseudoGTID {
 		log.Debugf("RegroupSlaves: using Pseudo-GTID to regroup slaves of %+v", *masterKey)
-		return RegroupSlavesPseudoGTID(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen, postponedFunctionsContainer)
+		return regroupSlavesPseudoGTID(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen, postponedFunctionsContainer)
 	}
 	// And, as last resort, we do PseudoGTID & binlog servers
 	log.Warningf("RegroupSlaves: unsure what method to invoke for %+v; trying Pseudo-GTID+Binlog Servers", *masterKey)
-	return RegroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen, postponedFunctionsContainer)
+	return regroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers(masterKey, returnSlaveEvenOnFailureToRegroup, onCandidateSlaveChosen, postponedFunctionsContainer)
 }
 
 // relocateBelowInternal is a protentially recursive function which chooses how to relocate an instance below another.
 // It may choose to use Pseudo-GTID, or normal binlog positions, or take advantage of binlog servers,
 // or it may combine any of the above in a multi-step operation.
+// On a multi-source instance, the replication channel the instance is presented by is relocated.
 func relocateBelowInternal(instance, other *Instance) (*Instance, error) {
+	channel := instance.ReplicationChannel
 	if canReplicate, err := instance.CanReplicateFrom(other); !canReplicate {
 		return instance, log.Errorf("%+v cannot replicate from %+v. Reason: %+v", instance.Key, other.Key, err)
 	}
 	// simplest:
 	if InstanceIsMasterOf(other, instance) {
 		// already the desired setup.
-		return Repoint(&instance.Key, &other.Key, GTIDHintNeutral)
+		return RepointChannel(&instance.Key, channel, &other.Key, GTIDHintNeutral)
 	}
 	// Do we have record of equivalent coordinates?
 	if !instance.IsBinlogServer() {
-		if movedInstance, err := MoveEquivalent(&instance.Key, &other.Key); err == nil {
+		if movedInstance, err := moveEquivalent(instance, &other.Key); err == nil {
 			return movedInstance, nil
 		}
 	}
 	// Try and take advantage of binlog servers:
 	if InstancesAreSiblings(instance, other) && other.IsBinlogServer() {
-		return MoveBelow(&instance.Key, &other.Key)
+		return MoveBelowChannel(&instance.Key, channel, &other.Key)
 	}
 	instanceMaster, _, err := ReadInstance(&instance.MasterKey)
 	if err != nil {
@@ -2425,12 +2770,12 @@ func relocateBelowInternal(instance, other *Instance) (*Instance, error) {
 	}
 	if instanceMaster != nil && instanceMaster.MasterKey.Equals(&other.Key) && instanceMaster.IsBinlogServer() {
 		// Moving to grandparent via binlog server
-		return Repoint(&instance.Key, &instanceMaster.MasterKey, GTIDHintDeny)
+		return RepointChannel(&instance.Key, channel, &instanceMaster.MasterKey, GTIDHintDeny)
 	}
 	if other.IsBinlogServer() {
 		if instanceMaster != nil && instanceMaster.IsBinlogServer() && InstancesAreSiblings(instanceMaster, other) {
 			// Special case: this is a binlog server family; we move under the uncle, in one single step
-			return Repoint(&instance.Key, &other.Key, GTIDHintDeny)
+			return RepointChannel(&instance.Key, channel, &other.Key, GTIDHintDeny)
 		}
 
 		// Relocate to its master, then repoint to the binlog server
@@ -2449,7 +2794,7 @@ func relocateBelowInternal(instance, other *Instance) (*Instance, error) {
 		if _, err := relocateBelowInternal(instance, otherMaster); err != nil {
 			return instance, err
 		}
-		return Repoint(&instance.Key, &other.Key, GTIDHintDeny)
+		return RepointChannel(&instance.Key, channel, &other.Key, GTIDHintDeny)
 	}
 	if instance.IsBinlogServer() {
 		// Can only move within the binlog-server family tree
@@ -2466,24 +2811,24 @@ func relocateBelowInternal(instance, other *Instance) (*Instance, error) {
 	if instance.UsingPseudoGTID && other.UsingPseudoGTID {
 		// We prefer PseudoGTID to anything else because, while it takes longer to run, it does not issue
 		// a STOP SLAVE on any server other than "instance" itself.
-		instance, _, err := MatchBelow(&instance.Key, &other.Key, true)
+		instance, _, err := MatchBelowChannel(&instance.Key, channel, &other.Key, true)
 		return instance, err
 	}
 	// No Pseudo-GTID; cehck simple binlog file/pos operations:
 	if InstancesAreSiblings(instance, other) {
 		// If comastering, only move below if it's read-only
 		if !other.IsCoMaster || other.ReadOnly {
-			return MoveBelow(&instance.Key, &other.Key)
+			return MoveBelowChannel(&instance.Key, channel, &other.Key)
 		}
 	}
 	// See if we need to MoveUp
 	if instanceMaster != nil && instanceMaster.MasterKey.Equals(&other.Key) {
 		// Moving to grandparent--handles co-mastering writable case
-		return MoveUp(&instance.Key)
+		return MoveUpChannel(&instance.Key, channel)
 	}
 	if instanceMaster != nil && instanceMaster.IsBinlogServer() {
 		// Break operation into two: move (repoint) up, then continue
-		if _, err := MoveUp(&instance.Key); err != nil {
+		if _, err := MoveUpChannel(&instance.Key, channel); err != nil {
 			return instance, err
 		}
 		return relocateBelowInternal(instance, other)
@@ -2495,20 +2840,35 @@ func relocateBelowInternal(instance, other *Instance) (*Instance, error) {
 // RelocateBelow will attempt moving instance indicated by instanceKey below another instance.
 // Orchestrator will try and figure out the best way to relocate the server. This could span normal
 // binlog-position, pseudo-gtid, repointing, binlog servers...
+// On a multi-source instance, the replication channel the instance is presented by is relocated.
 func RelocateBelow(instanceKey, otherKey *InstanceKey) (*Instance, error) {
 	instance, found, err := ReadInstance(instanceKey)
 	if err != nil || !found {
 		return instance, log.Errorf("Error reading %+v", *instanceKey)
 	}
+	return RelocateBelowChannel(instanceKey, instance.ReplicationChannel, otherKey)
+}
+
+// RelocateBelowChannel relocates a given replication channel of an instance below another instance, choosing
+// the best way to do so as RelocateBelow does. Other channels of a multi-source slave keep on replicating.
+func RelocateBelowChannel(instanceKey *InstanceKey, channel string, otherKey *InstanceKey) (*Instance, error) {
+	instance, found, err := ReadInstance(instanceKey)
+	if err != nil || !found {
+		return instance, log.Errorf("Error reading %+v", *instanceKey)
+	}
+	instance, err = instance.WithReplicationChannel(channel)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
 	other, found, err := ReadInstance(otherKey)
 	if err != nil || !found {
 		return instance, log.Errorf("Error reading %+v", *otherKey)
 	}
-	instance, err = relocateBelowInternal(instance, other)
+	relocatedInstance, err := relocateBelowInternal(instance, other)
 	if err == nil {
-		AuditOperation("relocate-below", instanceKey, fmt.Sprintf("relocated %+v below %+v", *instanceKey, *otherKey))
+		AuditOperation("relocate-below", instanceKey, fmt.Sprintf("relocated %+v below %+v%s", *instanceKey, *otherKey, replicationChannelAuditSuffix(instance, channel)))
 	}
-	return instance, err
+	return relocatedInstance, err
 }
 
 // relocateSlavesInternal is a protentially recursive function which chooses how to relocate
@@ -2589,6 +2949,17 @@ func relocateSlavesInternal(slaves [](*Instance), instance, other *Instance) ([]
 // Orchestrator will try and figure out the best way to relocate the servers. This could span normal
 // binlog-position, pseudo-gtid, repointing, binlog servers...
 func RelocateSlaves(instanceKey, otherKey *InstanceKey, pattern string) (slaves [](*Instance), other *Instance, err error, errs []error) {
+	lockToken, err := BeginClusterLockByInstanceKey(instanceKey, GetMaintenanceOwner(), "relocate-slaves")
+	if err != nil {
+		return nil, nil, err, nil
+	}
+	defer EndClusterLock(lockToken)
+
+	return relocateSlaves(instanceKey, otherKey, pattern)
+}
+
+// relocateSlaves implements RelocateSlaves. Caller is expected to hold the cluster lock.
+func relocateSlaves(instanceKey, otherKey *InstanceKey, pattern string) (slaves [](*Instance), other *Instance, err error, errs []error) {
 
 	instance, found, err := ReadInstance(instanceKey)
 	if err != nil || !found {
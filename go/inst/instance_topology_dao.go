@@ -18,7 +18,9 @@ package inst
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/outbrain/golib/log"
@@ -157,19 +159,19 @@ func GetSlaveRestartPreserveStatements(instanceKey *InstanceKey, injectedStateme
 		return statements, err
 	}
 	if instance.Slave_IO_Running {
-		statements = append(statements, SemicolonTerminated(`stop slave io_thread`))
+		statements = append(statements, SemicolonTerminated(replicationChannelStatement(instance, instance.ReplicationChannel, `stop slave io_thread`)))
 	}
 	if instance.Slave_SQL_Running {
-		statements = append(statements, SemicolonTerminated(`stop slave sql_thread`))
+		statements = append(statements, SemicolonTerminated(replicationChannelStatement(instance, instance.ReplicationChannel, `stop slave sql_thread`)))
 	}
 	if injectedStatement != "" {
 		statements = append(statements, SemicolonTerminated(injectedStatement))
 	}
 	if instance.Slave_SQL_Running {
-		statements = append(statements, SemicolonTerminated(`start slave sql_thread`))
+		statements = append(statements, SemicolonTerminated(replicationChannelStatement(instance, instance.ReplicationChannel, `start slave sql_thread`)))
 	}
 	if instance.Slave_IO_Running {
-		statements = append(statements, SemicolonTerminated(`start slave io_thread`))
+		statements = append(statements, SemicolonTerminated(replicationChannelStatement(instance, instance.ReplicationChannel, `start slave io_thread`)))
 	}
 	return statements, err
 }
@@ -246,8 +248,8 @@ func StopSlaveNicely(instanceKey *InstanceKey, timeout time.Duration) (*Instance
 		return instance, fmt.Errorf("instance is not a slave: %+v", instanceKey)
 	}
 
-	_, err = ExecInstanceNoPrepare(instanceKey, `stop slave io_thread`)
-	_, err = ExecInstanceNoPrepare(instanceKey, `start slave sql_thread`)
+	_, err = ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, instance.ReplicationChannel, `stop slave io_thread`))
+	_, err = ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, instance.ReplicationChannel, `start slave sql_thread`))
 
 	if instance.SQLDelay == 0 {
 		// Otherwise we don't bother.
@@ -269,7 +271,7 @@ func StopSlaveNicely(instanceKey *InstanceKey, timeout time.Duration) (*Instance
 			}
 		}
 	}
-	_, err = ExecInstanceNoPrepare(instanceKey, `stop slave`)
+	_, err = ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, instance.ReplicationChannel, `stop slave`))
 	if err != nil {
 		// Patch; current MaxScale behavior for STOP SLAVE is to throw an error if slave already stopped.
 		if instance.isMaxScale() && err.Error() == "Error 1199: Slave connection is not running" {
@@ -312,7 +314,29 @@ func StopSlavesNicely(slaves [](*Instance), timeout time.Duration) [](*Instance)
 	return refreshedSlaves
 }
 
-// StopSlave stops replication on a given instance
+// readTopologyInstanceChannel reads an instance from the topology, presented by given replication channel
+func readTopologyInstanceChannel(instanceKey *InstanceKey, channel string) (*Instance, error) {
+	instance, err := ReadTopologyInstance(instanceKey)
+	if err != nil {
+		return instance, err
+	}
+	return instance.WithReplicationChannel(channel)
+}
+
+// readTopologyInstanceByMaster reads an instance from the topology, presented by the replication channel via which
+// it replicates from given master, if there is such
+func readTopologyInstanceByMaster(instanceKey *InstanceKey, masterKey *InstanceKey) (*Instance, error) {
+	instance, err := ReadTopologyInstance(instanceKey)
+	if err != nil {
+		return instance, err
+	}
+	if channel := instance.GetReplicationChannelByMaster(masterKey); channel != nil {
+		return instance.WithReplicationChannel(channel.Name)
+	}
+	return instance, nil
+}
+
+// StopSlave stops replication on a given instance. On a multi-source instance, all replication channels are stopped.
 func StopSlave(instanceKey *InstanceKey) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
@@ -322,7 +346,7 @@ func StopSlave(instanceKey *InstanceKey) (*Instance, error) {
 	if !instance.IsSlave() {
 		return instance, fmt.Errorf("instance is not a slave: %+v", instanceKey)
 	}
-	_, err = ExecInstanceNoPrepare(instanceKey, `stop slave`)
+	_, err = ExecInstanceNoPrepare(instanceKey, allReplicationChannelsStatement(instance, `stop slave`))
 	if err != nil {
 		// Patch; current MaxScale behavior for STOP SLAVE is to throw an error if slave already stopped.
 		if instance.isMaxScale() && err.Error() == "Error 1199: Slave connection is not running" {
@@ -339,7 +363,82 @@ func StopSlave(instanceKey *InstanceKey) (*Instance, error) {
 	return instance, err
 }
 
-// StartSlave starts replication on a given instance.
+// StopSlaveChannel stops replication of a given channel on a given instance
+func StopSlaveChannel(instanceKey *InstanceKey, channel string) (*Instance, error) {
+	instance, err := ReadTopologyInstance(instanceKey)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+	return stopSlaveChannel(instance, channel)
+}
+
+func stopSlaveChannel(instance *Instance, channel string) (*Instance, error) {
+	instanceKey := instance.Key
+	instance, err := instance.WithReplicationChannel(channel)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+
+	if !instance.IsSlave() {
+		return instance, fmt.Errorf("instance is not a slave: %+v", instanceKey)
+	}
+	_, err = ExecInstanceNoPrepare(&instanceKey, replicationChannelStatement(instance, channel, `stop slave`))
+	if err != nil {
+		// Patch; current MaxScale behavior for STOP SLAVE is to throw an error if slave already stopped.
+		if instance.isMaxScale() && err.Error() == "Error 1199: Slave connection is not running" {
+			err = nil
+		}
+	}
+	if err != nil {
+
+		return instance, log.Errore(err)
+	}
+	instance, err = readTopologyInstanceChannel(&instanceKey, channel)
+
+	log.Infof("Stopped slave on %+v, channel: '%s', Self:%+v, Exec:%+v", instanceKey, channel, instance.SelfBinlogCoordinates, instance.ExecBinlogCoordinates)
+	return instance, err
+}
+
+// StopSlaveIOThread stops the IO thread of the replication channel a given instance is presented by. The SQL thread
+// keeps on applying relay logs.
+func StopSlaveIOThread(instanceKey *InstanceKey) (*Instance, error) {
+	instance, err := ReadTopologyInstance(instanceKey)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+	if !instance.IsSlave() {
+		return instance, fmt.Errorf("instance is not a slave: %+v", *instanceKey)
+	}
+	_, err = ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, instance.ReplicationChannel, `stop slave io_thread`))
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+	instance, err = ReadTopologyInstance(instanceKey)
+
+	log.Infof("Stopped slave IO thread on %+v, channel: '%s'", *instanceKey, instance.ReplicationChannel)
+	return instance, err
+}
+
+// StartSlaveIOThread starts the IO thread of the replication channel a given instance is presented by
+func StartSlaveIOThread(instanceKey *InstanceKey) (*Instance, error) {
+	instance, err := ReadTopologyInstance(instanceKey)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+	if !instance.IsSlave() {
+		return instance, fmt.Errorf("instance is not a slave: %+v", *instanceKey)
+	}
+	_, err = ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, instance.ReplicationChannel, `start slave io_thread`))
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+	instance, err = ReadTopologyInstance(instanceKey)
+
+	log.Infof("Started slave IO thread on %+v, channel: '%s'", *instanceKey, instance.ReplicationChannel)
+	return instance, err
+}
+
+// StartSlave starts replication on a given instance. On a multi-source instance, all replication channels are started.
 func StartSlave(instanceKey *InstanceKey) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
@@ -364,7 +463,7 @@ func StartSlave(instanceKey *InstanceKey) (*Instance, error) {
 		}
 	}
 
-	_, err = ExecInstanceNoPrepare(instanceKey, `start slave`)
+	_, err = ExecInstanceNoPrepare(instanceKey, allReplicationChannelsStatement(instance, `start slave`))
 	if err != nil {
 		return instance, log.Errore(err)
 	}
@@ -377,6 +476,52 @@ func StartSlave(instanceKey *InstanceKey) (*Instance, error) {
 	return instance, err
 }
 
+// StartSlaveChannel starts replication of a given channel on a given instance
+func StartSlaveChannel(instanceKey *InstanceKey, channel string) (*Instance, error) {
+	instance, err := ReadTopologyInstance(instanceKey)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+	return startSlaveChannel(instance, channel)
+}
+
+func startSlaveChannel(instance *Instance, channel string) (*Instance, error) {
+	instanceKey := instance.Key
+	instance, err := instance.WithReplicationChannel(channel)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+
+	if !instance.IsSlave() {
+		return instance, fmt.Errorf("instance is not a slave: %+v", instanceKey)
+	}
+	// If async fallback is disallowed, we'd better make sure to enable slaves to
+	// send ACKs before START SLAVE. Slave ACKing is off at mysqld startup because
+	// some slaves (those that must never be promoted) should never ACK.
+	// Note: We assume that slaves use 'skip-slave-start' so they won't
+	//       START SLAVE on their own upon restart.
+	if instance.SemiSyncEnforced {
+		// Send ACK only from promotable instances.
+		sendACK := instance.PromotionRule != MustNotPromoteRule
+		// Always disable master setting, in case we're converting a former master.
+		if err := EnableSemiSync(&instanceKey, false, sendACK); err != nil {
+			return instance, log.Errore(err)
+		}
+	}
+
+	_, err = ExecInstanceNoPrepare(&instanceKey, replicationChannelStatement(instance, channel, `start slave`))
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+	log.Infof("Started slave on %+v, channel: '%s'", instanceKey, channel)
+	if config.Config.SlaveStartPostWaitMilliseconds > 0 {
+		time.Sleep(time.Duration(config.Config.SlaveStartPostWaitMilliseconds) * time.Millisecond)
+	}
+
+	instance, err = readTopologyInstanceChannel(&instanceKey, channel)
+	return instance, err
+}
+
 // RestartSlave stops & starts replication on a given instance
 func RestartSlave(instanceKey *InstanceKey) (instance *Instance, err error) {
 	instance, err = StopSlave(instanceKey)
@@ -410,12 +555,23 @@ func StartSlaves(slaves [](*Instance)) {
 	}
 }
 
-// StartSlaveUntilMasterCoordinates issuesa START SLAVE UNTIL... statement on given instance
+// StartSlaveUntilMasterCoordinates issuesa START SLAVE UNTIL... statement on given instance. On a multi-source
+// instance, the replication channel the instance is presented by is started.
 func StartSlaveUntilMasterCoordinates(instanceKey *InstanceKey, masterCoordinates *BinlogCoordinates) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
 		return instance, log.Errore(err)
 	}
+	return StartSlaveUntilMasterCoordinatesChannel(instanceKey, instance.ReplicationChannel, masterCoordinates)
+}
+
+// StartSlaveUntilMasterCoordinatesChannel issues a START SLAVE UNTIL... statement on a given replication channel of
+// a given instance, and waits for the channel to reach the coordinates
+func StartSlaveUntilMasterCoordinatesChannel(instanceKey *InstanceKey, channel string, masterCoordinates *BinlogCoordinates) (*Instance, error) {
+	instance, err := readTopologyInstanceChannel(instanceKey, channel)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
 
 	if !instance.IsSlave() {
 		return instance, fmt.Errorf("instance is not a slave: %+v", instanceKey)
@@ -424,7 +580,7 @@ func StartSlaveUntilMasterCoordinates(instanceKey *InstanceKey, masterCoordinate
 		return instance, fmt.Errorf("slave already running: %+v", instanceKey)
 	}
 
-	log.Infof("Will start slave on %+v until coordinates: %+v", instanceKey, masterCoordinates)
+	log.Infof("Will start slave on %+v, channel: '%s', until coordinates: %+v", instanceKey, channel, masterCoordinates)
 
 	if instance.SemiSyncEnforced {
 		// Send ACK only from promotable instances.
@@ -438,14 +594,14 @@ func StartSlaveUntilMasterCoordinates(instanceKey *InstanceKey, masterCoordinate
 	// MariaDB has a bug: a CHANGE MASTER TO statement does not work properly with prepared statement... :P
 	// See https://mariadb.atlassian.net/browse/MDEV-7640
 	// This is the reason for ExecInstanceNoPrepare
-	_, err = ExecInstanceNoPrepare(instanceKey, fmt.Sprintf("start slave until master_log_file='%s', master_log_pos=%d",
-		masterCoordinates.LogFile, masterCoordinates.LogPos))
+	_, err = ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, channel, fmt.Sprintf("start slave until master_log_file='%s', master_log_pos=%d",
+		masterCoordinates.LogFile, masterCoordinates.LogPos)))
 	if err != nil {
 		return instance, log.Errore(err)
 	}
 
 	for upToDate := false; !upToDate; {
-		instance, err = ReadTopologyInstance(instanceKey)
+		instance, err = readTopologyInstanceChannel(instanceKey, channel)
 		if err != nil {
 			return instance, log.Errore(err)
 		}
@@ -460,7 +616,7 @@ func StartSlaveUntilMasterCoordinates(instanceKey *InstanceKey, masterCoordinate
 		}
 	}
 
-	instance, err = StopSlave(instanceKey)
+	instance, err = StopSlaveChannel(instanceKey, channel)
 	if err != nil {
 		return instance, log.Errore(err)
 	}
@@ -478,7 +634,39 @@ func EnableSemiSync(instanceKey *InstanceKey, master, slave bool) error {
 	return err
 }
 
+// quoteChangeMasterString returns given value as a CHANGE MASTER TO string literal. Backslashes are rejected rather
+// than escaped, since their meaning depends on the server's NO_BACKSLASH_ESCAPES sql_mode.
+func quoteChangeMasterString(value string) (string, error) {
+	if strings.ContainsAny(value, "\\\x00") {
+		return "", errors.New("backslash and NUL characters are not supported")
+	}
+	return fmt.Sprintf("'%s'", strings.Replace(value, "'", "''", -1)), nil
+}
+
+// changeMasterCredentialsStatement returns the CHANGE MASTER TO statement setting given credentials
+func changeMasterCredentialsStatement(masterUser string, masterPassword string) (string, error) {
+	quotedUser, err := quoteChangeMasterString(masterUser)
+	if err != nil {
+		return "", fmt.Errorf("Unsupported replication user: %+v", err)
+	}
+	quotedPassword, err := quoteChangeMasterString(masterPassword)
+	if err != nil {
+		return "", fmt.Errorf("Unsupported replication password: %+v", err)
+	}
+	return fmt.Sprintf("change master to master_user=%s, master_password=%s", quotedUser, quotedPassword), nil
+}
+
+// redactSecret masks given secret, as is or quoted, in given message
+func redactSecret(message string, secret string) string {
+	if secret == "" {
+		return message
+	}
+	message = strings.Replace(message, strings.Replace(secret, "'", "''", -1), "********", -1)
+	return strings.Replace(message, secret, "********", -1)
+}
+
 // ChangeMasterCredentials issues a CHANGE MASTER TO... MASTER_USER=, MASTER_PASSWORD=...
+// The password is never logged nor returned as part of an error.
 func ChangeMasterCredentials(instanceKey *InstanceKey, masterUser string, masterPassword string) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
@@ -487,34 +675,89 @@ func ChangeMasterCredentials(instanceKey *InstanceKey, masterUser string, master
 	if masterUser == "" {
 		return instance, log.Errorf("Empty user in ChangeMasterCredentials() for %+v", *instanceKey)
 	}
+	changeMasterStatement, err := changeMasterCredentialsStatement(masterUser, masterPassword)
+	if err != nil {
+		return instance, log.Errorf("ChangeMasterCredentials: %+v on %+v", err, *instanceKey)
+	}
 
 	if instance.SlaveRunning() {
-		return instance, fmt.Errorf("ChangeMasterTo: Cannot change master on: %+v because slave is running", *instanceKey)
+		return instance, fmt.Errorf("ChangeMasterCredentials: Cannot change master credentials on: %+v because slave is running", *instanceKey)
 	}
-	log.Debugf("ChangeMasterTo: will attempt changing master credentials on %+v", *instanceKey)
+	log.Debugf("ChangeMasterCredentials: will attempt changing master credentials on %+v", *instanceKey)
 
 	if *config.RuntimeCLIFlags.Noop {
 		return instance, fmt.Errorf("noop: aborting CHANGE MASTER TO operation on %+v; signalling error but nothing went wrong.", *instanceKey)
 	}
-	_, err = ExecInstanceNoPrepare(instanceKey, fmt.Sprintf("change master to master_user='%s', master_password='%s'",
-		masterUser, masterPassword))
+	topologyDB, err := db.OpenTopology(instanceKey.Hostname, instanceKey.Port)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+	// Not via ExecInstanceNoPrepare, which logs errors as they are: a MySQL error may quote the statement
+	if _, err = topologyDB.Exec(replicationChannelStatement(instance, instance.ReplicationChannel, changeMasterStatement)); err != nil {
+		return instance, log.Errorf("ChangeMasterCredentials: failed changing master credentials on %+v: %s", *instanceKey, redactSecret(err.Error(), masterPassword))
+	}
+
+	log.Infof("ChangeMasterCredentials: Changed master credentials on %+v", *instanceKey)
+
+	instance, err = ReadTopologyInstance(instanceKey)
+	return instance, err
+}
+
+// ChangeMasterSSL issues a CHANGE MASTER TO... MASTER_SSL=... on the replication channel given instance is presented by
+func ChangeMasterSSL(instanceKey *InstanceKey, masterSSL *MasterSSLSettings) (*Instance, error) {
+	instance, err := ReadTopologyInstance(instanceKey)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+
+	if instance.SlaveRunning() {
+		return instance, fmt.Errorf("ChangeMasterSSL: Cannot change master TLS settings on: %+v because slave is running", *instanceKey)
+	}
+	log.Debugf("ChangeMasterSSL: will attempt changing master TLS settings on %+v", *instanceKey)
 
+	if *config.RuntimeCLIFlags.Noop {
+		return instance, fmt.Errorf("noop: aborting CHANGE MASTER TO operation on %+v; signalling error but nothing went wrong.", *instanceKey)
+	}
+	_, err = ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, instance.ReplicationChannel,
+		"change master to "+strings.Join(masterSSL.changeMasterOptions(), ", ")))
 	if err != nil {
 		return instance, log.Errore(err)
 	}
 
-	log.Infof("ChangeMasterTo: Changed master credentials on %+v", *instanceKey)
+	log.Infof("ChangeMasterSSL: Changed master TLS settings on %+v", *instanceKey)
 
 	instance, err = ReadTopologyInstance(instanceKey)
 	return instance, err
 }
 
-// ChangeMasterTo changes the given instance's master according to given input.
+// ChangeMasterTo changes the given instance's master according to given input. On a multi-source instance, the
+// replication channel the instance is presented by is changed.
 func ChangeMasterTo(instanceKey *InstanceKey, masterKey *InstanceKey, masterBinlogCoordinates *BinlogCoordinates, skipUnresolve bool, gtidHint OperationGTIDHint) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
 	if err != nil {
 		return instance, log.Errore(err)
 	}
+	return changeMasterToChannel(instance, instance.ReplicationChannel, masterKey, masterBinlogCoordinates, skipUnresolve, gtidHint)
+}
+
+// ChangeMasterToChannel changes the master of a given replication channel on the given instance
+func ChangeMasterToChannel(instanceKey *InstanceKey, channel string, masterKey *InstanceKey, masterBinlogCoordinates *BinlogCoordinates, skipUnresolve bool, gtidHint OperationGTIDHint) (*Instance, error) {
+	instance, err := ReadTopologyInstance(instanceKey)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+	return changeMasterToChannel(instance, channel, masterKey, masterBinlogCoordinates, skipUnresolve, gtidHint)
+}
+
+func changeMasterToChannel(instance *Instance, channel string, masterKey *InstanceKey, masterBinlogCoordinates *BinlogCoordinates, skipUnresolve bool, gtidHint OperationGTIDHint) (*Instance, error) {
+	instanceKey := &instance.Key
+	if otherChannel := instance.GetReplicationChannelByMaster(masterKey); otherChannel != nil && otherChannel.Name != channel {
+		return instance, fmt.Errorf("ChangeMasterTo: %+v already replicates from %+v via channel '%s'", *instanceKey, *masterKey, otherChannel.Name)
+	}
+	instance, err := instance.WithReplicationChannel(channel)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
 
 	if instance.SlaveRunning() {
 		return instance, fmt.Errorf("ChangeMasterTo: Cannot change master on: %+v because slave is running", *instanceKey)
@@ -540,51 +783,62 @@ func ChangeMasterTo(instanceKey *InstanceKey, masterKey *InstanceKey, masterBinl
 	originalMasterKey := instance.MasterKey
 	originalExecBinlogCoordinates := instance.ExecBinlogCoordinates
 
+	// TLS settings are carried across the change. An instance which is not a slave (e.g. a master turned co-master,
+	// or a demoted master) takes on the TLS settings the new master's existing slaves replicate with, if any.
+	masterSSL := instance.MasterSSL
+	if !instance.IsSlave() {
+		if masterSlaves, err := ReadSlaveInstances(masterKey); err == nil {
+			masterSSL = slavesMasterSSLSettings(masterSlaves, instanceKey)
+		}
+	}
+
+	var changeMasterStatement string
 	changedViaGTID := false
 	if instance.UsingMariaDBGTID && gtidHint != GTIDHintDeny {
-		// MariaDB has a bug: a CHANGE MASTER TO statement does not work properly with prepared statement... :P
-		// See https://mariadb.atlassian.net/browse/MDEV-7640
-		// This is the reason for ExecInstanceNoPrepare
 		// Keep on using GTID
-		_, err = ExecInstanceNoPrepare(instanceKey, fmt.Sprintf("change master to master_host='%s', master_port=%d",
-			changeToMasterKey.Hostname, changeToMasterKey.Port))
+		changeMasterStatement = fmt.Sprintf("change master to master_host='%s', master_port=%d",
+			changeToMasterKey.Hostname, changeToMasterKey.Port)
 		changedViaGTID = true
 	} else if instance.UsingMariaDBGTID && gtidHint == GTIDHintDeny {
 		// Make sure to not use GTID
-		_, err = ExecInstanceNoPrepare(instanceKey, fmt.Sprintf("change master to master_host='%s', master_port=%d, master_log_file='%s', master_log_pos=%d, master_use_gtid=no",
-			changeToMasterKey.Hostname, changeToMasterKey.Port, masterBinlogCoordinates.LogFile, masterBinlogCoordinates.LogPos))
+		changeMasterStatement = fmt.Sprintf("change master to master_host='%s', master_port=%d, master_log_file='%s', master_log_pos=%d, master_use_gtid=no",
+			changeToMasterKey.Hostname, changeToMasterKey.Port, masterBinlogCoordinates.LogFile, masterBinlogCoordinates.LogPos)
 	} else if instance.IsMariaDB() && gtidHint == GTIDHintForce {
 		// Is MariaDB; not using GTID, turn into GTID
-		_, err = ExecInstanceNoPrepare(instanceKey, fmt.Sprintf("change master to master_host='%s', master_port=%d, master_use_gtid=slave_pos",
-			changeToMasterKey.Hostname, changeToMasterKey.Port))
+		changeMasterStatement = fmt.Sprintf("change master to master_host='%s', master_port=%d, master_use_gtid=slave_pos",
+			changeToMasterKey.Hostname, changeToMasterKey.Port)
 		changedViaGTID = true
 	} else if instance.UsingOracleGTID && gtidHint != GTIDHintDeny {
 		// Is Oracle; already uses GTID; keep using it.
-		_, err = ExecInstanceNoPrepare(instanceKey, fmt.Sprintf("change master to master_host='%s', master_port=%d",
-			changeToMasterKey.Hostname, changeToMasterKey.Port))
+		changeMasterStatement = fmt.Sprintf("change master to master_host='%s', master_port=%d",
+			changeToMasterKey.Hostname, changeToMasterKey.Port)
 		changedViaGTID = true
 	} else if instance.UsingOracleGTID && gtidHint == GTIDHintDeny {
 		// Is Oracle; already uses GTID
-		_, err = ExecInstanceNoPrepare(instanceKey, fmt.Sprintf("change master to master_host='%s', master_port=%d, master_log_file='%s', master_log_pos=%d, master_auto_position=0",
-			changeToMasterKey.Hostname, changeToMasterKey.Port, masterBinlogCoordinates.LogFile, masterBinlogCoordinates.LogPos))
+		changeMasterStatement = fmt.Sprintf("change master to master_host='%s', master_port=%d, master_log_file='%s', master_log_pos=%d, master_auto_position=0",
+			changeToMasterKey.Hostname, changeToMasterKey.Port, masterBinlogCoordinates.LogFile, masterBinlogCoordinates.LogPos)
 	} else if instance.SupportsOracleGTID && gtidHint == GTIDHintForce {
 		// Is Oracle; not using GTID right now; turn into GTID
-		_, err = ExecInstanceNoPrepare(instanceKey, fmt.Sprintf("change master to master_host='%s', master_port=%d, master_auto_position=1",
-			changeToMasterKey.Hostname, changeToMasterKey.Port))
+		changeMasterStatement = fmt.Sprintf("change master to master_host='%s', master_port=%d, master_auto_position=1",
+			changeToMasterKey.Hostname, changeToMasterKey.Port)
 		changedViaGTID = true
 	} else {
 		// Normal binlog file:pos
-		_, err = ExecInstanceNoPrepare(instanceKey, fmt.Sprintf("change master to master_host='%s', master_port=%d, master_log_file='%s', master_log_pos=%d",
-			changeToMasterKey.Hostname, changeToMasterKey.Port, masterBinlogCoordinates.LogFile, masterBinlogCoordinates.LogPos))
+		changeMasterStatement = fmt.Sprintf("change master to master_host='%s', master_port=%d, master_log_file='%s', master_log_pos=%d",
+			changeToMasterKey.Hostname, changeToMasterKey.Port, masterBinlogCoordinates.LogFile, masterBinlogCoordinates.LogPos)
 	}
+	// MariaDB has a bug: a CHANGE MASTER TO statement does not work properly with prepared statement... :P
+	// See https://mariadb.atlassian.net/browse/MDEV-7640
+	// This is the reason for ExecInstanceNoPrepare
+	_, err = ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, channel, changeMasterStatement+masterSSL.ChangeMasterClause()))
 	if err != nil {
 		return instance, log.Errore(err)
 	}
 	WriteMasterPositionEquivalence(&originalMasterKey, &originalExecBinlogCoordinates, changeToMasterKey, masterBinlogCoordinates)
 
-	log.Infof("ChangeMasterTo: Changed master on %+v to: %+v, %+v. GTID: %+v", *instanceKey, masterKey, masterBinlogCoordinates, changedViaGTID)
+	log.Infof("ChangeMasterTo: Changed master on %+v, channel: '%s', to: %+v, %+v. GTID: %+v", *instanceKey, channel, masterKey, masterBinlogCoordinates, changedViaGTID)
 
-	instance, err = ReadTopologyInstance(instanceKey)
+	instance, err = readTopologyInstanceChannel(instanceKey, channel)
 	return instance, err
 }
 
@@ -631,11 +885,11 @@ func ResetSlave(instanceKey *InstanceKey) (*Instance, error) {
 	// and only resets till after next restart. This leads to orchestrator still thinking the instance replicates
 	// from old host. We therefore forcibly modify the hostname.
 	// RESET SLAVE ALL command solves this, but only as of 5.6.3
-	_, err = ExecInstanceNoPrepare(instanceKey, `change master to master_host='_'`)
+	_, err = ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, instance.ReplicationChannel, `change master to master_host='_'`))
 	if err != nil {
 		return instance, log.Errore(err)
 	}
-	_, err = ExecInstanceNoPrepare(instanceKey, `reset slave /*!50603 all */`)
+	_, err = ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, instance.ReplicationChannel, `reset slave /*!50603 all */`))
 	if err != nil {
 		return instance, log.Errore(err)
 	}
@@ -670,6 +924,21 @@ func ResetMaster(instanceKey *InstanceKey) (*Instance, error) {
 	return instance, err
 }
 
+// SetGTIDPurged sets gtid_purged on given instance, which must have an empty gtid_executed (e.g. following RESET MASTER)
+func SetGTIDPurged(instanceKey *InstanceKey, gtidPurged string) (*Instance, error) {
+	instance, err := ReadTopologyInstance(instanceKey)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+	if err := setGTIDPurged(instance, gtidPurged); err != nil {
+		return instance, log.Errore(err)
+	}
+	log.Infof("Set gtid_purged on %+v to %s", *instanceKey, gtidPurged)
+
+	instance, err = ReadTopologyInstance(instanceKey)
+	return instance, err
+}
+
 // skipQueryClassic skips a query in normal binlog file:pos replication
 func setGTIDPurged(instance *Instance, gtidPurged string) error {
 	if *config.RuntimeCLIFlags.Noop {
@@ -767,7 +1036,7 @@ func DetachSlave(instanceKey *InstanceKey) (*Instance, error) {
 
 	detachedCoordinates := BinlogCoordinates{LogFile: fmt.Sprintf("//%s:%d", instance.ExecBinlogCoordinates.LogFile, instance.ExecBinlogCoordinates.LogPos), LogPos: instance.ExecBinlogCoordinates.LogPos}
 	// Encode the current coordinates within the log file name, in such way that replication is broken, but info can still be resurrected
-	_, err = ExecInstanceNoPrepare(instanceKey, fmt.Sprintf(`change master to master_log_file='%s', master_log_pos=%d`, detachedCoordinates.LogFile, detachedCoordinates.LogPos))
+	_, err = ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, instance.ReplicationChannel, fmt.Sprintf(`change master to master_log_file='%s', master_log_pos=%d`, detachedCoordinates.LogFile, detachedCoordinates.LogPos)))
 	if err != nil {
 		return instance, log.Errore(err)
 	}
@@ -799,7 +1068,7 @@ func ReattachSlave(instanceKey *InstanceKey) (*Instance, error) {
 		return instance, fmt.Errorf("noop: aborting reattach-slave operation on %+v; signalling error but nothing went wrong.", *instanceKey)
 	}
 
-	_, err = ExecInstanceNoPrepare(instanceKey, fmt.Sprintf(`change master to master_log_file='%s', master_log_pos=%s`, detachedLogFile, detachedLogPos))
+	_, err = ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, instance.ReplicationChannel, fmt.Sprintf(`change master to master_log_file='%s', master_log_pos=%s`, detachedLogFile, detachedLogPos)))
 	if err != nil {
 		return instance, log.Errore(err)
 	}
@@ -889,6 +1158,81 @@ func SetReadOnly(instanceKey *InstanceKey, readOnly bool) (*Instance, error) {
 	return instance, err
 }
 
+// readOtherClusterWriters returns reachable writeable masters, other than given instance, of given instance's cluster,
+// or of any cluster sharing its suggested alias (as with a master detached by a failover)
+func readOtherClusterWriters(instance *Instance) ([](*Instance), error) {
+	condition := `
+		read_only = 0
+		and (replication_depth = 0 or is_co_master)
+		and is_last_check_valid = 1
+		and not (hostname = ? and port = ?)
+		and (
+			cluster_name = ?
+			or (suggested_cluster_alias != '' and suggested_cluster_alias = ?)
+		)
+	`
+	return readInstancesByCondition(condition, sqlutils.Args(instance.Key.Hostname, instance.Key.Port, instance.ClusterName, instance.SuggestedClusterAlias), "")
+}
+
+// FixReadOnly sets the read_only flag of an instance according to its role: a master (not configured to replicate
+// from anywhere) is made writeable, and a slave is made read-only. Co-masters and binlog servers are left untouched,
+// as their intended role cannot be deduced. The change is made while holding the cluster lock and while the
+// instance is under maintenance. A master is not made writeable while its cluster is being recovered, nor while
+// another writer exists in its cluster.
+func FixReadOnly(instanceKey *InstanceKey) (*Instance, error) {
+	instance, err := ReadTopologyInstance(instanceKey)
+	if err != nil {
+		return instance, log.Errore(err)
+	}
+	if instance.IsBinlogServer() {
+		return instance, fmt.Errorf("fix-read-only: %+v is a binlog server", *instanceKey)
+	}
+	if instance.IsCoMaster {
+		return instance, fmt.Errorf("fix-read-only: %+v is a co-master; cannot deduce whether it should be writeable. Use set-read-only or set-writeable", *instanceKey)
+	}
+	readOnly := instance.IsSlave()
+	if instance.ReadOnly == readOnly {
+		log.Infof("fix-read-only: %+v already has read_only=%t", *instanceKey, readOnly)
+		return instance, nil
+	}
+
+	lockToken, err := BeginClusterLockByInstanceKey(instanceKey, GetMaintenanceOwner(), "fix-read-only")
+	if err != nil {
+		return instance, err
+	}
+	defer EndClusterLock(lockToken)
+
+	if !readOnly {
+		clustersInRecovery, instancesInRecovery, err := readClustersInRecovery()
+		if err != nil {
+			return instance, log.Errore(err)
+		}
+		if clustersInRecovery[instance.ClusterName] || instancesInRecovery[*instanceKey] {
+			return instance, fmt.Errorf("fix-read-only: cluster %s of %+v is being recovered; will not make it writeable", instance.ClusterName, *instanceKey)
+		}
+		otherWriters, err := readOtherClusterWriters(instance)
+		if err != nil {
+			return instance, log.Errore(err)
+		}
+		if len(otherWriters) > 0 {
+			return instance, fmt.Errorf("fix-read-only: %+v is writeable in the cluster of %+v; will not make it writeable", otherWriters[0].Key, *instanceKey)
+		}
+	}
+
+	if maintenanceToken, merr := BeginMaintenance(instanceKey, GetMaintenanceOwner(), fmt.Sprintf("fix read_only=%t", readOnly)); merr != nil {
+		return instance, fmt.Errorf("Cannot begin maintenance on %+v", *instanceKey)
+	} else {
+		defer EndMaintenance(maintenanceToken)
+	}
+
+	instance, err = SetReadOnly(instanceKey, readOnly)
+	if err != nil {
+		return instance, err
+	}
+	AuditOperation("fix-read-only", instanceKey, fmt.Sprintf("set read_only=%t", readOnly))
+	return instance, nil
+}
+
 // KillQuery stops replication on a given instance
 func KillQuery(instanceKey *InstanceKey, process int64) (*Instance, error) {
 	instance, err := ReadTopologyInstance(instanceKey)
@@ -4,6 +4,7 @@ import (
 	"github.com/outbrain/golib/log"
 	test "github.com/outbrain/golib/tests"
 	"github.com/outbrain/orchestrator/go/config"
+	"strings"
 	"testing"
 )
 
@@ -366,3 +367,123 @@ func TestChooseCandidateSlavePriorityBinlogFormatRowOverrides(t *testing.T) {
 	test.S(t).ExpectEquals(len(laterSlaves), 3)
 	test.S(t).ExpectEquals(len(cannotReplicateSlaves), 2)
 }
+
+func generateTestTopologyTree() *TopologyTree {
+	instances, instancesMap := generateTestInstances()
+	for _, instance := range instances {
+		instance.MasterKey = i710Key
+		instance.ReadBinlogCoordinates = instance.ExecBinlogCoordinates
+		instance.IsLastCheckValid = true
+		instance.IsRecentlyChecked = true
+		instance.Slave_SQL_Running = true
+		instance.Slave_IO_Running = true
+	}
+	instancesMap[i710Key.StringCode()].MasterKey = InstanceKey{}
+	instancesMap[i820Key.StringCode()].MasterKey = i810Key
+	instancesMap[i830Key.StringCode()].Slave_IO_Running = false
+	instancesMap[i830Key.StringCode()].IsDowntimed = true
+
+	replicationMap, masterInstances := getReplicationMap(instances)
+	tree := &TopologyTree{ClusterName: "i710:3306"}
+	for _, masterInstance := range masterInstances {
+		tree.Roots = append(tree.Roots, newTopologyNode(masterInstance, replicationMap, true))
+	}
+	return tree
+}
+
+func TestGetTopologyTree(t *testing.T) {
+	tree := generateTestTopologyTree()
+	test.S(t).ExpectEquals(len(tree.Roots), 1)
+	root := tree.Roots[0]
+	test.S(t).ExpectEquals(root.Key, i710Key)
+	test.S(t).ExpectEquals(len(root.Problems), 0)
+	test.S(t).ExpectEquals(len(root.Slaves), 4)
+	for _, slave := range root.Slaves {
+		switch slave.Key {
+		case i810Key:
+			test.S(t).ExpectEquals(len(slave.Slaves), 1)
+			test.S(t).ExpectEquals(slave.Slaves[0].Key, i820Key)
+		case i830Key:
+			test.S(t).ExpectTrue(slave.IsDowntimed)
+			test.S(t).ExpectEquals(len(slave.Problems), 1)
+		default:
+			test.S(t).ExpectEquals(len(slave.Slaves), 0)
+			test.S(t).ExpectEquals(len(slave.Problems), 0)
+		}
+	}
+}
+
+func TestGetReplicationMapReplicationGroup(t *testing.T) {
+	instances, instancesMap := generateTestInstances()
+	members := []ReplicationGroupMember{}
+	for _, instance := range instances {
+		members = append(members, ReplicationGroupMember{Key: instance.Key, State: ReplicationGroupMemberStateOnline, Role: ReplicationGroupMemberRoleSecondary})
+	}
+	for _, instance := range instances {
+		instance.MasterKey = InstanceKey{}
+		instance.ReplicationGroupName = "8a94f357-aab4-11df-86ab-c80aa9429562"
+		instance.ReplicationGroupIsSinglePrimary = true
+		instance.ReplicationGroupMemberState = ReplicationGroupMemberStateOnline
+		instance.ReplicationGroupMemberRole = ReplicationGroupMemberRoleSecondary
+		instance.ReplicationGroupMembers = members
+		instance.ReplicationGroupPrimaryKey = i710Key
+	}
+	instancesMap[i710Key.StringCode()].ReplicationGroupMemberRole = ReplicationGroupMemberRolePrimary
+
+	replicationMap, masterInstances := getReplicationMap(instances)
+	test.S(t).ExpectEquals(len(masterInstances), 1)
+	test.S(t).ExpectEquals(masterInstances[0].Key, i710Key)
+	test.S(t).ExpectEquals(len(replicationMap[masterInstances[0]]), len(instances)-1)
+}
+
+func TestGetReplicationMapGalera(t *testing.T) {
+	instances, _ := generateTestInstances()
+	for _, instance := range instances {
+		instance.MasterKey = InstanceKey{}
+		instance.GaleraClusterStateUUID = "e2b5b5a9-8f5c-11e6-9b7a-0242ac110002"
+		instance.GaleraClusterStatus = GaleraClusterStatusPrimary
+		instance.GaleraLocalStateComment = GaleraLocalStateCommentSynced
+	}
+
+	replicationMap, masterInstances := getReplicationMap(instances)
+	test.S(t).ExpectEquals(len(masterInstances), 1)
+	test.S(t).ExpectEquals(masterInstances[0].Key, i710Key)
+	test.S(t).ExpectEquals(len(replicationMap[masterInstances[0]]), len(instances)-1)
+}
+
+func TestTopologyTreeToDot(t *testing.T) {
+	dot := generateTestTopologyTree().ToDot()
+	test.S(t).ExpectTrue(strings.HasPrefix(dot, `digraph "i710:3306" {`))
+	test.S(t).ExpectTrue(strings.Contains(dot, `"i810:3306" -> "i820:3306";`))
+	test.S(t).ExpectTrue(strings.Contains(dot, `color=red, style=dashed`))
+}
+
+func TestTopologyTreeToMermaid(t *testing.T) {
+	mermaid := generateTestTopologyTree().ToMermaid()
+	test.S(t).ExpectTrue(strings.HasPrefix(mermaid, "graph TD\n  n0[\"i710:3306<br/>"))
+	test.S(t).ExpectTrue(strings.Contains(mermaid, "n0 --> n1"))
+}
+
+func TestNewPseudoGTIDHint(t *testing.T) {
+	hint := newPseudoGTIDHint()
+	test.S(t).ExpectTrue(strings.HasPrefix(hint, "_asc:"))
+	for i := 0; i < 100; i++ {
+		nextHint := newPseudoGTIDHint()
+		test.S(t).ExpectTrue(nextHint > hint)
+		hint = nextHint
+	}
+}
+
+func TestGetPseudoGTIDLaggingSlaves(t *testing.T) {
+	instances, instancesMap := generateTestInstances()
+	masterCoordinates := BinlogCoordinates{LogFile: "mysql.000007", LogPos: 25}
+	laggingSlaves := getPseudoGTIDLaggingSlaves(instances, &masterCoordinates)
+	test.S(t).ExpectEquals(len(*laggingSlaves), 2)
+	test.S(t).ExpectTrue(laggingSlaves.HasKey(i710Key))
+	test.S(t).ExpectTrue(laggingSlaves.HasKey(i720Key))
+
+	instancesMap[i720Key.StringCode()].IsDowntimed = true
+	laggingSlaves = getPseudoGTIDLaggingSlaves(instances, &masterCoordinates)
+	test.S(t).ExpectEquals(len(*laggingSlaves), 1)
+	test.S(t).ExpectTrue(laggingSlaves.HasKey(i710Key))
+}
@@ -59,6 +59,42 @@ func writePoolInstances(pool string, instanceKeys [](*InstanceKey)) error {
 	return ExecDBWriteFunc(writeFunc)
 }
 
+// ReadInstancePools returns the pools given instance is registered in
+func ReadInstancePools(instanceKey *InstanceKey) (pools []string, err error) {
+	query := `
+		select
+			pool
+		from
+			database_instance_pool
+		where
+			hostname = ?
+			and port = ?
+		order by
+			pool
+		`
+	err = db.QueryOrchestrator(query, sqlutils.Args(instanceKey.Hostname, instanceKey.Port), func(m sqlutils.RowMap) error {
+		pools = append(pools, m.GetString("pool"))
+		return nil
+	})
+	return pools, log.Errore(err)
+}
+
+// RegisterPoolInstance adds a single instance to a pool, keeping the pool's other instances
+func RegisterPoolInstance(pool string, instanceKey *InstanceKey) error {
+	writeFunc := func() error {
+		_, err := db.ExecOrchestrator(`
+			replace into database_instance_pool (
+					hostname, port, pool, registered_at
+				) values (
+					?, ?, ?, now()
+				)
+			`, instanceKey.Hostname, instanceKey.Port, pool,
+		)
+		return log.Errore(err)
+	}
+	return ExecDBWriteFunc(writeFunc)
+}
+
 // ReadClusterPoolInstances reads cluster-pool-instance associationsfor given cluster and pool
 func ReadClusterPoolInstances(clusterName string, pool string) (result [](*ClusterPoolInstance), err error) {
 	args := sqlutils.Args()
@@ -78,6 +78,58 @@ func WriteLongRunningProcesses(instanceKey *InstanceKey, processes []Process) er
 	return ExecDBWriteFunc(writeFunc)
 }
 
+// ReadInstanceLongRunningProcesses returns the long running processes of given instance, as last polled
+func ReadInstanceLongRunningProcesses(instanceKey *InstanceKey) ([]Process, error) {
+	longRunningProcesses := []Process{}
+	query := `
+		select
+			hostname,
+			port,
+			process_id,
+			process_started_at,
+			process_user,
+			process_host,
+			process_db,
+			process_command,
+			process_time_seconds,
+			process_state,
+			process_info
+		from
+			database_instance_long_running_queries
+		where
+			hostname = ?
+			and port = ?
+		order by
+			process_time_seconds desc
+		`
+	err := db.QueryOrchestrator(query, sqlutils.Args(instanceKey.Hostname, instanceKey.Port), func(m sqlutils.RowMap) error {
+		longRunningProcesses = append(longRunningProcesses, readProcessRow(m))
+		return nil
+	})
+
+	if err != nil {
+		log.Errore(err)
+	}
+	return longRunningProcesses, err
+}
+
+// readProcessRow reads a single row of database_instance_long_running_queries
+func readProcessRow(m sqlutils.RowMap) Process {
+	process := Process{}
+	process.InstanceHostname = m.GetString("hostname")
+	process.InstancePort = m.GetInt("port")
+	process.Id = m.GetInt64("process_id")
+	process.User = m.GetString("process_user")
+	process.Host = m.GetString("process_host")
+	process.Db = m.GetString("process_db")
+	process.Command = m.GetString("process_command")
+	process.Time = m.GetInt64("process_time_seconds")
+	process.State = m.GetString("process_state")
+	process.Info = m.GetString("process_info")
+	process.StartedAt = m.GetString("process_started_at")
+	return process
+}
+
 // ReadLongRunningProcesses returns the list of current known long running processes of all instances
 func ReadLongRunningProcesses(filter string) ([]Process, error) {
 	longRunningProcesses := []Process{}
@@ -115,20 +167,7 @@ func ReadLongRunningProcesses(filter string) ([]Process, error) {
 		`
 	args := sqlutils.Args(filter, filter, filter, filter, filter, filter, filter)
 	err := db.QueryOrchestrator(query, args, func(m sqlutils.RowMap) error {
-		process := Process{}
-		process.InstanceHostname = m.GetString("hostname")
-		process.InstancePort = m.GetInt("port")
-		process.Id = m.GetInt64("process_id")
-		process.User = m.GetString("process_user")
-		process.Host = m.GetString("process_host")
-		process.Db = m.GetString("process_db")
-		process.Command = m.GetString("process_command")
-		process.Time = m.GetInt64("process_time_seconds")
-		process.State = m.GetString("process_state")
-		process.Info = m.GetString("process_info")
-		process.StartedAt = m.GetString("process_started_at")
-
-		longRunningProcesses = append(longRunningProcesses, process)
+		longRunningProcesses = append(longRunningProcesses, readProcessRow(m))
 		return nil
 	})
 
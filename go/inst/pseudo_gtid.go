@@ -0,0 +1,49 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"time"
+)
+
+// PseudoGTIDInjectionStatus describes the state of automated Pseudo-GTID injection on a cluster master:
+// the last successful injection, recent failures, and whether the master's slaves are seen to receive the injected entries.
+type PseudoGTIDInjectionStatus struct {
+	Key                   InstanceKey
+	ClusterName           string
+	LastAttemptTimestamp  string
+	LastInjectedTimestamp string
+	LastInjectedHint      string
+	ConsecutiveFailures   uint
+	LastError             string
+	LastVerifiedTimestamp string
+	LastVerifiedHint      string
+	CountLaggingSlaves    uint
+	LaggingSlaves         InstanceKeyMap
+}
+
+// IsHealthy returns true when the last injection attempt succeeded and all slaves were seen to receive injected entries
+func (this *PseudoGTIDInjectionStatus) IsHealthy() bool {
+	return this.ConsecutiveFailures == 0 && this.CountLaggingSlaves == 0
+}
+
+// pseudoGTIDInjection is an injected entry, pending verification on the master's slaves
+type pseudoGTIDInjection struct {
+	Hint              string
+	MasterCoordinates BinlogCoordinates
+	InjectedAt        time.Time
+}
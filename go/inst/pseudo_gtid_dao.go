@@ -0,0 +1,286 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/golib/sqlutils"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/db"
+	"github.com/outbrain/orchestrator/go/process"
+	"github.com/rcrowley/go-metrics"
+)
+
+var pseudoGTIDInjectionCounter uint64 = 0
+
+// pendingPseudoGTIDVerifications holds, per master, an injected entry which is yet to be verified on the master's slaves
+var pendingPseudoGTIDVerifications = make(map[InstanceKey]pseudoGTIDInjection)
+var pendingPseudoGTIDVerificationsMutex = &sync.Mutex{}
+
+var pseudoGTIDInjectAttemptCounter = metrics.NewCounter()
+var pseudoGTIDInjectFailCounter = metrics.NewCounter()
+var pseudoGTIDVerifyLaggingSlavesCounter = metrics.NewCounter()
+
+func init() {
+	metrics.Register("pseudo_gtid.inject.attempt", pseudoGTIDInjectAttemptCounter)
+	metrics.Register("pseudo_gtid.inject.fail", pseudoGTIDInjectFailCounter)
+	metrics.Register("pseudo_gtid.verify.lagging_slaves", pseudoGTIDVerifyLaggingSlavesCounter)
+}
+
+// newPseudoGTIDHint generates a unique, monotonically increasing Pseudo-GTID text
+func newPseudoGTIDHint() string {
+	counter := atomic.AddUint64(&pseudoGTIDInjectionCounter, 1)
+	return fmt.Sprintf("_asc:%.8x:%.16x:%s", time.Now().Unix(), counter, process.ProcessToken.Hash[0:8])
+}
+
+// InjectPseudoGTID injects a Pseudo-GTID entry on given instance, matching the PseudoGTIDPattern implied
+// by AutoPseudoGTID. It returns the injected hint and the instance's binary log coordinates following the injection.
+func InjectPseudoGTID(instanceKey *InstanceKey) (hint string, coordinates *BinlogCoordinates, err error) {
+	hint = newPseudoGTIDHint()
+	query := fmt.Sprintf("drop view if exists `%s`.`%s`", config.Config.PseudoGTIDSchema, hint)
+	if _, err = ExecInstanceNoPrepare(instanceKey, query); err != nil {
+		return hint, nil, log.Errore(err)
+	}
+	coordinates, err = readMasterStatusCoordinates(instanceKey)
+	return hint, coordinates, err
+}
+
+// readMasterStatusCoordinates reads the current binary log coordinates of given instance
+func readMasterStatusCoordinates(instanceKey *InstanceKey) (*BinlogCoordinates, error) {
+	db, err := db.OpenTopology(instanceKey.Hostname, instanceKey.Port)
+	if err != nil {
+		return nil, log.Errore(err)
+	}
+	coordinates := &BinlogCoordinates{Type: BinaryLog}
+	err = sqlutils.QueryRowsMap(db, "show master status", func(m sqlutils.RowMap) error {
+		coordinates.LogFile = m.GetString("File")
+		coordinates.LogPos = m.GetInt64("Position")
+		return nil
+	})
+	if err != nil {
+		return nil, log.Errore(err)
+	}
+	if coordinates.LogFile == "" {
+		return nil, log.Errorf("No binary log coordinates found on %+v", *instanceKey)
+	}
+	return coordinates, nil
+}
+
+// writePseudoGTIDInjectionAttempt records the outcome of an injection attempt on given master
+func writePseudoGTIDInjectionAttempt(master *Instance, hint string, injectionErr error) error {
+	if injectionErr == nil {
+		_, err := db.ExecOrchestrator(`
+				insert into pseudo_gtid_injection (
+					hostname, port, cluster_name, last_attempt_timestamp, last_injected_timestamp, last_injected_hint, consecutive_failures, last_error, lagging_slaves
+				) values (
+					?, ?, ?, NOW(), NOW(), ?, 0, '', ''
+				) on duplicate key update
+					cluster_name=values(cluster_name),
+					last_attempt_timestamp=values(last_attempt_timestamp),
+					last_injected_timestamp=values(last_injected_timestamp),
+					last_injected_hint=values(last_injected_hint),
+					consecutive_failures=0
+				`, master.Key.Hostname, master.Key.Port, master.ClusterName, hint,
+		)
+		return log.Errore(err)
+	}
+	_, err := db.ExecOrchestrator(`
+			insert into pseudo_gtid_injection (
+				hostname, port, cluster_name, last_attempt_timestamp, consecutive_failures, last_error, lagging_slaves
+			) values (
+				?, ?, ?, NOW(), 1, ?, ''
+			) on duplicate key update
+				cluster_name=values(cluster_name),
+				last_attempt_timestamp=values(last_attempt_timestamp),
+				consecutive_failures=consecutive_failures+1,
+				last_error=values(last_error)
+			`, master.Key.Hostname, master.Key.Port, master.ClusterName, injectionErr.Error(),
+	)
+	return log.Errore(err)
+}
+
+// writePseudoGTIDVerification records the outcome of verifying an injected entry on given master's slaves
+func writePseudoGTIDVerification(masterKey *InstanceKey, hint string, laggingSlaves *InstanceKeyMap) error {
+	_, err := db.ExecOrchestrator(`
+			update pseudo_gtid_injection set
+				last_verified_timestamp=NOW(),
+				last_verified_hint=?,
+				count_lagging_slaves=?,
+				lagging_slaves=?
+			where
+				hostname=? and port=?
+			`, hint, len(*laggingSlaves), laggingSlaves.ToCommaDelimitedList(), masterKey.Hostname, masterKey.Port,
+	)
+	return log.Errore(err)
+}
+
+// getPseudoGTIDLaggingSlaves returns the (non downtimed) slaves of given master which have not executed
+// the master's binary log up to given coordinates
+func getPseudoGTIDLaggingSlaves(slaves [](*Instance), masterCoordinates *BinlogCoordinates) *InstanceKeyMap {
+	laggingSlaves := NewInstanceKeyMap()
+	for _, slave := range slaves {
+		if slave.IsDowntimed {
+			continue
+		}
+		if slave.ExecBinlogCoordinates.SmallerThan(masterCoordinates) {
+			laggingSlaves.AddKey(slave.Key)
+		}
+	}
+	return laggingSlaves
+}
+
+// verifyPseudoGTIDInjection checks the master's pending injected entry, once it is old enough,
+// against the master's slaves. The given, newly injected entry then becomes pending.
+func verifyPseudoGTIDInjection(masterKey *InstanceKey, injection pseudoGTIDInjection) error {
+	pendingPseudoGTIDVerificationsMutex.Lock()
+	pending, found := pendingPseudoGTIDVerifications[*masterKey]
+	if found && time.Since(pending.InjectedAt) < time.Duration(config.Config.AutoPseudoGTIDVerificationSeconds)*time.Second {
+		pendingPseudoGTIDVerificationsMutex.Unlock()
+		return nil
+	}
+	pendingPseudoGTIDVerifications[*masterKey] = injection
+	pendingPseudoGTIDVerificationsMutex.Unlock()
+
+	if !found {
+		return nil
+	}
+	slaves, err := ReadSlaveInstances(masterKey)
+	if err != nil {
+		return err
+	}
+	laggingSlaves := getPseudoGTIDLaggingSlaves(slaves, &pending.MasterCoordinates)
+	if len(*laggingSlaves) > 0 {
+		pseudoGTIDVerifyLaggingSlavesCounter.Inc(int64(len(*laggingSlaves)))
+		log.Warningf("Pseudo-GTID entry %s injected on %+v at %+v has not been executed by slaves: %s", pending.Hint, *masterKey, pending.MasterCoordinates, laggingSlaves.ToCommaDelimitedList())
+	}
+	return writePseudoGTIDVerification(masterKey, pending.Hint, laggingSlaves)
+}
+
+// injectPseudoGTIDOnMaster injects a Pseudo-GTID entry on given master, records the outcome and verifies previous injections
+func injectPseudoGTIDOnMaster(master *Instance) error {
+	pseudoGTIDInjectAttemptCounter.Inc(1)
+	injectedAt := time.Now()
+	hint, coordinates, err := InjectPseudoGTID(&master.Key)
+	if err != nil {
+		pseudoGTIDInjectFailCounter.Inc(1)
+		writePseudoGTIDInjectionAttempt(master, hint, err)
+		return err
+	}
+	if err := writePseudoGTIDInjectionAttempt(master, hint, nil); err != nil {
+		return err
+	}
+	return verifyPseudoGTIDInjection(&master.Key, pseudoGTIDInjection{Hint: hint, MasterCoordinates: *coordinates, InjectedAt: injectedAt})
+}
+
+// InjectPseudoGTIDOnWriters injects a Pseudo-GTID entry on each writeable cluster master.
+// It is expected to run periodically on the elected node when AutoPseudoGTID is enabled.
+func InjectPseudoGTIDOnWriters() error {
+	masters, err := ReadWriteableClustersMasters()
+	if err != nil {
+		return log.Errore(err)
+	}
+	for _, master := range masters {
+		master := master
+		if !master.IsLastCheckValid || !master.LogBinEnabled {
+			continue
+		}
+		go ExecuteOnTopology(func() {
+			injectPseudoGTIDOnMaster(master)
+		})
+	}
+	return nil
+}
+
+// readPseudoGTIDInjectionStatuses reads injection statuses matching given condition
+func readPseudoGTIDInjectionStatuses(whereCondition string, args []interface{}) ([]PseudoGTIDInjectionStatus, error) {
+	res := []PseudoGTIDInjectionStatus{}
+	query := fmt.Sprintf(`
+		select
+			hostname,
+			port,
+			cluster_name,
+			last_attempt_timestamp,
+			last_injected_timestamp,
+			last_injected_hint,
+			consecutive_failures,
+			last_error,
+			last_verified_timestamp,
+			last_verified_hint,
+			count_lagging_slaves,
+			lagging_slaves
+		from
+			pseudo_gtid_injection
+		where
+			1=1
+			%s
+		order by
+			cluster_name, hostname, port
+		`, whereCondition)
+	err := db.QueryOrchestrator(query, args, func(m sqlutils.RowMap) error {
+		status := PseudoGTIDInjectionStatus{LaggingSlaves: *NewInstanceKeyMap()}
+		status.Key.Hostname = m.GetString("hostname")
+		status.Key.Port = m.GetInt("port")
+		status.ClusterName = m.GetString("cluster_name")
+		status.LastAttemptTimestamp = m.GetString("last_attempt_timestamp")
+		status.LastInjectedTimestamp = m.GetString("last_injected_timestamp")
+		status.LastInjectedHint = m.GetString("last_injected_hint")
+		status.ConsecutiveFailures = m.GetUint("consecutive_failures")
+		status.LastError = m.GetString("last_error")
+		status.LastVerifiedTimestamp = m.GetString("last_verified_timestamp")
+		status.LastVerifiedHint = m.GetString("last_verified_hint")
+		status.CountLaggingSlaves = m.GetUint("count_lagging_slaves")
+		if laggingSlaves := m.GetString("lagging_slaves"); laggingSlaves != "" {
+			status.LaggingSlaves.ReadCommaDelimitedList(laggingSlaves)
+		}
+
+		res = append(res, status)
+		return nil
+	})
+
+	if err != nil {
+		log.Errore(err)
+	}
+	return res, err
+}
+
+// ReadPseudoGTIDInjectionStatuses returns injection status of all masters orchestrator injects Pseudo-GTID entries on
+func ReadPseudoGTIDInjectionStatuses() ([]PseudoGTIDInjectionStatus, error) {
+	return readPseudoGTIDInjectionStatuses(``, sqlutils.Args())
+}
+
+// ReadClusterPseudoGTIDInjectionStatuses returns injection status of given cluster's masters
+func ReadClusterPseudoGTIDInjectionStatuses(clusterName string) ([]PseudoGTIDInjectionStatus, error) {
+	return readPseudoGTIDInjectionStatuses(`and cluster_name = ?`, sqlutils.Args(clusterName))
+}
+
+// ExpirePseudoGTIDInjectionStatuses removes status of masters which have not been injected into for a while,
+// e.g. because they were demoted or have been forgotten
+func ExpirePseudoGTIDInjectionStatuses() error {
+	_, err := db.ExecOrchestrator(`
+			delete
+				from pseudo_gtid_injection
+			where
+				last_attempt_timestamp < NOW() - INTERVAL ? HOUR
+			`, config.Config.UnseenInstanceForgetHours,
+	)
+	return log.Errore(err)
+}
@@ -0,0 +1,202 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/golib/sqlutils"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/db"
+	"github.com/pmylund/go-cache"
+)
+
+// defaultLongRunningProcessSeconds is the time above which a query is considered to be long running, unless a
+// kill policy requires otherwise
+const defaultLongRunningProcessSeconds = 60
+
+// longRunningProcessSeconds returns the time above which queries are read as long running: the default, or less,
+// so as to catch queries to be killed by LongRunningQueryKillPolicies
+func longRunningProcessSeconds() int64 {
+	seconds := int64(defaultLongRunningProcessSeconds)
+	for _, policy := range config.Config.LongRunningQueryKillPolicies {
+		if policy.MaxTimeSeconds > 0 && policy.MaxTimeSeconds < seconds {
+			seconds = policy.MaxTimeSeconds
+		}
+	}
+	return seconds
+}
+
+// recentDryRunQueryKills remembers would-be kills audited on dry run, keyed by instance, process id and policy, such that
+// a query is audited once rather than on every poll while it runs. An entry expires once its query is no longer seen.
+var recentDryRunQueryKills = cache.New(10*time.Minute, time.Minute)
+
+// isDryRunQueryKillUnaudited returns true when given would-be kill has not been recently audited, and marks it as seen
+func isDryRunQueryKillUnaudited(instanceKey *InstanceKey, processId int64, policyName string) bool {
+	dryRunKey := fmt.Sprintf("%s:%d:%s", instanceKey.StringCode(), processId, policyName)
+	_, found := recentDryRunQueryKills.Get(dryRunKey)
+	recentDryRunQueryKills.Set(dryRunKey, true, cache.DefaultExpiration)
+	return !found
+}
+
+// queryKillPolicyPatterns caches the compiled kill policy patterns, so that each is compiled once rather than per
+// process per poll. Patterns are keyed by their text, which keeps the cache valid across configuration reloads.
+var queryKillPolicyPatterns = make(map[string]*regexp.Regexp)
+var queryKillPolicyPatternsMutex sync.Mutex
+
+// compileQueryKillPolicyPattern returns the compiled form of given pattern, compiling it on first use
+func compileQueryKillPolicyPattern(pattern string) (*regexp.Regexp, error) {
+	queryKillPolicyPatternsMutex.Lock()
+	defer queryKillPolicyPatternsMutex.Unlock()
+
+	if compiled, found := queryKillPolicyPatterns[pattern]; found {
+		return compiled, nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	queryKillPolicyPatterns[pattern] = compiled
+	return compiled, nil
+}
+
+// matchesPattern returns true when given pattern is empty or matches any of given values
+func matchesPattern(pattern string, values ...string) (bool, error) {
+	if pattern == "" {
+		return true, nil
+	}
+	compiled, err := compileQueryKillPolicyPattern(pattern)
+	if err != nil {
+		return false, err
+	}
+	for _, value := range values {
+		if compiled.MatchString(value) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// queryKillPolicyMatches returns true when given policy applies to given process running on given instance
+func queryKillPolicyMatches(policy *config.QueryKillPolicy, instance *Instance, clusterAlias string, process *Process) (bool, error) {
+	if policy.MaxTimeSeconds <= 0 || process.Time <= policy.MaxTimeSeconds {
+		return false, nil
+	}
+	if policy.SlavesOnly && !instance.IsSlave() {
+		return false, nil
+	}
+	if matched, err := matchesPattern(policy.ClusterPattern, instance.ClusterName, clusterAlias); !matched || err != nil {
+		return false, err
+	}
+	if matched, err := matchesPattern(policy.UserPattern, process.User); !matched || err != nil {
+		return false, err
+	}
+	if matched, err := matchesPattern(policy.CommandPattern, process.Info); !matched || err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getQueryKillPolicy returns the first of the configured kill policies which applies to given process, or nil
+func getQueryKillPolicy(instance *Instance, clusterAlias string, process *Process) *config.QueryKillPolicy {
+	for i := range config.Config.LongRunningQueryKillPolicies {
+		policy := &config.Config.LongRunningQueryKillPolicies[i]
+		matched, err := queryKillPolicyMatches(policy, instance, clusterAlias, process)
+		if err != nil {
+			log.Errorf("LongRunningQueryKillPolicies: invalid pattern in policy %s: %+v", policy.Name, err)
+			continue
+		}
+		if matched {
+			return policy
+		}
+	}
+	return nil
+}
+
+// isProcessStillRunning returns true when given process still runs the very same query on given instance, and has
+// been running for longer than given time. The long running queries are read as of last poll, by which time the
+// connection may have moved on to another query, or the process id may have been reused.
+func isProcessStillRunning(instanceKey *InstanceKey, process *Process, minTimeSeconds int64) (bool, error) {
+	db, err := db.OpenTopology(instanceKey.Hostname, instanceKey.Port)
+	if err != nil {
+		return false, err
+	}
+	stillRunning := false
+	err = sqlutils.QueryRowsMap(db, `
+			select
+				count(*) as is_running
+			from
+				information_schema.processlist
+			where
+				id = ?
+				and left(info, 1024) = ?
+				and time > ?
+		`,
+		func(m sqlutils.RowMap) error {
+			stillRunning = m.GetInt("is_running") > 0
+			return nil
+		}, process.Id, process.Info, minTimeSeconds)
+	return stillRunning, err
+}
+
+// EnforceQueryKillPolicies kills the long running queries of given instance, as last polled, which match
+// any of the LongRunningQueryKillPolicies. Every kill is audited along with the query; a would-be kill on dry run
+// is audited once per process and policy.
+func EnforceQueryKillPolicies(instance *Instance) error {
+	if len(config.Config.LongRunningQueryKillPolicies) == 0 {
+		return nil
+	}
+	processes, err := ReadInstanceLongRunningProcesses(&instance.Key)
+	if err != nil {
+		return err
+	}
+	clusterAlias, _ := ReadAliasByClusterName(instance.ClusterName)
+	for _, process := range processes {
+		process := process
+		policy := getQueryKillPolicy(instance, clusterAlias, &process)
+		if policy == nil {
+			continue
+		}
+		description := fmt.Sprintf("policy: %s, process: %d, user: %s, host: %s, db: %s, time: %ds, query: %s",
+			policy.Name, process.Id, process.User, process.Host, process.Db, process.Time, process.Info)
+		if policy.DryRun || *config.RuntimeCLIFlags.Noop {
+			if isDryRunQueryKillUnaudited(&instance.Key, process.Id, policy.Name) {
+				log.Infof("Would kill query on %+v; %s", instance.Key, description)
+				AuditOperation("kill-query-policy-dry-run", &instance.Key, description)
+			}
+			continue
+		}
+		if stillRunning, err := isProcessStillRunning(&instance.Key, &process, policy.MaxTimeSeconds); err != nil {
+			log.Errore(err)
+			continue
+		} else if !stillRunning {
+			log.Debugf("Not killing query on %+v: process is no longer running it; %s", instance.Key, description)
+			continue
+		}
+		if _, err := ExecInstance(&instance.Key, fmt.Sprintf(`kill query %d`, process.Id)); err != nil {
+			log.Errore(err)
+			continue
+		}
+		log.Infof("Killed query on %+v; %s", instance.Key, description)
+		AuditOperation("kill-query-policy", &instance.Key, description)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+func newQueryKillPolicyTestInstance(isSlave bool) *Instance {
+	instance := NewInstance()
+	instance.Key = key2
+	instance.ClusterName = "orders-db1:3306"
+	if isSlave {
+		instance.MasterKey = key1
+		instance.ReadBinlogCoordinates = BinlogCoordinates{LogFile: "mysql-bin.000001", LogPos: 4}
+	}
+	return instance
+}
+
+func newQueryKillPolicyTestProcess(user string, time int64, info string) *Process {
+	return &Process{Id: 17, User: user, Host: "app1:40000", Command: "Query", Time: time, Info: info}
+}
+
+func TestQueryKillPolicyMatchesTime(t *testing.T) {
+	policy := &config.QueryKillPolicy{Name: "long", MaxTimeSeconds: 300}
+	instance := newQueryKillPolicyTestInstance(false)
+
+	matched, err := queryKillPolicyMatches(policy, instance, "orders", newQueryKillPolicyTestProcess("app", 301, "select sleep(1000)"))
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectTrue(matched)
+
+	matched, _ = queryKillPolicyMatches(policy, instance, "orders", newQueryKillPolicyTestProcess("app", 300, "select sleep(1000)"))
+	test.S(t).ExpectFalse(matched)
+}
+
+func TestQueryKillPolicyMatchesPatterns(t *testing.T) {
+	policy := &config.QueryKillPolicy{Name: "reports", ClusterPattern: "^orders$", UserPattern: "^report", CommandPattern: "(?i)^select", MaxTimeSeconds: 60}
+	instance := newQueryKillPolicyTestInstance(false)
+
+	matched, _ := queryKillPolicyMatches(policy, instance, "orders", newQueryKillPolicyTestProcess("reporter", 61, "SELECT count(*) FROM orders"))
+	test.S(t).ExpectTrue(matched)
+	matched, _ = queryKillPolicyMatches(policy, instance, "billing", newQueryKillPolicyTestProcess("reporter", 61, "SELECT count(*) FROM orders"))
+	test.S(t).ExpectFalse(matched)
+	matched, _ = queryKillPolicyMatches(policy, instance, "orders", newQueryKillPolicyTestProcess("app", 61, "SELECT count(*) FROM orders"))
+	test.S(t).ExpectFalse(matched)
+	matched, _ = queryKillPolicyMatches(policy, instance, "orders", newQueryKillPolicyTestProcess("reporter", 61, "ALTER TABLE orders ENGINE=InnoDB"))
+	test.S(t).ExpectFalse(matched)
+}
+
+func TestQueryKillPolicyMatchesSlavesOnly(t *testing.T) {
+	policy := &config.QueryKillPolicy{Name: "slaves", MaxTimeSeconds: 60, SlavesOnly: true}
+	process := newQueryKillPolicyTestProcess("app", 61, "select 1")
+
+	matched, _ := queryKillPolicyMatches(policy, newQueryKillPolicyTestInstance(false), "orders", process)
+	test.S(t).ExpectFalse(matched)
+	matched, _ = queryKillPolicyMatches(policy, newQueryKillPolicyTestInstance(true), "orders", process)
+	test.S(t).ExpectTrue(matched)
+}
+
+func TestGetQueryKillPolicy(t *testing.T) {
+	policies := config.Config.LongRunningQueryKillPolicies
+	defer func() { config.Config.LongRunningQueryKillPolicies = policies }()
+	config.Config.LongRunningQueryKillPolicies = []config.QueryKillPolicy{
+		{Name: "invalid", UserPattern: "(", MaxTimeSeconds: 1},
+		{Name: "reports", UserPattern: "^report", MaxTimeSeconds: 30},
+		{Name: "all", MaxTimeSeconds: 600},
+	}
+	instance := newQueryKillPolicyTestInstance(false)
+
+	test.S(t).ExpectEquals(getQueryKillPolicy(instance, "orders", newQueryKillPolicyTestProcess("reporter", 31, "select 1")).Name, "reports")
+	test.S(t).ExpectEquals(getQueryKillPolicy(instance, "orders", newQueryKillPolicyTestProcess("app", 601, "select 1")).Name, "all")
+	test.S(t).ExpectTrue(getQueryKillPolicy(instance, "orders", newQueryKillPolicyTestProcess("app", 31, "select 1")) == nil)
+	test.S(t).ExpectEquals(longRunningProcessSeconds(), int64(1))
+}
+
+func TestLongRunningProcessSecondsDefault(t *testing.T) {
+	policies := config.Config.LongRunningQueryKillPolicies
+	defer func() { config.Config.LongRunningQueryKillPolicies = policies }()
+	config.Config.LongRunningQueryKillPolicies = []config.QueryKillPolicy{{Name: "all", MaxTimeSeconds: 600}}
+
+	test.S(t).ExpectEquals(longRunningProcessSeconds(), int64(defaultLongRunningProcessSeconds))
+}
+
+func TestCompileQueryKillPolicyPattern(t *testing.T) {
+	compiled, err := compileQueryKillPolicyPattern("^report")
+	test.S(t).ExpectNil(err)
+	cached, err := compileQueryKillPolicyPattern("^report")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectTrue(compiled == cached)
+
+	_, err = compileQueryKillPolicyPattern("(")
+	test.S(t).ExpectNotNil(err)
+	_, found := queryKillPolicyPatterns["("]
+	test.S(t).ExpectFalse(found)
+}
+
+func TestIsDryRunQueryKillUnaudited(t *testing.T) {
+	test.S(t).ExpectTrue(isDryRunQueryKillUnaudited(&key1, 17, "reports"))
+	test.S(t).ExpectFalse(isDryRunQueryKillUnaudited(&key1, 17, "reports"))
+	test.S(t).ExpectTrue(isDryRunQueryKillUnaudited(&key1, 18, "reports"))
+	test.S(t).ExpectTrue(isDryRunQueryKillUnaudited(&key1, 17, "other"))
+	test.S(t).ExpectTrue(isDryRunQueryKillUnaudited(&key2, 17, "reports"))
+}
@@ -0,0 +1,181 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ReplicationChannel is the replication state of a single upstream of a slave: a MySQL 5.7 replication channel,
+// or a MariaDB named slave connection. The default channel has an empty name.
+type ReplicationChannel struct {
+	Name                  string
+	MasterKey             InstanceKey
+	Slave_SQL_Running     bool
+	Slave_IO_Running      bool
+	UsingOracleGTID       bool
+	UsingMariaDBGTID      bool
+	ReadBinlogCoordinates BinlogCoordinates
+	ExecBinlogCoordinates BinlogCoordinates
+	RelaylogCoordinates   BinlogCoordinates
+	LastSQLError          string
+	LastIOError           string
+	SecondsBehindMaster   sql.NullInt64
+	MasterSSL             MasterSSLSettings
+}
+
+// ReplicationRunning returns true when both replication threads of this channel are running
+func (this *ReplicationChannel) ReplicationRunning() bool {
+	return this.Slave_SQL_Running && this.Slave_IO_Running
+}
+
+// primaryReplicationChannelIndex returns the index of the channel an instance is presented by: the default (unnamed)
+// channel if it exists, otherwise the first channel by name. It returns -1 on no channels.
+func primaryReplicationChannelIndex(channels []ReplicationChannel) int {
+	primary := -1
+	for i, channel := range channels {
+		if channel.Name == "" {
+			return i
+		}
+		if primary < 0 || channel.Name < channels[primary].Name {
+			primary = i
+		}
+	}
+	return primary
+}
+
+// IsMultiSource returns true when this instance replicates from more than one upstream
+func (this *Instance) IsMultiSource() bool {
+	return len(this.ReplicationChannels) > 1
+}
+
+// replicationChannelAuditSuffix notes given replication channel in the audit of an operation on given instance.
+// It is empty on single source instances.
+func replicationChannelAuditSuffix(instance *Instance, channel string) string {
+	if instance == nil || !instance.IsMultiSource() {
+		return ""
+	}
+	return fmt.Sprintf(", channel '%s'", channel)
+}
+
+// GetReplicationChannel returns this instance's replication channel by name, or nil if there is no such channel
+func (this *Instance) GetReplicationChannel(name string) *ReplicationChannel {
+	for i := range this.ReplicationChannels {
+		if this.ReplicationChannels[i].Name == name {
+			return &this.ReplicationChannels[i]
+		}
+	}
+	return nil
+}
+
+// GetReplicationChannelByMaster returns the replication channel via which this instance replicates from
+// given master, or nil if there is no such channel
+func (this *Instance) GetReplicationChannelByMaster(masterKey *InstanceKey) *ReplicationChannel {
+	for i := range this.ReplicationChannels {
+		if this.ReplicationChannels[i].MasterKey.Equals(masterKey) {
+			return &this.ReplicationChannels[i]
+		}
+	}
+	return nil
+}
+
+// AdditionalReplicationChannels returns the channels of this multi-source instance other than the one
+// it is presented by
+func (this *Instance) AdditionalReplicationChannels() []ReplicationChannel {
+	channels := []ReplicationChannel{}
+	for _, channel := range this.ReplicationChannels {
+		if channel.Name != this.ReplicationChannel {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// applyReplicationChannel presents given channel's replication state as this instance's own
+func (this *Instance) applyReplicationChannel(channel *ReplicationChannel) {
+	this.ReplicationChannel = channel.Name
+	this.MasterKey = channel.MasterKey
+	this.IsDetachedMaster = this.MasterKey.IsDetached()
+	this.Slave_SQL_Running = channel.Slave_SQL_Running
+	this.Slave_IO_Running = channel.Slave_IO_Running
+	this.UsingOracleGTID = channel.UsingOracleGTID
+	this.UsingMariaDBGTID = channel.UsingMariaDBGTID
+	this.ReadBinlogCoordinates = channel.ReadBinlogCoordinates
+	this.ExecBinlogCoordinates = channel.ExecBinlogCoordinates
+	this.IsDetached, _, _ = this.ExecBinlogCoordinates.DetachedCoordinates()
+	this.RelaylogCoordinates = channel.RelaylogCoordinates
+	this.LastSQLError = channel.LastSQLError
+	this.LastIOError = channel.LastIOError
+	this.SecondsBehindMaster = channel.SecondsBehindMaster
+	this.SlaveLagSeconds = channel.SecondsBehindMaster
+	this.MasterSSL = channel.MasterSSL
+	this.AllowTLS = channel.MasterSSL.Allowed
+}
+
+// WithReplicationChannel returns a copy of this instance, presented by given replication channel: master,
+// coordinates and replication threads are those of the channel.
+func (this *Instance) WithReplicationChannel(name string) (*Instance, error) {
+	instance := *this
+	if name == this.ReplicationChannel {
+		return &instance, nil
+	}
+	channel := this.GetReplicationChannel(name)
+	if channel == nil {
+		return this, fmt.Errorf("No replication channel '%s' on %+v", name, this.Key)
+	}
+	instance.applyReplicationChannel(channel)
+	return &instance, nil
+}
+
+// replicationChannelStatement adapts a "stop slave", "start slave ...", "change master to ..." or "reset slave ..."
+// statement to only apply to given replication channel of given instance. On a single source instance replicating
+// via the default channel the statement is unchanged.
+func replicationChannelStatement(instance *Instance, channel string, statement string) string {
+	quotedChannel := fmt.Sprintf("'%s'", strings.Replace(channel, "'", "''", -1))
+	if instance.IsMariaDB() {
+		if channel == "" {
+			return statement
+		}
+		for _, prefix := range []string{"change master", "stop slave", "start slave", "reset slave"} {
+			if strings.HasPrefix(statement, prefix) {
+				return fmt.Sprintf("%s %s%s", prefix, quotedChannel, statement[len(prefix):])
+			}
+		}
+		return statement
+	}
+	if channel == "" && !instance.IsMultiSource() {
+		return statement
+	}
+	// On MySQL, a statement with no channel applies to all channels
+	return fmt.Sprintf("%s for channel %s", statement, quotedChannel)
+}
+
+// allReplicationChannelsStatement adapts a "stop slave" or "start slave" statement to apply to all replication
+// channels of given instance. On MySQL a statement with no channel applies to all channels; on MariaDB it only
+// applies to the default connection.
+func allReplicationChannelsStatement(instance *Instance, statement string) string {
+	if instance.IsMariaDB() && instance.IsMultiSource() {
+		for _, prefix := range []string{"stop slave", "start slave"} {
+			if strings.HasPrefix(statement, prefix) {
+				return fmt.Sprintf("%s all slaves%s", strings.TrimSuffix(prefix, " slave"), statement[len(prefix):])
+			}
+		}
+	}
+	return statement
+}
@@ -0,0 +1,176 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/golib/sqlutils"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/db"
+)
+
+// readReplicationChannelRow reads a single row of database_instance_replication_channel
+func readReplicationChannelRow(m sqlutils.RowMap) ReplicationChannel {
+	channel := ReplicationChannel{}
+	channel.Name = m.GetString("channel_name")
+	channel.MasterKey = InstanceKey{Hostname: m.GetString("master_host"), Port: m.GetInt("master_port")}
+	channel.Slave_SQL_Running = m.GetBool("slave_sql_running")
+	channel.Slave_IO_Running = m.GetBool("slave_io_running")
+	channel.UsingOracleGTID = m.GetBool("oracle_gtid")
+	channel.UsingMariaDBGTID = m.GetBool("mariadb_gtid")
+	channel.ReadBinlogCoordinates = BinlogCoordinates{LogFile: m.GetString("master_log_file"), LogPos: m.GetInt64("read_master_log_pos")}
+	channel.ExecBinlogCoordinates = BinlogCoordinates{LogFile: m.GetString("relay_master_log_file"), LogPos: m.GetInt64("exec_master_log_pos")}
+	channel.RelaylogCoordinates = BinlogCoordinates{LogFile: m.GetString("relay_log_file"), LogPos: m.GetInt64("relay_log_pos"), Type: RelayLog}
+	channel.LastSQLError = m.GetString("last_sql_error")
+	channel.LastIOError = m.GetString("last_io_error")
+	channel.SecondsBehindMaster = m.GetNullInt64("seconds_behind_master")
+	return channel
+}
+
+// hasPersistedReplicationChannels returns true when replication channels are persisted for given instance, i.e.
+// when it has been known to be multi-source
+func hasPersistedReplicationChannels(instanceKey *InstanceKey) (bool, error) {
+	hasChannels := false
+	query := `
+		select
+			1
+		from
+			database_instance_replication_channel
+		where
+			hostname = ? and port = ?
+		limit 1
+		`
+	err := db.QueryOrchestrator(query, sqlutils.Args(instanceKey.Hostname, instanceKey.Port), func(m sqlutils.RowMap) error {
+		hasChannels = true
+		return nil
+	})
+	return hasChannels, log.Errore(err)
+}
+
+// writeInstanceReplicationChannels persists the replication channels of a multi-source instance, replacing formerly
+// persisted channels in a single transaction. Channels of a single source instance are not persisted; its replication
+// state is that of database_instance. Channels of an instance which is no longer multi-source are removed.
+func writeInstanceReplicationChannels(instance *Instance) error {
+	if config.Config.DatabaselessMode__experimental {
+		return nil
+	}
+	if !instance.IsMultiSource() {
+		wasMultiSource, err := hasPersistedReplicationChannels(&instance.Key)
+		if err != nil || !wasMultiSource {
+			return err
+		}
+	}
+	db, err := db.OpenOrchestrator()
+	if err != nil {
+		return log.Errore(err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return log.Errore(err)
+	}
+	if _, err := tx.Exec(`
+			delete
+				from database_instance_replication_channel
+			where
+				hostname = ? and port = ?`,
+		instance.Key.Hostname,
+		instance.Key.Port,
+	); err != nil {
+		tx.Rollback()
+		return log.Errore(err)
+	}
+	if instance.IsMultiSource() {
+		for _, channel := range instance.ReplicationChannels {
+			_, err := tx.Exec(`
+			insert into database_instance_replication_channel (
+				hostname, port, channel_name, master_host, master_port, slave_sql_running, slave_io_running, oracle_gtid, mariadb_gtid,
+				master_log_file, read_master_log_pos, relay_master_log_file, exec_master_log_pos, relay_log_file, relay_log_pos,
+				last_sql_error, last_io_error, seconds_behind_master, last_seen
+			) values (
+				?, ?, ?, ?, ?, ?, ?, ?, ?,
+				?, ?, ?, ?, ?, ?,
+				?, ?, ?, NOW()
+			)`,
+				instance.Key.Hostname, instance.Key.Port, channel.Name, channel.MasterKey.Hostname, channel.MasterKey.Port,
+				channel.Slave_SQL_Running, channel.Slave_IO_Running, channel.UsingOracleGTID, channel.UsingMariaDBGTID,
+				channel.ReadBinlogCoordinates.LogFile, channel.ReadBinlogCoordinates.LogPos,
+				channel.ExecBinlogCoordinates.LogFile, channel.ExecBinlogCoordinates.LogPos,
+				channel.RelaylogCoordinates.LogFile, channel.RelaylogCoordinates.LogPos,
+				channel.LastSQLError, channel.LastIOError, channel.SecondsBehindMaster,
+			)
+			if err != nil {
+				tx.Rollback()
+				return log.Errore(err)
+			}
+		}
+	}
+	return log.Errore(tx.Commit())
+}
+
+// readInstanceReplicationChannels reads the persisted replication channels of a multi-source instance
+func readInstanceReplicationChannels(instanceKey *InstanceKey) ([]ReplicationChannel, error) {
+	channels := []ReplicationChannel{}
+	query := `
+		select
+			*
+		from
+			database_instance_replication_channel
+		where
+			hostname = ? and port = ?
+		order by
+			channel_name
+		`
+	err := db.QueryOrchestrator(query, sqlutils.Args(instanceKey.Hostname, instanceKey.Port), func(m sqlutils.RowMap) error {
+		channels = append(channels, readReplicationChannelRow(m))
+		return nil
+	})
+	return channels, log.Errore(err)
+}
+
+// populateInstancesReplicationChannels reads the replication channels of given multi-source instances
+func populateInstancesReplicationChannels(instances [](*Instance)) error {
+	for _, instance := range instances {
+		channels, err := readInstanceReplicationChannels(&instance.Key)
+		if err != nil {
+			return err
+		}
+		instance.ReplicationChannels = channels
+	}
+	return nil
+}
+
+// readAllReplicationChannels reads the replication channels of all multi-source instances
+func readAllReplicationChannels() (map[InstanceKey][]ReplicationChannel, error) {
+	channels := make(map[InstanceKey][]ReplicationChannel)
+	query := `
+		select
+			database_instance_replication_channel.*
+		from
+			database_instance_replication_channel
+			join database_instance using (hostname, port)
+		where
+			database_instance.is_multi_source = 1
+		order by
+			hostname, port, channel_name
+		`
+	err := db.QueryOrchestratorRowsMap(query, func(m sqlutils.RowMap) error {
+		instanceKey := InstanceKey{Hostname: m.GetString("hostname"), Port: m.GetInt("port")}
+		channels[instanceKey] = append(channels[instanceKey], readReplicationChannelRow(m))
+		return nil
+	})
+	return channels, log.Errore(err)
+}
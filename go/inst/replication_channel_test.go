@@ -0,0 +1,103 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func newMultiSourceTestInstance(version string) *Instance {
+	instance := NewInstance()
+	instance.Key = key1
+	instance.Version = version
+	instance.ReplicationChannels = []ReplicationChannel{
+		{Name: "b", MasterKey: key3, ReadBinlogCoordinates: BinlogCoordinates{LogFile: "mysql-bin.000007", LogPos: 4}},
+		{Name: "a", MasterKey: key2, ReadBinlogCoordinates: BinlogCoordinates{LogFile: "mysql-bin.000003", LogPos: 120}, Slave_SQL_Running: true, Slave_IO_Running: true},
+	}
+	instance.applyReplicationChannel(&instance.ReplicationChannels[primaryReplicationChannelIndex(instance.ReplicationChannels)])
+	return instance
+}
+
+func TestPrimaryReplicationChannelIndex(t *testing.T) {
+	test.S(t).ExpectEquals(primaryReplicationChannelIndex([]ReplicationChannel{}), -1)
+	test.S(t).ExpectEquals(primaryReplicationChannelIndex([]ReplicationChannel{{Name: "b"}, {Name: "a"}, {Name: "c"}}), 1)
+	test.S(t).ExpectEquals(primaryReplicationChannelIndex([]ReplicationChannel{{Name: "b"}, {Name: ""}, {Name: "a"}}), 1)
+}
+
+func TestWithReplicationChannel(t *testing.T) {
+	instance := newMultiSourceTestInstance("5.7.16-log")
+	test.S(t).ExpectTrue(instance.IsMultiSource())
+	test.S(t).ExpectEquals(instance.ReplicationChannel, "a")
+	test.S(t).ExpectEquals(instance.MasterKey, key2)
+	test.S(t).ExpectTrue(instance.SlaveRunning())
+	test.S(t).ExpectEquals(len(instance.AdditionalReplicationChannels()), 1)
+	test.S(t).ExpectEquals(instance.GetReplicationChannelByMaster(&key3).Name, "b")
+
+	channelInstance, err := instance.WithReplicationChannel("b")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(channelInstance.ReplicationChannel, "b")
+	test.S(t).ExpectEquals(channelInstance.MasterKey, key3)
+	test.S(t).ExpectEquals(channelInstance.ReadBinlogCoordinates.LogFile, "mysql-bin.000007")
+	test.S(t).ExpectFalse(channelInstance.SlaveRunning())
+	// Original is untouched
+	test.S(t).ExpectEquals(instance.MasterKey, key2)
+
+	_, err = instance.WithReplicationChannel("no-such-channel")
+	test.S(t).ExpectNotNil(err)
+}
+
+func TestReplicationChannelStatementMySQL(t *testing.T) {
+	singleSource := NewInstance()
+	singleSource.Version = "5.7.16-log"
+	test.S(t).ExpectEquals(replicationChannelStatement(singleSource, "", "stop slave"), "stop slave")
+	test.S(t).ExpectEquals(replicationChannelStatement(singleSource, "a", "stop slave"), "stop slave for channel 'a'")
+
+	multiSource := newMultiSourceTestInstance("5.7.16-log")
+	test.S(t).ExpectEquals(replicationChannelStatement(multiSource, "", "stop slave io_thread"), "stop slave io_thread for channel ''")
+	test.S(t).ExpectEquals(replicationChannelStatement(multiSource, "b", "change master to master_host='host3', master_port=3306"), "change master to master_host='host3', master_port=3306 for channel 'b'")
+	test.S(t).ExpectEquals(replicationChannelStatement(multiSource, "it's", "start slave"), "start slave for channel 'it''s'")
+}
+
+func TestReplicationChannelStatementMariaDB(t *testing.T) {
+	multiSource := newMultiSourceTestInstance("10.1.19-MariaDB")
+	test.S(t).ExpectEquals(replicationChannelStatement(multiSource, "", "stop slave"), "stop slave")
+	test.S(t).ExpectEquals(replicationChannelStatement(multiSource, "b", "stop slave io_thread"), "stop slave 'b' io_thread")
+	test.S(t).ExpectEquals(replicationChannelStatement(multiSource, "b", "start slave until master_log_file='mysql-bin.000007', master_log_pos=4"), "start slave 'b' until master_log_file='mysql-bin.000007', master_log_pos=4")
+	test.S(t).ExpectEquals(replicationChannelStatement(multiSource, "b", "change master to master_host='host3'"), "change master 'b' to master_host='host3'")
+	test.S(t).ExpectEquals(replicationChannelStatement(multiSource, "b", "reset slave /*!50603 all */"), "reset slave 'b' /*!50603 all */")
+}
+
+func TestAllReplicationChannelsStatement(t *testing.T) {
+	test.S(t).ExpectEquals(allReplicationChannelsStatement(newMultiSourceTestInstance("5.7.16-log"), "stop slave"), "stop slave")
+	multiSource := newMultiSourceTestInstance("10.1.19-MariaDB")
+	test.S(t).ExpectEquals(allReplicationChannelsStatement(multiSource, "stop slave"), "stop all slaves")
+	test.S(t).ExpectEquals(allReplicationChannelsStatement(multiSource, "start slave"), "start all slaves")
+
+	multiSource.ReplicationChannels = multiSource.ReplicationChannels[:1]
+	test.S(t).ExpectEquals(allReplicationChannelsStatement(multiSource, "start slave"), "start slave")
+}
+
+func TestReplicationChannelAuditSuffix(t *testing.T) {
+	instance := newMultiSourceTestInstance("5.7.16-log")
+	test.S(t).ExpectEquals(replicationChannelAuditSuffix(instance, "b"), ", channel 'b'")
+
+	instance.ReplicationChannels = instance.ReplicationChannels[:1]
+	test.S(t).ExpectEquals(replicationChannelAuditSuffix(instance, "b"), "")
+	test.S(t).ExpectEquals(replicationChannelAuditSuffix(nil, "b"), "")
+}
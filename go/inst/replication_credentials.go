@@ -0,0 +1,175 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+// replicationCredentialsVerifyTimeout is the time given a slave's IO thread to connect to its master
+// with newly applied credentials
+const replicationCredentialsVerifyTimeout = 10 * time.Second
+
+// ReplicationCredentialsRotation is the outcome of rotating the replication credentials of a cluster's slaves
+type ReplicationCredentialsRotation struct {
+	ClusterName     string
+	ReplicationUser string
+	Rotated         []InstanceKey
+	RolledBack      []InstanceKey
+}
+
+// replicationCredentials are the credentials a slave replicated with before rotation, kept for rollback
+type replicationCredentials struct {
+	instanceKey InstanceKey
+	user        string
+	password    string
+}
+
+// RotateReplicationCredentials applies new replication credentials (MASTER_USER, MASTER_PASSWORD) on all slaves of
+// given cluster, one slave at a time. Each slave is put in maintenance, has its IO thread stopped, its credentials
+// changed, and is then verified to connect to its master. Upon failure the slave at hand and all slaves rotated
+// before it are rolled back to their previous credentials.
+// The password is never logged nor audited.
+func RotateReplicationCredentials(clusterName string, replicationUser string, replicationPassword string) (*ReplicationCredentialsRotation, error) {
+	rotation := &ReplicationCredentialsRotation{
+		ClusterName:     clusterName,
+		ReplicationUser: replicationUser,
+		Rotated:         []InstanceKey{},
+		RolledBack:      []InstanceKey{},
+	}
+	if replicationUser == "" {
+		return rotation, fmt.Errorf("rotate-replication-credentials: empty replication user")
+	}
+	if *config.RuntimeCLIFlags.Noop {
+		return rotation, fmt.Errorf("noop: aborting rotate-replication-credentials operation on %+v; signalling error but nothing went wrong.", clusterName)
+	}
+	instances, err := ReadClusterInstances(clusterName)
+	if err != nil {
+		return rotation, log.Errore(err)
+	}
+
+	previousCredentials := []replicationCredentials{}
+	rollback := func() {
+		for i := len(previousCredentials) - 1; i >= 0; i-- {
+			previous := previousCredentials[i]
+			if err := rotateInstanceReplicationCredentials(&previous.instanceKey, previous.user, previous.password, "rotate-replication-credentials-rollback"); err != nil {
+				log.Errorf("rotate-replication-credentials: cannot roll back credentials on %+v: %+v", previous.instanceKey, err)
+				continue
+			}
+			rotation.RolledBack = append(rotation.RolledBack, previous.instanceKey)
+		}
+	}
+
+	log.Infof("Will rotate replication credentials on slaves of %+v; user: %s", clusterName, replicationUser)
+	for _, instance := range instances {
+		if !instance.IsSlave() || instance.IsBinlogServer() {
+			continue
+		}
+		previousUser, previousPassword, err := ReadReplicationCredentials(&instance.Key)
+		if err != nil {
+			rollback()
+			return rotation, log.Errorf("rotate-replication-credentials: cannot read current credentials of %+v, which are required for rollback: %+v", instance.Key, err)
+		}
+		previousCredentials = append(previousCredentials, replicationCredentials{instanceKey: instance.Key, user: previousUser, password: previousPassword})
+		if err := rotateInstanceReplicationCredentials(&instance.Key, replicationUser, replicationPassword, "rotate-replication-credentials"); err != nil {
+			rollback()
+			return rotation, log.Errorf("rotate-replication-credentials: failed on %+v; rolled back %d slaves: %+v", instance.Key, len(rotation.RolledBack), err)
+		}
+		rotation.Rotated = append(rotation.Rotated, instance.Key)
+	}
+	log.Infof("Rotated replication credentials on %d slaves of %+v", len(rotation.Rotated), clusterName)
+
+	return rotation, nil
+}
+
+// rotateInstanceReplicationCredentials applies given replication credentials on a single slave, under maintenance,
+// and verifies the slave connects to its master.
+func rotateInstanceReplicationCredentials(instanceKey *InstanceKey, replicationUser string, replicationPassword string, operation string) (err error) {
+	instance, err := ReadTopologyInstance(instanceKey)
+	if err != nil {
+		return err
+	}
+	// With MySQL 5.7 only the IO thread needs be stopped to change credentials. Otherwise, all of replication.
+	stopSQLThread := instance.IsMariaDB() || instance.IsSmallerMajorVersionByString("5.7")
+	ioThreadWasRunning := instance.Slave_IO_Running
+	sqlThreadWasRunning := instance.Slave_SQL_Running
+
+	if maintenanceToken, merr := BeginMaintenance(instanceKey, GetMaintenanceOwner(), operation); merr != nil {
+		return fmt.Errorf("Cannot begin maintenance on %+v", *instanceKey)
+	} else {
+		defer EndMaintenance(maintenanceToken)
+	}
+
+	if stopSQLThread {
+		_, err = StopSlave(instanceKey)
+	} else {
+		_, err = StopSlaveIOThread(instanceKey)
+	}
+	if err != nil {
+		goto Cleanup
+	}
+	_, err = ChangeMasterCredentials(instanceKey, replicationUser, replicationPassword)
+	if err != nil {
+		goto Cleanup
+	}
+
+Cleanup:
+	// Replication threads are returned to their original state
+	if stopSQLThread && sqlThreadWasRunning && ioThreadWasRunning {
+		StartSlave(instanceKey)
+	} else {
+		if stopSQLThread && sqlThreadWasRunning {
+			ExecInstanceNoPrepare(instanceKey, replicationChannelStatement(instance, instance.ReplicationChannel, `start slave sql_thread`))
+		}
+		if ioThreadWasRunning {
+			StartSlaveIOThread(instanceKey)
+		}
+	}
+	if err != nil {
+		return log.Errore(err)
+	}
+	if ioThreadWasRunning {
+		if err = verifySlaveIOThreadConnects(instanceKey); err != nil {
+			return err
+		}
+	}
+	AuditOperation(operation, instanceKey, fmt.Sprintf("applied replication credentials of user %s", replicationUser))
+
+	return nil
+}
+
+// verifySlaveIOThreadConnects waits for the IO thread of given slave to connect to its master
+func verifySlaveIOThreadConnects(instanceKey *InstanceKey) error {
+	startTime := time.Now()
+	for {
+		instance, err := ReadTopologyInstance(instanceKey)
+		if err != nil {
+			return err
+		}
+		if instance.Slave_IO_Running {
+			return nil
+		}
+		if time.Since(startTime) >= replicationCredentialsVerifyTimeout {
+			return fmt.Errorf("IO thread of %+v does not connect to its master: %s", *instanceKey, instance.LastIOError)
+		}
+		time.Sleep(time.Second)
+	}
+}
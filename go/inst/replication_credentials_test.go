@@ -0,0 +1,66 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"strings"
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+func TestRotateReplicationCredentialsEmptyUser(t *testing.T) {
+	rotation, err := RotateReplicationCredentials("cluster:3306", "", "secret")
+	test.S(t).ExpectNotNil(err)
+	test.S(t).ExpectEquals(len(rotation.Rotated), 0)
+}
+
+func TestRotateReplicationCredentialsNoop(t *testing.T) {
+	noop := true
+	config.RuntimeCLIFlags.Noop = &noop
+	defer func() { config.RuntimeCLIFlags.Noop = nil }()
+
+	rotation, err := RotateReplicationCredentials("cluster:3306", "repl", "secret")
+	test.S(t).ExpectNotNil(err)
+	test.S(t).ExpectFalse(strings.Contains(err.Error(), "secret"))
+	test.S(t).ExpectEquals(rotation.ReplicationUser, "repl")
+	test.S(t).ExpectEquals(len(rotation.Rotated), 0)
+}
+
+func TestChangeMasterCredentialsStatement(t *testing.T) {
+	statement, err := changeMasterCredentialsStatement("repl", "se'cr'et")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(statement, "change master to master_user='repl', master_password='se''cr''et'")
+
+	statement, err = changeMasterCredentialsStatement("repl", "x', master_host='rogue")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(statement, "change master to master_user='repl', master_password='x'', master_host=''rogue'")
+
+	_, err = changeMasterCredentialsStatement("repl", `secret\`)
+	test.S(t).ExpectNotNil(err)
+	test.S(t).ExpectFalse(strings.Contains(err.Error(), "secret"))
+	_, err = changeMasterCredentialsStatement(`re\pl`, "secret")
+	test.S(t).ExpectNotNil(err)
+}
+
+func TestRedactSecret(t *testing.T) {
+	message := "You have an error in your SQL syntax; check the manual near 'se''cr''et'' at line 1"
+	test.S(t).ExpectEquals(redactSecret(message, "se'cr'et"), "You have an error in your SQL syntax; check the manual near '********'' at line 1")
+	test.S(t).ExpectEquals(redactSecret("password secret rejected", "secret"), "password ******** rejected")
+	test.S(t).ExpectEquals(redactSecret("no password here", ""), "no password here")
+}
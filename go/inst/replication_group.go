@@ -0,0 +1,136 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Member states and roles, as listed in performance_schema.replication_group_members
+const (
+	ReplicationGroupMemberStateOnline      = "ONLINE"
+	ReplicationGroupMemberStateRecovering  = "RECOVERING"
+	ReplicationGroupMemberStateOffline     = "OFFLINE"
+	ReplicationGroupMemberStateError       = "ERROR"
+	ReplicationGroupMemberStateUnreachable = "UNREACHABLE"
+
+	ReplicationGroupMemberRolePrimary   = "PRIMARY"
+	ReplicationGroupMemberRoleSecondary = "SECONDARY"
+)
+
+// ReplicationGroupMember is a member of a MySQL Group Replication group, as seen by some member of the group
+type ReplicationGroupMember struct {
+	Key   InstanceKey
+	UUID  string
+	State string
+	Role  string
+}
+
+// IsReachable returns true when this member is part of the group's view and can be communicated with
+func (this *ReplicationGroupMember) IsReachable() bool {
+	return this.State == ReplicationGroupMemberStateOnline || this.State == ReplicationGroupMemberStateRecovering
+}
+
+// isReplicationGroupChannel returns true for the replication channels Group Replication uses internally
+// (group_replication_applier, group_replication_recovery)
+func isReplicationGroupChannel(channelName string) bool {
+	return strings.HasPrefix(channelName, "group_replication_")
+}
+
+// replicationGroupMembersToJSON marshals given members for storage in the backend database
+func replicationGroupMembersToJSON(members []ReplicationGroupMember) string {
+	if len(members) == 0 {
+		return ""
+	}
+	bytes, _ := json.Marshal(members)
+	return string(bytes)
+}
+
+// replicationGroupMembersFromJSON reads members as stored in the backend database
+func replicationGroupMembersFromJSON(membersJSON string) (members []ReplicationGroupMember, err error) {
+	if membersJSON == "" {
+		return members, nil
+	}
+	err = json.Unmarshal([]byte(membersJSON), &members)
+	return members, err
+}
+
+// IsReplicationGroupMember returns true when this instance is an active member of a replication group
+func (this *Instance) IsReplicationGroupMember() bool {
+	return this.ReplicationGroupName != "" && this.ReplicationGroupMemberState != "" && this.ReplicationGroupMemberState != ReplicationGroupMemberStateOffline
+}
+
+// IsReplicationGroupPrimary returns true when this instance is a (or, in single-primary mode, the) writable group member
+func (this *Instance) IsReplicationGroupPrimary() bool {
+	return this.IsReplicationGroupMember() && this.ReplicationGroupMemberRole == ReplicationGroupMemberRolePrimary
+}
+
+// IsReplicationGroupSecondary returns true when this instance is a read-only member of a single-primary group
+func (this *Instance) IsReplicationGroupSecondary() bool {
+	return this.IsReplicationGroupMember() && this.ReplicationGroupMemberRole == ReplicationGroupMemberRoleSecondary
+}
+
+// applyReplicationGroupMembers sets this instance's group membership given the members of its group, as listed
+// by this instance. primaryMemberUUID is only required when the member list does not include roles (5.7)
+func (this *Instance) applyReplicationGroupMembers(members []ReplicationGroupMember, primaryMemberUUID string) {
+	for i := range members {
+		member := &members[i]
+		if member.Role == "" && member.IsReachable() {
+			if !this.ReplicationGroupIsSinglePrimary || member.UUID == primaryMemberUUID {
+				member.Role = ReplicationGroupMemberRolePrimary
+			} else {
+				member.Role = ReplicationGroupMemberRoleSecondary
+			}
+		}
+		if this.ReplicationGroupIsSinglePrimary && member.Role == ReplicationGroupMemberRolePrimary {
+			this.ReplicationGroupPrimaryKey = member.Key
+		}
+		if member.UUID == this.ServerUUID {
+			this.ReplicationGroupMemberState = member.State
+			this.ReplicationGroupMemberRole = member.Role
+		}
+	}
+	this.ReplicationGroupMembers = members
+}
+
+// replicationGroupClusterKey returns the key of the group member this instance's cluster is named by: the primary
+// in a single-primary group. In a multi-primary group, the member the cluster is currently named by (namingKey, may
+// be nil) keeps naming it while it is a reachable member, such that the cluster is not renamed as members join;
+// otherwise the smallest reachable member names the cluster.
+func (this *Instance) replicationGroupClusterKey(namingKey *InstanceKey) *InstanceKey {
+	if this.ReplicationGroupIsSinglePrimary {
+		if this.ReplicationGroupPrimaryKey.IsValid() {
+			return &this.ReplicationGroupPrimaryKey
+		}
+		return nil
+	}
+	var clusterKey *InstanceKey
+	for i := range this.ReplicationGroupMembers {
+		member := &this.ReplicationGroupMembers[i]
+		if !member.IsReachable() {
+			continue
+		}
+		if namingKey != nil && member.Key.Equals(namingKey) {
+			return &member.Key
+		}
+		if clusterKey == nil || member.Key.SmallerThan(clusterKey) {
+			clusterKey = &member.Key
+		}
+	}
+	return clusterKey
+}
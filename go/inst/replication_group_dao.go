@@ -0,0 +1,112 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"fmt"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/golib/sqlutils"
+	"github.com/outbrain/orchestrator/go/db"
+)
+
+// auditReplicationGroupPrimaryChange compares the group primary as just read from given instance with the one
+// last recorded for the instance. Upon change, the change is audited and its time recorded.
+func auditReplicationGroupPrimaryChange(instance *Instance) error {
+	if !instance.ReplicationGroupIsSinglePrimary || !instance.ReplicationGroupPrimaryKey.IsValid() {
+		return nil
+	}
+	previousPrimaryKey := InstanceKey{}
+	query := `
+		select
+			replication_group_primary_host,
+			replication_group_primary_port
+		from
+			database_instance
+		where
+			hostname = ?
+			and port = ?
+			and replication_group_name = ?
+		`
+	err := db.QueryOrchestrator(query, sqlutils.Args(instance.Key.Hostname, instance.Key.Port, instance.ReplicationGroupName), func(m sqlutils.RowMap) error {
+		previousPrimaryKey.Hostname = m.GetString("replication_group_primary_host")
+		previousPrimaryKey.Port = m.GetInt("replication_group_primary_port")
+		return nil
+	})
+	if err != nil {
+		return log.Errore(err)
+	}
+	if !previousPrimaryKey.IsValid() || previousPrimaryKey.Equals(&instance.ReplicationGroupPrimaryKey) {
+		return nil
+	}
+	_, err = db.ExecOrchestrator(`
+			update
+				database_instance
+			set
+				replication_group_primary_changed_timestamp = now()
+			where
+				hostname = ?
+				and port = ?
+			`,
+		instance.Key.Hostname, instance.Key.Port,
+	)
+	if err != nil {
+		return log.Errore(err)
+	}
+	if instance.ReplicationGroupPrimaryKey.Equals(&instance.Key) {
+		// Audited once, by the new primary
+		AuditOperation("replication-group-primary-change", &instance.Key, fmt.Sprintf("group %s primary changed from %+v to %+v", instance.ReplicationGroupName, previousPrimaryKey, instance.ReplicationGroupPrimaryKey))
+	}
+	return nil
+}
+
+// readReplicationGroupNamingKey returns the key of the member the cluster of given replication group is currently
+// named by, or nil if there is none
+func readReplicationGroupNamingKey(groupName string) (*InstanceKey, error) {
+	var namingKey *InstanceKey
+	query := `
+		select
+			hostname,
+			port
+		from
+			database_instance
+		where
+			replication_group_name = ?
+			and cluster_name = concat(hostname, ':', port)
+		order by
+			hostname asc,
+			port asc
+		limit 1
+		`
+	err := db.QueryOrchestrator(query, sqlutils.Args(groupName), func(m sqlutils.RowMap) error {
+		namingKey = &InstanceKey{Hostname: m.GetString("hostname"), Port: m.GetInt("port")}
+		return nil
+	})
+	if err != nil {
+		return nil, log.Errore(err)
+	}
+	return namingKey, nil
+}
+
+// ReadReplicationGroupInstances returns the known members of given replication group
+func ReadReplicationGroupInstances(groupName string) ([](*Instance), error) {
+	condition := `
+		replication_group_name = ?
+		and replication_group_member_state not in ('', 'OFFLINE')
+	`
+	return readInstancesByCondition(condition, sqlutils.Args(groupName), "hostname, port")
+}
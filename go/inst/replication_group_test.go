@@ -0,0 +1,112 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func newReplicationGroupTestInstance(singlePrimary bool) *Instance {
+	instance := NewInstance()
+	instance.Key = key2
+	instance.ServerUUID = "00000000-0000-0000-0000-000000000002"
+	instance.Version = "5.7.17-log"
+	instance.ReplicationGroupName = "8a94f357-aab4-11df-86ab-c80aa9429562"
+	instance.ReplicationGroupIsSinglePrimary = singlePrimary
+	return instance
+}
+
+func newReplicationGroupTestMembers() []ReplicationGroupMember {
+	return []ReplicationGroupMember{
+		{Key: key1, UUID: "00000000-0000-0000-0000-000000000001", State: ReplicationGroupMemberStateOnline},
+		{Key: key2, UUID: "00000000-0000-0000-0000-000000000002", State: ReplicationGroupMemberStateOnline},
+		{Key: key3, UUID: "00000000-0000-0000-0000-000000000003", State: ReplicationGroupMemberStateUnreachable},
+	}
+}
+
+func TestIsReplicationGroupChannel(t *testing.T) {
+	test.S(t).ExpectTrue(isReplicationGroupChannel("group_replication_applier"))
+	test.S(t).ExpectTrue(isReplicationGroupChannel("group_replication_recovery"))
+	test.S(t).ExpectFalse(isReplicationGroupChannel(""))
+	test.S(t).ExpectFalse(isReplicationGroupChannel("a"))
+}
+
+func TestApplyReplicationGroupMembersSinglePrimary(t *testing.T) {
+	instance := newReplicationGroupTestInstance(true)
+	instance.applyReplicationGroupMembers(newReplicationGroupTestMembers(), "00000000-0000-0000-0000-000000000001")
+
+	test.S(t).ExpectTrue(instance.IsReplicationGroupMember())
+	test.S(t).ExpectTrue(instance.IsReplicationGroupSecondary())
+	test.S(t).ExpectFalse(instance.IsReplicationGroupPrimary())
+	test.S(t).ExpectEquals(instance.ReplicationGroupMemberState, ReplicationGroupMemberStateOnline)
+	test.S(t).ExpectEquals(instance.ReplicationGroupPrimaryKey, key1)
+	test.S(t).ExpectEquals(*instance.replicationGroupClusterKey(nil), key1)
+	test.S(t).ExpectEquals(instance.ReplicationGroupMembers[0].Role, ReplicationGroupMemberRolePrimary)
+	// Unreachable members are assigned no role
+	test.S(t).ExpectEquals(instance.ReplicationGroupMembers[2].Role, "")
+}
+
+func TestApplyReplicationGroupMembersWithRoles(t *testing.T) {
+	instance := newReplicationGroupTestInstance(true)
+	members := newReplicationGroupTestMembers()
+	members[0].Role = ReplicationGroupMemberRoleSecondary
+	members[1].Role = ReplicationGroupMemberRolePrimary
+	instance.applyReplicationGroupMembers(members, "")
+
+	test.S(t).ExpectTrue(instance.IsReplicationGroupPrimary())
+	test.S(t).ExpectEquals(instance.ReplicationGroupPrimaryKey, key2)
+	test.S(t).ExpectEquals(*instance.replicationGroupClusterKey(nil), key2)
+}
+
+func TestApplyReplicationGroupMembersMultiPrimary(t *testing.T) {
+	instance := newReplicationGroupTestInstance(false)
+	instance.applyReplicationGroupMembers(newReplicationGroupTestMembers(), "")
+
+	test.S(t).ExpectTrue(instance.IsReplicationGroupPrimary())
+	test.S(t).ExpectFalse(instance.ReplicationGroupPrimaryKey.IsValid())
+	// Smallest reachable member
+	test.S(t).ExpectEquals(*instance.replicationGroupClusterKey(nil), key1)
+	// The member the cluster is named by keeps naming it while in the group
+	test.S(t).ExpectEquals(*instance.replicationGroupClusterKey(&key2), key2)
+	// ... but not once unreachable
+	instance.ReplicationGroupMembers[1].State = ReplicationGroupMemberStateUnreachable
+	test.S(t).ExpectEquals(*instance.replicationGroupClusterKey(&key2), key1)
+}
+
+func TestApplyReplicationGroupMembersOffline(t *testing.T) {
+	instance := newReplicationGroupTestInstance(true)
+	instance.applyReplicationGroupMembers([]ReplicationGroupMember{{UUID: instance.ServerUUID, State: ReplicationGroupMemberStateOffline}}, "")
+
+	test.S(t).ExpectFalse(instance.IsReplicationGroupMember())
+	test.S(t).ExpectTrue(instance.replicationGroupClusterKey(nil) == nil)
+}
+
+func TestReplicationGroupMembersJSON(t *testing.T) {
+	members := newReplicationGroupTestMembers()
+	membersJSON := replicationGroupMembersToJSON(members)
+	readMembers, err := replicationGroupMembersFromJSON(membersJSON)
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(readMembers), 3)
+	test.S(t).ExpectEquals(readMembers[2], members[2])
+
+	test.S(t).ExpectEquals(replicationGroupMembersToJSON(nil), "")
+	readMembers, err = replicationGroupMembersFromJSON("")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(readMembers), 0)
+}
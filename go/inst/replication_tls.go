@@ -0,0 +1,140 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/outbrain/golib/sqlutils"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+// MasterSSLSettings are the Master_SSL_* settings a slave replicates with, as presented by SHOW SLAVE STATUS
+type MasterSSLSettings struct {
+	Allowed          bool
+	CA               string
+	CAPath           string
+	Cert             string
+	Cipher           string
+	Key              string
+	CRL              string
+	CRLPath          string
+	VerifyServerCert bool
+}
+
+// readMasterSSLSettings reads the Master_SSL_* columns of a SHOW SLAVE STATUS row
+func readMasterSSLSettings(m sqlutils.RowMap) MasterSSLSettings {
+	return MasterSSLSettings{
+		Allowed:          (m.GetString("Master_SSL_Allowed") == "Yes"),
+		CA:               m.GetStringD("Master_SSL_CA_File", ""),
+		CAPath:           m.GetStringD("Master_SSL_CA_Path", ""),
+		Cert:             m.GetStringD("Master_SSL_Cert", ""),
+		Cipher:           m.GetStringD("Master_SSL_Cipher", ""),
+		Key:              m.GetStringD("Master_SSL_Key", ""),
+		CRL:              m.GetStringD("Master_SSL_Crl", ""),
+		CRLPath:          m.GetStringD("Master_SSL_Crlpath", ""),
+		VerifyServerCert: (m.GetStringD("Master_SSL_Verify_Server_Cert", "No") == "Yes"),
+	}
+}
+
+// changeMasterOptions returns the MASTER_SSL* options of a CHANGE MASTER TO statement, reproducing these settings
+func (this *MasterSSLSettings) changeMasterOptions() []string {
+	if !this.Allowed {
+		return []string{"master_ssl=0"}
+	}
+	options := []string{"master_ssl=1"}
+	addOption := func(name string, value string) {
+		if value != "" {
+			options = append(options, fmt.Sprintf("%s='%s'", name, strings.Replace(value, "'", "''", -1)))
+		}
+	}
+	addOption("master_ssl_ca", this.CA)
+	addOption("master_ssl_capath", this.CAPath)
+	addOption("master_ssl_cert", this.Cert)
+	addOption("master_ssl_cipher", this.Cipher)
+	addOption("master_ssl_key", this.Key)
+	addOption("master_ssl_crl", this.CRL)
+	addOption("master_ssl_crlpath", this.CRLPath)
+	if this.VerifyServerCert {
+		options = append(options, "master_ssl_verify_server_cert=1")
+	}
+	return options
+}
+
+// ChangeMasterClause returns the MASTER_SSL* options to append to a CHANGE MASTER TO statement so as to carry these
+// settings across the change. The clause is empty when TLS is not allowed, leaving the slave's settings untouched.
+func (this *MasterSSLSettings) ChangeMasterClause() string {
+	if !this.Allowed {
+		return ""
+	}
+	return ", " + strings.Join(this.changeMasterOptions(), ", ")
+}
+
+// configuredMasterSSLSettings returns the TLS settings enable-replication-tls sets up, based on the
+// MySQLReplicationSSL* configuration. Files not configured are kept as they are on the slave.
+func configuredMasterSSLSettings() MasterSSLSettings {
+	return MasterSSLSettings{
+		Allowed:          true,
+		CA:               config.Config.MySQLReplicationSSLCAFile,
+		Cert:             config.Config.MySQLReplicationSSLCertFile,
+		Key:              config.Config.MySQLReplicationSSLPrivateKeyFile,
+		VerifyServerCert: config.Config.MySQLReplicationSSLVerifyServerCert,
+	}
+}
+
+// slavesMasterSSLSettings returns the TLS settings with which given slaves, other than the excluded instance, replicate
+// over TLS: those of the first such slave. Returns TLS settings which are not allowed when no such slave replicates over TLS.
+func slavesMasterSSLSettings(slaves [](*Instance), excludeKey *InstanceKey) MasterSSLSettings {
+	for _, slave := range slaves {
+		if slave.Key.Equals(excludeKey) {
+			continue
+		}
+		if slave.MasterSSL.Allowed {
+			return slave.MasterSSL
+		}
+	}
+	return MasterSSLSettings{}
+}
+
+// ClusterReplicationTLS lists the slaves of a cluster by whether they replicate over TLS
+type ClusterReplicationTLS struct {
+	ClusterName string
+	Encrypted   []InstanceKey
+	Unencrypted []InstanceKey
+}
+
+// NewClusterReplicationTLS sorts given cluster instances by whether they replicate over TLS. Instances which
+// are not slaves are not listed.
+func NewClusterReplicationTLS(clusterName string, instances [](*Instance)) *ClusterReplicationTLS {
+	clusterReplicationTLS := &ClusterReplicationTLS{
+		ClusterName: clusterName,
+		Encrypted:   []InstanceKey{},
+		Unencrypted: []InstanceKey{},
+	}
+	for _, instance := range instances {
+		if !instance.IsSlave() {
+			continue
+		}
+		if instance.AllowTLS {
+			clusterReplicationTLS.Encrypted = append(clusterReplicationTLS.Encrypted, instance.Key)
+		} else {
+			clusterReplicationTLS.Unencrypted = append(clusterReplicationTLS.Unencrypted, instance.Key)
+		}
+	}
+	return clusterReplicationTLS
+}
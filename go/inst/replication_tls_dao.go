@@ -0,0 +1,26 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+// ReadClusterReplicationTLS reads the slaves of given cluster, sorted by whether they replicate over TLS
+func ReadClusterReplicationTLS(clusterName string) (*ClusterReplicationTLS, error) {
+	instances, err := ReadClusterInstances(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return NewClusterReplicationTLS(clusterName, instances), nil
+}
@@ -0,0 +1,86 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+)
+
+func TestMasterSSLChangeMasterClause(t *testing.T) {
+	masterSSL := MasterSSLSettings{Allowed: true, CA: "/etc/mysql/ca.pem", Cipher: "AES256-SHA", VerifyServerCert: true}
+	test.S(t).ExpectEquals(masterSSL.ChangeMasterClause(), ", master_ssl=1, master_ssl_ca='/etc/mysql/ca.pem', master_ssl_cipher='AES256-SHA', master_ssl_verify_server_cert=1")
+}
+
+func TestMasterSSLChangeMasterClauseQuoting(t *testing.T) {
+	masterSSL := MasterSSLSettings{Allowed: true, Key: "/etc/mysql/o'key.pem"}
+	test.S(t).ExpectEquals(masterSSL.ChangeMasterClause(), ", master_ssl=1, master_ssl_key='/etc/mysql/o''key.pem'")
+}
+
+func TestMasterSSLChangeMasterClauseNotAllowed(t *testing.T) {
+	masterSSL := MasterSSLSettings{Allowed: false, CA: "/etc/mysql/ca.pem"}
+	test.S(t).ExpectEquals(masterSSL.ChangeMasterClause(), "")
+	test.S(t).ExpectEquals(len(masterSSL.changeMasterOptions()), 1)
+	test.S(t).ExpectEquals(masterSSL.changeMasterOptions()[0], "master_ssl=0")
+}
+
+func TestApplyReplicationChannelMasterSSL(t *testing.T) {
+	instance := NewInstance()
+	instance.applyReplicationChannel(&ReplicationChannel{MasterKey: key1, MasterSSL: MasterSSLSettings{Allowed: true, CA: "/etc/mysql/ca.pem"}})
+	test.S(t).ExpectTrue(instance.AllowTLS)
+	test.S(t).ExpectEquals(instance.MasterSSL.CA, "/etc/mysql/ca.pem")
+}
+
+func TestNewClusterReplicationTLS(t *testing.T) {
+	master := NewInstance()
+	master.Key = key1
+	encryptedSlave := NewInstance()
+	encryptedSlave.Key = key2
+	encryptedSlave.MasterKey = key1
+	encryptedSlave.ReadBinlogCoordinates = BinlogCoordinates{LogFile: "mysql-bin.000001", LogPos: 4}
+	encryptedSlave.AllowTLS = true
+	unencryptedSlave := NewInstance()
+	unencryptedSlave.Key = key3
+	unencryptedSlave.MasterKey = key1
+	unencryptedSlave.ReadBinlogCoordinates = BinlogCoordinates{LogFile: "mysql-bin.000001", LogPos: 4}
+
+	clusterReplicationTLS := NewClusterReplicationTLS(key1.StringCode(), [](*Instance){master, encryptedSlave, unencryptedSlave})
+	test.S(t).ExpectEquals(len(clusterReplicationTLS.Encrypted), 1)
+	test.S(t).ExpectEquals(clusterReplicationTLS.Encrypted[0], key2)
+	test.S(t).ExpectEquals(len(clusterReplicationTLS.Unencrypted), 1)
+	test.S(t).ExpectEquals(clusterReplicationTLS.Unencrypted[0], key3)
+}
+
+func TestSlavesMasterSSLSettings(t *testing.T) {
+	unencryptedSlave := NewInstance()
+	unencryptedSlave.Key = key1
+	encryptedSlave := NewInstance()
+	encryptedSlave.Key = key2
+	encryptedSlave.MasterSSL = MasterSSLSettings{Allowed: true, CA: "/etc/mysql/ca.pem"}
+	instance := NewInstance()
+	instance.Key = key3
+	instance.MasterSSL = MasterSSLSettings{Allowed: true, CA: "/etc/mysql/other-ca.pem"}
+
+	masterSSL := slavesMasterSSLSettings([](*Instance){unencryptedSlave, instance, encryptedSlave}, &key3)
+	test.S(t).ExpectTrue(masterSSL.Allowed)
+	test.S(t).ExpectEquals(masterSSL.CA, "/etc/mysql/ca.pem")
+
+	masterSSL = slavesMasterSSLSettings([](*Instance){unencryptedSlave, instance}, &key3)
+	test.S(t).ExpectFalse(masterSSL.Allowed)
+	test.S(t).ExpectEquals(masterSSL.ChangeMasterClause(), "")
+}
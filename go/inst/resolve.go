@@ -25,6 +25,7 @@ import (
 	"net"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -37,9 +38,30 @@ func init() {
 	if config.Config.ExpiryHostnameResolvesMinutes < 1 {
 		config.Config.ExpiryHostnameResolvesMinutes = 1
 	}
+	config.OnChange(func(changes config.ConfigurationChanges) {
+		if changes.HasField("ExpiryHostnameResolvesMinutes") {
+			if config.Config.ExpiryHostnameResolvesMinutes < 1 {
+				config.Config.ExpiryHostnameResolvesMinutes = 1
+			}
+			// Keep resolved entries; only newly set entries get the new expiry
+			hostnameResolvesLightweightCacheMutex.Lock()
+			defer hostnameResolvesLightweightCacheMutex.Unlock()
+			hostnameResolvesLightweightCache = cache.NewFrom(time.Duration(config.Config.ExpiryHostnameResolvesMinutes)*time.Minute, time.Minute, hostnameResolvesLightweightCache.Items())
+		}
+	})
 }
 
+var hostnameResolvesLightweightCacheMutex sync.RWMutex
 var hostnameResolvesLightweightCache = cache.New(time.Duration(config.Config.ExpiryHostnameResolvesMinutes)*time.Minute, time.Minute)
+
+// getHostnameResolvesLightweightCache returns the cache of resolved hostnames, which is replaced upon
+// ExpiryHostnameResolvesMinutes change
+func getHostnameResolvesLightweightCache() *cache.Cache {
+	hostnameResolvesLightweightCacheMutex.RLock()
+	defer hostnameResolvesLightweightCacheMutex.RUnlock()
+	return hostnameResolvesLightweightCache
+}
+
 var hostnameResolvesLightweightCacheLoadedOnceFromDB bool = false
 
 func HostnameResolveMethodIsNone() bool {
@@ -85,7 +107,7 @@ func ResolveHostname(hostname string) (string, error) {
 	}
 
 	// First go to lightweight cache
-	if resolvedHostname, found := hostnameResolvesLightweightCache.Get(hostname); found {
+	if resolvedHostname, found := getHostnameResolvesLightweightCache().Get(hostname); found {
 		return resolvedHostname.(string), nil
 	}
 
@@ -96,7 +118,7 @@ func ResolveHostname(hostname string) (string, error) {
 		// let's try and get the resolved hostname from database.
 		if !HostnameResolveMethodIsNone() {
 			if resolvedHostname, err := ReadResolvedHostname(hostname); err == nil && resolvedHostname != "" {
-				hostnameResolvesLightweightCache.Set(hostname, resolvedHostname, 0)
+				getHostnameResolvesLightweightCache().Set(hostname, resolvedHostname, 0)
 				return resolvedHostname, nil
 			}
 		}
@@ -116,7 +138,7 @@ func ResolveHostname(hostname string) (string, error) {
 	if err != nil {
 		// Problem. What we'll do is cache the hostname for just one minute, so as to avoid flooding requests
 		// on one hand, yet make it refresh shortly on the other hand. Anyway do not write to database.
-		hostnameResolvesLightweightCache.Set(hostname, resolvedHostname, time.Minute)
+		getHostnameResolvesLightweightCache().Set(hostname, resolvedHostname, time.Minute)
 		return hostname, err
 	}
 	// Good result! Cache it, also to DB
@@ -132,10 +154,10 @@ func UpdateResolvedHostname(hostname string, resolvedHostname string) bool {
 	if resolvedHostname == "" {
 		return false
 	}
-	if existingResolvedHostname, found := hostnameResolvesLightweightCache.Get(hostname); found && (existingResolvedHostname == resolvedHostname) {
+	if existingResolvedHostname, found := getHostnameResolvesLightweightCache().Get(hostname); found && (existingResolvedHostname == resolvedHostname) {
 		return false
 	}
-	hostnameResolvesLightweightCache.Set(hostname, resolvedHostname, 0)
+	getHostnameResolvesLightweightCache().Set(hostname, resolvedHostname, 0)
 	if !HostnameResolveMethodIsNone() {
 		WriteResolvedHostname(hostname, resolvedHostname)
 	}
@@ -155,7 +177,7 @@ func loadHostnameResolveCacheFromDatabase() error {
 		return err
 	}
 	for _, hostnameResolve := range allHostnamesResolves {
-		hostnameResolvesLightweightCache.Set(hostnameResolve.hostname, hostnameResolve.resolvedHostname, 0)
+		getHostnameResolvesLightweightCache().Set(hostnameResolve.hostname, hostnameResolve.resolvedHostname, 0)
 	}
 	hostnameResolvesLightweightCacheLoadedOnceFromDB = true
 	return nil
@@ -167,7 +189,7 @@ func FlushNontrivialResolveCacheToDatabase() error {
 	}
 	items, _ := HostnameResolveCache()
 	for hostname := range items {
-		resolvedHostname, found := hostnameResolvesLightweightCache.Get(hostname)
+		resolvedHostname, found := getHostnameResolvesLightweightCache().Get(hostname)
 		if found && (resolvedHostname.(string) != hostname) {
 			WriteResolvedHostname(hostname, resolvedHostname.(string))
 		}
@@ -177,13 +199,13 @@ func FlushNontrivialResolveCacheToDatabase() error {
 
 func ResetHostnameResolveCache() error {
 	err := deleteHostnameResolves()
-	hostnameResolvesLightweightCache.Flush()
+	getHostnameResolvesLightweightCache().Flush()
 	hostnameResolvesLightweightCacheLoadedOnceFromDB = false
 	return err
 }
 
 func HostnameResolveCache() (map[string]*cache.Item, error) {
-	return hostnameResolvesLightweightCache.Items(), nil
+	return getHostnameResolvesLightweightCache().Items(), nil
 }
 
 func UnresolveHostname(instanceKey *InstanceKey) (InstanceKey, bool, error) {
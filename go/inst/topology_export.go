@@ -0,0 +1,285 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/outbrain/golib/math"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+const (
+	TopologyFormatASCII   = "ascii"
+	TopologyFormatJSON    = "json"
+	TopologyFormatDot     = "dot"
+	TopologyFormatMermaid = "mermaid"
+)
+
+// TopologyNode is a single instance in a nested replication tree, along with its slaves
+type TopologyNode struct {
+	Key                 InstanceKey
+	Title               string
+	Version             string
+	ReadOnly            bool
+	BinlogFormat        string
+	LogBinEnabled       bool
+	LogSlaveUpdates     bool
+	GTIDMode            string
+	Lag                 string
+	SecondsBehindMaster int64
+	IsDowntimed         bool
+	DowntimeReason      string
+	Problems            []string
+	Slaves              [](*TopologyNode)
+
+	ReplicationGroupMemberRole  string // only set on replication group members, which are listed under their group's primary
+	ReplicationGroupMemberState string
+	GaleraClusterStatus         string // only set on Galera nodes
+	GaleraLocalStateComment     string
+}
+
+// TopologyTree is the nested replication tree of a cluster. Roots is normally the single master,
+// or the co-masters in a master-master setup.
+type TopologyTree struct {
+	ClusterName string
+	Roots       [](*TopologyNode)
+}
+
+// getGTIDModeDescription returns a short description of the GTID mode an instance replicates with
+func getGTIDModeDescription(instance *Instance) string {
+	if instance.UsingOracleGTID {
+		return "GTID"
+	}
+	if instance.UsingMariaDBGTID {
+		return "MariaDB-GTID"
+	}
+	if instance.UsingPseudoGTID {
+		return "Pseudo-GTID"
+	}
+	return ""
+}
+
+// getInstanceProblems lists the problems of an instance, along the lines of ReadProblemInstances
+func getInstanceProblems(instance *Instance) []string {
+	problems := []string{}
+	if !instance.IsLastCheckValid {
+		problems = append(problems, "last check invalid")
+	}
+	if !instance.IsRecentlyChecked {
+		problems = append(problems, "not recently checked")
+	}
+	if instance.IsSlave() {
+		if !instance.Slave_SQL_Running {
+			problems = append(problems, "sql thread not running")
+		}
+		if !instance.Slave_IO_Running {
+			problems = append(problems, "io thread not running")
+		}
+		if instance.SecondsBehindMaster.Valid && math.AbsInt64(instance.SecondsBehindMaster.Int64-int64(instance.SQLDelay)) > int64(config.Config.ReasonableReplicationLagSeconds) {
+			problems = append(problems, "replication lag")
+		}
+	}
+	return problems
+}
+
+// newTopologyNode creates a tree node for given instance, and recursively for its slaves
+func newTopologyNode(instance *Instance, replicationMap map[*Instance]([]*Instance), annotateProblems bool) *TopologyNode {
+	node := &TopologyNode{
+		Key:             instance.Key,
+		Title:           instance.Key.DisplayString(),
+		Version:         instance.Version,
+		ReadOnly:        instance.ReadOnly,
+		BinlogFormat:    instance.Binlog_format,
+		LogBinEnabled:   instance.LogBinEnabled,
+		LogSlaveUpdates: instance.LogSlaveUpdatesEnabled,
+		GTIDMode:        getGTIDModeDescription(instance),
+		Lag:             instance.LagStatusString(),
+		IsDowntimed:     instance.IsDowntimed,
+		DowntimeReason:  instance.DowntimeReason,
+		Problems:        []string{},
+		Slaves:          [](*TopologyNode){},
+	}
+	if instance.SecondsBehindMaster.Valid {
+		node.SecondsBehindMaster = instance.SecondsBehindMaster.Int64
+	}
+	if instance.IsReplicationGroupMember() {
+		node.ReplicationGroupMemberRole = instance.ReplicationGroupMemberRole
+		node.ReplicationGroupMemberState = instance.ReplicationGroupMemberState
+	}
+	if instance.IsGaleraNode() {
+		node.GaleraClusterStatus = instance.GaleraClusterStatus
+		node.GaleraLocalStateComment = instance.GaleraLocalStateComment
+	}
+	if annotateProblems {
+		node.Problems = getInstanceProblems(instance)
+	}
+	for _, slave := range replicationMap[instance] {
+		if slave.IsCoMaster {
+			// Co-masters are each drawn as a root of their own
+			continue
+		}
+		node.Slaves = append(node.Slaves, newTopologyNode(slave, replicationMap, annotateProblems))
+	}
+	return node
+}
+
+// GetTopologyTree returns the nested replication tree of given cluster, either current or historical
+func GetTopologyTree(clusterName string, historyTimestampPattern string) (*TopologyTree, error) {
+	instances, err := readTopologyInstances(clusterName, historyTimestampPattern)
+	if err != nil {
+		return nil, err
+	}
+	tree := &TopologyTree{ClusterName: clusterName, Roots: [](*TopologyNode){}}
+
+	replicationMap, masterInstances := getReplicationMap(instances)
+	if len(masterInstances) == 0 {
+		// Co-masters? Each is the root of its own branch
+		for _, instance := range instances {
+			if instance.IsCoMaster {
+				masterInstances = append(masterInstances, instance)
+			}
+		}
+	}
+	for _, masterInstance := range masterInstances {
+		tree.Roots = append(tree.Roots, newTopologyNode(masterInstance, replicationMap, historyTimestampPattern == ""))
+	}
+	return tree, nil
+}
+
+// Description returns a short, human readable list of this node's properties
+func (this *TopologyNode) Description() string {
+	tokens := []string{this.Version}
+	if this.ReadOnly {
+		tokens = append(tokens, "ro")
+	} else {
+		tokens = append(tokens, "rw")
+	}
+	if this.LogBinEnabled {
+		tokens = append(tokens, this.BinlogFormat)
+	} else {
+		tokens = append(tokens, "nobinlog")
+	}
+	if this.GTIDMode != "" {
+		tokens = append(tokens, this.GTIDMode)
+	}
+	if this.ReplicationGroupMemberRole != "" {
+		tokens = append(tokens, fmt.Sprintf("group %s %s", this.ReplicationGroupMemberRole, this.ReplicationGroupMemberState))
+	}
+	if this.GaleraClusterStatus != "" {
+		tokens = append(tokens, fmt.Sprintf("galera %s %s", this.GaleraClusterStatus, this.GaleraLocalStateComment))
+	}
+	tokens = append(tokens, fmt.Sprintf("lag: %s", this.Lag))
+	if this.IsDowntimed {
+		tokens = append(tokens, "downtimed")
+	}
+	return strings.Join(tokens, " ")
+}
+
+// visitTopologyNodes calls given function on each node of the tree, parents before their slaves
+func (this *TopologyTree) visitTopologyNodes(visit func(node *TopologyNode, parent *TopologyNode)) {
+	var visitNode func(node *TopologyNode, parent *TopologyNode)
+	visitNode = func(node *TopologyNode, parent *TopologyNode) {
+		visit(node, parent)
+		for _, slave := range node.Slaves {
+			visitNode(slave, node)
+		}
+	}
+	for _, root := range this.Roots {
+		visitNode(root, nil)
+	}
+}
+
+// ToDot renders the tree in Graphviz DOT format
+func (this *TopologyTree) ToDot() string {
+	lines := []string{
+		fmt.Sprintf("digraph %q {", this.ClusterName),
+		"  node [shape=box];",
+	}
+	this.visitTopologyNodes(func(node *TopologyNode, parent *TopologyNode) {
+		label := fmt.Sprintf("%s\\n%s", node.Title, node.Description())
+		if len(node.Problems) > 0 {
+			label = fmt.Sprintf("%s\\n%s", label, strings.Join(node.Problems, ", "))
+		}
+		attributes := []string{fmt.Sprintf("label=%q", label)}
+		if len(node.Problems) > 0 {
+			attributes = append(attributes, "color=red")
+		}
+		if node.IsDowntimed {
+			attributes = append(attributes, "style=dashed")
+		}
+		lines = append(lines, fmt.Sprintf("  %q [%s];", node.Title, strings.Join(attributes, ", ")))
+		if parent != nil {
+			lines = append(lines, fmt.Sprintf("  %q -> %q;", parent.Title, node.Title))
+		}
+	})
+	lines = append(lines, "}")
+	return strings.Join(lines, "\n")
+}
+
+// ToMermaid renders the tree as a mermaid flowchart
+func (this *TopologyTree) ToMermaid() string {
+	lines := []string{"graph TD"}
+	nodeIds := make(map[*TopologyNode]string)
+	this.visitTopologyNodes(func(node *TopologyNode, parent *TopologyNode) {
+		nodeId := fmt.Sprintf("n%d", len(nodeIds))
+		nodeIds[node] = nodeId
+
+		label := fmt.Sprintf("%s<br/>%s", node.Title, node.Description())
+		if len(node.Problems) > 0 {
+			label = fmt.Sprintf("%s<br/>%s", label, strings.Join(node.Problems, ", "))
+		}
+		lines = append(lines, fmt.Sprintf("  %s[\"%s\"]", nodeId, strings.Replace(label, `"`, "#quot;", -1)))
+		if parent != nil {
+			lines = append(lines, fmt.Sprintf("  %s --> %s", nodeIds[parent], nodeId))
+		}
+		if len(node.Problems) > 0 {
+			lines = append(lines, fmt.Sprintf("  style %s stroke:#f00", nodeId))
+		} else if node.IsDowntimed {
+			lines = append(lines, fmt.Sprintf("  style %s stroke-dasharray:5", nodeId))
+		}
+	})
+	return strings.Join(lines, "\n")
+}
+
+// ExportTopology renders the topology of given cluster in given format: ascii, json, dot or mermaid
+func ExportTopology(clusterName string, historyTimestampPattern string, format string) (string, error) {
+	if format == "" || format == TopologyFormatASCII {
+		return ASCIITopology(clusterName, historyTimestampPattern)
+	}
+	tree, err := GetTopologyTree(clusterName, historyTimestampPattern)
+	if err != nil {
+		return "", err
+	}
+	switch format {
+	case TopologyFormatJSON:
+		{
+			output, err := json.MarshalIndent(tree, "", "  ")
+			if err != nil {
+				return "", err
+			}
+			return string(output), nil
+		}
+	case TopologyFormatDot:
+		return tree.ToDot(), nil
+	case TopologyFormatMermaid:
+		return tree.ToMermaid(), nil
+	}
+	return "", fmt.Errorf("Unknown topology format: %s. Expected one of: %s, %s, %s, %s", format, TopologyFormatASCII, TopologyFormatJSON, TopologyFormatDot, TopologyFormatMermaid)
+}
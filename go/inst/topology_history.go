@@ -0,0 +1,179 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const topologyHistoryTimeLayout = "2006-01-02 15:04:05"
+
+var topologyHistoryTimeLayouts = []string{
+	topologyHistoryTimeLayout,
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"2006-01-02",
+}
+
+var unixTimestampRegexp = regexp.MustCompile("^[0-9]+$")
+
+// TopologySnapshot is the state of a cluster's topology at some point in time.
+// A zero SnapshotUnixTimestamp indicates the current (live) topology.
+type TopologySnapshot struct {
+	ClusterName           string
+	SnapshotUnixTimestamp int64
+	SnapshotTimestamp     string
+	Instances             [](*Instance)
+}
+
+// InstanceTopologyMove describes an instance which changed its master between two snapshots
+type InstanceTopologyMove struct {
+	Key           InstanceKey
+	FromMasterKey InstanceKey
+	ToMasterKey   InstanceKey
+}
+
+// InstanceTopologyReconfiguration describes an instance whose configuration changed between two snapshots
+type InstanceTopologyReconfiguration struct {
+	Key     InstanceKey
+	Changes []string
+}
+
+// TopologyDiff lists the differences between two snapshots of a cluster's topology
+type TopologyDiff struct {
+	ClusterName           string
+	FromTimestamp         string
+	ToTimestamp           string
+	AddedInstances        []InstanceKey
+	RemovedInstances      []InstanceKey
+	MovedInstances        []InstanceTopologyMove
+	ReconfiguredInstances []InstanceTopologyReconfiguration
+}
+
+// IsEmpty returns true when there are no differences
+func (this *TopologyDiff) IsEmpty() bool {
+	return len(this.AddedInstances) == 0 && len(this.RemovedInstances) == 0 && len(this.MovedInstances) == 0 && len(this.ReconfiguredInstances) == 0
+}
+
+// ParseTopologyHistoryTime parses a point in time as given by a user. This can be a unix timestamp or
+// a local date/time such as "2016-05-01 03:14". An empty string parses as the zero time, which stands for "now".
+func ParseTopologyHistoryTime(timeString string) (time.Time, error) {
+	if timeString == "" {
+		return time.Time{}, nil
+	}
+	if unixTimestampRegexp.MatchString(timeString) {
+		unixTimestamp, err := strconv.ParseInt(timeString, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(unixTimestamp, 0), nil
+	}
+	for _, layout := range topologyHistoryTimeLayouts {
+		if t, err := time.ParseInLocation(layout, timeString, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Cannot parse time: %s. Expected a unix timestamp or a format such as %s", timeString, topologyHistoryTimeLayout)
+}
+
+// InstanceKeysByStringCode is a sortable type for InstanceKey
+type InstanceKeysByStringCode []InstanceKey
+
+func (this InstanceKeysByStringCode) Len() int      { return len(this) }
+func (this InstanceKeysByStringCode) Swap(i, j int) { this[i], this[j] = this[j], this[i] }
+func (this InstanceKeysByStringCode) Less(i, j int) bool {
+	return this[i].StringCode() < this[j].StringCode()
+}
+
+// sortedInstanceKeys returns the keys of given map sorted by their string representation
+func sortedInstanceKeys(instancesMap map[InstanceKey](*Instance)) []InstanceKey {
+	keys := []InstanceKey{}
+	for key := range instancesMap {
+		keys = append(keys, key)
+	}
+	sort.Sort(InstanceKeysByStringCode(keys))
+	return keys
+}
+
+// getInstanceReconfigurationChanges lists the configuration differences between two states of an instance
+func getInstanceReconfigurationChanges(fromInstance, toInstance *Instance) []string {
+	changes := []string{}
+	if fromInstance.ReadOnly != toInstance.ReadOnly {
+		changes = append(changes, fmt.Sprintf("read_only: %t -> %t", fromInstance.ReadOnly, toInstance.ReadOnly))
+	}
+	if fromInstance.UsingOracleGTID != toInstance.UsingOracleGTID {
+		changes = append(changes, fmt.Sprintf("oracle_gtid: %t -> %t", fromInstance.UsingOracleGTID, toInstance.UsingOracleGTID))
+	}
+	if fromInstance.UsingMariaDBGTID != toInstance.UsingMariaDBGTID {
+		changes = append(changes, fmt.Sprintf("mariadb_gtid: %t -> %t", fromInstance.UsingMariaDBGTID, toInstance.UsingMariaDBGTID))
+	}
+	if fromInstance.Version != toInstance.Version {
+		changes = append(changes, fmt.Sprintf("version: %s -> %s", fromInstance.Version, toInstance.Version))
+	}
+	if fromInstance.DataCenter != toInstance.DataCenter {
+		changes = append(changes, fmt.Sprintf("data_center: %s -> %s", fromInstance.DataCenter, toInstance.DataCenter))
+	}
+	if fromInstance.IsDowntimed != toInstance.IsDowntimed {
+		changes = append(changes, fmt.Sprintf("downtimed: %t -> %t", fromInstance.IsDowntimed, toInstance.IsDowntimed))
+	}
+	return changes
+}
+
+// DiffTopologies compares two states of a topology and reports added, removed, moved and reconfigured instances.
+// Changes in coordinates and lag are expected and are not reported.
+func DiffTopologies(fromInstances, toInstances [](*Instance)) *TopologyDiff {
+	diff := &TopologyDiff{
+		AddedInstances:        []InstanceKey{},
+		RemovedInstances:      []InstanceKey{},
+		MovedInstances:        []InstanceTopologyMove{},
+		ReconfiguredInstances: []InstanceTopologyReconfiguration{},
+	}
+	fromMap := make(map[InstanceKey](*Instance))
+	for _, instance := range fromInstances {
+		fromMap[instance.Key] = instance
+	}
+	toMap := make(map[InstanceKey](*Instance))
+	for _, instance := range toInstances {
+		toMap[instance.Key] = instance
+	}
+
+	for _, key := range sortedInstanceKeys(fromMap) {
+		if _, found := toMap[key]; !found {
+			diff.RemovedInstances = append(diff.RemovedInstances, key)
+		}
+	}
+	for _, key := range sortedInstanceKeys(toMap) {
+		toInstance := toMap[key]
+		fromInstance, found := fromMap[key]
+		if !found {
+			diff.AddedInstances = append(diff.AddedInstances, key)
+			continue
+		}
+		if !fromInstance.MasterKey.Equals(&toInstance.MasterKey) {
+			diff.MovedInstances = append(diff.MovedInstances, InstanceTopologyMove{Key: key, FromMasterKey: fromInstance.MasterKey, ToMasterKey: toInstance.MasterKey})
+		}
+		if changes := getInstanceReconfigurationChanges(fromInstance, toInstance); len(changes) > 0 {
+			diff.ReconfiguredInstances = append(diff.ReconfiguredInstances, InstanceTopologyReconfiguration{Key: key, Changes: changes})
+		}
+	}
+	return diff
+}
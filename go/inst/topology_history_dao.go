@@ -0,0 +1,147 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package inst
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/golib/sqlutils"
+	"github.com/outbrain/orchestrator/go/db"
+)
+
+// readTopologyHistoryRow reads a single (thin) instance row from database_instance_topology_history
+func readTopologyHistoryRow(m sqlutils.RowMap) *Instance {
+	instance := NewInstance()
+
+	instance.Key.Hostname = m.GetString("hostname")
+	instance.Key.Port = m.GetInt("port")
+	instance.MasterKey.Hostname = m.GetString("master_host")
+	instance.MasterKey.Port = m.GetInt("master_port")
+	instance.ClusterName = m.GetString("cluster_name")
+	instance.Version = m.GetString("version")
+	instance.SelfBinlogCoordinates.LogFile = m.GetString("binary_log_file")
+	instance.SelfBinlogCoordinates.LogPos = m.GetInt64("binary_log_pos")
+	instance.ExecBinlogCoordinates.LogFile = m.GetString("relay_master_log_file")
+	instance.ExecBinlogCoordinates.LogPos = m.GetInt64("exec_master_log_pos")
+	instance.SecondsBehindMaster = m.GetNullInt64("seconds_behind_master")
+	instance.SlaveLagSeconds = m.GetNullInt64("slave_lag_seconds")
+	instance.ReadOnly = m.GetBool("read_only")
+	instance.UsingOracleGTID = m.GetBool("oracle_gtid")
+	instance.UsingMariaDBGTID = m.GetBool("mariadb_gtid")
+	instance.DataCenter = m.GetString("data_center")
+	instance.IsDowntimed = m.GetBool("is_downtimed")
+
+	return instance
+}
+
+// readTopologySnapshotUnixTimestamp returns the timestamp of the latest topology snapshot taken at or before given time
+func readTopologySnapshotUnixTimestamp(at time.Time) (snapshotUnixTimestamp int64, err error) {
+	query := `
+		select
+			ifnull(max(snapshot_unix_timestamp), 0) as snapshot_unix_timestamp
+		from
+			database_instance_topology_history
+		where
+			snapshot_unix_timestamp <= ?
+		`
+	err = db.QueryOrchestrator(query, sqlutils.Args(at.Unix()), func(m sqlutils.RowMap) error {
+		snapshotUnixTimestamp = m.GetInt64("snapshot_unix_timestamp")
+		return nil
+	})
+	if err != nil {
+		return 0, log.Errore(err)
+	}
+	if snapshotUnixTimestamp == 0 {
+		return 0, fmt.Errorf("No topology snapshot found at or before %s", at.Format(topologyHistoryTimeLayout))
+	}
+	return snapshotUnixTimestamp, nil
+}
+
+// ReadClusterTopologyAt reads the topology of given cluster as recorded by the latest snapshot taken at or before given time.
+// Since a cluster's name changes upon master failover, the snapshot also includes instances recorded under any
+// cluster name which current members of the cluster had at the time.
+// A zero time reads the current topology.
+func ReadClusterTopologyAt(clusterName string, at time.Time) (*TopologySnapshot, error) {
+	snapshot := &TopologySnapshot{ClusterName: clusterName, Instances: [](*Instance){}}
+	if at.IsZero() {
+		instances, err := ReadClusterInstances(clusterName)
+		if err != nil {
+			return snapshot, err
+		}
+		snapshot.SnapshotTimestamp = time.Now().Format(topologyHistoryTimeLayout)
+		snapshot.Instances = instances
+		return snapshot, nil
+	}
+
+	snapshotUnixTimestamp, err := readTopologySnapshotUnixTimestamp(at)
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.SnapshotUnixTimestamp = snapshotUnixTimestamp
+	snapshot.SnapshotTimestamp = time.Unix(snapshotUnixTimestamp, 0).Format(topologyHistoryTimeLayout)
+
+	query := `
+		select
+			history.*
+		from
+			database_instance_topology_history history
+		where
+			history.snapshot_unix_timestamp = ?
+			and (
+				history.cluster_name = ?
+				or history.cluster_name in (
+					select
+						member_history.cluster_name
+					from
+						database_instance_topology_history member_history
+						join database_instance using (hostname, port)
+					where
+						member_history.snapshot_unix_timestamp = ?
+						and database_instance.cluster_name = ?
+				)
+			)
+		order by
+			hostname, port`
+	err = db.QueryOrchestrator(query, sqlutils.Args(snapshotUnixTimestamp, clusterName, snapshotUnixTimestamp, clusterName), func(m sqlutils.RowMap) error {
+		snapshot.Instances = append(snapshot.Instances, readTopologyHistoryRow(m))
+		return nil
+	})
+	if err != nil {
+		return snapshot, log.Errore(err)
+	}
+	return snapshot, nil
+}
+
+// DiffClusterTopology compares the topology of given cluster at two points in time.
+// A zero time stands for the current topology.
+func DiffClusterTopology(clusterName string, from time.Time, to time.Time) (*TopologyDiff, error) {
+	fromSnapshot, err := ReadClusterTopologyAt(clusterName, from)
+	if err != nil {
+		return nil, err
+	}
+	toSnapshot, err := ReadClusterTopologyAt(clusterName, to)
+	if err != nil {
+		return nil, err
+	}
+	diff := DiffTopologies(fromSnapshot.Instances, toSnapshot.Instances)
+	diff.ClusterName = clusterName
+	diff.FromTimestamp = fromSnapshot.SnapshotTimestamp
+	diff.ToTimestamp = toSnapshot.SnapshotTimestamp
+	return diff, nil
+}
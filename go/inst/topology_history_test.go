@@ -0,0 +1,92 @@
+package inst
+
+import (
+	test "github.com/outbrain/golib/tests"
+	"testing"
+	"time"
+)
+
+func generateTestTopology() [](*Instance) {
+	instances, _ := generateTestInstances()
+	for _, instance := range instances {
+		instance.MasterKey = i710Key
+		instance.ReadOnly = true
+	}
+	instances[0].MasterKey = InstanceKey{}
+	instances[0].ReadOnly = false
+	return instances
+}
+
+func TestDiffTopologiesIdentical(t *testing.T) {
+	diff := DiffTopologies(generateTestTopology(), generateTestTopology())
+	test.S(t).ExpectTrue(diff.IsEmpty())
+}
+
+func TestDiffTopologiesIgnoresCoordinates(t *testing.T) {
+	toInstances := generateTestTopology()
+	for _, instance := range toInstances {
+		instance.ExecBinlogCoordinates.LogPos += 1000
+	}
+	diff := DiffTopologies(generateTestTopology(), toInstances)
+	test.S(t).ExpectTrue(diff.IsEmpty())
+}
+
+func TestDiffTopologies(t *testing.T) {
+	fromInstances := generateTestTopology()
+	toInstances := generateTestTopology()
+	// i830 removed; i910 added
+	toInstances = toInstances[0:5]
+	toInstances = append(toInstances, &Instance{Key: InstanceKey{Hostname: "i910", Port: 3306}, MasterKey: i710Key})
+	// i820 moved below i810
+	toInstances[4].MasterKey = i810Key
+	// i720 made writable and downtimed
+	toInstances[1].ReadOnly = false
+	toInstances[1].IsDowntimed = true
+
+	diff := DiffTopologies(fromInstances, toInstances)
+	test.S(t).ExpectFalse(diff.IsEmpty())
+
+	test.S(t).ExpectEquals(len(diff.AddedInstances), 1)
+	test.S(t).ExpectEquals(diff.AddedInstances[0].Hostname, "i910")
+
+	test.S(t).ExpectEquals(len(diff.RemovedInstances), 1)
+	test.S(t).ExpectEquals(diff.RemovedInstances[0], i830Key)
+
+	test.S(t).ExpectEquals(len(diff.MovedInstances), 1)
+	test.S(t).ExpectEquals(diff.MovedInstances[0].Key, i820Key)
+	test.S(t).ExpectEquals(diff.MovedInstances[0].FromMasterKey, i710Key)
+	test.S(t).ExpectEquals(diff.MovedInstances[0].ToMasterKey, i810Key)
+
+	test.S(t).ExpectEquals(len(diff.ReconfiguredInstances), 1)
+	test.S(t).ExpectEquals(diff.ReconfiguredInstances[0].Key, i720Key)
+	test.S(t).ExpectEquals(len(diff.ReconfiguredInstances[0].Changes), 2)
+	test.S(t).ExpectEquals(diff.ReconfiguredInstances[0].Changes[0], "read_only: true -> false")
+	test.S(t).ExpectEquals(diff.ReconfiguredInstances[0].Changes[1], "downtimed: false -> true")
+}
+
+func TestParseTopologyHistoryTime(t *testing.T) {
+	{
+		at, err := ParseTopologyHistoryTime("")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectTrue(at.IsZero())
+	}
+	{
+		at, err := ParseTopologyHistoryTime("1462072440")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(at.Unix(), int64(1462072440))
+	}
+	{
+		at, err := ParseTopologyHistoryTime("2016-05-01 03:14")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(at, time.Date(2016, 5, 1, 3, 14, 0, 0, time.Local))
+	}
+	{
+		at, err := ParseTopologyHistoryTime("2016-05-01 03:14:15")
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(at, time.Date(2016, 5, 1, 3, 14, 15, 0, time.Local))
+	}
+	{
+		_, err := ParseTopologyHistoryTime("03:14")
+		test.S(t).ExpectNotNil(err)
+	}
+}
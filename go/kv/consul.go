@@ -0,0 +1,110 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package kv
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ConsulStore publishes into Consul's KV store via the HTTP API
+type ConsulStore struct {
+	scheme   string
+	address  string
+	aclToken string
+	client   *http.Client
+}
+
+func NewConsulStore(scheme string, address string, aclToken string) *ConsulStore {
+	if scheme == "" {
+		scheme = "http"
+	}
+	return &ConsulStore{
+		scheme:   scheme,
+		address:  address,
+		aclToken: aclToken,
+		client:   newHttpClient(),
+	}
+}
+
+func (this *ConsulStore) keyURL(key string) string {
+	return fmt.Sprintf("%s://%s/v1/kv/%s", this.scheme, this.address, strings.TrimPrefix(key, "/"))
+}
+
+func (this *ConsulStore) newRequest(method string, key string, value string) (*http.Request, error) {
+	request, err := http.NewRequest(method, this.keyURL(key), strings.NewReader(value))
+	if err != nil {
+		return request, err
+	}
+	if this.aclToken != "" {
+		request.Header.Set("X-Consul-Token", this.aclToken)
+	}
+	return request, nil
+}
+
+func (this *ConsulStore) PutKeyValue(key string, value string) error {
+	request, err := this.newRequest("PUT", key, value)
+	if err != nil {
+		return err
+	}
+	body, _, err := doRequest(this.client, request)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(body)) != "true" {
+		return fmt.Errorf("consul: failed writing key %s: %s", key, string(body))
+	}
+	return nil
+}
+
+func (this *ConsulStore) GetKeyValue(key string) (value string, found bool, err error) {
+	request, err := this.newRequest("GET", key, "")
+	if err != nil {
+		return value, found, err
+	}
+	request.URL.RawQuery = "raw"
+	body, statusCode, err := doRequest(this.client, request)
+	if err != nil || statusCode == http.StatusNotFound {
+		return value, found, err
+	}
+	return string(body), true, nil
+}
+
+func (this *ConsulStore) ListKeys(prefix string) (keys []string, err error) {
+	request, err := this.newRequest("GET", prefix, "")
+	if err != nil {
+		return keys, err
+	}
+	request.URL.RawQuery = "keys"
+	body, statusCode, err := doRequest(this.client, request)
+	if err != nil || statusCode == http.StatusNotFound {
+		return keys, err
+	}
+	err = json.Unmarshal(body, &keys)
+	return keys, err
+}
+
+func (this *ConsulStore) DeleteKeyValue(key string) error {
+	request, err := this.newRequest("DELETE", key, "")
+	if err != nil {
+		return err
+	}
+	_, _, err = doRequest(this.client, request)
+	return err
+}
@@ -0,0 +1,132 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package kv
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// EtcdStore publishes into etcd via its v2 keys HTTP API
+type EtcdStore struct {
+	scheme  string
+	address string
+	client  *http.Client
+}
+
+// etcdNode is a key, or a directory of keys, in an etcd v2 keys API response
+type etcdNode struct {
+	Key   string     `json:"key"`
+	Value string     `json:"value"`
+	Dir   bool       `json:"dir"`
+	Nodes []etcdNode `json:"nodes"`
+}
+
+// etcdResponse is the subset of an etcd v2 keys API response orchestrator cares about
+type etcdResponse struct {
+	Node etcdNode `json:"node"`
+}
+
+// leafKeys returns the keys, as opposed to directories, in the tree of given node
+func (this *etcdNode) leafKeys() (keys []string) {
+	if !this.Dir {
+		return append(keys, strings.TrimPrefix(this.Key, "/"))
+	}
+	for i := range this.Nodes {
+		keys = append(keys, this.Nodes[i].leafKeys()...)
+	}
+	return keys
+}
+
+func NewEtcdStore(scheme string, address string) *EtcdStore {
+	if scheme == "" {
+		scheme = "http"
+	}
+	return &EtcdStore{
+		scheme:  scheme,
+		address: address,
+		client:  newHttpClient(),
+	}
+}
+
+func (this *EtcdStore) keyURL(key string) string {
+	return fmt.Sprintf("%s://%s/v2/keys/%s", this.scheme, this.address, strings.TrimPrefix(key, "/"))
+}
+
+func (this *EtcdStore) PutKeyValue(key string, value string) error {
+	form := url.Values{}
+	form.Set("value", value)
+	request, err := http.NewRequest("PUT", this.keyURL(key), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, statusCode, err := doRequest(this.client, request)
+	if err != nil {
+		return err
+	}
+	if statusCode == http.StatusNotFound {
+		return fmt.Errorf("etcd: failed writing key %s", key)
+	}
+	return nil
+}
+
+func (this *EtcdStore) GetKeyValue(key string) (value string, found bool, err error) {
+	request, err := http.NewRequest("GET", this.keyURL(key), nil)
+	if err != nil {
+		return value, found, err
+	}
+	body, statusCode, err := doRequest(this.client, request)
+	if err != nil || statusCode == http.StatusNotFound {
+		return value, found, err
+	}
+	response := etcdResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return value, found, err
+	}
+	return response.Node.Value, true, nil
+}
+
+// ListKeys returns the keys in the directory of given prefix; etcd directories are taken to be delimited by "/"
+func (this *EtcdStore) ListKeys(prefix string) (keys []string, err error) {
+	request, err := http.NewRequest("GET", this.keyURL(prefix), nil)
+	if err != nil {
+		return keys, err
+	}
+	request.URL.RawQuery = "recursive=true"
+	body, statusCode, err := doRequest(this.client, request)
+	if err != nil || statusCode == http.StatusNotFound {
+		return keys, err
+	}
+	response := etcdResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return keys, err
+	}
+	return response.Node.leafKeys(), nil
+}
+
+func (this *EtcdStore) DeleteKeyValue(key string) error {
+	request, err := http.NewRequest("DELETE", this.keyURL(key), nil)
+	if err != nil {
+		return err
+	}
+	_, _, err = doRequest(this.client, request)
+	return err
+}
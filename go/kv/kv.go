@@ -0,0 +1,133 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package kv
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+// KVPair is a key-value entry published to key-value stores
+type KVPair struct {
+	Key   string
+	Value string
+}
+
+func NewKVPair(key string, value string) *KVPair {
+	return &KVPair{Key: key, Value: value}
+}
+
+func (this *KVPair) String() string {
+	return fmt.Sprintf("%s:%s", this.Key, this.Value)
+}
+
+// KVStore is a key-value store orchestrator publishes cluster information into,
+// such as Consul or etcd
+type KVStore interface {
+	PutKeyValue(key string, value string) error
+	GetKeyValue(key string) (value string, found bool, err error)
+	DeleteKeyValue(key string) error
+	ListKeys(prefix string) (keys []string, err error)
+}
+
+// GetKVStores returns the key-value stores made available by configuration
+func GetKVStores() (stores []KVStore) {
+	if config.Config.ConsulAddress != "" {
+		stores = append(stores, NewConsulStore(config.Config.ConsulScheme, config.Config.ConsulAddress, config.Config.ConsulAclToken))
+	}
+	if config.Config.EtcdAddress != "" {
+		stores = append(stores, NewEtcdStore(config.Config.EtcdScheme, config.Config.EtcdAddress))
+	}
+	return stores
+}
+
+// HasKVStores returns true when at least one key-value store is configured
+func HasKVStores() bool {
+	return len(GetKVStores()) > 0
+}
+
+// PutKVPair writes given pair to all configured stores. An error in one store does not
+// prevent writing to the others; the last error is returned.
+func PutKVPair(kvPair *KVPair) (err error) {
+	for _, store := range GetKVStores() {
+		if storeErr := store.PutKeyValue(kvPair.Key, kvPair.Value); storeErr != nil {
+			err = storeErr
+		}
+	}
+	return err
+}
+
+// DeleteKey removes given key from all configured stores. An error in one store does not
+// prevent deleting from the others; the last error is returned.
+func DeleteKey(key string) (err error) {
+	for _, store := range GetKVStores() {
+		if storeErr := store.DeleteKeyValue(key); storeErr != nil {
+			err = storeErr
+		}
+	}
+	return err
+}
+
+// ListKeys returns the keys found under given prefix in any of the configured stores. An error in one store does not
+// prevent listing the others; the last error is returned.
+func ListKeys(prefix string) (keys []string, err error) {
+	listedKeys := make(map[string]bool)
+	for _, store := range GetKVStores() {
+		storeKeys, storeErr := store.ListKeys(prefix)
+		if storeErr != nil {
+			err = storeErr
+			continue
+		}
+		for _, key := range storeKeys {
+			if !listedKeys[key] {
+				listedKeys[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, err
+}
+
+func newHttpClient() *http.Client {
+	return &http.Client{Timeout: time.Duration(config.Config.HttpTimeoutSeconds) * time.Second}
+}
+
+// doRequest executes given request and returns the response body along with its status code.
+// Any status other than 2xx and 404 is reported as error.
+func doRequest(client *http.Client, request *http.Request) (body []byte, statusCode int, err error) {
+	response, err := client.Do(request)
+	if err != nil {
+		return body, statusCode, err
+	}
+	defer response.Body.Close()
+
+	statusCode = response.StatusCode
+	if body, err = ioutil.ReadAll(response.Body); err != nil {
+		return body, statusCode, err
+	}
+	if statusCode == http.StatusNotFound {
+		return body, statusCode, nil
+	}
+	if statusCode < 200 || statusCode >= 300 {
+		return body, statusCode, fmt.Errorf("%s %s: unexpected status %d: %s", request.Method, request.URL.Path, statusCode, string(body))
+	}
+	return body, statusCode, nil
+}
@@ -0,0 +1,246 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package kv
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/config"
+)
+
+// fakeKVServer is an in-memory stand-in for both Consul's KV HTTP API and etcd's v2 keys API
+type fakeKVServer struct {
+	mutex     sync.Mutex
+	server    *httptest.Server
+	values    map[string]string
+	aclTokens []string
+}
+
+func newFakeKVServer() *fakeKVServer {
+	kvServer := &fakeKVServer{values: make(map[string]string)}
+	kvServer.server = httptest.NewServer(http.HandlerFunc(kvServer.serve))
+	return kvServer
+}
+
+func (this *fakeKVServer) address() string {
+	return strings.TrimPrefix(this.server.URL, "http://")
+}
+
+func (this *fakeKVServer) serve(w http.ResponseWriter, r *http.Request) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/"):
+		this.serveConsul(w, r, strings.TrimPrefix(r.URL.Path, "/v1/kv/"))
+	case strings.HasPrefix(r.URL.Path, "/v2/keys/"):
+		this.serveEtcd(w, r, strings.TrimPrefix(r.URL.Path, "/v2/keys/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (this *fakeKVServer) serveConsul(w http.ResponseWriter, r *http.Request, key string) {
+	if r.Method != "GET" {
+		this.aclTokens = append(this.aclTokens, r.Header.Get("X-Consul-Token"))
+	}
+	switch r.Method {
+	case "PUT":
+		body, _ := ioutil.ReadAll(r.Body)
+		this.values[key] = string(body)
+		w.Write([]byte("true"))
+	case "GET":
+		if _, listing := r.URL.Query()["keys"]; listing {
+			keys := this.keysWithPrefix(key)
+			if len(keys) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(keys)
+			return
+		}
+		value, found := this.values[key]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(value))
+	case "DELETE":
+		delete(this.values, key)
+		w.Write([]byte("true"))
+	}
+}
+
+// keysWithPrefix returns the stored keys starting with given prefix, sorted
+func (this *fakeKVServer) keysWithPrefix(prefix string) (keys []string) {
+	for key := range this.values {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (this *fakeKVServer) serveEtcd(w http.ResponseWriter, r *http.Request, key string) {
+	response := etcdResponse{}
+	response.Node.Key = "/" + key
+	if r.Method == "GET" && r.URL.Query().Get("recursive") == "true" {
+		// A flat directory will do: orchestrator only flattens the tree anyway
+		keys := this.keysWithPrefix(strings.TrimSuffix(key, "/") + "/")
+		if len(keys) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorCode":100,"message":"Key not found"}`))
+			return
+		}
+		response.Node.Dir = true
+		for _, childKey := range keys {
+			response.Node.Nodes = append(response.Node.Nodes, etcdNode{Key: "/" + childKey, Value: this.values[childKey]})
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+	switch r.Method {
+	case "PUT":
+		r.ParseForm()
+		this.values[key] = r.PostForm.Get("value")
+		response.Node.Value = this.values[key]
+	case "GET", "DELETE":
+		value, found := this.values[key]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorCode":100,"message":"Key not found"}`))
+			return
+		}
+		response.Node.Value = value
+		if r.Method == "DELETE" {
+			delete(this.values, key)
+		}
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func testKVStore(t *testing.T, store KVStore) {
+	_, found, err := store.GetKeyValue("mysql/master/cluster1")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectFalse(found)
+
+	err = store.PutKeyValue("mysql/master/cluster1", "host1:3306")
+	test.S(t).ExpectNil(err)
+	value, found, err := store.GetKeyValue("mysql/master/cluster1")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectTrue(found)
+	test.S(t).ExpectEquals(value, "host1:3306")
+
+	err = store.PutKeyValue("mysql/master/cluster1", "host2:3306")
+	test.S(t).ExpectNil(err)
+	value, _, _ = store.GetKeyValue("mysql/master/cluster1")
+	test.S(t).ExpectEquals(value, "host2:3306")
+
+	keys, err := store.ListKeys("mysql/replica/cluster1/")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(keys), 0)
+	store.PutKeyValue("mysql/replica/cluster1/host3:3306", "host3:3306")
+	store.PutKeyValue("mysql/replica/cluster1/host4:3306", "host4:3306")
+	store.PutKeyValue("mysql/replica/cluster10/host5:3306", "host5:3306")
+	keys, err = store.ListKeys("mysql/replica/cluster1/")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(keys), 2)
+	test.S(t).ExpectEquals(keys[0], "mysql/replica/cluster1/host3:3306")
+	test.S(t).ExpectEquals(keys[1], "mysql/replica/cluster1/host4:3306")
+
+	err = store.DeleteKeyValue("mysql/master/cluster1")
+	test.S(t).ExpectNil(err)
+	_, found, err = store.GetKeyValue("mysql/master/cluster1")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectFalse(found)
+}
+
+func TestConsulStore(t *testing.T) {
+	kvServer := newFakeKVServer()
+	defer kvServer.server.Close()
+
+	testKVStore(t, NewConsulStore("http", kvServer.address(), "secret-token"))
+	test.S(t).ExpectEquals(len(kvServer.aclTokens), 6)
+	for _, aclToken := range kvServer.aclTokens {
+		test.S(t).ExpectEquals(aclToken, "secret-token")
+	}
+}
+
+func TestEtcdStore(t *testing.T) {
+	kvServer := newFakeKVServer()
+	defer kvServer.server.Close()
+
+	testKVStore(t, NewEtcdStore("", kvServer.address()))
+}
+
+func TestEtcdStoreDeleteMissingKey(t *testing.T) {
+	kvServer := newFakeKVServer()
+	defer kvServer.server.Close()
+
+	err := NewEtcdStore("http", kvServer.address()).DeleteKeyValue("mysql/master/no-such-cluster")
+	test.S(t).ExpectNil(err)
+}
+
+func TestStoreUnreachable(t *testing.T) {
+	kvServer := newFakeKVServer()
+	address := kvServer.address()
+	kvServer.server.Close()
+
+	err := NewConsulStore("http", address, "").PutKeyValue("mysql/master/cluster1", "host1:3306")
+	test.S(t).ExpectNotNil(err)
+	err = NewEtcdStore("http", address).PutKeyValue("mysql/master/cluster1", "host1:3306")
+	test.S(t).ExpectNotNil(err)
+}
+
+func TestPutKVPairAllStores(t *testing.T) {
+	consulServer := newFakeKVServer()
+	defer consulServer.server.Close()
+	etcdServer := newFakeKVServer()
+	defer etcdServer.server.Close()
+
+	defer func(consulAddress, etcdAddress string) {
+		config.Config.ConsulAddress = consulAddress
+		config.Config.EtcdAddress = etcdAddress
+	}(config.Config.ConsulAddress, config.Config.EtcdAddress)
+
+	config.Config.ConsulAddress = ""
+	config.Config.EtcdAddress = ""
+	test.S(t).ExpectFalse(HasKVStores())
+
+	config.Config.ConsulAddress = consulServer.address()
+	config.Config.EtcdAddress = etcdServer.address()
+	test.S(t).ExpectEquals(len(GetKVStores()), 2)
+
+	err := PutKVPair(NewKVPair("mysql/master/cluster1", "host1:3306"))
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(consulServer.values["mysql/master/cluster1"], "host1:3306")
+	test.S(t).ExpectEquals(etcdServer.values["mysql/master/cluster1"], "host1:3306")
+
+	err = DeleteKey("mysql/master/cluster1")
+	test.S(t).ExpectNil(err)
+	test.S(t).ExpectEquals(len(consulServer.values), 0)
+	test.S(t).ExpectEquals(len(etcdServer.values), 0)
+}
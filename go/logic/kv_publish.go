@@ -0,0 +1,177 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package logic
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/inst"
+	"github.com/outbrain/orchestrator/go/kv"
+)
+
+var publishedKVPairsMutex sync.Mutex
+
+// publishedClusterKVPairs maps cluster aliases to the key-value entries this node has published for them
+var publishedClusterKVPairs = make(map[string]map[string]string)
+
+func init() {
+	config.OnChange(func(changes config.ConfigurationChanges) {
+		if changes.HasField("ConsulAddress", "ConsulScheme", "ConsulAclToken", "EtcdAddress", "EtcdScheme", "KVClusterMasterPrefix", "KVClusterReplicaPrefix") {
+			// Stores or keys have changed: have all entries published anew. Entries under former keys are left as they are.
+			publishedKVPairsMutex.Lock()
+			defer publishedKVPairsMutex.Unlock()
+			publishedClusterKVPairs = make(map[string]map[string]string)
+		}
+	})
+}
+
+// diffClusterKVPairs compares a cluster's current key-value entries with those previously published, and returns
+// the entries to be written and the keys to be removed. When force is given, all current entries are to be written.
+// storedKeys are keys found in the stores, regardless of having been published by this node; those which are not
+// current are removed as well.
+func diffClusterKVPairs(published map[string]string, storedKeys []string, kvPairs [](*kv.KVPair), force bool) (changedKVPairs [](*kv.KVPair), staleKeys []string) {
+	current := make(map[string]bool)
+	for _, kvPair := range kvPairs {
+		current[kvPair.Key] = true
+		if value, found := published[kvPair.Key]; force || !found || value != kvPair.Value {
+			changedKVPairs = append(changedKVPairs, kvPair)
+		}
+	}
+	for key := range published {
+		if !current[key] {
+			staleKeys = append(staleKeys, key)
+		}
+	}
+	for _, key := range storedKeys {
+		if _, found := published[key]; !found && !current[key] {
+			staleKeys = append(staleKeys, key)
+		}
+	}
+	return changedKVPairs, staleKeys
+}
+
+// submitClusterKVPairs writes changed key-value entries of a cluster to all configured stores and removes its stale
+// entries. Entries failing to submit are retried on next submission. Upon force, or the first submission of the
+// cluster by this node, the stores are listed for replica entries of the cluster, so that entries written by a
+// previous leader, or before a restart, are removed as well if no longer current.
+func submitClusterKVPairs(clusterAlias string, kvPairs [](*kv.KVPair), force bool) (submittedCount int, err error) {
+	publishedKVPairsMutex.Lock()
+	defer publishedKVPairsMutex.Unlock()
+
+	published, found := publishedClusterKVPairs[clusterAlias]
+	if !found {
+		published = make(map[string]string)
+		publishedClusterKVPairs[clusterAlias] = published
+	}
+	var storedKeys []string
+	if force || !found {
+		if storedKeys, err = kv.ListKeys(inst.GetClusterReplicaKVPrefix(clusterAlias)); err != nil {
+			log.Errore(err)
+		}
+	}
+	changedKVPairs, staleKeys := diffClusterKVPairs(published, storedKeys, kvPairs, force)
+	for _, kvPair := range changedKVPairs {
+		if submitErr := kv.PutKVPair(kvPair); submitErr != nil {
+			err = log.Errore(submitErr)
+			continue
+		}
+		log.Infof("kv: submitted %s", kvPair.String())
+		published[kvPair.Key] = kvPair.Value
+		submittedCount++
+	}
+	for _, key := range staleKeys {
+		if deleteErr := kv.DeleteKey(key); deleteErr != nil {
+			err = log.Errore(deleteErr)
+			continue
+		}
+		log.Infof("kv: removed %s", key)
+		delete(published, key)
+		submittedCount++
+	}
+	return submittedCount, err
+}
+
+// SubmitMastersToKVStores publishes the writeable master of each cluster, along with its replicating slaves,
+// to configured key-value stores. Only entries changed since last submission are written, unless force is
+// given, in which case all entries are rewritten. An empty clusterName submits all clusters.
+func SubmitMastersToKVStores(clusterName string, force bool) (kvPairs [](*kv.KVPair), submittedCount int, err error) {
+	if !kv.HasKVStores() {
+		return kvPairs, submittedCount, fmt.Errorf("No key-value stores configured. Set ConsulAddress and/or EtcdAddress")
+	}
+	masters, err := inst.ReadWriteableClustersMasters()
+	if err != nil {
+		return kvPairs, submittedCount, log.Errore(err)
+	}
+	for _, master := range masters {
+		if clusterName != "" && master.ClusterName != clusterName {
+			continue
+		}
+		if !master.IsLastCheckValid {
+			// Likely a dead master pending recovery; never announce it
+			continue
+		}
+		clusterAlias, clusterKVPairs, readErr := inst.ReadClusterKVPairs(master)
+		if readErr != nil {
+			err = log.Errore(readErr)
+			continue
+		}
+		kvPairs = append(kvPairs, clusterKVPairs...)
+		clusterSubmittedCount, submitErr := submitClusterKVPairs(clusterAlias, clusterKVPairs, force)
+		submittedCount += clusterSubmittedCount
+		if submitErr != nil {
+			err = submitErr
+		}
+	}
+	if force {
+		inst.AuditOperation("kv-resync", nil, fmt.Sprintf("Submitted %d entries; cluster: %s", submittedCount, clusterName))
+	}
+	return kvPairs, submittedCount, err
+}
+
+// SubmitClusterMasterToKVStores immediately publishes given instance as master of given cluster, as
+// upon master failover, without waiting for the instance to be re-read as a writeable master
+func SubmitClusterMasterToKVStores(clusterAlias string, masterKey *inst.InstanceKey) error {
+	if !kv.HasKVStores() {
+		return nil
+	}
+	kvPair := inst.GetClusterMasterKVPair(clusterAlias, masterKey)
+	if err := kv.PutKVPair(kvPair); err != nil {
+		return log.Errore(err)
+	}
+
+	publishedKVPairsMutex.Lock()
+	defer publishedKVPairsMutex.Unlock()
+	if _, found := publishedClusterKVPairs[clusterAlias]; !found {
+		publishedClusterKVPairs[clusterAlias] = make(map[string]string)
+	}
+	publishedClusterKVPairs[clusterAlias][kvPair.Key] = kvPair.Value
+
+	inst.AuditOperation("kv-submit-master", masterKey, kvPair.String())
+	return nil
+}
+
+// submitPromotedMasterToKVStores publishes the master promoted by a recovery of given analysis
+func submitPromotedMasterToKVStores(analysisEntry *inst.ReplicationAnalysis, promotedMasterKey *inst.InstanceKey) error {
+	clusterAlias := analysisEntry.ClusterDetails.ClusterAlias
+	if clusterAlias == "" {
+		clusterAlias = analysisEntry.ClusterDetails.ClusterName
+	}
+	return SubmitClusterMasterToKVStores(clusterAlias, promotedMasterKey)
+}
@@ -0,0 +1,85 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package logic
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/kv"
+)
+
+func TestDiffClusterKVPairs(t *testing.T) {
+	published := map[string]string{
+		"mysql/master/mycluster":             "host1:3306",
+		"mysql/replica/mycluster/host2:3306": "host2:3306",
+		"mysql/replica/mycluster/host3:3306": "host3:3306",
+	}
+	kvPairs := [](*kv.KVPair){
+		kv.NewKVPair("mysql/master/mycluster", "host1:3306"),
+		kv.NewKVPair("mysql/replica/mycluster/host2:3306", "host2:3306"),
+		kv.NewKVPair("mysql/replica/mycluster/host4:3306", "host4:3306"),
+	}
+	{
+		changedKVPairs, staleKeys := diffClusterKVPairs(published, nil, kvPairs, false)
+		test.S(t).ExpectEquals(len(changedKVPairs), 1)
+		test.S(t).ExpectEquals(changedKVPairs[0].Key, "mysql/replica/mycluster/host4:3306")
+		test.S(t).ExpectEquals(len(staleKeys), 1)
+		test.S(t).ExpectEquals(staleKeys[0], "mysql/replica/mycluster/host3:3306")
+	}
+	{
+		changedKVPairs, staleKeys := diffClusterKVPairs(published, nil, kvPairs, true)
+		test.S(t).ExpectEquals(len(changedKVPairs), 3)
+		test.S(t).ExpectEquals(len(staleKeys), 1)
+	}
+}
+
+func TestDiffClusterKVPairsMasterChange(t *testing.T) {
+	published := map[string]string{"mysql/master/mycluster": "host1:3306"}
+	kvPairs := [](*kv.KVPair){kv.NewKVPair("mysql/master/mycluster", "host2:3306")}
+
+	changedKVPairs, staleKeys := diffClusterKVPairs(published, nil, kvPairs, false)
+	test.S(t).ExpectEquals(len(changedKVPairs), 1)
+	test.S(t).ExpectEquals(changedKVPairs[0].Value, "host2:3306")
+	test.S(t).ExpectEquals(len(staleKeys), 0)
+}
+
+func TestDiffClusterKVPairsUnchanged(t *testing.T) {
+	published := map[string]string{"mysql/master/mycluster": "host1:3306"}
+	kvPairs := [](*kv.KVPair){kv.NewKVPair("mysql/master/mycluster", "host1:3306")}
+
+	changedKVPairs, staleKeys := diffClusterKVPairs(published, nil, kvPairs, false)
+	test.S(t).ExpectEquals(len(changedKVPairs), 0)
+	test.S(t).ExpectEquals(len(staleKeys), 0)
+}
+
+func TestDiffClusterKVPairsStoredKeys(t *testing.T) {
+	published := map[string]string{}
+	storedKeys := []string{
+		"mysql/replica/mycluster/host2:3306",
+		"mysql/replica/mycluster/host3:3306",
+	}
+	kvPairs := [](*kv.KVPair){
+		kv.NewKVPair("mysql/master/mycluster", "host1:3306"),
+		kv.NewKVPair("mysql/replica/mycluster/host2:3306", "host2:3306"),
+	}
+
+	changedKVPairs, staleKeys := diffClusterKVPairs(published, storedKeys, kvPairs, false)
+	test.S(t).ExpectEquals(len(changedKVPairs), 2)
+	test.S(t).ExpectEquals(len(staleKeys), 1)
+	test.S(t).ExpectEquals(staleKeys[0], "mysql/replica/mycluster/host3:3306")
+}
@@ -17,8 +17,10 @@
 package logic
 
 import (
+	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -27,6 +29,7 @@ import (
 	"github.com/outbrain/orchestrator/go/agent"
 	"github.com/outbrain/orchestrator/go/config"
 	"github.com/outbrain/orchestrator/go/inst"
+	"github.com/outbrain/orchestrator/go/kv"
 	ometrics "github.com/outbrain/orchestrator/go/metrics"
 	"github.com/outbrain/orchestrator/go/process"
 	"github.com/pmylund/go-cache"
@@ -49,8 +52,17 @@ var isElectedGauge = metrics.NewGauge()
 
 var isElectedNode int64 = 0
 
+var recentDiscoveryOperationKeysMutex sync.RWMutex
 var recentDiscoveryOperationKeys *cache.Cache
 
+// getRecentDiscoveryOperationKeys returns the cache of recently discovered instances, which is set up by continuous
+// discovery and replaced upon InstancePollSeconds change. It is nil outside continuous discovery.
+func getRecentDiscoveryOperationKeys() *cache.Cache {
+	recentDiscoveryOperationKeysMutex.RLock()
+	defer recentDiscoveryOperationKeysMutex.RUnlock()
+	return recentDiscoveryOperationKeys
+}
+
 func init() {
 	metrics.Register("discoveries.attempt", discoveriesCounter)
 	metrics.Register("discoveries.fail", failedDiscoveriesCounter)
@@ -60,12 +72,20 @@ func init() {
 
 	ometrics.OnGraphiteTick(func() { discoveryQueueLengthGauge.Update(int64(len(discoveryInstanceKeys))) })
 	ometrics.OnGraphiteTick(func() {
-		if recentDiscoveryOperationKeys == nil {
-			return
+		if recentDiscoveryOperationKeys := getRecentDiscoveryOperationKeys(); recentDiscoveryOperationKeys != nil {
+			discoveryRecentCountGauge.Update(int64(recentDiscoveryOperationKeys.ItemCount()))
 		}
-		discoveryRecentCountGauge.Update(int64(recentDiscoveryOperationKeys.ItemCount()))
 	})
 	ometrics.OnGraphiteTick(func() { isElectedGauge.Update(int64(atomic.LoadInt64(&isElectedNode))) })
+	config.OnChange(func(changes config.ConfigurationChanges) {
+		if changes.HasField("InstancePollSeconds") {
+			recentDiscoveryOperationKeysMutex.Lock()
+			defer recentDiscoveryOperationKeysMutex.Unlock()
+			if recentDiscoveryOperationKeys != nil {
+				recentDiscoveryOperationKeys = cache.New(time.Duration(config.Config.InstancePollSeconds)*time.Second, time.Second)
+			}
+		}
+	})
 }
 
 // acceptSignals registers for OS signals
@@ -78,13 +98,28 @@ func acceptSignals() {
 			switch sig {
 			case syscall.SIGHUP:
 				log.Debugf("Received SIGHUP. Reloading configuration")
-				config.Reload()
-				inst.AuditOperation("reload-configuration", nil, "Triggered via SIGHUP")
+				ReloadConfiguration("SIGHUP")
 			}
 		}
 	}()
 }
 
+// ReloadConfiguration reloads configuration files and audits the outcome: either the changed fields, or the reason
+// the configuration was not reloaded
+func ReloadConfiguration(trigger string) (changes config.ConfigurationChanges, err error) {
+	changes, err = config.Reload()
+	if err != nil {
+		inst.AuditOperation("reload-configuration", nil, fmt.Sprintf("Triggered via %s. Failed: %+v", trigger, err))
+		return changes, log.Errore(err)
+	}
+	if len(changes) == 0 {
+		inst.AuditOperation("reload-configuration", nil, fmt.Sprintf("Triggered via %s. No changes", trigger))
+	} else {
+		inst.AuditOperation("reload-configuration", nil, fmt.Sprintf("Triggered via %s. Changes: %s", trigger, changes.String()))
+	}
+	return changes, nil
+}
+
 // handleDiscoveryRequests iterates the discoveryInstanceKeys channel and calls upon
 // instance discovery per entry.
 func handleDiscoveryRequests() {
@@ -108,7 +143,7 @@ func discoverInstance(instanceKey inst.InstanceKey) {
 		return
 	}
 
-	if existsInCacheError := recentDiscoveryOperationKeys.Add(instanceKey.DisplayString(), true, cache.DefaultExpiration); existsInCacheError != nil {
+	if existsInCacheError := getRecentDiscoveryOperationKeys().Add(instanceKey.DisplayString(), true, cache.DefaultExpiration); existsInCacheError != nil {
 		// Just recently attempted
 		return
 	}
@@ -138,6 +173,10 @@ func discoverInstance(instanceKey inst.InstanceKey) {
 		return
 	}
 
+	if config.Config.ReadLongRunningQueries {
+		inst.EnforceQueryKillPolicies(instance)
+	}
+
 	// Investigate slaves:
 	for _, slaveKey := range instance.SlaveHosts.GetInstanceKeys() {
 		slaveKey := slaveKey
@@ -160,19 +199,34 @@ func ContinuousDiscovery() {
 	}
 
 	log.Infof("Starting continuous discovery")
+	recentDiscoveryOperationKeysMutex.Lock()
 	recentDiscoveryOperationKeys = cache.New(time.Duration(config.Config.InstancePollSeconds)*time.Second, time.Second)
+	recentDiscoveryOperationKeysMutex.Unlock()
 
 	inst.LoadHostnameResolveCache()
 	go handleDiscoveryRequests()
 
 	discoveryTick := time.Tick(time.Duration(config.Config.GetDiscoveryPollSeconds()) * time.Second)
-	instancePollTick := time.Tick(time.Duration(config.Config.InstancePollSeconds) * time.Second)
+	instancePollTicker := time.NewTicker(time.Duration(config.Config.InstancePollSeconds) * time.Second)
 	caretakingTick := time.Tick(time.Minute)
-	recoveryTick := time.Tick(time.Duration(config.Config.RecoveryPollSeconds) * time.Second)
+	recoveryTicker := time.NewTicker(time.Duration(config.Config.RecoveryPollSeconds) * time.Second)
+	pollIntervalsChanged := make(chan bool, 1)
+	config.OnChange(func(changes config.ConfigurationChanges) {
+		if changes.HasField("InstancePollSeconds", "RecoveryPollSeconds") {
+			select {
+			case pollIntervalsChanged <- true:
+			default:
+			}
+		}
+	})
 	var snapshotTopologiesTick <-chan time.Time
 	if config.Config.SnapshotTopologiesIntervalHours > 0 {
 		snapshotTopologiesTick = time.Tick(time.Duration(config.Config.SnapshotTopologiesIntervalHours) * time.Hour)
 	}
+	var autoPseudoGTIDTick <-chan time.Time
+	if config.Config.AutoPseudoGTID {
+		autoPseudoGTIDTick = time.Tick(time.Duration(config.Config.AutoPseudoGTIDIntervalSeconds) * time.Second)
+	}
 
 	go ometrics.InitGraphiteMetrics()
 	go acceptSignals()
@@ -219,7 +273,7 @@ func ContinuousDiscovery() {
 					log.Debugf("Not elected as active node; polling")
 				}
 			}()
-		case <-instancePollTick:
+		case <-instancePollTicker.C:
 			go func() {
 				// This tick does NOT do instance poll (these are handled by the oversmapling discoveryTick)
 				// But rather should invoke such routinely operations that need to be as (or roughly as) frequent
@@ -227,6 +281,9 @@ func ContinuousDiscovery() {
 				if atomic.LoadInt64(&isElectedNode) == 1 {
 					go inst.UpdateInstanceRecentRelaylogHistory()
 					go inst.RecordInstanceCoordinatesHistory()
+					if kv.HasKVStores() {
+						go SubmitMastersToKVStores("", false)
+					}
 				}
 			}()
 		case <-caretakingTick:
@@ -243,22 +300,25 @@ func ContinuousDiscovery() {
 					go inst.ResolveUnknownMasterHostnameResolves()
 					go inst.UpdateClusterAliases()
 					go inst.ExpireMaintenance()
+					go inst.ExpireClusterLocks()
 					go inst.ExpireDowntime()
 					go inst.ExpireCandidateInstances()
 					go inst.ExpireHostnameUnresolve()
 					go inst.ExpireClusterDomainName()
 					go inst.ExpireAudit()
 					go inst.ExpireMasterPositionEquivalence()
+					go inst.ExpirePseudoGTIDInjectionStatuses()
 					go inst.ExpirePoolInstances()
 					go inst.FlushNontrivialResolveCacheToDatabase()
 					go process.ExpireNodesHistory()
 					go process.ExpireAccessTokens()
+					go ExpireInstanceReachabilityOpinions()
 				} else {
 					// Take this opportunity to refresh yourself
 					go inst.LoadHostnameResolveCache()
 				}
 			}()
-		case <-recoveryTick:
+		case <-recoveryTicker.C:
 			go func() {
 				if atomic.LoadInt64(&isElectedNode) == 1 {
 					go ClearActiveFailureDetections()
@@ -267,12 +327,27 @@ func ContinuousDiscovery() {
 					go AcknowledgeCrashedRecoveries()
 					go inst.ExpireInstanceAnalysisChangelog()
 					go CheckAndRecover(nil, nil, false)
+				} else if config.Config.MasterFailoverNodesQuorum > 0 {
+					// Help the elected node decide whether failed masters are really unreachable
+					go PublishReachabilityOpinions()
+				}
+			}()
+		case <-autoPseudoGTIDTick:
+			go func() {
+				if atomic.LoadInt64(&isElectedNode) == 1 {
+					go inst.InjectPseudoGTIDOnWriters()
 				}
 			}()
 		case <-snapshotTopologiesTick:
 			go func() {
 				go inst.SnapshotTopologies()
 			}()
+		case <-pollIntervalsChanged:
+			// Configuration has been reloaded with new intervals
+			instancePollTicker.Stop()
+			instancePollTicker = time.NewTicker(time.Duration(config.Config.InstancePollSeconds) * time.Second)
+			recoveryTicker.Stop()
+			recoveryTicker = time.NewTicker(time.Duration(config.Config.RecoveryPollSeconds) * time.Second)
 		}
 	}
 }
@@ -314,6 +389,7 @@ func ContinuousAgentsPoll() {
 		case <-caretakingTick:
 			agent.ForgetLongUnseenAgents()
 			agent.FailStaleSeeds()
+			agent.ExpireCustomCommandAudit()
 		default:
 		}
 	}
@@ -0,0 +1,114 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package logic
+
+import (
+	"fmt"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/inst"
+	"github.com/outbrain/orchestrator/go/process"
+)
+
+// NodesReachabilityVote tells how many healthy orchestrator nodes agree that an instance is unreachable
+type NodesReachabilityVote struct {
+	InstanceKey      inst.InstanceKey
+	Quorum           float64
+	CountNodes       int
+	CountUnreachable int
+	CountReachable   int
+	CountAbstained   int
+	IsConfirmed      bool
+}
+
+// NewNodesReachabilityVote tallies the opinions of available nodes. Opinions map a node ("hostname;token") to
+// whether it was able to reach the instance. The voting node, which has its own analysis to go by, always
+// considers the instance unreachable. Nodes without a recent opinion abstain, which counts against the quorum.
+func NewNodesReachabilityVote(instanceKey inst.InstanceKey, quorum float64, thisNode string, availableNodes []string, opinions map[string]bool) *NodesReachabilityVote {
+	vote := &NodesReachabilityVote{InstanceKey: instanceKey, Quorum: quorum}
+	nodes := map[string]bool{thisNode: true}
+	for _, node := range availableNodes {
+		nodes[node] = true
+	}
+	for node := range nodes {
+		vote.CountNodes++
+		if node == thisNode {
+			vote.CountUnreachable++
+			continue
+		}
+		isReachable, found := opinions[node]
+		switch {
+		case !found:
+			vote.CountAbstained++
+		case isReachable:
+			vote.CountReachable++
+		default:
+			vote.CountUnreachable++
+		}
+	}
+	vote.IsConfirmed = float64(vote.CountUnreachable) >= quorum*float64(vote.CountNodes)
+	return vote
+}
+
+// Description returns a human readable summary of the vote
+func (this *NodesReachabilityVote) Description() string {
+	outcome := "unconfirmed"
+	if this.IsConfirmed {
+		outcome = "confirmed"
+	}
+	return fmt.Sprintf("%s: %d unreachable, %d reachable, %d abstained out of %d orchestrator nodes; quorum: %.2f",
+		outcome, this.CountUnreachable, this.CountReachable, this.CountAbstained, this.CountNodes, this.Quorum)
+}
+
+// ConfirmMasterUnreachableByNodes collects the recent opinions of healthy orchestrator nodes on the given master
+func ConfirmMasterUnreachableByNodes(masterKey *inst.InstanceKey) (*NodesReachabilityVote, error) {
+	availableNodes, err := process.ReadAvailableNodes(true)
+	if err != nil {
+		return nil, log.Errore(err)
+	}
+	opinions, err := readInstanceReachabilityOpinions(masterKey)
+	if err != nil {
+		return nil, log.Errore(err)
+	}
+	thisNode := fmt.Sprintf("%s;%s", process.ThisHostname, process.ProcessToken.Hash)
+	vote := NewNodesReachabilityVote(*masterKey, config.Config.MasterFailoverNodesQuorum, thisNode, availableNodes, opinions)
+	log.Infof("topology_recovery: master %+v unreachable by orchestrator nodes %s", *masterKey, vote.Description())
+	return vote, nil
+}
+
+// probeInstanceReachability checks whether this node is able to query the given instance
+func probeInstanceReachability(instanceKey inst.InstanceKey) {
+	var dummy int
+	err := inst.ScanInstanceRow(&instanceKey, "select 1", &dummy)
+	writeInstanceReachabilityOpinion(&instanceKey, err)
+}
+
+// PublishReachabilityOpinions has this node probe masters which replication analysis finds unreachable,
+// and publish its own view to the backend, for the elected node to consider before failing over.
+func PublishReachabilityOpinions() error {
+	replicationAnalysis, err := inst.GetReplicationAnalysis("", true, false)
+	if err != nil {
+		return log.Errore(err)
+	}
+	for _, analysisEntry := range replicationAnalysis {
+		if (analysisEntry.IsMaster || analysisEntry.IsCoMaster) && !analysisEntry.LastCheckValid {
+			go probeInstanceReachability(analysisEntry.AnalyzedInstanceKey)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package logic
+
+import (
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/golib/sqlutils"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/db"
+	"github.com/outbrain/orchestrator/go/inst"
+	"github.com/outbrain/orchestrator/go/process"
+)
+
+// writeInstanceReachabilityOpinion records this node's opinion on whether it can reach given instance
+func writeInstanceReachabilityOpinion(instanceKey *inst.InstanceKey, probeErr error) error {
+	probeError := ""
+	if probeErr != nil {
+		probeError = probeErr.Error()
+	}
+	_, err := db.ExecOrchestrator(`
+			insert into node_instance_reachability (
+					hostname, port, node_hostname, node_token, is_reachable, probe_error, last_probed
+				) values (
+					?, ?, ?, ?, ?, ?, NOW()
+				)
+				on duplicate key update
+					node_token=values(node_token),
+					is_reachable=values(is_reachable),
+					probe_error=values(probe_error),
+					last_probed=values(last_probed)
+			`, instanceKey.Hostname, instanceKey.Port, process.ThisHostname, process.ProcessToken.Hash, (probeErr == nil), probeError,
+	)
+	return log.Errore(err)
+}
+
+// readInstanceReachabilityOpinions reads recent opinions on given instance, mapping "hostname;token" of
+// each publishing node to whether it could reach the instance
+func readInstanceReachabilityOpinions(instanceKey *inst.InstanceKey) (map[string]bool, error) {
+	opinions := make(map[string]bool)
+	query := `
+		select
+			concat(node_hostname, ';', node_token) as node,
+			is_reachable
+		from
+			node_instance_reachability
+		where
+			hostname = ?
+			and port = ?
+			and last_probed > now() - interval ? second
+		`
+	err := db.QueryOrchestrator(query, sqlutils.Args(instanceKey.Hostname, instanceKey.Port, 3*config.Config.RecoveryPollSeconds), func(m sqlutils.RowMap) error {
+		opinions[m.GetString("node")] = m.GetBool("is_reachable")
+		return nil
+	})
+	return opinions, log.Errore(err)
+}
+
+// ExpireInstanceReachabilityOpinions removes stale opinions
+func ExpireInstanceReachabilityOpinions() error {
+	_, err := db.ExecOrchestrator(`
+			delete
+				from node_instance_reachability
+			where
+				last_probed < now() - interval ? second
+			`, config.Config.RecoveryPeriodBlockSeconds,
+	)
+	return log.Errore(err)
+}
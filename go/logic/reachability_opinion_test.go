@@ -0,0 +1,59 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package logic
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/inst"
+)
+
+var reachabilityMasterKey = inst.InstanceKey{Hostname: "master", Port: 3306}
+
+func TestNewNodesReachabilityVote(t *testing.T) {
+	availableNodes := []string{"node1;t1", "node2;t2", "node3;t3"}
+	{
+		vote := NewNodesReachabilityVote(reachabilityMasterKey, 0.5, "node1;t1", availableNodes, map[string]bool{"node2;t2": false})
+		test.S(t).ExpectTrue(vote.IsConfirmed)
+		test.S(t).ExpectEquals(vote.CountNodes, 3)
+		test.S(t).ExpectEquals(vote.CountUnreachable, 2)
+		test.S(t).ExpectEquals(vote.CountAbstained, 1)
+	}
+	{
+		vote := NewNodesReachabilityVote(reachabilityMasterKey, 0.5, "node1;t1", availableNodes, map[string]bool{"node2;t2": true, "node3;t3": true})
+		test.S(t).ExpectFalse(vote.IsConfirmed)
+		test.S(t).ExpectEquals(vote.CountReachable, 2)
+	}
+	{
+		// Abstaining nodes count against the quorum
+		vote := NewNodesReachabilityVote(reachabilityMasterKey, 0.5, "node1;t1", availableNodes, map[string]bool{})
+		test.S(t).ExpectFalse(vote.IsConfirmed)
+	}
+	{
+		// Opinions of nodes which are not (or no longer) healthy are ignored
+		vote := NewNodesReachabilityVote(reachabilityMasterKey, 1, "node1;t1", availableNodes, map[string]bool{"node2;t2": false, "node3;t3": false, "node4;t4": true})
+		test.S(t).ExpectTrue(vote.IsConfirmed)
+		test.S(t).ExpectEquals(vote.CountNodes, 3)
+	}
+	{
+		// This node votes even if it is not listed as available
+		vote := NewNodesReachabilityVote(reachabilityMasterKey, 1, "node9;t9", []string{}, map[string]bool{})
+		test.S(t).ExpectTrue(vote.IsConfirmed)
+		test.S(t).ExpectEquals(vote.CountNodes, 1)
+	}
+}
@@ -20,11 +20,13 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/outbrain/golib/log"
 	"github.com/outbrain/orchestrator/go/attributes"
 	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/dns"
 	"github.com/outbrain/orchestrator/go/inst"
 	"github.com/outbrain/orchestrator/go/os"
 	"github.com/outbrain/orchestrator/go/process"
@@ -65,6 +67,8 @@ type TopologyRecovery struct {
 	AcknowledgedComment       string
 	LastDetectionId           int64
 	RelatedRecoveryId         int64
+	DeadMasterConfirmation    *DeadMasterConfirmation
+	DNSUpdate                 *dns.RecordUpdate
 }
 
 func NewTopologyRecovery(replicationAnalysis inst.ReplicationAnalysis) *TopologyRecovery {
@@ -102,6 +106,26 @@ const (
 var emptySlavesList [](*inst.Instance)
 
 var emergencyReadTopologyInstanceMap = cache.New(time.Duration(config.Config.InstancePollSeconds)*time.Second, time.Second)
+var deadMasterConfirmationsInProgress = cache.New(time.Minute, time.Second)
+var recentCustomAnalysisDetections = cache.New(time.Duration(config.Config.RecoveryPollSeconds*2)*time.Second, time.Second)
+
+// recoveryCachesMutex guards emergencyReadTopologyInstanceMap and recentCustomAnalysisDetections, which are replaced
+// upon InstancePollSeconds and RecoveryPollSeconds change, respectively
+var recoveryCachesMutex sync.RWMutex
+
+// getEmergencyReadTopologyInstanceMap returns the cache of instances recently read upon emergency
+func getEmergencyReadTopologyInstanceMap() *cache.Cache {
+	recoveryCachesMutex.RLock()
+	defer recoveryCachesMutex.RUnlock()
+	return emergencyReadTopologyInstanceMap
+}
+
+// getRecentCustomAnalysisDetections returns the cache of recently detected custom analysis
+func getRecentCustomAnalysisDetections() *cache.Cache {
+	recoveryCachesMutex.RLock()
+	defer recoveryCachesMutex.RUnlock()
+	return recentCustomAnalysisDetections
+}
 
 // InstancesByCountSlaves sorts instances by umber of slaves, descending
 type InstancesByCountSlaves [](*inst.Instance)
@@ -119,6 +143,7 @@ func (this InstancesByCountSlaves) Less(i, j int) bool {
 var recoverDeadMasterCounter = metrics.NewCounter()
 var recoverDeadMasterSuccessCounter = metrics.NewCounter()
 var recoverDeadMasterFailureCounter = metrics.NewCounter()
+var recoverDeadMasterUnconfirmedCounter = metrics.NewCounter()
 var recoverDeadIntermediateMasterCounter = metrics.NewCounter()
 var recoverDeadIntermediateMasterSuccessCounter = metrics.NewCounter()
 var recoverDeadIntermediateMasterFailureCounter = metrics.NewCounter()
@@ -130,12 +155,24 @@ func init() {
 	metrics.Register("recover.dead_master.start", recoverDeadMasterCounter)
 	metrics.Register("recover.dead_master.success", recoverDeadMasterSuccessCounter)
 	metrics.Register("recover.dead_master.fail", recoverDeadMasterFailureCounter)
+	metrics.Register("recover.dead_master.unconfirmed", recoverDeadMasterUnconfirmedCounter)
 	metrics.Register("recover.dead_intermediate_master.start", recoverDeadIntermediateMasterCounter)
 	metrics.Register("recover.dead_intermediate_master.success", recoverDeadIntermediateMasterSuccessCounter)
 	metrics.Register("recover.dead_intermediate_master.fail", recoverDeadIntermediateMasterFailureCounter)
 	metrics.Register("recover.dead_co_master.start", recoverDeadCoMasterCounter)
 	metrics.Register("recover.dead_co_master.success", recoverDeadCoMasterSuccessCounter)
 	metrics.Register("recover.dead_co_master.fail", recoverDeadCoMasterFailureCounter)
+	config.OnChange(func(changes config.ConfigurationChanges) {
+		recoveryCachesMutex.Lock()
+		defer recoveryCachesMutex.Unlock()
+
+		if changes.HasField("InstancePollSeconds") {
+			emergencyReadTopologyInstanceMap = cache.New(time.Duration(config.Config.InstancePollSeconds)*time.Second, time.Second)
+		}
+		if changes.HasField("RecoveryPollSeconds") {
+			recentCustomAnalysisDetections = cache.New(time.Duration(config.Config.RecoveryPollSeconds*2)*time.Second, time.Second)
+		}
+	})
 }
 
 // replaceCommandPlaceholders replaces agreed-upon placeholders with analysis data
@@ -196,7 +233,7 @@ func recoverDeadMasterInBinlogServerTopology(topologyRecovery *TopologyRecovery)
 
 	var promotedBinlogServer *inst.Instance
 
-	_, promotedBinlogServer, err = inst.RegroupSlavesBinlogServers(failedMasterKey, true)
+	_, promotedBinlogServer, err = inst.RegroupSlavesBinlogServersLocked(failedMasterKey, true)
 	if err != nil {
 		return nil, log.Errore(err)
 	}
@@ -312,11 +349,11 @@ func RecoverDeadMaster(topologyRecovery *TopologyRecovery, skipProcesses bool) (
 	switch masterRecoveryType {
 	case MasterRecoveryGTID:
 		{
-			lostSlaves, _, cannotReplicateSlaves, promotedSlave, err = inst.RegroupSlavesGTID(failedInstanceKey, true, nil)
+			lostSlaves, _, cannotReplicateSlaves, promotedSlave, err = inst.RegroupSlavesGTIDLocked(failedInstanceKey, true, nil)
 		}
 	case MasterRecoveryPseudoGTID:
 		{
-			lostSlaves, _, _, cannotReplicateSlaves, promotedSlave, err = inst.RegroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers(failedInstanceKey, true, nil, &topologyRecovery.PostponedFunctionsContainer)
+			lostSlaves, _, _, cannotReplicateSlaves, promotedSlave, err = inst.RegroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServersLocked(failedInstanceKey, true, nil, &topologyRecovery.PostponedFunctionsContainer)
 		}
 	case MasterRecoveryBinlogServer:
 		{
@@ -444,7 +481,7 @@ func replacePromotedSlaveWithCandidate(deadInstanceKey *inst.InstanceKey, promot
 
 	if candidateInstance.MasterKey.Equals(&promotedSlave.Key) {
 		log.Debugf("topology_recovery: suggested candidate %+v is slave of promoted instance %+v. Will try and enslave its master", *candidateInstanceKey, promotedSlave.Key)
-		candidateInstance, err = inst.EnslaveMaster(&candidateInstance.Key)
+		candidateInstance, err = inst.EnslaveMasterLocked(&candidateInstance.Key)
 		if err != nil {
 			return promotedSlave, log.Errore(err)
 		}
@@ -456,17 +493,99 @@ func replacePromotedSlaveWithCandidate(deadInstanceKey *inst.InstanceKey, promot
 	return promotedSlave, nil
 }
 
+// checkClusterLockForRecovery checks whether the cluster of the failed instance is locked by some
+// operation. Unless configured to preempt such locks, recovery is not attempted on a locked cluster.
+// This check has no side effects; the lock is only preempted by lockClusterForRecovery.
+func checkClusterLockForRecovery(analysisEntry inst.ReplicationAnalysis) error {
+	clusterName := analysisEntry.ClusterDetails.ClusterName
+	clusterLock, err := inst.ReadClusterLock(clusterName)
+	if err != nil {
+		return err
+	}
+	if clusterLock == nil || config.Config.PreemptClusterLockOnRecovery {
+		return nil
+	}
+	return log.Errorf("topology_recovery: cluster %+v is locked by %+v (%+v); will not recover %+v", clusterName, clusterLock.Owner, clusterLock.Reason, analysisEntry.AnalyzedInstanceKey)
+}
+
+// lockClusterForRecovery acquires the lock on the cluster of the failed instance, to be held throughout the
+// recovery so that no other operation refactors the cluster meanwhile. A lock held by another operation is
+// preempted when so configured. To be called only once the recovery is registered (and confirmed).
+func lockClusterForRecovery(topologyRecovery *TopologyRecovery) (lockToken string, err error) {
+	analysisEntry := &topologyRecovery.AnalysisEntry
+	clusterName := analysisEntry.ClusterDetails.ClusterName
+	reason := fmt.Sprintf("recovery of %+v (%+v)", analysisEntry.AnalyzedInstanceKey, analysisEntry.Analysis)
+
+	lockToken, err = inst.BeginClusterLock(clusterName, inst.GetMaintenanceOwner(), reason)
+	if err == nil || !config.Config.PreemptClusterLockOnRecovery {
+		return lockToken, err
+	}
+	clusterLock, err := inst.ReadClusterLock(clusterName)
+	if err != nil {
+		return "", err
+	}
+	if clusterLock != nil {
+		log.Warningf("topology_recovery: preempting lock on cluster %+v held by %+v (%+v) for recovery of %+v", clusterName, clusterLock.Owner, clusterLock.Reason, analysisEntry.AnalyzedInstanceKey)
+		if err := inst.ForceEndClusterLock(clusterName, fmt.Sprintf("preempted by %s", reason)); err != nil {
+			return "", err
+		}
+	}
+	return inst.BeginClusterLock(clusterName, inst.GetMaintenanceOwner(), reason)
+}
+
 // checkAndRecoverDeadMaster checks a given analysis, decides whether to take action, and possibly takes action
 // Returns true when action was taken.
 func checkAndRecoverDeadMaster(analysisEntry inst.ReplicationAnalysis, candidateInstanceKey *inst.InstanceKey, forceInstanceRecovery bool, skipProcesses bool) (bool, *TopologyRecovery, error) {
 	if !(forceInstanceRecovery || analysisEntry.ClusterDetails.HasAutomatedMasterRecovery) {
 		return false, nil, nil
 	}
+	if err := checkClusterLockForRecovery(analysisEntry); err != nil {
+		return false, nil, err
+	}
+	if config.Config.MasterFailoverNodesQuorum > 0 && !forceInstanceRecovery {
+		// Make sure it's not us who are partitioned away from the master
+		nodesVote, err := ConfirmMasterUnreachableByNodes(&analysisEntry.AnalyzedInstanceKey)
+		if err != nil {
+			return false, nil, err
+		}
+		if !nodesVote.IsConfirmed {
+			recoverDeadMasterUnconfirmedCounter.Inc(1)
+			inst.AuditOperation("dead-master-nodes-unconfirmed", &analysisEntry.AnalyzedInstanceKey, nodesVote.Description())
+			return false, nil, log.Errorf("topology_recovery: DeadMaster %+v not confirmed by orchestrator nodes (%s). Will not issue RecoverDeadMaster.", analysisEntry.AnalyzedInstanceKey, nodesVote.Description())
+		}
+	}
+	var deadMasterConfirmation *DeadMasterConfirmation
+	if config.Config.DeadMasterConfirmationSeconds > 0 && !forceInstanceRecovery {
+		// Let the slaves have their say before we act upon our own failure to reach the master
+		confirmationExpiry := time.Duration(2*config.Config.DeadMasterConfirmationSeconds) * time.Second
+		if err := deadMasterConfirmationsInProgress.Add(analysisEntry.AnalyzedInstanceKey.StringCode(), true, confirmationExpiry); err != nil {
+			log.Debugf("topology_recovery: DeadMaster confirmation already in progress on %+v", analysisEntry.AnalyzedInstanceKey)
+			return false, nil, nil
+		}
+		deadMasterConfirmation = ConfirmDeadMaster(&analysisEntry)
+		deadMasterConfirmationsInProgress.Delete(analysisEntry.AnalyzedInstanceKey.StringCode())
+		if !deadMasterConfirmation.IsConfirmed {
+			recoverDeadMasterUnconfirmedCounter.Inc(1)
+			inst.AuditOperation("dead-master-unconfirmed", &analysisEntry.AnalyzedInstanceKey, deadMasterConfirmation.Description())
+			return false, nil, log.Errorf("topology_recovery: DeadMaster %+v not confirmed by slaves (%s). Will not issue RecoverDeadMaster.", analysisEntry.AnalyzedInstanceKey, deadMasterConfirmation.Description())
+		}
+	}
 	topologyRecovery, err := AttemptRecoveryRegistration(&analysisEntry, !forceInstanceRecovery, !forceInstanceRecovery)
 	if topologyRecovery == nil {
 		log.Debugf("topology_recovery: found an active or recent recovery on %+v. Will not issue another RecoverDeadMaster.", analysisEntry.AnalyzedInstanceKey)
 		return false, nil, err
 	}
+	if deadMasterConfirmation != nil {
+		topologyRecovery.DeadMasterConfirmation = deadMasterConfirmation
+		writeTopologyRecoveryDeadMasterConfirmation(topologyRecovery)
+	}
+	lockToken, err := lockClusterForRecovery(topologyRecovery)
+	if err != nil {
+		topologyRecovery.AddError(err)
+		ResolveRecovery(topologyRecovery, nil)
+		return false, topologyRecovery, log.Errore(err)
+	}
+	defer inst.EndClusterLock(lockToken)
 
 	// That's it! We must do recovery!
 	log.Debugf("topology_recovery: will handle DeadMaster event on %+v", analysisEntry.ClusterDetails.ClusterName)
@@ -489,6 +608,8 @@ func checkAndRecoverDeadMaster(analysisEntry inst.ReplicationAnalysis, candidate
 			inst.ResetSlaveOperation(&promotedSlave.Key)
 			inst.SetReadOnly(&promotedSlave.Key, false)
 		}
+		submitPromotedMasterToKVStores(&analysisEntry, &promotedSlave.Key)
+		updateClusterDomainDNS(topologyRecovery, &promotedSlave.Key)
 		if !skipProcesses {
 			// Execute post master-failover processes
 			executeProcesses(config.Config.PostMasterFailoverProcesses, "PostMasterFailoverProcesses", topologyRecovery, false)
@@ -641,7 +762,7 @@ func RecoverDeadIntermediateMaster(topologyRecovery *TopologyRecovery, skipProce
 		}
 		// We have a candidate
 		log.Debugf("topology_recovery: - RecoverDeadIntermediateMaster: will attempt a candidate intermediate master: %+v", candidateSiblingOfIntermediateMaster.Key)
-		relocatedSlaves, candidateSibling, err, errs := inst.RelocateSlaves(failedInstanceKey, &candidateSiblingOfIntermediateMaster.Key, "")
+		relocatedSlaves, candidateSibling, err, errs := inst.RelocateSlavesLocked(failedInstanceKey, &candidateSiblingOfIntermediateMaster.Key, "")
 		topologyRecovery.AddErrors(errs)
 		topologyRecovery.ParticipatingInstanceKeys.AddKey(candidateSiblingOfIntermediateMaster.Key)
 
@@ -667,7 +788,7 @@ func RecoverDeadIntermediateMaster(topologyRecovery *TopologyRecovery, skipProce
 	if !recoveryResolved {
 		log.Debugf("topology_recovery: - RecoverDeadIntermediateMaster: will next attempt regrouping of slaves")
 		// Plan B: regroup (we wish to reduce cross-DC replication streams)
-		_, _, _, _, regroupPromotedSlave, err := inst.RegroupSlaves(failedInstanceKey, true, nil, nil)
+		_, _, _, _, regroupPromotedSlave, err := inst.RegroupSlavesLocked(failedInstanceKey, true, nil, nil)
 		if err != nil {
 			topologyRecovery.AddError(err)
 			log.Debugf("topology_recovery: - RecoverDeadIntermediateMaster: regroup failed on: %+v", err)
@@ -692,7 +813,7 @@ func RecoverDeadIntermediateMaster(topologyRecovery *TopologyRecovery, skipProce
 
 		var errs []error
 		var relocatedSlaves [](*inst.Instance)
-		relocatedSlaves, successorInstance, err, errs = inst.RelocateSlaves(failedInstanceKey, &analysisEntry.AnalyzedInstanceMasterKey, "")
+		relocatedSlaves, successorInstance, err, errs = inst.RelocateSlavesLocked(failedInstanceKey, &analysisEntry.AnalyzedInstanceMasterKey, "")
 		topologyRecovery.AddErrors(errs)
 		topologyRecovery.ParticipatingInstanceKeys.AddKey(analysisEntry.AnalyzedInstanceMasterKey)
 
@@ -717,11 +838,21 @@ func checkAndRecoverDeadIntermediateMaster(analysisEntry inst.ReplicationAnalysi
 	if !(forceInstanceRecovery || analysisEntry.ClusterDetails.HasAutomatedIntermediateMasterRecovery) {
 		return false, nil, nil
 	}
+	if err := checkClusterLockForRecovery(analysisEntry); err != nil {
+		return false, nil, err
+	}
 	topologyRecovery, err := AttemptRecoveryRegistration(&analysisEntry, !forceInstanceRecovery, !forceInstanceRecovery)
 	if topologyRecovery == nil {
 		log.Debugf("topology_recovery: found an active or recent recovery on %+v. Will not issue another RecoverDeadIntermediateMaster.", analysisEntry.AnalyzedInstanceKey)
 		return false, nil, err
 	}
+	lockToken, err := lockClusterForRecovery(topologyRecovery)
+	if err != nil {
+		topologyRecovery.AddError(err)
+		ResolveRecovery(topologyRecovery, nil)
+		return false, topologyRecovery, log.Errore(err)
+	}
+	defer inst.EndClusterLock(lockToken)
 
 	// That's it! We must do recovery!
 	recoverDeadIntermediateMasterCounter.Inc(1)
@@ -771,11 +902,11 @@ func RecoverDeadCoMaster(topologyRecovery *TopologyRecovery, skipProcesses bool)
 	switch coMasterRecoveryType {
 	case MasterRecoveryGTID:
 		{
-			lostSlaves, _, cannotReplicateSlaves, promotedSlave, err = inst.RegroupSlavesGTID(failedInstanceKey, true, nil)
+			lostSlaves, _, cannotReplicateSlaves, promotedSlave, err = inst.RegroupSlavesGTIDLocked(failedInstanceKey, true, nil)
 		}
 	case MasterRecoveryPseudoGTID:
 		{
-			lostSlaves, _, _, cannotReplicateSlaves, promotedSlave, err = inst.RegroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServers(failedInstanceKey, true, nil, &topologyRecovery.PostponedFunctionsContainer)
+			lostSlaves, _, _, cannotReplicateSlaves, promotedSlave, err = inst.RegroupSlavesPseudoGTIDIncludingSubSlavesOfBinlogServersLocked(failedInstanceKey, true, nil, &topologyRecovery.PostponedFunctionsContainer)
 		}
 	}
 	topologyRecovery.AddError(err)
@@ -867,11 +998,21 @@ func checkAndRecoverDeadCoMaster(analysisEntry inst.ReplicationAnalysis, candida
 	if !(forceInstanceRecovery || analysisEntry.ClusterDetails.HasAutomatedMasterRecovery) {
 		return false, nil, nil
 	}
+	if err := checkClusterLockForRecovery(analysisEntry); err != nil {
+		return false, nil, err
+	}
 	topologyRecovery, err := AttemptRecoveryRegistration(&analysisEntry, !forceInstanceRecovery, !forceInstanceRecovery)
 	if topologyRecovery == nil {
 		log.Debugf("topology_recovery: found an active or recent recovery on %+v. Will not issue another RecoverDeadCoMaster.", analysisEntry.AnalyzedInstanceKey)
 		return false, nil, err
 	}
+	lockToken, err := lockClusterForRecovery(topologyRecovery)
+	if err != nil {
+		topologyRecovery.AddError(err)
+		ResolveRecovery(topologyRecovery, nil)
+		return false, topologyRecovery, log.Errore(err)
+	}
+	defer inst.EndClusterLock(lockToken)
 
 	// That's it! We must do recovery!
 	recoverDeadCoMasterCounter.Inc(1)
@@ -891,6 +1032,7 @@ func checkAndRecoverDeadCoMaster(analysisEntry inst.ReplicationAnalysis, candida
 			log.Debugf("topology_recovery: - RecoverDeadMaster: will apply MySQL changes to promoted master")
 			inst.SetReadOnly(&promotedSlave.Key, false)
 		}
+		submitPromotedMasterToKVStores(&analysisEntry, &promotedSlave.Key)
 		if !skipProcesses {
 			// Execute post intermediate-master-failover processes
 			topologyRecovery.SuccessorKey = &promotedSlave.Key
@@ -911,7 +1053,7 @@ func checkAndRecoverGenericProblem(analysisEntry inst.ReplicationAnalysis, candi
 // Force a re-read of a topology instance; this is done because we need to substantiate a suspicion
 // that we may have a failover scenario. we want to speed up reading the complete picture.
 func emergentlyReadTopologyInstance(instanceKey *inst.InstanceKey, analysisCode inst.AnalysisCode) {
-	if existsInCacheError := emergencyReadTopologyInstanceMap.Add(instanceKey.StringCode(), true, cache.DefaultExpiration); existsInCacheError != nil {
+	if existsInCacheError := getEmergencyReadTopologyInstanceMap().Add(instanceKey.StringCode(), true, cache.DefaultExpiration); existsInCacheError != nil {
 		// Just recently attempted
 		return
 	}
@@ -948,11 +1090,38 @@ func checkAndExecuteFailureDetectionProcesses(analysisEntry inst.ReplicationAnal
 	return true, err
 }
 
+// executeCustomAnalysisProcesses executes the OnDetectionProcesses of custom analysis rules which have just started
+// matching given instance. Custom analysis never leads to recovery.
+func executeCustomAnalysisProcesses(analysisEntry inst.ReplicationAnalysis) {
+	for _, customAnalysis := range analysisEntry.CustomAnalysis {
+		detectionKey := fmt.Sprintf("%s;%s", analysisEntry.AnalyzedInstanceKey.DisplayString(), customAnalysis.Code)
+		_, detectedRecently := getRecentCustomAnalysisDetections().Get(detectionKey)
+		getRecentCustomAnalysisDetections().Set(detectionKey, true, cache.DefaultExpiration)
+		if detectedRecently {
+			continue
+		}
+		log.Debugf("topology_recovery: detected custom analysis %+v on %+v", customAnalysis.Code, analysisEntry.AnalyzedInstanceKey)
+		for _, rule := range config.Config.CustomAnalysisRules {
+			if rule.Name != string(customAnalysis.Code) {
+				continue
+			}
+			customAnalysisEntry := analysisEntry
+			customAnalysisEntry.Analysis = customAnalysis.Code
+			customAnalysisEntry.Description = customAnalysis.Description
+			executeProcesses(rule.OnDetectionProcesses, "OnDetectionProcesses", NewTopologyRecovery(customAnalysisEntry), false)
+		}
+	}
+}
+
 // executeCheckAndRecoverFunction will choose the correct check & recovery function based on analysis.
 // It executes the function synchronuously
 func executeCheckAndRecoverFunction(analysisEntry inst.ReplicationAnalysis, candidateInstanceKey *inst.InstanceKey, forceInstanceRecovery bool, skipProcesses bool) (recoveryAttempted bool, topologyRecovery *TopologyRecovery, err error) {
 	var checkAndRecoverFunction func(analysisEntry inst.ReplicationAnalysis, candidateInstanceKey *inst.InstanceKey, forceInstanceRecovery bool, skipProcesses bool) (recoveryAttempted bool, topologyRecovery *TopologyRecovery, err error) = nil
 
+	if analysisEntry.IsReplicationGroupMember || analysisEntry.IsGaleraNode {
+		// A replication group or a Galera cluster manages its own membership; we stay away
+		return false, nil, nil
+	}
 	switch analysisEntry.Analysis {
 	case inst.DeadMaster:
 		checkAndRecoverFunction = checkAndRecoverDeadMaster
@@ -1039,6 +1208,9 @@ func CheckAndRecover(specificInstance *inst.InstanceKey, candidateInstanceKey *i
 			// Only recover a downtimed server if explicitly requested
 			continue
 		}
+		if len(analysisEntry.CustomAnalysis) > 0 && specificInstance == nil && !skipProcesses {
+			go executeCustomAnalysisProcesses(analysisEntry)
+		}
 
 		if specificInstance != nil {
 			// force mode. Keep it synchronuous
@@ -1062,6 +1234,14 @@ func ForceExecuteRecovery(clusterName string, analysisCode inst.AnalysisCode, fa
 	if err != nil {
 		return recoveryAttempted, topologyRecovery, err
 	}
+	if failedInstance, _, err := inst.ReadInstance(failedInstanceKey); err == nil && failedInstance != nil {
+		if failedInstance.IsReplicationGroupMember() {
+			return recoveryAttempted, topologyRecovery, fmt.Errorf("%+v is a member of replication group %s, which manages its own failover. Will not recover", *failedInstanceKey, failedInstance.ReplicationGroupName)
+		}
+		if failedInstance.IsGaleraNode() {
+			return recoveryAttempted, topologyRecovery, fmt.Errorf("%+v is a Galera node, which manages its own membership. Will not recover", *failedInstanceKey)
+		}
+	}
 
 	analysisEntry := inst.ReplicationAnalysis{
 		Analysis:            analysisCode,
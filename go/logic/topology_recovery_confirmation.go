@@ -0,0 +1,175 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package logic
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/inst"
+)
+
+type DeadMasterVote string
+
+const (
+	DeadMasterVoteDead        DeadMasterVote = "dead"
+	DeadMasterVoteAlive                      = "alive"
+	DeadMasterVoteUndecided                  = "undecided"
+	DeadMasterVoteUnreachable                = "unreachable"
+)
+
+// SlaveDeadMasterVote is a single slave's opinion, based on its own replication status, on whether its master is dead
+type SlaveDeadMasterVote struct {
+	Key                   inst.InstanceKey
+	Vote                  DeadMasterVote
+	Slave_IO_Running      bool
+	SecondsBehindMaster   sql.NullInt64
+	ReadBinlogCoordinates inst.BinlogCoordinates
+	ReceivedProgress      bool
+}
+
+// DeadMasterConfirmation is the outcome of polling a master's slaves before recovering it as a DeadMaster
+type DeadMasterConfirmation struct {
+	MasterKey        inst.InstanceKey
+	WindowSeconds    int
+	Quorum           float64
+	Votes            []SlaveDeadMasterVote
+	CountDead        int
+	CountAlive       int
+	CountUndecided   int
+	CountUnreachable int
+	IsConfirmed      bool
+}
+
+// NewDeadMasterConfirmation tallies given votes against the given quorum
+func NewDeadMasterConfirmation(masterKey inst.InstanceKey, windowSeconds int, quorum float64, votes []SlaveDeadMasterVote) *DeadMasterConfirmation {
+	confirmation := &DeadMasterConfirmation{
+		MasterKey:     masterKey,
+		WindowSeconds: windowSeconds,
+		Quorum:        quorum,
+		Votes:         votes,
+	}
+	for _, vote := range votes {
+		switch vote.Vote {
+		case DeadMasterVoteDead:
+			confirmation.CountDead++
+		case DeadMasterVoteAlive:
+			confirmation.CountAlive++
+		case DeadMasterVoteUndecided:
+			confirmation.CountUndecided++
+		default:
+			confirmation.CountUnreachable++
+		}
+	}
+	// All slaves which could be read count towards the quorum, undecided included; at least one slave must confirm
+	// the master is gone.
+	countPolled := confirmation.CountDead + confirmation.CountAlive + confirmation.CountUndecided
+	confirmation.IsConfirmed = confirmation.CountDead > 0 && float64(confirmation.CountDead) >= quorum*float64(countPolled)
+	return confirmation
+}
+
+// Description returns a human readable summary of the vote
+func (this *DeadMasterConfirmation) Description() string {
+	outcome := "unconfirmed"
+	if this.IsConfirmed {
+		outcome = "confirmed"
+	}
+	return fmt.Sprintf("%s: %d dead, %d alive, %d undecided, %d unreachable out of %d slaves over %ds; quorum: %.2f",
+		outcome, this.CountDead, this.CountAlive, this.CountUndecided, this.CountUnreachable, len(this.Votes), this.WindowSeconds, this.Quorum)
+}
+
+// voteOnDeadMaster deduces a slave's vote from two samples of its replication status, taken at the
+// beginning and at the end of the confirmation window.
+// A slave whose received coordinates advanced has heard from its master and votes "alive". A slave whose
+// IO thread was not running throughout, received nothing, and is failing to connect to its master votes "dead".
+// Anything else is undecided: e.g. an idle master and a dead master whose slaves did not yet time out look the
+// same, and a slave whose IO thread was stopped by hand knows nothing of its master.
+func voteOnDeadMaster(slaveKey inst.InstanceKey, masterKey *inst.InstanceKey, first *inst.Instance, last *inst.Instance) SlaveDeadMasterVote {
+	vote := SlaveDeadMasterVote{Key: slaveKey, Vote: DeadMasterVoteUnreachable}
+	if first == nil || last == nil {
+		return vote
+	}
+	vote.Slave_IO_Running = last.Slave_IO_Running
+	vote.SecondsBehindMaster = last.SecondsBehindMaster
+	vote.ReadBinlogCoordinates = last.ReadBinlogCoordinates
+
+	if !first.MasterKey.Equals(masterKey) || !last.MasterKey.Equals(masterKey) {
+		// No longer replicating from this master; has no say
+		vote.Vote = DeadMasterVoteUndecided
+		return vote
+	}
+	vote.ReceivedProgress = first.ReadBinlogCoordinates.SmallerThan(&last.ReadBinlogCoordinates)
+	if vote.ReceivedProgress {
+		vote.Vote = DeadMasterVoteAlive
+		return vote
+	}
+	if !first.Slave_IO_Running && last.IsFailingToConnectToMaster() {
+		vote.Vote = DeadMasterVoteDead
+		return vote
+	}
+	vote.Vote = DeadMasterVoteUndecided
+	return vote
+}
+
+// sampleSlaves concurrently reads given slaves; unreachable slaves map to nil
+func sampleSlaves(slaveKeys []inst.InstanceKey) map[inst.InstanceKey]*inst.Instance {
+	samples := make(map[inst.InstanceKey]*inst.Instance)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, slaveKey := range slaveKeys {
+		slaveKey := slaveKey
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			instance, err := inst.ReadTopologyInstance(&slaveKey)
+			if err != nil {
+				instance = nil
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			samples[slaveKey] = instance
+		}()
+	}
+	wg.Wait()
+	return samples
+}
+
+// ConfirmDeadMaster polls the slaves of a presumably dead master, directly and over a short window, and
+// checks whether a quorum of them agrees the master is gone. This protects against failing over a master
+// which is only unreachable from orchestrator's own point of view, e.g. due to a network partition.
+func ConfirmDeadMaster(analysisEntry *inst.ReplicationAnalysis) *DeadMasterConfirmation {
+	masterKey := analysisEntry.AnalyzedInstanceKey
+	slaveKeys := analysisEntry.SlaveHosts.GetInstanceKeys()
+	windowSeconds := config.Config.DeadMasterConfirmationSeconds
+
+	log.Debugf("topology_recovery: confirming DeadMaster %+v via %d slaves over %ds", masterKey, len(slaveKeys), windowSeconds)
+	firstSamples := sampleSlaves(slaveKeys)
+	time.Sleep(time.Duration(windowSeconds) * time.Second)
+	lastSamples := sampleSlaves(slaveKeys)
+
+	votes := []SlaveDeadMasterVote{}
+	for _, slaveKey := range slaveKeys {
+		votes = append(votes, voteOnDeadMaster(slaveKey, &masterKey, firstSamples[slaveKey], lastSamples[slaveKey]))
+	}
+	confirmation := NewDeadMasterConfirmation(masterKey, windowSeconds, config.Config.DeadMasterConfirmationQuorum, votes)
+	log.Infof("topology_recovery: DeadMaster %+v %s", masterKey, confirmation.Description())
+	return confirmation
+}
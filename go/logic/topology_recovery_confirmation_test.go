@@ -0,0 +1,104 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package logic
+
+import (
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/inst"
+)
+
+var confirmationMasterKey = inst.InstanceKey{Hostname: "master", Port: 3306}
+var confirmationSlaveKey = inst.InstanceKey{Hostname: "slave", Port: 3306}
+
+func newConfirmationSample(ioRunning bool, logPos int64) *inst.Instance {
+	instance := inst.NewInstance()
+	instance.Key = confirmationSlaveKey
+	instance.MasterKey = confirmationMasterKey
+	instance.Slave_IO_Running = ioRunning
+	if !ioRunning {
+		instance.LastIOError = "error reconnecting to master 'repl@master:3306' - retry-time: 60  retries: 86400"
+	}
+	instance.ReadBinlogCoordinates = inst.BinlogCoordinates{LogFile: "mysql-bin.000017", LogPos: logPos}
+	return instance
+}
+
+func TestVoteOnDeadMaster(t *testing.T) {
+	vote := voteOnDeadMaster(confirmationSlaveKey, &confirmationMasterKey, newConfirmationSample(false, 120), newConfirmationSample(false, 120))
+	test.S(t).ExpectEquals(vote.Vote, DeadMasterVoteDead)
+
+	vote = voteOnDeadMaster(confirmationSlaveKey, &confirmationMasterKey, newConfirmationSample(true, 120), newConfirmationSample(true, 4510))
+	test.S(t).ExpectEquals(vote.Vote, DeadMasterVote(DeadMasterVoteAlive))
+	test.S(t).ExpectTrue(vote.ReceivedProgress)
+
+	// A slave which reconnected and received events has heard from its master
+	vote = voteOnDeadMaster(confirmationSlaveKey, &confirmationMasterKey, newConfirmationSample(false, 120), newConfirmationSample(false, 4510))
+	test.S(t).ExpectEquals(vote.Vote, DeadMasterVote(DeadMasterVoteAlive))
+
+	// Idle master, or a dead one not yet timed out by the slave
+	vote = voteOnDeadMaster(confirmationSlaveKey, &confirmationMasterKey, newConfirmationSample(true, 120), newConfirmationSample(true, 120))
+	test.S(t).ExpectEquals(vote.Vote, DeadMasterVote(DeadMasterVoteUndecided))
+
+	vote = voteOnDeadMaster(confirmationSlaveKey, &confirmationMasterKey, newConfirmationSample(false, 120), nil)
+	test.S(t).ExpectEquals(vote.Vote, DeadMasterVote(DeadMasterVoteUnreachable))
+
+	// IO thread stopped by hand: no connection error
+	stoppedSample := newConfirmationSample(false, 120)
+	stoppedSample.LastIOError = ""
+	vote = voteOnDeadMaster(confirmationSlaveKey, &confirmationMasterKey, stoppedSample, stoppedSample)
+	test.S(t).ExpectEquals(vote.Vote, DeadMasterVote(DeadMasterVoteUndecided))
+
+	movedSample := newConfirmationSample(false, 120)
+	movedSample.MasterKey = inst.InstanceKey{Hostname: "other", Port: 3306}
+	vote = voteOnDeadMaster(confirmationSlaveKey, &confirmationMasterKey, newConfirmationSample(false, 120), movedSample)
+	test.S(t).ExpectEquals(vote.Vote, DeadMasterVote(DeadMasterVoteUndecided))
+}
+
+func newConfirmationVotes(votes ...DeadMasterVote) []SlaveDeadMasterVote {
+	result := []SlaveDeadMasterVote{}
+	for _, vote := range votes {
+		result = append(result, SlaveDeadMasterVote{Key: confirmationSlaveKey, Vote: vote})
+	}
+	return result
+}
+
+func TestNewDeadMasterConfirmation(t *testing.T) {
+	confirmation := NewDeadMasterConfirmation(confirmationMasterKey, 5, 1, newConfirmationVotes(DeadMasterVoteDead, DeadMasterVoteDead, DeadMasterVoteUnreachable))
+	test.S(t).ExpectTrue(confirmation.IsConfirmed)
+	test.S(t).ExpectEquals(confirmation.CountDead, 2)
+	test.S(t).ExpectEquals(confirmation.CountUnreachable, 1)
+
+	// Undecided slaves count towards the quorum
+	confirmation = NewDeadMasterConfirmation(confirmationMasterKey, 5, 1, newConfirmationVotes(DeadMasterVoteDead, DeadMasterVoteDead, DeadMasterVoteUndecided))
+	test.S(t).ExpectFalse(confirmation.IsConfirmed)
+	test.S(t).ExpectEquals(confirmation.CountUndecided, 1)
+	confirmation = NewDeadMasterConfirmation(confirmationMasterKey, 5, 0.5, newConfirmationVotes(DeadMasterVoteDead, DeadMasterVoteUndecided, DeadMasterVoteUndecided, DeadMasterVoteUndecided))
+	test.S(t).ExpectFalse(confirmation.IsConfirmed)
+
+	confirmation = NewDeadMasterConfirmation(confirmationMasterKey, 5, 1, newConfirmationVotes(DeadMasterVoteDead, DeadMasterVoteDead, DeadMasterVoteAlive))
+	test.S(t).ExpectFalse(confirmation.IsConfirmed)
+
+	confirmation = NewDeadMasterConfirmation(confirmationMasterKey, 5, 0.5, newConfirmationVotes(DeadMasterVoteDead, DeadMasterVoteDead, DeadMasterVoteAlive))
+	test.S(t).ExpectTrue(confirmation.IsConfirmed)
+
+	confirmation = NewDeadMasterConfirmation(confirmationMasterKey, 5, 0.5, newConfirmationVotes(DeadMasterVoteUndecided, DeadMasterVoteUnreachable))
+	test.S(t).ExpectFalse(confirmation.IsConfirmed)
+
+	confirmation = NewDeadMasterConfirmation(confirmationMasterKey, 5, 1, newConfirmationVotes())
+	test.S(t).ExpectFalse(confirmation.IsConfirmed)
+}
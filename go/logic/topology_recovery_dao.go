@@ -17,6 +17,7 @@
 package logic
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -24,6 +25,7 @@ import (
 	"github.com/outbrain/golib/sqlutils"
 	"github.com/outbrain/orchestrator/go/config"
 	"github.com/outbrain/orchestrator/go/db"
+	"github.com/outbrain/orchestrator/go/dns"
 	"github.com/outbrain/orchestrator/go/inst"
 	"github.com/outbrain/orchestrator/go/process"
 )
@@ -392,6 +394,38 @@ func ResolveRecovery(topologyRecovery *TopologyRecovery, successorInstance *inst
 	return log.Errore(err)
 }
 
+// writeTopologyRecoveryDeadMasterConfirmation records the slaves' vote which preceded a DeadMaster recovery
+func writeTopologyRecoveryDeadMasterConfirmation(topologyRecovery *TopologyRecovery) error {
+	confirmation, err := json.Marshal(topologyRecovery.DeadMasterConfirmation)
+	if err != nil {
+		return log.Errore(err)
+	}
+	_, err = db.ExecOrchestrator(`
+			update topology_recovery set
+				dead_master_confirmation = ?
+			where
+				recovery_id = ?
+			`, string(confirmation), topologyRecovery.Id,
+	)
+	return log.Errore(err)
+}
+
+// writeTopologyRecoveryDNSUpdate records the outcome of updating the cluster domain's DNS record upon recovery
+func writeTopologyRecoveryDNSUpdate(topologyRecovery *TopologyRecovery) error {
+	dnsUpdate, err := json.Marshal(topologyRecovery.DNSUpdate)
+	if err != nil {
+		return log.Errore(err)
+	}
+	_, err = db.ExecOrchestrator(`
+			update topology_recovery set
+				dns_update = ?
+			where
+				recovery_id = ?
+			`, string(dnsUpdate), topologyRecovery.Id,
+	)
+	return log.Errore(err)
+}
+
 // readRecoveries reads recovery entry/audit entires from topology_recovery
 func readRecoveries(whereCondition string, limit string, args []interface{}) ([]TopologyRecovery, error) {
 	res := []TopologyRecovery{}
@@ -422,7 +456,9 @@ func readRecoveries(whereCondition string, limit string, args []interface{}) ([]
             acknowledged_at,
             acknowledged_by,
             acknowledge_comment,
-            last_detection_id
+            last_detection_id,
+            ifnull(dead_master_confirmation, '') as dead_master_confirmation,
+            ifnull(dns_update, '') as dns_update
 		from
 			topology_recovery
 		%s
@@ -466,6 +502,18 @@ func readRecoveries(whereCondition string, limit string, args []interface{}) ([]
 		topologyRecovery.AcknowledgedComment = m.GetString("acknowledge_comment")
 
 		topologyRecovery.LastDetectionId = m.GetInt64("last_detection_id")
+		if deadMasterConfirmation := m.GetString("dead_master_confirmation"); deadMasterConfirmation != "" {
+			topologyRecovery.DeadMasterConfirmation = &DeadMasterConfirmation{}
+			if err := json.Unmarshal([]byte(deadMasterConfirmation), topologyRecovery.DeadMasterConfirmation); err != nil {
+				log.Errore(err)
+			}
+		}
+		if dnsUpdate := m.GetString("dns_update"); dnsUpdate != "" {
+			topologyRecovery.DNSUpdate = &dns.RecordUpdate{}
+			if err := json.Unmarshal([]byte(dnsUpdate), topologyRecovery.DNSUpdate); err != nil {
+				log.Errore(err)
+			}
+		}
 
 		res = append(res, topologyRecovery)
 		return nil
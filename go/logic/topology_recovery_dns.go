@@ -0,0 +1,129 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package logic
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/outbrain/golib/log"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/dns"
+	"github.com/outbrain/orchestrator/go/inst"
+)
+
+const dnsUpdateTimeout = 5 * time.Second
+
+// getClusterDomainZone returns the zone in which the cluster domain record is updated: the configured
+// DNSUpdateZone, or else the cluster domain's parent domain
+func getClusterDomainZone(clusterDomain string) string {
+	if config.Config.DNSUpdateZone != "" {
+		return dns.Fqdn(config.Config.DNSUpdateZone)
+	}
+	clusterDomain = strings.TrimSuffix(clusterDomain, ".")
+	if i := strings.Index(clusterDomain, "."); i >= 0 {
+		return dns.Fqdn(clusterDomain[i+1:])
+	}
+	return dns.Fqdn(clusterDomain)
+}
+
+// getClusterDomainRecordValue returns the value the cluster domain record should have so as to point at given master:
+// its hostname for a CNAME record, its IPv4 address for an A record
+func getClusterDomainRecordValue(recordType string, masterKey *inst.InstanceKey) (string, error) {
+	if !strings.EqualFold(recordType, "A") {
+		return masterKey.Hostname, nil
+	}
+	if ip := net.ParseIP(masterKey.Hostname); ip != nil && ip.To4() != nil {
+		return ip.String(), nil
+	}
+	ips, err := net.LookupIP(masterKey.Hostname)
+	if err != nil {
+		return "", err
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip.String(), nil
+		}
+	}
+	return "", fmt.Errorf("No IPv4 address found for %s", masterKey.Hostname)
+}
+
+// readClusterInfoFunc reads the cluster's domain, which analysis does not load. Tests point it at fixtures.
+var readClusterInfoFunc = inst.ReadClusterInfo
+
+// updateDNSRecordFunc sends the dynamic update and verifies it. Tests point it at a stub.
+var updateDNSRecordFunc = func(key *dns.TSIGKey, zone string, name string, value string) *dns.RecordUpdate {
+	client := dns.NewClient(config.Config.DNSUpdateServer, key, dnsUpdateTimeout)
+	verifyTimeout := time.Duration(config.Config.DNSUpdateVerifySeconds) * time.Second
+	return client.UpdateRecord(zone, name, config.Config.DNSUpdateRecordType, value, uint32(config.Config.DNSUpdateTTL), verifyTimeout)
+}
+
+// recordDNSUpdateFunc records the outcome with the recovery and audits it. Tests point it at a stub.
+var recordDNSUpdateFunc = func(topologyRecovery *TopologyRecovery, promotedMasterKey *inst.InstanceKey) {
+	writeTopologyRecoveryDNSUpdate(topologyRecovery)
+	inst.AuditOperation("dns-update", promotedMasterKey, topologyRecovery.DNSUpdate.Description())
+}
+
+// getClusterDomain returns the domain of the recovered cluster: as analyzed, if known, or else as read from the
+// backend, where it is registered by DetectClusterDomainQuery
+func getClusterDomain(analysisEntry *inst.ReplicationAnalysis) string {
+	if analysisEntry.ClusterDetails.ClusterDomain != "" {
+		return analysisEntry.ClusterDetails.ClusterDomain
+	}
+	clusterInfo, err := readClusterInfoFunc(analysisEntry.ClusterDetails.ClusterName)
+	if err != nil {
+		log.Errore(err)
+		return ""
+	}
+	return clusterInfo.ClusterDomain
+}
+
+// updateClusterDomainDNS points the recovered cluster's domain DNS record at the promoted master via a dynamic update,
+// and verifies the update by lookup. The outcome is recorded with the recovery and audited.
+func updateClusterDomainDNS(topologyRecovery *TopologyRecovery, promotedMasterKey *inst.InstanceKey) *dns.RecordUpdate {
+	if config.Config.DNSUpdateServer == "" {
+		return nil
+	}
+	clusterDomain := getClusterDomain(&topologyRecovery.AnalysisEntry)
+	if clusterDomain == "" {
+		log.Debugf("topology_recovery: no cluster domain for %+v; will not update DNS", topologyRecovery.AnalysisEntry.ClusterDetails.ClusterName)
+		return nil
+	}
+	log.Debugf("topology_recovery: updating DNS record of %s to point at %+v", clusterDomain, *promotedMasterKey)
+
+	zone := getClusterDomainZone(clusterDomain)
+	recordUpdate := &dns.RecordUpdate{Server: config.Config.DNSUpdateServer, Zone: zone, Name: dns.Fqdn(clusterDomain), Type: config.Config.DNSUpdateRecordType}
+	var key *dns.TSIGKey
+	value, err := getClusterDomainRecordValue(config.Config.DNSUpdateRecordType, promotedMasterKey)
+	if err == nil && config.Config.DNSUpdateTSIGKeyName != "" {
+		key, err = dns.NewTSIGKey(config.Config.DNSUpdateTSIGKeyName, config.Config.DNSUpdateTSIGAlgorithm, config.Config.DNSUpdateTSIGSecret)
+	}
+	if err == nil {
+		recordUpdate = updateDNSRecordFunc(key, zone, clusterDomain, value)
+	} else {
+		recordUpdate.Error = err.Error()
+	}
+
+	topologyRecovery.DNSUpdate = recordUpdate
+	recordDNSUpdateFunc(topologyRecovery, promotedMasterKey)
+	if recordUpdate.Error != "" {
+		topologyRecovery.AddError(log.Errorf("topology_recovery: DNS update failed: %s", recordUpdate.Description()))
+	}
+	return recordUpdate
+}
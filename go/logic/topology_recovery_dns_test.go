@@ -0,0 +1,118 @@
+/*
+   Copyright 2015 Shlomi Noach, courtesy Booking.com
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package logic
+
+import (
+	"fmt"
+	"testing"
+
+	test "github.com/outbrain/golib/tests"
+	"github.com/outbrain/orchestrator/go/config"
+	"github.com/outbrain/orchestrator/go/dns"
+	"github.com/outbrain/orchestrator/go/inst"
+)
+
+func TestGetClusterDomainZone(t *testing.T) {
+	defer func(zone string) { config.Config.DNSUpdateZone = zone }(config.Config.DNSUpdateZone)
+
+	config.Config.DNSUpdateZone = ""
+	test.S(t).ExpectEquals(getClusterDomainZone("mycluster.db.mycompany.com"), "db.mycompany.com.")
+	test.S(t).ExpectEquals(getClusterDomainZone("mycluster.db.mycompany.com."), "db.mycompany.com.")
+
+	config.Config.DNSUpdateZone = "mycompany.com"
+	test.S(t).ExpectEquals(getClusterDomainZone("mycluster.db.mycompany.com"), "mycompany.com.")
+}
+
+func TestGetClusterDomainRecordValue(t *testing.T) {
+	{
+		value, err := getClusterDomainRecordValue("CNAME", &inst.InstanceKey{Hostname: "db-2.mycompany.com", Port: 3306})
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(value, "db-2.mycompany.com")
+	}
+	{
+		value, err := getClusterDomainRecordValue("A", &inst.InstanceKey{Hostname: "10.0.0.2", Port: 3306})
+		test.S(t).ExpectNil(err)
+		test.S(t).ExpectEquals(value, "10.0.0.2")
+	}
+}
+
+// withDNSUpdateStubs has updateClusterDomainDNS run against given cluster domains, keyed by cluster name, and
+// collects the DNS updates it sends and records
+func withDNSUpdateStubs(clusterDomains map[string]string, f func(sent *[]dns.RecordUpdate, recorded *[]dns.RecordUpdate)) {
+	defer func(server, recordType string) {
+		config.Config.DNSUpdateServer = server
+		config.Config.DNSUpdateRecordType = recordType
+	}(config.Config.DNSUpdateServer, config.Config.DNSUpdateRecordType)
+	defer func(readFunc func(string) (*inst.ClusterInfo, error)) { readClusterInfoFunc = readFunc }(readClusterInfoFunc)
+	defer func(updateFunc func(*dns.TSIGKey, string, string, string) *dns.RecordUpdate) {
+		updateDNSRecordFunc = updateFunc
+	}(updateDNSRecordFunc)
+	defer func(recordFunc func(*TopologyRecovery, *inst.InstanceKey)) { recordDNSUpdateFunc = recordFunc }(recordDNSUpdateFunc)
+
+	config.Config.DNSUpdateServer = "ns1.mycompany.com:53"
+	config.Config.DNSUpdateRecordType = "CNAME"
+	sent := []dns.RecordUpdate{}
+	recorded := []dns.RecordUpdate{}
+	readClusterInfoFunc = func(clusterName string) (*inst.ClusterInfo, error) {
+		clusterDomain, found := clusterDomains[clusterName]
+		if !found {
+			return &inst.ClusterInfo{}, fmt.Errorf("No cluster info found for %s", clusterName)
+		}
+		return &inst.ClusterInfo{ClusterName: clusterName, ClusterDomain: clusterDomain}, nil
+	}
+	updateDNSRecordFunc = func(key *dns.TSIGKey, zone string, name string, value string) *dns.RecordUpdate {
+		recordUpdate := dns.RecordUpdate{Zone: zone, Name: name, Value: value, Updated: true, Verified: true}
+		sent = append(sent, recordUpdate)
+		return &recordUpdate
+	}
+	recordDNSUpdateFunc = func(topologyRecovery *TopologyRecovery, promotedMasterKey *inst.InstanceKey) {
+		recorded = append(recorded, *topologyRecovery.DNSUpdate)
+	}
+	f(&sent, &recorded)
+}
+
+func TestUpdateClusterDomainDNS(t *testing.T) {
+	withDNSUpdateStubs(map[string]string{"db-1:3306": "mycluster.db.mycompany.com"}, func(sent *[]dns.RecordUpdate, recorded *[]dns.RecordUpdate) {
+		// As analyzed: cluster name and alias only, no domain
+		analysisEntry := inst.ReplicationAnalysis{AnalyzedInstanceKey: inst.InstanceKey{Hostname: "db-1", Port: 3306}}
+		analysisEntry.ClusterDetails.ClusterName = "db-1:3306"
+		analysisEntry.ClusterDetails.ClusterAlias = "mycluster"
+		topologyRecovery := NewTopologyRecovery(analysisEntry)
+
+		recordUpdate := updateClusterDomainDNS(topologyRecovery, &inst.InstanceKey{Hostname: "db-2.mycompany.com", Port: 3306})
+		test.S(t).ExpectNotNil(recordUpdate)
+		test.S(t).ExpectEquals(len(*sent), 1)
+		test.S(t).ExpectEquals((*sent)[0].Zone, "db.mycompany.com.")
+		test.S(t).ExpectEquals((*sent)[0].Name, "mycluster.db.mycompany.com")
+		test.S(t).ExpectEquals((*sent)[0].Value, "db-2.mycompany.com")
+		test.S(t).ExpectEquals(len(*recorded), 1)
+		test.S(t).ExpectTrue(topologyRecovery.DNSUpdate == recordUpdate)
+		test.S(t).ExpectEquals(len(topologyRecovery.AllErrors), 0)
+	})
+}
+
+func TestUpdateClusterDomainDNSNoDomain(t *testing.T) {
+	withDNSUpdateStubs(map[string]string{"db-1:3306": ""}, func(sent *[]dns.RecordUpdate, recorded *[]dns.RecordUpdate) {
+		analysisEntry := inst.ReplicationAnalysis{}
+		analysisEntry.ClusterDetails.ClusterName = "db-1:3306"
+
+		recordUpdate := updateClusterDomainDNS(NewTopologyRecovery(analysisEntry), &inst.InstanceKey{Hostname: "db-2", Port: 3306})
+		test.S(t).ExpectTrue(recordUpdate == nil)
+		test.S(t).ExpectEquals(len(*sent), 0)
+		test.S(t).ExpectEquals(len(*recorded), 0)
+	})
+}
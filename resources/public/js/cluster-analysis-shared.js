@@ -18,4 +18,7 @@ var interestingAnalysis = {
 	"AllIntermediateMasterSlavesNotReplicating" : true,
 	"UnreachableIntermediateMaster" : true,
 	"BinlogServerFailingToConnectToMaster" : true,
+	"MultipleWritersInCluster" : true,
+	"ReadOnlyMaster" : true,
+	"WritableSlave" : true,
 };
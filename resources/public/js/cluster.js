@@ -1590,6 +1590,12 @@ function Cluster() {
         addAlert('A <strong>' + blockedRecovery.Analysis + '</strong> on ' + getInstanceTitle(blockedRecovery.FailedInstanceKey.Hostname, blockedRecovery.FailedInstanceKey.Port) + ' is blocked due to a <a href="' + appUrl('/web/audit-recovery/cluster/' + blockedRecovery.ClusterName) + '">previous recovery</a>');
       });
     });
+    getData("/api/cluster-locks/" + currentClusterName(), function(clusterLocks) {
+      // Result is an array: either empty (cluster not locked) or with a single entry
+      clusterLocks.forEach(function(clusterLock) {
+        addAlert('This cluster is locked by <strong>' + clusterLock.Owner + '</strong> since ' + clusterLock.BeginTimestamp + ': ' + clusterLock.Reason + '. Refactoring operations on this cluster are blocked until the lock is released.');
+      });
+    });
     getData("/api/cluster-osc-slaves/" + currentClusterName(), function(instances) {
       var instancesMap = normalizeInstances(instances, Array());
       var instancesTitles = Array();
@@ -289,6 +289,19 @@ function openNodeModal(node) {
     addNodeModalDataAttribute("Seconds behind master", node.SecondsBehindMaster.Valid ? node.SecondsBehindMaster.Int64 : "null");
     addNodeModalDataAttribute("Replication lag", node.SlaveLagSeconds.Valid ? node.SlaveLagSeconds.Int64 : "null");
     addNodeModalDataAttribute("SQL delay", node.SQLDelay);
+    if (node.isMultiSource) {
+      addNodeModalDataAttribute("Replication channels", node.ReplicationChannels.map(function(channel) {
+        return "'" + channel.Name + "': " + canonizeInstanceTitle(channel.MasterKey.Hostname + ":" + channel.MasterKey.Port) +
+          (channel.Slave_IO_Running && channel.Slave_SQL_Running ? " (running)" : " (not running)");
+      }).join("<br>"));
+    }
+
+    if (node.isReplicationGroupMember) {
+      addNodeModalDataAttribute("Replication group", node.ReplicationGroupName + " (" + (node.ReplicationGroupIsSinglePrimary ? "single-primary" : "multi-primary") + ")");
+      addNodeModalDataAttribute("Group members", node.ReplicationGroupMembers.map(function(member) {
+        return canonizeInstanceTitle(member.Key.Hostname + ":" + member.Key.Port) + ": " + member.Role + " " + member.State;
+      }).join("<br>"));
+    }
 
     var masterCoordinatesEl = addNodeModalDataAttribute("Master coordinates", node.ExecBinlogCoordinates.LogFile + ":" + node.ExecBinlogCoordinates.LogPos);
     $('#node_modal [data-btn-group=move-equivalent] ul').empty();
@@ -353,9 +366,18 @@ function openNodeModal(node) {
   }
 
   addNodeModalDataAttribute("Semi-sync enforced", booleanString(node.SemiSyncEnforced));
+  if (node.isGaleraNode) {
+    addNodeModalDataAttribute("Galera state", node.GaleraLocalStateComment + " (" + node.GaleraClusterStatus + " component, " + node.GaleraClusterSize + " nodes)");
+    addNodeModalDataAttribute("Galera flow control", "paused " + node.GaleraFlowControlPaused + ", sent " + node.GaleraFlowControlSent + ", received " + node.GaleraFlowControlRecv);
+  }
 
   addNodeModalDataAttribute("Uptime", node.Uptime);
-  addNodeModalDataAttribute("Allow TLS", node.AllowTLS);
+  var td = addNodeModalDataAttribute("Allow TLS", node.AllowTLS);
+  $('#node_modal button[data-btn=enable-replication-tls]').appendTo(td.find("div"))
+  $('#node_modal button[data-btn=disable-replication-tls]').appendTo(td.find("div"))
+  if (node.AllowTLS && node.MasterSSL.CA) {
+    addNodeModalDataAttribute("Replication TLS CA", node.MasterSSL.CA);
+  }
   addNodeModalDataAttribute("Cluster",
     '<a href="' + appUrl('/web/cluster/' + node.ClusterName) + '">' + node.ClusterName + '</a>');
   addNodeModalDataAttribute("Audit",
@@ -443,6 +465,26 @@ function openNodeModal(node) {
       }
     });
   });
+  $('#node_modal button[data-btn=enable-replication-tls]').click(function() {
+    var message = "<p>Are you sure you wish to enable replication TLS on <code><strong>" + node.Key.Hostname + ":" + node.Key.Port +
+      "</strong></code>?" +
+      "<p>Replication <i>might</i> break as consequence";
+    bootbox.confirm(message, function(confirm) {
+      if (confirm) {
+        apiCommand("/api/enable-replication-tls/" + node.Key.Hostname + "/" + node.Key.Port);
+      }
+    });
+  });
+  $('#node_modal button[data-btn=disable-replication-tls]').click(function() {
+    var message = "<p>Are you sure you wish to disable replication TLS on <code><strong>" + node.Key.Hostname + ":" + node.Key.Port +
+      "</strong></code>?" +
+      "<p>Replication will be unencrypted";
+    bootbox.confirm(message, function(confirm) {
+      if (confirm) {
+        apiCommand("/api/disable-replication-tls/" + node.Key.Hostname + "/" + node.Key.Port);
+      }
+    });
+  });
   $('#node_modal button[data-btn=forget-instance]').click(function() {
     var message = "<p>Are you sure you wish to forget <code><strong>" + node.Key.Hostname + ":" + node.Key.Port +
       "</strong></code>?" +
@@ -508,6 +550,16 @@ function openNodeModal(node) {
     $('#node_modal button[data-btn=enable-gtid]').show();
   }
 
+  $('#node_modal button[data-btn=enable-replication-tls]').hide();
+  $('#node_modal button[data-btn=disable-replication-tls]').hide();
+  if (node.MasterKey.Hostname) {
+    if (node.AllowTLS) {
+      $('#node_modal button[data-btn=disable-replication-tls]').show();
+    } else {
+      $('#node_modal button[data-btn=enable-replication-tls]').show();
+    }
+  }
+
   $('#node_modal button[data-btn=regroup-slaves]').hide();
   if (node.SlaveHosts.length > 1) {
     $('#node_modal button[data-btn=regroup-slaves]').show();
@@ -582,7 +634,17 @@ function normalizeInstance(instance) {
   instance.replicationLagReasonable = Math.abs(instance.SlaveLagSeconds.Int64 - instance.SQLDelay) <= 10;
   instance.isSeenRecently = instance.SecondsSinceLastSeen.Valid && instance.SecondsSinceLastSeen.Int64 <= 3600;
   instance.usingGTID = instance.UsingOracleGTID || instance.UsingMariaDBGTID;
+  instance.isMultiSource = (instance.ReplicationChannels != null && instance.ReplicationChannels.length > 1);
+  instance.additionalUpstreams = (instance.ReplicationChannels || []).filter(function(channel) {
+    return channel.Name != instance.ReplicationChannel;
+  }).map(function(channel) {
+    return channel.MasterKey.Hostname + ":" + channel.MasterKey.Port + " ('" + channel.Name + "')";
+  });
   instance.isMaxScale = (instance.Version.indexOf("maxscale") >= 0);
+  instance.isReplicationGroupMember = (instance.ReplicationGroupName != "" && instance.ReplicationGroupMemberState != "" && instance.ReplicationGroupMemberState != "OFFLINE");
+  instance.isReplicationGroupSecondary = (instance.isReplicationGroupMember && instance.ReplicationGroupMemberRole == "SECONDARY");
+  instance.replicationGroupPrimaryId = getInstanceId(instance.ReplicationGroupPrimaryKey.Hostname, instance.ReplicationGroupPrimaryKey.Port);
+  instance.isGaleraNode = (instance.GaleraClusterStateUUID != "");
 
   // used by cluster-tree
   instance.children = [];
@@ -620,6 +682,9 @@ function normalizeInstanceProblem(instance) {
   instance.replicationLagProblem = function() {
     return !instance.replicationLagReasonable;
   }
+  instance.hostProblem = function() {
+    return (instance.AgentAnalysis != null && instance.AgentAnalysis.length > 0);
+  }
 
   instance.problem = null;
   instance.problemOrder = 0;
@@ -644,6 +709,12 @@ function normalizeInstanceProblem(instance) {
     instance.problem = "replication_lag";
     instance.problemDescription = "Slave is lagging in replication.\nThis diagnostic is based on either Seconds_behind_master or configured SlaveLagQuery";
     instance.problemOrder = 5;
+  } else if (instance.hostProblem()) {
+    instance.problem = "host_problem";
+    instance.problemDescription = instance.AgentAnalysis.map(function(agentAnalysis) {
+      return agentAnalysis.Description;
+    }).join("\n");
+    instance.problemOrder = 6;
   }
   instance.hasProblem = (instance.problem != null);
   instance.hasConnectivityProblem = (!instance.IsLastCheckValid || !instance.IsRecentlyChecked);
@@ -708,6 +779,18 @@ function normalizeInstances(instances, maintenanceList) {
   instances.forEach(function(instance) {
     // add to parent
     var parent = instancesMap[instance.masterId];
+    if (!parent && instance.isReplicationGroupSecondary) {
+      // Group secondaries are drawn under their group's primary
+      parent = instancesMap[instance.replicationGroupPrimaryId];
+    }
+    if (!parent && instance.isGaleraNode && !instance.MasterKey.Hostname) {
+      // Galera nodes are drawn under the node their cluster is named by
+      var clusterNameTokens = instance.ClusterName.split(":");
+      var galeraClusterId = getInstanceId(clusterNameTokens[0], clusterNameTokens[1]);
+      if (galeraClusterId != instance.id) {
+        parent = instancesMap[galeraClusterId];
+      }
+    }
     if (parent) {
       instance.parent = parent;
       instance.masterNode = parent;
@@ -811,6 +894,15 @@ function renderInstanceElement(popoverElement, instance, renderType) {
     if (instance.HasReplicationFilters) {
       popoverElement.find("h3 div.pull-right").prepend('<span class="glyphicon glyphicon-filter" title="Using replication filters"></span> ');
     }
+    if (instance.isReplicationGroupMember) {
+      popoverElement.find("h3 div.pull-right").prepend('<span class="glyphicon glyphicon-link" title="Replication group member: ' + instance.ReplicationGroupMemberRole + ', ' + instance.ReplicationGroupMemberState + '"></span> ');
+    }
+    if (instance.isGaleraNode) {
+      popoverElement.find("h3 div.pull-right").prepend('<span class="glyphicon glyphicon-link" title="Galera node: ' + instance.GaleraClusterStatus + ', ' + instance.GaleraLocalStateComment + '"></span> ');
+    }
+    if (instance.isMultiSource) {
+      popoverElement.find("h3 div.pull-right").prepend('<span class="glyphicon glyphicon-random" title="Multi-source; also replicates from: ' + instance.additionalUpstreams.join(", ") + '"></span> ');
+    }
     if (instance.LogBinEnabled && instance.LogSlaveUpdatesEnabled && !(instance.isMaster && !instance.isCoMaster)) {
       popoverElement.find("h3 div.pull-right").prepend('<span class="glyphicon glyphicon-forward" title="Logs slave updates"></span> ');
     }
@@ -840,6 +932,8 @@ function renderInstanceElement(popoverElement, instance, renderType) {
       instance.renderHint = "danger";
     } else if (instance.replicationLagProblem()) {
       instance.renderHint = "warning";
+    } else if (instance.hostProblem()) {
+      instance.renderHint = "warning";
     }
     if (instance.renderHint != "") {
       popoverElement.find("h3").addClass("label-" + instance.renderHint);